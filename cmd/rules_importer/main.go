@@ -0,0 +1,607 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"firestore-clone/internal/firestore/adapter/persistence/mongodb"
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/shared/logger"
+
+	"github.com/google/cel-go/cel"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+	"firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/rules_translator/usecase"
+)
+
+const (
+	version = "1.0.0"
+	appName = "firestore-rules-importer"
+)
+
+// Códigos de salida para pipelines de CI
+const (
+	exitOK           = 0 // Reglas válidas (y desplegadas si no es dry run)
+	exitInvalidRules = 1 // Errores de sintaxis, traducción o compilación de las reglas
+	exitUsage        = 2 // Argumentos, configuración o archivo de reglas inválidos
+	exitDeployFailed = 3 // Motor de seguridad inaccesible o despliegue fallido
+)
+
+// errMongoNotConfigured indica que falta la configuración de MongoDB en el entorno
+var errMongoNotConfigured = errors.New("MONGODB_URI y DATABASE_NAME deben estar definidos en el entorno o .env")
+
+// wildcardPattern extrae los wildcards de un match, como userId en /users/{userId}
+var wildcardPattern = regexp.MustCompile(`\{([^}=]+)(=\*\*)?\}`)
+
+// Config estructura de configuración de la aplicación
+type Config struct {
+	RulesFile    string
+	ProjectID    string
+	DatabaseID   string
+	DryRun       bool
+	Verbose      bool
+	NoCache      bool
+	Optimize     bool
+	ValidateOnly bool
+	UseMock      bool
+	// AllowInsecure despliega las reglas aunque el linter encuentre errores de seguridad
+	AllowInsecure bool
+	User          string
+	OutputFormat  string
+}
+
+// App estructura principal de la aplicación
+type App struct {
+	config     *Config
+	parser     domain.RulesParser
+	translator domain.RulesTranslator
+	linter     domain.RulesLinter
+	celEnv     *cel.Env
+	stdout     io.Writer
+	stderr     io.Writer
+
+	// newEngine crea el motor de seguridad solo cuando hace falta, así validar
+	// reglas no requiere MongoDB
+	newEngine func(ctx context.Context) (repository.SecurityRulesEngine, error)
+	// history guarda los rulesets desplegados; en MongoDB junto al motor, en memoria con -mock
+	history adapter.DeployHistoryStore
+}
+
+// Diagnostic es un error o advertencia de las reglas con su posición en el archivo
+type Diagnostic struct {
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // "error", "warning" o "info"
+	Type     string `json:"type"`     // "lexical", "syntax", "semantic", "translation", "compile" o "security"
+	Message  string `json:"message"`
+	Fix      string `json:"fix,omitempty"` // Corrección sugerida de los hallazgos de seguridad
+}
+
+// Report es el resultado de una ejecución, en texto o JSON
+type Report struct {
+	RulesFile      string               `json:"rules_file"`
+	ProjectID      string               `json:"project_id"`
+	DatabaseID     string               `json:"database_id"`
+	Mode           string               `json:"mode"` // "validate", "dry-run" o "deploy"
+	Valid          bool                 `json:"valid"`
+	RuleCount      int                  `json:"rule_count"`
+	RulesGenerated int                  `json:"rules_generated,omitempty"`
+	Diagnostics    []Diagnostic         `json:"diagnostics"`
+	Diff           *domain.RulesDiff    `json:"diff,omitempty"`
+	Deploy         *domain.DeployResult `json:"deploy,omitempty"`
+	Error          string               `json:"error,omitempty"`
+	ExitCode       int                  `json:"exit_code"`
+	Duration       string               `json:"duration"`
+}
+
+func main() {
+	config, code := parseFlags(os.Args[1:])
+	if config == nil {
+		os.Exit(code)
+	}
+
+	app, err := NewApp(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing application: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	os.Exit(app.Run(context.Background()))
+}
+
+// parseFlags parsea argumentos de línea de comandos; sin configuración devuelve el
+// código de salida
+func parseFlags(args []string) (*Config, int) {
+	config := &Config{}
+	flags := flag.NewFlagSet(appName, flag.ContinueOnError)
+
+	flags.StringVar(&config.RulesFile, "rules", "", "Path to firestore.rules file (required)")
+	flags.StringVar(&config.ProjectID, "project", "default-project", "Project ID")
+	flags.StringVar(&config.DatabaseID, "database", "default", "Database ID")
+	flags.BoolVar(&config.DryRun, "dry-run", false, "Translate rules and diff them against the deployed rules without deploying")
+	flags.BoolVar(&config.Verbose, "verbose", false, "Enable verbose output on stderr")
+	flags.BoolVar(&config.NoCache, "no-cache", false, "Disable caching")
+	flags.BoolVar(&config.Optimize, "optimize", true, "Enable rule optimization")
+	flags.BoolVar(&config.ValidateOnly, "validate-only", false, "Only validate syntax without translation")
+	flags.BoolVar(&config.UseMock, "mock", false, "Use an in-memory security engine instead of MongoDB")
+	flags.BoolVar(&config.AllowInsecure, "allow-insecure", false, "Deploy the rules even with security lint errors, reported as warnings")
+	flags.StringVar(&config.User, "user", os.Getenv("USER"), "User the deployment is attributed to")
+	flags.StringVar(&config.OutputFormat, "output", "text", "Output format: text, json")
+
+	var showVersion bool
+	flags.BoolVar(&showVersion, "version", false, "Show version information")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", appName)
+		fmt.Fprintf(os.Stderr, "%s v%s - Import Firestore security rules\n\n", appName, version)
+		fmt.Fprintf(os.Stderr, "This tool translates Firestore security rules (.rules files) into\n")
+		fmt.Fprintf(os.Stderr, "the internal format used by your Firestore clone system.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExit codes:\n")
+		fmt.Fprintf(os.Stderr, "  %d  rules are valid (and deployed unless -dry-run or -validate-only)\n", exitOK)
+		fmt.Fprintf(os.Stderr, "  %d  rules have errors\n", exitInvalidRules)
+		fmt.Fprintf(os.Stderr, "  %d  invalid arguments or configuration\n", exitUsage)
+		fmt.Fprintf(os.Stderr, "  %d  security engine unavailable or deployment failed\n", exitDeployFailed)
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  %s -rules=firestore.rules -project=myapp\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -rules=firestore.rules -dry-run -verbose\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -rules=firestore.rules -validate-only\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -rules=firestore.rules -output=json\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -rules=firestore.rules -allow-insecure\n", appName)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, exitOK
+		}
+		return nil, exitUsage
+	}
+
+	if showVersion {
+		fmt.Printf("%s v%s\n", appName, version)
+		return nil, exitOK
+	}
+
+	if config.RulesFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -rules flag is required\n\n")
+		flags.Usage()
+		return nil, exitUsage
+	}
+
+	if config.OutputFormat != "text" && config.OutputFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s'\n", config.OutputFormat)
+		return nil, exitUsage
+	}
+
+	if config.DryRun && config.ValidateOnly {
+		fmt.Fprintf(os.Stderr, "Error: -dry-run and -validate-only are mutually exclusive\n")
+		return nil, exitUsage
+	}
+
+	return config, exitOK
+}
+
+// NewApp crea una nueva instancia de la aplicación
+func NewApp(config *Config) (*App, error) {
+	// Cargar variables de entorno desde .env si existe
+	_ = godotenv.Load()
+
+	// Configurar componentes optimizados
+	translatorConfig := usecase.DefaultTranslatorConfig()
+	translatorConfig.EnableCache = !config.NoCache
+	translatorConfig.EnableOptimization = config.Optimize
+	translatorConfig.EnableMetrics = config.Verbose
+
+	cacheConfig := adapter.DefaultCacheConfig()
+	if config.NoCache {
+		cacheConfig.MaxSize = 0
+	}
+
+	optimizerConfig := adapter.DefaultOptimizerConfig()
+	optimizerConfig.EnableAggressiveOptim = config.Optimize
+
+	// Inicializar componentes
+	translator := usecase.NewFastTranslator(
+		adapter.NewMemoryCache(cacheConfig), adapter.NewRulesOptimizer(optimizerConfig), translatorConfig)
+
+	// Entorno CEL del motor, para comprobar que las condiciones traducidas compilan
+	celEnv, err := rules_cel.NewEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("error creating rules CEL environment: %w", err)
+	}
+
+	app := &App{
+		config:     config,
+		parser:     parser.NewModernParserInstance(),
+		translator: translator,
+		linter:     adapter.NewRulesLinter(),
+		celEnv:     celEnv,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		history:    adapter.NewMemoryHistoryStore(),
+	}
+	app.newEngine = app.connectSecurityEngine
+	return app, nil
+}
+
+// connectSecurityEngine crea el motor de seguridad: en memoria con -mock, o sobre la
+// base de datos MongoDB del servidor
+func (a *App) connectSecurityEngine(ctx context.Context) (repository.SecurityRulesEngine, error) {
+	if a.config.UseMock {
+		return NewMockSecurityRulesEngine(), nil
+	}
+
+	// Leer configuración de MongoDB desde variables de entorno
+	mongoURI := os.Getenv("MONGODB_URI")
+	dbName := os.Getenv("DATABASE_NAME")
+	if mongoURI == "" || dbName == "" {
+		return nil, errMongoNotConfigured
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		return nil, fmt.Errorf("error conectando a MongoDB: %w", err)
+	}
+	if err := client.Ping(connectCtx, nil); err != nil {
+		return nil, fmt.Errorf("error conectando a MongoDB: %w", err)
+	}
+
+	db := client.Database(dbName)
+	a.history = mongodb.NewRulesHistoryStore(db)
+	return mongodb.NewSecurityRulesEngine(db, logger.NewLogger()), nil
+}
+
+// Run ejecuta la aplicación y devuelve el código de salida
+func (a *App) Run(ctx context.Context) int {
+	startTime := time.Now()
+	report := &Report{
+		RulesFile:   a.config.RulesFile,
+		ProjectID:   a.config.ProjectID,
+		DatabaseID:  a.config.DatabaseID,
+		Mode:        a.mode(),
+		Diagnostics: make([]Diagnostic, 0),
+	}
+
+	report.ExitCode = a.process(ctx, report)
+	report.Duration = time.Since(startTime).String()
+
+	if err := a.output(report); err != nil {
+		fmt.Fprintf(a.stderr, "Error writing output: %v\n", err)
+		if report.ExitCode == exitOK {
+			return exitUsage
+		}
+	}
+	return report.ExitCode
+}
+
+func (a *App) mode() string {
+	switch {
+	case a.config.ValidateOnly:
+		return "validate"
+	case a.config.DryRun:
+		return "dry-run"
+	default:
+		return "deploy"
+	}
+}
+
+// process valida, traduce y despliega las reglas, completando el reporte
+func (a *App) process(ctx context.Context, report *Report) int {
+	// 1. Leer archivo de reglas
+	content, err := os.ReadFile(a.config.RulesFile)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to read rules file: %v", err)
+		return exitUsage
+	}
+	a.logf("Read %d bytes from %s", len(content), a.config.RulesFile)
+
+	// 2. Parsear reglas
+	parseResult, err := a.parser.ParseString(ctx, string(content))
+	if err != nil {
+		report.Diagnostics = append(report.Diagnostics, parseFailure(err))
+		return exitInvalidRules
+	}
+	report.RuleCount = parseResult.RuleCount
+	for _, parseErr := range parseResult.Errors {
+		report.Diagnostics = append(report.Diagnostics, Diagnostic{
+			Line: parseErr.Line, Column: parseErr.Column, Severity: "error", Type: parseErr.Type, Message: parseErr.Message,
+		})
+	}
+	for _, warning := range parseResult.Warnings {
+		report.Diagnostics = append(report.Diagnostics, Diagnostic{
+			Line: warning.Line, Column: warning.Column, Severity: "warning", Type: warning.Type, Message: warning.Message,
+		})
+	}
+	a.logf("Parsed %d rules", parseResult.RuleCount)
+	if a.hasErrors(report) {
+		return exitInvalidRules
+	}
+
+	// Si solo validación, terminar aquí
+	if a.config.ValidateOnly {
+		report.Valid = true
+		return exitOK
+	}
+
+	// 3. Traducir reglas y comprobar que compilan en el motor
+	translation, err := a.translateRules(ctx, parseResult.Ruleset, content)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to translate rules: %v", err)
+		return exitInvalidRules
+	}
+	rules, _ := translation.Rules.([]*repository.SecurityRule)
+	report.RulesGenerated = len(rules)
+	for _, message := range translation.Errors {
+		report.Diagnostics = append(report.Diagnostics, Diagnostic{Severity: "error", Type: "translation", Message: message})
+	}
+	report.Diagnostics = append(report.Diagnostics, a.compileRules(rules)...)
+	a.logf("Translated to %d security rules", len(rules))
+	if a.hasErrors(report) {
+		return exitInvalidRules
+	}
+
+	// 4. Revisar la seguridad de las reglas; los hallazgos de severidad error impiden desplegarlas
+	findings, err := a.linter.Lint(ctx, rules)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to lint rules: %v", err)
+		return exitInvalidRules
+	}
+	for _, finding := range findings {
+		diagnostic := Diagnostic{
+			Line: finding.Line, Column: finding.Column, Severity: finding.Severity, Type: "security",
+			Message: fmt.Sprintf("%s [%s]", finding.Message, finding.Check), Fix: finding.Fix,
+		}
+		// Con -allow-insecure los errores de seguridad se aceptan y se muestran como advertencias
+		if a.config.AllowInsecure && finding.Severity == domain.LintSeverityError {
+			diagnostic.Severity = domain.LintSeverityWarning
+			diagnostic.Message += " (allowed by -allow-insecure)"
+		}
+		report.Diagnostics = append(report.Diagnostics, diagnostic)
+	}
+	a.logf("Security lint found %d issues", len(findings))
+	if a.hasErrors(report) {
+		return exitInvalidRules
+	}
+	report.Valid = true
+
+	// 5. Comparar con las reglas desplegadas
+	engine, err := a.newEngine(ctx)
+	if err != nil {
+		report.Error = err.Error()
+		if errors.Is(err, errMongoNotConfigured) {
+			return exitUsage
+		}
+		return exitDeployFailed
+	}
+	deployer := adapter.NewRulesDeployer(engine, adapter.NewSimpleValidator(), a.history, adapter.DefaultDeployerConfig())
+
+	current, err := deployer.GetCurrentRules(ctx, a.config.ProjectID, a.config.DatabaseID)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to load deployed rules: %v", err)
+		return exitDeployFailed
+	}
+	report.Diff = usecase.DiffRules(current, rules)
+	a.logf("Diff against %d deployed rules computed", len(current))
+
+	// Si dry run, mostrar resultado sin desplegar
+	if a.config.DryRun {
+		return exitOK
+	}
+
+	// 6. Desplegar reglas como un ruleset nuevo, con el texto original
+	if a.config.AllowInsecure {
+		ctx = domain.WithInsecureRulesAllowed(ctx)
+	}
+	deployResult, err := deployer.DeployRuleset(ctx, a.config.ProjectID, a.config.DatabaseID, string(content), rules, a.config.User)
+	if err != nil {
+		report.Error = fmt.Sprintf("failed to deploy rules: %v", err)
+		return exitDeployFailed
+	}
+	report.Deploy = deployResult
+	if !deployResult.Success {
+		return exitDeployFailed
+	}
+	a.logf("Deployed %d rules as version %s", deployResult.RulesDeployed, deployResult.Version)
+	return exitOK
+}
+
+// parseFailure convierte un error del parser en un diagnóstico con su posición
+func parseFailure(err error) Diagnostic {
+	var parseErr domain.ParseError
+	if errors.As(err, &parseErr) {
+		return Diagnostic{
+			Line: parseErr.Line, Column: parseErr.Column, Severity: "error", Type: parseErr.Type, Message: parseErr.Message,
+		}
+	}
+	return Diagnostic{Severity: "error", Type: "syntax", Message: err.Error()}
+}
+
+// translateRules traduce las reglas parseadas
+func (a *App) translateRules(ctx context.Context, ruleset *domain.FirestoreRuleset, content []byte) (*domain.TranslationResult, error) {
+	if a.config.NoCache {
+		return a.translator.Translate(ctx, ruleset)
+	}
+
+	// Usar caché si está habilitado
+	cacheKey := &domain.CacheKey{
+		ProjectID:  a.config.ProjectID,
+		DatabaseID: a.config.DatabaseID,
+		Version:    ruleset.Version,
+		Hash:       fmt.Sprintf("%x", sha256.Sum256(content)),
+	}
+
+	return a.translator.TranslateWithCache(ctx, ruleset, cacheKey)
+}
+
+// compileRules compila cada condición traducida con el entorno CEL del motor, que es
+// el que las evaluará tras el despliegue
+func (a *App) compileRules(rules []*repository.SecurityRule) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, rule := range rules {
+		var wildcards []string
+		for _, match := range wildcardPattern.FindAllStringSubmatch(rule.Match, -1) {
+			wildcards = append(wildcards, match[1])
+		}
+
+		check := func(effect string, conditions map[repository.OperationType]string) {
+			operations := make([]string, 0, len(conditions))
+			for op := range conditions {
+				operations = append(operations, string(op))
+			}
+			sort.Strings(operations)
+
+			for _, op := range operations {
+				condition := conditions[repository.OperationType(op)]
+				if _, err := rules_cel.Compile(a.celEnv, condition, wildcards); err != nil {
+					diagnostics = append(diagnostics, Diagnostic{
+						Severity: "error",
+						Type:     "compile",
+						Message:  fmt.Sprintf("match %s: %s %s: %v", rule.Match, effect, op, err),
+					})
+				}
+			}
+		}
+		check("allow", rule.Allow)
+		check("deny", rule.Deny)
+	}
+	return diagnostics
+}
+
+func (a *App) hasErrors(report *Report) bool {
+	for _, diagnostic := range report.Diagnostics {
+		if diagnostic.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+// logf escribe el progreso en stderr en modo verbose, sin mezclarlo con la salida
+func (a *App) logf(format string, args ...interface{}) {
+	if a.config.Verbose {
+		fmt.Fprintf(a.stderr, format+"\n", args...)
+	}
+}
+
+// Output methods
+
+func (a *App) output(report *Report) error {
+	if a.config.OutputFormat == "json" {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	// Los diagnósticos siguen el formato archivo:línea:columna que entienden los editores y CI
+	for _, diagnostic := range report.Diagnostics {
+		location := report.RulesFile
+		if diagnostic.Line > 0 {
+			location += fmt.Sprintf(":%d", diagnostic.Line)
+			if diagnostic.Column > 0 {
+				location += fmt.Sprintf(":%d", diagnostic.Column)
+			}
+		}
+		fmt.Fprintf(a.stderr, "%s: %s: %s\n", location, diagnostic.Severity, diagnostic.Message)
+		if diagnostic.Fix != "" {
+			fmt.Fprintf(a.stderr, "  fix: %s\n", diagnostic.Fix)
+		}
+	}
+	if report.Error != "" {
+		fmt.Fprintf(a.stderr, "Error: %s\n", report.Error)
+	}
+
+	switch {
+	case report.ExitCode == exitInvalidRules:
+		fmt.Fprintf(a.stdout, "❌ Rules file is invalid\n")
+		return nil
+	case report.ExitCode != exitOK && report.Deploy == nil:
+		return nil
+	}
+
+	if report.Diff != nil {
+		a.outputDiff(report.Diff)
+	}
+
+	switch report.Mode {
+	case "validate":
+		fmt.Fprintf(a.stdout, "✅ Rules file is valid\n")
+		fmt.Fprintf(a.stdout, "   Rules found: %d\n", report.RuleCount)
+	case "dry-run":
+		fmt.Fprintf(a.stdout, "🧪 Dry run completed successfully\n")
+		fmt.Fprintf(a.stdout, "   Rules found: %d\n", report.RuleCount)
+		fmt.Fprintf(a.stdout, "   Translated rules: %d\n", report.RulesGenerated)
+	default:
+		a.outputDeploy(report)
+	}
+	return nil
+}
+
+func (a *App) outputDiff(diff *domain.RulesDiff) {
+	if !diff.HasChanges() {
+		fmt.Fprintf(a.stdout, "No changes against the deployed rules (%d conditions unchanged)\n\n", diff.Unchanged)
+		return
+	}
+
+	fmt.Fprintf(a.stdout, "Changes against the deployed rules:\n")
+	for _, change := range diff.Added {
+		fmt.Fprintf(a.stdout, "  + %s %s %s: %s\n", change.Match, change.Effect, change.Operation, change.After)
+	}
+	for _, change := range diff.Removed {
+		fmt.Fprintf(a.stdout, "  - %s %s %s: %s\n", change.Match, change.Effect, change.Operation, change.Before)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(a.stdout, "  ~ %s %s %s:\n", change.Match, change.Effect, change.Operation)
+		fmt.Fprintf(a.stdout, "      - %s\n", change.Before)
+		fmt.Fprintf(a.stdout, "      + %s\n", change.After)
+	}
+	fmt.Fprintf(a.stdout, "  %d added, %d removed, %d changed, %d unchanged\n\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+}
+
+func (a *App) outputDeploy(report *Report) {
+	deployResult := report.Deploy
+	if deployResult.Success {
+		fmt.Fprintf(a.stdout, "🎉 Rules deployed successfully!\n\n")
+	} else {
+		fmt.Fprintf(a.stdout, "❌ Deployment failed!\n\n")
+	}
+
+	fmt.Fprintf(a.stdout, "📊 Final Summary:\n")
+	fmt.Fprintf(a.stdout, "   Version: %s\n", deployResult.Version)
+	fmt.Fprintf(a.stdout, "   Rules deployed: %d\n", deployResult.RulesDeployed)
+	fmt.Fprintf(a.stdout, "   Total time: %s\n", report.Duration)
+	fmt.Fprintf(a.stdout, "   Project: %s\n", report.ProjectID)
+	fmt.Fprintf(a.stdout, "   Database: %s\n", report.DatabaseID)
+
+	if len(deployResult.Errors) > 0 {
+		fmt.Fprintf(a.stdout, "\n❌ Errors:\n")
+		for _, err := range deployResult.Errors {
+			fmt.Fprintf(a.stdout, "   - %s\n", err)
+		}
+	}
+
+	if len(deployResult.Warnings) > 0 {
+		fmt.Fprintf(a.stdout, "\n⚠️  Warnings:\n")
+		for _, warning := range deployResult.Warnings {
+			fmt.Fprintf(a.stdout, "   - %s\n", warning)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /users/{userId} {
+      allow read: if request.auth != null;
+      allow write: if request.auth.uid == userId;
+    }
+  }
+}`
+
+// newTestApp crea la aplicación con un motor en memoria compartido entre ejecuciones
+func newTestApp(t *testing.T, engine repository.SecurityRulesEngine, rules string, configure func(*Config)) (*App, *bytes.Buffer, *bytes.Buffer) {
+	rulesFile := filepath.Join(t.TempDir(), "firestore.rules")
+	require.NoError(t, os.WriteFile(rulesFile, []byte(rules), 0o644))
+
+	config := &Config{RulesFile: rulesFile, ProjectID: "p1", DatabaseID: "d1", Optimize: true, OutputFormat: "json"}
+	if configure != nil {
+		configure(config)
+	}
+	app, err := NewApp(config)
+	require.NoError(t, err)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	app.stdout, app.stderr = stdout, stderr
+	app.newEngine = func(ctx context.Context) (repository.SecurityRulesEngine, error) { return engine, nil }
+	return app, stdout, stderr
+}
+
+func decodeReport(t *testing.T, stdout *bytes.Buffer) *Report {
+	var report Report
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report), stdout.String())
+	return &report
+}
+
+func TestImporterValidation(t *testing.T) {
+	t.Run("Syntax errors report their position", func(t *testing.T) {
+		rules := "rules_version = '2';\nservice cloud.firestore {\n  match /users/{userId} {\n    allow read if true;\n  }\n}"
+		app, stdout, _ := newTestApp(t, NewMockSecurityRulesEngine(), rules, func(c *Config) { c.ValidateOnly = true })
+
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		assert.False(t, report.Valid)
+		require.Len(t, report.Diagnostics, 1)
+		assert.Equal(t, 4, report.Diagnostics[0].Line)
+		assert.Equal(t, 16, report.Diagnostics[0].Column)
+	})
+
+	t.Run("Text diagnostics use file:line:column", func(t *testing.T) {
+		rules := "rules_version = '2';\nservice cloud.firestore {\n  match /users/{userId} {\n    allow read: if 'a;\n  }\n}"
+		app, _, stderr := newTestApp(t, NewMockSecurityRulesEngine(), rules, func(c *Config) { c.OutputFormat = "text" })
+
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+		assert.Contains(t, stderr.String(), app.config.RulesFile+":4:20: error: unterminated string")
+	})
+
+	t.Run("Conditions which do not compile are errors", func(t *testing.T) {
+		rules := "rules_version = '2';\nservice cloud.firestore {\n  match /users/{userId} {\n    allow read: if request.auth.uid ==;\n  }\n}"
+		app, stdout, _ := newTestApp(t, NewMockSecurityRulesEngine(), rules, func(c *Config) { c.DryRun = true })
+
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		require.NotEmpty(t, report.Diagnostics)
+		assert.Equal(t, "compile", report.Diagnostics[0].Type)
+	})
+
+	t.Run("Valid rules", func(t *testing.T) {
+		app, stdout, _ := newTestApp(t, NewMockSecurityRulesEngine(), validRules, func(c *Config) { c.ValidateOnly = true })
+
+		assert.Equal(t, exitOK, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		assert.True(t, report.Valid)
+		assert.Empty(t, report.Diagnostics)
+	})
+}
+
+func TestImporterSecurityLint(t *testing.T) {
+	rules := "rules_version = '2';\nservice cloud.firestore {\n  match /databases/{database}/documents {\n    match /posts/{postId} {\n      allow write: if true;\n    }\n  }\n}"
+
+	t.Run("Security errors fail the import", func(t *testing.T) {
+		engine := NewMockSecurityRulesEngine()
+		app, stdout, _ := newTestApp(t, engine, rules, nil)
+
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		assert.False(t, report.Valid)
+		require.NotEmpty(t, report.Diagnostics)
+		assert.Equal(t, "security", report.Diagnostics[0].Type)
+		assert.Equal(t, "error", report.Diagnostics[0].Severity)
+		assert.Equal(t, 5, report.Diagnostics[0].Line)
+		assert.Equal(t, 7, report.Diagnostics[0].Column)
+		assert.Contains(t, report.Diagnostics[0].Message, "[public-write]")
+		assert.NotEmpty(t, report.Diagnostics[0].Fix)
+
+		// Nada se despliega
+		deployed, _ := engine.LoadRules(context.Background(), "p1", "d1")
+		assert.Empty(t, deployed)
+	})
+
+	t.Run("Text diagnostics include the fix", func(t *testing.T) {
+		app, _, stderr := newTestApp(t, NewMockSecurityRulesEngine(), rules, func(c *Config) { c.DryRun = true; c.OutputFormat = "text" })
+
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+		assert.Contains(t, stderr.String(), app.config.RulesFile+":5:7: error:")
+		assert.Contains(t, stderr.String(), "  fix: ")
+	})
+
+	t.Run("Security errors can be allowed explicitly", func(t *testing.T) {
+		engine := NewMockSecurityRulesEngine()
+		app, stdout, _ := newTestApp(t, engine, rules, func(c *Config) { c.AllowInsecure = true })
+
+		assert.Equal(t, exitOK, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		assert.True(t, report.Valid)
+		require.NotEmpty(t, report.Diagnostics)
+		assert.Equal(t, "warning", report.Diagnostics[0].Severity)
+		assert.Contains(t, report.Diagnostics[0].Message, "(allowed by -allow-insecure)")
+		require.NotNil(t, report.Deploy)
+		assert.True(t, report.Deploy.Success)
+		deployed, _ := engine.LoadRules(context.Background(), "p1", "d1")
+		assert.NotEmpty(t, deployed)
+	})
+
+	t.Run("Warnings do not block the import", func(t *testing.T) {
+		app, stdout, _ := newTestApp(t, NewMockSecurityRulesEngine(), validRules, func(c *Config) { c.DryRun = true })
+
+		assert.Equal(t, exitOK, app.Run(context.Background()))
+		report := decodeReport(t, stdout)
+		assert.True(t, report.Valid)
+		require.NotEmpty(t, report.Diagnostics)
+		for _, diagnostic := range report.Diagnostics {
+			assert.Equal(t, "security", diagnostic.Type)
+			assert.NotEqual(t, "error", diagnostic.Severity)
+		}
+	})
+}
+
+func TestImporterDryRunAndDeploy(t *testing.T) {
+	ctx := context.Background()
+	engine := NewMockSecurityRulesEngine()
+
+	// Un dry run no despliega y muestra las condiciones nuevas
+	app, stdout, _ := newTestApp(t, engine, validRules, func(c *Config) { c.DryRun = true })
+	require.Equal(t, exitOK, app.Run(ctx))
+	report := decodeReport(t, stdout)
+	require.NotNil(t, report.Diff)
+	assert.NotEmpty(t, report.Diff.Added)
+	assert.Nil(t, report.Deploy)
+	deployed, _ := engine.LoadRules(ctx, "p1", "d1")
+	assert.Empty(t, deployed)
+
+	// El despliegue guarda las reglas en el motor
+	app, stdout, _ = newTestApp(t, engine, validRules, nil)
+	require.Equal(t, exitOK, app.Run(ctx))
+	report = decodeReport(t, stdout)
+	require.NotNil(t, report.Deploy)
+	assert.True(t, report.Deploy.Success)
+	deployed, _ = engine.LoadRules(ctx, "p1", "d1")
+	assert.NotEmpty(t, deployed)
+
+	// Tras desplegar, el mismo archivo no tiene cambios
+	app, stdout, _ = newTestApp(t, engine, validRules, func(c *Config) { c.DryRun = true })
+	require.Equal(t, exitOK, app.Run(ctx))
+	report = decodeReport(t, stdout)
+	assert.False(t, report.Diff.HasChanges())
+	assert.Positive(t, report.Diff.Unchanged)
+}
+
+func TestImporterFlags(t *testing.T) {
+	_, code := parseFlags([]string{"-dry-run"})
+	assert.Equal(t, exitUsage, code, "Missing -rules")
+
+	_, code = parseFlags([]string{"-rules=firestore.rules", "-output=yaml"})
+	assert.Equal(t, exitUsage, code)
+
+	config, code := parseFlags([]string{"-rules=firestore.rules", "-dry-run", "-output=json", "-mock"})
+	require.NotNil(t, config)
+	assert.Equal(t, exitOK, code)
+	assert.True(t, config.DryRun && config.UseMock)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"firestore-clone/internal/firestore/domain/repository"
+)
+
+// MockSecurityRulesEngine guarda las reglas en memoria, para probar el importador sin
+// MongoDB. Las reglas desplegadas se pierden al terminar el proceso.
+type MockSecurityRulesEngine struct {
+	rules map[string][]*repository.SecurityRule
+	mutex sync.RWMutex
+}
+
+// NewMockSecurityRulesEngine crea un motor en memoria sin reglas desplegadas
+func NewMockSecurityRulesEngine() *MockSecurityRulesEngine {
+	return &MockSecurityRulesEngine{rules: make(map[string][]*repository.SecurityRule)}
+}
+
+func (m *MockSecurityRulesEngine) EvaluateAccess(ctx context.Context, operation repository.OperationType, securityContext *repository.SecurityContext) (*repository.RuleEvaluationResult, error) {
+	return &repository.RuleEvaluationResult{Allowed: true}, nil
+}
+
+func (m *MockSecurityRulesEngine) LoadRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.rules[mockRulesKey(projectID, databaseID)], nil
+}
+
+func (m *MockSecurityRulesEngine) SaveRules(ctx context.Context, projectID, databaseID string, rules []*repository.SecurityRule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rules[mockRulesKey(projectID, databaseID)] = rules
+	return nil
+}
+
+func (m *MockSecurityRulesEngine) ValidateRules(rules []*repository.SecurityRule) error {
+	return nil
+}
+
+func (m *MockSecurityRulesEngine) ClearCache(projectID, databaseID string) {
+	// No-op for mock
+}
+
+func (m *MockSecurityRulesEngine) SetResourceAccessor(accessor repository.ResourceAccessor) {
+	// No-op for mock
+}
+
+func (m *MockSecurityRulesEngine) DeleteRules(ctx context.Context, projectID, databaseID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.rules, mockRulesKey(projectID, databaseID))
+	return nil
+}
+
+func (m *MockSecurityRulesEngine) GetRawRules(ctx context.Context, projectID, databaseID string) (string, error) {
+	return "{}", nil
+}
+
+func mockRulesKey(projectID, databaseID string) string {
+	return fmt.Sprintf("%s:%s", projectID, databaseID)
+}
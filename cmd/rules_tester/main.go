@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/rules_translator/adapter"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+	"firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/rules_translator/usecase"
+)
+
+const (
+	version = "1.0.0"
+	appName = "firestore-rules-tester"
+)
+
+// Códigos de salida para pipelines de CI
+const (
+	exitOK           = 0 // Todos los casos pasaron
+	exitTestsFailed  = 1 // Algún caso falló o no se pudo evaluar
+	exitUsage        = 2 // Argumentos, archivo de pruebas o archivo de reglas inválidos
+	exitInvalidRules = 3 // Las reglas no se pudieron parsear o traducir
+)
+
+// Config estructura de configuración de la aplicación
+type Config struct {
+	TestsFile    string
+	RulesFile    string
+	JUnitFile    string
+	OutputFormat string
+	Coverage     bool
+}
+
+// App estructura principal de la aplicación
+type App struct {
+	config *Config
+	runner domain.RulesTestRunner
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	config, code := parseFlags(os.Args[1:])
+	if config == nil {
+		os.Exit(code)
+	}
+
+	app, err := NewApp(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing application: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	os.Exit(app.Run(context.Background()))
+}
+
+// parseFlags parsea argumentos de línea de comandos; sin configuración devuelve el
+// código de salida
+func parseFlags(args []string) (*Config, int) {
+	config := &Config{}
+	flags := flag.NewFlagSet(appName, flag.ContinueOnError)
+
+	flags.StringVar(&config.TestsFile, "tests", "", "Path to the rules test file, YAML or JSON (required)")
+	flags.StringVar(&config.RulesFile, "rules", "", "Path to the firestore.rules file (default: the rules of the test file)")
+	flags.StringVar(&config.JUnitFile, "junit", "", "Write a JUnit XML report to this file")
+	flags.StringVar(&config.OutputFormat, "output", "text", "Output format: text, json")
+	flags.BoolVar(&config.Coverage, "coverage", false, "Report which lines of the rules file the tests cover")
+
+	var showVersion bool
+	flags.BoolVar(&showVersion, "version", false, "Show version information")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", appName)
+		fmt.Fprintf(os.Stderr, "%s v%s - Unit test Firestore security rules\n\n", appName, version)
+		fmt.Fprintf(os.Stderr, "This tool runs the cases of a test file against a .rules file in memory,\n")
+		fmt.Fprintf(os.Stderr, "without a database or the Firebase emulator.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExit codes:\n")
+		fmt.Fprintf(os.Stderr, "  %d  all tests passed\n", exitOK)
+		fmt.Fprintf(os.Stderr, "  %d  some tests failed\n", exitTestsFailed)
+		fmt.Fprintf(os.Stderr, "  %d  invalid arguments, test file or rules file\n", exitUsage)
+		fmt.Fprintf(os.Stderr, "  %d  rules have errors\n", exitInvalidRules)
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  %s -tests=rules_test.yaml\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -tests=rules_test.yaml -rules=firestore.rules -junit=report.xml\n", appName)
+		fmt.Fprintf(os.Stderr, "  %s -tests=rules_test.yaml -coverage\n", appName)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, exitOK
+		}
+		return nil, exitUsage
+	}
+
+	if showVersion {
+		fmt.Printf("%s v%s\n", appName, version)
+		return nil, exitOK
+	}
+
+	if config.TestsFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: -tests flag is required\n\n")
+		flags.Usage()
+		return nil, exitUsage
+	}
+
+	if config.OutputFormat != "text" && config.OutputFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format '%s'\n", config.OutputFormat)
+		return nil, exitUsage
+	}
+
+	return config, exitOK
+}
+
+// NewApp crea una nueva instancia de la aplicación. Las reglas se evalúan con el
+// simulador del motor sobre un accessor en memoria: solo existen los documentos
+// sembrados por las pruebas.
+func NewApp(config *Config) (*App, error) {
+	celEnv, err := rules_cel.NewEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("error creating rules CEL environment: %w", err)
+	}
+
+	translator := usecase.NewFastTranslator(
+		adapter.NewMemoryCache(adapter.DefaultCacheConfig()), adapter.NewRulesOptimizer(adapter.DefaultOptimizerConfig()),
+		usecase.DefaultTranslatorConfig())
+	simulator := rules_cel.NewSimulator(celEnv, adapter.NewMemoryResourceAccessor(nil))
+
+	return &App{
+		config: config,
+		runner: usecase.NewRulesTestRunner(parser.NewModernParserInstance(), translator, simulator),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// Run ejecuta las pruebas y devuelve el código de salida
+func (a *App) Run(ctx context.Context) int {
+	report, code := a.process(ctx)
+	if report == nil {
+		return code
+	}
+
+	if a.config.JUnitFile != "" {
+		if err := a.writeJUnit(report); err != nil {
+			fmt.Fprintf(a.stderr, "Error writing JUnit report: %v\n", err)
+			return exitUsage
+		}
+	}
+	if err := a.output(report); err != nil {
+		fmt.Fprintf(a.stderr, "Error writing output: %v\n", err)
+		return exitUsage
+	}
+
+	if !report.Success() {
+		return exitTestsFailed
+	}
+	return exitOK
+}
+
+// process lee la suite y las reglas y ejecuta los casos
+func (a *App) process(ctx context.Context) (*domain.RulesTestReport, int) {
+	content, err := os.ReadFile(a.config.TestsFile)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "Error: failed to read test file: %v\n", err)
+		return nil, exitUsage
+	}
+	suite, err := adapter.ParseRulesTestSuite(content)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "%s: error: %v\n", a.config.TestsFile, err)
+		return nil, exitUsage
+	}
+	if suite.Name == "" {
+		suite.Name = filepath.Base(a.config.TestsFile)
+	}
+	if a.config.Coverage {
+		suite.Coverage = true
+	}
+
+	// -rules tiene prioridad; la ruta de la suite es relativa al archivo de pruebas
+	rulesFile := a.config.RulesFile
+	if rulesFile == "" && suite.Rules != "" {
+		rulesFile = suite.Rules
+		if !filepath.IsAbs(rulesFile) {
+			rulesFile = filepath.Join(filepath.Dir(a.config.TestsFile), rulesFile)
+		}
+	}
+	if rulesFile == "" {
+		fmt.Fprintf(a.stderr, "Error: no rules file: use -rules or set rules in the test file\n")
+		return nil, exitUsage
+	}
+	source, err := os.ReadFile(rulesFile)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "Error: failed to read rules file: %v\n", err)
+		return nil, exitUsage
+	}
+
+	report, err := a.runner.Run(ctx, string(source), suite)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "%s: error: %v\n", rulesFile, err)
+		return nil, exitInvalidRules
+	}
+	return report, exitOK
+}
+
+func (a *App) writeJUnit(report *domain.RulesTestReport) error {
+	output, err := adapter.FormatJUnitReport(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.config.JUnitFile, output, 0o644)
+}
+
+// Output methods
+
+func (a *App) output(report *domain.RulesTestReport) error {
+	if a.config.OutputFormat == "json" {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	for _, result := range report.Results {
+		switch {
+		case result.Error != "":
+			fmt.Fprintf(a.stdout, "💥 ERROR %s: %s\n", result.Name, result.Error)
+		case result.Passed:
+			fmt.Fprintf(a.stdout, "✅ PASS  %s\n", result.Name)
+		default:
+			fmt.Fprintf(a.stdout, "❌ FAIL  %s: expected %s, got %s (%s)\n", result.Name, result.Expected, result.Actual, result.Reason)
+		}
+	}
+	fmt.Fprintf(a.stdout, "\n%d tests: %d passed, %d failed, %d errors (%s)\n",
+		report.Total, report.Passed, report.Failed, report.Errors, report.Duration.Round(time.Microsecond))
+
+	if report.Coverage != nil {
+		a.outputCoverage(report.Coverage)
+	}
+	return nil
+}
+
+// outputCoverage imprime el porcentaje de condiciones cubiertas y las líneas con
+// condiciones que ninguna prueba evaluó
+func (a *App) outputCoverage(coverage *domain.RulesCoverageReport) {
+	fmt.Fprintf(a.stdout, "\nCoverage: %.1f%% (%d/%d conditions)\n", coverage.Percent, coverage.Covered, coverage.Conditions)
+	for _, line := range coverage.Lines {
+		if len(line.Uncovered) == 0 {
+			continue
+		}
+		fmt.Fprintf(a.stdout, "  %4d  %s  (not covered: %s)\n", line.Number, strings.TrimSpace(line.Text), strings.Join(line.Uncovered, ", "))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"firestore-clone/internal/rules_translator/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /posts/{postId} {
+      allow read: if resource.data.published == true || request.auth.uid == resource.data.author;
+      allow create: if request.auth != null && request.resource.data.author == request.auth.uid;
+      allow update: if get(/databases/$(database)/documents/users/$(request.auth.uid)).data.role == 'editor';
+    }
+  }
+}`
+
+const testSuite = `name: posts
+rules: firestore.rules
+documents:
+  posts/draft: {published: false, author: alice}
+  users/bob: {role: editor}
+tests:
+  - name: author reads a draft
+    auth: {uid: alice}
+    operation: read
+    path: posts/draft
+    expect: allow
+  - name: others cannot read a draft
+    auth: {uid: carol}
+    operation: read
+    path: posts/draft
+    expect: deny
+  - name: create as another author
+    auth: {uid: alice}
+    operation: create
+    path: posts/new
+    data: {author: bob}
+    expect: deny
+  - name: editors update
+    auth: {uid: bob}
+    operation: update
+    path: posts/draft
+    expect: allow
+`
+
+// newTestApp escribe las reglas y la suite en un directorio temporal
+func newTestApp(t *testing.T, suite string, configure func(*Config)) (*App, *bytes.Buffer, *bytes.Buffer) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "firestore.rules"), []byte(testRules), 0o644))
+	testsFile := filepath.Join(dir, "rules_test.yaml")
+	require.NoError(t, os.WriteFile(testsFile, []byte(suite), 0o644))
+
+	config := &Config{TestsFile: testsFile, OutputFormat: "json"}
+	if configure != nil {
+		configure(config)
+	}
+	app, err := NewApp(config)
+	require.NoError(t, err)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	app.stdout, app.stderr = stdout, stderr
+	return app, stdout, stderr
+}
+
+func TestRulesTester(t *testing.T) {
+	t.Run("Passing suite", func(t *testing.T) {
+		app, stdout, _ := newTestApp(t, testSuite, nil)
+		require.Equal(t, exitOK, app.Run(context.Background()))
+
+		var report domain.RulesTestReport
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		assert.Equal(t, "posts", report.Name)
+		assert.Equal(t, 4, report.Total)
+		assert.Equal(t, 4, report.Passed)
+	})
+
+	t.Run("Failing case writes a JUnit failure", func(t *testing.T) {
+		suite := strings.Replace(testSuite, "path: posts/new\n    data: {author: bob}\n    expect: deny", "path: posts/new\n    data: {author: bob}\n    expect: allow", 1)
+		var junitFile string
+		app, stdout, _ := newTestApp(t, suite, func(c *Config) {
+			c.OutputFormat = "text"
+			junitFile = filepath.Join(filepath.Dir(c.TestsFile), "report.xml")
+			c.JUnitFile = junitFile
+		})
+		require.Equal(t, exitTestsFailed, app.Run(context.Background()))
+		assert.Contains(t, stdout.String(), "❌ FAIL  create as another author: expected allow, got deny")
+		assert.Contains(t, stdout.String(), "4 tests: 3 passed, 1 failed, 0 errors")
+
+		junit, err := os.ReadFile(junitFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(junit), `<testsuite name="posts" tests="4" failures="1" errors="0"`)
+		assert.Contains(t, string(junit), `<failure message="expected allow, got deny">`)
+	})
+
+	t.Run("Invalid cases are errors", func(t *testing.T) {
+		suite := testSuite + "  - operation: fly\n    path: posts/draft\n    expect: allow\n"
+		app, stdout, _ := newTestApp(t, suite, nil)
+		require.Equal(t, exitTestsFailed, app.Run(context.Background()))
+
+		var report domain.RulesTestReport
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		assert.Equal(t, 1, report.Errors)
+		assert.Contains(t, report.Results[4].Error, "invalid operation")
+	})
+
+	t.Run("Coverage lists the uncovered conditions", func(t *testing.T) {
+		app, stdout, _ := newTestApp(t, testSuite, func(c *Config) {
+			c.OutputFormat = "text"
+			c.Coverage = true
+		})
+		require.Equal(t, exitOK, app.Run(context.Background()))
+		assert.Contains(t, stdout.String(), "Coverage: 75.0% (3/4 conditions)")
+		assert.Contains(t, stdout.String(), "     5  allow read: if resource.data.published == true || request.auth.uid == resource.data.author;  (not covered: list)")
+	})
+
+	t.Run("Unknown fields in the test file", func(t *testing.T) {
+		app, _, stderr := newTestApp(t, strings.Replace(testSuite, "expect: deny", "expected: deny", 1), nil)
+		assert.Equal(t, exitUsage, app.Run(context.Background()))
+		assert.Contains(t, stderr.String(), "expected")
+	})
+
+	t.Run("Invalid rules", func(t *testing.T) {
+		app, _, _ := newTestApp(t, testSuite, func(c *Config) {
+			c.RulesFile = filepath.Join(filepath.Dir(c.TestsFile), "broken.rules")
+			require.NoError(t, os.WriteFile(c.RulesFile, []byte("service cloud.firestore {"), 0o644))
+		})
+		assert.Equal(t, exitInvalidRules, app.Run(context.Background()))
+	})
+}
+
+func TestParseFlagsRequiresTests(t *testing.T) {
+	config, code := parseFlags([]string{"-rules=firestore.rules"})
+	assert.Nil(t, config)
+	assert.Equal(t, exitUsage, code)
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 
 	authhttp "firestore-clone/internal/auth/adapter/http"
@@ -72,6 +73,14 @@ func (am *AuthModule) GetUsecase() usecase.AuthUsecaseInterface {
 	return am.usecase
 }
 
+// OnUserAccessChanged registers a function called after the roles, tenants or active
+// state of a user change
+func (am *AuthModule) OnUserAccessChanged(notify func(ctx context.Context, userID string)) {
+	if uc, ok := am.usecase.(*usecase.AuthUsecase); ok {
+		uc.OnAccessChanged(notify)
+	}
+}
+
 // GetTokenService returns the token service for external access
 func (am *AuthModule) GetTokenService() repository.TokenService {
 	return am.tokenSvc
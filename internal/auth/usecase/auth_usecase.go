@@ -64,6 +64,9 @@ type AuthUsecase struct {
 	authRepo repository.AuthRepository
 	tokenSvc repository.TokenService
 	config   *config.Config
+
+	// accessChanged is called after the roles, tenants or active state of a user change
+	accessChanged func(ctx context.Context, userID string)
 }
 
 // NewAuthUsecase creates a new instance of AuthUsecase.
@@ -422,7 +425,43 @@ func (uc *AuthUsecase) UpdateUser(ctx context.Context, user *model.User) error {
 		return fmt.Errorf("validation failed: %v", errs)
 	}
 
-	return uc.authRepo.UpdateUser(ctx, user)
+	var previous *model.User
+	if uc.accessChanged != nil {
+		previous, _ = uc.authRepo.GetUserByID(ctx, user.UserID)
+	}
+	if err := uc.authRepo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	if uc.accessChanged != nil && (previous == nil || !sameAccess(previous, user)) {
+		uc.accessChanged(ctx, user.UserID)
+	}
+	return nil
+}
+
+// OnAccessChanged registers a function called after the roles, tenants or active state of
+// a user change, so that the access already granted to the user can be checked again
+func (uc *AuthUsecase) OnAccessChanged(notify func(ctx context.Context, userID string)) {
+	uc.accessChanged = notify
+}
+
+// notifyAccessChanged calls the access change function, when there is one
+func (uc *AuthUsecase) notifyAccessChanged(ctx context.Context, userID string) {
+	if uc.accessChanged != nil {
+		uc.accessChanged(ctx, userID)
+	}
+}
+
+// sameAccess reports whether two versions of a user grant the same access
+func sameAccess(previous, current *model.User) bool {
+	if previous.TenantID != current.TenantID || previous.IsActive != current.IsActive || len(previous.Roles) != len(current.Roles) {
+		return false
+	}
+	for _, role := range previous.Roles {
+		if !current.HasRole(role) {
+			return false
+		}
+	}
+	return true
 }
 
 func (uc *AuthUsecase) DeleteUser(ctx context.Context, userID string) error {
@@ -431,7 +470,11 @@ func (uc *AuthUsecase) DeleteUser(ctx context.Context, userID string) error {
 		return fmt.Errorf("failed to delete user sessions: %w", err)
 	}
 
-	return uc.authRepo.DeleteUser(ctx, userID)
+	if err := uc.authRepo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	uc.notifyAccessChanged(ctx, userID)
+	return nil
 }
 
 func (uc *AuthUsecase) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
@@ -476,11 +519,19 @@ func (uc *AuthUsecase) GetUsersByTenant(ctx context.Context, tenantID string) ([
 }
 
 func (uc *AuthUsecase) AddUserToTenant(ctx context.Context, userID, tenantID string) error {
-	return uc.authRepo.AddUserToTenant(ctx, userID, tenantID)
+	if err := uc.authRepo.AddUserToTenant(ctx, userID, tenantID); err != nil {
+		return err
+	}
+	uc.notifyAccessChanged(ctx, userID)
+	return nil
 }
 
 func (uc *AuthUsecase) RemoveUserFromTenant(ctx context.Context, userID, tenantID string) error {
-	return uc.authRepo.RemoveUserFromTenant(ctx, userID, tenantID)
+	if err := uc.authRepo.RemoveUserFromTenant(ctx, userID, tenantID); err != nil {
+		return err
+	}
+	uc.notifyAccessChanged(ctx, userID)
+	return nil
 }
 
 // Token validation
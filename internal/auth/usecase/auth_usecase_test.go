@@ -2,6 +2,7 @@ package usecase_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -442,6 +443,50 @@ func (suite *AuthUsecaseTestSuite) TestLogout_InvalidToken() {
 	}
 }
 
+func (suite *AuthUsecaseTestSuite) TestUpdateUser_NotifiesAccessChanges() {
+	ctx := context.Background()
+	var changed []string
+	suite.usecase.OnAccessChanged(func(ctx context.Context, userID string) {
+		changed = append(changed, userID)
+	})
+	stored := &model.User{UserID: "user-123", Email: "test@example.com", FirstName: "Test", LastName: "User", TenantID: "t1", Roles: []string{"user"}, IsActive: true}
+	suite.mockRepo.On("GetUserByID", ctx, "user-123").Return(stored, nil)
+	suite.mockRepo.On("UpdateUser", ctx, mock.AnythingOfType("*model.User")).Return(nil)
+	suite.mockRepo.On("AddUserToTenant", ctx, "user-123", "t2").Return(nil)
+
+	// Profile changes keep the access of the user
+	profile := *stored
+	profile.Phone = "+34600000000"
+	assert.NoError(suite.T(), suite.usecase.UpdateUser(ctx, &profile))
+	assert.Empty(suite.T(), changed)
+
+	promoted := *stored
+	promoted.Roles = []string{"user", "admin"}
+	assert.NoError(suite.T(), suite.usecase.UpdateUser(ctx, &promoted))
+	assert.Equal(suite.T(), []string{"user-123"}, changed)
+
+	assert.NoError(suite.T(), suite.usecase.AddUserToTenant(ctx, "user-123", "t2"))
+	assert.Equal(suite.T(), []string{"user-123", "user-123"}, changed)
+}
+
+func (suite *AuthUsecaseTestSuite) TestDeleteUser_NotifiesAccessChanges() {
+	ctx := context.Background()
+	var changed []string
+	suite.usecase.OnAccessChanged(func(ctx context.Context, userID string) {
+		changed = append(changed, userID)
+	})
+	suite.mockRepo.On("DeleteSessionsByUserID", ctx, "user-123").Return(nil)
+	suite.mockRepo.On("DeleteUser", ctx, "user-123").Return(nil).Once()
+	suite.mockRepo.On("DeleteUser", ctx, "user-123").Return(errors.New("storage unavailable")).Once()
+
+	assert.NoError(suite.T(), suite.usecase.DeleteUser(ctx, "user-123"))
+	assert.Equal(suite.T(), []string{"user-123"}, changed)
+
+	// A failed delete keeps the access of the user
+	assert.Error(suite.T(), suite.usecase.DeleteUser(ctx, "user-123"))
+	assert.Equal(suite.T(), []string{"user-123"}, changed)
+}
+
 func TestAuthUsecaseTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthUsecaseTestSuite))
 }
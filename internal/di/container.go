@@ -123,6 +123,9 @@ func (c *Container) InitializeFirestore(cfg *firestoreConfig.FirestoreConfig) er
 	}
 
 	c.FirestoreModule = firestoreModule
+
+	// Listeners are checked again whenever the roles or tenants of their user change
+	c.AuthModule.OnUserAccessChanged(firestoreModule.RevalidateUserListeners)
 	return nil
 }
 
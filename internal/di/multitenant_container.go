@@ -89,6 +89,9 @@ func NewMultitenantContainer(
 		return nil, fmt.Errorf("failed to initialize firestore components: %w", err)
 	}
 
+	// Listeners are checked again whenever the roles or tenants of their user change
+	container.authModule.OnUserAccessChanged(container.firestoreModule.RevalidateUserListeners)
+
 	return container, nil
 }
 
@@ -105,6 +105,13 @@ func (h *HTTPHandler) RunAggregationQuery(c *fiber.Ctx) error {
 	// Assign the structured aggregation query
 	req.StructuredAggregationQuery = structuredAggQuery
 
+	// Aggregations only see the documents their query can return
+	if baseQuery := structuredAggQuery.StructuredQuery; baseQuery != nil {
+		if err := h.authorizeQuery(c, req.Parent+"/"+baseQuery.CollectionID, baseQuery); err != nil {
+			return operationErrorResponse(c, err, "permission_denied")
+		}
+	}
+
 	// Execute the aggregation query
 	response, err := h.FirestoreUC.RunAggregationQuery(c.UserContext(), req)
 	if err != nil {
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultChangeFeedWait is how long a change feed request long-polls when no wait is given
+const defaultChangeFeedWait = 30 * time.Second
+
+// registerChangeFeedRoutes registers the pull-based change feed endpoint
+func (h *HTTPHandler) registerChangeFeedRoutes(router fiber.Router) {
+	if h.ChangeFeedUC == nil {
+		return
+	}
+	// Changes carry the documents without security rules checks, so only administrators read the feed
+	router.Get("/changes", h.adminOnly(h.ReadChanges)...)
+}
+
+// ReadChanges returns the document changes after the since cursor in commit order.
+// Query parameters: since, collectionGroup, limit, view (FULL or AFTER) and wait, the
+// number of seconds to long-poll when there are no changes yet.
+func (h *HTTPHandler) ReadChanges(c *fiber.Ctx) error {
+	req := usecase.ReadChangesRequest{
+		ProjectID:       c.Params("projectID"),
+		DatabaseID:      c.Params("databaseID"),
+		Since:           c.Query("since"),
+		CollectionGroup: c.Query("collectionGroup"),
+		View:            model.ChangeFeedView(strings.ToUpper(c.Query("view"))),
+		Wait:            defaultChangeFeedWait,
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_limit",
+				"message": "limit must be a positive integer",
+			})
+		}
+		req.Limit = limit
+	}
+	if raw := c.Query("wait"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_wait",
+				"message": "wait must be a number of seconds",
+			})
+		}
+		req.Wait = time.Duration(seconds) * time.Second
+	}
+
+	page, err := h.ChangeFeedUC.ReadChanges(c.UserContext(), req)
+	if errors.Is(err, model.ErrChangeFeedCursorExpired) {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error":   "cursor_expired",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return operationErrorResponse(c, err, "read_changes_failed")
+	}
+	return c.JSON(page)
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changeFeedUCStub records the last change feed request
+type changeFeedUCStub struct {
+	usecase.ChangeFeedUsecase
+	lastRequest usecase.ReadChangesRequest
+	err         error
+}
+
+func (s *changeFeedUCStub) ReadChanges(ctx context.Context, req usecase.ReadChangesRequest) (*model.ChangeFeedPage, error) {
+	s.lastRequest = req
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.ChangeFeedPage{
+		Changes:    []*model.ChangeFeedEntry{{Cursor: "8", Kind: model.DocumentChangeCreated, Document: "users/u1/orders/o1"}},
+		NextCursor: "8",
+	}, nil
+}
+
+func newChangeFeedTestApp(changeFeedUC usecase.ChangeFeedUsecase) *fiber.App {
+	app := fiber.New()
+	h := &HTTPHandler{
+		FirestoreUC:    &MockFirestoreUC{},
+		ChangeFeedUC:   changeFeedUC,
+		RulesAdminAuth: []fiber.Handler{headerAdminAuth},
+		Log:            TestLogger{},
+	}
+	h.registerChangeFeedRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	return app
+}
+
+// changeFeedRequest builds a change feed request made by an administrator
+func changeFeedRequest(target string) *http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("X-User", "admin")
+	return req
+}
+
+func TestChangeFeedHandler_ReadChanges(t *testing.T) {
+	stub := &changeFeedUCStub{}
+	app := newChangeFeedTestApp(stub)
+
+	resp, err := app.Test(changeFeedRequest("/projects/p/databases/d/changes?since=7&collectionGroup=orders&limit=1000&view=after&wait=5"))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var page model.ChangeFeedPage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "8", page.NextCursor)
+
+	assert.Equal(t, usecase.ReadChangesRequest{
+		ProjectID:       "p",
+		DatabaseID:      "d",
+		Since:           "7",
+		CollectionGroup: "orders",
+		Limit:           1000,
+		View:            model.ChangeFeedViewAfter,
+		Wait:            5 * time.Second,
+	}, stub.lastRequest)
+}
+
+func TestChangeFeedHandler_Errors(t *testing.T) {
+	app := newChangeFeedTestApp(&changeFeedUCStub{})
+	resp, err := app.Test(changeFeedRequest("/projects/p/databases/d/changes?limit=-1"))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(changeFeedRequest("/projects/p/databases/d/changes?wait=soon"))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	app = newChangeFeedTestApp(&changeFeedUCStub{err: fmt.Errorf("%w: pruned", model.ErrChangeFeedCursorExpired)})
+	resp, err = app.Test(changeFeedRequest("/projects/p/databases/d/changes?since=1"))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusGone, resp.StatusCode)
+}
+
+func TestChangeFeedHandler_RequiresAdministrator(t *testing.T) {
+	stub := &changeFeedUCStub{}
+	app := newChangeFeedTestApp(stub)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/projects/p/databases/d/changes", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, stub.lastRequest.ProjectID)
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerCollectionSchemaRoutes registers collection schema management endpoints
+func (h *HTTPHandler) registerCollectionSchemaRoutes(router fiber.Router) {
+	if h.CollectionSchemaUC == nil {
+		return
+	}
+	// Schemas decide which writes are accepted, so only administrators manage them
+	router.Get("/schemas", h.adminOnly(h.ListCollectionSchemas)...)
+	router.Post("/schemas", h.adminOnly(h.SetCollectionSchema)...)
+	router.Get("/schemas/:schemaID", h.adminOnly(h.GetCollectionSchema)...)
+	router.Delete("/schemas/:schemaID", h.adminOnly(h.DeleteCollectionSchema)...)
+}
+
+// SetCollectionSchema creates or replaces the schema of a collection pattern
+func (h *HTTPHandler) SetCollectionSchema(c *fiber.Ctx) error {
+	var req usecase.SetCollectionSchemaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+	req.ProjectID = c.Params("projectID")
+	req.DatabaseID = c.Params("databaseID")
+
+	schema, err := h.CollectionSchemaUC.SetSchema(c.UserContext(), req)
+	if err != nil {
+		h.Log.Error("Failed to set collection schema", "error", err, "pattern", req.CollectionPattern)
+		return operationErrorResponse(c, err, "set_schema_failed")
+	}
+	return c.JSON(schema)
+}
+
+// GetCollectionSchema returns a collection schema
+func (h *HTTPHandler) GetCollectionSchema(c *fiber.Ctx) error {
+	schema, err := h.CollectionSchemaUC.GetSchema(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("schemaID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_schema_failed")
+	}
+	return c.JSON(schema)
+}
+
+// ListCollectionSchemas lists the collection schemas of a database
+func (h *HTTPHandler) ListCollectionSchemas(c *fiber.Ctx) error {
+	schemas, err := h.CollectionSchemaUC.ListSchemas(c.UserContext(), c.Params("projectID"), c.Params("databaseID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "list_schemas_failed")
+	}
+	return c.JSON(fiber.Map{
+		"schemas": schemas,
+		"metrics": h.CollectionSchemaUC.GetMetrics(),
+	})
+}
+
+// DeleteCollectionSchema removes a collection schema
+func (h *HTTPHandler) DeleteCollectionSchema(c *fiber.Ctx) error {
+	if err := h.CollectionSchemaUC.DeleteSchema(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("schemaID")); err != nil {
+		return operationErrorResponse(c, err, "delete_schema_failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// schemaViolationResponse writes an INVALID_ARGUMENT response when err is a rejected
+// collection schema write. It reports false when err is of any other kind.
+func schemaViolationResponse(c *fiber.Ctx, err error) (bool, error) {
+	appErr, ok := usecase.IsSchemaViolation(err)
+	if !ok {
+		return false, nil
+	}
+	return true, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "INVALID_ARGUMENT",
+		"message": appErr.Message,
+		"details": appErr.Details["validation_errors"],
+	})
+}
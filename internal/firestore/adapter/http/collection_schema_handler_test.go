@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCollectionSchemaTestApp(firestoreUC usecase.FirestoreUsecaseInterface, schemaUC usecase.CollectionSchemaUsecase) *fiber.App {
+	app := fiber.New()
+	h := &HTTPHandler{
+		FirestoreUC:        firestoreUC,
+		CollectionSchemaUC: schemaUC,
+		RulesAdminAuth:     []fiber.Handler{headerAdminAuth},
+		Log:                TestLogger{},
+	}
+	group := app.Group("/projects/:projectID/databases/:databaseID")
+	h.registerCollectionSchemaRoutes(group)
+	h.registerDocumentRoutes(group)
+	return app
+}
+
+// adminSchemaRequest builds a schema management request made by an administrator
+func adminSchemaRequest(method, target, body string) *http.Request {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-User", "admin")
+	return req
+}
+
+func TestCollectionSchemaHandler_Lifecycle(t *testing.T) {
+	schemaUC := usecase.NewCollectionSchemaUsecase(nil, nil, TestLogger{})
+	app := newCollectionSchemaTestApp(&MockFirestoreUC{}, schemaUC)
+
+	body := `{"collectionPattern":"users","mode":"warn","schema":{"type":"object","properties":{"age":{"type":"integer"}}}}`
+	resp, err := app.Test(adminSchemaRequest("POST", "/projects/p1/databases/d1/schemas", body))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var created model.CollectionSchema
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, "p1", created.ProjectID)
+	assert.Equal(t, model.SchemaModeWarn, created.Mode)
+
+	resp, err = app.Test(adminSchemaRequest("GET", "/projects/p1/databases/d1/schemas/"+created.SchemaID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(adminSchemaRequest("DELETE", "/projects/p1/databases/d1/schemas/"+created.SchemaID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, err = app.Test(adminSchemaRequest("GET", "/projects/p1/databases/d1/schemas/"+created.SchemaID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(adminSchemaRequest("POST", "/projects/p1/databases/d1/schemas", `{"collectionPattern":"users","mode":"strict","schema":{}}`))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCollectionSchemaHandler_RequiresAdministrator(t *testing.T) {
+	app := newCollectionSchemaTestApp(&MockFirestoreUC{}, usecase.NewCollectionSchemaUsecase(nil, nil, TestLogger{}))
+
+	body := `{"collectionPattern":"users","mode":"warn","schema":{"type":"object"}}`
+	req := httptest.NewRequest("POST", "/projects/p1/databases/d1/schemas", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/projects/p1/databases/d1/schemas", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestCollectionSchemaHandler_FailsClosedWithoutAdminAuth(t *testing.T) {
+	app := fiber.New()
+	h := &HTTPHandler{FirestoreUC: &MockFirestoreUC{}, CollectionSchemaUC: usecase.NewCollectionSchemaUsecase(nil, nil, TestLogger{}), Log: TestLogger{}}
+	h.registerCollectionSchemaRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+
+	resp, err := app.Test(adminSchemaRequest("GET", "/projects/p1/databases/d1/schemas", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestCollectionSchemaHandler_RejectedWriteIsInvalidArgument(t *testing.T) {
+	schemaUC := usecase.NewCollectionSchemaUsecase(nil, nil, TestLogger{})
+	_, err := schemaUC.SetSchema(context.Background(), usecase.SetCollectionSchemaRequest{
+		ProjectID:         "p1",
+		DatabaseID:        "d1",
+		CollectionPattern: "users",
+		Schema: &model.FieldSchema{
+			Type:       model.SchemaTypes{model.SchemaTypeObject},
+			Properties: map[string]*model.FieldSchema{"age": {Type: model.SchemaTypes{model.SchemaTypeInteger}}},
+		},
+	})
+	require.NoError(t, err)
+
+	firestoreUC := &MockFirestoreUCForDocuments{
+		CreateDocumentFn: func(ctx context.Context, req usecase.CreateDocumentRequest) (*model.Document, error) {
+			fields := map[string]*model.FieldValue{}
+			for k, v := range req.Data {
+				fields[k] = model.NewFieldValue(v)
+			}
+			if err := schemaUC.ValidateWrite(ctx, req.ProjectID, req.DatabaseID, req.CollectionID, fields); err != nil {
+				return nil, fmt.Errorf("failed to create document: %w", err)
+			}
+			return &model.Document{DocumentID: "u1"}, nil
+		},
+	}
+	app := newCollectionSchemaTestApp(firestoreUC, schemaUC)
+
+	req := httptest.NewRequest("POST", "/projects/p1/databases/d1/documents/users", strings.NewReader(`{"age":"old"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "INVALID_ARGUMENT", result["error"])
+	details := result["details"].([]interface{})
+	require.Len(t, details, 1)
+	assert.Equal(t, "age", details[0].(map[string]interface{})["field"])
+}
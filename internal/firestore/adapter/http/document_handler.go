@@ -2,8 +2,11 @@ package http
 
 import (
 	"encoding/json"
+	authModel "firestore-clone/internal/auth/domain/model"
 	"firestore-clone/internal/firestore/domain/model"
 	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
 	"fmt"
 	"log"
 	"strings"
@@ -24,16 +27,39 @@ type FirestoreCursor struct {
 }
 
 type FirestoreStructuredQuery struct {
-	From       []FirestoreCollectionSelector `json:"from,omitempty"`
-	Where      *FirestoreFilter              `json:"where,omitempty"`
-	OrderBy    []FirestoreOrder              `json:"orderBy,omitempty"`
-	Select     *FirestoreProjection          `json:"select,omitempty"`
-	Limit      int                           `json:"limit,omitempty"`
-	Offset     int                           `json:"offset,omitempty"`
-	StartAt    *FirestoreCursor              `json:"startAt,omitempty"`
-	StartAfter *FirestoreCursor              `json:"startAfter,omitempty"`
-	EndAt      *FirestoreCursor              `json:"endAt,omitempty"`
-	EndBefore  *FirestoreCursor              `json:"endBefore,omitempty"`
+	From        []FirestoreCollectionSelector `json:"from,omitempty"`
+	Where       *FirestoreFilter              `json:"where,omitempty"`
+	OrderBy     []FirestoreOrder              `json:"orderBy,omitempty"`
+	Select      *FirestoreProjection          `json:"select,omitempty"`
+	Limit       int                           `json:"limit,omitempty"`
+	Offset      int                           `json:"offset,omitempty"`
+	StartAt     *FirestoreCursor              `json:"startAt,omitempty"`
+	StartAfter  *FirestoreCursor              `json:"startAfter,omitempty"`
+	EndAt       *FirestoreCursor              `json:"endAt,omitempty"`
+	EndBefore   *FirestoreCursor              `json:"endBefore,omitempty"`
+	GeoFilter   *FirestoreGeoFilter           `json:"geoFilter,omitempty"`
+	FindNearest *FirestoreFindNearest         `json:"findNearest,omitempty"`
+}
+
+// FirestoreFindNearest is the Firestore vector similarity clause. The query vector is a
+// vectorValue, a Firestore vector mapValue or a plain list of numbers.
+type FirestoreFindNearest struct {
+	VectorField         FirestoreFieldReference `json:"vectorField"`
+	QueryVector         interface{}             `json:"queryVector"`
+	DistanceMeasure     string                  `json:"distanceMeasure"`
+	Limit               int                     `json:"limit"`
+	DistanceResultField string                  `json:"distanceResultField,omitempty"`
+	DistanceThreshold   *float64                `json:"distanceThreshold,omitempty"`
+}
+
+// FirestoreGeoFilter restricts a query to GeoPoints within a radius and/or bounding box.
+// It is an extension of the Firestore structured query format.
+type FirestoreGeoFilter struct {
+	Field           FirestoreFieldReference `json:"field"`
+	Near            *model.GeoPoint         `json:"near,omitempty"`
+	RadiusMeters    float64                 `json:"radiusMeters,omitempty"`
+	BoundingBox     *model.GeoBoundingBox   `json:"boundingBox,omitempty"`
+	OrderByDistance bool                    `json:"orderByDistance,omitempty"`
 }
 
 type FirestoreCollectionSelector struct {
@@ -350,6 +376,45 @@ func convertFirestoreJSONToModelQuery(firestoreQuery FirestoreStructuredQuery) (
 		query.EndBefore = convertFirestoreCursorValues(firestoreQuery.EndBefore.Values)
 	}
 
+	// Handle geo filter extension
+	if geo := firestoreQuery.GeoFilter; geo != nil {
+		query.Geo = &model.GeoQuery{
+			Field:           geo.Field.FieldPath,
+			Near:            geo.Near,
+			RadiusMeters:    geo.RadiusMeters,
+			BoundingBox:     geo.BoundingBox,
+			OrderByDistance: geo.OrderByDistance,
+		}
+		if err := query.Geo.Validate(); err != nil {
+			return nil, err
+		}
+		if query.Geo.OrderByDistance && len(query.Orders) > 0 {
+			return nil, fmt.Errorf("%w: ordering by distance cannot be combined with orderBy", model.ErrInvalidGeoQuery)
+		}
+	}
+
+	// Handle findNearest vector search
+	if nearest := firestoreQuery.FindNearest; nearest != nil {
+		vector, ok := model.VectorFromValue(nearest.QueryVector)
+		if !ok {
+			return nil, fmt.Errorf("%w: queryVector must be a vector value", model.ErrInvalidFindNearest)
+		}
+		query.FindNearest = &model.FindNearest{
+			VectorField:         nearest.VectorField.FieldPath,
+			QueryVector:         vector.Values,
+			DistanceMeasure:     model.DistanceMeasure(nearest.DistanceMeasure),
+			Limit:               nearest.Limit,
+			DistanceResultField: nearest.DistanceResultField,
+			DistanceThreshold:   nearest.DistanceThreshold,
+		}
+		if err := query.FindNearest.Validate(); err != nil {
+			return nil, err
+		}
+		if len(query.Orders) > 0 || (query.Geo != nil && query.Geo.OrderByDistance) {
+			return nil, fmt.Errorf("%w: results are ordered by vector distance and cannot have another ordering", model.ErrInvalidFindNearest)
+		}
+	}
+
 	return query, nil
 }
 
@@ -384,6 +449,9 @@ func (h *HTTPHandler) CreateDocument(c *fiber.Ctx) error {
 	document, err := h.FirestoreUC.CreateDocument(c.UserContext(), req)
 	if err != nil {
 		h.Log.Error("Failed to create document", "error", err)
+		if handled, respErr := schemaViolationResponse(c, err); handled {
+			return respErr
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "create_document_failed",
 			"message": err.Error(),
@@ -444,6 +512,9 @@ func (h *HTTPHandler) UpdateDocument(c *fiber.Ctx) error {
 	document, err := h.FirestoreUC.UpdateDocument(c.UserContext(), req)
 	if err != nil {
 		h.Log.Error("Failed to update document", "error", err)
+		if handled, respErr := schemaViolationResponse(c, err); handled {
+			return respErr
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "update_document_failed",
 			"message": err.Error(),
@@ -541,6 +612,11 @@ func (h *HTTPHandler) RunQuery(c *fiber.Ctx) error {
 	// Assign the structured query
 	req.StructuredQuery = query
 
+	// Rules are not filters: queries the list rules cannot prove safe are rejected
+	if err := h.authorizeQuery(c, req.Parent+"/"+query.CollectionID, query); err != nil {
+		return operationErrorResponse(c, err, "permission_denied")
+	}
+
 	// Execute the query
 	documents, err := h.FirestoreUC.RunQuery(c.UserContext(), req)
 	if err != nil {
@@ -723,6 +799,11 @@ func (h *HTTPHandler) QueryDocuments(c *fiber.Ctx) error {
 	// Assign the structured query
 	req.StructuredQuery = query
 
+	// Rules are not filters: queries the list rules cannot prove safe are rejected
+	if err := h.authorizeQuery(c, req.Parent+"/"+query.CollectionID, query); err != nil {
+		return operationErrorResponse(c, err, "permission_denied")
+	}
+
 	// Execute the query
 	documents, err := h.FirestoreUC.RunQuery(c.UserContext(), req)
 	if err != nil {
@@ -738,6 +819,22 @@ func (h *HTTPHandler) QueryDocuments(c *fiber.Ctx) error {
 	})
 }
 
+// authorizeQuery checks a query of a collection against the list rules before any
+// document is read. Unauthenticated queries are evaluated with request.auth == null; a
+// query is denied when its authenticated user cannot be loaded.
+func (h *HTTPHandler) authorizeQuery(c *fiber.Ctx, collectionPath string, query *model.Query) error {
+	if h.SecurityUC == nil {
+		return nil
+	}
+	var user *authModel.User
+	if userID, err := utils.GetUserIDFromContext(c.UserContext()); err == nil && userID != "" {
+		if user = h.resolveUser(c); user == nil {
+			return errors.NewAuthorizationError("query denied: the authenticated user could not be loaded")
+		}
+	}
+	return h.SecurityUC.ValidateQuery(c.UserContext(), user, collectionPath, query)
+}
+
 // ListDocuments lists all documents in a collection with pagination
 func (h *HTTPHandler) ListDocuments(c *fiber.Ctx) error {
 	h.Log.Debug("Listing documents via HTTP", "collection", c.Params("collectionID"))
@@ -817,6 +914,9 @@ func (h *HTTPHandler) CreateDocumentInSubcollection(c *fiber.Ctx) error {
 	document, err := h.FirestoreUC.CreateDocument(c.UserContext(), req)
 	if err != nil {
 		h.Log.Error("Failed to create document in subcollection", "error", err)
+		if handled, respErr := schemaViolationResponse(c, err); handled {
+			return respErr
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "create_document_failed",
 			"message": err.Error(),
@@ -920,6 +1020,9 @@ func (h *HTTPHandler) UpdateDocumentInSubcollection(c *fiber.Ctx) error {
 	document, err := h.FirestoreUC.UpdateDocument(c.UserContext(), req)
 	if err != nil {
 		h.Log.Error("Failed to update document in subcollection", "error", err)
+		if handled, respErr := schemaViolationResponse(c, err); handled {
+			return respErr
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "update_document_failed",
 			"message": err.Error(),
@@ -8,8 +8,11 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	authModel "firestore-clone/internal/auth/domain/model"
 	"firestore-clone/internal/firestore/domain/model"
 	"firestore-clone/internal/firestore/usecase"
+	sharedErrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -1243,3 +1246,166 @@ func TestRunQueryHandler_ComplexQuery(t *testing.T) {
 	_ = json.NewDecoder(resp.Body).Decode(&result)
 	assert.Equal(t, float64(1), result["count"])
 }
+
+func TestConvertFirestoreJSONToModelQuery_GeoFilter(t *testing.T) {
+	var structured FirestoreStructuredQuery
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"from": [{"collectionId": "stores"}],
+		"geoFilter": {
+			"field": {"fieldPath": "location"},
+			"near": {"latitude": 48.8566, "longitude": 2.3522},
+			"radiusMeters": 5000,
+			"orderByDistance": true
+		},
+		"limit": 10
+	}`), &structured))
+
+	query, err := convertFirestoreJSONToModelQuery(structured)
+	require.NoError(t, err)
+	require.NotNil(t, query.Geo)
+	assert.Equal(t, "location", query.Geo.Field)
+	assert.Equal(t, 5000.0, query.Geo.RadiusMeters)
+	assert.Equal(t, 2.3522, query.Geo.Near.Longitude)
+	assert.True(t, query.Geo.OrderByDistance)
+
+	structured.GeoFilter.Near = nil
+	_, err = convertFirestoreJSONToModelQuery(structured)
+	assert.ErrorIs(t, err, model.ErrInvalidGeoQuery)
+}
+
+func TestConvertFirestoreJSONToModelQuery_FindNearest(t *testing.T) {
+	var structured FirestoreStructuredQuery
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"from": [{"collectionId": "items"}],
+		"findNearest": {
+			"vectorField": {"fieldPath": "embedding"},
+			"queryVector": {"mapValue": {"fields": {
+				"__type__": {"stringValue": "__vector__"},
+				"value": {"arrayValue": {"values": [{"doubleValue": 0.5}, {"doubleValue": 1.5}]}}
+			}}},
+			"distanceMeasure": "COSINE",
+			"limit": 10,
+			"distanceResultField": "score"
+		}
+	}`), &structured))
+
+	query, err := convertFirestoreJSONToModelQuery(structured)
+	require.NoError(t, err)
+	require.NotNil(t, query.FindNearest)
+	assert.Equal(t, "embedding", query.FindNearest.VectorField)
+	assert.Equal(t, []float64{0.5, 1.5}, query.FindNearest.QueryVector)
+	assert.Equal(t, model.DistanceMeasureCosine, query.FindNearest.DistanceMeasure)
+	assert.Equal(t, "score", query.FindNearest.DistanceResultField)
+
+	structured.FindNearest.DistanceMeasure = "MANHATTAN"
+	_, err = convertFirestoreJSONToModelQuery(structured)
+	assert.ErrorIs(t, err, model.ErrInvalidFindNearest)
+}
+
+// querySecurityUC denies queries without an owner filter, as a rule checking
+// resource.data.owner == request.auth.uid would
+type querySecurityUC struct {
+	*usecase.MockSecurityUsecase
+	collectionPath string
+}
+
+func (m *querySecurityUC) ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error {
+	m.collectionPath = collectionPath
+	if user == nil {
+		return sharedErrors.NewAuthorizationError("query denied by security rules: request.auth is null")
+	}
+	for _, filter := range query.Filters {
+		if filter.Field == "owner" && filter.Operator == model.OperatorEqual && filter.Value == user.UserID {
+			return nil
+		}
+	}
+	return sharedErrors.NewAuthorizationError("query denied by security rules: resource.data.owner is not constrained by the query")
+}
+
+func TestRunQueryHandler_ChecksListRules(t *testing.T) {
+	queried := false
+	mockUC := &customQueryUC{
+		runQueryFunc: func(ctx context.Context, req usecase.QueryRequest) ([]*model.Document, error) {
+			queried = true
+			return []*model.Document{{DocumentID: "n1"}}, nil
+		},
+	}
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "alice"})
+	securityUC := &querySecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	h := &HTTPHandler{FirestoreUC: mockUC, SecurityUC: securityUC, AuthClient: authClient, Log: TestLogger{}}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), "alice"))
+		return c.Next()
+	})
+	app.Post("/projects/:projectID/databases/:databaseID/documents:runQuery", h.RunQuery)
+
+	runQuery := func(body string) (int, fiber.Map) {
+		req := httptest.NewRequest("POST", "/projects/p1/databases/d1/documents:runQuery", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result fiber.Map
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+
+	// Rules are not filters: the query is rejected before reading any document
+	status, result := runQuery(`{"structuredQuery": {"from": [{"collectionId": "notes"}]}}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, "permission_denied", result["error"])
+	assert.Contains(t, result["message"], "resource.data.owner")
+	assert.False(t, queried)
+	assert.Equal(t, "projects/p1/databases/d1/documents/notes", securityUC.collectionPath)
+
+	status, _ = runQuery(`{"structuredQuery": {"from": [{"collectionId": "notes"}],
+		"where": {"fieldFilter": {"field": {"fieldPath": "owner"}, "op": "EQUAL", "value": {"stringValue": "alice"}}}}}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.True(t, queried)
+}
+
+func TestRunQueryHandler_ChecksListRulesWithoutUser(t *testing.T) {
+	queried := false
+	mockUC := &customQueryUC{
+		runQueryFunc: func(ctx context.Context, req usecase.QueryRequest) ([]*model.Document, error) {
+			queried = true
+			return []*model.Document{{DocumentID: "n1"}}, nil
+		},
+	}
+	securityUC := &querySecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	h := &HTTPHandler{FirestoreUC: mockUC, SecurityUC: securityUC, AuthClient: usecase.NewMockAuthClient(), Log: TestLogger{}}
+
+	runQuery := func(userID string) (int, fiber.Map) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if userID != "" {
+				c.SetUserContext(utils.WithUserID(c.UserContext(), userID))
+			}
+			return c.Next()
+		})
+		app.Post("/projects/:projectID/databases/:databaseID/documents:runQuery", h.RunQuery)
+		req := httptest.NewRequest("POST", "/projects/p1/databases/d1/documents:runQuery", bytes.NewReader([]byte(`{"structuredQuery": {"from": [{"collectionId": "notes"}],
+			"where": {"fieldFilter": {"field": {"fieldPath": "owner"}, "op": "EQUAL", "value": {"stringValue": "alice"}}}}}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result fiber.Map
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+
+	// Unauthenticated queries are checked with request.auth == null
+	status, result := runQuery("")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Contains(t, result["message"], "request.auth is null")
+
+	// A user the auth service does not know is denied before the rules are evaluated
+	securityUC.collectionPath = ""
+	status, result = runQuery("mallory")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Contains(t, result["message"], "could not be loaded")
+	assert.Empty(t, securityUC.collectionPath)
+	assert.False(t, queried)
+}
@@ -15,6 +15,7 @@ import (
 	"firestore-clone/internal/firestore/usecase"
 	"firestore-clone/internal/shared/firestore"
 	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
@@ -36,6 +37,12 @@ type EnhancedWebSocketHandler struct {
 	// Heartbeat configuration
 	heartbeatInterval time.Duration
 	connectionTimeout time.Duration
+
+	// consistencyInterval is how often a global consistency point is sent while all targets are current
+	consistencyInterval time.Duration
+
+	// FirestoreUC reads the initial snapshot of listen targets; without it targets start empty
+	FirestoreUC usecase.FirestoreUsecaseInterface
 }
 
 // ConnectionState tracks the state of a WebSocket connection
@@ -43,7 +50,9 @@ type ConnectionState struct {
 	SubscriberID    string
 	Connection      *websocket.Conn
 	User            *authModel.User
+	UserID          string // Authenticated user; User is loaded from it on the first subscription
 	ActiveSubs      map[model.SubscriptionID]chan model.RealtimeEvent
+	Targets         map[model.SubscriptionID]*usecase.ListenTarget
 	LastHeartbeat   time.Time
 	Context         context.Context
 	CancelFunc      context.CancelFunc
@@ -60,13 +69,14 @@ func NewEnhancedWebSocketHandler(
 	log logger.Logger,
 ) *EnhancedWebSocketHandler {
 	handler := &EnhancedWebSocketHandler{
-		realtimeUC:        rtuc,
-		securityUC:        secUC,
-		authClient:        ac,
-		log:               log,
-		connections:       make(map[string]*ConnectionState),
-		heartbeatInterval: 30 * time.Second, // Send heartbeat every 30 seconds
-		connectionTimeout: 90 * time.Second, // Timeout after 90 seconds without response
+		realtimeUC:          rtuc,
+		securityUC:          secUC,
+		authClient:          ac,
+		log:                 log,
+		connections:         make(map[string]*ConnectionState),
+		heartbeatInterval:   30 * time.Second, // Send heartbeat every 30 seconds
+		connectionTimeout:   90 * time.Second, // Timeout after 90 seconds without response
+		consistencyInterval: 10 * time.Second,
 	}
 
 	// Start background processes
@@ -89,6 +99,13 @@ func (h *EnhancedWebSocketHandler) RegisterRoutes(router fiber.Router, authMiddl
 				c.Set("X-Firestore-Protocol-Ver", protocolVer)
 			}
 			c.Locals("allowed", true)
+			if userID, err := utils.GetUserIDFromContext(c.UserContext()); err == nil {
+				c.Locals("userId", userID)
+			}
+			// Snapshot reads of listen targets are scoped to the organization
+			if organizationID := requestOrganizationID(c); organizationID != "" {
+				c.Locals("organizationId", organizationID)
+			}
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
@@ -101,19 +118,27 @@ func (h *EnhancedWebSocketHandler) RegisterRoutes(router fiber.Router, authMiddl
 // handleEnhancedWebSocketConnection handles new WebSocket connections with full Firestore features
 func (h *EnhancedWebSocketHandler) handleEnhancedWebSocketConnection(conn *websocket.Conn) {
 	subscriberID := uuid.NewString()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx := context.Background()
+	if organizationID, ok := conn.Locals("organizationId").(string); ok && organizationID != "" {
+		ctx = utils.WithOrganizationID(ctx, organizationID)
+	}
+	ctx, cancel := context.WithCancel(ctx)
 
 	// Create connection state
 	connState := &ConnectionState{
 		SubscriberID:    subscriberID,
 		Connection:      conn,
 		ActiveSubs:      make(map[model.SubscriptionID]chan model.RealtimeEvent),
+		Targets:         make(map[model.SubscriptionID]*usecase.ListenTarget),
 		LastHeartbeat:   time.Now(),
 		Context:         ctx,
 		CancelFunc:      cancel,
 		MessageQueue:    make(chan model.WebSocketMessage, 100),
 		IsAuthenticated: false,
 	}
+	if userID, ok := conn.Locals("userId").(string); ok {
+		connState.UserID = userID
+	}
 
 	// Register connection
 	h.connMutex.Lock()
@@ -129,7 +154,7 @@ func (h *EnhancedWebSocketHandler) handleEnhancedWebSocketConnection(conn *webso
 	// Start connection handlers
 	go h.handleIncomingMessages(connState)
 	go h.handleOutgoingMessages(connState)
-	go h.handleRealtimeEvents(connState)
+	go h.sendConsistencyPoints(connState)
 
 	// Authentication flow
 	if err := h.authenticateConnection(connState); err != nil {
@@ -241,18 +266,33 @@ func (h *EnhancedWebSocketHandler) handleEnhancedSubscribe(connState *Connection
 	}
 
 	// Validate security permissions
-	if h.securityUC != nil && connState.User != nil {
-		if err := h.securityUC.ValidateRead(connState.Context, connState.User, req.FullPath); err != nil {
+	connState.mutex.RLock()
+	user := connState.User
+	connState.mutex.RUnlock()
+	if user == nil {
+		user = loadListenUser(connState.Context, h.authClient, connState.UserID, req.FullPath, h.log)
+		connState.mutex.Lock()
+		connState.User = user
+		connState.mutex.Unlock()
+	}
+	if h.securityUC != nil && user != nil {
+		if err := authorizeListenTarget(connState.Context, h.securityUC, user, req.FullPath, req.Query); err != nil {
 			h.log.Warn("Security validation failed for WebSocket subscription",
 				zap.String("subscriberID", connState.SubscriberID),
 				zap.String("path", req.FullPath),
-				zap.String("userID", connState.User.ID.Hex()),
+				zap.String("userID", user.ID.Hex()),
 				zap.Error(err))
 			h.sendSubscriptionError(connState, req.SubscriptionID, "forbidden", "Access denied to this path")
 			return
 		}
 	}
 
+	target, err := usecase.NewListenTarget(req.SubscriptionID, req.FullPath, req.Query)
+	if err != nil {
+		h.sendSubscriptionError(connState, req.SubscriptionID, "invalid_target", err.Error())
+		return
+	}
+
 	// Create event channel for this subscription
 	eventChan := make(chan model.RealtimeEvent, 200) // Larger buffer for better performance
 	// Register subscription with enhanced features
@@ -261,7 +301,6 @@ func (h *EnhancedWebSocketHandler) handleEnhancedSubscribe(connState *Connection
 		SubscriptionID: req.SubscriptionID,
 		FirestorePath:  req.FullPath,
 		EventChannel:   eventChan,
-		ResumeToken:    req.ResumeToken,
 		Query:          req.Query,
 		Options: usecase.SubscriptionOptions{
 			IncludeMetadata:   true,
@@ -285,6 +324,7 @@ func (h *EnhancedWebSocketHandler) handleEnhancedSubscribe(connState *Connection
 	// Track subscription in connection state
 	connState.mutex.Lock()
 	connState.ActiveSubs[req.SubscriptionID] = eventChan
+	connState.Targets[req.SubscriptionID] = target
 	connState.mutex.Unlock()
 
 	h.log.Info("Client subscribed to path",
@@ -310,6 +350,9 @@ func (h *EnhancedWebSocketHandler) handleEnhancedSubscribe(connState *Connection
 	}
 
 	h.sendSubscriptionResponse(connState, response)
+
+	// A resumed client holds documents of the target, which the snapshot replaces
+	go h.runListenTarget(connState, target, eventChan, req.ResumeToken != "")
 }
 
 // handleEnhancedUnsubscribe processes unsubscription requests
@@ -333,6 +376,7 @@ func (h *EnhancedWebSocketHandler) handleEnhancedUnsubscribe(connState *Connecti
 		close(eventChan)
 		delete(connState.ActiveSubs, req.SubscriptionID)
 	}
+	delete(connState.Targets, req.SubscriptionID)
 	connState.mutex.Unlock()
 
 	h.log.Info("Client unsubscribed from path",
@@ -349,67 +393,117 @@ func (h *EnhancedWebSocketHandler) handleEnhancedUnsubscribe(connState *Connecti
 	h.sendSubscriptionResponse(connState, response)
 }
 
-// handleRealtimeEvents forwards real-time events from subscriptions to the client
-func (h *EnhancedWebSocketHandler) handleRealtimeEvents(connState *ConnectionState) {
-	defer connState.CancelFunc()
+// RevalidateListeners checks the listeners in scope against the current security rules,
+// closing with a PERMISSION_DENIED subscription error those no longer allowed
+func (h *EnhancedWebSocketHandler) RevalidateListeners(ctx context.Context, scope usecase.ListenerRevalidation) []usecase.RevokedListener {
+	if h.securityUC == nil {
+		return nil
+	}
+	h.connMutex.RLock()
+	connections := make([]*ConnectionState, 0, len(h.connections))
+	for _, connState := range h.connections {
+		connections = append(connections, connState)
+	}
+	h.connMutex.RUnlock()
+
+	revalidation := listenRevalidation{
+		realtimeUC: h.realtimeUC,
+		securityUC: h.securityUC,
+		authClient: h.authClient,
+		log:        h.log,
+		scope:      scope,
+	}
+	var revoked []usecase.RevokedListener
+	for _, connState := range connections {
+		connState.mutex.RLock()
+		user := connState.User
+		connState.mutex.RUnlock()
+		if user == nil || !revalidation.includes(connState.UserID) {
+			continue
+		}
+		denied := revalidation.revoke(ctx, connState.SubscriberID, connState.UserID, user)
+		for _, listener := range denied {
+			subscriptionID := model.SubscriptionID(listener.SubscriptionID)
+			connState.mutex.Lock()
+			if eventChan, exists := connState.ActiveSubs[subscriptionID]; exists {
+				close(eventChan)
+				delete(connState.ActiveSubs, subscriptionID)
+			}
+			delete(connState.Targets, subscriptionID)
+			connState.mutex.Unlock()
+			h.sendSubscriptionError(connState, subscriptionID, "PERMISSION_DENIED", listener.Reason)
+		}
+		revoked = append(revoked, denied...)
+	}
+	return revoked
+}
+
+// runListenTarget delivers a listen target to the client until it is unsubscribed
+func (h *EnhancedWebSocketHandler) runListenTarget(connState *ConnectionState, target *usecase.ListenTarget, eventChan <-chan model.RealtimeEvent, reset bool) {
+	stream := &listenStream{
+		target:     target,
+		events:     eventChan,
+		reader:     h.FirestoreUC,
+		lock:       &connState.mutex,
+		subscriber: connState.SubscriberID,
+		log:        h.log,
+		send: func(response model.ListenResponse) bool {
+			return h.queueListenResponse(connState, target.ID, response)
+		},
+	}
+	stream.run(connState.Context, reset)
+}
+
+// sendConsistencyPoints periodically tells the client that all its targets are consistent
+// with a global NO_CHANGE target change
+func (h *EnhancedWebSocketHandler) sendConsistencyPoints(connState *ConnectionState) {
+	ticker := time.NewTicker(h.consistencyInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-connState.Context.Done():
 			return
-		default:
-			// Check all active subscriptions for events
+		case <-ticker.C:
 			connState.mutex.RLock()
-			subscriptions := make(map[model.SubscriptionID]chan model.RealtimeEvent)
-			for subID, ch := range connState.ActiveSubs {
-				subscriptions[subID] = ch
-			}
+			consistent := targetsConsistent(connState.Targets, connState.ActiveSubs)
 			connState.mutex.RUnlock()
-
-			// Process events from all subscriptions
-			for subID, eventChan := range subscriptions {
-				select {
-				case event, ok := <-eventChan:
-					if !ok {
-						// Channel was closed, remove from active subscriptions
-						connState.mutex.Lock()
-						delete(connState.ActiveSubs, subID)
-						connState.mutex.Unlock()
-						continue
-					}
-
-					// Forward event to client
-					msg := model.WebSocketMessage{
-						Type:           model.MessageTypeDocumentChange,
-						SubscriptionID: subID,
-						Data: map[string]interface{}{
-							"event": event,
-						},
-						Timestamp: time.Now(),
-					}
-
-					select {
-					case connState.MessageQueue <- msg:
-						h.log.Debug("Event queued for client",
-							zap.String("subscriberID", connState.SubscriberID),
-							zap.String("subscriptionID", string(subID)),
-							zap.String("eventType", string(event.Type)))
-					default:
-						h.log.Warn("Message queue full, dropping event",
-							zap.String("subscriberID", connState.SubscriberID),
-							zap.String("subscriptionID", string(subID)))
-					}
-				default:
-					// No event available
-				}
+			if consistent {
+				h.queueListenResponse(connState, "", globalConsistencyPoint())
 			}
-
-			// Small delay to prevent busy waiting
-			time.Sleep(5 * time.Millisecond)
 		}
 	}
 }
 
+// queueListenResponse queues a Listen protocol message, reporting false when it could not be sent
+func (h *EnhancedWebSocketHandler) queueListenResponse(connState *ConnectionState, targetID model.SubscriptionID, response model.ListenResponse) bool {
+	kind, content := listenResponseKind(response)
+	msg := model.WebSocketMessage{
+		Type:           model.MessageTypeListen,
+		SubscriptionID: targetID,
+		Data:           map[string]interface{}{kind: content},
+		Timestamp:      time.Now(),
+	}
+
+	// The queue is closed under the connection lock once the connection is gone
+	connState.mutex.RLock()
+	defer connState.mutex.RUnlock()
+	if connState.Context.Err() != nil {
+		return false
+	}
+	select {
+	case connState.MessageQueue <- msg:
+		return true
+	case <-connState.Context.Done():
+		return false
+	case <-time.After(5 * time.Second):
+		h.log.Warn("Message queue full, listen target will be resynchronized",
+			zap.String("subscriberID", connState.SubscriberID),
+			zap.String("subscriptionID", string(targetID)))
+		return false
+	}
+}
+
 // handleOutgoingMessages processes the message queue and sends messages to the client
 func (h *EnhancedWebSocketHandler) handleOutgoingMessages(connState *ConnectionState) {
 	defer connState.CancelFunc()
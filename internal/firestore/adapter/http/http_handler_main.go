@@ -18,6 +18,24 @@ type HTTPHandler struct {
 	SecurityUC  usecase.SecurityUsecase
 	RealtimeUC  usecase.RealtimeUsecase
 
+	// Optional use cases, registered only when configured
+	RecursiveDeleteUC  usecase.RecursiveDeleteUsecase
+	CollectionSchemaUC usecase.CollectionSchemaUsecase
+	SchemaDiscoveryUC  usecase.SchemaDiscoveryUsecase
+	SearchUC           usecase.SearchUsecase
+	TriggerUC          usecase.TriggerUsecase
+	ChangeFeedUC       usecase.ChangeFeedUsecase
+	RulesCRUDUC        usecase.SecurityRulesCRUDUsecase
+	RulesReleaseUC     usecase.SecurityRulesReleaseUsecase
+	RulesSimulatorUC   usecase.SecurityRulesSimulatorUsecase
+	RulesTestUC        usecase.SecurityRulesTestUsecase
+	RulesCoverageUC    usecase.SecurityRulesCoverageUsecase
+
+	// RulesAdminAuth authenticates the database administrators; the rules management and
+	// the other administration routes run it before their handlers and refuse every request
+	// without it
+	RulesAdminAuth []fiber.Handler
+
 	// External dependencies (secondary adapters)
 	AuthClient client.AuthClient
 	Log        logger.Logger
@@ -67,8 +85,19 @@ func (h *HTTPHandler) RegisterRoutes(router fiber.Router) { // Register organiza
 	dbAPI := projectAPI.Group("/databases/:databaseID", ValidateFirestoreHierarchy())
 
 	// Register domain-specific routes following Firestore API standards
-	// Note: Atomic routes must come before document routes to avoid route conflicts
+	// Note: Atomic and recursive delete routes must come before document routes to avoid route conflicts
 	h.registerAtomicRoutes(dbAPI)
+	h.registerRecursiveDeleteRoutes(dbAPI)
+	h.registerCollectionSchemaRoutes(dbAPI)
+	h.registerSchemaDiscoveryRoutes(dbAPI)
+	h.registerSearchRoutes(dbAPI)
+	h.registerTriggerRoutes(dbAPI)
+	h.registerSecurityRulesRoutes(dbAPI)
+	h.registerRulesReleaseRoutes(dbAPI)
+	h.registerRulesSimulatorRoutes(dbAPI)
+	h.registerRulesTestRoutes(dbAPI)
+	h.registerRulesCoverageRoutes(dbAPI)
+	h.registerChangeFeedRoutes(dbAPI)
 	h.registerDocumentRoutes(dbAPI)
 	h.registerCollectionRoutes(dbAPI)
 	h.registerIndexRoutes(dbAPI)
@@ -0,0 +1,278 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/client"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/firestore"
+	"firestore-clone/internal/shared/logger"
+
+	"go.uber.org/zap"
+)
+
+// listenStream delivers one listen target over a transport: ADD, the initial snapshot and
+// CURRENT, then the changes of its realtime events until the event channel is closed.
+// The WebSocket and SSE handlers share it.
+type listenStream struct {
+	target     *usecase.ListenTarget
+	events     <-chan model.RealtimeEvent
+	reader     usecase.FirestoreUsecaseInterface // Reads snapshots; without it targets start empty
+	lock       sync.Locker                       // Guards the target against consistency checks
+	send       func(model.ListenResponse) bool   // Reports false when a message could not be sent
+	subscriber string
+	log        logger.Logger
+}
+
+// run delivers the target until ctx is done or the event channel is closed. A resumed
+// client holds documents of the target, which the snapshot replaces after a RESET.
+func (s *listenStream) run(ctx context.Context, reset bool) {
+	s.send(model.NewTargetChange(model.TargetChangeAdd, s.target.ID))
+	readTime := s.sendSnapshot(ctx, reset)
+	resync := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-s.events:
+			if !ok {
+				s.send(s.target.Remove("", ""))
+				return
+			}
+			if resync {
+				// Messages were lost; the client view is rebuilt from a new snapshot
+				readTime = s.sendSnapshot(ctx, true)
+				resync = false
+			}
+			// Changes committed before the snapshot read are already part of it
+			if !event.Timestamp.IsZero() && event.Timestamp.Before(readTime) {
+				continue
+			}
+			s.lock.Lock()
+			responses := s.target.Apply(event)
+			s.lock.Unlock()
+			for _, response := range responses {
+				if !s.send(response) {
+					resync = true
+					break
+				}
+			}
+			if !resync && s.target.NeedsRefresh() && s.reader != nil {
+				readTime = s.refresh(ctx, readTime)
+			}
+		}
+	}
+}
+
+// sendSnapshot reads and sends the documents of the target, returning the read time
+func (s *listenStream) sendSnapshot(ctx context.Context, reset bool) time.Time {
+	readTime := time.Now()
+	var docs []*model.Document
+	if s.reader != nil {
+		var err error
+		docs, err = s.target.ReadSnapshot(ctx, s.reader)
+		if err != nil {
+			s.log.Warn("Failed to read listen target snapshot",
+				zap.String("subscriberID", s.subscriber),
+				zap.String("subscriptionID", string(s.target.ID)),
+				zap.Error(err))
+			s.send(s.target.Remove("INTERNAL", "failed to read the target documents"))
+			return readTime
+		}
+	}
+
+	s.lock.Lock()
+	responses := s.target.Snapshot(docs, readTime, reset)
+	s.lock.Unlock()
+	for _, response := range responses {
+		s.send(response)
+	}
+	return readTime
+}
+
+// refresh reads the target again and sends the differences from the client view,
+// backfilling limited windows; it returns the new read time, or lastReadTime on failure
+func (s *listenStream) refresh(ctx context.Context, lastReadTime time.Time) time.Time {
+	readTime := time.Now()
+	docs, err := s.target.ReadSnapshot(ctx, s.reader)
+	if err != nil {
+		s.log.Warn("Failed to refresh listen target",
+			zap.String("subscriberID", s.subscriber),
+			zap.String("subscriptionID", string(s.target.ID)),
+			zap.Error(err))
+		return lastReadTime
+	}
+
+	s.lock.Lock()
+	responses := s.target.Refresh(docs, readTime)
+	s.lock.Unlock()
+	for _, response := range responses {
+		s.send(response)
+	}
+	return readTime
+}
+
+// targetsConsistent reports whether every target is current with no events pending, so
+// that a global consistency point can be sent. The caller holds the lock of the targets.
+func targetsConsistent(targets map[model.SubscriptionID]*usecase.ListenTarget, events map[model.SubscriptionID]chan model.RealtimeEvent) bool {
+	if len(targets) == 0 {
+		return false
+	}
+	for id, target := range targets {
+		if !target.IsCurrent() || len(events[id]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// globalConsistencyPoint returns the NO_CHANGE target change without target IDs marking
+// all targets consistent as of now
+func globalConsistencyPoint() model.ListenResponse {
+	readTime := time.Now()
+	response := model.NewTargetChange(model.TargetChangeNoChange)
+	response.TargetChange.ReadTime = &readTime
+	return response
+}
+
+// listenResponseKind returns the name and content of the message set in a ListenResponse
+func listenResponseKind(response model.ListenResponse) (string, interface{}) {
+	switch {
+	case response.TargetChange != nil:
+		return "targetChange", response.TargetChange
+	case response.DocumentChange != nil:
+		return "documentChange", response.DocumentChange
+	case response.DocumentDelete != nil:
+		return "documentDelete", response.DocumentDelete
+	case response.DocumentRemove != nil:
+		return "documentRemove", response.DocumentRemove
+	default:
+		return "", nil
+	}
+}
+
+// authorizeListenTarget checks that a user can listen to a target: document targets are
+// reads, collection targets are queries checked against the list rules
+func authorizeListenTarget(ctx context.Context, securityUC usecase.SecurityUsecase, user *authModel.User, fullPath string, query *model.Query) error {
+	pathInfo, err := firestore.ParseFirestorePath(fullPath)
+	if err != nil {
+		return err
+	}
+	if pathInfo.IsDocument {
+		return securityUC.ValidateRead(ctx, user, fullPath)
+	}
+	if query == nil {
+		query = &model.Query{}
+	}
+	return securityUC.ValidateQuery(ctx, user, fullPath, query)
+}
+
+// loadListenUser loads the authenticated user of a listen connection. Connections are not
+// bound to a project, so the user is loaded from the project of the first target.
+func loadListenUser(ctx context.Context, authClient client.AuthClient, userID, fullPath string, log logger.Logger) *authModel.User {
+	if userID == "" || authClient == nil {
+		return nil
+	}
+	pathInfo, err := firestore.ParseFirestorePath(fullPath)
+	if err != nil {
+		return nil
+	}
+	user, err := authClient.GetUserByID(ctx, userID, pathInfo.ProjectID)
+	if err != nil {
+		log.Warn("Failed to load authenticated user", zap.String("userID", userID), zap.Error(err))
+		return nil
+	}
+	return user
+}
+
+// listenRevalidation checks the listeners in a scope again after the rules of their
+// database or the roles of their user change
+type listenRevalidation struct {
+	realtimeUC usecase.RealtimeUsecase
+	securityUC usecase.SecurityUsecase
+	authClient client.AuthClient
+	log        logger.Logger
+	scope      usecase.ListenerRevalidation
+}
+
+// includes reports whether the listeners of a user are in scope
+func (v listenRevalidation) includes(userID string) bool {
+	return v.scope.UserID == "" || v.scope.UserID == userID
+}
+
+// revoke revalidates the subscriptions in scope of a subscriber, unsubscribing and
+// returning those the rules deny. The user is loaded again for the project of each
+// subscription so that role changes are seen, and subscriptions of a user that can no
+// longer be loaded are revoked; subscriptions whose rules cannot be evaluated are kept.
+func (v listenRevalidation) revoke(ctx context.Context, subscriberID, userID string, user *authModel.User) []usecase.RevokedListener {
+	prefix := "projects/"
+	if v.scope.ProjectID != "" {
+		prefix = fmt.Sprintf("projects/%s/databases/", v.scope.ProjectID)
+		if v.scope.DatabaseID != "" {
+			prefix += v.scope.DatabaseID + "/"
+		}
+	}
+	users := make(map[string]*authModel.User)
+
+	var revoked []usecase.RevokedListener
+	err := v.realtimeUC.ValidatePermissions(ctx, subscriberID, func(subscription *usecase.Subscription) error {
+		if !strings.HasPrefix(subscription.FirestorePath, prefix) {
+			return nil
+		}
+		pathInfo, err := firestore.ParseFirestorePath(subscription.FirestorePath)
+		if err != nil {
+			return nil
+		}
+		current, loaded := users[pathInfo.ProjectID]
+		if !loaded {
+			current = user
+			if v.authClient != nil && userID != "" {
+				reloaded, err := v.authClient.GetUserByID(ctx, userID, pathInfo.ProjectID)
+				if err != nil {
+					v.log.Warn("Failed to reload authenticated user", zap.String("userID", userID), zap.Error(err))
+				}
+				current = reloaded
+			}
+			users[pathInfo.ProjectID] = current
+		}
+
+		if userID != "" && current == nil {
+			// A deleted or unloadable user keeps no listener
+			err = errors.NewAuthorizationError("listener revoked: the authenticated user could not be loaded")
+		} else {
+			err = authorizeListenTarget(ctx, v.securityUC, current, subscription.FirestorePath, subscription.Query)
+		}
+		if err == nil {
+			return nil
+		}
+		if !errors.IsAuthorization(err) {
+			v.log.Warn("Listener could not be revalidated",
+				zap.String("subscriberID", subscriberID),
+				zap.String("path", subscription.FirestorePath),
+				zap.Error(err))
+			return nil
+		}
+		revoked = append(revoked, usecase.RevokedListener{
+			ProjectID:      pathInfo.ProjectID,
+			DatabaseID:     pathInfo.DatabaseID,
+			SubscriberID:   subscriberID,
+			SubscriptionID: string(subscription.SubscriptionID),
+			Path:           subscription.FirestorePath,
+			UserID:         userID,
+			Reason:         err.Error(),
+		})
+		return err
+	})
+	if err != nil {
+		v.log.Error("Failed to revalidate listeners", zap.String("subscriberID", subscriberID), zap.Error(err))
+	}
+	return revoked
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerRecursiveDeleteRoutes registers recursive delete and long-running operation endpoints
+// Must be registered before document routes so the :recursiveDelete suffix is not taken as an ID
+func (h *HTTPHandler) registerRecursiveDeleteRoutes(router fiber.Router) {
+	if h.RecursiveDeleteUC == nil {
+		return
+	}
+	router.Post("/documents/*\\:recursiveDelete", h.RecursiveDelete)
+	router.Get("/operations/:operationID", h.GetOperation)
+	router.Post("/operations/:operationID\\:resume", h.ResumeOperation)
+	router.Post("/operations/:operationID\\:cancel", h.CancelOperation)
+}
+
+// RecursiveDelete starts a recursive delete of a document or collection and all its descendants
+func (h *HTTPHandler) RecursiveDelete(c *fiber.Ctx) error {
+	req := usecase.RecursiveDeleteRequest{
+		ProjectID:  c.Params("projectID"),
+		DatabaseID: c.Params("databaseID"),
+		Path:       c.Params("*"),
+	}
+
+	var body struct {
+		BatchSize   int  `json:"batchSize"`
+		BypassRules bool `json:"bypassRules"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_request_body",
+				"message": "Failed to parse request body",
+			})
+		}
+	}
+	req.BatchSize = body.BatchSize
+	req.BypassRules = body.BypassRules
+	req.User = h.resolveUser(c)
+
+	h.Log.Debug("Starting recursive delete via HTTP", "path", req.Path, "bypassRules", req.BypassRules)
+
+	op, err := h.RecursiveDeleteUC.StartRecursiveDelete(c.UserContext(), req)
+	if err != nil {
+		h.Log.Error("Failed to start recursive delete", "error", err, "path", req.Path)
+		return operationErrorResponse(c, err, "recursive_delete_failed")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(op)
+}
+
+// GetOperation returns the state of a long-running operation
+func (h *HTTPHandler) GetOperation(c *fiber.Ctx) error {
+	op, err := h.getScopedOperation(c, h.resolveUser(c))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_operation_failed")
+	}
+	return c.JSON(op)
+}
+
+// ResumeOperation restarts a failed or cancelled long-running operation
+func (h *HTTPHandler) ResumeOperation(c *fiber.Ctx) error {
+	user := h.resolveUser(c)
+	if _, err := h.getScopedOperation(c, user); err != nil {
+		return operationErrorResponse(c, err, "resume_operation_failed")
+	}
+
+	op, err := h.RecursiveDeleteUC.ResumeOperation(c.UserContext(), c.Params("operationID"), user)
+	if err != nil {
+		h.Log.Error("Failed to resume operation", "error", err, "operationID", c.Params("operationID"))
+		return operationErrorResponse(c, err, "resume_operation_failed")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(op)
+}
+
+// CancelOperation requests cancellation of a running long-running operation
+func (h *HTTPHandler) CancelOperation(c *fiber.Ctx) error {
+	user := h.resolveUser(c)
+	if _, err := h.getScopedOperation(c, user); err != nil {
+		return operationErrorResponse(c, err, "cancel_operation_failed")
+	}
+
+	if err := h.RecursiveDeleteUC.CancelOperation(c.UserContext(), c.Params("operationID"), user); err != nil {
+		h.Log.Error("Failed to cancel operation", "error", err, "operationID", c.Params("operationID"))
+		return operationErrorResponse(c, err, "cancel_operation_failed")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// getScopedOperation loads an operation of the user and hides it when it belongs to another project or database
+func (h *HTTPHandler) getScopedOperation(c *fiber.Ctx, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	op, err := h.RecursiveDeleteUC.GetOperation(c.UserContext(), c.Params("operationID"), user)
+	if err != nil {
+		return nil, err
+	}
+	if op.ProjectID != c.Params("projectID") || op.DatabaseID != c.Params("databaseID") {
+		return nil, errors.NewNotFoundError("operation")
+	}
+	return op, nil
+}
+
+// resolveUser loads the authenticated user, if any, so security rules and role checks can be applied
+func (h *HTTPHandler) resolveUser(c *fiber.Ctx) *authModel.User {
+	userID, err := utils.GetUserIDFromContext(c.UserContext())
+	if err != nil || userID == "" || h.AuthClient == nil {
+		return nil
+	}
+	user, err := h.AuthClient.GetUserByID(c.UserContext(), userID, c.Params("projectID"))
+	if err != nil {
+		h.Log.Warn("Failed to load authenticated user", "userID", userID, "error", err)
+		return nil
+	}
+	return user
+}
+
+// operationErrorResponse maps usecase errors to HTTP responses
+func operationErrorResponse(c *fiber.Ctx, err error, code string) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.IsValidation(err):
+		status = fiber.StatusBadRequest
+	case errors.IsAuthentication(err):
+		status = fiber.StatusUnauthorized
+	case errors.IsAuthorization(err):
+		status = fiber.StatusForbidden
+	case errors.IsNotFound(err):
+		status = fiber.StatusNotFound
+	case errors.IsConflict(err):
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   code,
+		"message": err.Error(),
+	})
+}
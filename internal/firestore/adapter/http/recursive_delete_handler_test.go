@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRecursiveDeleteUC struct {
+	lastRequest usecase.RecursiveDeleteRequest
+	operation   *model.RecursiveDeleteOperation
+	startErr    error
+	cancelled   string
+}
+
+func (m *mockRecursiveDeleteUC) StartRecursiveDelete(ctx context.Context, req usecase.RecursiveDeleteRequest) (*model.RecursiveDeleteOperation, error) {
+	m.lastRequest = req
+	if m.startErr != nil {
+		return nil, m.startErr
+	}
+	return m.operation, nil
+}
+
+func (m *mockRecursiveDeleteUC) GetOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	if m.operation == nil || m.operation.OperationID != operationID {
+		return nil, errors.NewNotFoundError("operation")
+	}
+	return m.operation, nil
+}
+
+func (m *mockRecursiveDeleteUC) ResumeOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	return m.operation, nil
+}
+
+func (m *mockRecursiveDeleteUC) CancelOperation(ctx context.Context, operationID string, user *authModel.User) error {
+	m.cancelled = operationID
+	return nil
+}
+
+func newRecursiveDeleteTestApp(uc usecase.RecursiveDeleteUsecase) *fiber.App {
+	app := fiber.New()
+	h := &HTTPHandler{FirestoreUC: &MockFirestoreUC{}, RecursiveDeleteUC: uc, Log: TestLogger{}}
+	group := app.Group("/projects/:projectID/databases/:databaseID")
+	h.registerRecursiveDeleteRoutes(group)
+	h.registerDocumentRoutes(group)
+	return app
+}
+
+func TestRecursiveDeleteHandler_Start(t *testing.T) {
+	uc := &mockRecursiveDeleteUC{operation: &model.RecursiveDeleteOperation{OperationID: "op1", State: model.OperationStateRunning}}
+	app := newRecursiveDeleteTestApp(uc)
+
+	req := httptest.NewRequest("POST", "/projects/p1/databases/d1/documents/users/u1/posts:recursiveDelete", strings.NewReader(`{"batchSize":50}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusAccepted, resp.StatusCode)
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "op1", result["operationId"])
+	assert.Equal(t, "users/u1/posts", uc.lastRequest.Path)
+	assert.Equal(t, "p1", uc.lastRequest.ProjectID)
+	assert.Equal(t, "d1", uc.lastRequest.DatabaseID)
+	assert.Equal(t, 50, uc.lastRequest.BatchSize)
+}
+
+func TestRecursiveDeleteHandler_BypassDenied(t *testing.T) {
+	uc := &mockRecursiveDeleteUC{startErr: errors.NewAuthorizationError("only administrators can bypass security rules")}
+	app := newRecursiveDeleteTestApp(uc)
+
+	req := httptest.NewRequest("POST", "/projects/p1/databases/d1/documents/users:recursiveDelete", strings.NewReader(`{"bypassRules":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	assert.True(t, uc.lastRequest.BypassRules)
+}
+
+func TestRecursiveDeleteHandler_Operations(t *testing.T) {
+	uc := &mockRecursiveDeleteUC{operation: &model.RecursiveDeleteOperation{OperationID: "op1", ProjectID: "p1", DatabaseID: "d1"}}
+	app := newRecursiveDeleteTestApp(uc)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/projects/p1/databases/d1/operations/op1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	// Operations are scoped to the project and database they were started in
+	resp, err = app.Test(httptest.NewRequest("GET", "/projects/p2/databases/d1/operations/op1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest("POST", "/projects/p1/databases/d1/operations/op1:cancel", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "op1", uc.cancelled)
+}
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerRulesCoverageRoutes registers the security rules coverage report
+func (h *HTTPHandler) registerRulesCoverageRoutes(router fiber.Router) {
+	if h.RulesCoverageUC == nil {
+		return
+	}
+	router.Get("/securityRules\\:coverage", h.rulesAdmin(h.GetRulesCoverage)...)
+	router.Delete("/securityRules\\:coverage", h.rulesAdmin(h.ResetRulesCoverage)...)
+}
+
+// GetRulesCoverage returns the lines of the released rules source annotated with the
+// coverage recorded since the last reset
+func (h *HTTPHandler) GetRulesCoverage(c *fiber.Ctx) error {
+	report, err := h.RulesCoverageUC.GetCoverage(c.UserContext(), c.Params("projectID"), c.Params("databaseID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_rules_coverage_failed")
+	}
+	return c.JSON(report)
+}
+
+// ResetRulesCoverage forgets the coverage recorded for the database
+func (h *HTTPHandler) ResetRulesCoverage(c *fiber.Ctx) error {
+	if err := h.RulesCoverageUC.ResetCoverage(c.UserContext(), c.Params("projectID"), c.Params("databaseID")); err != nil {
+		return operationErrorResponse(c, err, "reset_rules_coverage_failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
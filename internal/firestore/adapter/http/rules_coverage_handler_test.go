@@ -0,0 +1,118 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtparser "firestore-clone/internal/rules_translator/adapter/parser"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deployedRulesSimulator simulates requests against the rules deployed in the engine
+type deployedRulesSimulator struct {
+	engine    *memoryRulesEngine
+	simulator *rules_cel.Simulator
+}
+
+func (s *deployedRulesSimulator) SimulateAccess(ctx context.Context, request *repository.SimulationRequest) (*repository.SimulationResult, error) {
+	if len(request.Rules) == 0 {
+		request.Rules = s.engine.rules
+	}
+	return s.simulator.SimulateAccess(ctx, request)
+}
+
+func TestRulesCoverageHandler(t *testing.T) {
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	parser := rtparser.NewModernParserInstance()
+	translator := rtusecase.NewFastTranslator(rtadapter.NewMemoryCache(nil), rtadapter.NewRulesOptimizer(nil), nil)
+	engine := &memoryRulesEngine{}
+	deployer := rtadapter.NewRulesDeployer(engine, rtadapter.NewSimpleValidator(), rtadapter.NewMemoryHistoryStore(), nil)
+	store := rules_cel.NewCoverageStore()
+	simulator := &deployedRulesSimulator{engine: engine, simulator: rules_cel.NewSimulator(env, nil)}
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "alice", Roles: []string{usecase.AdminRole}})
+
+	h := &HTTPHandler{
+		RulesSimulatorUC: usecase.NewSecurityRulesSimulatorUsecaseWithCoverage(simulator, parser, translator, store),
+		RulesCoverageUC:  usecase.NewSecurityRulesCoverageUsecase(store, deployer, parser, translator),
+		RulesAdminAuth:   []fiber.Handler{headerAdminAuth},
+		AuthClient:       authClient,
+		Log:              TestLogger{},
+	}
+	app := fiber.New()
+	h.registerRulesSimulatorRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	h.registerRulesCoverageRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+
+	getCoverage := func() (int, *rtdomain.RulesCoverageReport) {
+		req := httptest.NewRequest("GET", "/projects/p/databases/d/securityRules:coverage", nil)
+		req.Header.Set("X-User", "alice")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var report rtdomain.RulesCoverageReport
+		if resp.StatusCode == fiber.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		}
+		return resp.StatusCode, &report
+	}
+
+	status, _ := getCoverage()
+	assert.Equal(t, fiber.StatusNotFound, status, "No rules have been released")
+
+	releases := usecase.NewSecurityRulesReleaseUsecase(parser, translator, deployer)
+	release, err := releases.DeployRules(context.Background(), "p", "d", strings.Replace(releaseTestRules, "%s", "request.auth != null", 1), "alice")
+	require.NoError(t, err)
+
+	status, _ = simulateRules(t, app, `{"operation":"read","path":"users/alice","auth":{"uid":"alice"},"recordCoverage":true}`)
+	require.Equal(t, fiber.StatusOK, status)
+	status, _ = simulateRules(t, app, `{"operation":"read","path":"users/alice","recordCoverage":true}`)
+	require.Equal(t, fiber.StatusOK, status)
+	status, _ = simulateRules(t, app, `{"operation":"read","path":"users/alice","recordCoverage":true,"source":"service cloud.firestore {}"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "Coverage is only recorded for the deployed rules")
+
+	authClient.SetUser(&authModel.User{UserID: "alice"})
+	status, _ = simulateRules(t, app, `{"operation":"read","path":"users/alice","recordCoverage":true}`)
+	assert.Equal(t, fiber.StatusForbidden, status, "Only administrators record coverage")
+	authClient.SetUser(&authModel.User{UserID: "alice", Roles: []string{usecase.AdminRole}})
+
+	status, report := getCoverage()
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, release.Version, report.Version)
+	require.Len(t, report.Lines, 8)
+	assert.Equal(t, rtdomain.CoverageLineMatch, report.Lines[3].Kind)
+	assert.Equal(t, int64(2), report.Lines[3].Hits)
+	allow := report.Lines[4]
+	assert.Equal(t, rtdomain.CoverageLineAllow, allow.Kind)
+	assert.Equal(t, int64(1), allow.True)
+	assert.Equal(t, int64(1), allow.False)
+	assert.Equal(t, []string{"list"}, allow.Uncovered)
+	assert.Equal(t, 2, report.Conditions)
+	assert.Equal(t, 1, report.Covered)
+	assert.Equal(t, 50.0, report.Percent)
+
+	reset := httptest.NewRequest("DELETE", "/projects/p/databases/d/securityRules:coverage", nil)
+	reset.Header.Set("X-User", "alice")
+	resp, err := app.Test(reset)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	_, report = getCoverage()
+	assert.Equal(t, 0, report.Covered)
+	assert.Zero(t, report.Lines[3].Hits)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/projects/p/databases/d/securityRules:coverage", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, "Coverage is only shown to rules administrators")
+}
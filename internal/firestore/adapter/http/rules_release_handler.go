@@ -0,0 +1,207 @@
+package http
+
+import (
+	"strconv"
+
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerRulesReleaseRoutes registers the security rules ruleset and release endpoints
+func (h *HTTPHandler) registerRulesReleaseRoutes(router fiber.Router) {
+	if h.RulesReleaseUC == nil {
+		return
+	}
+	// Rules sources and their history are only visible to administrators
+	router.Get("/rulesets", h.rulesAdmin(h.ListRulesets)...)
+	router.Post("/rulesets", h.rulesAdmin(h.CreateRuleset)...)
+	router.Get("/rulesets\\:diff", h.rulesAdmin(h.DiffRulesets)...)
+	router.Get("/rulesets/:version", h.rulesAdmin(h.GetRuleset)...)
+	router.Get("/releases", h.rulesAdmin(h.ListRulesReleases)...)
+	router.Post("/releases", h.rulesAdmin(h.ReleaseRuleset)...)
+	router.Get("/releases/current", h.rulesAdmin(h.GetRulesRelease)...)
+	router.Post("/releases\\:rollback", h.rulesAdmin(h.RollbackRulesRelease)...)
+}
+
+// rulesAdmin puts a rules management handler behind the administrator authentication.
+// Without RulesAdminAuth the routes fail closed.
+func (h *HTTPHandler) rulesAdmin(handler fiber.Handler) []fiber.Handler {
+	return h.requireAdmin(handler, "Security rules management requires an authenticated administrator")
+}
+
+// adminOnly puts the handler of a database administration route (schemas, triggers, the
+// change feed...) behind the same administrator authentication as the rules management
+func (h *HTTPHandler) adminOnly(handler fiber.Handler) []fiber.Handler {
+	return h.requireAdmin(handler, "This endpoint requires an authenticated administrator")
+}
+
+// requireAdmin prepends RulesAdminAuth to the handler, or refuses every request without it
+func (h *HTTPHandler) requireAdmin(handler fiber.Handler, message string) []fiber.Handler {
+	if len(h.RulesAdminAuth) == 0 {
+		return []fiber.Handler{func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   "unauthenticated",
+				"message": message,
+			})
+		}}
+	}
+	handlers := make([]fiber.Handler, 0, len(h.RulesAdminAuth)+1)
+	handlers = append(handlers, h.RulesAdminAuth...)
+	return append(handlers, handler)
+}
+
+// CreateRuleset deploys a rules source as a new ruleset and releases it
+func (h *HTTPHandler) CreateRuleset(c *fiber.Ctx) error {
+	user, ok := rulesChangeUser(c)
+	if !ok {
+		return nil
+	}
+	var req struct {
+		Source string `json:"source"`
+		// AllowInsecure deploys the rules even with security lint errors
+		AllowInsecure bool `json:"allowInsecure"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+
+	ctx := c.UserContext()
+	if req.AllowInsecure {
+		h.Log.Warn("Deploying security rules regardless of security lint errors", "user", user,
+			"projectID", c.Params("projectID"), "databaseID", c.Params("databaseID"))
+		ctx = rtdomain.WithInsecureRulesAllowed(ctx)
+	}
+	result, err := h.RulesReleaseUC.DeployRules(ctx, c.Params("projectID"), c.Params("databaseID"), req.Source, user)
+	if err != nil {
+		h.Log.Error("Failed to deploy security rules", "error", err, "user", user)
+		return operationErrorResponse(c, err, "deploy_rules_failed")
+	}
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+// ListRulesets lists the rulesets of a database, oldest first
+func (h *HTTPHandler) ListRulesets(c *fiber.Ctx) error {
+	limit, ok := rulesHistoryLimit(c)
+	if !ok {
+		return nil
+	}
+	rulesets, err := h.RulesReleaseUC.ListRulesets(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), limit)
+	if err != nil {
+		return operationErrorResponse(c, err, "list_rulesets_failed")
+	}
+	return c.JSON(fiber.Map{"rulesets": rulesets})
+}
+
+// GetRuleset returns a ruleset with its source and translated rules
+func (h *HTTPHandler) GetRuleset(c *fiber.Ctx) error {
+	ruleset, err := h.RulesReleaseUC.GetRuleset(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("version"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_ruleset_failed")
+	}
+	return c.JSON(ruleset)
+}
+
+// DiffRulesets compares the translated rules of the rulesets given by the from and to query parameters
+func (h *HTTPHandler) DiffRulesets(c *fiber.Ctx) error {
+	diff, err := h.RulesReleaseUC.DiffRulesets(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		return operationErrorResponse(c, err, "diff_rulesets_failed")
+	}
+	return c.JSON(diff)
+}
+
+// ReleaseRuleset makes a stored ruleset the active one
+func (h *HTTPHandler) ReleaseRuleset(c *fiber.Ctx) error {
+	user, ok := rulesChangeUser(c)
+	if !ok {
+		return nil
+	}
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+
+	release, err := h.RulesReleaseUC.ReleaseRuleset(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), req.Version, user)
+	if err != nil {
+		h.Log.Error("Failed to release ruleset", "error", err, "version", req.Version, "user", user)
+		return operationErrorResponse(c, err, "release_ruleset_failed")
+	}
+	return c.JSON(release)
+}
+
+// RollbackRulesRelease releases again the ruleset that was active before the current release
+func (h *HTTPHandler) RollbackRulesRelease(c *fiber.Ctx) error {
+	user, ok := rulesChangeUser(c)
+	if !ok {
+		return nil
+	}
+	release, err := h.RulesReleaseUC.Rollback(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), user)
+	if err != nil {
+		h.Log.Error("Failed to roll back security rules", "error", err, "user", user)
+		return operationErrorResponse(c, err, "rollback_rules_failed")
+	}
+	return c.JSON(release)
+}
+
+// GetRulesRelease returns the active release
+func (h *HTTPHandler) GetRulesRelease(c *fiber.Ctx) error {
+	release, err := h.RulesReleaseUC.GetRelease(c.UserContext(), c.Params("projectID"), c.Params("databaseID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_rules_release_failed")
+	}
+	return c.JSON(release)
+}
+
+// ListRulesReleases lists the releases of a database, oldest first, with who made each one
+func (h *HTTPHandler) ListRulesReleases(c *fiber.Ctx) error {
+	limit, ok := rulesHistoryLimit(c)
+	if !ok {
+		return nil
+	}
+	releases, err := h.RulesReleaseUC.ListReleases(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), limit)
+	if err != nil {
+		return operationErrorResponse(c, err, "list_rules_releases_failed")
+	}
+	return c.JSON(fiber.Map{"releases": releases})
+}
+
+// rulesChangeUser returns the authenticated user a rules change is attributed to. Rules
+// cannot be changed anonymously; without a user it writes the 401 response.
+func rulesChangeUser(c *fiber.Ctx) (string, bool) {
+	userID, err := utils.GetUserIDFromContext(c.UserContext())
+	if err != nil || userID == "" {
+		_ = c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error":   "unauthenticated",
+			"message": "Security rules changes require an authenticated user",
+		})
+		return "", false
+	}
+	return userID, true
+}
+
+// rulesHistoryLimit parses the optional limit query parameter; zero means the deployer default
+func rulesHistoryLimit(c *fiber.Ctx) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		_ = c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_limit",
+			"message": "limit must be a positive integer",
+		})
+		return 0, false
+	}
+	return limit, true
+}
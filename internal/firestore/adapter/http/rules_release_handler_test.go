@@ -0,0 +1,252 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authhttp "firestore-clone/internal/auth/adapter/http"
+	authrepo "firestore-clone/internal/auth/domain/repository"
+	authusecase "firestore-clone/internal/auth/usecase"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtparser "firestore-clone/internal/rules_translator/adapter/parser"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+	"firestore-clone/internal/shared/contextkeys"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const releaseTestRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /users/{userId} {
+      allow read: if %s;
+    }
+  }
+}`
+
+// memoryRulesEngine keeps the deployed rules in memory
+type memoryRulesEngine struct {
+	repository.SecurityRulesEngine
+	rules []*repository.SecurityRule
+}
+
+func (e *memoryRulesEngine) LoadRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
+	return e.rules, nil
+}
+
+func (e *memoryRulesEngine) SaveRules(ctx context.Context, projectID, databaseID string, rules []*repository.SecurityRule) error {
+	e.rules = rules
+	return nil
+}
+
+// headerAdminAuth authenticates every user in the X-User header as a rules administrator
+func headerAdminAuth(c *fiber.Ctx) error {
+	user := strings.Clone(c.Get("X-User"))
+	if user == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization token required"})
+	}
+	c.SetUserContext(context.WithValue(c.UserContext(), contextkeys.UserIDKey, user))
+	return c.Next()
+}
+
+func newRulesReleaseUsecase() usecase.SecurityRulesReleaseUsecase {
+	translator := rtusecase.NewFastTranslator(rtadapter.NewMemoryCache(nil), rtadapter.NewRulesOptimizer(nil), nil)
+	deployer := rtadapter.NewRulesDeployer(&memoryRulesEngine{}, rtadapter.NewSimpleValidator(), rtadapter.NewMemoryHistoryStore(), nil)
+	return usecase.NewSecurityRulesReleaseUsecase(rtparser.NewModernParserInstance(), translator, deployer)
+}
+
+// newRulesReleaseTestApp authenticates requests as the administrator in the X-User header
+func newRulesReleaseTestApp() *fiber.App {
+	h := &HTTPHandler{
+		RulesReleaseUC: newRulesReleaseUsecase(),
+		RulesAdminAuth: []fiber.Handler{headerAdminAuth},
+		Log:            TestLogger{},
+	}
+
+	app := fiber.New()
+	h.registerRulesReleaseRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	return app
+}
+
+func rulesReleaseRequest(t *testing.T, app *fiber.App, method, path, user string, body interface{}, out interface{}) int {
+	req := httptest.NewRequest(method, "/projects/p/databases/d"+path, nil)
+	if body != nil {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		req = httptest.NewRequest(method, "/projects/p/databases/d"+path, strings.NewReader(string(payload)))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestRulesReleaseHandler_DeployDiffAndRollback(t *testing.T) {
+	app := newRulesReleaseTestApp()
+
+	var first, second rtdomain.DeployResult
+	require.Equal(t, fiber.StatusCreated, rulesReleaseRequest(t, app, "POST", "/rulesets", "alice",
+		fiber.Map{"source": strings.Replace(releaseTestRules, "%s", "true", 1)}, &first))
+	require.Equal(t, fiber.StatusCreated, rulesReleaseRequest(t, app, "POST", "/rulesets", "bob",
+		fiber.Map{"source": strings.Replace(releaseTestRules, "%s", "request.auth != null", 1)}, &second))
+
+	var list struct {
+		Rulesets []*rtdomain.DeployHistory `json:"rulesets"`
+	}
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "GET", "/rulesets", "alice", nil, &list))
+	require.Len(t, list.Rulesets, 2)
+	assert.Equal(t, "bob", list.Rulesets[1].DeployedBy)
+	assert.Empty(t, list.Rulesets[1].Source, "Listing returns metadata only")
+
+	var ruleset rtdomain.DeployHistory
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "GET", "/rulesets/"+first.Version, "alice", nil, &ruleset))
+	assert.Contains(t, ruleset.Source, "allow read: if true")
+
+	var diff rtdomain.RulesDiff
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "GET", "/rulesets:diff?from="+first.Version+"&to="+second.Version, "alice", nil, &diff))
+	require.NotEmpty(t, diff.Changed)
+	for _, change := range diff.Changed {
+		assert.Equal(t, "true", change.Before)
+		assert.Equal(t, "request.auth != null", change.After)
+	}
+
+	var release rtdomain.RulesRelease
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "POST", "/releases:rollback", "carol", nil, &release))
+	assert.Equal(t, first.Version, release.Version)
+	assert.Equal(t, second.Version, release.RollbackOf)
+	assert.Equal(t, "carol", release.ReleasedBy)
+
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "POST", "/releases", "dave", fiber.Map{"version": second.Version}, &release))
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "GET", "/releases/current", "alice", nil, &release))
+	assert.Equal(t, second.Version, release.Version)
+	assert.Equal(t, "dave", release.ReleasedBy)
+
+	var releases struct {
+		Releases []*rtdomain.RulesRelease `json:"releases"`
+	}
+	require.Equal(t, fiber.StatusOK, rulesReleaseRequest(t, app, "GET", "/releases?limit=2", "alice", nil, &releases))
+	require.Len(t, releases.Releases, 2)
+	assert.Equal(t, "carol", releases.Releases[0].ReleasedBy)
+}
+
+func TestRulesReleaseHandler_InvalidRequests(t *testing.T) {
+	app := newRulesReleaseTestApp()
+
+	assert.Equal(t, fiber.StatusUnauthorized, rulesReleaseRequest(t, app, "POST", "/rulesets", "", fiber.Map{"source": releaseTestRules}, nil))
+	assert.Equal(t, fiber.StatusUnauthorized, rulesReleaseRequest(t, app, "POST", "/releases:rollback", "", nil, nil))
+	assert.Equal(t, fiber.StatusBadRequest, rulesReleaseRequest(t, app, "POST", "/rulesets", "alice", fiber.Map{"source": "service cloud.firestore {"}, nil))
+	assert.Equal(t, fiber.StatusNotFound, rulesReleaseRequest(t, app, "GET", "/rulesets/missing", "alice", nil, nil))
+	assert.Equal(t, fiber.StatusNotFound, rulesReleaseRequest(t, app, "GET", "/releases/current", "alice", nil, nil))
+	assert.Equal(t, fiber.StatusNotFound, rulesReleaseRequest(t, app, "POST", "/releases", "alice", fiber.Map{"version": "missing"}, nil))
+	assert.Equal(t, fiber.StatusNotFound, rulesReleaseRequest(t, app, "POST", "/releases:rollback", "alice", nil, nil))
+	assert.Equal(t, fiber.StatusBadRequest, rulesReleaseRequest(t, app, "GET", "/releases?limit=0", "alice", nil, nil))
+}
+
+// tokenAuthUsecase validates the bearer tokens it was given claims for
+type tokenAuthUsecase struct {
+	authusecase.AuthUsecaseInterface
+	claims map[string]*authrepo.Claims
+}
+
+func (u *tokenAuthUsecase) ValidateToken(ctx context.Context, token string) (*authrepo.Claims, error) {
+	if claims, ok := u.claims[token]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// newRulesAdminAuth returns the rules administrator authentication wired by the module
+func newRulesAdminAuth(claims map[string]*authrepo.Claims) []fiber.Handler {
+	middleware := authhttp.NewAuthMiddleware(&tokenAuthUsecase{claims: claims}, "token")
+	return []fiber.Handler{middleware.RequireAuth(), middleware.RequireRole(usecase.AdminRole)}
+}
+
+func TestRulesReleaseHandler_InsecureRulesNeedExplicitOverride(t *testing.T) {
+	app := newRulesReleaseTestApp()
+	source := strings.Replace(releaseTestRules, "allow read: if %s;", "allow write: if true;", 1)
+
+	var failure map[string]interface{}
+	require.Equal(t, fiber.StatusBadRequest, rulesReleaseRequest(t, app, "POST", "/rulesets", "alice", fiber.Map{"source": source}, &failure))
+	assert.Contains(t, failure["message"], "[public-write]")
+
+	var result rtdomain.DeployResult
+	require.Equal(t, fiber.StatusCreated, rulesReleaseRequest(t, app, "POST", "/rulesets", "alice",
+		fiber.Map{"source": source, "allowInsecure": true}, &result))
+	assert.True(t, result.Success)
+	require.NotEmpty(t, result.Warnings)
+	assert.Contains(t, strings.Join(result.Warnings, "\n"), "insecure rules allowed: ")
+}
+
+func TestRulesReleaseRoutes_RequireAdministrator(t *testing.T) {
+	h := &HTTPHandler{
+		RulesReleaseUC: newRulesReleaseUsecase(),
+		RulesAdminAuth: newRulesAdminAuth(map[string]*authrepo.Claims{
+			"admin-token":  {UserID: "alice", ProjectID: "p", DatabaseID: "d", Roles: []string{usecase.AdminRole}},
+			"member-token": {UserID: "bob", ProjectID: "p", DatabaseID: "d", Roles: []string{"user"}},
+		}),
+		Log: TestLogger{},
+	}
+	app := fiber.New()
+	h.RegisterRoutes(app)
+
+	request := func(method, path, token string, body interface{}) *http.Response {
+		req := httptest.NewRequest(method, "/organizations/org1/projects/p/databases/d"+path, nil)
+		if body != nil {
+			payload, err := json.Marshal(body)
+			require.NoError(t, err)
+			req = httptest.NewRequest(method, "/organizations/org1/projects/p/databases/d"+path, strings.NewReader(string(payload)))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+	source := fiber.Map{"source": strings.Replace(releaseTestRules, "%s", "true", 1)}
+
+	// Anonymous callers and members can neither read nor change the rules
+	assert.Equal(t, fiber.StatusUnauthorized, request("POST", "/rulesets", "", source).StatusCode)
+	assert.Equal(t, fiber.StatusUnauthorized, request("GET", "/rulesets", "", nil).StatusCode)
+	assert.Equal(t, fiber.StatusForbidden, request("POST", "/rulesets", "member-token", source).StatusCode)
+	assert.Equal(t, fiber.StatusForbidden, request("GET", "/releases/current", "member-token", nil).StatusCode)
+
+	// An administrator deploys and the release is attributed to them
+	var result rtdomain.DeployResult
+	resp := request("POST", "/rulesets", "admin-token", source)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	var ruleset rtdomain.DeployHistory
+	resp = request("GET", "/rulesets/"+result.Version, "admin-token", nil)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&ruleset))
+	assert.Equal(t, "alice", ruleset.DeployedBy)
+	assert.Equal(t, fiber.StatusForbidden, request("GET", "/rulesets/"+result.Version, "member-token", nil).StatusCode)
+}
+
+func TestRulesReleaseRoutes_FailClosedWithoutAdminAuth(t *testing.T) {
+	h := &HTTPHandler{RulesReleaseUC: newRulesReleaseUsecase(), Log: TestLogger{}}
+	app := fiber.New()
+	h.RegisterRoutes(app)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/organizations/org1/projects/p/databases/d/releases/current", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerRulesSimulatorRoutes registers the security rules simulator
+func (h *HTTPHandler) registerRulesSimulatorRoutes(router fiber.Router) {
+	if h.RulesSimulatorUC == nil {
+		return
+	}
+	router.Post("/securityRules\\:simulate", h.rulesAdmin(h.SimulateRules)...)
+}
+
+// SimulateRules evaluates a simulated request against the deployed or candidate rules
+// and returns the decision with the trace of the evaluation
+func (h *HTTPHandler) SimulateRules(c *fiber.Ctx) error {
+	var req usecase.SimulateRulesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+	req.ProjectID = c.Params("projectID")
+	req.DatabaseID = c.Params("databaseID")
+	req.User = h.resolveUser(c)
+
+	result, err := h.RulesSimulatorUC.Simulate(c.UserContext(), req)
+	if err != nil {
+		return operationErrorResponse(c, err, "simulate_rules_failed")
+	}
+	return c.JSON(result)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtparser "firestore-clone/internal/rules_translator/adapter/parser"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRulesSimulatorTestApp serves the simulator to alice, with the given roles, over a
+// database storing users/alice
+func newRulesSimulatorTestApp(t *testing.T, roles ...string) *fiber.App {
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	translator := rtusecase.NewFastTranslator(rtadapter.NewMemoryCache(nil), rtadapter.NewRulesOptimizer(nil), nil)
+	accessor := rtadapter.NewMemoryResourceAccessor(map[string]map[string]interface{}{
+		"users/alice": {"owner": "alice", "secret": "s3cr3t"},
+	})
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "alice", Roles: roles})
+	h := &HTTPHandler{
+		RulesSimulatorUC: usecase.NewSecurityRulesSimulatorUsecase(rules_cel.NewSimulator(env, accessor), rtparser.NewModernParserInstance(), translator),
+		RulesAdminAuth:   []fiber.Handler{headerAdminAuth},
+		AuthClient:       authClient,
+		Log:              TestLogger{},
+	}
+	app := fiber.New()
+	h.registerRulesSimulatorRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	return app
+}
+
+func simulateRules(t *testing.T, app *fiber.App, body string) (int, *repository.SimulationResult) {
+	req := httptest.NewRequest("POST", "/projects/p/databases/d/securityRules:simulate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "alice")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	var result repository.SimulationResult
+	if resp.StatusCode == fiber.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	}
+	return resp.StatusCode, &result
+}
+
+func TestRulesSimulatorHandler_CandidateRules(t *testing.T) {
+	app := newRulesSimulatorTestApp(t, usecase.AdminRole)
+	source, err := json.Marshal(strings.Replace(releaseTestRules, "%s", "request.auth.uid == userId", 1))
+	require.NoError(t, err)
+
+	status, result := simulateRules(t, app, `{"operation":"read","path":"users/alice","auth":{"uid":"alice"},"source":`+string(source)+`}`)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.True(t, result.Allowed)
+	require.Len(t, result.Matches, 1)
+	assert.Equal(t, "alice", result.Matches[0].Variables["userId"])
+	assert.NotEmpty(t, result.Matches[0].Conditions[0].Expressions)
+
+	status, result = simulateRules(t, app, `{"operation":"read","path":"users/alice","source":`+string(source)+`}`)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.False(t, result.Allowed)
+	assert.NotEmpty(t, result.Matches[0].Conditions[0].Error, "request.auth is null for unauthenticated requests")
+}
+
+func TestRulesSimulatorHandler_InvalidRequests(t *testing.T) {
+	app := newRulesSimulatorTestApp(t, usecase.AdminRole)
+
+	status, _ := simulateRules(t, app, `{"operation":"read"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = simulateRules(t, app, `{"operation":"fly","path":"users/alice"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = simulateRules(t, app, `{"operation":"read","path":"users/alice","source":"service cloud.firestore {"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+func TestRulesSimulatorHandler_StoredDocument(t *testing.T) {
+	source, err := json.Marshal(strings.Replace(releaseTestRules, "%s", "resource.data.secret == 'guess'", 1))
+	require.NoError(t, err)
+	body := `{"operation":"read","path":"users/alice","auth":{"uid":"alice"},"useExistingDocument":true,"source":` + string(source) + `}`
+
+	// Candidate rules could read any stored value, only administrators may run them
+	// against the stored document
+	status, _ := simulateRules(t, newRulesSimulatorTestApp(t), body)
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, result := simulateRules(t, newRulesSimulatorTestApp(t, usecase.AdminRole), body)
+	require.Equal(t, fiber.StatusOK, status)
+	assert.False(t, result.Allowed)
+	require.Len(t, result.Matches, 1)
+	redacted := false
+	for _, expression := range result.Matches[0].Conditions[0].Expressions {
+		assert.NotEqual(t, "s3cr3t", expression.Value, expression.Expression)
+		redacted = redacted || expression.Redacted
+	}
+	assert.True(t, redacted, "The values read from the stored document are redacted")
+
+	// Mocked documents are the caller's own data and stay in the trace
+	status, result = simulateRules(t, newRulesSimulatorTestApp(t), `{"operation":"read","path":"users/alice","resource":{"secret":"mine"},"source":`+string(source)+`}`)
+	require.Equal(t, fiber.StatusOK, status)
+	values := make([]interface{}, 0)
+	for _, expression := range result.Matches[0].Conditions[0].Expressions {
+		values = append(values, expression.Value)
+	}
+	assert.Contains(t, values, "mine")
+}
+
+func TestRulesSimulatorHandler_RequiresRulesAdministrator(t *testing.T) {
+	h := &HTTPHandler{RulesSimulatorUC: usecase.NewSecurityRulesSimulatorUsecase(nil, nil, nil), Log: TestLogger{}}
+	app := fiber.New()
+	h.registerRulesSimulatorRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	status, _ := simulateRules(t, app, `{"operation":"read","path":"users/alice"}`)
+	assert.Equal(t, fiber.StatusUnauthorized, status, "The simulator fails closed without the administrator authentication")
+
+	app = newRulesSimulatorTestApp(t)
+	req := httptest.NewRequest("POST", "/projects/p/databases/d/securityRules:simulate", strings.NewReader(`{"operation":"read","path":"users/alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerRulesTestRoutes registers the security rules test runner
+func (h *HTTPHandler) registerRulesTestRoutes(router fiber.Router) {
+	if h.RulesTestUC == nil {
+		return
+	}
+	router.Post("/securityRules\\:test", h.rulesAdmin(h.TestRules)...)
+}
+
+// TestRules runs a rules test suite in memory and returns its report, as JSON or as
+// JUnit XML with ?format=junit
+func (h *HTTPHandler) TestRules(c *fiber.Ctx) error {
+	var req usecase.RunRulesTestsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "junit" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_format",
+			"message": "format must be json or junit",
+		})
+	}
+
+	report, err := h.RulesTestUC.RunTests(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), req)
+	if err != nil {
+		return operationErrorResponse(c, err, "test_rules_failed")
+	}
+
+	if format == "junit" {
+		output, err := rtadapter.FormatJUnitReport(report)
+		if err != nil {
+			return operationErrorResponse(c, err, "test_rules_failed")
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Send(output)
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtparser "firestore-clone/internal/rules_translator/adapter/parser"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRulesTestTestApp(t *testing.T) (*fiber.App, rtdomain.RulesTranslator, rtdomain.RulesDeployer) {
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	translator := rtusecase.NewFastTranslator(rtadapter.NewMemoryCache(nil), rtadapter.NewRulesOptimizer(nil), nil)
+	deployer := rtadapter.NewRulesDeployer(&memoryRulesEngine{}, rtadapter.NewSimpleValidator(), rtadapter.NewMemoryHistoryStore(), nil)
+	runner := rtusecase.NewRulesTestRunner(rtparser.NewModernParserInstance(), translator,
+		rules_cel.NewSimulator(env, rtadapter.NewMemoryResourceAccessor(nil)))
+
+	h := &HTTPHandler{
+		RulesTestUC:    usecase.NewSecurityRulesTestUsecase(runner, deployer),
+		RulesAdminAuth: []fiber.Handler{headerAdminAuth},
+		Log:            TestLogger{},
+	}
+	app := fiber.New()
+	h.registerRulesTestRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	return app, translator, deployer
+}
+
+func testRulesRequest(t *testing.T, app *fiber.App, query string, body fiber.Map) (int, []byte) {
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/projects/p/databases/d/securityRules:test"+query, strings.NewReader(string(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "alice")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	output, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, output
+}
+
+func TestRulesTestHandler(t *testing.T) {
+	app, translator, deployer := newRulesTestTestApp(t)
+	tests := []fiber.Map{
+		{"name": "owner", "auth": fiber.Map{"uid": "alice"}, "operation": "read", "path": "users/alice", "expect": "allow"},
+		{"name": "anonymous", "operation": "read", "path": "users/alice", "expect": "allow"},
+	}
+	source := strings.Replace(releaseTestRules, "%s", "request.auth.uid == userId", 1)
+
+	status, output := testRulesRequest(t, app, "", fiber.Map{"source": source, "tests": tests})
+	require.Equal(t, fiber.StatusOK, status, string(output))
+	var report rtdomain.RulesTestReport
+	require.NoError(t, json.Unmarshal(output, &report))
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, "deny", report.Results[1].Actual)
+
+	status, output = testRulesRequest(t, app, "?format=junit", fiber.Map{"source": source, "tests": tests})
+	require.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, string(output), `<failure message="expected allow, got deny">`)
+
+	// Without a source the suite tests the released ruleset
+	status, _ = testRulesRequest(t, app, "", fiber.Map{"tests": tests})
+	assert.Equal(t, fiber.StatusBadRequest, status, "No rules have been released")
+	releases := usecase.NewSecurityRulesReleaseUsecase(rtparser.NewModernParserInstance(), translator, deployer)
+	_, err := releases.DeployRules(context.Background(), "p", "d", strings.Replace(releaseTestRules, "%s", "true", 1), "alice")
+	require.NoError(t, err)
+	status, output = testRulesRequest(t, app, "", fiber.Map{"tests": tests})
+	require.Equal(t, fiber.StatusOK, status)
+	require.NoError(t, json.Unmarshal(output, &report))
+	assert.Equal(t, 2, report.Passed)
+
+	status, _ = testRulesRequest(t, app, "", fiber.Map{"source": source})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
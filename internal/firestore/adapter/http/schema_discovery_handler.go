@@ -0,0 +1,51 @@
+package http
+
+import (
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerSchemaDiscoveryRoutes registers the collection schema discovery endpoint
+// Must be registered before collection routes so the :schema suffix is not taken as part of the ID
+func (h *HTTPHandler) registerSchemaDiscoveryRoutes(router fiber.Router) {
+	if h.SchemaDiscoveryUC == nil {
+		return
+	}
+	// Reports read documents without security rules and include example field values,
+	// so only administrators profile collections
+	router.Get("/collections/:collectionID\\:schema", h.adminOnly(h.DiscoverCollectionSchema)...)
+}
+
+// DiscoverCollectionSchema profiles the documents of a collection.
+//
+// Query parameters: sampleSize (documents to profile), full=true (scan every document),
+// examples (example values per field) and format=jsonSchema to get a starting collection
+// schema that can be posted to /schemas instead of the report.
+func (h *HTTPHandler) DiscoverCollectionSchema(c *fiber.Ctx) error {
+	req := usecase.DiscoverSchemaRequest{
+		ProjectID:    c.Params("projectID"),
+		DatabaseID:   c.Params("databaseID"),
+		CollectionID: c.Params("collectionID"),
+		SampleSize:   c.QueryInt("sampleSize", 0),
+		FullScan:     c.QueryBool("full", false),
+		MaxExamples:  c.QueryInt("examples", 0),
+	}
+
+	report, err := h.SchemaDiscoveryUC.DiscoverSchema(c.UserContext(), req)
+	if err != nil {
+		h.Log.Error("Failed to discover collection schema", "error", err, "collection", req.CollectionID)
+		return operationErrorResponse(c, err, "discover_schema_failed")
+	}
+
+	if c.Query("format") == "jsonSchema" {
+		return c.JSON(usecase.SetCollectionSchemaRequest{
+			CollectionPattern: req.CollectionID,
+			Mode:              model.SchemaModeWarn,
+			Schema:            h.SchemaDiscoveryUC.ExportJSONSchema(report),
+			Description:       "Discovered from the collection documents",
+		})
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSchemaDiscoveryUC struct {
+	lastRequest usecase.DiscoverSchemaRequest
+}
+
+func (m *mockSchemaDiscoveryUC) DiscoverSchema(ctx context.Context, req usecase.DiscoverSchemaRequest) (*model.CollectionSchemaReport, error) {
+	m.lastRequest = req
+	return &model.CollectionSchemaReport{CollectionID: req.CollectionID, DocumentsScanned: 1}, nil
+}
+
+func (m *mockSchemaDiscoveryUC) ExportJSONSchema(report *model.CollectionSchemaReport) *model.FieldSchema {
+	return &model.FieldSchema{Type: model.SchemaTypes{model.SchemaTypeObject}}
+}
+
+func TestSchemaDiscoveryHandler(t *testing.T) {
+	uc := &mockSchemaDiscoveryUC{}
+	app := fiber.New()
+	h := &HTTPHandler{
+		FirestoreUC:       &MockFirestoreUC{},
+		SchemaDiscoveryUC: uc,
+		RulesAdminAuth:    []fiber.Handler{headerAdminAuth},
+		Log:               TestLogger{},
+	}
+	group := app.Group("/projects/:projectID/databases/:databaseID")
+	h.registerSchemaDiscoveryRoutes(group)
+	h.registerCollectionRoutes(group)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/projects/p1/databases/d1/collections/users:schema", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, uc.lastRequest.CollectionID)
+
+	req := httptest.NewRequest("GET", "/projects/p1/databases/d1/collections/users:schema?sampleSize=50&examples=5", nil)
+	req.Header.Set("X-User", "admin")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "users", uc.lastRequest.CollectionID)
+	assert.Equal(t, 50, uc.lastRequest.SampleSize)
+	assert.Equal(t, 5, uc.lastRequest.MaxExamples)
+
+	req = httptest.NewRequest("GET", "/projects/p1/databases/d1/collections/users:schema?full=true&format=jsonSchema", nil)
+	req.Header.Set("X-User", "admin")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.True(t, uc.lastRequest.FullScan)
+
+	var exported map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exported))
+	assert.Equal(t, "users", exported["collectionPattern"])
+	assert.Equal(t, map[string]interface{}{"type": "object"}, exported["schema"])
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// searchRequestBody is the body of a full-text search
+type searchRequestBody struct {
+	Query    string           `json:"query"`
+	Where    *FirestoreFilter `json:"where,omitempty"` // Optional Firestore-style filter applied to the hits
+	Limit    int              `json:"limit,omitempty"`
+	MatchAny bool             `json:"matchAny,omitempty"`
+}
+
+// registerSearchRoutes registers the full-text search endpoints
+// Must be registered before collection routes so the :search suffix is not taken as part of the ID
+// Searches are checked against the list rules like queries; configuration is for administrators
+func (h *HTTPHandler) registerSearchRoutes(router fiber.Router) {
+	if h.SearchUC == nil {
+		return
+	}
+	router.Post("/collections/:collectionID\\:search", h.SearchDocuments)
+	router.Get("/collections/:collectionID/searchConfig", h.adminOnly(h.GetSearchConfig)...)
+	router.Put("/collections/:collectionID/searchConfig", h.adminOnly(h.SetSearchConfig)...)
+	router.Delete("/collections/:collectionID/searchConfig", h.adminOnly(h.DeleteSearchConfig)...)
+}
+
+// SetSearchConfig declares the searchable fields of a collection
+func (h *HTTPHandler) SetSearchConfig(c *fiber.Ctx) error {
+	var body struct {
+		Fields []model.SearchField `json:"fields"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body: " + err.Error(),
+		})
+	}
+
+	config, err := h.SearchUC.SetSearchConfig(c.UserContext(), usecase.SetSearchConfigRequest{
+		ProjectID:    c.Params("projectID"),
+		DatabaseID:   c.Params("databaseID"),
+		CollectionID: c.Params("collectionID"),
+		Fields:       body.Fields,
+	})
+	if err != nil {
+		h.Log.Error("Failed to set search config", "error", err, "collection", c.Params("collectionID"))
+		return operationErrorResponse(c, err, "set_search_config_failed")
+	}
+	return c.JSON(config)
+}
+
+// GetSearchConfig returns the searchable fields of a collection
+func (h *HTTPHandler) GetSearchConfig(c *fiber.Ctx) error {
+	config, err := h.SearchUC.GetSearchConfig(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("collectionID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_search_config_failed")
+	}
+	return c.JSON(config)
+}
+
+// DeleteSearchConfig disables full-text search on a collection and drops its index
+func (h *HTTPHandler) DeleteSearchConfig(c *fiber.Ctx) error {
+	if err := h.SearchUC.DeleteSearchConfig(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("collectionID")); err != nil {
+		return operationErrorResponse(c, err, "delete_search_config_failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SearchDocuments runs a full-text search over the searchable fields of a collection
+func (h *HTTPHandler) SearchDocuments(c *fiber.Ctx) error {
+	var body searchRequestBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body: " + err.Error(),
+		})
+	}
+
+	req := usecase.SearchRequest{
+		ProjectID:    c.Params("projectID"),
+		DatabaseID:   c.Params("databaseID"),
+		CollectionID: c.Params("collectionID"),
+		Query:        body.Query,
+		Limit:        body.Limit,
+		MatchAny:     body.MatchAny,
+	}
+	if body.Where != nil {
+		filters, err := convertFirestoreFilter(*body.Where)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_filter",
+				"message": err.Error(),
+			})
+		}
+		req.Filters = filters
+	}
+
+	// Every hit matches the filters, so the list rules are checked on the equivalent query
+	parent := "projects/" + req.ProjectID + "/databases/" + req.DatabaseID + "/documents"
+	query := &model.Query{Path: parent, CollectionID: req.CollectionID, Filters: req.Filters, Limit: req.Limit}
+	if err := h.authorizeQuery(c, parent+"/"+req.CollectionID, query); err != nil {
+		return operationErrorResponse(c, err, "permission_denied")
+	}
+
+	result, err := h.SearchUC.Search(c.UserContext(), req)
+	if err != nil {
+		h.Log.Error("Failed to search documents", "error", err, "collection", req.CollectionID)
+		return operationErrorResponse(c, err, "search_failed")
+	}
+	return c.JSON(result)
+}
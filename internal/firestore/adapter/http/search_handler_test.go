@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchUCStub records the last search request
+type searchUCStub struct {
+	usecase.SearchUsecase
+	lastRequest usecase.SearchRequest
+}
+
+func (s *searchUCStub) Search(ctx context.Context, req usecase.SearchRequest) (*model.SearchResult, error) {
+	s.lastRequest = req
+	return &model.SearchResult{
+		Query:     req.Query,
+		Hits:      []*model.SearchHit{{Document: &model.Document{DocumentID: "p1"}, Score: 1.5}},
+		TotalHits: 1,
+	}, nil
+}
+
+func (s *searchUCStub) SetSearchConfig(ctx context.Context, req usecase.SetSearchConfigRequest) (*model.SearchIndexConfig, error) {
+	return &model.SearchIndexConfig{ProjectID: req.ProjectID, DatabaseID: req.DatabaseID, CollectionID: req.CollectionID, Fields: req.Fields}, nil
+}
+
+func newSearchTestApp(searchUC usecase.SearchUsecase) *fiber.App {
+	app := fiber.New()
+	h := &HTTPHandler{FirestoreUC: &MockFirestoreUC{}, SearchUC: searchUC, Log: TestLogger{}, RulesAdminAuth: []fiber.Handler{headerAdminAuth}}
+	group := app.Group("/projects/:projectID/databases/:databaseID")
+	h.registerSearchRoutes(group)
+	h.registerCollectionRoutes(group)
+	return app
+}
+
+func TestSearchHandler_Search(t *testing.T) {
+	stub := &searchUCStub{}
+	app := newSearchTestApp(stub)
+
+	body := `{"query":"socks","limit":5,"where":{"fieldFilter":{"field":{"fieldPath":"price"},"op":"LESS_THAN","value":{"integerValue":"10"}}}}`
+	req := httptest.NewRequest("POST", "/projects/p/databases/d/collections/products:search", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var result model.SearchResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Hits, 1)
+	assert.Equal(t, "p1", result.Hits[0].Document.DocumentID)
+
+	assert.Equal(t, "products", stub.lastRequest.CollectionID)
+	assert.Equal(t, 5, stub.lastRequest.Limit)
+	require.Len(t, stub.lastRequest.Filters, 1)
+	assert.Equal(t, model.OperatorLessThan, stub.lastRequest.Filters[0].Operator)
+}
+
+func TestSearchHandler_InvalidFilterAndConfig(t *testing.T) {
+	app := newSearchTestApp(&searchUCStub{})
+
+	req := httptest.NewRequest("POST", "/projects/p/databases/d/collections/products:search", strings.NewReader(`{"query":"x","where":{}}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	req = httptest.NewRequest("PUT", "/projects/p/databases/d/collections/products/searchConfig", strings.NewReader(`{"fields":[{"fieldPath":"name","weight":2}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", "admin")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var config model.SearchIndexConfig
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&config))
+	assert.Equal(t, "products", config.CollectionID)
+	assert.Equal(t, 2.0, config.Fields[0].Weight)
+}
+
+func TestSearchHandler_ConfigRequiresAdministrator(t *testing.T) {
+	app := newSearchTestApp(&searchUCStub{})
+
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		req := httptest.NewRequest(method, "/projects/p/databases/d/collections/products/searchConfig", strings.NewReader(`{"fields":[{"fieldPath":"name"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, method)
+	}
+
+	// Without admin authentication configured the routes fail closed
+	h := &HTTPHandler{FirestoreUC: &MockFirestoreUC{}, SearchUC: &searchUCStub{}, Log: TestLogger{}}
+	closed := fiber.New()
+	h.registerSearchRoutes(closed.Group("/projects/:projectID/databases/:databaseID"))
+	req := httptest.NewRequest("GET", "/projects/p/databases/d/collections/products/searchConfig", nil)
+	req.Header.Set("X-User", "admin")
+	resp, err := closed.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSearchHandler_ChecksListRules(t *testing.T) {
+	stub := &searchUCStub{}
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "alice"})
+	securityUC := &querySecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	h := &HTTPHandler{FirestoreUC: &MockFirestoreUC{}, SearchUC: stub, SecurityUC: securityUC, AuthClient: authClient, Log: TestLogger{}}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), "alice"))
+		return c.Next()
+	})
+	h.registerSearchRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+
+	search := func(body string) int {
+		req := httptest.NewRequest("POST", "/projects/p/databases/d/collections/notes:search", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// Rules are not filters: the search is rejected before any hit is loaded
+	assert.Equal(t, fiber.StatusForbidden, search(`{"query":"groceries"}`))
+	assert.Empty(t, stub.lastRequest.Query)
+	assert.Equal(t, "projects/p/databases/d/documents/notes", securityUC.collectionPath)
+
+	assert.Equal(t, fiber.StatusOK, search(`{"query":"groceries","where":{"fieldFilter":{"field":{"fieldPath":"owner"},"op":"EQUAL","value":{"stringValue":"alice"}}}}`))
+	assert.Equal(t, "groceries", stub.lastRequest.Query)
+}
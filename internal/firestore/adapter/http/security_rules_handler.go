@@ -1,7 +1,11 @@
 package http
 
 import (
+	"context"
+
 	"firestore-clone/internal/firestore/usecase"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -14,6 +18,19 @@ func NewSecurityRulesHandler(uc usecase.SecurityRulesCRUDUsecase) *SecurityRules
 	return &SecurityRulesHandler{UC: uc}
 }
 
+// registerSecurityRulesRoutes registers the rules CRUD of a database for administrators
+func (h *HTTPHandler) registerSecurityRulesRoutes(router fiber.Router) {
+	if h.RulesCRUDUC == nil {
+		return
+	}
+	rules := NewSecurityRulesHandler(h.RulesCRUDUC)
+	router.Get("/securityRules", h.rulesAdmin(rules.GetRules)...)
+	router.Put("/securityRules", h.rulesAdmin(rules.PutRules)...)
+	router.Patch("/securityRules", h.rulesAdmin(rules.PatchRules)...)
+	router.Delete("/securityRules", h.rulesAdmin(rules.DeleteRules)...)
+	router.Post("/securityRules\\:validate", h.rulesAdmin(rules.ValidateRules)...)
+}
+
 func (h *SecurityRulesHandler) RegisterRoutes(router fiber.Router) {
 	router.Get("/v1/projects/:projectID/databases/:databaseID/securityRules", h.GetRules)
 	router.Put("/v1/projects/:projectID/databases/:databaseID/securityRules", h.PutRules)
@@ -31,21 +48,51 @@ func (h *SecurityRulesHandler) GetRules(c *fiber.Ctx) error {
 }
 
 func (h *SecurityRulesHandler) PutRules(c *fiber.Ctx) error {
-	var body struct {
-		Rules string `json:"rules"`
-	}
+	var body rulesBody
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
 	}
-	err := h.UC.PutRules(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), body.Rules, "admin")
+	err := h.UC.PutRules(body.context(c), c.Params("projectID"), c.Params("databaseID"), body.Rules, rulesAuthor(c))
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.SendStatus(204)
 }
 
+// PatchRules fusiona los bloques match de {"rules": "..."} con las reglas desplegadas
 func (h *SecurityRulesHandler) PatchRules(c *fiber.Ctx) error {
-	return c.Status(501).JSON(fiber.Map{"error": "PATCH not implemented"})
+	var body rulesBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
+	}
+	err := h.UC.PatchRules(body.context(c), c.Params("projectID"), c.Params("databaseID"), body.Rules, rulesAuthor(c))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(204)
+}
+
+// rulesBody es el cuerpo de PUT, PATCH y :validate. AllowInsecure acepta las reglas con
+// hallazgos de seguridad de severidad error.
+type rulesBody struct {
+	Rules         string `json:"rules"`
+	AllowInsecure bool   `json:"allowInsecure"`
+}
+
+// context devuelve el contexto de la petición, aceptando las reglas inseguras si se pidió
+func (b *rulesBody) context(c *fiber.Ctx) context.Context {
+	if b.AllowInsecure {
+		return rtdomain.WithInsecureRulesAllowed(c.UserContext())
+	}
+	return c.UserContext()
+}
+
+// rulesAuthor devuelve el usuario autenticado al que se atribuye un cambio de reglas
+func rulesAuthor(c *fiber.Ctx) string {
+	if userID, err := utils.GetUserIDFromContext(c.UserContext()); err == nil && userID != "" {
+		return userID
+	}
+	return "admin"
 }
 
 func (h *SecurityRulesHandler) DeleteRules(c *fiber.Ctx) error {
@@ -57,13 +104,11 @@ func (h *SecurityRulesHandler) DeleteRules(c *fiber.Ctx) error {
 }
 
 func (h *SecurityRulesHandler) ValidateRules(c *fiber.Ctx) error {
-	var body struct {
-		Rules string `json:"rules"`
-	}
+	var body rulesBody
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid body"})
 	}
-	err := h.UC.ValidateRules(c.UserContext(), body.Rules)
+	err := h.UC.ValidateRules(body.context(c), body.Rules)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
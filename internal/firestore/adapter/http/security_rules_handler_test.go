@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firestore-clone/internal/firestore/usecase"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchRecordingCRUD records the rules patches it receives
+type patchRecordingCRUD struct {
+	usecase.SecurityRulesCRUDUsecase
+	patched, user string
+	allowInsecure bool
+}
+
+func (u *patchRecordingCRUD) PatchRules(ctx context.Context, projectID, databaseID, partialText, user string) error {
+	u.patched, u.user = partialText, user
+	u.allowInsecure = rtdomain.InsecureRulesAllowed(ctx)
+	return nil
+}
+
+func TestSecurityRulesRoutes_PatchAsAdministrator(t *testing.T) {
+	crud := &patchRecordingCRUD{}
+	h := &HTTPHandler{RulesCRUDUC: crud, RulesAdminAuth: []fiber.Handler{headerAdminAuth}, Log: TestLogger{}}
+	app := fiber.New()
+	h.registerSecurityRulesRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+
+	patch := func(user string, allowInsecure ...bool) int {
+		body := `{"rules":"match /users/{userId} {}"}`
+		if len(allowInsecure) > 0 && allowInsecure[0] {
+			body = `{"rules":"match /users/{userId} {}","allowInsecure":true}`
+		}
+		req := httptest.NewRequest("PATCH", "/projects/p/databases/d/securityRules", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusUnauthorized, patch(""))
+	assert.Empty(t, crud.patched)
+
+	assert.Equal(t, fiber.StatusNoContent, patch("alice"))
+	assert.Equal(t, "match /users/{userId} {}", crud.patched)
+	assert.Equal(t, "alice", crud.user, "The patch is attributed to the administrator")
+	assert.False(t, crud.allowInsecure)
+
+	assert.Equal(t, fiber.StatusNoContent, patch("alice", true))
+	assert.True(t, crud.allowInsecure, "allowInsecure accepts rules with security lint errors")
+}
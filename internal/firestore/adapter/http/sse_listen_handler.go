@@ -0,0 +1,442 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/client"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/firestore"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// SSEListenHandler serves listen targets over Server-Sent Events, for clients behind
+// proxies that block WebSocket upgrades. GET /listen/sse opens a session streaming the
+// targets given as target query parameters; POST /listen/sse/:sessionId adds or removes
+// targets of the session with the subscription messages of the WebSocket. Messages are
+// the Listen protocol messages of the WebSocket, sent as "event: <kind>" frames; target
+// changes carrying a resume token use it as the frame ID, which browsers send back as
+// Last-Event-ID when reconnecting.
+type SSEListenHandler struct {
+	realtimeUC usecase.RealtimeUsecase
+	log        logger.Logger
+
+	sessions   map[string]*sseSession
+	sessionsMu sync.RWMutex
+
+	heartbeatInterval   time.Duration
+	consistencyInterval time.Duration
+
+	// FirestoreUC reads the initial snapshot of listen targets; without it targets start empty
+	FirestoreUC usecase.FirestoreUsecaseInterface
+	// SecurityUC checks targets against the rules for the user loaded through AuthClient;
+	// targets of a user that cannot be loaded are denied. Without SecurityUC targets are
+	// not checked.
+	SecurityUC usecase.SecurityUsecase
+	AuthClient client.AuthClient
+}
+
+// sseSession is an open event stream and the targets it carries
+type sseSession struct {
+	id     string
+	userID string
+	user   *authModel.User // Loaded from userID when the first target is added
+	ctx    context.Context
+	cancel context.CancelFunc
+	frames chan sseFrame
+
+	mutex   sync.RWMutex
+	events  map[model.SubscriptionID]chan model.RealtimeEvent
+	targets map[model.SubscriptionID]*usecase.ListenTarget
+}
+
+// sseFrame is one event of the stream
+type sseFrame struct {
+	id    string
+	event string
+	data  []byte
+}
+
+// NewSSEListenHandler creates a Server-Sent Events listen handler
+func NewSSEListenHandler(rtuc usecase.RealtimeUsecase, log logger.Logger) *SSEListenHandler {
+	return &SSEListenHandler{
+		realtimeUC:          rtuc,
+		log:                 log,
+		sessions:            make(map[string]*sseSession),
+		heartbeatInterval:   15 * time.Second,
+		consistencyInterval: 10 * time.Second,
+	}
+}
+
+// RegisterRoutes registers the SSE listen endpoints behind the authentication middleware
+func (h *SSEListenHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	sseGroup := router.Group("/listen/sse", authMiddleware, TenantMiddleware())
+	sseGroup.Get("/", h.OpenStream)
+	sseGroup.Post("/:sessionId", h.UpdateTargets)
+}
+
+// OpenStream opens an event stream with the targets of the target query parameters. The
+// first event is "session", whose sessionId addresses the stream in UpdateTargets.
+func (h *SSEListenHandler) OpenStream(c *fiber.Ctx) error {
+	userID, _ := utils.GetUserIDFromContext(c.UserContext())
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.UserContext()))
+	session := &sseSession{
+		id:      uuid.NewString(),
+		userID:  userID,
+		ctx:     ctx,
+		cancel:  cancel,
+		frames:  make(chan sseFrame, 100),
+		events:  make(map[model.SubscriptionID]chan model.RealtimeEvent),
+		targets: make(map[model.SubscriptionID]*usecase.ListenTarget),
+	}
+	sessionData, _ := json.Marshal(fiber.Map{"sessionId": session.id})
+	session.frames <- sseFrame{event: "session", data: sessionData}
+
+	// A reconnecting client holds the documents of its targets
+	resumeToken := model.ResumeToken(c.Get("Last-Event-ID"))
+	for _, target := range c.Context().QueryArgs().PeekMulti("target") {
+		path := string(target)
+		req := model.SubscriptionRequest{SubscriptionID: model.SubscriptionID(path), FullPath: path, ResumeToken: resumeToken}
+		if err := h.addTarget(session, req); err != nil {
+			h.closeSession(session)
+			return targetErrorResponse(c, err)
+		}
+	}
+
+	h.sessionsMu.Lock()
+	h.sessions[session.id] = session
+	h.sessionsMu.Unlock()
+
+	h.log.Info("SSE listen session opened",
+		zap.String("sessionID", session.id),
+		zap.Int("targets", len(session.targets)))
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		h.writeFrames(session, w)
+	})
+	return nil
+}
+
+// UpdateTargets adds or removes a target of a session from a subscription message
+func (h *SSEListenHandler) UpdateTargets(c *fiber.Ctx) error {
+	h.sessionsMu.RLock()
+	session, ok := h.sessions[c.Params("sessionId")]
+	h.sessionsMu.RUnlock()
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "session_not_found",
+			"message": "The listen session does not exist or was closed",
+		})
+	}
+	if userID, _ := utils.GetUserIDFromContext(c.UserContext()); userID != session.userID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   "forbidden",
+			"message": "The listen session belongs to another user",
+		})
+	}
+
+	var req model.SubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request",
+			"message": "Invalid request body",
+		})
+	}
+	if req.SubscriptionID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request",
+			"message": "subscriptionId is required",
+		})
+	}
+
+	switch req.Action {
+	case model.MessageTypeSubscribe:
+		if err := h.addTarget(session, req); err != nil {
+			return targetErrorResponse(c, err)
+		}
+		return c.JSON(model.SubscriptionResponse{
+			Type:           model.MessageTypeSubscriptionConfirmed,
+			SubscriptionID: req.SubscriptionID,
+			Status:         "confirmed",
+		})
+	case model.MessageTypeUnsubscribe:
+		h.removeTarget(session, req.SubscriptionID)
+		return c.JSON(model.SubscriptionResponse{
+			Type:           "unsubscription_confirmed",
+			SubscriptionID: req.SubscriptionID,
+			Status:         "confirmed",
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_action",
+			"message": "Unknown action: " + req.Action,
+		})
+	}
+}
+
+// addTarget subscribes a session to a target and starts streaming it
+func (h *SSEListenHandler) addTarget(session *sseSession, req model.SubscriptionRequest) error {
+	if _, err := firestore.ParseFirestorePath(req.FullPath); err != nil {
+		return fmt.Errorf("invalid Firestore path %q", req.FullPath)
+	}
+	session.mutex.Lock()
+	if session.user == nil {
+		session.user = loadListenUser(session.ctx, h.AuthClient, session.userID, req.FullPath, h.log)
+	}
+	user := session.user
+	session.mutex.Unlock()
+	if h.SecurityUC != nil {
+		// Targets are never streamed unchecked: a user that cannot be loaded is denied
+		var err error = errors.NewAuthorizationError("listen target denied: the authenticated user could not be loaded")
+		if user != nil {
+			err = authorizeListenTarget(session.ctx, h.SecurityUC, user, req.FullPath, req.Query)
+		}
+		if err != nil {
+			h.log.Warn("Security validation failed for SSE listen target",
+				zap.String("sessionID", session.id),
+				zap.String("path", req.FullPath),
+				zap.Error(err))
+			return err
+		}
+	}
+	target, err := usecase.NewListenTarget(req.SubscriptionID, req.FullPath, req.Query)
+	if err != nil {
+		return err
+	}
+
+	eventChan := make(chan model.RealtimeEvent, 200)
+	_, err = h.realtimeUC.Subscribe(session.ctx, usecase.SubscribeRequest{
+		SubscriberID:   session.id,
+		SubscriptionID: req.SubscriptionID,
+		FirestorePath:  req.FullPath,
+		EventChannel:   eventChan,
+		Query:          req.Query,
+		Options: usecase.SubscriptionOptions{
+			IncludeMetadata:   true,
+			IncludeOldData:    true,
+			HeartbeatInterval: h.heartbeatInterval,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	session.mutex.Lock()
+	session.events[req.SubscriptionID] = eventChan
+	session.targets[req.SubscriptionID] = target
+	session.mutex.Unlock()
+
+	stream := &listenStream{
+		target:     target,
+		events:     eventChan,
+		reader:     h.FirestoreUC,
+		lock:       &session.mutex,
+		subscriber: session.id,
+		log:        h.log,
+		send: func(response model.ListenResponse) bool {
+			return h.queueListenResponse(session, response)
+		},
+	}
+	go stream.run(session.ctx, req.ResumeToken != "")
+	return nil
+}
+
+// targetErrorResponse maps an error adding a target: denied targets are forbidden, the
+// others invalid
+func targetErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.IsAuthorization(err) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   "permission_denied",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "invalid_target",
+		"message": err.Error(),
+	})
+}
+
+// removeTarget unsubscribes a session from a target; its stream ends with REMOVE
+func (h *SSEListenHandler) removeTarget(session *sseSession, subscriptionID model.SubscriptionID) {
+	if err := h.realtimeUC.Unsubscribe(session.ctx, usecase.UnsubscribeRequest{SubscriberID: session.id, SubscriptionID: subscriptionID}); err != nil {
+		h.log.Warn("Error unsubscribing SSE listen target",
+			zap.String("sessionID", session.id),
+			zap.String("subscriptionID", string(subscriptionID)),
+			zap.Error(err))
+	}
+
+	session.mutex.Lock()
+	if eventChan, ok := session.events[subscriptionID]; ok {
+		close(eventChan)
+		delete(session.events, subscriptionID)
+	}
+	delete(session.targets, subscriptionID)
+	session.mutex.Unlock()
+}
+
+// RevalidateListeners checks the targets of the sessions in scope against the current
+// security rules; targets no longer allowed end with a subscription_error frame carrying
+// PERMISSION_DENIED
+func (h *SSEListenHandler) RevalidateListeners(ctx context.Context, scope usecase.ListenerRevalidation) []usecase.RevokedListener {
+	if h.SecurityUC == nil {
+		return nil
+	}
+	h.sessionsMu.RLock()
+	sessions := make([]*sseSession, 0, len(h.sessions))
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+	h.sessionsMu.RUnlock()
+
+	revalidation := listenRevalidation{
+		realtimeUC: h.realtimeUC,
+		securityUC: h.SecurityUC,
+		authClient: h.AuthClient,
+		log:        h.log,
+		scope:      scope,
+	}
+	var revoked []usecase.RevokedListener
+	for _, session := range sessions {
+		session.mutex.RLock()
+		user := session.user
+		session.mutex.RUnlock()
+		if user == nil || !revalidation.includes(session.userID) {
+			continue
+		}
+		denied := revalidation.revoke(ctx, session.id, session.userID, user)
+		for _, listener := range denied {
+			subscriptionID := model.SubscriptionID(listener.SubscriptionID)
+			session.mutex.Lock()
+			if eventChan, ok := session.events[subscriptionID]; ok {
+				close(eventChan)
+				delete(session.events, subscriptionID)
+			}
+			delete(session.targets, subscriptionID)
+			session.mutex.Unlock()
+
+			data, _ := json.Marshal(model.SubscriptionResponse{
+				Type:           model.MessageTypeSubscriptionError,
+				SubscriptionID: subscriptionID,
+				Status:         "error",
+				Error:          "PERMISSION_DENIED: " + listener.Reason,
+			})
+			select {
+			case session.frames <- sseFrame{event: model.MessageTypeSubscriptionError, data: data}:
+			case <-session.ctx.Done():
+			case <-time.After(5 * time.Second):
+				h.log.Warn("SSE frame queue full, permission error not sent",
+					zap.String("sessionID", session.id),
+					zap.String("subscriptionID", listener.SubscriptionID))
+			}
+		}
+		revoked = append(revoked, denied...)
+	}
+	return revoked
+}
+
+// writeFrames writes the frames of a session to the stream, with heartbeat comments and
+// global consistency points, until the client goes away
+func (h *SSEListenHandler) writeFrames(session *sseSession, w *bufio.Writer) {
+	defer h.closeSession(session)
+	heartbeat := time.NewTicker(h.heartbeatInterval)
+	defer heartbeat.Stop()
+	consistency := time.NewTicker(h.consistencyInterval)
+	defer consistency.Stop()
+
+	for {
+		var err error
+		select {
+		case <-session.ctx.Done():
+			return
+		case frame := <-session.frames:
+			err = writeSSEFrame(w, frame)
+		case <-heartbeat.C:
+			if _, err = w.WriteString(": heartbeat\n\n"); err == nil {
+				err = w.Flush()
+			}
+		case <-consistency.C:
+			session.mutex.RLock()
+			consistent := len(session.frames) == 0 && targetsConsistent(session.targets, session.events)
+			session.mutex.RUnlock()
+			if consistent {
+				err = writeSSEFrame(w, listenResponseFrame(globalConsistencyPoint()))
+			}
+		}
+		if err != nil {
+			h.log.Debug("SSE listen stream closed",
+				zap.String("sessionID", session.id),
+				zap.Error(err))
+			return
+		}
+	}
+}
+
+// closeSession unsubscribes all targets of a session and forgets it
+func (h *SSEListenHandler) closeSession(session *sseSession) {
+	session.cancel()
+	h.sessionsMu.Lock()
+	delete(h.sessions, session.id)
+	h.sessionsMu.Unlock()
+
+	if err := h.realtimeUC.UnsubscribeAll(context.Background(), session.id); err != nil {
+		h.log.Warn("Error unsubscribing SSE listen session",
+			zap.String("sessionID", session.id),
+			zap.Error(err))
+	}
+	h.log.Info("SSE listen session closed", zap.String("sessionID", session.id))
+}
+
+// queueListenResponse queues a Listen protocol message, reporting false when it could not be sent
+func (h *SSEListenHandler) queueListenResponse(session *sseSession, response model.ListenResponse) bool {
+	select {
+	case session.frames <- listenResponseFrame(response):
+		return true
+	case <-session.ctx.Done():
+		return false
+	case <-time.After(5 * time.Second):
+		h.log.Warn("SSE frame queue full, listen target will be resynchronized",
+			zap.String("sessionID", session.id))
+		return false
+	}
+}
+
+// listenResponseFrame turns a Listen protocol message into a frame; target changes with a
+// resume token carry it as the frame ID
+func listenResponseFrame(response model.ListenResponse) sseFrame {
+	kind, content := listenResponseKind(response)
+	data, _ := json.Marshal(content)
+	frame := sseFrame{event: kind, data: data}
+	if response.TargetChange != nil {
+		frame.id = string(response.TargetChange.ResumeToken)
+	}
+	return frame
+}
+
+// writeSSEFrame writes and flushes one frame
+func writeSSEFrame(w *bufio.Writer, frame sseFrame) error {
+	if frame.id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", frame.id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", frame.event, frame.data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
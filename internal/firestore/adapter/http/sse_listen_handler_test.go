@@ -0,0 +1,279 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	sharedErrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is a frame read from an SSE stream
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSEEvent reads the next frame, skipping comments
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	events := make(chan sseEvent, 1)
+	go func() {
+		var event sseEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && event.event != "":
+				events <- event
+				return
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for SSE event")
+		return sseEvent{}
+	}
+}
+
+func startSSEServer(t *testing.T, realtimeUC usecase.RealtimeUsecase) string {
+	app := fiber.New()
+	fakeAuth := func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), c.Get("X-User")))
+		return c.Next()
+	}
+	handler := NewSSEListenHandler(realtimeUC, TestLogger{})
+	handler.heartbeatInterval = 50 * time.Millisecond
+	handler.RegisterRoutes(app, fakeAuth)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + listener.Addr().String()
+}
+
+func TestSSEListenHandler_StreamsTargets(t *testing.T) {
+	realtimeUC := usecase.NewRealtimeUsecase(TestLogger{})
+	baseURL := startSSEServer(t, realtimeUC)
+	collection := "projects/p/databases/d/documents/rooms"
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/listen/sse?organization_id=org1&target="+url.QueryEscape(collection), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-User", "u1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	session := readSSEEvent(t, reader)
+	require.Equal(t, "session", session.event)
+	var sessionData struct {
+		SessionID string `json:"sessionId"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(session.data), &sessionData))
+
+	var change model.TargetChange
+	added := readSSEEvent(t, reader)
+	require.Equal(t, "targetChange", added.event)
+	require.NoError(t, json.Unmarshal([]byte(added.data), &change))
+	assert.Equal(t, model.TargetChangeAdd, change.TargetChangeType)
+	current := readSSEEvent(t, reader)
+	require.NoError(t, json.Unmarshal([]byte(current.data), &change))
+	assert.Equal(t, model.TargetChangeCurrent, change.TargetChangeType)
+
+	require.NoError(t, realtimeUC.PublishEvent(context.Background(), model.RealtimeEvent{
+		Type: model.EventTypeAdded, FullPath: collection + "/r1", Data: map[string]interface{}{"name": "lobby"}, Timestamp: time.Now(),
+	}))
+	documentChange := readSSEEvent(t, reader)
+	assert.Equal(t, "documentChange", documentChange.event)
+	assert.Contains(t, documentChange.data, collection+"/r1")
+
+	// Another user cannot change the targets of the session
+	body := `{"action":"subscribe","subscriptionId":"r2","fullPath":"` + collection + `/r2"}`
+	post := func(user string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/listen/sse/"+sessionData.SessionID+"?organization_id=org1", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, http.StatusForbidden, post("u2").StatusCode)
+
+	// A target added to the session is multiplexed on the same stream
+	require.Equal(t, http.StatusOK, post("u1").StatusCode)
+	added = readSSEEvent(t, reader)
+	require.NoError(t, json.Unmarshal([]byte(added.data), &change))
+	assert.Equal(t, model.TargetChangeAdd, change.TargetChangeType)
+	assert.Equal(t, []model.SubscriptionID{"r2"}, change.TargetIDs)
+}
+
+// roleSecurityUC allows listening only to users with the reader role
+type roleSecurityUC struct {
+	*usecase.MockSecurityUsecase
+}
+
+func (m *roleSecurityUC) ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error {
+	for _, role := range user.Roles {
+		if role == "reader" {
+			return nil
+		}
+	}
+	return sharedErrors.NewAuthorizationError("query denied by security rules: missing reader role")
+}
+
+func TestSSEListenHandler_RejectsDeniedTargets(t *testing.T) {
+	realtimeUC := usecase.NewRealtimeUsecase(TestLogger{})
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "u1"})
+
+	app := fiber.New()
+	handler := NewSSEListenHandler(realtimeUC, TestLogger{})
+	handler.SecurityUC = &roleSecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	handler.AuthClient = authClient
+	handler.RegisterRoutes(app, func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), c.Get("X-User")))
+		return c.Next()
+	})
+
+	collection := "projects/p/databases/d/documents/rooms"
+	open := func(user string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, "/listen/sse?organization_id=org1&target="+url.QueryEscape(collection), nil)
+		require.NoError(t, err)
+		req.Header.Set("X-User", user)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// The rules deny the query of the target: the stream is not opened
+	assert.Equal(t, http.StatusForbidden, open("u1").StatusCode)
+	assert.Equal(t, 0, realtimeUC.GetSubscriberCount(collection))
+
+	// A user that cannot be loaded is denied rather than left unchecked
+	assert.Equal(t, http.StatusForbidden, open("unknown").StatusCode)
+	assert.Equal(t, 0, realtimeUC.GetSubscriberCount(collection))
+}
+
+func TestSSEListenHandler_RevalidateListeners(t *testing.T) {
+	realtimeUC := usecase.NewRealtimeUsecase(TestLogger{})
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "u1", Roles: []string{"reader"}})
+
+	app := fiber.New()
+	handler := NewSSEListenHandler(realtimeUC, TestLogger{})
+	handler.SecurityUC = &roleSecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	handler.AuthClient = authClient
+	handler.heartbeatInterval = 50 * time.Millisecond
+	handler.RegisterRoutes(app, func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), "u1"))
+		return c.Next()
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	collection := "projects/p/databases/d/documents/rooms"
+	resp, err := http.Get("http://" + listener.Addr().String() + "/listen/sse?organization_id=org1&target=" + url.QueryEscape(collection))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reader := bufio.NewReader(resp.Body)
+	require.Equal(t, "session", readSSEEvent(t, reader).event)
+	readSSEEvent(t, reader) // ADD
+	readSSEEvent(t, reader) // CURRENT
+
+	// The user loses the role: rules of other databases and role changes of other users do
+	// not affect the target
+	authClient.SetUser(&authModel.User{UserID: "u1"})
+	assert.Empty(t, handler.RevalidateListeners(context.Background(), usecase.ListenerRevalidation{ProjectID: "p", DatabaseID: "other"}))
+	assert.Empty(t, handler.RevalidateListeners(context.Background(), usecase.ListenerRevalidation{UserID: "u2"}))
+
+	revoked := handler.RevalidateListeners(context.Background(), usecase.ListenerRevalidation{UserID: "u1"})
+	require.Len(t, revoked, 1)
+	assert.Equal(t, collection, revoked[0].Path)
+	assert.Equal(t, "u1", revoked[0].UserID)
+	assert.Equal(t, "p", revoked[0].ProjectID)
+	assert.Equal(t, "d", revoked[0].DatabaseID)
+
+	frame := readSSEEvent(t, reader)
+	assert.Equal(t, model.MessageTypeSubscriptionError, frame.event)
+	var response model.SubscriptionResponse
+	require.NoError(t, json.Unmarshal([]byte(frame.data), &response))
+	assert.Equal(t, model.SubscriptionID(collection), response.SubscriptionID)
+	assert.Contains(t, response.Error, "PERMISSION_DENIED")
+
+	// The realtime subscription is gone
+	assert.Equal(t, 0, realtimeUC.GetSubscriberCount(collection))
+}
+
+func TestSSEListenHandler_RevalidateListenersOfAnUnloadableUser(t *testing.T) {
+	realtimeUC := usecase.NewRealtimeUsecase(TestLogger{})
+	authClient := usecase.NewMockAuthClient()
+	authClient.SetUser(&authModel.User{UserID: "u1", Roles: []string{"reader"}})
+
+	app := fiber.New()
+	handler := NewSSEListenHandler(realtimeUC, TestLogger{})
+	handler.SecurityUC = &roleSecurityUC{MockSecurityUsecase: usecase.NewMockSecurityUsecase()}
+	handler.AuthClient = authClient
+	handler.heartbeatInterval = 50 * time.Millisecond
+	handler.RegisterRoutes(app, func(c *fiber.Ctx) error {
+		c.SetUserContext(utils.WithUserID(c.UserContext(), "u1"))
+		return c.Next()
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	collection := "projects/p/databases/d/documents/rooms"
+	resp, err := http.Get("http://" + listener.Addr().String() + "/listen/sse?organization_id=org1&target=" + url.QueryEscape(collection))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reader := bufio.NewReader(resp.Body)
+	require.Equal(t, "session", readSSEEvent(t, reader).event)
+	readSSEEvent(t, reader) // ADD
+	readSSEEvent(t, reader) // CURRENT
+
+	// The user is deleted: its listeners are revoked instead of reusing the cached user
+	authClient.SetUser(nil)
+	revoked := handler.RevalidateListeners(context.Background(), usecase.ListenerRevalidation{UserID: "u1"})
+	require.Len(t, revoked, 1)
+	assert.Equal(t, collection, revoked[0].Path)
+	assert.Contains(t, revoked[0].Reason, "could not be loaded")
+
+	frame := readSSEEvent(t, reader)
+	assert.Equal(t, model.MessageTypeSubscriptionError, frame.event)
+	assert.Equal(t, 0, realtimeUC.GetSubscriberCount(collection))
+}
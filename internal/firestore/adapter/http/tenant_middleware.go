@@ -418,26 +418,34 @@ func ValidateDatabaseIDFormat(databaseID string) error {
 	return nil
 }
 
+// requestOrganizationID returns the organization of a request from the path, the
+// X-Organization-ID header, an "token@organization" bearer token or the organization_id
+// query parameter, or "" when none is given
+func requestOrganizationID(c *fiber.Ctx) string {
+	organizationID := c.Params("organizationId")
+	if organizationID == "" {
+		organizationID = c.Get("X-Organization-ID")
+	}
+	if organizationID == "" {
+		if auth := c.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token := strings.TrimPrefix(auth, "Bearer ")
+			if parts := strings.Split(token, "@"); len(parts) == 2 {
+				organizationID = parts[1]
+			}
+		}
+	}
+	if organizationID == "" {
+		organizationID = c.Query("organization_id")
+	}
+	return organizationID
+}
+
 // TenantMiddleware validates tenant context and organization access
 func TenantMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		fmt.Println("[TenantMiddleware] INICIO: path=", c.Path(), "method=", c.Method(), "params=", c.Params("organizationId"), c.Params("projectID"), c.Params("databaseID"))
 		// Extract organization ID from path, header, query, or Authorization
-		organizationID := c.Params("organizationId")
-		if organizationID == "" {
-			organizationID = c.Get("X-Organization-ID")
-		}
-		if organizationID == "" {
-			if auth := c.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
-				token := strings.TrimPrefix(auth, "Bearer ")
-				if parts := strings.Split(token, "@"); len(parts) == 2 {
-					organizationID = parts[1]
-				}
-			}
-		}
-		if organizationID == "" {
-			organizationID = c.Query("organization_id")
-		}
+		organizationID := requestOrganizationID(c)
 		if organizationID == "" {
 			fmt.Println("[TenantMiddleware] organization_id_missing")
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
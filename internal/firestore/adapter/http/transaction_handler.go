@@ -36,6 +36,9 @@ func (h *HTTPHandler) BatchWrite(c *fiber.Ctx) error {
 	response, err := h.FirestoreUC.RunBatchWrite(c.UserContext(), req)
 	if err != nil {
 		h.Log.Error("Failed to execute batch write", "error", err)
+		if handled, respErr := schemaViolationResponse(c, err); handled {
+			return respErr
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "batch_write_failed",
 			"message": err.Error(),
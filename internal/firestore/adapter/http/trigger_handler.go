@@ -0,0 +1,106 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultDeliveryListLimit bounds the deliveries listed when no limit is given
+const defaultDeliveryListLimit = 100
+
+// registerTriggerRoutes registers document trigger management and delivery admin endpoints
+func (h *HTTPHandler) registerTriggerRoutes(router fiber.Router) {
+	if h.TriggerUC == nil {
+		return
+	}
+	// Triggers send document data to arbitrary endpoints, so only administrators manage
+	// them and their deliveries
+	router.Get("/triggers", h.adminOnly(h.ListTriggers)...)
+	router.Post("/triggers", h.adminOnly(h.CreateTrigger)...)
+	router.Get("/triggers/:triggerID", h.adminOnly(h.GetTrigger)...)
+	router.Delete("/triggers/:triggerID", h.adminOnly(h.DeleteTrigger)...)
+	router.Get("/triggerDeliveries", h.adminOnly(h.ListTriggerDeliveries)...)
+	router.Post("/triggerDeliveries/:deliveryID\\:retry", h.adminOnly(h.RetryTriggerDelivery)...)
+}
+
+// CreateTrigger registers a webhook trigger on a document pattern
+func (h *HTTPHandler) CreateTrigger(c *fiber.Ctx) error {
+	var req usecase.CreateTriggerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "invalid_request_body",
+			"message": "Failed to parse request body",
+		})
+	}
+	req.ProjectID = c.Params("projectID")
+	req.DatabaseID = c.Params("databaseID")
+
+	trigger, err := h.TriggerUC.CreateTrigger(c.UserContext(), req)
+	if err != nil {
+		h.Log.Error("Failed to create document trigger", "error", err, "document", req.DocumentPattern)
+		return operationErrorResponse(c, err, "create_trigger_failed")
+	}
+	return c.Status(fiber.StatusCreated).JSON(trigger)
+}
+
+// GetTrigger returns a document trigger
+func (h *HTTPHandler) GetTrigger(c *fiber.Ctx) error {
+	trigger, err := h.TriggerUC.GetTrigger(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("triggerID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "get_trigger_failed")
+	}
+	return c.JSON(trigger)
+}
+
+// ListTriggers lists the document triggers of a database
+func (h *HTTPHandler) ListTriggers(c *fiber.Ctx) error {
+	triggers, err := h.TriggerUC.ListTriggers(c.UserContext(), c.Params("projectID"), c.Params("databaseID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "list_triggers_failed")
+	}
+	return c.JSON(fiber.Map{"triggers": triggers})
+}
+
+// DeleteTrigger removes a document trigger
+func (h *HTTPHandler) DeleteTrigger(c *fiber.Ctx) error {
+	if err := h.TriggerUC.DeleteTrigger(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("triggerID")); err != nil {
+		return operationErrorResponse(c, err, "delete_trigger_failed")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListTriggerDeliveries lists trigger deliveries, dead-lettered ones by default
+func (h *HTTPHandler) ListTriggerDeliveries(c *fiber.Ctx) error {
+	state := model.TriggerDeliveryState(strings.ToUpper(c.Query("state", string(model.TriggerDeliveryDeadLetter))))
+	limit := defaultDeliveryListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "invalid_limit",
+				"message": "limit must be a positive integer",
+			})
+		}
+		limit = parsed
+	}
+
+	deliveries, err := h.TriggerUC.ListDeliveries(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), state, limit)
+	if err != nil {
+		return operationErrorResponse(c, err, "list_trigger_deliveries_failed")
+	}
+	return c.JSON(fiber.Map{"deliveries": deliveries})
+}
+
+// RetryTriggerDelivery queues a dead-lettered delivery again
+func (h *HTTPHandler) RetryTriggerDelivery(c *fiber.Ctx) error {
+	delivery, err := h.TriggerUC.RetryDelivery(c.UserContext(), c.Params("projectID"), c.Params("databaseID"), c.Params("deliveryID"))
+	if err != nil {
+		return operationErrorResponse(c, err, "retry_trigger_delivery_failed")
+	}
+	return c.JSON(delivery)
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authrepo "firestore-clone/internal/auth/domain/repository"
+	"firestore-clone/internal/firestore/adapter/webhook"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTriggerTestApp() *fiber.App {
+	app := fiber.New()
+	triggerUC := usecase.NewTriggerUsecase(nil, nil, webhook.NewHTTPSender(nil), usecase.DefaultTriggerDeliveryConfig(), TestLogger{})
+	h := &HTTPHandler{
+		FirestoreUC: &MockFirestoreUC{},
+		TriggerUC:   triggerUC,
+		RulesAdminAuth: newRulesAdminAuth(map[string]*authrepo.Claims{
+			"admin-token":  {UserID: "alice", ProjectID: "p", DatabaseID: "d", Roles: []string{usecase.AdminRole}},
+			"member-token": {UserID: "bob", ProjectID: "p", DatabaseID: "d", Roles: []string{"user"}},
+		}),
+		Log: TestLogger{},
+	}
+	h.registerTriggerRoutes(app.Group("/projects/:projectID/databases/:databaseID"))
+	return app
+}
+
+// triggerRequest builds a trigger management request made with a token
+func triggerRequest(method, target, token, body string) *http.Request {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestTriggerHandler_CreateGetDelete(t *testing.T) {
+	app := newTriggerTestApp()
+
+	body := `{"eventType":"onCreate","document":"users/{uid}","url":"https://hooks.example.com/users"}`
+	resp, err := app.Test(triggerRequest("POST", "/projects/p/databases/d/triggers", "admin-token", body))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+	var trigger model.DocumentTrigger
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trigger))
+	assert.NotEmpty(t, trigger.TriggerID)
+	assert.Equal(t, "p", trigger.ProjectID)
+	assert.Equal(t, "users/{uid}", trigger.DocumentPattern)
+
+	resp, err = app.Test(triggerRequest("GET", "/projects/p/databases/d/triggers/"+trigger.TriggerID, "admin-token", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(triggerRequest("DELETE", "/projects/p/databases/d/triggers/"+trigger.TriggerID, "admin-token", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+
+	resp, err = app.Test(triggerRequest("GET", "/projects/p/databases/d/triggers/"+trigger.TriggerID, "admin-token", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestTriggerHandler_InvalidRequests(t *testing.T) {
+	app := newTriggerTestApp()
+
+	resp, err := app.Test(triggerRequest("POST", "/projects/p/databases/d/triggers", "admin-token", `{"eventType":"onCreate","document":"users","url":"https://hooks.example.com"}`))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(triggerRequest("GET", "/projects/p/databases/d/triggerDeliveries?limit=0", "admin-token", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	resp, err = app.Test(triggerRequest("POST", "/projects/p/databases/d/triggerDeliveries/missing:retry", "admin-token", ""))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestTriggerHandler_RequiresAdministrator(t *testing.T) {
+	app := newTriggerTestApp()
+	body := `{"eventType":"onCreate","document":"users/{uid}","url":"https://hooks.example.com/users"}`
+
+	requests := []struct{ method, path, body string }{
+		{"GET", "/projects/p/databases/d/triggers", ""},
+		{"POST", "/projects/p/databases/d/triggers", body},
+		{"GET", "/projects/p/databases/d/triggers/t1", ""},
+		{"DELETE", "/projects/p/databases/d/triggers/t1", ""},
+		{"GET", "/projects/p/databases/d/triggerDeliveries", ""},
+		{"POST", "/projects/p/databases/d/triggerDeliveries/d1:retry", ""},
+	}
+	for _, r := range requests {
+		resp, err := app.Test(triggerRequest(r.method, r.path, "", r.body))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode, "%s %s", r.method, r.path)
+
+		resp, err = app.Test(triggerRequest(r.method, r.path, "member-token", r.body))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode, "%s %s", r.method, r.path)
+	}
+}
@@ -3,9 +3,9 @@ package mongodb
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/utils"
 )
 
 // AtomicOperations maneja operaciones atómicas sobre documentos
@@ -51,7 +51,7 @@ func (a *AtomicOperations) AtomicIncrement(ctx context.Context, projectID, datab
 			fmt.Sprintf("fields.%s.value", field): value,
 		},
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 
@@ -92,7 +92,7 @@ func (a *AtomicOperations) AtomicArrayUnion(ctx context.Context, projectID, data
 			},
 		},
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la unión atómica de arreglos
@@ -130,7 +130,7 @@ func (a *AtomicOperations) AtomicArrayRemove(ctx context.Context, projectID, dat
 			fmt.Sprintf("fields.%s.value", field): values,
 		},
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la eliminación atómica de arreglos
@@ -156,12 +156,13 @@ func (a *AtomicOperations) AtomicServerTimestamp(ctx context.Context, projectID,
 		"document_id":   documentID,
 	}
 
-	// Construir la operación de actualización
+	// La marca de tiempo del servidor es la hora de commit de la escritura
+	commitTime := utils.GetCommitTimeOrNow(ctx)
 	updateDoc := map[string]interface{}{
 		"$set": map[string]interface{}{
-			fmt.Sprintf("fields.%s.value", field):      time.Now(),
+			fmt.Sprintf("fields.%s.value", field):      commitTime,
 			fmt.Sprintf("fields.%s.value_type", field): model.FieldTypeTimestamp,
-			"update_time": time.Now(),
+			"update_time": commitTime,
 		},
 	}
 	// Ejecutar la operación atómica de marca de tiempo del servidor
@@ -199,7 +200,7 @@ func (a *AtomicOperations) AtomicDelete(ctx context.Context, projectID, database
 	updateDoc := map[string]interface{}{
 		"$unset": unsetFields,
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la eliminación atómica de campos
@@ -237,7 +238,7 @@ func (a *AtomicOperations) AtomicSetIfEmpty(ctx context.Context, projectID, data
 		"$set": map[string]interface{}{
 			fmt.Sprintf("fields.%s.value", field):      value.Value,
 			fmt.Sprintf("fields.%s.value_type", field): value.ValueType,
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la operación de establecimiento condicional
@@ -268,7 +269,7 @@ func (a *AtomicOperations) AtomicMaximum(ctx context.Context, projectID, databas
 			fmt.Sprintf("fields.%s.value", field): value,
 		},
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la operación atómica de máximo
@@ -299,7 +300,7 @@ func (a *AtomicOperations) AtomicMinimum(ctx context.Context, projectID, databas
 			fmt.Sprintf("fields.%s.value", field): value,
 		},
 		"$set": map[string]interface{}{
-			"update_time": time.Now(),
+			"update_time": utils.GetCommitTimeOrNow(ctx),
 		},
 	}
 	// Ejecutar la operación atómica de mínimo
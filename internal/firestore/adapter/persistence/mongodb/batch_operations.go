@@ -8,6 +8,7 @@ import (
 
 	"firestore-clone/internal/firestore/domain/model"
 	"firestore-clone/internal/shared/eventbus"
+	"firestore-clone/internal/shared/utils"
 )
 
 // BatchOperations handles batch write operations
@@ -41,7 +42,7 @@ func (b *BatchOperations) RunBatchWrite(ctx context.Context, projectID, database
 }
 
 func (b *BatchOperations) executeBatchOperation(ctx context.Context, projectID, databaseID string, write *model.WriteOperation) (*model.WriteResult, error) {
-	now := time.Now()
+	now := utils.GetCommitTimeOrNow(ctx)
 	pathParts := strings.Split(strings.Trim(write.Path, "/"), "/")
 	if len(pathParts) < 2 {
 		return nil, fmt.Errorf("invalid document path: %s", write.Path)
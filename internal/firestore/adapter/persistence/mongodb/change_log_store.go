@@ -0,0 +1,168 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeLogStore is the durable per-database changelog in the master database. Sequences
+// are handed out by an atomic counter per database, so several server instances can
+// append to and read from the same changelog. Appended in a transaction, the counter stays
+// locked until the transaction commits, so entries become visible in sequence order.
+// Changelogs are keyed by organization as well as by project and database.
+type ChangeLogStore struct {
+	entries *mongo.Collection
+	states  *mongo.Collection
+}
+
+// NewChangeLogStore creates a changelog over the document_changelog collections
+func NewChangeLogStore(db *mongo.Database) *ChangeLogStore {
+	return &ChangeLogStore{
+		entries: db.Collection("document_changelog"),
+		states:  db.Collection("document_changelog_state"),
+	}
+}
+
+// EnsureIndexes creates the sequence and retention indexes
+func (s *ChangeLogStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.entries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_changelog_organization_database_sequence_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "recorded_at", Value: 1}},
+			Options: options.Index().SetName("idx_changelog_recorded_at"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create changelog indexes: %w", err)
+	}
+	_, err = s.states.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("idx_changelog_state_organization_database_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create changelog state indexes: %w", err)
+	}
+	return nil
+}
+
+func changeLogFilter(organizationID, projectID, databaseID string) bson.M {
+	return withOrganization(bson.M{"project_id": projectID, "database_id": databaseID}, organizationID)
+}
+
+// Append reserves a block of sequences of the changelog of the organization in the
+// context and inserts the entries with them
+func (s *ChangeLogStore) Append(ctx context.Context, projectID, databaseID string, entries []*model.ChangeLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	var state model.ChangeLogState
+	err := s.states.FindOneAndUpdate(ctx,
+		changeLogFilter(organizationID, projectID, databaseID),
+		bson.M{
+			"$inc":         bson.M{"last_sequence": int64(len(entries))},
+			"$setOnInsert": bson.M{"pruned_through": int64(0)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&state)
+	if err != nil {
+		return fmt.Errorf("failed to reserve changelog sequences: %w", err)
+	}
+
+	first := state.LastSequence - int64(len(entries)) + 1
+	documents := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		entry.OrganizationID = organizationID
+		entry.Sequence = first + int64(i)
+		documents[i] = entry
+	}
+	if _, err := s.entries.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to append to the changelog: %w", err)
+	}
+	return nil
+}
+
+// ReadAfter returns the entries following a sequence in sequence order
+func (s *ChangeLogStore) ReadAfter(ctx context.Context, projectID, databaseID string, after int64, limit int) ([]*model.ChangeLogEntry, error) {
+	filter := changeLogFilter(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID)
+	filter["sequence"] = bson.M{"$gt": after}
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := s.entries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the changelog: %w", err)
+	}
+	entries := make([]*model.ChangeLogEntry, 0)
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode changelog entries: %w", err)
+	}
+	return entries, nil
+}
+
+// GetState returns the sequence state of a database
+func (s *ChangeLogStore) GetState(ctx context.Context, projectID, databaseID string) (*model.ChangeLogState, error) {
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	var state model.ChangeLogState
+	err := s.states.FindOne(ctx, changeLogFilter(organizationID, projectID, databaseID)).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &model.ChangeLogState{OrganizationID: organizationID, ProjectID: projectID, DatabaseID: databaseID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changelog state: %w", err)
+	}
+	return &state, nil
+}
+
+// Prune removes, per database, the entries up to the last one recorded before cutoff
+func (s *ChangeLogStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	cursor, err := s.states.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list changelog states: %w", err)
+	}
+	var states []*model.ChangeLogState
+	if err := cursor.All(ctx, &states); err != nil {
+		return 0, fmt.Errorf("failed to decode changelog states: %w", err)
+	}
+
+	removed := 0
+	for _, state := range states {
+		filter := changeLogFilter(state.OrganizationID, state.ProjectID, state.DatabaseID)
+		filter["recorded_at"] = bson.M{"$lt": cutoff}
+		var newest model.ChangeLogEntry
+		err := s.entries.FindOne(ctx, filter, options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})).Decode(&newest)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to find expired changelog entries: %w", err)
+		}
+
+		// Advance the pruned sequence first, so readers never miss entries silently
+		_, err = s.states.UpdateOne(ctx, changeLogFilter(state.OrganizationID, state.ProjectID, state.DatabaseID), bson.M{"$max": bson.M{"pruned_through": newest.Sequence}})
+		if err != nil {
+			return removed, fmt.Errorf("failed to update changelog state: %w", err)
+		}
+		deleteFilter := changeLogFilter(state.OrganizationID, state.ProjectID, state.DatabaseID)
+		deleteFilter["sequence"] = bson.M{"$lte": newest.Sequence}
+		result, err := s.entries.DeleteMany(ctx, deleteFilter)
+		if err != nil {
+			return removed, fmt.Errorf("failed to prune the changelog: %w", err)
+		}
+		removed += int(result.DeletedCount)
+	}
+	return removed, nil
+}
@@ -0,0 +1,421 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/logger"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// resumeTokenSaveInterval bounds how often the resume token is written while events
+	// stream in; after a crash at most this much is replayed
+	resumeTokenSaveInterval = time.Second
+	maxChangeStreamBackoff  = 30 * time.Second
+	// changeStreamCommitWait is how long the server waits for more events before answering
+	// an empty batch; a commit is handed over once no more of its events arrive in that time
+	changeStreamCommitWait = 100 * time.Millisecond
+
+	// Server error codes of a resume token that fell off the oplog
+	errorCodeChangeStreamHistoryLost = 286
+	errorCodeChangeStreamFatal       = 280
+)
+
+// tenantMetadataCollections hold the tenant metadata, not Firestore documents
+var tenantMetadataCollections = map[string]bool{
+	"projects":    true,
+	"databases":   true,
+	"collections": true,
+	"indexes":     true,
+}
+
+// changeStreamEvent is the part of a change stream event used to rebuild a document change
+type changeStreamEvent struct {
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Namespace     struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey              bson.M             `bson:"documentKey"`
+	FullDocument             *MongoDocumentFlat `bson:"fullDocument"`
+	FullDocumentBeforeChange *MongoDocumentFlat `bson:"fullDocumentBeforeChange"`
+	// Session and transaction number of the writes made in a transaction
+	LSID      bson.Raw `bson:"lsid"`
+	TxnNumber *int64   `bson:"txnNumber"`
+}
+
+// changeStreamCommit collects the consecutive document changes of one commit
+type changeStreamCommit struct {
+	key     string
+	changes []*model.DocumentChange
+}
+
+// changeStreamToken is the persisted resume token of a change stream
+type changeStreamToken struct {
+	StreamID  string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// ChangeStreamSource tails a MongoDB change stream over every tenant database and hands
+// the document changes to a handler, so that each server instance sees the writes handled
+// by all instances. Pre- and post-images are enabled on the document collections so that
+// updates carry the previous document and deletes can be attributed to a path; deletes in
+// collections without pre-images are skipped. The resume token is persisted in the master
+// database under the stream ID, so a restarted instance resumes where it stopped.
+type ChangeStreamSource struct {
+	client         *mongo.Client
+	tokens         *mongo.Collection
+	databasePrefix string
+	streamID       string
+	handler        usecase.DocumentChangeHandler
+	logger         logger.Logger
+
+	preImages map[string]bool // db.collection -> pre- and post-images enabled
+
+	stop  context.CancelFunc
+	done  chan struct{}
+	runMu sync.Mutex
+}
+
+// NewChangeStreamSource creates a change stream source over the databases named with the
+// tenant database prefix
+func NewChangeStreamSource(client *mongo.Client, masterDB *mongo.Database, databasePrefix, streamID string, handler usecase.DocumentChangeHandler, log logger.Logger) *ChangeStreamSource {
+	return &ChangeStreamSource{
+		client:         client,
+		tokens:         masterDB.Collection("change_stream_tokens"),
+		databasePrefix: databasePrefix,
+		streamID:       streamID,
+		handler:        handler,
+		logger:         log,
+		preImages:      make(map[string]bool),
+	}
+}
+
+// Start tails the change stream in the background until Stop is called, reopening it
+// with backoff after errors
+func (s *ChangeStreamSource) Start(ctx context.Context) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.stop = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+	s.logger.Info("MongoDB change stream source started", "stream", s.streamID, "databasePrefix", s.databasePrefix)
+}
+
+// Stop stops tailing and waits for the event in progress
+func (s *ChangeStreamSource) Stop() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	<-s.done
+	s.stop = nil
+}
+
+func (s *ChangeStreamSource) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	s.enableExistingPreImages(ctx)
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := s.tail(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if isChangeStreamHistoryLost(err) {
+			s.logger.Error("Change stream resume token is no longer in the oplog; events since the last token are lost", "stream", s.streamID, "error", err)
+			s.deleteResumeToken(ctx)
+		} else {
+			s.logger.Warn("Change stream interrupted, reopening", "stream", s.streamID, "error", err, "backoff", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxChangeStreamBackoff {
+			backoff = maxChangeStreamBackoff
+		}
+	}
+}
+
+// tail opens the change stream from the persisted resume token and handles its events
+func (s *ChangeStreamSource) tail(ctx context.Context) error {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{
+		{Key: "ns.db", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(s.databasePrefix)}}},
+		{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update", "replace", "delete"}}}},
+	}}}}
+	opts := options.ChangeStream().
+		SetFullDocument(options.WhenAvailable).
+		SetFullDocumentBeforeChange(options.WhenAvailable).
+		SetMaxAwaitTime(changeStreamCommitWait)
+	if token := s.loadResumeToken(ctx); token != nil {
+		opts.SetStartAfter(token)
+	}
+
+	stream, err := s.client.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	// The changes of a commit are handed over together, once the next event belongs to
+	// another commit or no more events arrive. The resume token is only saved between
+	// commits, so a restart never replays part of one.
+	var commit changeStreamCommit
+	var token bson.Raw
+	lastSaved := time.Now()
+	flush := func(ctx context.Context, next bson.Raw) {
+		if len(commit.changes) > 0 {
+			s.handler.HandleChanges(ctx, commit.changes)
+		}
+		commit = changeStreamCommit{}
+		token = next
+		if time.Since(lastSaved) >= resumeTokenSaveInterval {
+			s.saveResumeToken(ctx, token)
+			lastSaved = time.Now()
+		}
+	}
+	defer func() { s.saveResumeToken(context.Background(), token) }()
+	for {
+		// Pending changes are only waited on briefly; without them the stream blocks
+		previous := stream.ResumeToken()
+		if len(commit.changes) > 0 {
+			if !stream.TryNext(ctx) {
+				flush(ctx, stream.ResumeToken())
+				if stream.Err() != nil || ctx.Err() != nil || stream.ID() == 0 {
+					return stream.Err()
+				}
+				continue
+			}
+		} else if !stream.Next(ctx) {
+			return stream.Err()
+		}
+
+		var event changeStreamEvent
+		var change *model.DocumentChange
+		if err := stream.Decode(&event); err != nil {
+			s.logger.Warn("Failed to decode change stream event", "error", err)
+		} else {
+			change = s.documentChange(ctx, &event)
+		}
+		if change == nil {
+			if len(commit.changes) == 0 {
+				token = stream.ResumeToken()
+			}
+			continue
+		}
+		key := commitKey(&event, change)
+		if len(commit.changes) > 0 && (key == "" || key != commit.key) {
+			// The token before this event resumes after the previous commit
+			flush(ctx, previous)
+		}
+		commit.key = key
+		commit.add(change)
+		if key == "" {
+			flush(ctx, stream.ResumeToken())
+		}
+	}
+}
+
+// commitKey identifies the commit of a change: the transaction it was written in, or else
+// the commit time stored on the document by the write. Deletes outside a transaction
+// leave no commit time behind and are handed over on their own.
+func commitKey(event *changeStreamEvent, change *model.DocumentChange) string {
+	if event.TxnNumber != nil && len(event.LSID) > 0 {
+		return fmt.Sprintf("txn %s %d", event.LSID.String(), *event.TxnNumber)
+	}
+	if change.After != nil && !change.After.UpdateTime.IsZero() {
+		return fmt.Sprintf("time %s/%s %s", change.ProjectID, change.DatabaseID, change.After.UpdateTime.UTC().Format(time.RFC3339Nano))
+	}
+	return ""
+}
+
+// add appends a change to the commit; all its changes carry the commit time stored on the
+// written documents, which deletes do not have
+func (c *changeStreamCommit) add(change *model.DocumentChange) {
+	c.changes = append(c.changes, change)
+	var commitTime time.Time
+	for _, change := range c.changes {
+		if change.After != nil && !change.After.UpdateTime.IsZero() {
+			commitTime = change.After.UpdateTime
+			break
+		}
+	}
+	if commitTime.IsZero() {
+		return
+	}
+	for _, change := range c.changes {
+		change.CommitTime = commitTime
+	}
+}
+
+// documentChange rebuilds the document change of a change stream event, or returns nil
+// for writes that are not Firestore documents
+func (s *ChangeStreamSource) documentChange(ctx context.Context, event *changeStreamEvent) *model.DocumentChange {
+	if tenantMetadataCollections[event.Namespace.Collection] {
+		return nil
+	}
+	if event.OperationType == "insert" {
+		s.enablePreImages(ctx, event.Namespace.Database, event.Namespace.Collection)
+	}
+
+	var before, after *model.Document
+	if event.FullDocumentBeforeChange != nil {
+		before = existingDocument(mongoFlatToModelDocument(event.FullDocumentBeforeChange))
+	}
+	if event.OperationType != "delete" {
+		flat := event.FullDocument
+		if flat == nil {
+			// Post-images are not enabled yet on this collection; read the current version
+			flat = s.lookupDocument(ctx, event)
+		}
+		if flat != nil {
+			after = existingDocument(mongoFlatToModelDocument(flat))
+		}
+	}
+
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+	if snapshot == nil {
+		if event.OperationType == "delete" {
+			s.logger.Debug("Skipping delete without pre-image", "database", event.Namespace.Database, "collection", event.Namespace.Collection)
+		}
+		return nil
+	}
+	index := strings.Index(snapshot.Path, "/documents/")
+	if index < 0 || snapshot.DocumentID == "" {
+		return nil
+	}
+
+	change := &model.DocumentChange{
+		ProjectID:    snapshot.ProjectID,
+		DatabaseID:   snapshot.DatabaseID,
+		DocumentPath: snapshot.Path[index+len("/documents/"):],
+		Before:       before,
+		After:        after,
+		CommitTime:   time.Unix(int64(event.ClusterTime.T), 0),
+	}
+	if after != nil && !after.UpdateTime.IsZero() {
+		change.CommitTime = after.UpdateTime
+	}
+	return change
+}
+
+// existingDocument returns nil for tombstones
+func existingDocument(doc *model.Document) *model.Document {
+	if doc == nil || !doc.Exists {
+		return nil
+	}
+	return doc
+}
+
+func (s *ChangeStreamSource) lookupDocument(ctx context.Context, event *changeStreamEvent) *MongoDocumentFlat {
+	if len(event.DocumentKey) == 0 {
+		return nil
+	}
+	var flat MongoDocumentFlat
+	collection := s.client.Database(event.Namespace.Database).Collection(event.Namespace.Collection)
+	if err := collection.FindOne(ctx, event.DocumentKey).Decode(&flat); err != nil {
+		return nil
+	}
+	return &flat
+}
+
+// enableExistingPreImages enables pre- and post-images on the document collections of
+// the tenant databases that already exist
+func (s *ChangeStreamSource) enableExistingPreImages(ctx context.Context) {
+	databases, err := s.client.ListDatabaseNames(ctx, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(s.databasePrefix)}})
+	if err != nil {
+		s.logger.Warn("Failed to list tenant databases for change stream pre-images", "error", err)
+		return
+	}
+	for _, database := range databases {
+		collections, err := s.client.Database(database).ListCollectionNames(ctx, bson.M{})
+		if err != nil {
+			s.logger.Warn("Failed to list tenant collections for change stream pre-images", "database", database, "error", err)
+			continue
+		}
+		for _, collection := range collections {
+			if !tenantMetadataCollections[collection] {
+				s.enablePreImages(ctx, database, collection)
+			}
+		}
+	}
+}
+
+// enablePreImages turns on pre- and post-images for a collection once; it requires
+// MongoDB 6.0 or later
+func (s *ChangeStreamSource) enablePreImages(ctx context.Context, database, collection string) {
+	namespace := database + "." + collection
+	if s.preImages[namespace] {
+		return
+	}
+	s.preImages[namespace] = true
+	err := s.client.Database(database).RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "changeStreamPreAndPostImages", Value: bson.D{{Key: "enabled", Value: true}}},
+	}).Err()
+	if err != nil {
+		s.logger.Warn("Failed to enable change stream pre-images; updates will lack previous data", "namespace", namespace, "error", err)
+	}
+}
+
+func (s *ChangeStreamSource) loadResumeToken(ctx context.Context) bson.Raw {
+	var token changeStreamToken
+	if err := s.tokens.FindOne(ctx, bson.M{"_id": s.streamID}).Decode(&token); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			s.logger.Warn("Failed to load change stream resume token", "stream", s.streamID, "error", err)
+		}
+		return nil
+	}
+	return token.Token
+}
+
+func (s *ChangeStreamSource) saveResumeToken(ctx context.Context, token bson.Raw) {
+	if token == nil {
+		return
+	}
+	_, err := s.tokens.ReplaceOne(ctx,
+		bson.M{"_id": s.streamID},
+		changeStreamToken{StreamID: s.streamID, Token: token, UpdatedAt: time.Now()},
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		s.logger.Warn("Failed to save change stream resume token", "stream", s.streamID, "error", err)
+	}
+}
+
+func (s *ChangeStreamSource) deleteResumeToken(ctx context.Context) {
+	if _, err := s.tokens.DeleteOne(ctx, bson.M{"_id": s.streamID}); err != nil {
+		s.logger.Warn("Failed to delete change stream resume token", "stream", s.streamID, "error", err)
+	}
+}
+
+// isChangeStreamHistoryLost reports errors that resuming from the same token cannot fix
+func isChangeStreamHistoryLost(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) &&
+		(serverErr.HasErrorCode(errorCodeChangeStreamHistoryLost) || serverErr.HasErrorCode(errorCodeChangeStreamFatal))
+}
@@ -0,0 +1,47 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCommitKey_GroupsByTransactionThenCommitTime(t *testing.T) {
+	commitTime := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	written := &model.DocumentChange{ProjectID: "p", DatabaseID: "d", DocumentPath: "users/a", After: &model.Document{UpdateTime: commitTime}}
+	deleted := &model.DocumentChange{ProjectID: "p", DatabaseID: "d", DocumentPath: "users/b", Before: &model.Document{UpdateTime: commitTime.Add(-time.Hour)}}
+
+	lsid, err := bson.Marshal(bson.M{"id": "session"})
+	assert.NoError(t, err)
+	txnNumber := int64(7)
+	inTransaction := &changeStreamEvent{LSID: lsid, TxnNumber: &txnNumber}
+
+	// Writes of one transaction share a key, deletes included
+	assert.NotEmpty(t, commitKey(inTransaction, written))
+	assert.Equal(t, commitKey(inTransaction, written), commitKey(inTransaction, deleted))
+
+	// Outside a transaction, writes with the same stored commit time share a key
+	sameTime := &model.DocumentChange{ProjectID: "p", DatabaseID: "d", DocumentPath: "users/c", After: &model.Document{UpdateTime: commitTime}}
+	assert.Equal(t, commitKey(&changeStreamEvent{}, written), commitKey(&changeStreamEvent{}, sameTime))
+	later := &model.DocumentChange{ProjectID: "p", DatabaseID: "d", DocumentPath: "users/c", After: &model.Document{UpdateTime: commitTime.Add(time.Millisecond)}}
+	assert.NotEqual(t, commitKey(&changeStreamEvent{}, written), commitKey(&changeStreamEvent{}, later))
+
+	// Deletes outside a transaction stand alone
+	assert.Empty(t, commitKey(&changeStreamEvent{}, deleted))
+}
+
+func TestChangeStreamCommit_ChangesShareTheStoredCommitTime(t *testing.T) {
+	commitTime := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	var commit changeStreamCommit
+	commit.add(&model.DocumentChange{DocumentPath: "users/a", Before: &model.Document{}, CommitTime: time.Unix(commitTime.Unix()-1, 0)})
+	commit.add(&model.DocumentChange{DocumentPath: "users/b", After: &model.Document{UpdateTime: commitTime}, CommitTime: commitTime})
+
+	assert.Len(t, commit.changes, 2)
+	for _, change := range commit.changes {
+		assert.Equal(t, commitTime, change.CommitTime, change.DocumentPath)
+	}
+}
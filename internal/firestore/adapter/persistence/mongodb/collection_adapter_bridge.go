@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"context"
+	"fmt"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -68,9 +69,41 @@ func NewDocumentCollectionAdapter(col CollectionInterface) *DocumentCollectionAd
 }
 
 func (a *DocumentCollectionAdapter) Indexes() IndexManager {
-	// Not implemented: you may need to adapt this if you use index management
+	// Only collections backed by a real MongoDB collection support index management
+	if mongoCol, ok := a.col.(*MongoCollectionAdapter); ok && mongoCol.col != nil {
+		return &MongoIndexManagerAdapter{view: mongoCol.col.Indexes()}
+	}
 	return nil
 }
 func (a *DocumentCollectionAdapter) CountDocuments(ctx context.Context, filter interface{}) (int64, error) {
 	return a.col.CountDocuments(ctx, filter)
 }
+
+// --- Adapter: mongo.IndexView -> IndexManager (for IndexOperations) ---
+type MongoIndexManagerAdapter struct {
+	view mongo.IndexView
+}
+
+func (a *MongoIndexManagerAdapter) CreateOne(ctx context.Context, model interface{}) (interface{}, error) {
+	indexModel, ok := model.(mongo.IndexModel)
+	if !ok {
+		return nil, fmt.Errorf("unsupported index model type %T", model)
+	}
+	return a.view.CreateOne(ctx, indexModel)
+}
+
+func (a *MongoIndexManagerAdapter) DropOne(ctx context.Context, name string) (interface{}, error) {
+	return a.view.DropOne(ctx, name)
+}
+
+func (a *MongoIndexManagerAdapter) ListSpecifications(ctx context.Context) ([]IndexSpec, error) {
+	specs, err := a.view.ListSpecifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]IndexSpec, 0, len(specs))
+	for _, spec := range specs {
+		result = append(result, IndexSpec{Name: spec.Name})
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/database"
+	sharederrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionSchemaStore persists collection schemas in the database of the organization
+// in the request context, next to its collection metadata, so every instance enforces the
+// same schemas and they survive restarts
+type CollectionSchemaStore struct {
+	tenantManager *database.TenantManager
+
+	// Organizations whose schema indexes were already created by this instance
+	indexed sync.Map
+}
+
+// NewCollectionSchemaStore creates a tenant-aware collection schema store
+func NewCollectionSchemaStore(tenantManager *database.TenantManager) *CollectionSchemaStore {
+	return &CollectionSchemaStore{tenantManager: tenantManager}
+}
+
+// schemas returns the collection_schemas collection of the organization in the context
+func (s *CollectionSchemaStore) schemas(ctx context.Context) (*mongo.Collection, error) {
+	organizationID, err := utils.GetOrganizationIDFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("organization ID required: %w", err)
+	}
+	db, err := s.tenantManager.GetDatabaseForOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization database: %w", err)
+	}
+	collection := db.Collection("collection_schemas")
+
+	if _, done := s.indexed.Load(organizationID); !done {
+		_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "schema_id", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("idx_schemas_database_schema_unique"),
+			},
+			{
+				Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "collection_pattern", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("idx_schemas_database_pattern_unique"),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collection schema indexes: %w", err)
+		}
+		s.indexed.Store(organizationID, true)
+	}
+	return collection, nil
+}
+
+func schemaFilter(projectID, databaseID, schemaID string) bson.M {
+	return bson.M{"project_id": projectID, "database_id": databaseID, "schema_id": schemaID}
+}
+
+// SaveSchema inserts or replaces a schema. Two schemas cannot share a collection pattern.
+func (s *CollectionSchemaStore) SaveSchema(ctx context.Context, schema *model.CollectionSchema) error {
+	collection, err := s.schemas(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = collection.ReplaceOne(ctx, schemaFilter(schema.ProjectID, schema.DatabaseID, schema.SchemaID), schema, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return sharederrors.NewConflictError(fmt.Sprintf("a schema for collection pattern %s already exists", schema.CollectionPattern))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save collection schema: %w", err)
+	}
+	return nil
+}
+
+// GetSchema returns a stored schema
+func (s *CollectionSchemaStore) GetSchema(ctx context.Context, projectID, databaseID, schemaID string) (*model.CollectionSchema, error) {
+	collection, err := s.schemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var schema model.CollectionSchema
+	err = collection.FindOne(ctx, schemaFilter(projectID, databaseID, schemaID)).Decode(&schema)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sharederrors.NewNotFoundError("collection schema")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// ListSchemas returns the schemas of a database ordered by pattern
+func (s *CollectionSchemaStore) ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error) {
+	collection, err := s.schemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := collection.Find(ctx, bson.M{"project_id": projectID, "database_id": databaseID}, options.Find().SetSort(bson.D{{Key: "collection_pattern", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collection schemas: %w", err)
+	}
+	schemas := make([]*model.CollectionSchema, 0)
+	if err := cursor.All(ctx, &schemas); err != nil {
+		return nil, fmt.Errorf("failed to decode collection schemas: %w", err)
+	}
+	return schemas, nil
+}
+
+// DeleteSchema removes a stored schema
+func (s *CollectionSchemaStore) DeleteSchema(ctx context.Context, projectID, databaseID, schemaID string) error {
+	collection, err := s.schemas(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := collection.DeleteOne(ctx, schemaFilter(projectID, databaseID, schemaID))
+	if err != nil {
+		return fmt.Errorf("failed to delete collection schema: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return sharederrors.NewNotFoundError("collection schema")
+	}
+	return nil
+}
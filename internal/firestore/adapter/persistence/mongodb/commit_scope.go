@@ -0,0 +1,60 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"firestore-clone/internal/shared/logger"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TransactionalCommitScope runs a document write and the deliveries queued for it in one
+// MongoDB transaction. Transactions need a replica set: against a standalone server the
+// scope logs a warning once and runs the writes without a transaction.
+type TransactionalCommitScope struct {
+	client      *mongo.Client
+	logger      logger.Logger
+	unsupported atomic.Bool
+}
+
+// NewTransactionalCommitScope creates a commit scope over the client shared by the
+// organization databases and the master database
+func NewTransactionalCommitScope(client *mongo.Client, log logger.Logger) *TransactionalCommitScope {
+	return &TransactionalCommitScope{client: client, logger: log}
+}
+
+// Atomically implements usecase.CommitScope
+func (s *TransactionalCommitScope) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.unsupported.Load() {
+		return fn(ctx)
+	}
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext)
+	})
+	if err != nil && transactionsUnsupported(err) {
+		// The first operation was refused, so nothing was written yet
+		if !s.unsupported.Swap(true) {
+			s.logger.Warn("MongoDB transactions not supported, document writes and their deliveries are no longer committed together", "error", err)
+		}
+		return fn(ctx)
+	}
+	return err
+}
+
+// transactionsUnsupported reports the errors of a server without transaction support
+func transactionsUnsupported(err error) bool {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == 20 { // IllegalOperation
+		return true
+	}
+	return strings.Contains(err.Error(), "Transaction numbers are only allowed")
+}
@@ -0,0 +1,119 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeliveredDocumentEventRetention is how long dispatched document events are kept before
+// MongoDB expires them. Dead-lettered events are kept for inspection.
+const DeliveredDocumentEventRetention = 24 * time.Hour
+
+// DocumentEventOutbox is the persistent document event outbox in the master database.
+// Events are claimed atomically, so several server instances can share the outbox.
+type DocumentEventOutbox struct {
+	collection *mongo.Collection
+}
+
+// NewDocumentEventOutbox creates an outbox over the document_event_outbox collection
+func NewDocumentEventOutbox(db *mongo.Database) *DocumentEventOutbox {
+	return &DocumentEventOutbox{collection: db.Collection("document_event_outbox")}
+}
+
+// EnsureIndexes creates the claim and listing indexes and expires dispatched events
+func (o *DocumentEventOutbox) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_document_events_event_id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "state", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("idx_document_events_state_next_attempt"),
+		},
+		{
+			Keys:    bson.D{{Key: "delivered_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(DeliveredDocumentEventRetention.Seconds())).SetName("idx_document_events_delivered_at_ttl"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create document event outbox indexes: %w", err)
+	}
+	return nil
+}
+
+// Enqueue inserts the events
+func (o *DocumentEventOutbox) Enqueue(ctx context.Context, events []*model.DocumentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(events))
+	for i, event := range events {
+		documents[i] = event
+	}
+	if _, err := o.collection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to record document events: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue leases the oldest pending events due at now, one atomic update each
+func (o *DocumentEventOutbox) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.DocumentEvent, error) {
+	filter := bson.M{"state": model.TriggerDeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	claimed := make([]*model.DocumentEvent, 0, limit)
+	for len(claimed) < limit {
+		var event model.DocumentEvent
+		err := o.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&event)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim document event: %w", err)
+		}
+		claimed = append(claimed, &event)
+	}
+	return claimed, nil
+}
+
+// SaveEvent replaces an event with its new state
+func (o *DocumentEventOutbox) SaveEvent(ctx context.Context, event *model.DocumentEvent) error {
+	_, err := o.collection.ReplaceOne(ctx, bson.M{"event_id": event.EventID}, event, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save document event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns the events in a state, newest first
+func (o *DocumentEventOutbox) ListEvents(ctx context.Context, state model.TriggerDeliveryState, limit int) ([]*model.DocumentEvent, error) {
+	filter := bson.M{}
+	if state != "" {
+		filter["state"] = state
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := o.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document events: %w", err)
+	}
+	events := make([]*model.DocumentEvent, 0)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode document events: %w", err)
+	}
+	return events, nil
+}
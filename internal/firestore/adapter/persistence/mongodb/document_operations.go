@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/utils"
 	"fmt"
 	"strings"
 	"time"
@@ -165,6 +166,14 @@ func flattenFieldsForMongoDB(fields map[string]*model.FieldValue) map[string]int
 					},
 				}
 			}
+		case model.FieldTypeGeoPoint:
+			if point, ok := model.GeoPointFromValue(fieldValue.Value); ok {
+				result[key] = flattenGeoPoint(point)
+			}
+		case model.FieldTypeVector:
+			if vector, ok := model.VectorFromValue(fieldValue.Value); ok {
+				result[key] = flattenVector(vector)
+			}
 		default:
 			// Para tipos no manejados, guardar tal como está
 			result[key] = fieldValue.Value
@@ -261,6 +270,20 @@ func expandFieldsFromMongoDB(flatFields map[string]interface{}) map[string]*mode
 						}
 					}
 				}
+			} else if geoVal, exists := valueMap["geoPointValue"]; exists {
+				if point, ok := model.GeoPointFromValue(geoVal); ok {
+					result[key] = &model.FieldValue{
+						ValueType: model.FieldTypeGeoPoint,
+						Value:     point,
+					}
+				}
+			} else if vectorVal, exists := valueMap["vectorValue"]; exists {
+				if vector, ok := model.VectorFromValue(vectorVal); ok {
+					result[key] = &model.FieldValue{
+						ValueType: model.FieldTypeVector,
+						Value:     vector,
+					}
+				}
 			} else if arrayVal, exists := valueMap["arrayValue"]; exists {
 				// Debug: Log array processing
 				fmt.Printf("[DEBUG expandFieldsFromMongoDB] Found arrayValue for field '%s': %+v\n", key, arrayVal)
@@ -610,7 +633,7 @@ func mongoFlatToModelDocument(mongoDoc *MongoDocumentFlat) *model.Document {
 
 // CreateDocument creates a new document
 func (ops *DocumentOperations) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
-	now := time.Now()
+	now := utils.GetCommitTimeOrNow(ctx)
 
 	// Generate a new document ID if not provided
 	if documentID == "" {
@@ -670,7 +693,7 @@ func (ops *DocumentOperations) UpdateDocument(ctx context.Context, projectID, da
 	update := bson.M{
 		"$set": bson.M{
 			"fields":     flattenedFields,
-			"updateTime": time.Now(),
+			"updateTime": utils.GetCommitTimeOrNow(ctx),
 		},
 		"$inc": bson.M{
 			"version": 1,
@@ -694,7 +717,7 @@ func (ops *DocumentOperations) UpdateDocument(ctx context.Context, projectID, da
 
 // SetDocument sets (creates or updates) a document
 func (ops *DocumentOperations) SetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) (*model.Document, error) {
-	now := time.Now()
+	now := utils.GetCommitTimeOrNow(ctx)
 
 	// Generate a new document ID if not provided
 	if documentID == "" {
@@ -15,10 +15,11 @@ import (
 	"firestore-clone/internal/shared/utils"
 )
 
-// ErrDocumentNotFound is returned when a document is not found.
+// ErrDocumentNotFound is returned when a document is not found. The not found errors are
+// the shared ones, so callers can detect them with errors.IsNotFound.
 var (
-	ErrDocumentNotFound   = errors.New("document not found")
-	ErrCollectionNotFound = errors.New("collection not found")
+	ErrDocumentNotFound   = sharedErrors.ErrDocumentNotFound
+	ErrCollectionNotFound = sharedErrors.ErrCollectionNotFound
 	ErrInvalidPath        = errors.New("invalid document path")
 	ErrPreconditionFailed = errors.New("precondition failed")
 )
@@ -71,6 +72,10 @@ func NewDocumentRepository(db DatabaseProvider, eventBus *eventbus.EventBus, log
 		NewDocumentCollectionAdapter(repo.documentsCol),
 		repo.logger,
 	)
+	// Documents are stored per collection, so indexes must be created there
+	repo.indexOps.collectionFor = func(collectionID string) DocumentCollection {
+		return NewDocumentCollectionAdapter(repo.db.Collection(collectionID))
+	}
 	return repo
 }
 
@@ -731,6 +731,9 @@ func (e *EnhancedMongoQueryEngine) determineFirestoreFieldType(fieldMap bson.M)
 	if _, exists := fieldMap["geoPointValue"]; exists {
 		return model.FieldTypeGeoPoint
 	}
+	if _, exists := fieldMap["vectorValue"]; exists {
+		return model.FieldTypeVector
+	}
 
 	// Default fallback
 	return model.FieldTypeString
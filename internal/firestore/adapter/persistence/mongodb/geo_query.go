@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"fmt"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// geoJSONKey holds a GeoJSON copy of every stored GeoPoint, next to its geoPointValue.
+// $geoWithin and 2dsphere indexes work on GeoJSON, which expects [longitude, latitude].
+const geoJSONKey = "geoJson"
+
+// flattenGeoPoint builds the stored representation of a GeoPoint field
+func flattenGeoPoint(point *model.GeoPoint) map[string]interface{} {
+	return map[string]interface{}{
+		"geoPointValue": map[string]interface{}{
+			"latitude":  point.Latitude,
+			"longitude": point.Longitude,
+		},
+		geoJSONKey: map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []float64{point.Longitude, point.Latitude},
+		},
+	}
+}
+
+// nestedFieldMongoPath maps a field path to its stored location, following map values:
+// "address.location" -> "fields.address.mapValue.fields.location"
+func nestedFieldMongoPath(field string) (string, error) {
+	fieldPath, err := model.NewFieldPath(field)
+	if err != nil {
+		return "", fmt.Errorf("invalid field path %q: %w", field, err)
+	}
+	return "fields." + strings.Join(fieldPath.Segments(), ".mapValue.fields."), nil
+}
+
+// buildGeoFilter translates a geo query into a MongoDB filter. Radii use $geoWithin with
+// $centerSphere, which needs no index but uses a 2dsphere index when one exists; bounding
+// boxes are latitude/longitude ranges so they match the in-memory evaluation exactly.
+func buildGeoFilter(geo *model.GeoQuery) (bson.M, error) {
+	if err := geo.Validate(); err != nil {
+		return nil, err
+	}
+	base, err := nestedFieldMongoPath(geo.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []bson.M{{base + ".geoPointValue": bson.M{"$exists": true}}}
+	if geo.RadiusMeters > 0 {
+		conditions = append(conditions, bson.M{base + "." + geoJSONKey: bson.M{
+			"$geoWithin": bson.M{
+				"$centerSphere": bson.A{
+					bson.A{geo.Near.Longitude, geo.Near.Latitude},
+					geo.RadiusMeters / model.EarthRadiusMeters,
+				},
+			},
+		}})
+	}
+	if box := geo.BoundingBox; box != nil {
+		latPath := base + ".geoPointValue.latitude"
+		lngPath := base + ".geoPointValue.longitude"
+		conditions = append(conditions, bson.M{latPath: bson.M{"$gte": box.SouthWest.Latitude, "$lte": box.NorthEast.Latitude}})
+		if box.SouthWest.Longitude <= box.NorthEast.Longitude {
+			conditions = append(conditions, bson.M{lngPath: bson.M{"$gte": box.SouthWest.Longitude, "$lte": box.NorthEast.Longitude}})
+		} else {
+			// The box crosses the antimeridian
+			conditions = append(conditions, bson.M{"$or": []bson.M{
+				{lngPath: bson.M{"$gte": box.SouthWest.Longitude}},
+				{lngPath: bson.M{"$lte": box.NorthEast.Longitude}},
+			}})
+		}
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return bson.M{"$and": conditions}, nil
+}
+
+// geoFindQuery returns the query used to build the find options. When results are ordered
+// by distance, sorting and pagination happen after the matches are fetched.
+func geoFindQuery(query model.Query) model.Query {
+	if query.Geo != nil && query.Geo.OrderByDistance {
+		query.Orders = nil
+		query.Limit = 0
+		query.Offset = 0
+		query.LimitToLast = false
+	}
+	return query
+}
+
+// applyGeoOrdering orders documents nearest first and applies the query offset and limit
+func applyGeoOrdering(query model.Query, docs []*model.Document) []*model.Document {
+	if query.Geo == nil || !query.Geo.OrderByDistance {
+		return docs
+	}
+	docs = query.Geo.FilterAndSortDocuments(docs)
+	if query.Offset > 0 {
+		if query.Offset >= len(docs) {
+			return []*model.Document{}
+		}
+		docs = docs[query.Offset:]
+	}
+	if query.Limit > 0 && len(docs) > query.Limit {
+		docs = docs[:query.Limit]
+	}
+	return docs
+}
+
+// projectionCovers reports whether a projected path already includes path, to avoid path collisions
+func projectionCovers(projection bson.M, path string) bool {
+	for projected := range projection {
+		if projected == path || strings.HasPrefix(path, projected+".") {
+			return true
+		}
+	}
+	return false
+}
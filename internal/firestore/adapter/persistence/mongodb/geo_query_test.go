@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGeoPoint_RoundTripsThroughStorage(t *testing.T) {
+	point := &model.GeoPoint{Latitude: 48.8566, Longitude: 2.3522}
+	flat := flattenFieldsForMongoDB(map[string]*model.FieldValue{"location": model.NewFieldValue(point)})
+
+	stored := flat["location"].(map[string]interface{})
+	assert.Equal(t, []float64{2.3522, 48.8566}, stored[geoJSONKey].(map[string]interface{})["coordinates"])
+
+	expanded := expandFieldsFromMongoDB(flat)
+	require.Contains(t, expanded, "location")
+	assert.Equal(t, model.FieldTypeGeoPoint, expanded["location"].ValueType)
+	assert.Equal(t, point, expanded["location"].Value)
+}
+
+func TestBuildGeoFilter(t *testing.T) {
+	near := &model.GeoPoint{Latitude: 48.8566, Longitude: 2.3522}
+	filter, err := buildGeoFilter(&model.GeoQuery{Field: "address.location", Near: near, RadiusMeters: 5000})
+	require.NoError(t, err)
+
+	conditions := filter["$and"].([]bson.M)
+	require.Len(t, conditions, 2)
+	assert.Contains(t, conditions[0], "fields.address.mapValue.fields.location.geoPointValue")
+	within := conditions[1]["fields.address.mapValue.fields.location.geoJson"].(bson.M)["$geoWithin"].(bson.M)["$centerSphere"].(bson.A)
+	assert.Equal(t, bson.A{2.3522, 48.8566}, within[0])
+	assert.InDelta(t, 5000/model.EarthRadiusMeters, within[1], 1e-12)
+
+	// A bounding box crossing the antimeridian matches either side of it
+	filter, err = buildGeoFilter(&model.GeoQuery{Field: "location", BoundingBox: &model.GeoBoundingBox{
+		SouthWest: model.GeoPoint{Latitude: -20, Longitude: 170},
+		NorthEast: model.GeoPoint{Latitude: 0, Longitude: -170},
+	}})
+	require.NoError(t, err)
+	conditions = filter["$and"].([]bson.M)
+	require.Len(t, conditions, 3)
+	assert.Len(t, conditions[2]["$or"], 2)
+
+	_, err = buildGeoFilter(&model.GeoQuery{Field: "location", RadiusMeters: 10})
+	assert.ErrorIs(t, err, model.ErrInvalidGeoQuery)
+}
+
+func TestApplyGeoOrdering(t *testing.T) {
+	doc := func(id string, lat float64) *model.Document {
+		return &model.Document{DocumentID: id, Fields: map[string]*model.FieldValue{
+			"location": model.NewFieldValue(&model.GeoPoint{Latitude: lat, Longitude: 0}),
+		}}
+	}
+	docs := []*model.Document{doc("far", 0.03), doc("near", 0.01), doc("mid", 0.02)}
+	query := model.Query{Limit: 1, Offset: 1, Geo: &model.GeoQuery{Field: "location", Near: &model.GeoPoint{}, OrderByDistance: true}}
+
+	ordered := applyGeoOrdering(query, docs)
+	require.Len(t, ordered, 1)
+	assert.Equal(t, "mid", ordered[0].DocumentID)
+
+	find := geoFindQuery(query)
+	assert.Zero(t, find.Limit)
+	assert.Zero(t, find.Offset)
+}
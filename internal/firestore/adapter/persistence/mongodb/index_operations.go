@@ -63,6 +63,10 @@ type IndexOperations struct {
 	indexesCol   IndexCollection
 	documentsCol DocumentCollection
 	logger       logger.Logger
+
+	// collectionFor resolves the MongoDB collection holding a Firestore collection's
+	// documents. When nil, indexes are created on documentsCol.
+	collectionFor func(collectionID string) DocumentCollection
 }
 
 // NewIndexOperations crea una nueva instancia inyectando dependencias
@@ -252,11 +256,40 @@ func (i *IndexOperations) GetIndex(ctx context.Context, projectID, databaseID, c
 	return index, nil
 }
 
+// indexManagerFor returns the index manager of the collection storing collectionID's documents
+func (i *IndexOperations) indexManagerFor(collectionID string) (IndexManager, error) {
+	col := i.documentsCol
+	if i.collectionFor != nil && collectionID != "" {
+		col = i.collectionFor(collectionID)
+	}
+	if col == nil {
+		return nil, fmt.Errorf("no collection available for indexes of %q", collectionID)
+	}
+	manager := col.Indexes()
+	if manager == nil {
+		return nil, fmt.Errorf("index management is not supported for collection %q", collectionID)
+	}
+	return manager, nil
+}
+
 // createMongoDBIndex creates the actual MongoDB index
 func (i *IndexOperations) createMongoDBIndex(ctx context.Context, projectID, databaseID, collectionID string, index *model.CollectionIndex) error {
 	// Build MongoDB index model
 	keys := bson.D{}
 	for _, field := range index.Fields {
+		if field.VectorConfig != nil {
+			// Vector fields are served by the in-process approximate index, not MongoDB
+			continue
+		}
+		if field.Mode == model.IndexFieldModeGeo {
+			// GeoPoints keep a GeoJSON copy next to their value for 2dsphere indexing
+			base, err := nestedFieldMongoPath(field.Path)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, bson.E{Key: base + "." + geoJSONKey, Value: "2dsphere"})
+			continue
+		}
 		direction := 1
 		if field.Order == model.IndexFieldOrderDescending {
 			direction = -1
@@ -264,19 +297,30 @@ func (i *IndexOperations) createMongoDBIndex(ctx context.Context, projectID, dat
 		keys = append(keys, bson.E{Key: "fields." + field.Path + ".value", Value: direction})
 	}
 
+	if len(keys) == 0 {
+		return nil
+	}
+
 	indexModel := mongo.IndexModel{
 		Keys:    keys,
 		Options: options.Index().SetName(index.Name),
 	}
 
-	// Create the index on the documents collection
-	_, err := i.documentsCol.Indexes().CreateOne(ctx, indexModel)
+	manager, err := i.indexManagerFor(collectionID)
+	if err != nil {
+		return err
+	}
+	_, err = manager.CreateOne(ctx, indexModel)
 	return err
 }
 
 // deleteMongoDBIndex deletes the actual MongoDB index
 func (i *IndexOperations) deleteMongoDBIndex(ctx context.Context, projectID, databaseID, collectionID, indexName string) error {
-	_, err := i.documentsCol.Indexes().DropOne(ctx, indexName)
+	manager, err := i.indexManagerFor(collectionID)
+	if err != nil {
+		return err
+	}
+	_, err = manager.DropOne(ctx, indexName)
 	return err
 }
 
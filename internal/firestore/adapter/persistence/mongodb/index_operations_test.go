@@ -222,6 +222,34 @@ func TestIndexOperations(t *testing.T) {
 			mockIndexCol.AssertExpectations(t)
 		})
 
+		t.Run("with geo index on the collection's own storage", func(t *testing.T) {
+			ops, mockIndexCol, _ := createTestIndexOperations()
+			mockIndexCol.On("CountDocuments", mock.Anything, mock.Anything).Return(int64(0), nil)
+			mockIndexCol.On("InsertOne", mock.Anything, mock.Anything).Return("geo_index", nil)
+			mockIndexCol.On("UpdateOne", mock.Anything, mock.Anything, mock.Anything).Return(UpdateResult{}, nil)
+			mockIndexManager := new(MockIndexManager)
+			mockIndexManager.On("CreateOne", mock.Anything, mock.MatchedBy(func(m mongo.IndexModel) bool {
+				keys := m.Keys.(bson.D)
+				return len(keys) == 1 && keys[0].Key == "fields.address.mapValue.fields.location.geoJson" && keys[0].Value == "2dsphere"
+			})).Return(nil, nil)
+			storesCol := new(MockDocumentCollection)
+			storesCol.On("Indexes").Return(mockIndexManager)
+			ops.collectionFor = func(collectionID string) DocumentCollection {
+				assert.Equal(t, "stores", collectionID)
+				return storesCol
+			}
+
+			index := &model.CollectionIndex{
+				Name:   "geo_index",
+				Fields: []model.IndexField{{Path: "address.location", Mode: model.IndexFieldModeGeo}},
+			}
+
+			err := ops.CreateIndex(ctx, "p1", "d1", "stores", index)
+
+			assert.NoError(t, err)
+			mockIndexManager.AssertExpectations(t)
+		})
+
 		t.Run("validates input parameters", func(t *testing.T) {
 			ops, _, _ := createTestIndexOperations()
 
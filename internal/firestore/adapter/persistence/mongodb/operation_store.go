@@ -0,0 +1,78 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	sharederrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FinishedOperationRetention is how long finished long-running operations are kept before
+// MongoDB expires them
+const FinishedOperationRetention = 30 * 24 * time.Hour
+
+// OperationStore persists long-running operations in the master database, so every server
+// instance sees the progress of an operation and can resume it. Operations are only read
+// from the organization they were started in.
+type OperationStore struct {
+	collection *mongo.Collection
+}
+
+// NewOperationStore creates an operation store over the long_running_operations collection
+func NewOperationStore(db *mongo.Database) *OperationStore {
+	return &OperationStore{collection: db.Collection("long_running_operations")}
+}
+
+// EnsureIndexes creates the lookup index and expires finished operations
+func (s *OperationStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "operation_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_operations_operation_id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "end_time", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(FinishedOperationRetention.Seconds())).SetName("idx_operations_end_time_ttl"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create operation indexes: %w", err)
+	}
+	return nil
+}
+
+// SaveOperation inserts or replaces an operation
+func (s *OperationStore) SaveOperation(ctx context.Context, op *model.RecursiveDeleteOperation) error {
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"operation_id": op.OperationID}, op, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save operation: %w", err)
+	}
+	return nil
+}
+
+// GetOperation returns a stored operation of the organization in the context
+func (s *OperationStore) GetOperation(ctx context.Context, operationID string) (*model.RecursiveDeleteOperation, error) {
+	filter := bson.M{"operation_id": operationID}
+	if organizationID := utils.GetOrganizationIDOrDefault(ctx, ""); organizationID != "" {
+		filter["organization_id"] = organizationID
+	} else {
+		filter["organization_id"] = bson.M{"$exists": false}
+	}
+	var op model.RecursiveDeleteOperation
+	err := s.collection.FindOne(ctx, filter).Decode(&op)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sharederrors.NewNotFoundError("operation")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	return &op, nil
+}
@@ -70,6 +70,13 @@ func (qe *MongoQueryEngine) executeCollectionGroupQuery(ctx context.Context, que
 		allDocs = append(allDocs, docs...)
 	}
 
+	// Nearest first across all collections; each collection was already limited
+	if query.Geo != nil && query.Geo.OrderByDistance {
+		allDocs = applyGeoOrdering(model.Query{Geo: query.Geo}, allDocs)
+	}
+	// Nearest vectors across all collections; each collection returned its own nearest
+	allDocs = applyVectorRanking(query, allDocs)
+
 	// Apply global limit and ordering if needed
 	if query.Limit > 0 && len(allDocs) > int(query.Limit) {
 		// TODO: For proper ordering across collections, we should sort first then limit
@@ -176,7 +183,21 @@ func (qe *MongoQueryEngine) executeSingleCollectionQuery(ctx context.Context, co
 		filter = mergeFiltersWithAnd(filter, cursorFilter)
 		log.Printf("[MongoQueryEngine] Filtro final después de merge: %+v", filter)
 	}
-	findOpts := qe.buildMongoFindOptions(ctx, collectionPath, query)
+	if query.Geo != nil {
+		geoFilter, err := buildGeoFilter(query.Geo)
+		if err != nil {
+			return nil, err
+		}
+		filter = mergeFiltersWithAnd(filter, geoFilter)
+	}
+	if query.FindNearest != nil {
+		vectorFilter, err := buildVectorFilter(query.FindNearest)
+		if err != nil {
+			return nil, err
+		}
+		filter = mergeFiltersWithAnd(filter, vectorFilter)
+	}
+	findOpts := qe.buildMongoFindOptions(ctx, collectionPath, vectorFindQuery(geoFindQuery(query)))
 	log.Printf("[MongoQueryEngine] FindOptions: %+v", findOpts)
 	cur, err := qe.db.Collection(collectionPath).Find(ctx, filter, findOpts)
 	if err != nil {
@@ -199,6 +220,8 @@ func (qe *MongoQueryEngine) executeSingleCollectionQuery(ctx context.Context, co
 			docs = docs[:query.Limit]
 		}
 	}
+	docs = applyGeoOrdering(query, docs)
+	docs = applyVectorRanking(query, docs)
 	log.Printf("[MongoQueryEngine] Documentos encontrados en %s: %d", collectionPath, len(docs))
 
 	// Ensure we always return a non-nil slice
@@ -557,9 +580,23 @@ func (qe *MongoQueryEngine) ExecuteQueryWithProjection(ctx context.Context, coll
 	if len(cursorFilter) > 0 {
 		filter = mergeFiltersWithAnd(filter, cursorFilter)
 	}
+	if query.Geo != nil {
+		geoFilter, err := buildGeoFilter(query.Geo)
+		if err != nil {
+			return nil, err
+		}
+		filter = mergeFiltersWithAnd(filter, geoFilter)
+	}
+	if query.FindNearest != nil {
+		vectorFilter, err := buildVectorFilter(query.FindNearest)
+		if err != nil {
+			return nil, err
+		}
+		filter = mergeFiltersWithAnd(filter, vectorFilter)
+	}
 
 	// Build find options with projection
-	findOpts := qe.buildMongoFindOptions(ctx, collectionPath, query)
+	findOpts := qe.buildMongoFindOptions(ctx, collectionPath, vectorFindQuery(geoFindQuery(query)))
 
 	// Add projection if specified
 	if len(projection) > 0 {
@@ -569,6 +606,18 @@ func (qe *MongoQueryEngine) ExecuteQueryWithProjection(ctx context.Context, coll
 			mongoField := fmt.Sprintf("fields.%s", field)
 			projectionDoc[mongoField] = 1
 		}
+		// Distance ordering needs the GeoPoint of every match
+		if query.Geo != nil && query.Geo.OrderByDistance {
+			if geoPath, err := nestedFieldMongoPath(query.Geo.Field); err == nil && !projectionCovers(projectionDoc, geoPath) {
+				projectionDoc[geoPath] = 1
+			}
+		}
+		// Vector ranking needs the vector of every candidate
+		if query.FindNearest != nil {
+			if vectorPath, err := nestedFieldMongoPath(query.FindNearest.VectorField); err == nil && !projectionCovers(projectionDoc, vectorPath) {
+				projectionDoc[vectorPath] = 1
+			}
+		}
 		// Always include metadata fields
 		projectionDoc["project_id"] = 1
 		projectionDoc["database_id"] = 1
@@ -603,6 +652,8 @@ func (qe *MongoQueryEngine) ExecuteQueryWithProjection(ctx context.Context, coll
 			docs = docs[:query.Limit]
 		}
 	}
+	docs = applyGeoOrdering(query, docs)
+	docs = applyVectorRanking(query, docs)
 
 	log.Printf("[MongoQueryEngine] Documentos encontrados con proyección: %d", len(docs))
 	return docs, nil
@@ -615,6 +666,13 @@ func (qe *MongoQueryEngine) CountDocuments(ctx context.Context, collectionPath s
 
 	// Build the filter (same as ExecuteQuery but without cursor filters for count) with context for type inference
 	filter := qe.buildMongoFilterWithContext(ctx, collectionPath, query.Filters)
+	if query.Geo != nil {
+		geoFilter, err := buildGeoFilter(query.Geo)
+		if err != nil {
+			return 0, err
+		}
+		filter = mergeFiltersWithAnd(filter, geoFilter)
+	}
 	log.Printf("[MongoQueryEngine] Filtro para conteo: %+v", filter)
 
 	// Count documents matching the filter
@@ -1045,6 +1103,9 @@ func (qe *MongoQueryEngine) determineFirestoreFieldType(fieldMap bson.M) model.F
 	if _, exists := fieldMap["geoPointValue"]; exists {
 		return model.FieldTypeGeoPoint
 	}
+	if _, exists := fieldMap["vectorValue"]; exists {
+		return model.FieldTypeVector
+	}
 
 	// Default fallback
 	return model.FieldTypeString
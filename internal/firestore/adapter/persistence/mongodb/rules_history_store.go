@@ -0,0 +1,223 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RulesHistoryStore persists security rules deployments in the master database, following
+// the Firebase Rules model: every deployment is an immutable ruleset holding the rules
+// source and its translation, and releases point a database at one of them. Releases are
+// only appended, so the latest one is the active ruleset and the rest are the audit log.
+type RulesHistoryStore struct {
+	rulesets *mongo.Collection
+	releases *mongo.Collection
+}
+
+// NewRulesHistoryStore creates a rules history store over the security_rulesets and
+// security_rules_releases collections
+func NewRulesHistoryStore(db *mongo.Database) *RulesHistoryStore {
+	return &RulesHistoryStore{
+		rulesets: db.Collection("security_rulesets"),
+		releases: db.Collection("security_rules_releases"),
+	}
+}
+
+// rulesetDocument is the stored form of a ruleset; the translated rules are stored typed
+// so that they decode back into security rules
+type rulesetDocument struct {
+	ProjectID  string                     `bson:"project_id"`
+	DatabaseID string                     `bson:"database_id"`
+	Version    string                     `bson:"version"`
+	DeployedAt time.Time                  `bson:"deployed_at"`
+	DeployedBy string                     `bson:"deployed_by"`
+	RulesCount int                        `bson:"rules_count"`
+	Status     string                     `bson:"status"`
+	RollbackOf string                     `bson:"rollback_of,omitempty"`
+	Source     string                     `bson:"source"`
+	Rules      []*repository.SecurityRule `bson:"rules"`
+}
+
+type releaseDocument struct {
+	Name       string    `bson:"name"`
+	ProjectID  string    `bson:"project_id"`
+	DatabaseID string    `bson:"database_id"`
+	Version    string    `bson:"version"`
+	ReleasedAt time.Time `bson:"released_at"`
+	ReleasedBy string    `bson:"released_by"`
+	RollbackOf string    `bson:"rollback_of,omitempty"`
+}
+
+// EnsureIndexes creates the indexes used to look rulesets and releases up
+func (s *RulesHistoryStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.rulesets.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_rulesets_database_version_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "deployed_at", Value: -1}},
+			Options: options.Index().SetName("idx_rulesets_database_deployed"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ruleset indexes: %w", err)
+	}
+	_, err = s.releases.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "released_at", Value: -1}},
+		Options: options.Index().SetName("idx_rules_releases_database_released"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create rules release indexes: %w", err)
+	}
+	return nil
+}
+
+// SaveDeployment stores a new ruleset
+func (s *RulesHistoryStore) SaveDeployment(ctx context.Context, projectID, databaseID string, deployment *rtdomain.DeployHistory) error {
+	rules, ok := deployment.Rules.([]*repository.SecurityRule)
+	if !ok && deployment.Rules != nil {
+		return fmt.Errorf("unsupported ruleset rules type %T", deployment.Rules)
+	}
+	doc := &rulesetDocument{
+		ProjectID:  projectID,
+		DatabaseID: databaseID,
+		Version:    deployment.Version,
+		DeployedAt: deployment.DeployedAt,
+		DeployedBy: deployment.DeployedBy,
+		RulesCount: deployment.RulesCount,
+		Status:     deployment.Status,
+		RollbackOf: deployment.RollbackOf,
+		Source:     deployment.Source,
+		Rules:      rules,
+	}
+	if _, err := s.rulesets.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("failed to save ruleset: %w", err)
+	}
+	return nil
+}
+
+// GetHistory returns the latest rulesets, oldest first
+func (s *RulesHistoryStore) GetHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.DeployHistory, error) {
+	docs := make([]*rulesetDocument, 0)
+	if err := findLatest(ctx, s.rulesets, databaseFilter(projectID, databaseID), "deployed_at", limit, &docs); err != nil {
+		return nil, fmt.Errorf("failed to list rulesets: %w", err)
+	}
+	history := make([]*rtdomain.DeployHistory, len(docs))
+	for i, doc := range docs {
+		history[len(docs)-1-i] = doc.toDomain()
+	}
+	return history, nil
+}
+
+// GetLastDeployment returns the latest ruleset, or nil when none was deployed
+func (s *RulesHistoryStore) GetLastDeployment(ctx context.Context, projectID, databaseID string) (*rtdomain.DeployHistory, error) {
+	history, err := s.GetHistory(ctx, projectID, databaseID, 1)
+	if err != nil || len(history) == 0 {
+		return nil, err
+	}
+	return history[0], nil
+}
+
+// GetDeployment returns a ruleset by version, or nil when it does not exist
+func (s *RulesHistoryStore) GetDeployment(ctx context.Context, projectID, databaseID, version string) (*rtdomain.DeployHistory, error) {
+	filter := databaseFilter(projectID, databaseID)
+	filter["version"] = version
+
+	var doc rulesetDocument
+	err := s.rulesets.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ruleset: %w", err)
+	}
+	return doc.toDomain(), nil
+}
+
+// SaveRelease appends a release, which becomes the active one
+func (s *RulesHistoryStore) SaveRelease(ctx context.Context, release *rtdomain.RulesRelease) error {
+	doc := &releaseDocument{
+		Name:       release.Name,
+		ProjectID:  release.ProjectID,
+		DatabaseID: release.DatabaseID,
+		Version:    release.Version,
+		ReleasedAt: release.ReleasedAt,
+		ReleasedBy: release.ReleasedBy,
+		RollbackOf: release.RollbackOf,
+	}
+	if _, err := s.releases.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("failed to save rules release: %w", err)
+	}
+	return nil
+}
+
+// GetRelease returns the active release, or nil when no rules were released
+func (s *RulesHistoryStore) GetRelease(ctx context.Context, projectID, databaseID string) (*rtdomain.RulesRelease, error) {
+	releases, err := s.GetReleaseHistory(ctx, projectID, databaseID, 1)
+	if err != nil || len(releases) == 0 {
+		return nil, err
+	}
+	return releases[0], nil
+}
+
+// GetReleaseHistory returns the latest releases, oldest first; all of them when limit is not positive
+func (s *RulesHistoryStore) GetReleaseHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.RulesRelease, error) {
+	docs := make([]*releaseDocument, 0)
+	if err := findLatest(ctx, s.releases, databaseFilter(projectID, databaseID), "released_at", limit, &docs); err != nil {
+		return nil, fmt.Errorf("failed to list rules releases: %w", err)
+	}
+	releases := make([]*rtdomain.RulesRelease, len(docs))
+	for i, doc := range docs {
+		releases[len(docs)-1-i] = &rtdomain.RulesRelease{
+			Name:       doc.Name,
+			ProjectID:  doc.ProjectID,
+			DatabaseID: doc.DatabaseID,
+			Version:    doc.Version,
+			ReleasedAt: doc.ReleasedAt,
+			ReleasedBy: doc.ReleasedBy,
+			RollbackOf: doc.RollbackOf,
+		}
+	}
+	return releases, nil
+}
+
+func (doc *rulesetDocument) toDomain() *rtdomain.DeployHistory {
+	return &rtdomain.DeployHistory{
+		Version:    doc.Version,
+		DeployedAt: doc.DeployedAt,
+		DeployedBy: doc.DeployedBy,
+		RulesCount: doc.RulesCount,
+		Status:     doc.Status,
+		RollbackOf: doc.RollbackOf,
+		Source:     doc.Source,
+		Rules:      doc.Rules,
+	}
+}
+
+func databaseFilter(projectID, databaseID string) bson.M {
+	return bson.M{"project_id": projectID, "database_id": databaseID}
+}
+
+// findLatest decodes the newest documents of a filter, newest first. Insertion order
+// breaks ties between documents written in the same millisecond.
+func findLatest(ctx context.Context, collection *mongo.Collection, filter bson.M, timeField string, limit int, results interface{}) error {
+	opts := options.Find().SetSort(bson.D{{Key: timeField, Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
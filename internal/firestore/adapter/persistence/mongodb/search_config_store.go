@@ -0,0 +1,107 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/database"
+	sharederrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchConfigStore persists full-text search configurations in the database of the
+// organization in the request context, so every instance indexes the same fields and the
+// configurations survive restarts
+type SearchConfigStore struct {
+	tenantManager *database.TenantManager
+
+	// Organizations whose search configuration index was already created by this instance
+	indexed sync.Map
+}
+
+// NewSearchConfigStore creates a tenant-aware search configuration store
+func NewSearchConfigStore(tenantManager *database.TenantManager) *SearchConfigStore {
+	return &SearchConfigStore{tenantManager: tenantManager}
+}
+
+// configs returns the search_configs collection of the organization in the context
+func (s *SearchConfigStore) configs(ctx context.Context) (*mongo.Collection, error) {
+	organizationID, err := utils.GetOrganizationIDFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("organization ID required: %w", err)
+	}
+	db, err := s.tenantManager.GetDatabaseForOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization database: %w", err)
+	}
+	collection := db.Collection("search_configs")
+
+	if _, done := s.indexed.Load(organizationID); !done {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "collection_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_search_configs_collection_unique"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create search configuration index: %w", err)
+		}
+		s.indexed.Store(organizationID, true)
+	}
+	return collection, nil
+}
+
+func searchConfigFilter(projectID, databaseID, collectionID string) bson.M {
+	return bson.M{"project_id": projectID, "database_id": databaseID, "collection_id": collectionID}
+}
+
+// SaveSearchConfig inserts or replaces the configuration of a collection
+func (s *SearchConfigStore) SaveSearchConfig(ctx context.Context, config *model.SearchIndexConfig) error {
+	collection, err := s.configs(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = collection.ReplaceOne(ctx, searchConfigFilter(config.ProjectID, config.DatabaseID, config.CollectionID), config, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save search configuration: %w", err)
+	}
+	return nil
+}
+
+// GetSearchConfig returns the stored configuration of a collection
+func (s *SearchConfigStore) GetSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error) {
+	collection, err := s.configs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var config model.SearchIndexConfig
+	err = collection.FindOne(ctx, searchConfigFilter(projectID, databaseID, collectionID)).Decode(&config)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sharederrors.NewNotFoundError("search index")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search configuration: %w", err)
+	}
+	return &config, nil
+}
+
+// DeleteSearchConfig removes the configuration of a collection
+func (s *SearchConfigStore) DeleteSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) error {
+	collection, err := s.configs(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := collection.DeleteOne(ctx, searchConfigFilter(projectID, databaseID, collectionID))
+	if err != nil {
+		return fmt.Errorf("failed to delete search configuration: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return sharederrors.NewNotFoundError("search index")
+	}
+	return nil
+}
@@ -9,11 +9,12 @@ import (
 	"sync"
 	"time"
 
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/model"
 	"firestore-clone/internal/firestore/domain/repository"
 	"firestore-clone/internal/shared/logger"
 
 	"github.com/google/cel-go/cel"
-	"github.com/google/cel-go/checker/decls"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -34,6 +35,7 @@ type SecurityRulesEngine struct {
 	collection       *mongo.Collection
 	log              logger.Logger
 	resourceAccessor repository.ResourceAccessor
+	coverage         repository.RulesCoverageRecorder // Optional; records the rules each request exercises
 
 	// Cache for compiled rules - maps "projectID:databaseID" to compiled rules
 	rulesCache map[string][]*CachedRule
@@ -59,18 +61,10 @@ func NewSecurityRulesEngine(db *mongo.Database, log logger.Logger) repository.Se
 	}
 }
 
-// createCELEnvironment creates and configures the CEL environment for security rules
+// createCELEnvironment creates the CEL environment for security rules, with the
+// Firestore rules standard library
 func createCELEnvironment() (*cel.Env, error) {
-	return cel.NewEnv(
-		// Declare standard variables available in security rules
-		cel.Declarations(
-			decls.NewVar("auth", decls.Dyn),     // Allow auth to be null or map
-			decls.NewVar("request", decls.Dyn),  // Allow request to be null or map
-			decls.NewVar("resource", decls.Dyn), // Allow resource to be null or map
-			decls.NewVar("path", decls.String),
-			decls.NewVar("variables", decls.NewMapType(decls.String, decls.String)),
-		),
-	)
+	return rules_cel.NewEnvironment()
 }
 
 // SetResourceAccessor sets the resource accessor for CEL functions
@@ -78,6 +72,13 @@ func (e *SecurityRulesEngine) SetResourceAccessor(accessor repository.ResourceAc
 	e.resourceAccessor = accessor
 }
 
+// SetCoverageRecorder records the rules and conditions exercised by every evaluated request
+func (e *SecurityRulesEngine) SetCoverageRecorder(recorder repository.RulesCoverageRecorder) {
+	e.coverage = recorder
+}
+
+var _ repository.RulesCoverageInstrumented = (*SecurityRulesEngine)(nil)
+
 // LoadRules loads security rules from storage and compiles them for efficient evaluation
 func (e *SecurityRulesEngine) LoadRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
 	cacheKey := fmt.Sprintf("%s:%s", projectID, databaseID)
@@ -499,7 +500,7 @@ func (e *SecurityRulesEngine) compileRule(rule *repository.SecurityRule) (*Cache
 
 	// Compile allow conditions
 	for op, condition := range rule.Allow {
-		program, err := e.compileCELExpression(condition)
+		program, err := e.compileCELExpression(condition, variables)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compile allow condition for operation '%s': %w", op, err)
 		}
@@ -508,7 +509,7 @@ func (e *SecurityRulesEngine) compileRule(rule *repository.SecurityRule) (*Cache
 
 	// Compile deny conditions
 	for op, condition := range rule.Deny {
-		program, err := e.compileCELExpression(condition)
+		program, err := e.compileCELExpression(condition, variables)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compile deny condition for operation '%s': %w", op, err)
 		}
@@ -520,49 +521,13 @@ func (e *SecurityRulesEngine) compileRule(rule *repository.SecurityRule) (*Cache
 
 // compileMatchPattern converts a Firestore match pattern to a regex and extracts variable names
 func (e *SecurityRulesEngine) compileMatchPattern(pattern string) (*regexp.Regexp, []string, error) {
-	// Extract variable names from pattern
-	varRegex := regexp.MustCompile(`\{([^}]+)\}`)
-	matches := varRegex.FindAllStringSubmatch(pattern, -1)
-	variables := make([]string, len(matches))
-	for i, match := range matches {
-		variables[i] = match[1]
-	}
-
-	// Convert Firestore pattern to regex
-	// Replace {variableName} with named capture groups
-	// Replace {variableName=**} with recursive wildcard matching
-	regexPattern := regexp.QuoteMeta(pattern)
-
-	// Handle recursive wildcards first (=**)
-	regexPattern = regexp.MustCompile(`\\{([^}]+)=\\\*\\\*\\}`).ReplaceAllString(regexPattern, `(?P<$1>.*)`)
-
-	// Handle regular wildcards
-	regexPattern = regexp.MustCompile(`\\{([^}]+)\\}`).ReplaceAllString(regexPattern, `(?P<$1>[^/]+)`)
-
-	// Anchor the pattern
-	regexPattern = "^" + regexPattern + "$"
-
-	compiledRegex, err := regexp.Compile(regexPattern)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to compile regex pattern: %w", err)
-	}
-
-	return compiledRegex, variables, nil
+	return rules_cel.CompileMatch(pattern)
 }
 
-// compileCELExpression compiles a CEL expression string into a program
-func (e *SecurityRulesEngine) compileCELExpression(expression string) (cel.Program, error) {
-	ast, issues := e.celEnv.Compile(expression)
-	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("CEL compilation error: %w", issues.Err())
-	}
-
-	program, err := e.celEnv.Program(ast)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create CEL program: %w", err)
-	}
-
-	return program, nil
+// compileCELExpression compiles a rules condition into a program, declaring the
+// wildcard variables of its match pattern
+func (e *SecurityRulesEngine) compileCELExpression(expression string, variables []string) (cel.Program, error) {
+	return rules_cel.Compile(e.celEnv, expression, variables)
 }
 
 // EvaluateAccess evaluates security rules to determine if an operation is allowed
@@ -629,10 +594,12 @@ func (e *SecurityRulesEngine) EvaluateAccess(ctx context.Context, operation repo
 			// Create updated security context with variables
 			contextWithVars := *securityContext
 			contextWithVars.Variables = variables
+			e.recordRule(securityContext, cachedRule)
 
 			// Check deny rules first (deny takes precedence)
 			if denyProgram, exists := cachedRule.DenyPrograms[operation]; exists {
 				denied, reason, err := e.evaluateCondition(ctx, denyProgram, &contextWithVars)
+				e.recordCondition(securityContext, cachedRule, "deny", operation, denied, err)
 				if err != nil {
 					e.log.Error("Failed to evaluate deny condition",
 						zap.String("rule", cachedRule.Rule.Match),
@@ -654,6 +621,7 @@ func (e *SecurityRulesEngine) EvaluateAccess(ctx context.Context, operation repo
 			// Check allow rules
 			if allowProgram, exists := cachedRule.AllowPrograms[operation]; exists {
 				allowed, reason, err := e.evaluateCondition(ctx, allowProgram, &contextWithVars)
+				e.recordCondition(securityContext, cachedRule, "allow", operation, allowed, err)
 				if err != nil {
 					e.log.Error("Failed to evaluate allow condition",
 						zap.String("rule", cachedRule.Rule.Match),
@@ -690,58 +658,104 @@ func (e *SecurityRulesEngine) EvaluateAccess(ctx context.Context, operation repo
 	return result, nil
 }
 
-// matchesPath checks if a cached rule's regex matches the given path
-func (e *SecurityRulesEngine) matchesPath(cachedRule *CachedRule, path string) bool {
-	return cachedRule.MatchRegex.MatchString(path)
+// recordRule records a matched rule when coverage is recorded
+func (e *SecurityRulesEngine) recordRule(securityContext *repository.SecurityContext, cachedRule *CachedRule) {
+	if e.coverage != nil {
+		e.coverage.RecordRule(securityContext.ProjectID, securityContext.DatabaseID, cachedRule.Rule.Match)
+	}
 }
 
-// extractVariables extracts path variables using the cached rule's regex
-func (e *SecurityRulesEngine) extractVariables(cachedRule *CachedRule, path string) map[string]string {
-	variables := make(map[string]string)
-
-	matches := cachedRule.MatchRegex.FindStringSubmatch(path)
-	if matches == nil {
-		return variables
+// recordCondition records the outcome of an evaluated condition when coverage is recorded
+func (e *SecurityRulesEngine) recordCondition(securityContext *repository.SecurityContext, cachedRule *CachedRule, effect string, operation repository.OperationType, result bool, err error) {
+	if e.coverage == nil {
+		return
+	}
+	outcome := repository.ConditionFalse
+	switch {
+	case err != nil:
+		outcome = repository.ConditionError
+	case result:
+		outcome = repository.ConditionTrue
 	}
+	e.coverage.RecordCondition(securityContext.ProjectID, securityContext.DatabaseID, cachedRule.Rule.Match, effect, operation, outcome)
+}
 
-	subexpNames := cachedRule.MatchRegex.SubexpNames()
-	for i, name := range subexpNames {
-		if i != 0 && name != "" && i < len(matches) {
-			variables[name] = matches[i]
+// SimulateAccess evaluates the candidate rules of a simulation, or the deployed rules
+// when it has none, and traces the evaluation
+func (e *SecurityRulesEngine) SimulateAccess(ctx context.Context, request *repository.SimulationRequest) (*repository.SimulationResult, error) {
+	rules := request.Rules
+	if len(rules) == 0 {
+		loaded, err := e.LoadRules(ctx, request.ProjectID, request.DatabaseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load security rules: %w", err)
 		}
+		rules = loaded
 	}
+	return rules_cel.Simulate(ctx, e.celEnv, rules, e.resourceAccessor, request)
+}
+
+var _ repository.RulesSimulator = (*SecurityRulesEngine)(nil)
 
-	return variables
+// EvaluateQueryAccess checks a collection query against the list rules of the database
+func (e *SecurityRulesEngine) EvaluateQueryAccess(ctx context.Context, securityContext *repository.SecurityContext, query *model.Query) (*repository.RuleEvaluationResult, error) {
+	rules, err := e.LoadRules(ctx, securityContext.ProjectID, securityContext.DatabaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security rules: %w", err)
+	}
+	return rules_cel.EvaluateQuery(ctx, e.celEnv, rules, e.resourceAccessor, &rules_cel.QueryRequest{
+		ProjectID:      securityContext.ProjectID,
+		DatabaseID:     securityContext.DatabaseID,
+		CollectionPath: securityContext.Path,
+		Auth:           rulesAuth(securityContext),
+		Query:          query,
+	})
+}
+
+var _ repository.QueryRulesEvaluator = (*SecurityRulesEngine)(nil)
+
+// matchesPath checks if a cached rule's regex matches the given path
+func (e *SecurityRulesEngine) matchesPath(cachedRule *CachedRule, path string) bool {
+	return cachedRule.MatchRegex.MatchString(path)
+}
+
+// extractVariables extracts path variables using the cached rule's regex
+func (e *SecurityRulesEngine) extractVariables(cachedRule *CachedRule, path string) map[string]string {
+	return rules_cel.MatchVariables(cachedRule.MatchRegex, path)
 }
 
 // evaluateCondition evaluates a CEL program with the given security context
 func (e *SecurityRulesEngine) evaluateCondition(ctx context.Context, program cel.Program, securityContext *repository.SecurityContext) (bool, string, error) {
-	_ = ctx // Mark ctx as used to avoid linter warning
 	// Prepare evaluation variables
 	vars := map[string]interface{}{
-		"request":   securityContext.Request,
 		"resource":  securityContext.Resource,
 		"path":      securityContext.Path,
 		"variables": securityContext.Variables,
 	}
 
 	// Add auth information if user is present
-	if securityContext.User != nil {
-		authMap := map[string]interface{}{
-			"uid": securityContext.User.ID.Hex(),
-		}
-		if securityContext.User.Email != "" {
-			authMap["token"] = map[string]interface{}{
-				"email": securityContext.User.Email,
-			}
-		}
+	authMap := rulesAuth(securityContext)
+	if authMap != nil {
 		vars["auth"] = authMap
 	} else {
 		vars["auth"] = nil
 	}
+	vars["request"] = rulesRequest(securityContext, authMap)
+
+	// Match wildcards are variables of the condition, as in Firestore rules
+	for name, value := range securityContext.Variables {
+		vars[name] = value
+	}
+
+	// get() and exists() read documents of the same database
+	evaluation := &rules_cel.Evaluation{
+		Context:    ctx,
+		Accessor:   e.resourceAccessor,
+		ProjectID:  securityContext.ProjectID,
+		DatabaseID: securityContext.DatabaseID,
+	}
 
 	// Evaluate the CEL program
-	out, _, err := program.Eval(vars)
+	out, _, err := program.Eval(evaluation.Bind(vars))
 	if err != nil {
 		return false, "", fmt.Errorf("CEL evaluation error: %w", err)
 	}
@@ -755,6 +769,46 @@ func (e *SecurityRulesEngine) evaluateCondition(ctx context.Context, program cel
 	return result, reason, nil
 }
 
+// rulesAuth returns request.auth for the user of a request, or nil when unauthenticated
+func rulesAuth(securityContext *repository.SecurityContext) map[string]interface{} {
+	if securityContext.User == nil {
+		return nil
+	}
+	authMap := map[string]interface{}{
+		"uid": securityContext.User.ID.Hex(),
+	}
+	if securityContext.User.Email != "" {
+		authMap["token"] = map[string]interface{}{
+			"email": securityContext.User.Email,
+		}
+	}
+	return authMap
+}
+
+// rulesRequest returns the request variable of a condition, adding request.auth and
+// request.time when the caller did not set them
+func rulesRequest(securityContext *repository.SecurityContext, authMap map[string]interface{}) map[string]interface{} {
+	request := make(map[string]interface{}, len(securityContext.Request)+2)
+	for key, value := range securityContext.Request {
+		request[key] = value
+	}
+	if _, ok := request["auth"]; !ok {
+		if authMap != nil {
+			request["auth"] = authMap
+		} else {
+			request["auth"] = nil
+		}
+	}
+	if _, ok := request["time"]; !ok {
+		requestTime := time.Now()
+		if securityContext.Timestamp > 0 {
+			requestTime = time.Unix(securityContext.Timestamp, 0)
+		}
+		request["time"] = requestTime
+	}
+	return request
+}
+
 // GetRawRules returns the raw .rules text for a project/database
 func (e *SecurityRulesEngine) GetRawRules(ctx context.Context, projectID, databaseID string) (string, error) {
 	filter := bson.M{
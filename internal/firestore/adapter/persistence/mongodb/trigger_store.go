@@ -0,0 +1,229 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	sharederrors "firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeliveredTriggerRetention is how long delivered trigger deliveries are kept before
+// MongoDB expires them. Dead-lettered deliveries are kept until they are retried.
+const DeliveredTriggerRetention = 7 * 24 * time.Hour
+
+// TriggerStore persists document triggers in the master database. Triggers are keyed by
+// organization as well as by project and database, since project IDs are only unique
+// within an organization.
+type TriggerStore struct {
+	collection *mongo.Collection
+}
+
+// NewTriggerStore creates a trigger store over the document_triggers collection
+func NewTriggerStore(db *mongo.Database) *TriggerStore {
+	return &TriggerStore{collection: db.Collection("document_triggers")}
+}
+
+// EnsureIndexes creates the indexes used to look triggers up
+func (s *TriggerStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "trigger_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_triggers_organization_database_trigger_unique"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create trigger indexes: %w", err)
+	}
+	return nil
+}
+
+func triggerFilter(organizationID, projectID, databaseID, triggerID string) bson.M {
+	return withOrganization(bson.M{"project_id": projectID, "database_id": databaseID, "trigger_id": triggerID}, organizationID)
+}
+
+// withOrganization restricts a filter to the documents of an organization. Documents
+// written without an organization are only matched when no organization is given.
+func withOrganization(filter bson.M, organizationID string) bson.M {
+	if organizationID != "" {
+		filter["organization_id"] = organizationID
+	} else {
+		filter["organization_id"] = bson.M{"$exists": false}
+	}
+	return filter
+}
+
+// SaveTrigger inserts or replaces a trigger
+func (s *TriggerStore) SaveTrigger(ctx context.Context, trigger *model.DocumentTrigger) error {
+	_, err := s.collection.ReplaceOne(ctx, triggerFilter(trigger.OrganizationID, trigger.ProjectID, trigger.DatabaseID, trigger.TriggerID), trigger, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save trigger: %w", err)
+	}
+	return nil
+}
+
+// GetTrigger returns a stored trigger of the organization in the context
+func (s *TriggerStore) GetTrigger(ctx context.Context, projectID, databaseID, triggerID string) (*model.DocumentTrigger, error) {
+	var trigger model.DocumentTrigger
+	err := s.collection.FindOne(ctx, triggerFilter(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, triggerID)).Decode(&trigger)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sharederrors.NewNotFoundError("document trigger")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trigger: %w", err)
+	}
+	return &trigger, nil
+}
+
+// ListTriggers returns the triggers of a database of the organization in the context ordered by creation
+func (s *TriggerStore) ListTriggers(ctx context.Context, projectID, databaseID string) ([]*model.DocumentTrigger, error) {
+	filter := withOrganization(bson.M{"project_id": projectID, "database_id": databaseID}, utils.GetOrganizationIDOrDefault(ctx, ""))
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list triggers: %w", err)
+	}
+	triggers := make([]*model.DocumentTrigger, 0)
+	if err := cursor.All(ctx, &triggers); err != nil {
+		return nil, fmt.Errorf("failed to decode triggers: %w", err)
+	}
+	return triggers, nil
+}
+
+// DeleteTrigger removes a stored trigger of the organization in the context
+func (s *TriggerStore) DeleteTrigger(ctx context.Context, projectID, databaseID, triggerID string) error {
+	result, err := s.collection.DeleteOne(ctx, triggerFilter(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, triggerID))
+	if err != nil {
+		return fmt.Errorf("failed to delete trigger: %w", err)
+	}
+	if result.DeletedCount == 0 {
+		return sharederrors.NewNotFoundError("document trigger")
+	}
+	return nil
+}
+
+// TriggerDeliveryQueue is the persistent trigger delivery queue in the master database.
+// Deliveries are claimed atomically, so several server instances can share the queue.
+type TriggerDeliveryQueue struct {
+	collection *mongo.Collection
+}
+
+// NewTriggerDeliveryQueue creates a delivery queue over the trigger_deliveries collection
+func NewTriggerDeliveryQueue(db *mongo.Database) *TriggerDeliveryQueue {
+	return &TriggerDeliveryQueue{collection: db.Collection("trigger_deliveries")}
+}
+
+// EnsureIndexes creates the claim and admin view indexes and expires delivered deliveries
+func (q *TriggerDeliveryQueue) EnsureIndexes(ctx context.Context) error {
+	_, err := q.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "delivery_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_deliveries_delivery_id_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "state", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("idx_deliveries_state_next_attempt"),
+		},
+		{
+			Keys:    bson.D{{Key: "organization_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "database_id", Value: 1}, {Key: "state", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("idx_deliveries_organization_database_state_created"),
+		},
+		{
+			Keys:    bson.D{{Key: "delivered_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(DeliveredTriggerRetention.Seconds())).SetName("idx_deliveries_delivered_at_ttl"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create trigger delivery indexes: %w", err)
+	}
+	return nil
+}
+
+// Enqueue inserts the deliveries
+func (q *TriggerDeliveryQueue) Enqueue(ctx context.Context, deliveries []*model.TriggerDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		documents[i] = delivery
+	}
+	if _, err := q.collection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to enqueue trigger deliveries: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue leases the oldest pending deliveries due at now, one atomic update each
+func (q *TriggerDeliveryQueue) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.TriggerDelivery, error) {
+	filter := bson.M{"state": model.TriggerDeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	claimed := make([]*model.TriggerDelivery, 0, limit)
+	for len(claimed) < limit {
+		var delivery model.TriggerDelivery
+		err := q.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim trigger delivery: %w", err)
+		}
+		claimed = append(claimed, &delivery)
+	}
+	return claimed, nil
+}
+
+// SaveDelivery replaces a delivery with its new state
+func (q *TriggerDeliveryQueue) SaveDelivery(ctx context.Context, delivery *model.TriggerDelivery) error {
+	_, err := q.collection.ReplaceOne(ctx, bson.M{"delivery_id": delivery.DeliveryID}, delivery, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save trigger delivery: %w", err)
+	}
+	return nil
+}
+
+// GetDelivery returns a delivery of a database of the organization in the context
+func (q *TriggerDeliveryQueue) GetDelivery(ctx context.Context, projectID, databaseID, deliveryID string) (*model.TriggerDelivery, error) {
+	filter := withOrganization(bson.M{"project_id": projectID, "database_id": databaseID, "delivery_id": deliveryID}, utils.GetOrganizationIDOrDefault(ctx, ""))
+	var delivery model.TriggerDelivery
+	err := q.collection.FindOne(ctx, filter).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, sharederrors.NewNotFoundError("trigger delivery")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trigger delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns the deliveries of a database of the organization in the context
+// in a state, newest first
+func (q *TriggerDeliveryQueue) ListDeliveries(ctx context.Context, projectID, databaseID string, state model.TriggerDeliveryState, limit int) ([]*model.TriggerDelivery, error) {
+	filter := withOrganization(bson.M{"project_id": projectID, "database_id": databaseID}, utils.GetOrganizationIDOrDefault(ctx, ""))
+	if state != "" {
+		filter["state"] = state
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cursor, err := q.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trigger deliveries: %w", err)
+	}
+	deliveries := make([]*model.TriggerDelivery, 0)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to decode trigger deliveries: %w", err)
+	}
+	return deliveries, nil
+}
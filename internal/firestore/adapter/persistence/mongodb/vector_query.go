@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"firestore-clone/internal/firestore/domain/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// flattenVector builds the stored representation of a vector field
+func flattenVector(vector *model.VectorValue) map[string]interface{} {
+	return map[string]interface{}{
+		"vectorValue": map[string]interface{}{
+			"values": vector.Values,
+		},
+	}
+}
+
+// buildVectorFilter restricts a findNearest query to documents holding a vector in the
+// searched field and, when an approximate index preselected them, to its candidates.
+// Vector distances are computed after the fetch, so only the pre-filters run in MongoDB.
+func buildVectorFilter(nearest *model.FindNearest) (bson.M, error) {
+	if err := nearest.Validate(); err != nil {
+		return nil, err
+	}
+	base, err := nestedFieldMongoPath(nearest.VectorField)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{base + ".vectorValue.values": bson.M{"$size": len(nearest.QueryVector)}}
+	if nearest.CandidateIDs != nil {
+		filter = bson.M{"$and": []bson.M{filter, {"documentID": bson.M{"$in": nearest.CandidateIDs}}}}
+	}
+	return filter, nil
+}
+
+// vectorFindQuery returns the query used to build the find options. Every pre-filtered
+// document is a candidate, so ordering and pagination happen after the ranking.
+func vectorFindQuery(query model.Query) model.Query {
+	if query.FindNearest != nil {
+		query.Orders = nil
+		query.Limit = 0
+		query.Offset = 0
+		query.LimitToLast = false
+	}
+	return query
+}
+
+// applyVectorRanking keeps the findNearest limit of nearest documents
+func applyVectorRanking(query model.Query, docs []*model.Document) []*model.Document {
+	if query.FindNearest == nil {
+		return docs
+	}
+	return query.FindNearest.Rank(docs)
+}
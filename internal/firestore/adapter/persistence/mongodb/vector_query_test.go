@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestVectorValue_RoundTripsThroughStorage(t *testing.T) {
+	vector := &model.VectorValue{Values: []float64{0.1, -0.5, 2}}
+	flat := flattenFieldsForMongoDB(map[string]*model.FieldValue{"embedding": model.NewFieldValue(vector)})
+
+	expanded := expandFieldsFromMongoDB(flat)
+	require.Contains(t, expanded, "embedding")
+	assert.Equal(t, model.FieldTypeVector, expanded["embedding"].ValueType)
+	assert.Equal(t, vector, expanded["embedding"].Value)
+}
+
+func TestBuildVectorFilter(t *testing.T) {
+	nearest := &model.FindNearest{VectorField: "meta.embedding", QueryVector: []float64{1, 2, 3}, DistanceMeasure: model.DistanceMeasureCosine, Limit: 5}
+	filter, err := buildVectorFilter(nearest)
+	require.NoError(t, err)
+	assert.Equal(t, bson.M{"fields.meta.mapValue.fields.embedding.vectorValue.values": bson.M{"$size": 3}}, filter)
+
+	// Candidates preselected by an approximate index restrict the fetch
+	nearest.CandidateIDs = []string{"a", "b"}
+	filter, err = buildVectorFilter(nearest)
+	require.NoError(t, err)
+	conditions := filter["$and"].([]bson.M)
+	require.Len(t, conditions, 2)
+	assert.Equal(t, bson.M{"$in": []string{"a", "b"}}, conditions[1]["documentID"])
+
+	nearest.Limit = 0
+	_, err = buildVectorFilter(nearest)
+	assert.ErrorIs(t, err, model.ErrInvalidFindNearest)
+}
@@ -0,0 +1,257 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// RealtimeEventsChannel is the Redis pub/sub channel carrying realtime events between server instances
+const RealtimeEventsChannel = "firestore:realtime:events"
+
+// realtimeSequenceKeyPrefix prefixes the per-path sequence counters
+const realtimeSequenceKeyPrefix = "firestore:realtime:seq:"
+
+// publishEventScript assigns the next sequence of the path, appends the event to the path
+// stream and publishes it, atomically, so that all instances see the events of a path in
+// sequence order. The published message is "<sequence>\n<stream ID>\n<event JSON>".
+//
+// KEYS[1] stream, KEYS[2] sequence counter; ARGV[1] channel, ARGV[2] max stream length,
+// ARGV[3] event JSON, ARGV[4..] stream entry fields and values
+var publishEventScript = redis.NewScript(`
+local sequence = redis.call('INCR', KEYS[2])
+local fields = {'sequenceNumber', sequence}
+for i = 4, #ARGV do
+	fields[#fields + 1] = ARGV[i]
+end
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', unpack(fields))
+redis.call('PUBLISH', ARGV[1], sequence .. '\n' .. id .. '\n' .. ARGV[3])
+return {sequence, id}
+`)
+
+// publishCommitScript is publishEventScript for the changes of a snapshot event: each change
+// gets the next sequence of its path and is appended to its path stream, then the commit is
+// published as one message "commit\n<sequence> <stream ID> ...\n<snapshot event JSON>".
+//
+// KEYS[2i-1] stream and KEYS[2i] sequence counter of change i; ARGV[1] channel, ARGV[2] max
+// stream length, ARGV[3] snapshot event JSON, then per change the number of stream entry
+// fields and values followed by them
+var publishCommitScript = redis.NewScript(`
+local header = {}
+local results = {}
+local arg = 4
+for i = 1, #KEYS, 2 do
+	local sequence = redis.call('INCR', KEYS[i + 1])
+	local count = tonumber(ARGV[arg])
+	local fields = {'sequenceNumber', sequence}
+	for j = arg + 1, arg + count do
+		fields[#fields + 1] = ARGV[j]
+	end
+	arg = arg + count + 1
+	local id = redis.call('XADD', KEYS[i], 'MAXLEN', '~', ARGV[2], '*', unpack(fields))
+	header[#header + 1] = sequence .. ' ' .. id
+	results[#results + 1] = sequence
+	results[#results + 1] = id
+end
+redis.call('PUBLISH', ARGV[1], 'commit\n' .. table.concat(header, ' ') .. '\n' .. ARGV[3])
+return results
+`)
+
+// commitMessagePrefix starts the messages of publishCommitScript
+const commitMessagePrefix = "commit\n"
+
+// RedisEventFanout implements RealtimeFanout with Redis Streams and pub/sub. Events are
+// stored in the path streams read by RedisEventStore, with the stream entry ID as resume
+// token, so tokens issued by any instance can be resumed on any other.
+//
+// The fan-out needs a single-node Redis (optionally with replicas), not Redis Cluster: a
+// commit updates the streams and sequence counters of all its paths in one script, and
+// those keys are the plain document paths read by RedisEventStore, so they do not share
+// a hash slot.
+type RedisEventFanout struct {
+	store           *RedisEventStore
+	streamMaxLength int64
+	logger          logger.Logger
+}
+
+// NewRedisEventFanout creates a fan-out over the Redis client of the event store; path
+// streams are capped at about streamMaxLength events
+func NewRedisEventFanout(store *RedisEventStore, streamMaxLength int64, log logger.Logger) *RedisEventFanout {
+	if streamMaxLength <= 0 {
+		streamMaxLength = 10000
+	}
+	return &RedisEventFanout{store: store, streamMaxLength: streamMaxLength, logger: log}
+}
+
+// Publish stores the event and publishes it to every instance with its sequence and resume token
+func (f *RedisEventFanout) Publish(ctx context.Context, event model.RealtimeEvent) (model.RealtimeEvent, error) {
+	if event.Type == model.EventTypeSnapshot {
+		return f.publishCommit(ctx, event)
+	}
+	event.ResumeToken = ""
+	event.SequenceNumber = 0
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return event, fmt.Errorf("failed to serialize realtime event: %w", err)
+	}
+	values, err := f.store.eventStreamValues(event)
+	if err != nil {
+		return event, err
+	}
+	delete(values, "sequenceNumber")
+	delete(values, "resumeToken")
+
+	args := []interface{}{RealtimeEventsChannel, f.streamMaxLength, payload}
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+	result, err := publishEventScript.Run(ctx, f.store.client, []string{event.FullPath, realtimeSequenceKeyPrefix + event.FullPath}, args...).Slice()
+	if err != nil {
+		return event, fmt.Errorf("failed to publish realtime event: %w", err)
+	}
+	if len(result) != 2 {
+		return event, fmt.Errorf("unexpected publish result %v", result)
+	}
+	sequence, _ := result[0].(int64)
+	id, _ := result[1].(string)
+	event.SequenceNumber = sequence
+	event.ResumeToken = model.ResumeToken(id)
+	return event, nil
+}
+
+// publishCommit stores the changes of a snapshot event and publishes them as one message.
+// The changes share the stream ID of the last one as resume token: stream IDs order
+// entries across streams, so it resumes every path after the whole commit.
+func (f *RedisEventFanout) publishCommit(ctx context.Context, commit model.RealtimeEvent) (model.RealtimeEvent, error) {
+	commit.ResumeToken = ""
+	commit.SequenceNumber = 0
+	keys := make([]string, 0, 2*len(commit.Changes))
+	var fields []interface{}
+	for i := range commit.Changes {
+		change := &commit.Changes[i]
+		change.ResumeToken = ""
+		change.SequenceNumber = 0
+		values, err := f.store.eventStreamValues(*change)
+		if err != nil {
+			return commit, err
+		}
+		delete(values, "sequenceNumber")
+		delete(values, "resumeToken")
+		keys = append(keys, change.FullPath, realtimeSequenceKeyPrefix+change.FullPath)
+		fields = append(fields, 2*len(values))
+		for field, value := range values {
+			fields = append(fields, field, value)
+		}
+	}
+	payload, err := json.Marshal(commit)
+	if err != nil {
+		return commit, fmt.Errorf("failed to serialize realtime commit: %w", err)
+	}
+
+	args := append([]interface{}{RealtimeEventsChannel, f.streamMaxLength, payload}, fields...)
+	result, err := publishCommitScript.Run(ctx, f.store.client, keys, args...).Slice()
+	if err != nil {
+		return commit, fmt.Errorf("failed to publish realtime commit: %w", err)
+	}
+	if len(result) != 2*len(commit.Changes) {
+		return commit, fmt.Errorf("unexpected publish result %v", result)
+	}
+	for i := range commit.Changes {
+		commit.Changes[i].SequenceNumber, _ = result[2*i].(int64)
+	}
+	lastID, _ := result[len(result)-1].(string)
+	setCommitToken(&commit, model.ResumeToken(lastID))
+	return commit, nil
+}
+
+// setCommitToken gives a snapshot event and its changes the resume token of the commit
+func setCommitToken(commit *model.RealtimeEvent, token model.ResumeToken) {
+	commit.ResumeToken = token
+	for i := range commit.Changes {
+		commit.Changes[i].ResumeToken = token
+	}
+	if len(commit.Changes) > 0 {
+		commit.SequenceNumber = commit.Changes[len(commit.Changes)-1].SequenceNumber
+	}
+}
+
+// Receive delivers the events published by every instance until ctx is done or the
+// subscription fails
+func (f *RedisEventFanout) Receive(ctx context.Context, deliver func(model.RealtimeEvent)) error {
+	pubsub := f.store.client.Subscribe(ctx, RealtimeEventsChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to realtime events: %w", err)
+	}
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("realtime event subscription failed: %w", err)
+		}
+		event, err := parsePublishedEvent(msg.Payload)
+		if err != nil {
+			f.logger.Warn("Failed to parse published realtime event", zap.Error(err))
+			continue
+		}
+		deliver(event)
+	}
+}
+
+// parsePublishedEvent decodes a message published by publishEventScript or publishCommitScript
+func parsePublishedEvent(payload string) (model.RealtimeEvent, error) {
+	if strings.HasPrefix(payload, commitMessagePrefix) {
+		return parsePublishedCommit(strings.TrimPrefix(payload, commitMessagePrefix))
+	}
+	var event model.RealtimeEvent
+	parts := strings.SplitN(payload, "\n", 3)
+	if len(parts) != 3 {
+		return event, fmt.Errorf("malformed realtime event message")
+	}
+	sequence, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return event, fmt.Errorf("invalid realtime event sequence %q", parts[0])
+	}
+	if err := json.Unmarshal([]byte(parts[2]), &event); err != nil {
+		return event, fmt.Errorf("invalid realtime event: %w", err)
+	}
+	event.SequenceNumber = sequence
+	event.ResumeToken = model.ResumeToken(parts[1])
+	return event, nil
+}
+
+// parsePublishedCommit decodes the rest of a message published by publishCommitScript
+func parsePublishedCommit(payload string) (model.RealtimeEvent, error) {
+	var commit model.RealtimeEvent
+	parts := strings.SplitN(payload, "\n", 2)
+	if len(parts) != 2 {
+		return commit, fmt.Errorf("malformed realtime commit message")
+	}
+	if err := json.Unmarshal([]byte(parts[1]), &commit); err != nil {
+		return commit, fmt.Errorf("invalid realtime commit: %w", err)
+	}
+	header := strings.Fields(parts[0])
+	if len(header) != 2*len(commit.Changes) || len(header) == 0 {
+		return commit, fmt.Errorf("realtime commit header does not match its %d changes", len(commit.Changes))
+	}
+	for i := range commit.Changes {
+		sequence, err := strconv.ParseInt(header[2*i], 10, 64)
+		if err != nil {
+			return commit, fmt.Errorf("invalid realtime event sequence %q", header[2*i])
+		}
+		commit.Changes[i].SequenceNumber = sequence
+	}
+	setCommitToken(&commit, model.ResumeToken(header[len(header)-1]))
+	return commit, nil
+}
@@ -0,0 +1,88 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishedEvent(t *testing.T) {
+	event, err := parsePublishedEvent("7\n1700000000000-0\n{\"type\":\"modified\",\"fullPath\":\"projects/p/databases/d/documents/users/u1\",\"data\":{\"name\":\"Ada\"}}")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), event.SequenceNumber)
+	assert.Equal(t, model.ResumeToken("1700000000000-0"), event.ResumeToken)
+	assert.Equal(t, model.EventTypeModified, event.Type)
+	assert.Equal(t, "Ada", event.Data["name"])
+
+	commit, err := parsePublishedEvent("commit\n3 1700000000000-0 5 1700000000000-1\n{\"type\":\"snapshot\",\"changes\":[{\"fullPath\":\"a/1\"},{\"fullPath\":\"a/2\"}]}")
+	require.NoError(t, err)
+	assert.Equal(t, model.EventTypeSnapshot, commit.Type)
+	require.Len(t, commit.Changes, 2)
+	assert.Equal(t, int64(3), commit.Changes[0].SequenceNumber)
+	assert.Equal(t, int64(5), commit.Changes[1].SequenceNumber)
+	assert.Equal(t, model.ResumeToken("1700000000000-1"), commit.Changes[0].ResumeToken)
+	assert.Equal(t, model.ResumeToken("1700000000000-1"), commit.ResumeToken)
+	_, err = parsePublishedEvent("commit\n3 1700000000000-0\n{\"type\":\"snapshot\"}")
+	assert.Error(t, err)
+
+	_, err = parsePublishedEvent("not a message")
+	assert.Error(t, err)
+	_, err = parsePublishedEvent("x\n1-0\n{}")
+	assert.Error(t, err)
+}
+
+// TestRedisEventFanout_PublishReceiveResume tests sequences, delivery and resume tokens across two instances
+func TestRedisEventFanout_PublishReceiveResume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	client := createTestRedisClient()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available for testing:", err)
+	}
+	defer func() {
+		client.FlushDB(context.Background())
+		client.Close()
+	}()
+
+	log := logger.NewLogger()
+	storeA, storeB := NewRedisEventStore(client, log), NewRedisEventStore(client, log)
+	instanceA := NewRedisEventFanout(storeA, 100, log)
+	instanceB := NewRedisEventFanout(storeB, 100, log)
+
+	received := make(chan model.RealtimeEvent, 10)
+	receiveCtx, stopReceiving := context.WithCancel(ctx)
+	defer stopReceiving()
+	go instanceB.Receive(receiveCtx, func(event model.RealtimeEvent) { received <- event })
+	time.Sleep(200 * time.Millisecond) // Let the subscription settle
+
+	path := "projects/test-project/databases/test-db/documents/users/fanout"
+	first, err := instanceA.Publish(ctx, model.RealtimeEvent{Type: model.EventTypeAdded, FullPath: path, Data: map[string]interface{}{"n": "1"}, Timestamp: time.Now()})
+	require.NoError(t, err)
+	second, err := instanceB.Publish(ctx, model.RealtimeEvent{Type: model.EventTypeModified, FullPath: path, Data: map[string]interface{}{"n": "2"}, Timestamp: time.Now()})
+	require.NoError(t, err)
+	assert.Equal(t, first.SequenceNumber+1, second.SequenceNumber)
+
+	for _, expected := range []model.RealtimeEvent{first, second} {
+		select {
+		case event := <-received:
+			assert.Equal(t, expected.SequenceNumber, event.SequenceNumber)
+			assert.Equal(t, expected.ResumeToken, event.ResumeToken)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for published event")
+		}
+	}
+
+	// A token issued while publishing on A resumes from the store of B
+	events, err := storeB.GetEventsSince(ctx, path, first.ResumeToken)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, second.SequenceNumber, events[0].SequenceNumber)
+	assert.Equal(t, "2", events[0].Data["n"])
+}
@@ -31,16 +31,8 @@ func NewRedisEventStore(client *redis.Client, log logger.Logger) *RedisEventStor
 
 // StoreEvent stores a RealtimeEvent in Redis Streams with Firestore-compatible structure
 func (r *RedisEventStore) StoreEvent(ctx context.Context, event model.RealtimeEvent) error {
-	// Serialize event data to JSON for Redis storage
-	eventData, err := json.Marshal(event.Data)
-	if err != nil {
-		r.logger.Error("Failed to serialize event data", zap.Error(err))
-		return err
-	}
-
-	oldData, err := json.Marshal(event.OldData)
+	values, err := r.eventStreamValues(event)
 	if err != nil {
-		r.logger.Error("Failed to serialize old data", zap.Error(err))
 		return err
 	}
 
@@ -50,19 +42,7 @@ func (r *RedisEventStore) StoreEvent(ctx context.Context, event model.RealtimeEv
 	// Store event in Redis Stream with all Firestore event fields
 	_, err = r.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: streamName,
-		Values: map[string]interface{}{
-			"type":           string(event.Type),
-			"fullPath":       event.FullPath,
-			"projectId":      event.ProjectID,
-			"databaseId":     event.DatabaseID,
-			"documentPath":   event.DocumentPath,
-			"data":           eventData,
-			"oldData":        oldData,
-			"timestamp":      event.Timestamp.UnixNano(),
-			"resumeToken":    string(event.ResumeToken),
-			"sequenceNumber": event.SequenceNumber,
-			"subscriptionId": event.SubscriptionID,
-		},
+		Values: values,
 	}).Result()
 
 	if err != nil {
@@ -81,6 +61,36 @@ func (r *RedisEventStore) StoreEvent(ctx context.Context, event model.RealtimeEv
 	return nil
 }
 
+// eventStreamValues returns the stream entry fields of an event
+func (r *RedisEventStore) eventStreamValues(event model.RealtimeEvent) (map[string]interface{}, error) {
+	// Serialize event data to JSON for Redis storage
+	eventData, err := json.Marshal(event.Data)
+	if err != nil {
+		r.logger.Error("Failed to serialize event data", zap.Error(err))
+		return nil, err
+	}
+
+	oldData, err := json.Marshal(event.OldData)
+	if err != nil {
+		r.logger.Error("Failed to serialize old data", zap.Error(err))
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"type":           string(event.Type),
+		"fullPath":       event.FullPath,
+		"projectId":      event.ProjectID,
+		"databaseId":     event.DatabaseID,
+		"documentPath":   event.DocumentPath,
+		"data":           eventData,
+		"oldData":        oldData,
+		"timestamp":      event.Timestamp.UnixNano(),
+		"resumeToken":    string(event.ResumeToken),
+		"sequenceNumber": event.SequenceNumber,
+		"subscriptionId": event.SubscriptionID,
+	}, nil
+}
+
 // GetEventsSince retrieves events after a resume token with Firestore-compatible semantics
 func (r *RedisEventStore) GetEventsSince(ctx context.Context, firestorePath string, resumeToken model.ResumeToken) ([]model.RealtimeEvent, error) {
 	streamName := firestorePath
@@ -0,0 +1,70 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ListenerRevalidationChannel is the Redis pub/sub channel carrying listener revalidations
+// between server instances
+const ListenerRevalidationChannel = "firestore:security:revalidate"
+
+// RedisListenerRevalidationFanout implements ListenerRevalidationFanout with Redis pub/sub.
+// A revalidation is not stored: instances which are not subscribed when it is published
+// have no listeners it could concern.
+type RedisListenerRevalidationFanout struct {
+	client *redis.Client
+	logger logger.Logger
+}
+
+// NewRedisListenerRevalidationFanout creates the revalidation fan-out over a Redis client
+func NewRedisListenerRevalidationFanout(client *redis.Client, log logger.Logger) *RedisListenerRevalidationFanout {
+	return &RedisListenerRevalidationFanout{client: client, logger: log}
+}
+
+// Publish sends a revalidation to every instance
+func (f *RedisListenerRevalidationFanout) Publish(ctx context.Context, revalidation usecase.ListenerRevalidation) error {
+	payload, err := json.Marshal(revalidation)
+	if err != nil {
+		return fmt.Errorf("failed to serialize listener revalidation: %w", err)
+	}
+	if err := f.client.Publish(ctx, ListenerRevalidationChannel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish listener revalidation: %w", err)
+	}
+	return nil
+}
+
+// Receive delivers the revalidations published by every instance until ctx is done or the
+// subscription fails
+func (f *RedisListenerRevalidationFanout) Receive(ctx context.Context, deliver func(usecase.ListenerRevalidation)) error {
+	pubsub := f.client.Subscribe(ctx, ListenerRevalidationChannel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to listener revalidations: %w", err)
+	}
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("listener revalidation subscription failed: %w", err)
+		}
+		var revalidation usecase.ListenerRevalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &revalidation); err != nil {
+			f.logger.Warn("Failed to parse published listener revalidation", zap.Error(err))
+			continue
+		}
+		deliver(revalidation)
+	}
+}
+
+var _ usecase.ListenerRevalidationFanout = (*RedisListenerRevalidationFanout)(nil)
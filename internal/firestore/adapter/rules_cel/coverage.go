@@ -0,0 +1,150 @@
+package rules_cel
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"firestore-clone/internal/firestore/domain/repository"
+)
+
+// CoverageStore keeps the rules coverage of each database in memory. Coverage is a
+// debugging aid, so it is lost on restart and not shared across instances.
+//
+// Recording is on the path of every evaluated request: the counters of each database,
+// rule and condition are created once and then only incremented atomically, so requests
+// on different databases or rules never wait for each other.
+type CoverageStore struct {
+	databases sync.Map // coverageKey -> *databaseCoverage
+}
+
+// databaseCoverage holds the counters of the rules of a database
+type databaseCoverage struct {
+	rules sync.Map // match -> *ruleCounters
+}
+
+// ruleCounters holds the counters of a rule and of its conditions
+type ruleCounters struct {
+	hits       atomic.Int64
+	conditions sync.Map // condition key -> *conditionCounters
+}
+
+// conditionCounters counts the outcomes of a condition
+type conditionCounters struct {
+	effect    string
+	operation repository.OperationType
+	true      atomic.Int64
+	false     atomic.Int64
+	error     atomic.Int64
+}
+
+// NewCoverageStore creates an empty coverage store
+func NewCoverageStore() *CoverageStore {
+	return &CoverageStore{}
+}
+
+var _ repository.RulesCoverageStore = (*CoverageStore)(nil)
+
+// RecordRule implements repository.RulesCoverageRecorder
+func (s *CoverageStore) RecordRule(projectID, databaseID, match string) {
+	s.database(projectID, databaseID).rule(match).hits.Add(1)
+}
+
+// RecordCondition implements repository.RulesCoverageRecorder
+func (s *CoverageStore) RecordCondition(projectID, databaseID, match, effect string, operation repository.OperationType, outcome repository.ConditionOutcome) {
+	s.database(projectID, databaseID).rule(match).condition(effect, operation).record(outcome)
+}
+
+// RecordSimulation implements repository.RulesCoverageStore
+func (s *CoverageStore) RecordSimulation(projectID, databaseID string, result *repository.SimulationResult) {
+	database := s.database(projectID, databaseID)
+	for _, match := range result.Matches {
+		rule := database.rule(match.Match)
+		rule.hits.Add(1)
+		for _, condition := range match.Conditions {
+			outcome := repository.ConditionFalse
+			switch {
+			case condition.Error != "":
+				outcome = repository.ConditionError
+			case condition.Result:
+				outcome = repository.ConditionTrue
+			}
+			rule.condition(condition.Effect, condition.Operation).record(outcome)
+		}
+	}
+}
+
+// Coverage implements repository.RulesCoverageStore. Counters keep moving while they
+// are read, so the copy is a snapshot of each counter rather than of the database.
+func (s *CoverageStore) Coverage(projectID, databaseID string) *repository.RulesCoverage {
+	coverage := repository.NewRulesCoverage(projectID, databaseID)
+	value, ok := s.databases.Load(coverageKey(projectID, databaseID))
+	if !ok {
+		return coverage
+	}
+	value.(*databaseCoverage).rules.Range(func(match, value any) bool {
+		counters := value.(*ruleCounters)
+		rule := coverage.Rule(match.(string))
+		rule.Hits = counters.hits.Load()
+		counters.conditions.Range(func(_, value any) bool {
+			condition := value.(*conditionCounters)
+			snapshot := rule.Condition(condition.effect, condition.operation)
+			snapshot.True = condition.true.Load()
+			snapshot.False = condition.false.Load()
+			snapshot.Error = condition.error.Load()
+			return true
+		})
+		return true
+	})
+	return coverage
+}
+
+// Reset implements repository.RulesCoverageStore. Requests being recorded while the
+// coverage is reset may be counted in the forgotten coverage.
+func (s *CoverageStore) Reset(projectID, databaseID string) {
+	s.databases.Delete(coverageKey(projectID, databaseID))
+}
+
+// database returns the counters of a database, adding them on its first request
+func (s *CoverageStore) database(projectID, databaseID string) *databaseCoverage {
+	key := coverageKey(projectID, databaseID)
+	if value, ok := s.databases.Load(key); ok {
+		return value.(*databaseCoverage)
+	}
+	value, _ := s.databases.LoadOrStore(key, &databaseCoverage{})
+	return value.(*databaseCoverage)
+}
+
+// rule returns the counters of a rule, adding them when the rule was never matched
+func (d *databaseCoverage) rule(match string) *ruleCounters {
+	if value, ok := d.rules.Load(match); ok {
+		return value.(*ruleCounters)
+	}
+	value, _ := d.rules.LoadOrStore(match, &ruleCounters{})
+	return value.(*ruleCounters)
+}
+
+// condition returns the counters of a condition of the rule, adding them when the
+// condition was never evaluated
+func (r *ruleCounters) condition(effect string, operation repository.OperationType) *conditionCounters {
+	key := repository.ConditionKey(effect, operation)
+	if value, ok := r.conditions.Load(key); ok {
+		return value.(*conditionCounters)
+	}
+	value, _ := r.conditions.LoadOrStore(key, &conditionCounters{effect: effect, operation: operation})
+	return value.(*conditionCounters)
+}
+
+func (c *conditionCounters) record(outcome repository.ConditionOutcome) {
+	switch outcome {
+	case repository.ConditionTrue:
+		c.true.Add(1)
+	case repository.ConditionFalse:
+		c.false.Add(1)
+	default:
+		c.error.Add(1)
+	}
+}
+
+func coverageKey(projectID, databaseID string) string {
+	return projectID + ":" + databaseID
+}
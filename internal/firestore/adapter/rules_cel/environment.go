@@ -0,0 +1,91 @@
+// Package rules_cel implements the Firestore security rules language on top of CEL:
+// the standard library of the rules (map diffs, sets, timestamps, durations, math,
+// hashing, latlng and paths), the rules syntax CEL lacks (`x is string` and path
+// literals) and the get() and exists() document lookups.
+package rules_cel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/env"
+)
+
+// Variables declared in every rules environment
+const (
+	AuthVariable      = "auth"
+	RequestVariable   = "request"
+	ResourceVariable  = "resource"
+	PathVariable      = "path"
+	VariablesVariable = "variables"
+)
+
+// NewEnvironment creates the CEL environment for Firestore security rules conditions
+func NewEnvironment() (*cel.Env, error) {
+	return cel.NewCustomEnv(
+		// The rules string.matches() matches the whole string, unlike the CEL one
+		cel.StdLib(cel.StdLibSubset(&env.LibrarySubset{
+			ExcludeFunctions: []*env.Function{env.NewFunction("matches")},
+		})),
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable(AuthVariable, cel.DynType),
+		cel.Variable(RequestVariable, cel.DynType),
+		cel.Variable(ResourceVariable, cel.DynType),
+		cel.Variable(PathVariable, cel.StringType),
+		cel.Variable(VariablesVariable, cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable(EvaluationVariable, evaluationType),
+		cel.Lib(rulesLibrary{}),
+	)
+}
+
+// Compile compiles a rules condition into a program. The wildcards of the rule match,
+// such as userId in /users/{userId}, are declared as string variables.
+func Compile(rulesEnv *cel.Env, condition string, wildcards []string) (cel.Program, error) {
+	program, _, err := compile(rulesEnv, condition, wildcards)
+	return program, err
+}
+
+// compile compiles a rules condition, returning its program and checked AST
+func compile(rulesEnv *cel.Env, condition string, wildcards []string, programOptions ...cel.ProgramOption) (cel.Program, *cel.Ast, error) {
+	expression, err := RewriteSyntax(condition)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rules syntax error: %w", err)
+	}
+
+	var declarations []cel.EnvOption
+	for _, wildcard := range wildcards {
+		// Recursive wildcards such as {document=**} are declared by their name
+		name := strings.SplitN(wildcard, "=", 2)[0]
+		if isDeclared(name) {
+			continue
+		}
+		declarations = append(declarations, cel.Variable(name, cel.StringType))
+	}
+	if len(declarations) > 0 {
+		if rulesEnv, err = rulesEnv.Extend(declarations...); err != nil {
+			return nil, nil, fmt.Errorf("failed to declare match wildcards: %w", err)
+		}
+	}
+
+	ast, issues := rulesEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, nil, fmt.Errorf("CEL compilation error: %w", issues.Err())
+	}
+
+	program, err := rulesEnv.Program(ast, programOptions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CEL program: %w", err)
+	}
+	return program, ast, nil
+}
+
+// isDeclared reports whether a wildcard name is already a variable of the environment;
+// its value then replaces the variable at evaluation, as in Firestore
+func isDeclared(name string) bool {
+	switch name {
+	case AuthVariable, RequestVariable, ResourceVariable, PathVariable, VariablesVariable, EvaluationVariable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package rules_cel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/repository"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// EvaluationVariable holds the Evaluation of a condition. get() and exists() are
+// macros which expand to calls on it, so compiled programs can be shared across
+// evaluations of different projects.
+const EvaluationVariable = "__evaluation"
+
+var evaluationType = cel.OpaqueType("firestore.rules.Evaluation")
+
+// Evaluation carries what get() and exists() need to read documents while a
+// condition is evaluated
+type Evaluation struct {
+	Context    context.Context
+	Accessor   repository.ResourceAccessor
+	ProjectID  string
+	DatabaseID string
+
+	// Documents mocks documents by path relative to the database; a nil document does
+	// not exist. Paths which are not mocked are read through the accessor.
+	Documents map[string]map[string]interface{}
+	// TrackLookups records the get() and exists() calls in Lookups
+	TrackLookups bool
+	Lookups      []*repository.DocumentLookupTrace
+}
+
+// Bind adds the evaluation to the variables of a condition
+func (e *Evaluation) Bind(vars map[string]interface{}) map[string]interface{} {
+	vars[EvaluationVariable] = e
+	return vars
+}
+
+// ConvertToNative implements ref.Val
+func (e *Evaluation) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return nil, fmt.Errorf("unsupported conversion of rules evaluation to %v", typeDesc)
+}
+
+// ConvertToType implements ref.Val
+func (e *Evaluation) ConvertToType(typeValue ref.Type) ref.Val {
+	if typeValue == types.TypeType {
+		return evaluationType
+	}
+	return types.NewErr("unsupported conversion of rules evaluation to %s", typeValue.TypeName())
+}
+
+// Equal implements ref.Val
+func (e *Evaluation) Equal(other ref.Val) ref.Val {
+	return types.Bool(e == other)
+}
+
+// Type implements ref.Val
+func (e *Evaluation) Type() ref.Type {
+	return evaluationType
+}
+
+// Value implements ref.Val
+func (e *Evaluation) Value() any {
+	return e
+}
+
+// get returns the resource at a document path: its data, id and __name__. Reading a
+// document which does not exist is an error, which denies the request.
+func (e *Evaluation) get(pathValue ref.Val) ref.Val {
+	documentPath, err := documentPathOf(pathValue)
+	if err != nil {
+		return types.NewErr("get(): %v", err)
+	}
+
+	data, mocked, err := e.getDocument(documentPath)
+	e.recordLookup("get", documentPath, data != nil, mocked, err)
+	if err != nil {
+		return types.NewErr("get(%s): %v", documentPath, err)
+	}
+	if data == nil {
+		return types.NewErr("get(%s): document does not exist", documentPath)
+	}
+
+	segments := strings.Split(documentPath, "/")
+	return types.DefaultTypeAdapter.NativeToValue(map[string]interface{}{
+		"data":     data,
+		"id":       segments[len(segments)-1],
+		"__name__": string(pathValue.(types.String)),
+	})
+}
+
+// exists reports whether a document exists at a path
+func (e *Evaluation) exists(pathValue ref.Val) ref.Val {
+	documentPath, err := documentPathOf(pathValue)
+	if err != nil {
+		return types.NewErr("exists(): %v", err)
+	}
+
+	var exists, mocked bool
+	if document, ok := e.Documents[documentPath]; ok {
+		exists, mocked = document != nil, true
+	} else if e.Accessor == nil {
+		err = errNoAccessor
+	} else {
+		exists, err = e.Accessor.ExistsDocument(e.ctx(), e.ProjectID, e.DatabaseID, documentPath)
+	}
+	e.recordLookup("exists", documentPath, exists, mocked, err)
+	if err != nil {
+		return types.NewErr("exists(%s): %v", documentPath, err)
+	}
+	return types.Bool(exists)
+}
+
+// errNoAccessor is the error of the lookups of documents which are not mocked when the
+// evaluation has no accessor
+var errNoAccessor = errors.New("document lookups are not available: no resource accessor")
+
+// getDocument reads a document, mocked or through the accessor
+func (e *Evaluation) getDocument(documentPath string) (map[string]interface{}, bool, error) {
+	if document, ok := e.Documents[documentPath]; ok {
+		return document, true, nil
+	}
+	if e.Accessor == nil {
+		return nil, false, errNoAccessor
+	}
+	data, err := e.Accessor.GetDocument(e.ctx(), e.ProjectID, e.DatabaseID, documentPath)
+	return data, false, err
+}
+
+func (e *Evaluation) recordLookup(function, documentPath string, found, mocked bool, err error) {
+	if !e.TrackLookups {
+		return
+	}
+	lookup := &repository.DocumentLookupTrace{Function: function, Path: documentPath, Found: found, Mocked: mocked}
+	if err != nil {
+		lookup.Error = err.Error()
+	}
+	e.Lookups = append(e.Lookups, lookup)
+}
+
+func (e *Evaluation) ctx() context.Context {
+	if e.Context == nil {
+		return context.Background()
+	}
+	return e.Context
+}
+
+// documentPathOf returns the document path relative to the database, removing the
+// /databases/{database}/documents prefix of rules paths
+func documentPathOf(pathValue ref.Val) (string, error) {
+	value, ok := pathValue.(types.String)
+	if !ok {
+		return "", fmt.Errorf("expected a path, got %s", pathValue.Type().TypeName())
+	}
+
+	documentPath := strings.Trim(string(value), "/")
+	if strings.HasPrefix(documentPath, "databases/") {
+		parts := strings.SplitN(documentPath, "/", 4)
+		if len(parts) < 4 || parts[2] != "documents" {
+			return "", fmt.Errorf("invalid document path %q", string(value))
+		}
+		documentPath = parts[3]
+	}
+	if documentPath == "" || strings.Count(documentPath, "/")%2 != 1 {
+		return "", fmt.Errorf("invalid document path %q", string(value))
+	}
+	return documentPath, nil
+}
+
+// Members of the evaluation behind get() and exists(). They cannot share the names of
+// the global functions: a member exists() would overlap the list exists() macro.
+const (
+	getDocumentFunction    = "getDocument"
+	existsDocumentFunction = "existsDocument"
+)
+
+// documentLookupMacros expand get(p) and exists(p) into calls on the evaluation
+func documentLookupMacros() []cel.Macro {
+	lookup := func(macro, function string) cel.Macro {
+		return cel.GlobalMacro(macro, 1, func(eh cel.MacroExprFactory, target ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+			return eh.NewMemberCall(function, eh.NewIdent(EvaluationVariable), args...), nil
+		})
+	}
+	return []cel.Macro{lookup("get", getDocumentFunction), lookup("exists", existsDocumentFunction)}
+}
+
+// documentLookupFunctions declares the evaluation members behind get() and exists()
+func documentLookupFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function(getDocumentFunction,
+			cel.MemberOverload("evaluation_get_document_string", []*cel.Type{evaluationType, cel.StringType}, cel.DynType,
+				cel.BinaryBinding(func(evaluation, pathValue ref.Val) ref.Val {
+					return evaluation.(*Evaluation).get(pathValue)
+				}))),
+		cel.Function(existsDocumentFunction,
+			cel.MemberOverload("evaluation_exists_document_string", []*cel.Type{evaluationType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(evaluation, pathValue ref.Val) ref.Val {
+					return evaluation.(*Evaluation).exists(pathValue)
+				}))),
+	}
+}
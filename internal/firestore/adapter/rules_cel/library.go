@@ -0,0 +1,624 @@
+package rules_cel
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+var (
+	listType = cel.ListType(cel.DynType)
+	mapType  = cel.MapType(cel.DynType, cel.DynType)
+)
+
+// rulesLibrary is the Firestore rules standard library as a CEL library. Sets are lists
+// without duplicates in a canonical order, so set equality is list equality, and paths
+// are strings.
+type rulesLibrary struct{}
+
+// LibraryName implements cel.SingletonLibrary
+func (rulesLibrary) LibraryName() string {
+	return "firestore.rules"
+}
+
+// CompileOptions implements cel.Library
+func (rulesLibrary) CompileOptions() []cel.EnvOption {
+	var options []cel.EnvOption
+	options = append(options, cel.Macros(documentLookupMacros()...))
+	options = append(options, documentLookupFunctions()...)
+	options = append(options, mapFunctions()...)
+	options = append(options, listFunctions()...)
+	options = append(options, stringFunctions()...)
+	options = append(options, bytesFunctions()...)
+	options = append(options, numberFunctions()...)
+	options = append(options, mathFunctions()...)
+	options = append(options, hashingFunctions()...)
+	options = append(options, timestampFunctions()...)
+	options = append(options, durationFunctions()...)
+	options = append(options, latLngFunctions()...)
+	options = append(options, pathFunctions()...)
+	options = append(options, typeFunctions()...)
+	return options
+}
+
+// ProgramOptions implements cel.Library
+func (rulesLibrary) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// Map functions: keys(), values(), get() and diff()
+
+func mapFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("keys",
+			cel.MemberOverload("map_keys", []*cel.Type{mapType}, listType,
+				cel.UnaryBinding(func(m ref.Val) ref.Val {
+					keys, _ := mapEntries(m.(traits.Mapper))
+					return newList(keys)
+				}))),
+		cel.Function("values",
+			cel.MemberOverload("map_values", []*cel.Type{mapType}, listType,
+				cel.UnaryBinding(func(m ref.Val) ref.Val {
+					_, values := mapEntries(m.(traits.Mapper))
+					return newList(values)
+				}))),
+		cel.Function("get",
+			cel.MemberOverload("map_get_key_default", []*cel.Type{mapType, cel.DynType, cel.DynType}, cel.DynType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return mapGet(args[0].(traits.Mapper), args[1], args[2])
+				}))),
+		cel.Function("diff",
+			cel.MemberOverload("map_diff_map", []*cel.Type{mapType, mapType}, mapDiffType,
+				cel.BinaryBinding(func(m, other ref.Val) ref.Val {
+					return newMapDiff(m.(traits.Mapper), other.(traits.Mapper))
+				}))),
+		cel.Function("addedKeys",
+			cel.MemberOverload("mapdiff_added_keys", []*cel.Type{mapDiffType}, listType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val { return newSet(d.(*mapDiff).added) }))),
+		cel.Function("removedKeys",
+			cel.MemberOverload("mapdiff_removed_keys", []*cel.Type{mapDiffType}, listType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val { return newSet(d.(*mapDiff).removed) }))),
+		cel.Function("changedKeys",
+			cel.MemberOverload("mapdiff_changed_keys", []*cel.Type{mapDiffType}, listType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val { return newSet(d.(*mapDiff).changed) }))),
+		cel.Function("unchangedKeys",
+			cel.MemberOverload("mapdiff_unchanged_keys", []*cel.Type{mapDiffType}, listType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val { return newSet(d.(*mapDiff).unchanged) }))),
+		cel.Function("affectedKeys",
+			cel.MemberOverload("mapdiff_affected_keys", []*cel.Type{mapDiffType}, listType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val {
+					diff := d.(*mapDiff)
+					affected := append(append(append([]ref.Val{}, diff.added...), diff.removed...), diff.changed...)
+					return newSet(affected)
+				}))),
+	}
+}
+
+// mapGet returns the value at a key, or at a list of nested keys, or the default
+func mapGet(m traits.Mapper, key, defaultValue ref.Val) ref.Val {
+	keys := []ref.Val{key}
+	if list, ok := key.(traits.Lister); ok {
+		keys = listValues(list)
+	}
+
+	var current ref.Val = m
+	for _, k := range keys {
+		mapper, ok := current.(traits.Mapper)
+		if !ok {
+			return defaultValue
+		}
+		value, found := mapper.Find(k)
+		if !found {
+			return defaultValue
+		}
+		current = value
+	}
+	return current
+}
+
+// List and set functions
+
+func listFunctions() []cel.EnvOption {
+	binaryListOp := func(name string, result *cel.Type, op func(list, other []ref.Val) ref.Val) cel.EnvOption {
+		return cel.Function(name,
+			cel.MemberOverload("list_"+strings.ToLower(name)+"_list", []*cel.Type{listType, listType}, result,
+				cel.BinaryBinding(func(list, other ref.Val) ref.Val {
+					return op(listValues(list.(traits.Lister)), listValues(other.(traits.Lister)))
+				})))
+	}
+
+	return []cel.EnvOption{
+		binaryListOp("hasAll", cel.BoolType, func(list, other []ref.Val) ref.Val {
+			return types.Bool(containsAll(list, other))
+		}),
+		binaryListOp("hasOnly", cel.BoolType, func(list, other []ref.Val) ref.Val {
+			return types.Bool(containsAll(other, list))
+		}),
+		binaryListOp("hasAny", cel.BoolType, func(list, other []ref.Val) ref.Val {
+			for _, value := range other {
+				if containsValue(list, value) {
+					return types.True
+				}
+			}
+			return types.False
+		}),
+		binaryListOp("removeAll", listType, func(list, other []ref.Val) ref.Val {
+			return newList(without(list, other))
+		}),
+		binaryListOp("concat", listType, func(list, other []ref.Val) ref.Val {
+			return newList(append(append([]ref.Val{}, list...), other...))
+		}),
+		binaryListOp("difference", listType, func(list, other []ref.Val) ref.Val {
+			return newSet(without(list, other))
+		}),
+		binaryListOp("intersection", listType, func(list, other []ref.Val) ref.Val {
+			var common []ref.Val
+			for _, value := range list {
+				if containsValue(other, value) {
+					common = append(common, value)
+				}
+			}
+			return newSet(common)
+		}),
+		binaryListOp("union", listType, func(list, other []ref.Val) ref.Val {
+			return newSet(append(append([]ref.Val{}, list...), other...))
+		}),
+		cel.Function("toSet",
+			cel.MemberOverload("list_to_set", []*cel.Type{listType}, listType,
+				cel.UnaryBinding(func(list ref.Val) ref.Val {
+					return newSet(listValues(list.(traits.Lister)))
+				}))),
+		cel.Function("join",
+			cel.MemberOverload("list_join_string", []*cel.Type{listType, cel.StringType}, cel.StringType,
+				cel.BinaryBinding(func(list, separator ref.Val) ref.Val {
+					values := listValues(list.(traits.Lister))
+					parts := make([]string, len(values))
+					for i, value := range values {
+						s, ok := value.(types.String)
+						if !ok {
+							return types.NewErr("join() requires a list of strings")
+						}
+						parts[i] = string(s)
+					}
+					return types.String(strings.Join(parts, string(separator.(types.String))))
+				}))),
+	}
+}
+
+// String functions
+
+func stringFunctions() []cel.EnvOption {
+	unary := func(name string, result *cel.Type, op func(string) ref.Val) cel.EnvOption {
+		return cel.Function(name,
+			cel.MemberOverload("string_"+strings.ToLower(name), []*cel.Type{cel.StringType}, result,
+				cel.UnaryBinding(func(s ref.Val) ref.Val { return op(string(s.(types.String))) })))
+	}
+
+	return []cel.EnvOption{
+		unary("lower", cel.StringType, func(s string) ref.Val { return types.String(strings.ToLower(s)) }),
+		unary("upper", cel.StringType, func(s string) ref.Val { return types.String(strings.ToUpper(s)) }),
+		unary("trim", cel.StringType, func(s string) ref.Val { return types.String(strings.TrimSpace(s)) }),
+		unary("toUtf8", cel.BytesType, func(s string) ref.Val { return types.Bytes(s) }),
+		cel.Function("matches",
+			cel.MemberOverload("string_matches_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(s, pattern ref.Val) ref.Val {
+					re, err := regexp.Compile("^(?:" + string(pattern.(types.String)) + ")$")
+					if err != nil {
+						return types.NewErr("matches(): %v", err)
+					}
+					return types.Bool(re.MatchString(string(s.(types.String))))
+				}))),
+		cel.Function("replace",
+			cel.MemberOverload("string_replace_string_string", []*cel.Type{cel.StringType, cel.StringType, cel.StringType}, cel.StringType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					re, err := regexp.Compile(string(args[1].(types.String)))
+					if err != nil {
+						return types.NewErr("replace(): %v", err)
+					}
+					return types.String(re.ReplaceAllString(string(args[0].(types.String)), string(args[2].(types.String))))
+				}))),
+		cel.Function("split",
+			cel.MemberOverload("string_split_string", []*cel.Type{cel.StringType, cel.StringType}, listType,
+				cel.BinaryBinding(func(s, pattern ref.Val) ref.Val {
+					re, err := regexp.Compile(string(pattern.(types.String)))
+					if err != nil {
+						return types.NewErr("split(): %v", err)
+					}
+					return types.NewStringList(types.DefaultTypeAdapter, re.Split(string(s.(types.String)), -1))
+				}))),
+	}
+}
+
+// Bytes functions
+
+func bytesFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("toBase64",
+			cel.MemberOverload("bytes_to_base64", []*cel.Type{cel.BytesType}, cel.StringType,
+				cel.UnaryBinding(func(b ref.Val) ref.Val {
+					return types.String(base64.StdEncoding.EncodeToString(b.(types.Bytes)))
+				}))),
+		cel.Function("toHexString",
+			cel.MemberOverload("bytes_to_hex_string", []*cel.Type{cel.BytesType}, cel.StringType,
+				cel.UnaryBinding(func(b ref.Val) ref.Val {
+					return types.String(strings.ToUpper(hex.EncodeToString(b.(types.Bytes))))
+				}))),
+	}
+}
+
+// Number conversions: the rules float() is the CEL double()
+
+func numberFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("float",
+			cel.Overload("float_int", []*cel.Type{cel.IntType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return v.ConvertToType(types.DoubleType) })),
+			cel.Overload("float_double", []*cel.Type{cel.DoubleType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return v })),
+			cel.Overload("float_string", []*cel.Type{cel.StringType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return v.ConvertToType(types.DoubleType) }))),
+	}
+}
+
+// math namespace
+
+func mathFunctions() []cel.EnvOption {
+	doubleOp := func(name string, op func(float64) ref.Val) cel.EnvOption {
+		id := "math_" + strings.ToLower(name)
+		return cel.Function("math."+name,
+			cel.Overload(id+"_int", []*cel.Type{cel.IntType}, cel.DynType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return op(float64(v.(types.Int))) })),
+			cel.Overload(id+"_double", []*cel.Type{cel.DoubleType}, cel.DynType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return op(float64(v.(types.Double))) })))
+	}
+
+	return []cel.EnvOption{
+		cel.Function("math.abs",
+			cel.Overload("math_abs_int", []*cel.Type{cel.IntType}, cel.IntType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val {
+					if i := v.(types.Int); i < 0 {
+						return -i
+					}
+					return v
+				})),
+			cel.Overload("math_abs_double", []*cel.Type{cel.DoubleType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return types.Double(math.Abs(float64(v.(types.Double)))) }))),
+		doubleOp("ceil", func(f float64) ref.Val { return types.Double(math.Ceil(f)) }),
+		doubleOp("floor", func(f float64) ref.Val { return types.Double(math.Floor(f)) }),
+		doubleOp("round", func(f float64) ref.Val { return types.Double(math.Round(f)) }),
+		doubleOp("sqrt", func(f float64) ref.Val { return types.Double(math.Sqrt(f)) }),
+		doubleOp("isInfinite", func(f float64) ref.Val { return types.Bool(math.IsInf(f, 0)) }),
+		doubleOp("isNaN", func(f float64) ref.Val { return types.Bool(math.IsNaN(f)) }),
+		cel.Function("math.pow",
+			cel.Overload("math_pow_dyn_dyn", []*cel.Type{cel.DynType, cel.DynType}, cel.DynType,
+				cel.BinaryBinding(func(base, exponent ref.Val) ref.Val {
+					b, ok1 := toFloat(base)
+					e, ok2 := toFloat(exponent)
+					if !ok1 || !ok2 {
+						return types.NewErr("math.pow() requires numbers")
+					}
+					return types.Double(math.Pow(b, e))
+				}))),
+	}
+}
+
+// hashing namespace
+
+func hashingFunctions() []cel.EnvOption {
+	hashOp := func(name string, sum func([]byte) []byte) cel.EnvOption {
+		id := "hashing_" + name
+		return cel.Function("hashing."+name,
+			cel.Overload(id+"_string", []*cel.Type{cel.StringType}, cel.BytesType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return types.Bytes(sum([]byte(v.(types.String)))) })),
+			cel.Overload(id+"_bytes", []*cel.Type{cel.BytesType}, cel.BytesType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return types.Bytes(sum(v.(types.Bytes))) })))
+	}
+	return []cel.EnvOption{
+		hashOp("crc32", func(b []byte) []byte {
+			h := crc32.NewIEEE()
+			h.Write(b)
+			return h.Sum(nil)
+		}),
+		hashOp("crc32c", func(b []byte) []byte {
+			h := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+			h.Write(b)
+			return h.Sum(nil)
+		}),
+		hashOp("md5", func(b []byte) []byte {
+			sum := md5.Sum(b)
+			return sum[:]
+		}),
+		hashOp("sha256", func(b []byte) []byte {
+			sum := sha256.Sum256(b)
+			return sum[:]
+		}),
+	}
+}
+
+// timestamp namespace and Timestamp members
+
+func timestampFunctions() []cel.EnvOption {
+	member := func(name string, result *cel.Type, op func(time.Time) ref.Val) cel.EnvOption {
+		return cel.Function(name,
+			cel.MemberOverload("timestamp_"+strings.ToLower(name), []*cel.Type{cel.TimestampType}, result,
+				cel.UnaryBinding(func(t ref.Val) ref.Val { return op(t.(types.Timestamp).Time.UTC()) })))
+	}
+
+	return []cel.EnvOption{
+		cel.Function("timestamp.date",
+			cel.Overload("timestamp_date_int_int_int", []*cel.Type{cel.IntType, cel.IntType, cel.IntType}, cel.TimestampType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return types.Timestamp{Time: time.Date(int(args[0].(types.Int)), time.Month(args[1].(types.Int)),
+						int(args[2].(types.Int)), 0, 0, 0, 0, time.UTC)}
+				}))),
+		cel.Function("timestamp.value",
+			cel.Overload("timestamp_value_int", []*cel.Type{cel.IntType}, cel.TimestampType,
+				cel.UnaryBinding(func(ms ref.Val) ref.Val {
+					return types.Timestamp{Time: time.UnixMilli(int64(ms.(types.Int))).UTC()}
+				}))),
+		member("date", cel.TimestampType, func(t time.Time) ref.Val {
+			return types.Timestamp{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+		}),
+		member("year", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Year()) }),
+		member("month", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Month()) }),
+		member("day", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Day()) }),
+		member("hours", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Hour()) }),
+		member("minutes", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Minute()) }),
+		member("seconds", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Second()) }),
+		member("nanos", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.Nanosecond()) }),
+		member("dayOfWeek", cel.IntType, func(t time.Time) ref.Val {
+			// Monday is 1 and Sunday is 7
+			return types.Int((int(t.Weekday())+6)%7 + 1)
+		}),
+		member("dayOfYear", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.YearDay()) }),
+		member("time", cel.DurationType, func(t time.Time) ref.Val {
+			return types.Duration{Duration: t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))}
+		}),
+		member("toMillis", cel.IntType, func(t time.Time) ref.Val { return types.Int(t.UnixMilli()) }),
+	}
+}
+
+// duration namespace and Duration members
+
+var durationUnits = map[string]time.Duration{
+	"w": 7 * 24 * time.Hour, "d": 24 * time.Hour, "h": time.Hour, "m": time.Minute,
+	"s": time.Second, "ms": time.Millisecond, "ns": time.Nanosecond,
+}
+
+func durationFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("duration.value",
+			cel.Overload("duration_value_int_string", []*cel.Type{cel.IntType, cel.StringType}, cel.DurationType,
+				cel.BinaryBinding(func(magnitude, unit ref.Val) ref.Val {
+					scale, ok := durationUnits[string(unit.(types.String))]
+					if !ok {
+						return types.NewErr("duration.value(): unknown unit %q", string(unit.(types.String)))
+					}
+					return types.Duration{Duration: time.Duration(magnitude.(types.Int)) * scale}
+				}))),
+		cel.Function("duration.time",
+			cel.Overload("duration_time_int_int_int_int", []*cel.Type{cel.IntType, cel.IntType, cel.IntType, cel.IntType}, cel.DurationType,
+				cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+					return types.Duration{Duration: time.Duration(args[0].(types.Int))*time.Hour +
+						time.Duration(args[1].(types.Int))*time.Minute +
+						time.Duration(args[2].(types.Int))*time.Second +
+						time.Duration(args[3].(types.Int))}
+				}))),
+		cel.Function("duration.abs",
+			cel.Overload("duration_abs_duration", []*cel.Type{cel.DurationType}, cel.DurationType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val {
+					if duration := d.(types.Duration).Duration; duration < 0 {
+						return types.Duration{Duration: -duration}
+					}
+					return d
+				}))),
+		cel.Function("seconds",
+			cel.MemberOverload("duration_seconds", []*cel.Type{cel.DurationType}, cel.IntType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val {
+					return types.Int(d.(types.Duration).Duration / time.Second)
+				}))),
+		cel.Function("nanos",
+			cel.MemberOverload("duration_nanos", []*cel.Type{cel.DurationType}, cel.IntType,
+				cel.UnaryBinding(func(d ref.Val) ref.Val {
+					return types.Int(d.(types.Duration).Duration % time.Second)
+				}))),
+	}
+}
+
+// latlng namespace and LatLng members
+
+func latLngFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("latlng.value",
+			cel.Overload("latlng_value_double_double", []*cel.Type{cel.DoubleType, cel.DoubleType}, latLngType,
+				cel.BinaryBinding(func(lat, lng ref.Val) ref.Val {
+					return newLatLng(float64(lat.(types.Double)), float64(lng.(types.Double)))
+				}))),
+		cel.Function("latitude",
+			cel.MemberOverload("latlng_latitude", []*cel.Type{latLngType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return types.Double(v.(*latLng).Latitude) }))),
+		cel.Function("longitude",
+			cel.MemberOverload("latlng_longitude", []*cel.Type{latLngType}, cel.DoubleType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return types.Double(v.(*latLng).Longitude) }))),
+		cel.Function("distance",
+			cel.MemberOverload("latlng_distance_latlng", []*cel.Type{latLngType, latLngType}, cel.DoubleType,
+				cel.BinaryBinding(func(v, other ref.Val) ref.Val {
+					return types.Double(v.(*latLng).distance(other.(*latLng)))
+				}))),
+	}
+}
+
+// path() and Path members
+
+func pathFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("path",
+			cel.Overload("path_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(s ref.Val) ref.Val { return s }))),
+		cel.Function("bind",
+			cel.MemberOverload("path_bind_map", []*cel.Type{cel.StringType, mapType}, cel.StringType,
+				cel.BinaryBinding(func(p, bindings ref.Val) ref.Val {
+					bound := string(p.(types.String))
+					keys, values := mapEntries(bindings.(traits.Mapper))
+					for i, key := range keys {
+						value := values[i].ConvertToType(types.StringType)
+						if types.IsError(value) {
+							return value
+						}
+						bound = strings.ReplaceAll(bound, "$("+string(key.ConvertToType(types.StringType).(types.String))+")",
+							string(value.(types.String)))
+					}
+					return types.String(bound)
+				}))),
+		cel.Function("debug",
+			cel.Overload("debug_dyn", []*cel.Type{cel.DynType}, cel.DynType,
+				cel.UnaryBinding(func(v ref.Val) ref.Val { return v }))),
+	}
+}
+
+// is(value, 'type') implements the rules `value is type` operator
+
+func typeFunctions() []cel.EnvOption {
+	return []cel.EnvOption{
+		cel.Function("is",
+			cel.Overload("is_dyn_string", []*cel.Type{cel.DynType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(func(v, typeName ref.Val) ref.Val {
+					return isType(v, string(typeName.(types.String)))
+				}))),
+	}
+}
+
+func isType(v ref.Val, typeName string) ref.Val {
+	switch typeName {
+	case "bool":
+		return types.Bool(v.Type() == types.BoolType)
+	case "bytes":
+		return types.Bool(v.Type() == types.BytesType)
+	case "duration":
+		return types.Bool(v.Type() == types.DurationType)
+	case "float":
+		return types.Bool(v.Type() == types.DoubleType)
+	case "int":
+		return types.Bool(v.Type() == types.IntType || v.Type() == types.UintType)
+	case "number":
+		return types.Bool(v.Type() == types.IntType || v.Type() == types.UintType || v.Type() == types.DoubleType)
+	case "string", "path":
+		return types.Bool(v.Type() == types.StringType)
+	case "list", "set":
+		_, ok := v.(traits.Lister)
+		return types.Bool(ok)
+	case "map":
+		_, ok := v.(traits.Mapper)
+		return types.Bool(ok)
+	case "timestamp":
+		return types.Bool(v.Type() == types.TimestampType)
+	case "latlng":
+		_, ok := v.(*latLng)
+		return types.Bool(ok)
+	default:
+		return types.NewErr("unknown rules type %q", typeName)
+	}
+}
+
+// Helpers
+
+// newList creates a CEL list from values
+func newList(values []ref.Val) ref.Val {
+	return types.NewRefValList(types.DefaultTypeAdapter, values)
+}
+
+// newSet creates a set: a list without duplicates in a canonical order
+func newSet(values []ref.Val) ref.Val {
+	var unique []ref.Val
+	for _, value := range values {
+		if !containsValue(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return setOrderKey(unique[i]) < setOrderKey(unique[j])
+	})
+	return newList(unique)
+}
+
+// setOrderKey orders set elements by type and then by value; numbers compare
+// numerically across int and float
+func setOrderKey(value ref.Val) string {
+	if f, ok := toFloat(value); ok {
+		return fmt.Sprintf("number:%020.6f", f)
+	}
+	return value.Type().TypeName() + ":" + fmt.Sprintf("%v", value.Value())
+}
+
+func listValues(list traits.Lister) []ref.Val {
+	size := int(list.Size().(types.Int))
+	values := make([]ref.Val, size)
+	for i := 0; i < size; i++ {
+		values[i] = list.Get(types.Int(i))
+	}
+	return values
+}
+
+func mapEntries(m traits.Mapper) ([]ref.Val, []ref.Val) {
+	var keys, values []ref.Val
+	for it := m.Iterator(); it.HasNext() == types.True; {
+		key := it.Next()
+		keys = append(keys, key)
+		values = append(values, m.Get(key))
+	}
+	return keys, values
+}
+
+func containsValue(values []ref.Val, value ref.Val) bool {
+	for _, candidate := range values {
+		if candidate.Equal(value) == types.True {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(values, required []ref.Val) bool {
+	for _, value := range required {
+		if !containsValue(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func without(values, removed []ref.Val) []ref.Val {
+	var kept []ref.Val
+	for _, value := range values {
+		if !containsValue(removed, value) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+func toFloat(value ref.Val) (float64, bool) {
+	switch v := value.(type) {
+	case types.Int:
+		return float64(v), true
+	case types.Uint:
+		return float64(v), true
+	case types.Double:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
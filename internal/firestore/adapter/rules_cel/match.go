@@ -0,0 +1,49 @@
+package rules_cel
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	matchWildcard           = regexp.MustCompile(`\{([^}]+)\}`)
+	quotedRecursiveWildcard = regexp.MustCompile(`\\{([^}]+)=\\\*\\\*\\}`)
+	quotedWildcard          = regexp.MustCompile(`\\{([^}]+)\\}`)
+)
+
+// CompileMatch converts a rule match pattern, such as /users/{userId} or
+// /docs/{document=**}, into an anchored regexp with a named group per wildcard, and
+// returns the wildcards of the pattern
+func CompileMatch(pattern string) (*regexp.Regexp, []string, error) {
+	matches := matchWildcard.FindAllStringSubmatch(pattern, -1)
+	wildcards := make([]string, len(matches))
+	for i, match := range matches {
+		wildcards[i] = match[1]
+	}
+
+	// Recursive wildcards match the rest of the path, regular ones a single segment
+	regexPattern := regexp.QuoteMeta(pattern)
+	regexPattern = quotedRecursiveWildcard.ReplaceAllString(regexPattern, `(?P<$1>.*)`)
+	regexPattern = quotedWildcard.ReplaceAllString(regexPattern, `(?P<$1>[^/]+)`)
+
+	compiled, err := regexp.Compile("^" + regexPattern + "$")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile regex pattern: %w", err)
+	}
+	return compiled, wildcards, nil
+}
+
+// MatchVariables returns the values of the wildcards of a compiled match in a path
+func MatchVariables(match *regexp.Regexp, path string) map[string]string {
+	variables := make(map[string]string)
+	values := match.FindStringSubmatch(path)
+	if values == nil {
+		return variables
+	}
+	for i, name := range match.SubexpNames() {
+		if i != 0 && name != "" && i < len(values) {
+			variables[name] = values[i]
+		}
+	}
+	return variables
+}
@@ -0,0 +1,504 @@
+package rules_cel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// queryPlaceholder stands for the path segments a query does not fix: the ID of the
+// documents it returns and the parents of collection group queries
+const queryPlaceholder = "__query__"
+
+// maxQueryProbes bounds the disjunctions a query expands to, counting in and
+// array-contains-any values as disjunctions
+const maxQueryProbes = 64
+
+// collectionGroupDepths are the numbers of parent documents of the collections probed
+// for collection group queries
+var collectionGroupDepths = []int{0, 1, 2}
+
+// QueryRequest is a collection query checked against the list rules
+type QueryRequest struct {
+	ProjectID  string
+	DatabaseID string
+	// CollectionPath is the queried collection relative to the database, such as
+	// users/alice/posts; collection group queries use its last segment
+	CollectionPath string
+	// Auth is request.auth; nil for unauthenticated requests
+	Auth  map[string]interface{}
+	Query *model.Query
+}
+
+// EvaluateQuery checks that a query can only return documents its rules allow to list,
+// without reading any document: rules are not filters. The list conditions are
+// evaluated for a probe document whose data holds only what the query constraints
+// guarantee, the values of its == filters and the elements of its array-contains
+// filters. Reading anything else of the probe, such as another field, the document ID
+// or the size of its data, is an error, so conditions which hold whatever the rest of
+// the document are proven and the others are not. Queries with or, in and
+// array-contains-any filters are proven for each of their disjunctions.
+func EvaluateQuery(ctx context.Context, rulesEnv *cel.Env, rules []*repository.SecurityRule, accessor repository.ResourceAccessor, request *QueryRequest) (*repository.RuleEvaluationResult, error) {
+	startTime := time.Now()
+	query := request.Query
+	if query == nil {
+		query = &model.Query{}
+	}
+
+	probes, err := queryProbes(query)
+	if err != nil {
+		return &repository.RuleEvaluationResult{
+			Reason:           err.Error(),
+			EvaluationTimeMs: time.Since(startTime).Milliseconds(),
+		}, nil
+	}
+
+	ordered := make([]*repository.SecurityRule, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority > ordered[j].Priority })
+
+	evaluation := &Evaluation{
+		Context:    ctx,
+		Accessor:   accessor,
+		ProjectID:  request.ProjectID,
+		DatabaseID: request.DatabaseID,
+	}
+	result := &repository.RuleEvaluationResult{}
+	for _, documentPath := range queryDocumentPaths(request.CollectionPath, query.AllDescendants) {
+		for _, probe := range probes {
+			probeResult, err := evaluateQueryProbe(rulesEnv, ordered, evaluation, request, query, documentPath, probe)
+			if err != nil {
+				return nil, err
+			}
+			if !probeResult.Allowed {
+				probeResult.EvaluationTimeMs = time.Since(startTime).Milliseconds()
+				return probeResult, nil
+			}
+			if result.AllowedBy == "" {
+				result.AllowedBy = probeResult.AllowedBy
+				result.RuleMatch = probeResult.RuleMatch
+			}
+		}
+	}
+
+	result.Allowed = true
+	result.Reason = "Every document the query can return is allowed by a list condition"
+	result.EvaluationTimeMs = time.Since(startTime).Milliseconds()
+	return result, nil
+}
+
+// evaluateQueryProbe evaluates the list conditions of the rules matching a probe
+// document: a deny condition must not hold and an allow condition must hold
+func evaluateQueryProbe(rulesEnv *cel.Env, rules []*repository.SecurityRule, evaluation *Evaluation, request *QueryRequest, query *model.Query, documentPath string, probe map[string]interface{}) (*repository.RuleEvaluationResult, error) {
+	result := &repository.RuleEvaluationResult{Reason: "No matching rule found (default deny)"}
+	var unproven string
+
+	for _, rule := range rules {
+		match, wildcards, err := CompileMatch(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile match pattern '%s': %w", rule.Match, err)
+		}
+		rulePath := simulationRulePath(rule.Match, request.DatabaseID, documentPath)
+		if !match.MatchString(rulePath) {
+			continue
+		}
+		if result.RuleMatch == "" {
+			result.RuleMatch = rule.Match
+		}
+		vars := queryVariables(request, query, probe, MatchVariables(match, rulePath))
+
+		if condition, ok := listCondition(rule.Deny); ok {
+			denied, err := evaluateQueryCondition(rulesEnv, condition, wildcards, evaluation, vars)
+			if err != nil || denied {
+				result.DeniedBy = rule.Match
+				result.Reason = "Query may return documents denied by a deny condition"
+				if err != nil {
+					result.Reason = fmt.Sprintf("Cannot prove that the deny condition does not apply to the query: %v", err)
+				}
+				return result, nil
+			}
+		}
+		if condition, ok := listCondition(rule.Allow); ok {
+			allowed, err := evaluateQueryCondition(rulesEnv, condition, wildcards, evaluation, vars)
+			if err == nil && allowed {
+				result.Allowed = true
+				result.AllowedBy = rule.Match
+				result.RuleMatch = rule.Match
+				return result, nil
+			}
+			if err != nil && unproven == "" {
+				unproven = err.Error()
+			}
+		}
+	}
+
+	if result.RuleMatch != "" {
+		result.Reason = "Query may return documents the list conditions do not allow"
+		if unproven != "" {
+			result.Reason = fmt.Sprintf("Query may return documents the list conditions do not allow: %s", unproven)
+		}
+	}
+	return result, nil
+}
+
+// listCondition returns the condition of the list operation, or of read, which grants list
+func listCondition(conditions map[repository.OperationType]string) (string, bool) {
+	if condition, ok := conditions[repository.OperationList]; ok {
+		return condition, true
+	}
+	condition, ok := conditions[repository.OperationRead]
+	return condition, ok
+}
+
+func evaluateQueryCondition(rulesEnv *cel.Env, condition string, wildcards []string, evaluation *Evaluation, vars map[string]interface{}) (bool, error) {
+	program, err := Compile(rulesEnv, condition, wildcards)
+	if err != nil {
+		return false, err
+	}
+	out, _, err := program.Eval(evaluation.Bind(vars))
+	if err != nil {
+		return false, err
+	}
+	value, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("CEL expression did not return boolean value")
+	}
+	return value, nil
+}
+
+// queryDocumentPaths returns the paths of the probe documents of a query: a document of
+// the collection, or of collections with that ID at several depths for collection groups
+func queryDocumentPaths(collectionPath string, allDescendants bool) []string {
+	collectionPath = strings.Trim(collectionPath, "/")
+	if !allDescendants {
+		return []string{collectionPath + "/" + queryPlaceholder}
+	}
+
+	segments := strings.Split(collectionPath, "/")
+	collectionID := segments[len(segments)-1]
+	paths := make([]string, 0, len(collectionGroupDepths))
+	for _, depth := range collectionGroupDepths {
+		parents := make([]string, 0, 2*depth+2)
+		for i := 0; i < 2*depth; i++ {
+			parents = append(parents, queryPlaceholder)
+		}
+		paths = append(paths, strings.Join(append(parents, collectionID, queryPlaceholder), "/"))
+	}
+	return paths
+}
+
+// queryVariables returns the variables of a list condition for a probe document. The
+// wildcards bound to placeholders, the path of the document and its ID are unconstrained.
+func queryVariables(request *QueryRequest, query *model.Query, probe map[string]interface{}, wildcards map[string]string) map[string]interface{} {
+	rulesQuery := map[string]interface{}{}
+	if query.Limit > 0 {
+		rulesQuery["limit"] = query.Limit
+	}
+	if query.Offset > 0 {
+		rulesQuery["offset"] = query.Offset
+	}
+
+	auth := simulationAuth(request.Auth)
+	vars := map[string]interface{}{
+		AuthVariable: auth,
+		RequestVariable: map[string]interface{}{
+			"auth":   auth,
+			"method": string(repository.OperationList),
+			"path":   unconstrained("request.path"),
+			"time":   time.Now(),
+			"query":  rulesQuery,
+		},
+		ResourceVariable: map[string]interface{}{
+			"data":     newQueryData("resource.data", probe),
+			"id":       unconstrained("resource.id"),
+			"__name__": unconstrained("resource.__name__"),
+		},
+		PathVariable:      unconstrained("path"),
+		VariablesVariable: wildcards,
+	}
+	for name, value := range wildcards {
+		if strings.Contains(value, queryPlaceholder) {
+			vars[name] = unconstrained(name)
+			vars[VariablesVariable] = unconstrained("variables")
+			continue
+		}
+		vars[name] = value
+	}
+	return vars
+}
+
+// unconstrained is the value of what the query does not constrain
+func unconstrained(name string) ref.Val {
+	return types.NewErr("%s is not constrained by the query", name)
+}
+
+// arrayElement is an element an array field is known to contain
+type arrayElement struct {
+	value interface{}
+}
+
+// queryProbes returns the data of the probe documents of a query, one per disjunction:
+// the values of its == filters and the elements of its array-contains filters by field
+// path. Other filters constrain the results further and are ignored.
+func queryProbes(query *model.Query) ([]map[string]interface{}, error) {
+	conjunctions, err := queryDisjunctions(query.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	probes := make([]map[string]interface{}, 0, len(conjunctions))
+	for _, filters := range conjunctions {
+		probe := make(map[string]interface{})
+		for _, filter := range filters {
+			field := filterField(filter)
+			switch filter.Operator {
+			case model.OperatorEqual:
+				probe[field] = filter.Value
+			case model.OperatorArrayContains:
+				if _, ok := probe[field]; !ok {
+					probe[field] = arrayElement{value: filter.Value}
+				}
+			}
+		}
+		probes = append(probes, probe)
+	}
+	return probes, nil
+}
+
+// queryDisjunctions expands filters into a disjunction of conjunctions of simple
+// filters, turning in and array-contains-any filters into == and array-contains ones
+func queryDisjunctions(filters []model.Filter) ([][]model.Filter, error) {
+	conjunctions := [][]model.Filter{{}}
+	for _, filter := range filters {
+		alternatives, err := filterAlternatives(filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(conjunctions)*len(alternatives) > maxQueryProbes {
+			return nil, fmt.Errorf("Query has more than %d disjunctions to check against the rules", maxQueryProbes)
+		}
+
+		expanded := make([][]model.Filter, 0, len(conjunctions)*len(alternatives))
+		for _, conjunction := range conjunctions {
+			for _, alternative := range alternatives {
+				combined := make([]model.Filter, 0, len(conjunction)+len(alternative))
+				combined = append(append(combined, conjunction...), alternative...)
+				expanded = append(expanded, combined)
+			}
+		}
+		conjunctions = expanded
+	}
+	return conjunctions, nil
+}
+
+// filterAlternatives returns the conjunctions one of which holds for every document
+// matching a filter
+func filterAlternatives(filter model.Filter) ([][]model.Filter, error) {
+	switch {
+	case strings.EqualFold(filter.Composite, "or"):
+		var alternatives [][]model.Filter
+		for _, subFilter := range filter.SubFilters {
+			subAlternatives, err := filterAlternatives(subFilter)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, subAlternatives...)
+		}
+		return alternatives, nil
+	case filter.Composite != "":
+		return queryDisjunctions(filter.SubFilters)
+	}
+
+	var operator model.Operator
+	switch filter.Operator {
+	case model.OperatorIn:
+		operator = model.OperatorEqual
+	case model.OperatorArrayContainsAny:
+		operator = model.OperatorArrayContains
+	default:
+		return [][]model.Filter{{filter}}, nil
+	}
+
+	values := reflect.ValueOf(filter.Value)
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return [][]model.Filter{{}}, nil
+	}
+	alternatives := make([][]model.Filter, values.Len())
+	for i := range alternatives {
+		alternatives[i] = []model.Filter{{Field: filterField(filter), Operator: operator, Value: values.Index(i).Interface()}}
+	}
+	return alternatives, nil
+}
+
+func filterField(filter model.Filter) string {
+	if filter.FieldPath != nil {
+		return filter.FieldPath.Raw()
+	}
+	return filter.Field
+}
+
+// newQueryData returns the data of a probe document from its constraints by field path
+func newQueryData(name string, probe map[string]interface{}) *queryData {
+	data := &queryData{name: name, fields: make(map[string]ref.Val)}
+	nested := make(map[string]map[string]interface{})
+
+	paths := make([]string, 0, len(probe))
+	for path := range probe {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		field, rest, isNested := strings.Cut(path, ".")
+		if !isNested {
+			data.fields[field] = queryValue(name+"."+field, probe[path])
+			continue
+		}
+		if nested[field] == nil {
+			nested[field] = make(map[string]interface{})
+		}
+		nested[field][rest] = probe[path]
+	}
+	// A constrained map wins over constraints on its fields
+	for field, fields := range nested {
+		if _, ok := data.fields[field]; !ok {
+			data.fields[field] = newQueryData(name+"."+field, fields)
+		}
+	}
+	return data
+}
+
+func queryValue(name string, value interface{}) ref.Val {
+	if element, ok := value.(arrayElement); ok {
+		return &queryArray{name: name, element: types.DefaultTypeAdapter.NativeToValue(element.value)}
+	}
+	return types.DefaultTypeAdapter.NativeToValue(value)
+}
+
+// The types declare their traits, which the in operator dispatches on
+var (
+	queryDataType  = types.NewObjectType("rules.QueryData", traits.ContainerType)
+	queryArrayType = types.NewObjectType("rules.QueryArray", traits.ContainerType)
+)
+
+// queryData is a map of which only some fields are known. It supports field selection,
+// has() and in; anything else, such as its size, keys or iteration, is an error.
+type queryData struct {
+	name   string
+	fields map[string]ref.Val
+}
+
+// Get implements traits.Indexer
+func (d *queryData) Get(index ref.Val) ref.Val {
+	if field, ok := index.(types.String); ok {
+		if value, ok := d.fields[string(field)]; ok {
+			return value
+		}
+		return unconstrained(d.name + "." + string(field))
+	}
+	return unconstrained(d.name)
+}
+
+// IsSet implements traits.FieldTester
+func (d *queryData) IsSet(field ref.Val) ref.Val {
+	return d.Contains(field)
+}
+
+// Contains implements traits.Container
+func (d *queryData) Contains(index ref.Val) ref.Val {
+	if field, ok := index.(types.String); ok {
+		if _, ok := d.fields[string(field)]; ok {
+			return types.True
+		}
+		return unconstrained(d.name + "." + string(field))
+	}
+	return unconstrained(d.name)
+}
+
+// ConvertToNative implements ref.Val
+func (d *queryData) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return nil, fmt.Errorf("%s is not constrained by the query", d.name)
+}
+
+// ConvertToType implements ref.Val
+func (d *queryData) ConvertToType(typeValue ref.Type) ref.Val {
+	if typeValue == types.TypeType {
+		return types.MapType
+	}
+	return unconstrained(d.name)
+}
+
+// Equal implements ref.Val
+func (d *queryData) Equal(other ref.Val) ref.Val {
+	return unconstrained(d.name)
+}
+
+// Type implements ref.Val
+func (d *queryData) Type() ref.Type {
+	return queryDataType
+}
+
+// Value implements ref.Val
+func (d *queryData) Value() any {
+	return d
+}
+
+// queryArray is an array known to contain an element. It supports in for that
+// element; anything else is an error.
+type queryArray struct {
+	name    string
+	element ref.Val
+}
+
+// Contains implements traits.Container
+func (a *queryArray) Contains(value ref.Val) ref.Val {
+	if a.element.Equal(value) == types.True {
+		return types.True
+	}
+	return unconstrained(a.name)
+}
+
+// ConvertToNative implements ref.Val
+func (a *queryArray) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return nil, fmt.Errorf("%s is not constrained by the query", a.name)
+}
+
+// ConvertToType implements ref.Val
+func (a *queryArray) ConvertToType(typeValue ref.Type) ref.Val {
+	if typeValue == types.TypeType {
+		return types.ListType
+	}
+	return unconstrained(a.name)
+}
+
+// Equal implements ref.Val
+func (a *queryArray) Equal(other ref.Val) ref.Val {
+	return unconstrained(a.name)
+}
+
+// Type implements ref.Val
+func (a *queryArray) Type() ref.Type {
+	return queryArrayType
+}
+
+// Value implements ref.Val
+func (a *queryArray) Value() any {
+	return a
+}
+
+var (
+	_ traits.Indexer     = (*queryData)(nil)
+	_ traits.FieldTester = (*queryData)(nil)
+	_ traits.Container   = (*queryData)(nil)
+	_ traits.Container   = (*queryArray)(nil)
+)
@@ -0,0 +1,236 @@
+package rules_cel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/repository"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Simulate evaluates rules for a simulated request the way the engine evaluates them:
+// rules are tried by priority, a deny condition which holds denies the request and the
+// first allow condition which holds allows it. Unlike the engine it evaluates requests
+// with Firestore semantics for resource and request.resource and traces every matched
+// rule, every evaluated condition with its sub-expressions and every document lookup.
+func Simulate(ctx context.Context, rulesEnv *cel.Env, rules []*repository.SecurityRule, accessor repository.ResourceAccessor, request *repository.SimulationRequest) (*repository.SimulationResult, error) {
+	documentPath := simulationDocumentPath(request.Path)
+	if documentPath == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	evaluation := &Evaluation{
+		Context:      ctx,
+		Accessor:     accessor,
+		ProjectID:    request.ProjectID,
+		DatabaseID:   request.DatabaseID,
+		Documents:    request.Documents,
+		TrackLookups: true,
+	}
+	resource, err := simulationResource(ctx, evaluation, request, documentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]*repository.SecurityRule, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Priority > ordered[j].Priority })
+
+	result := &repository.SimulationResult{
+		Reason:  "No matching rule found (default deny)",
+		Matches: make([]*repository.MatchTrace, 0),
+	}
+	for _, rule := range ordered {
+		match, wildcards, err := CompileMatch(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile match pattern '%s': %w", rule.Match, err)
+		}
+		rulePath := simulationRulePath(rule.Match, request.DatabaseID, documentPath)
+		if !match.MatchString(rulePath) {
+			continue
+		}
+
+		trace := &repository.MatchTrace{
+			Match:      rule.Match,
+			Variables:  MatchVariables(match, rulePath),
+			Conditions: make([]*repository.ConditionTrace, 0),
+		}
+		result.Matches = append(result.Matches, trace)
+		vars := simulationVariables(request, resource, rulePath, trace.Variables)
+
+		// Deny conditions are evaluated first, as in the engine
+		if condition, ok := rule.Deny[request.Operation]; ok {
+			conditionTrace := simulateCondition(rulesEnv, "deny", request.Operation, condition, wildcards, evaluation, vars)
+			trace.Conditions = append(trace.Conditions, conditionTrace)
+			if conditionTrace.Result {
+				result.Allowed = false
+				result.DecidedBy = rule.Match
+				result.Reason = "Denied by a deny condition"
+				break
+			}
+		}
+		if condition, ok := rule.Allow[request.Operation]; ok {
+			conditionTrace := simulateCondition(rulesEnv, "allow", request.Operation, condition, wildcards, evaluation, vars)
+			trace.Conditions = append(trace.Conditions, conditionTrace)
+			if conditionTrace.Result {
+				result.Allowed = true
+				result.DecidedBy = rule.Match
+				result.Reason = "Allowed by an allow condition"
+				break
+			}
+		}
+	}
+
+	if result.DecidedBy == "" && len(result.Matches) > 0 {
+		result.Reason = fmt.Sprintf("No condition allowed operation '%s' on the matched rules", request.Operation)
+	}
+	result.Lookups = evaluation.Lookups
+	if result.Lookups == nil {
+		result.Lookups = make([]*repository.DocumentLookupTrace, 0)
+	}
+	return result, nil
+}
+
+// Simulator simulates requests against the candidate rules of each request, reading
+// the documents which are not mocked through its accessor
+type Simulator struct {
+	env      *cel.Env
+	accessor repository.ResourceAccessor
+}
+
+// NewSimulator creates a simulator of candidate rules
+func NewSimulator(rulesEnv *cel.Env, accessor repository.ResourceAccessor) *Simulator {
+	return &Simulator{env: rulesEnv, accessor: accessor}
+}
+
+// SimulateAccess implements repository.RulesSimulator
+func (s *Simulator) SimulateAccess(ctx context.Context, request *repository.SimulationRequest) (*repository.SimulationResult, error) {
+	return Simulate(ctx, s.env, request.Rules, s.accessor, request)
+}
+
+var _ repository.RulesSimulator = (*Simulator)(nil)
+
+// simulateCondition compiles and evaluates a condition with its trace. Conditions which
+// fail to compile or to evaluate do not hold.
+func simulateCondition(rulesEnv *cel.Env, effect string, operation repository.OperationType, condition string, wildcards []string, evaluation *Evaluation, vars map[string]interface{}) *repository.ConditionTrace {
+	trace := &repository.ConditionTrace{Effect: effect, Operation: operation, Condition: condition}
+
+	program, err := CompileTraced(rulesEnv, condition, wildcards)
+	if err != nil {
+		trace.Error = err.Error()
+		return trace
+	}
+
+	out, expressions, err := program.Eval(evaluation.Bind(vars))
+	trace.Expressions = expressions
+	if err != nil {
+		trace.Error = fmt.Sprintf("CEL evaluation error: %v", err)
+		return trace
+	}
+	value, ok := out.Value().(bool)
+	if !ok {
+		trace.Error = "CEL expression did not return boolean value"
+		return trace
+	}
+	trace.Result = value
+	return trace
+}
+
+// simulationDocumentPath returns the document path relative to the database, accepting
+// full /databases/{database}/documents paths too
+func simulationDocumentPath(path string) string {
+	path = strings.Trim(path, "/")
+	if strings.HasPrefix(path, "databases/") {
+		parts := strings.SplitN(path, "/", 4)
+		if len(parts) < 4 {
+			return ""
+		}
+		return parts[3]
+	}
+	return path
+}
+
+// simulationRulePath returns the path a rule match is tested against: the full rules
+// path for matches nested in /databases/{database}/documents, or the document path
+func simulationRulePath(match, databaseID, documentPath string) string {
+	if strings.HasPrefix(match, "/databases/") {
+		return "/databases/" + databaseID + "/documents/" + documentPath
+	}
+	return "/" + documentPath
+}
+
+// simulationResource returns the resource variable: the mocked or stored document, or
+// nil when the document does not exist
+func simulationResource(ctx context.Context, evaluation *Evaluation, request *repository.SimulationRequest, documentPath string) (map[string]interface{}, error) {
+	data := request.Resource
+	if data == nil && request.UseExistingDocument {
+		if evaluation.Accessor == nil {
+			return nil, errNoAccessor
+		}
+		stored, err := evaluation.Accessor.GetDocument(ctx, request.ProjectID, request.DatabaseID, documentPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the existing document: %w", err)
+		}
+		data = stored
+	}
+	if data == nil {
+		return nil, nil
+	}
+	return simulationDocument(request.DatabaseID, documentPath, data), nil
+}
+
+// simulationVariables returns the variables of the conditions of a matched rule
+func simulationVariables(request *repository.SimulationRequest, resource map[string]interface{}, rulePath string, wildcards map[string]string) map[string]interface{} {
+	documentPath := simulationDocumentPath(request.Path)
+	rulesRequest := map[string]interface{}{
+		"auth":   simulationAuth(request.Auth),
+		"method": string(request.Operation),
+		"path":   "/databases/" + request.DatabaseID + "/documents/" + documentPath,
+		"time":   time.Now(),
+	}
+	if request.RequestData != nil {
+		rulesRequest["resource"] = simulationDocument(request.DatabaseID, documentPath, request.RequestData)
+	}
+
+	vars := map[string]interface{}{
+		AuthVariable:      rulesRequest["auth"],
+		RequestVariable:   rulesRequest,
+		ResourceVariable:  resource,
+		PathVariable:      rulePath,
+		VariablesVariable: wildcards,
+	}
+	for name, value := range wildcards {
+		vars[name] = value
+	}
+	return vars
+}
+
+// simulationAuth returns request.auth, giving authenticated requests a token map
+func simulationAuth(auth map[string]interface{}) interface{} {
+	if auth == nil {
+		return nil
+	}
+	if _, ok := auth["token"]; ok {
+		return auth
+	}
+	withToken := make(map[string]interface{}, len(auth)+1)
+	for key, value := range auth {
+		withToken[key] = value
+	}
+	withToken["token"] = map[string]interface{}{}
+	return withToken
+}
+
+// simulationDocument returns a document as rules see it: its data, id and __name__
+func simulationDocument(databaseID, documentPath string, data map[string]interface{}) map[string]interface{} {
+	segments := strings.Split(documentPath, "/")
+	return map[string]interface{}{
+		"data":     data,
+		"id":       segments[len(segments)-1],
+		"__name__": "/databases/" + databaseID + "/documents/" + documentPath,
+	}
+}
@@ -0,0 +1,275 @@
+package rules_cel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rulesTypes are the type names accepted by the rules `is` operator
+var rulesTypes = map[string]bool{
+	"bool": true, "bytes": true, "duration": true, "float": true, "int": true,
+	"latlng": true, "list": true, "map": true, "number": true, "path": true,
+	"set": true, "string": true, "timestamp": true,
+}
+
+// RewriteSyntax rewrites the rules syntax CEL does not parse: `x is string` becomes
+// is(x, 'string') and path literals such as /databases/$(database)/documents/users/$(uid)
+// become path() calls concatenating their interpolations.
+func RewriteSyntax(condition string) (string, error) {
+	expression, err := rewritePaths(condition)
+	if err != nil {
+		return "", err
+	}
+	return rewriteTypeChecks(expression)
+}
+
+// rewritePaths replaces the path literals of an expression by path() calls
+func rewritePaths(expression string) (string, error) {
+	var sb strings.Builder
+	operand := false // After an operand a slash is a division
+
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := skipString(expression, i)
+			sb.WriteString(expression[i:end])
+			i = end
+			operand = true
+		case ch == '/' && !operand:
+			literal, end, err := rewritePathLiteral(expression, i)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(literal)
+			i = end
+			operand = true
+		case isSpace(ch):
+			sb.WriteByte(ch)
+			i++
+		default:
+			sb.WriteByte(ch)
+			i++
+			operand = isIdentifierPart(ch) || ch == ')' || ch == ']' || ch == '}'
+		}
+	}
+	return sb.String(), nil
+}
+
+// rewritePathLiteral converts the path literal starting at start into a path() call,
+// returning it with the offset where the literal ends
+func rewritePathLiteral(expression string, start int) (string, int, error) {
+	var parts []string
+	var literal strings.Builder
+	i := start
+	for i < len(expression) {
+		ch := expression[i]
+		if isSpace(ch) || ch == ')' || ch == ',' || ch == ';' || ch == ']' {
+			break
+		}
+		if ch == '$' && i+1 < len(expression) && expression[i+1] == '(' {
+			closing := matchingParen(expression, i+1)
+			if closing < 0 {
+				return "", 0, fmt.Errorf("unclosed path interpolation in %q", expression[start:])
+			}
+			inner, err := rewritePaths(expression[i+2 : closing])
+			if err != nil {
+				return "", 0, err
+			}
+			if literal.Len() > 0 {
+				parts = append(parts, quote(literal.String()))
+				literal.Reset()
+			}
+			parts = append(parts, "string("+strings.TrimSpace(inner)+")")
+			i = closing + 1
+			continue
+		}
+		if ch == '(' {
+			// Literal parentheses, as in /databases/(default)/documents
+			closing := matchingParen(expression, i)
+			if closing < 0 {
+				return "", 0, fmt.Errorf("unclosed parenthesis in path %q", expression[start:])
+			}
+			literal.WriteString(expression[i : closing+1])
+			i = closing + 1
+			continue
+		}
+		literal.WriteByte(ch)
+		i++
+	}
+	if literal.Len() > 0 {
+		parts = append(parts, quote(literal.String()))
+	}
+	return "path(" + strings.Join(parts, " + ") + ")", i, nil
+}
+
+// rewriteTypeChecks replaces each `operand is type` by is(operand, 'type')
+func rewriteTypeChecks(expression string) (string, error) {
+	for {
+		index := findTypeCheck(expression)
+		if index < 0 {
+			return expression, nil
+		}
+
+		operandEnd := index
+		for operandEnd > 0 && isSpace(expression[operandEnd-1]) {
+			operandEnd--
+		}
+		operandStart, err := operandStartBefore(expression, operandEnd)
+		if err != nil {
+			return "", err
+		}
+
+		typeStart := skipSpaces(expression, index+2)
+		typeEnd := typeStart
+		for typeEnd < len(expression) && isIdentifierPart(expression[typeEnd]) {
+			typeEnd++
+		}
+		typeName := expression[typeStart:typeEnd]
+		if !rulesTypes[typeName] {
+			return "", fmt.Errorf("unknown type %q in type check", typeName)
+		}
+
+		expression = expression[:operandStart] +
+			"is(" + expression[operandStart:operandEnd] + ", '" + typeName + "')" +
+			expression[typeEnd:]
+	}
+}
+
+// findTypeCheck returns the offset of the first `is` operator outside string literals
+func findTypeCheck(expression string) int {
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+		if ch == '\'' || ch == '"' {
+			i = skipString(expression, i)
+			continue
+		}
+		if isIdentifierStart(ch) {
+			end := i
+			for end < len(expression) && isIdentifierPart(expression[end]) {
+				end++
+			}
+			if expression[i:end] == "is" && (i == 0 || expression[i-1] != '.') &&
+				skipSpaces(expression, end) < len(expression) && expression[skipSpaces(expression, end)] != '(' {
+				return i
+			}
+			i = end
+			continue
+		}
+		i++
+	}
+	return -1
+}
+
+// operandStartBefore returns where the postfix expression ending at end starts: an
+// identifier with its member accesses, calls and indexes, or a literal
+func operandStartBefore(expression string, end int) (int, error) {
+	i := end
+	for i > 0 {
+		ch := expression[i-1]
+		switch {
+		case ch == ')' || ch == ']':
+			open := matchingOpenBefore(expression, i-1)
+			if open < 0 {
+				return 0, fmt.Errorf("unbalanced %q before type check", ch)
+			}
+			i = open
+		case ch == '\'' || ch == '"':
+			open := strings.LastIndexByte(expression[:i-1], ch)
+			if open < 0 {
+				return 0, fmt.Errorf("unterminated string before type check")
+			}
+			i = open
+		case isIdentifierPart(ch) || ch == '.':
+			i--
+		default:
+			if i == end {
+				return 0, fmt.Errorf("missing operand before type check")
+			}
+			return i, nil
+		}
+	}
+	if i == end {
+		return 0, fmt.Errorf("missing operand before type check")
+	}
+	return i, nil
+}
+
+// matchingOpenBefore returns the offset of the bracket opening the one at closing
+func matchingOpenBefore(expression string, closing int) int {
+	depth := 0
+	for i := closing; i >= 0; i-- {
+		switch expression[i] {
+		case ')', ']', '}':
+			depth++
+		case '(', '[', '{':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchingParen returns the offset of the ')' closing the '(' at open, or -1
+func matchingParen(expression string, open int) int {
+	depth := 0
+	for i := open; i < len(expression); {
+		switch expression[i] {
+		case '\'', '"':
+			i = skipString(expression, i)
+			continue
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if expression[i] != ')' {
+					return -1
+				}
+				return i
+			}
+		}
+		i++
+	}
+	return -1
+}
+
+// skipString returns the offset after the string literal starting at start
+func skipString(expression string, start int) int {
+	quote := expression[start]
+	for i := start + 1; i < len(expression); i++ {
+		if expression[i] == '\\' {
+			i++
+			continue
+		}
+		if expression[i] == quote {
+			return i + 1
+		}
+	}
+	return len(expression)
+}
+
+func quote(literal string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(literal, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func skipSpaces(expression string, i int) int {
+	for i < len(expression) && isSpace(expression[i]) {
+		i++
+	}
+	return i
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+func isIdentifierStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+}
+
+func isIdentifierPart(ch byte) bool {
+	return isIdentifierStart(ch) || (ch >= '0' && ch <= '9')
+}
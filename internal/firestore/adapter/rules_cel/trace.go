@@ -0,0 +1,102 @@
+package rules_cel
+
+import (
+	"reflect"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/repository"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// TracedProgram is a compiled condition which reports the values of its sub-expressions
+// when evaluated. Tracking them is slower, so the engine only uses it to explain decisions.
+type TracedProgram struct {
+	program cel.Program
+	ast     *cel.Ast
+}
+
+// CompileTraced compiles a rules condition like Compile, keeping its evaluation state
+func CompileTraced(rulesEnv *cel.Env, condition string, wildcards []string) (*TracedProgram, error) {
+	program, checked, err := compile(rulesEnv, condition, wildcards, cel.EvalOptions(cel.OptTrackState))
+	if err != nil {
+		return nil, err
+	}
+	return &TracedProgram{program: program, ast: checked}, nil
+}
+
+// Eval evaluates the condition and returns the values of the calls and field selections
+// it evaluated, outermost first. Sub-expressions skipped by short-circuiting are left out.
+func (p *TracedProgram) Eval(vars map[string]interface{}) (ref.Val, []*repository.ExpressionTrace, error) {
+	out, details, err := p.program.Eval(vars)
+	if details == nil {
+		return out, nil, err
+	}
+
+	state := details.State()
+	native := p.ast.NativeRep()
+	var traces []*repository.ExpressionTrace
+	ast.PreOrderVisit(native.Expr(), ast.NewExprVisitor(func(expr ast.Expr) {
+		if !isTraced(expr) {
+			return
+		}
+		value, ok := state.Value(expr.ID())
+		if !ok {
+			return
+		}
+		text, unparseErr := cel.ExprToString(expr, native.SourceInfo())
+		if unparseErr != nil {
+			return
+		}
+		trace := &repository.ExpressionTrace{Expression: rulesSyntax(text)}
+		if types.IsError(value) {
+			trace.Error = value.(*types.Err).Error()
+		} else {
+			trace.Value = traceValue(value)
+		}
+		traces = append(traces, trace)
+	}))
+	return out, traces, err
+}
+
+// isTraced reports whether a sub-expression is worth showing: calls and field
+// selections, but not literals, identifiers or the evaluation behind get() and exists()
+func isTraced(expr ast.Expr) bool {
+	switch expr.Kind() {
+	case ast.CallKind, ast.SelectKind:
+		return true
+	}
+	return false
+}
+
+// rulesSyntax shows the document lookups in the syntax of the rules
+func rulesSyntax(expression string) string {
+	expression = strings.ReplaceAll(expression, EvaluationVariable+"."+getDocumentFunction+"(", "get(")
+	return strings.ReplaceAll(expression, EvaluationVariable+"."+existsDocumentFunction+"(", "exists(")
+}
+
+var (
+	traceListType = reflect.TypeOf([]interface{}{})
+	traceMapType  = reflect.TypeOf(map[string]interface{}{})
+)
+
+// traceValue converts a CEL value into a value which can be encoded as JSON
+func traceValue(value ref.Val) interface{} {
+	switch value.(type) {
+	case *Evaluation:
+		return nil
+	case traits.Mapper:
+		if native, err := value.ConvertToNative(traceMapType); err == nil {
+			return native
+		}
+	case traits.Lister:
+		if native, err := value.ConvertToNative(traceListType); err == nil {
+			return native
+		}
+	}
+	return value.Value()
+}
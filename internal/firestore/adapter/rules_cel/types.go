@@ -0,0 +1,122 @@
+package rules_cel
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+var (
+	mapDiffType = cel.OpaqueType("firestore.rules.MapDiff")
+	latLngType  = cel.OpaqueType("firestore.rules.LatLng")
+)
+
+// earthRadiusMeters is the mean Earth radius used by LatLng.distance()
+const earthRadiusMeters = 6371008.8
+
+// opaqueValue implements the ref.Val conversions shared by the rules opaque types
+type opaqueValue struct {
+	typ *cel.Type
+}
+
+// ConvertToNative implements ref.Val
+func (o opaqueValue) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return nil, fmt.Errorf("unsupported conversion of %s to %v", o.typ.TypeName(), typeDesc)
+}
+
+// ConvertToType implements ref.Val
+func (o opaqueValue) ConvertToType(typeValue ref.Type) ref.Val {
+	if typeValue == types.TypeType {
+		return o.typ
+	}
+	return types.NewErr("unsupported conversion of %s to %s", o.typ.TypeName(), typeValue.TypeName())
+}
+
+// Type implements ref.Val
+func (o opaqueValue) Type() ref.Type {
+	return o.typ
+}
+
+// mapDiff is the result of Map.diff(): the keys added, removed, changed and unchanged
+// in a map compared with another
+type mapDiff struct {
+	opaqueValue
+	added, removed, changed, unchanged []ref.Val
+}
+
+// newMapDiff compares m with other: added keys are only in m, removed keys only in other
+func newMapDiff(m, other traits.Mapper) *mapDiff {
+	diff := &mapDiff{opaqueValue: opaqueValue{typ: mapDiffType}}
+
+	keys, values := mapEntries(m)
+	for i, key := range keys {
+		otherValue, found := other.Find(key)
+		switch {
+		case !found:
+			diff.added = append(diff.added, key)
+		case values[i].Equal(otherValue) == types.True:
+			diff.unchanged = append(diff.unchanged, key)
+		default:
+			diff.changed = append(diff.changed, key)
+		}
+	}
+
+	otherKeys, _ := mapEntries(other)
+	for _, key := range otherKeys {
+		if _, found := m.Find(key); !found {
+			diff.removed = append(diff.removed, key)
+		}
+	}
+	return diff
+}
+
+// Equal implements ref.Val
+func (d *mapDiff) Equal(other ref.Val) ref.Val {
+	o, ok := other.(*mapDiff)
+	if !ok {
+		return types.False
+	}
+	same := func(a, b []ref.Val) bool { return containsAll(a, b) && containsAll(b, a) }
+	return types.Bool(same(d.added, o.added) && same(d.removed, o.removed) &&
+		same(d.changed, o.changed) && same(d.unchanged, o.unchanged))
+}
+
+// Value implements ref.Val
+func (d *mapDiff) Value() any {
+	return d
+}
+
+// latLng is a geographic point created with latlng.value()
+type latLng struct {
+	opaqueValue
+	Latitude, Longitude float64
+}
+
+func newLatLng(latitude, longitude float64) *latLng {
+	return &latLng{opaqueValue: opaqueValue{typ: latLngType}, Latitude: latitude, Longitude: longitude}
+}
+
+// Equal implements ref.Val
+func (l *latLng) Equal(other ref.Val) ref.Val {
+	o, ok := other.(*latLng)
+	return types.Bool(ok && l.Latitude == o.Latitude && l.Longitude == o.Longitude)
+}
+
+// Value implements ref.Val
+func (l *latLng) Value() any {
+	return l
+}
+
+// distance returns the great-circle distance to another point in meters
+func (l *latLng) distance(other *latLng) float64 {
+	lat1, lat2 := l.Latitude*math.Pi/180, other.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (other.Longitude - l.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/client"
+)
+
+// DefaultRequestTimeout bounds a single webhook delivery attempt
+const DefaultRequestTimeout = 30 * time.Second
+
+// cloudEventContentType is the content type of structured mode CloudEvents
+const cloudEventContentType = "application/cloudevents+json; charset=utf-8"
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal to most providers
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// HTTPSenderConfig configures the webhook sender
+type HTTPSenderConfig struct {
+	// Timeout bounds a single delivery attempt. Defaults to DefaultRequestTimeout.
+	Timeout time.Duration
+	// AllowPrivateNetworks lets webhooks target loopback and private addresses, e.g. a
+	// function running next to the emulator during local development
+	AllowPrivateNetworks bool
+}
+
+// HTTPSender delivers webhook requests with net/http. Unless private networks are allowed,
+// connections are only opened to public addresses: the check runs on the address actually
+// dialed, so it also covers redirects and hosts whose DNS records change after validation.
+type HTTPSender struct {
+	client       *http.Client
+	resolver     *net.Resolver
+	allowPrivate bool
+}
+
+// NewHTTPSender creates a webhook sender that only delivers to public addresses.
+// A nil client uses one with DefaultRequestTimeout.
+func NewHTTPSender(httpClient *http.Client) client.WebhookSender {
+	if httpClient == nil {
+		return NewHTTPSenderWithConfig(HTTPSenderConfig{})
+	}
+	return &HTTPSender{client: httpClient, resolver: net.DefaultResolver}
+}
+
+// NewHTTPSenderWithConfig creates a webhook sender with its own HTTP client
+func NewHTTPSenderWithConfig(config HTTPSenderConfig) client.WebhookSender {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultRequestTimeout
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isInternalAddress(ip) {
+				return fmt.Errorf("%w: %s", client.ErrWebhookTargetForbidden, host)
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		// No proxy: the dialed address must be the webhook host
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &HTTPSender{
+		client:       &http.Client{Timeout: config.Timeout, Transport: transport},
+		resolver:     net.DefaultResolver,
+		allowPrivate: config.AllowPrivateNetworks,
+	}
+}
+
+// ValidateTarget implements client.WebhookSender. A host that cannot be resolved yet is
+// accepted; its address is checked again on every delivery.
+func (s *HTTPSender) ValidateTarget(ctx context.Context, rawURL string) error {
+	if s.allowPrivate {
+		return nil
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	host := strings.TrimSuffix(strings.ToLower(target.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", client.ErrWebhookTargetForbidden, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if isInternalAddress(ip) {
+			return fmt.Errorf("%w: %s", client.ErrWebhookTargetForbidden, host)
+		}
+		return nil
+	}
+	addresses, err := s.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, address := range addresses {
+		if isInternalAddress(address.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", client.ErrWebhookTargetForbidden, host, address.IP)
+		}
+	}
+	return nil
+}
+
+// Send implements client.WebhookSender
+func (s *HTTPSender) Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", cloudEventContentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// Drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// isInternalAddress reports loopback, private, link-local (including the 169.254.169.254
+// metadata endpoint), shared, unspecified and multicast addresses
+func isInternalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) ||
+		(ip.To4() != nil && ip.To4()[0] == 0)
+}
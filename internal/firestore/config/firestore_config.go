@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -16,6 +17,24 @@ type RealtimeConfig struct {
 	// Helps in preventing blocking when broadcasting events if a client is slow.
 	ClientSendChannelBuffer int `env:"CLIENT_SEND_CHANNEL_BUFFER" envDefault:"10" mapstructure:"client_send_channel_buffer" json:"client_send_channel_buffer"`
 
+	// ChangeFeedRetention is how long document changes stay readable from the change feed.
+	ChangeFeedRetention time.Duration `env:"CHANGE_FEED_RETENTION" envDefault:"168h" mapstructure:"change_feed_retention" json:"change_feed_retention"`
+
+	// ChangeStreamsEnabled makes every instance publish realtime events from MongoDB change
+	// streams instead of from its own writes, so listeners see writes handled by any replica.
+	// Requires a replica set; MongoDB 6.0+ for previous document data.
+	ChangeStreamsEnabled bool `env:"REALTIME_CHANGE_STREAMS" envDefault:"false" mapstructure:"change_streams_enabled" json:"change_streams_enabled"`
+
+	// RedisFanoutEnabled publishes realtime events through Redis so that every instance delivers
+	// them to its own listeners, with per-path sequences and resume tokens shared by all instances.
+	// Mutually exclusive with ChangeStreamsEnabled, which would deliver every write twice.
+	// Requires a single-node Redis: the publish scripts touch the streams and sequence
+	// counters of several paths at once, which Redis Cluster rejects across hash slots.
+	RedisFanoutEnabled bool `env:"REALTIME_REDIS_FANOUT" envDefault:"false" mapstructure:"redis_fanout_enabled" json:"redis_fanout_enabled"`
+
+	// ChangeStreamID identifies the persisted resume token of this instance. Defaults to the hostname.
+	ChangeStreamID string `env:"REALTIME_CHANGE_STREAM_ID" mapstructure:"change_stream_id" json:"change_stream_id"`
+
 	// Example: HandshakeTimeout for WebSocket connections
 	// HandshakeTimeout time.Duration `env:"HANDSHAKE_TIMEOUT" envDefault:"5s" mapstructure:"handshake_timeout" json:"handshake_timeout"`
 
@@ -78,6 +97,13 @@ type FirestoreConfig struct {
 	Realtime            RealtimeConfig `mapstructure:"realtime" json:"realtime"`
 	CORS                CORSConfig     `mapstructure:"cors" json:"cors"`
 	Redis               RedisConfig    `mapstructure:"redis" json:"redis"`
+	// WebhookAllowPrivateNetworks lets document triggers deliver to loopback and private
+	// addresses. Only for local development: it exposes internal services to webhook URLs.
+	WebhookAllowPrivateNetworks bool `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" envDefault:"false" mapstructure:"webhook_allow_private_networks" json:"webhook_allow_private_networks"`
+	// RulesCoverageEnabled makes the rules engine count the rules and conditions exercised by
+	// every evaluated request, reported by the securityRules:coverage routes. A debugging aid:
+	// it adds work to every request, so it is off by default.
+	RulesCoverageEnabled bool `env:"RULES_COVERAGE" envDefault:"false" mapstructure:"rules_coverage_enabled" json:"rules_coverage_enabled"`
 	// Other configurations for persistence, security rules, etc.
 }
 
@@ -116,10 +142,22 @@ func LoadConfig() (*FirestoreConfig, error) {
 	if cfg.Realtime.ClientSendChannelBuffer <= 0 {
 		cfg.Realtime.ClientSendChannelBuffer = 10
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+// Validate reports configuration combinations the module cannot run with.
+func (c *FirestoreConfig) Validate() error {
+	if c.Realtime.ChangeStreamsEnabled && c.Realtime.RedisFanoutEnabled {
+		// Each source publishes every write on its own, so listeners would receive it twice
+		return errors.New("REALTIME_CHANGE_STREAMS and REALTIME_REDIS_FANOUT cannot both be enabled; choose one realtime event source")
+	}
+	return nil
+}
+
 // DefaultFirestoreConfig returns a FirestoreConfig with default values.
 func DefaultFirestoreConfig() *FirestoreConfig {
 	return &FirestoreConfig{
@@ -128,6 +166,7 @@ func DefaultFirestoreConfig() *FirestoreConfig {
 		Realtime: RealtimeConfig{
 			WebSocketPath:           "/ws/v1/listen", // Default path
 			ClientSendChannelBuffer: 10,              // Default buffer size
+			ChangeFeedRetention:     7 * 24 * time.Hour,
 			// HandshakeTimeout: time.Second * 5,
 			// MaxMessageSize: 1024 * 1024, // 1MB
 		},
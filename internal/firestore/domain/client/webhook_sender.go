@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWebhookTargetForbidden is returned for webhook URLs whose host is, or resolves to, a
+// loopback, private, link-local or otherwise internal address, such as a cloud metadata
+// endpoint. Delivering to such a target is never retried.
+var ErrWebhookTargetForbidden = errors.New("webhook target address is not allowed")
+
+// WebhookSender defines the interface for delivering events to HTTP webhooks.
+// It is used by the document triggers to deliver CloudEvents.
+type WebhookSender interface {
+	// ValidateTarget checks that a webhook URL can be delivered to. It resolves the host
+	// and returns an error wrapping ErrWebhookTargetForbidden for internal addresses.
+	ValidateTarget(ctx context.Context, url string) error
+
+	// Send posts body as a structured CloudEvent with the given extra headers.
+	// It returns the response status code, or an error when no response was received.
+	Send(ctx context.Context, url string, headers map[string]string, body []byte) (statusCode int, err error)
+}
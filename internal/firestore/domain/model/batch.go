@@ -71,6 +71,14 @@ type IndexField struct {
 	Path  string          `json:"path" bson:"path"`
 	Order IndexFieldOrder `json:"order" bson:"order"`
 	Mode  IndexFieldMode  `json:"mode,omitempty" bson:"mode,omitempty"`
+
+	// VectorConfig makes this a vector index field used by findNearest queries
+	VectorConfig *VectorIndexConfig `json:"vectorConfig,omitempty" bson:"vectorConfig,omitempty"`
+}
+
+// VectorIndexConfig describes a vector index field. Only vectors of Dimension are indexed.
+type VectorIndexConfig struct {
+	Dimension int `json:"dimension" bson:"dimension"`
 }
 
 // IndexFieldOrder defines the order of an index field
@@ -86,6 +94,7 @@ type IndexFieldMode string
 
 const (
 	IndexFieldModeArray IndexFieldMode = "ARRAY_CONTAINS"
+	IndexFieldModeGeo   IndexFieldMode = "GEO" // 2dsphere index on a GeoPoint field
 )
 
 // IndexState defines the state of an index
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrChangeFeedCursorExpired is returned when the changes after a cursor have been pruned
+// from the changelog; the reader has to resynchronize from a full export
+var ErrChangeFeedCursorExpired = errors.New("change feed cursor expired")
+
+// ChangeLogEntry is one committed document change in the durable changelog of a database.
+// Sequences increase by one for every change of the database.
+type ChangeLogEntry struct {
+	OrganizationID  string          `json:"organizationId,omitempty" bson:"organization_id,omitempty"`
+	ProjectID       string          `json:"projectId" bson:"project_id"`
+	DatabaseID      string          `json:"databaseId" bson:"database_id"`
+	Sequence        int64           `json:"sequence" bson:"sequence"`
+	CollectionGroup string          `json:"collectionGroup" bson:"collection_group"` // Collection ID of the document
+	Change          *DocumentChange `json:"change" bson:"change"`
+	RecordedAt      time.Time       `json:"recordedAt" bson:"recorded_at"`
+}
+
+// ChangeLogState tracks the sequences of the changelog of a database
+type ChangeLogState struct {
+	OrganizationID string `json:"organizationId,omitempty" bson:"organization_id,omitempty"`
+	ProjectID      string `json:"projectId" bson:"project_id"`
+	DatabaseID     string `json:"databaseId" bson:"database_id"`
+	LastSequence   int64  `json:"lastSequence" bson:"last_sequence"`   // Last sequence handed out
+	PrunedThrough  int64  `json:"prunedThrough" bson:"pruned_through"` // Entries up to this sequence were removed by retention
+}
+
+// ChangeFeedView selects the document snapshots returned by the change feed
+type ChangeFeedView string
+
+const (
+	ChangeFeedViewFull  ChangeFeedView = "FULL"  // Before and after snapshots
+	ChangeFeedViewAfter ChangeFeedView = "AFTER" // Only the snapshot after the change
+)
+
+// IsValid reports whether the view is supported
+func (v ChangeFeedView) IsValid() bool {
+	return v == ChangeFeedViewFull || v == ChangeFeedViewAfter
+}
+
+// ChangeFeedEntry is a document change as returned by the change feed
+type ChangeFeedEntry struct {
+	Cursor     string             `json:"cursor"` // Cursor resuming right after this change
+	Kind       DocumentChangeKind `json:"kind"`
+	Document   string             `json:"document"` // Relative document path
+	Before     *Document          `json:"before,omitempty"`
+	After      *Document          `json:"after,omitempty"`
+	CommitTime time.Time          `json:"commitTime"`
+}
+
+// ChangeFeedPage is a page of the change feed in commit order
+type ChangeFeedPage struct {
+	Changes    []*ChangeFeedEntry `json:"changes"`
+	NextCursor string             `json:"nextCursor"` // Pass as since to read the following changes
+}
+
+// NewChangeFeedEntry renders a changelog entry in the given view
+func NewChangeFeedEntry(entry *ChangeLogEntry, view ChangeFeedView) *ChangeFeedEntry {
+	result := &ChangeFeedEntry{
+		Cursor:     FormatChangeFeedCursor(entry.Sequence),
+		Kind:       entry.Change.Kind(),
+		Document:   entry.Change.DocumentPath,
+		After:      entry.Change.After,
+		CommitTime: entry.Change.CommitTime,
+	}
+	if view != ChangeFeedViewAfter {
+		result.Before = entry.Change.Before
+	}
+	return result
+}
+
+// FormatChangeFeedCursor encodes a changelog sequence as a change feed cursor
+func FormatChangeFeedCursor(sequence int64) string {
+	return strconv.FormatInt(sequence, 10)
+}
+
+// ParseChangeFeedCursor decodes a change feed cursor; the empty cursor reads from the
+// oldest retained change
+func ParseChangeFeedCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	sequence, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || sequence < 0 {
+		return 0, fmt.Errorf("invalid change feed cursor %q", cursor)
+	}
+	return sequence, nil
+}
@@ -0,0 +1,243 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SchemaMode controls how schema violations are handled on writes
+type SchemaMode string
+
+const (
+	SchemaModeEnforce SchemaMode = "enforce" // Reject writes that violate the schema
+	SchemaModeWarn    SchemaMode = "warn"    // Accept the write, log and count the violation
+	SchemaModeOff     SchemaMode = "off"     // Schema is stored but not evaluated
+)
+
+// SchemaType is a JSON-Schema-like type name extended with Firestore value types
+type SchemaType string
+
+const (
+	SchemaTypeString    SchemaType = "string"
+	SchemaTypeInteger   SchemaType = "integer"
+	SchemaTypeNumber    SchemaType = "number" // integer or double
+	SchemaTypeBoolean   SchemaType = "boolean"
+	SchemaTypeNull      SchemaType = "null"
+	SchemaTypeObject    SchemaType = "object" // Firestore map
+	SchemaTypeArray     SchemaType = "array"
+	SchemaTypeTimestamp SchemaType = "timestamp"
+	SchemaTypeBytes     SchemaType = "bytes"
+	SchemaTypeReference SchemaType = "reference"
+	SchemaTypeGeoPoint  SchemaType = "geopoint"
+	SchemaTypeVector    SchemaType = "vector"
+)
+
+// CollectionSchema is a data contract attached to a collection or a collection group pattern.
+//
+// CollectionPattern uses the same wildcards as security rules match paths:
+// "users" matches the top-level collection, "users/{userId}/posts" (or "users/*/posts")
+// matches posts under any user and "{path=**}/posts" (or "**/posts") matches every
+// collection named posts at any depth.
+type CollectionSchema struct {
+	SchemaID          string       `json:"schemaId" bson:"schema_id"`
+	ProjectID         string       `json:"projectId" bson:"project_id"`
+	DatabaseID        string       `json:"databaseId" bson:"database_id"`
+	CollectionPattern string       `json:"collectionPattern" bson:"collection_pattern"`
+	Mode              SchemaMode   `json:"mode" bson:"mode"`
+	Schema            *FieldSchema `json:"schema" bson:"schema"`
+	Description       string       `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedAt         time.Time    `json:"createdAt" bson:"created_at"`
+	UpdatedAt         time.Time    `json:"updatedAt" bson:"updated_at"`
+}
+
+// FieldSchema describes the accepted values of a document or field.
+// The root schema of a collection describes the document as an object.
+type FieldSchema struct {
+	Type                 SchemaTypes             `json:"type,omitempty" bson:"type,omitempty"`
+	Description          string                  `json:"description,omitempty" bson:"description,omitempty"`
+	Properties           map[string]*FieldSchema `json:"properties,omitempty" bson:"properties,omitempty"`
+	Required             []string                `json:"required,omitempty" bson:"required,omitempty"`
+	AdditionalProperties *bool                   `json:"additionalProperties,omitempty" bson:"additional_properties,omitempty"`
+	Items                *FieldSchema            `json:"items,omitempty" bson:"items,omitempty"`
+	Enum                 []interface{}           `json:"enum,omitempty" bson:"enum,omitempty"`
+	Minimum              *float64                `json:"minimum,omitempty" bson:"minimum,omitempty"`
+	Maximum              *float64                `json:"maximum,omitempty" bson:"maximum,omitempty"`
+	MinLength            *int                    `json:"minLength,omitempty" bson:"min_length,omitempty"`
+	MaxLength            *int                    `json:"maxLength,omitempty" bson:"max_length,omitempty"`
+	Pattern              string                  `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	MinItems             *int                    `json:"minItems,omitempty" bson:"min_items,omitempty"`
+	MaxItems             *int                    `json:"maxItems,omitempty" bson:"max_items,omitempty"`
+}
+
+// SchemaTypes accepts either a single type ("string") or a list of types (["string", "null"])
+type SchemaTypes []SchemaType
+
+// UnmarshalJSON implements JSON Schema's string-or-array form of "type"
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single SchemaType
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	var multiple []SchemaType
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("schema type must be a string or an array of strings")
+	}
+	*t = multiple
+	return nil
+}
+
+// MarshalJSON writes a single type as a plain string
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(string(t[0]))
+	}
+	return json.Marshal([]SchemaType(t))
+}
+
+// Allows reports whether the type list accepts the given type. An empty list accepts anything.
+func (t SchemaTypes) Allows(schemaType SchemaType) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, allowed := range t {
+		if allowed == schemaType || (allowed == SchemaTypeNumber && schemaType == SchemaTypeInteger) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collection schema validation errors
+var (
+	ErrInvalidSchemaMode    = errors.New("invalid schema mode")
+	ErrInvalidSchemaPattern = errors.New("invalid collection pattern")
+	ErrInvalidSchema        = errors.New("invalid schema definition")
+)
+
+var validSchemaTypes = map[SchemaType]bool{
+	SchemaTypeString: true, SchemaTypeInteger: true, SchemaTypeNumber: true, SchemaTypeBoolean: true,
+	SchemaTypeNull: true, SchemaTypeObject: true, SchemaTypeArray: true, SchemaTypeTimestamp: true,
+	SchemaTypeBytes: true, SchemaTypeReference: true, SchemaTypeGeoPoint: true, SchemaTypeVector: true,
+}
+
+// Validate checks the schema definition itself
+func (s *CollectionSchema) Validate() error {
+	switch s.Mode {
+	case SchemaModeEnforce, SchemaModeWarn, SchemaModeOff:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSchemaMode, s.Mode)
+	}
+	if err := validateCollectionPattern(s.CollectionPattern); err != nil {
+		return err
+	}
+	if s.Schema == nil {
+		return fmt.Errorf("%w: schema is required", ErrInvalidSchema)
+	}
+	return s.Schema.validate("$")
+}
+
+func (f *FieldSchema) validate(path string) error {
+	for _, t := range f.Type {
+		if !validSchemaTypes[t] {
+			return fmt.Errorf("%w: unknown type %q at %s", ErrInvalidSchema, t, path)
+		}
+	}
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return fmt.Errorf("%w: invalid pattern at %s: %v", ErrInvalidSchema, path, err)
+		}
+	}
+	for name, prop := range f.Properties {
+		if prop == nil {
+			return fmt.Errorf("%w: empty property schema at %s.%s", ErrInvalidSchema, path, name)
+		}
+		if err := prop.validate(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if f.Items != nil {
+		return f.Items.validate(path + "[]")
+	}
+	return nil
+}
+
+func validateCollectionPattern(pattern string) error {
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return fmt.Errorf("%w: pattern is required", ErrInvalidSchemaPattern)
+	}
+	segments := strings.Split(trimmed, "/")
+	last := segments[len(segments)-1]
+	if isRecursiveWildcard(last) {
+		return fmt.Errorf("%w: %q must end with a collection segment", ErrInvalidSchemaPattern, pattern)
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("%w: %q contains an empty segment", ErrInvalidSchemaPattern, pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a documents-relative collection path (e.g. "users/u1/posts") matches the pattern
+func (s *CollectionSchema) Matches(collectionPath string) bool {
+	return matchPatternSegments(splitSchemaPath(s.CollectionPattern), splitSchemaPath(collectionPath))
+}
+
+// Specificity ranks matching schemas: more literal segments win over wildcards
+func (s *CollectionSchema) Specificity() int {
+	score := 0
+	for _, segment := range splitSchemaPath(s.CollectionPattern) {
+		switch {
+		case isRecursiveWildcard(segment):
+		case isSingleWildcard(segment):
+			score++
+		default:
+			score += 2
+		}
+	}
+	return score
+}
+
+func matchPatternSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	head := pattern[0]
+	if isRecursiveWildcard(head) {
+		for i := 0; i <= len(path); i++ {
+			if matchPatternSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if isSingleWildcard(head) || head == path[0] {
+		return matchPatternSegments(pattern[1:], path[1:])
+	}
+	return false
+}
+
+func isRecursiveWildcard(segment string) bool {
+	return segment == "**" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "=**}"))
+}
+
+func isSingleWildcard(segment string) bool {
+	return segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && !isRecursiveWildcard(segment))
+}
+
+func splitSchemaPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionSchema_Matches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"users", "users", true},
+		{"users", "users/u1/posts", false},
+		{"users/{userId}/posts", "users/u1/posts", true},
+		{"users/*/posts", "users/u1/posts", true},
+		{"users/*/posts", "orgs/o1/posts", false},
+		{"{path=**}/posts", "posts", true},
+		{"**/posts", "users/u1/posts", true},
+		{"**/posts", "users/u1/posts/p1/comments", false},
+	}
+	for _, tt := range tests {
+		schema := &CollectionSchema{CollectionPattern: tt.pattern}
+		assert.Equal(t, tt.want, schema.Matches(tt.path), "%s ~ %s", tt.pattern, tt.path)
+	}
+}
+
+func TestCollectionSchema_Specificity(t *testing.T) {
+	literal := &CollectionSchema{CollectionPattern: "users/u1/posts"}
+	single := &CollectionSchema{CollectionPattern: "users/{userId}/posts"}
+	group := &CollectionSchema{CollectionPattern: "{path=**}/posts"}
+
+	assert.Greater(t, literal.Specificity(), single.Specificity())
+	assert.Greater(t, single.Specificity(), group.Specificity())
+}
+
+func TestCollectionSchema_Validate(t *testing.T) {
+	valid := &CollectionSchema{
+		CollectionPattern: "users",
+		Mode:              SchemaModeEnforce,
+		Schema:            &FieldSchema{Type: SchemaTypes{SchemaTypeObject}},
+	}
+	require.NoError(t, valid.Validate())
+
+	badMode := *valid
+	badMode.Mode = "strict"
+	assert.True(t, errors.Is(badMode.Validate(), ErrInvalidSchemaMode))
+
+	badPattern := *valid
+	badPattern.CollectionPattern = "users/{path=**}"
+	assert.True(t, errors.Is(badPattern.Validate(), ErrInvalidSchemaPattern))
+
+	badType := *valid
+	badType.Schema = &FieldSchema{Properties: map[string]*FieldSchema{"age": {Type: SchemaTypes{"int"}}}}
+	assert.True(t, errors.Is(badType.Validate(), ErrInvalidSchema))
+}
+
+func TestSchemaTypes_JSON(t *testing.T) {
+	var schema FieldSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"string"}`), &schema))
+	assert.Equal(t, SchemaTypes{SchemaTypeString}, schema.Type)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":["string","null"]}`), &schema))
+	assert.Equal(t, SchemaTypes{SchemaTypeString, SchemaTypeNull}, schema.Type)
+
+	data, err := json.Marshal(FieldSchema{Type: SchemaTypes{SchemaTypeNumber}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"number"}`, string(data))
+
+	assert.True(t, SchemaTypes{SchemaTypeNumber}.Allows(SchemaTypeInteger))
+	assert.False(t, SchemaTypes{SchemaTypeInteger}.Allows(SchemaTypeNumber))
+}
@@ -86,6 +86,8 @@ func (fv *FieldValue) MarshalJSON() ([]byte, error) {
 		result["referenceValue"] = fv.Value
 	case FieldTypeGeoPoint:
 		result["geoPointValue"] = fv.Value
+	case FieldTypeVector:
+		result["vectorValue"] = fv.Value
 	case FieldTypeArray:
 		result["arrayValue"] = fv.Value
 	case FieldTypeMap:
@@ -143,6 +145,15 @@ func (fv *FieldValue) UnmarshalJSON(data []byte) error {
 	} else if val, exists := result["geoPointValue"]; exists {
 		fv.ValueType = FieldTypeGeoPoint
 		fv.Value = val
+		if point, ok := GeoPointFromValue(val); ok {
+			fv.Value = point
+		}
+	} else if val, exists := result["vectorValue"]; exists {
+		fv.ValueType = FieldTypeVector
+		fv.Value = val
+		if vector, ok := VectorFromValue(val); ok {
+			fv.Value = vector
+		}
 	} else if val, exists := result["arrayValue"]; exists {
 		fv.ValueType = FieldTypeArray
 		fv.Value = val
@@ -180,6 +191,7 @@ const (
 	// Complex types
 	FieldTypeReference FieldValueType = "referenceValue"
 	FieldTypeGeoPoint  FieldValueType = "geoPointValue"
+	FieldTypeVector    FieldValueType = "vectorValue"
 	FieldTypeArray     FieldValueType = "arrayValue"
 	FieldTypeMap       FieldValueType = "mapValue"
 )
@@ -347,6 +359,8 @@ func NewFieldValue(value interface{}) *FieldValue {
 		return &FieldValue{ValueType: FieldTypeTimestamp, Value: v}
 	case *GeoPoint:
 		return &FieldValue{ValueType: FieldTypeGeoPoint, Value: v}
+	case *VectorValue:
+		return &FieldValue{ValueType: FieldTypeVector, Value: v}
 	case []interface{}:
 		arrayValues := make([]*FieldValue, len(v))
 		for i, item := range v {
@@ -354,6 +368,18 @@ func NewFieldValue(value interface{}) *FieldValue {
 		}
 		return &FieldValue{ValueType: FieldTypeArray, Value: &ArrayValue{Values: arrayValues}}
 	case map[string]interface{}:
+		// A Firestore typed {"geoPointValue": {...}} map stores a GeoPoint
+		if _, typed := v["geoPointValue"]; typed && len(v) == 1 {
+			if point, ok := GeoPointFromValue(v); ok {
+				return &FieldValue{ValueType: FieldTypeGeoPoint, Value: point}
+			}
+		}
+		// So do {"vectorValue": {"values": [...]}} and Firestore {"__type__": "__vector__"} maps for vectors
+		if _, typed := v["vectorValue"]; (typed && len(v) == 1) || v["__type__"] == "__vector__" {
+			if vector, ok := VectorFromValue(v); ok {
+				return &FieldValue{ValueType: FieldTypeVector, Value: vector}
+			}
+		}
 		mapFields := make(map[string]*FieldValue)
 		for k, item := range v {
 			mapFields[k] = NewFieldValue(item)
@@ -403,6 +429,8 @@ func (fv *FieldValue) ToInterface() interface{} {
 		return fv.Value.(time.Time)
 	case FieldTypeGeoPoint:
 		return fv.Value.(*GeoPoint)
+	case FieldTypeVector:
+		return fv.Value.(*VectorValue)
 	case FieldTypeArray:
 		arrayValue := fv.Value.(*ArrayValue)
 		result := make([]interface{}, len(arrayValue.Values))
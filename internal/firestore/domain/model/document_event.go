@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// DocumentEventNoSubscriber is the state of an outbox event that no registered handler
+// matched when it was dispatched, e.g. after the handler was removed
+const DocumentEventNoSubscriber TriggerDeliveryState = "NO_SUBSCRIBER"
+
+// DocumentEvent is a committed document change recorded in the document event outbox.
+// It stays pending until every matching in-process handler has processed it. Delivery is
+// tracked per handler: after a failure or a crash the event is dispatched again only to
+// the handlers that have not processed it yet. Delivery is at least once and handlers
+// deduplicate on EventID.
+type DocumentEvent struct {
+	EventID       string               `json:"eventId" bson:"event_id"`
+	Change        *DocumentChange      `json:"change" bson:"change"`
+	State         TriggerDeliveryState `json:"state" bson:"state"`
+	HandledBy     []string             `json:"handledBy,omitempty" bson:"handled_by,omitempty"` // IDs of the handlers that processed the event
+	Attempts      int                  `json:"attempts" bson:"attempts"`
+	NextAttemptAt time.Time            `json:"nextAttemptAt" bson:"next_attempt_at"`
+	LastError     string               `json:"lastError,omitempty" bson:"last_error,omitempty"`
+	CreatedAt     time.Time            `json:"createdAt" bson:"created_at"`
+	UpdatedAt     time.Time            `json:"updatedAt" bson:"updated_at"`
+	DeliveredAt   *time.Time           `json:"deliveredAt,omitempty" bson:"delivered_at,omitempty"`
+}
+
+// Handled reports whether the handler with the given ID has processed the event
+func (e *DocumentEvent) Handled(handlerID string) bool {
+	for _, id := range e.HandledBy {
+		if id == handlerID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EarthRadiusMeters is the mean Earth radius used for distance computations
+const EarthRadiusMeters = 6371008.8
+
+// Geo query errors
+var (
+	ErrInvalidGeoQuery = errors.New("invalid geo query")
+	ErrInvalidGeoPoint = errors.New("invalid geo point")
+)
+
+// GeoQuery restricts a query to documents whose GeoPoint field lies within a radius
+// or a bounding box, optionally ordering the results by distance to Near.
+type GeoQuery struct {
+	Field           string          `json:"field" bson:"field"`                                           // GeoPoint field path, e.g. "location" or "address.coordinates"
+	Near            *GeoPoint       `json:"near,omitempty" bson:"near,omitempty"`                         // Center of the radius and of the distance ordering
+	RadiusMeters    float64         `json:"radiusMeters,omitempty" bson:"radius_meters,omitempty"`        // Requires Near
+	BoundingBox     *GeoBoundingBox `json:"boundingBox,omitempty" bson:"bounding_box,omitempty"`          // Can be combined with a radius
+	OrderByDistance bool            `json:"orderByDistance,omitempty" bson:"order_by_distance,omitempty"` // Nearest first, requires Near
+}
+
+// GeoBoundingBox is a latitude/longitude rectangle. A SouthWest longitude greater than
+// the NorthEast longitude describes a box crossing the antimeridian.
+type GeoBoundingBox struct {
+	SouthWest GeoPoint `json:"southwest" bson:"southwest"`
+	NorthEast GeoPoint `json:"northeast" bson:"northeast"`
+}
+
+// Validate checks that a point has a valid latitude and longitude
+func (p GeoPoint) Validate() error {
+	if math.IsNaN(p.Latitude) || p.Latitude < -90 || p.Latitude > 90 {
+		return fmt.Errorf("%w: latitude %v out of range [-90, 90]", ErrInvalidGeoPoint, p.Latitude)
+	}
+	if math.IsNaN(p.Longitude) || p.Longitude < -180 || p.Longitude > 180 {
+		return fmt.Errorf("%w: longitude %v out of range [-180, 180]", ErrInvalidGeoPoint, p.Longitude)
+	}
+	return nil
+}
+
+// Validate checks the geo query constraints
+func (g *GeoQuery) Validate() error {
+	if g.Field == "" {
+		return fmt.Errorf("%w: field is required", ErrInvalidGeoQuery)
+	}
+	if g.Near == nil && g.BoundingBox == nil {
+		return fmt.Errorf("%w: a center point or a bounding box is required", ErrInvalidGeoQuery)
+	}
+	if g.Near != nil {
+		if err := g.Near.Validate(); err != nil {
+			return fmt.Errorf("%w: near: %v", ErrInvalidGeoQuery, err)
+		}
+	}
+	if g.RadiusMeters < 0 || math.IsNaN(g.RadiusMeters) {
+		return fmt.Errorf("%w: radius must not be negative", ErrInvalidGeoQuery)
+	}
+	if g.RadiusMeters > 0 && g.Near == nil {
+		return fmt.Errorf("%w: radius requires a center point", ErrInvalidGeoQuery)
+	}
+	if g.OrderByDistance && g.Near == nil {
+		return fmt.Errorf("%w: ordering by distance requires a center point", ErrInvalidGeoQuery)
+	}
+	if g.BoundingBox != nil {
+		if err := g.BoundingBox.SouthWest.Validate(); err != nil {
+			return fmt.Errorf("%w: southwest: %v", ErrInvalidGeoQuery, err)
+		}
+		if err := g.BoundingBox.NorthEast.Validate(); err != nil {
+			return fmt.Errorf("%w: northeast: %v", ErrInvalidGeoQuery, err)
+		}
+		if g.BoundingBox.SouthWest.Latitude > g.BoundingBox.NorthEast.Latitude {
+			return fmt.Errorf("%w: southwest latitude is north of northeast latitude", ErrInvalidGeoQuery)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a point satisfies the radius and bounding box constraints
+func (g *GeoQuery) Matches(point *GeoPoint) bool {
+	if point == nil {
+		return false
+	}
+	if g.BoundingBox != nil && !g.BoundingBox.Contains(*point) {
+		return false
+	}
+	if g.RadiusMeters > 0 && g.Near != nil && HaversineDistance(*g.Near, *point) > g.RadiusMeters {
+		return false
+	}
+	return true
+}
+
+// MatchesData evaluates the geo query against plain document data, as used by realtime listeners
+func (g *GeoQuery) MatchesData(data map[string]interface{}) bool {
+	point, ok := GeoPointFromValue(lookupPlainField(data, g.Field))
+	return ok && g.Matches(point)
+}
+
+// Contains reports whether a point lies within the box, handling antimeridian crossing
+func (b *GeoBoundingBox) Contains(point GeoPoint) bool {
+	if point.Latitude < b.SouthWest.Latitude || point.Latitude > b.NorthEast.Latitude {
+		return false
+	}
+	if b.SouthWest.Longitude <= b.NorthEast.Longitude {
+		return point.Longitude >= b.SouthWest.Longitude && point.Longitude <= b.NorthEast.Longitude
+	}
+	return point.Longitude >= b.SouthWest.Longitude || point.Longitude <= b.NorthEast.Longitude
+}
+
+// HaversineDistance returns the great-circle distance between two points in meters
+func HaversineDistance(a, b GeoPoint) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// FilterAndSortDocuments keeps the documents matching the geo query and, when requested,
+// orders them nearest first. Documents without a GeoPoint in the field are dropped.
+func (g *GeoQuery) FilterAndSortDocuments(docs []*Document) []*Document {
+	type scored struct {
+		doc      *Document
+		distance float64
+	}
+	matched := make([]scored, 0, len(docs))
+	for _, doc := range docs {
+		point, ok := GeoPointFromValue(lookupFieldValue(doc.Fields, g.Field))
+		if !ok || !g.Matches(point) {
+			continue
+		}
+		distance := 0.0
+		if g.Near != nil {
+			distance = HaversineDistance(*g.Near, *point)
+		}
+		matched = append(matched, scored{doc, distance})
+	}
+	if g.OrderByDistance {
+		sort.SliceStable(matched, func(i, j int) bool { return matched[i].distance < matched[j].distance })
+	}
+	result := make([]*Document, len(matched))
+	for i, m := range matched {
+		result[i] = m.doc
+	}
+	return result
+}
+
+// GeoPointFromValue extracts a GeoPoint from the representations it takes across layers:
+// *GeoPoint, GeoPoint, FieldValue, {"latitude","longitude"} maps (plain or BSON) and
+// Firestore typed {"geoPointValue": {...}} maps.
+func GeoPointFromValue(value interface{}) (*GeoPoint, bool) {
+	switch v := value.(type) {
+	case *GeoPoint:
+		return v, v != nil
+	case GeoPoint:
+		return &v, true
+	case *FieldValue:
+		if v == nil || v.ValueType != FieldTypeGeoPoint {
+			return nil, false
+		}
+		return GeoPointFromValue(v.Value)
+	case primitive.M:
+		return GeoPointFromValue(map[string]interface{}(v))
+	case primitive.D:
+		return GeoPointFromValue(map[string]interface{}(v.Map()))
+	case map[string]interface{}:
+		if typed, exists := v["geoPointValue"]; exists && len(v) == 1 {
+			return GeoPointFromValue(typed)
+		}
+		lat, latOK := toFloat64(v["latitude"])
+		lng, lngOK := toFloat64(v["longitude"])
+		if !latOK || !lngOK {
+			return nil, false
+		}
+		return &GeoPoint{Latitude: lat, Longitude: lng}, true
+	}
+	return nil, false
+}
+
+// toFloat64 converts a JSON, BSON or Go number to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// lookupPlainField resolves a dotted field path in plain document data
+func lookupPlainField(data map[string]interface{}, fieldPath string) interface{} {
+	path, err := NewFieldPath(fieldPath)
+	if err != nil {
+		return nil
+	}
+	var current interface{} = data
+	for _, segment := range path.Segments() {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[segment]
+	}
+	return current
+}
+
+// lookupFieldValue resolves a dotted field path in typed document fields
+func lookupFieldValue(fields map[string]*FieldValue, fieldPath string) *FieldValue {
+	path, err := NewFieldPath(fieldPath)
+	if err != nil {
+		return nil
+	}
+	segments := path.Segments()
+	current := fields
+	for i, segment := range segments {
+		value, exists := current[segment]
+		if !exists || value == nil {
+			return nil
+		}
+		if i == len(segments)-1 {
+			return value
+		}
+		nested, ok := value.Value.(*MapValue)
+		if !ok || nested == nil {
+			return nil
+		}
+		current = nested.Fields
+	}
+	return nil
+}
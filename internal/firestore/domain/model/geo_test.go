@@ -0,0 +1,100 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	paris  = GeoPoint{Latitude: 48.8566, Longitude: 2.3522}
+	london = GeoPoint{Latitude: 51.5074, Longitude: -0.1278}
+)
+
+func geoDoc(id string, point *GeoPoint) *Document {
+	fields := map[string]*FieldValue{"name": NewFieldValue(id)}
+	if point != nil {
+		fields["place"] = NewFieldValue(map[string]interface{}{"location": point})
+	}
+	return &Document{DocumentID: id, Fields: fields}
+}
+
+func TestHaversineDistance(t *testing.T) {
+	assert.InDelta(t, 343_500, HaversineDistance(paris, london), 1_000)
+	assert.Equal(t, 0.0, HaversineDistance(paris, paris))
+}
+
+func TestGeoBoundingBox_Contains(t *testing.T) {
+	box := GeoBoundingBox{SouthWest: GeoPoint{Latitude: 48, Longitude: 2}, NorthEast: GeoPoint{Latitude: 49, Longitude: 3}}
+	assert.True(t, box.Contains(paris))
+	assert.False(t, box.Contains(london))
+
+	// A box crossing the antimeridian
+	pacific := GeoBoundingBox{SouthWest: GeoPoint{Latitude: -20, Longitude: 170}, NorthEast: GeoPoint{Latitude: 0, Longitude: -170}}
+	assert.True(t, pacific.Contains(GeoPoint{Latitude: -10, Longitude: 179}))
+	assert.True(t, pacific.Contains(GeoPoint{Latitude: -10, Longitude: -175}))
+	assert.False(t, pacific.Contains(GeoPoint{Latitude: -10, Longitude: 0}))
+}
+
+func TestGeoQuery_Validate(t *testing.T) {
+	valid := &GeoQuery{Field: "location", Near: &paris, RadiusMeters: 5000, OrderByDistance: true}
+	require.NoError(t, valid.Validate())
+
+	invalid := []*GeoQuery{
+		{Near: &paris},
+		{Field: "location"},
+		{Field: "location", RadiusMeters: 10, BoundingBox: &GeoBoundingBox{NorthEast: paris}},
+		{Field: "location", Near: &GeoPoint{Latitude: 91}},
+		{Field: "location", Near: &paris, RadiusMeters: -1},
+		{Field: "location", BoundingBox: &GeoBoundingBox{SouthWest: london, NorthEast: paris}, OrderByDistance: true},
+		{Field: "location", BoundingBox: &GeoBoundingBox{SouthWest: london, NorthEast: paris}},
+	}
+	for _, q := range invalid {
+		assert.True(t, errors.Is(q.Validate(), ErrInvalidGeoQuery), "%+v", q)
+	}
+
+	query := &Query{Path: "places", Geo: valid, Orders: []Order{{Field: "name", Direction: DirectionAscending}}}
+	assert.True(t, errors.Is(query.ValidateQuery(), ErrInvalidGeoQuery))
+}
+
+func TestGeoQuery_FilterAndSortDocuments(t *testing.T) {
+	near := GeoPoint{Latitude: 48.86, Longitude: 2.35}
+	docs := []*Document{
+		geoDoc("london", &london),
+		geoDoc("far-paris", &GeoPoint{Latitude: 48.89, Longitude: 2.35}),
+		geoDoc("none", nil),
+		geoDoc("paris", &paris),
+	}
+
+	q := &GeoQuery{Field: "place.location", Near: &near, RadiusMeters: 5000, OrderByDistance: true}
+	result := q.FilterAndSortDocuments(docs)
+	require.Len(t, result, 2)
+	assert.Equal(t, "paris", result[0].DocumentID)
+	assert.Equal(t, "far-paris", result[1].DocumentID)
+}
+
+func TestGeoQuery_MatchesData(t *testing.T) {
+	q := &GeoQuery{Field: "place.location", Near: &paris, RadiusMeters: 1000}
+
+	assert.True(t, q.MatchesData(map[string]interface{}{"place": map[string]interface{}{"location": &paris}}))
+	assert.True(t, q.MatchesData(map[string]interface{}{"place": map[string]interface{}{
+		"location": map[string]interface{}{"latitude": 48.857, "longitude": 2.352},
+	}}))
+	assert.False(t, q.MatchesData(map[string]interface{}{"place": map[string]interface{}{"location": london}}))
+	assert.False(t, q.MatchesData(map[string]interface{}{"place": "paris"}))
+}
+
+func TestGeoPointFromValue(t *testing.T) {
+	point, ok := GeoPointFromValue(map[string]interface{}{"geoPointValue": map[string]interface{}{"latitude": 1.5, "longitude": int64(2)}})
+	require.True(t, ok)
+	assert.Equal(t, GeoPoint{Latitude: 1.5, Longitude: 2}, *point)
+
+	point, ok = GeoPointFromValue(NewFieldValue(&london))
+	require.True(t, ok)
+	assert.Equal(t, london, *point)
+
+	_, ok = GeoPointFromValue(NewFieldValue("london"))
+	assert.False(t, ok)
+}
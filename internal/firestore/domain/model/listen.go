@@ -0,0 +1,81 @@
+package model
+
+import "time"
+
+// TargetChangeType is the kind of a TargetChange of the Firestore Listen protocol
+type TargetChangeType string
+
+const (
+	// TargetChangeNoChange carries a consistency point; with no target IDs it is global
+	TargetChangeNoChange TargetChangeType = "NO_CHANGE"
+	// TargetChangeAdd confirms that the targets were added
+	TargetChangeAdd TargetChangeType = "ADD"
+	// TargetChangeRemove reports that the targets were removed, with a cause on failure
+	TargetChangeRemove TargetChangeType = "REMOVE"
+	// TargetChangeCurrent reports that the targets are consistent as of the read time
+	TargetChangeCurrent TargetChangeType = "CURRENT"
+	// TargetChangeReset tells the client to discard the documents of the targets; they are sent again
+	TargetChangeReset TargetChangeType = "RESET"
+)
+
+// MessageTypeListen is the WebSocket message type carrying a ListenResponse
+const MessageTypeListen = "listen"
+
+// TargetChangeCause explains why a target was removed
+type TargetChangeCause struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// TargetChange reports a state change of listen targets
+type TargetChange struct {
+	TargetChangeType TargetChangeType   `json:"targetChangeType"`
+	TargetIDs        []SubscriptionID   `json:"targetIds"`
+	Cause            *TargetChangeCause `json:"cause,omitempty"`
+	ResumeToken      ResumeToken        `json:"resumeToken,omitempty"`
+	ReadTime         *time.Time         `json:"readTime,omitempty"`
+}
+
+// ListenDocument is a document as sent to listeners
+type ListenDocument struct {
+	Name       string                 `json:"name"` // Full document path
+	Fields     map[string]interface{} `json:"fields"`
+	UpdateTime time.Time              `json:"updateTime"`
+}
+
+// ListenDocumentChange reports a document that was added to or changed in the targets
+type ListenDocumentChange struct {
+	Document         *ListenDocument  `json:"document"`
+	TargetIDs        []SubscriptionID `json:"targetIds"`
+	RemovedTargetIDs []SubscriptionID `json:"removedTargetIds,omitempty"`
+}
+
+// ListenDocumentDelete reports a document of the targets that was deleted
+type ListenDocumentDelete struct {
+	Document         string           `json:"document"`
+	RemovedTargetIDs []SubscriptionID `json:"removedTargetIds"`
+	ReadTime         time.Time        `json:"readTime"`
+}
+
+// ListenDocumentRemove reports a document that no longer matches the targets
+type ListenDocumentRemove struct {
+	Document         string           `json:"document"`
+	RemovedTargetIDs []SubscriptionID `json:"removedTargetIds"`
+	ReadTime         time.Time        `json:"readTime"`
+}
+
+// ListenResponse is one message of the Firestore Listen protocol; exactly one field is set
+type ListenResponse struct {
+	TargetChange   *TargetChange         `json:"targetChange,omitempty"`
+	DocumentChange *ListenDocumentChange `json:"documentChange,omitempty"`
+	DocumentDelete *ListenDocumentDelete `json:"documentDelete,omitempty"`
+	DocumentRemove *ListenDocumentRemove `json:"documentRemove,omitempty"`
+}
+
+// NewTargetChange creates a ListenResponse holding a target change
+func NewTargetChange(changeType TargetChangeType, targetIDs ...SubscriptionID) ListenResponse {
+	if targetIDs == nil {
+		targetIDs = []SubscriptionID{}
+	}
+	return ListenResponse{TargetChange: &TargetChange{TargetChangeType: changeType, TargetIDs: targetIDs}}
+}
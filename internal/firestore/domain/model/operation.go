@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// OperationState represents the lifecycle state of a long-running operation
+type OperationState string
+
+const (
+	OperationStateRunning   OperationState = "RUNNING"
+	OperationStateSucceeded OperationState = "SUCCEEDED"
+	OperationStateFailed    OperationState = "FAILED"
+	OperationStateCancelled OperationState = "CANCELLED"
+)
+
+// RecursiveDeleteOperation tracks the progress of a recursive delete long-running operation.
+// The operation can be resumed while it is FAILED or CANCELLED; already deleted documents
+// are simply not found again on the next walk.
+type RecursiveDeleteOperation struct {
+	OperationID string `json:"operationId" bson:"operation_id"`
+	// OrganizationID is the organization the operation was started in; project IDs are only
+	// unique within an organization, so operations are only visible from it
+	OrganizationID string         `json:"organizationId,omitempty" bson:"organization_id,omitempty"`
+	ProjectID      string         `json:"projectId" bson:"project_id"`
+	DatabaseID     string         `json:"databaseId" bson:"database_id"`
+	Path           string         `json:"path" bson:"path"` // Relative path of the root document or collection
+	State          OperationState `json:"state" bson:"state"`
+
+	// Progress counters
+	DocumentsDeleted   int64 `json:"documentsDeleted" bson:"documents_deleted"`
+	CollectionsDeleted int64 `json:"collectionsDeleted" bson:"collections_deleted"`
+	BatchesCompleted   int64 `json:"batchesCompleted" bson:"batches_completed"`
+
+	// Resumption metadata
+	Attempts     int    `json:"attempts" bson:"attempts"`
+	LastPath     string `json:"lastPath,omitempty" bson:"last_path,omitempty"` // Last document path deleted
+	ErrorMessage string `json:"error,omitempty" bson:"error,omitempty"`
+	BatchSize    int    `json:"batchSize" bson:"batch_size"`
+	BypassRules  bool   `json:"bypassRules" bson:"bypass_rules"`
+	RequestedBy  string `json:"requestedBy,omitempty" bson:"requested_by,omitempty"` // Only this user or an administrator can manage the operation
+
+	StartTime time.Time  `json:"startTime" bson:"start_time"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updated_at"`
+	EndTime   *time.Time `json:"endTime,omitempty" bson:"end_time,omitempty"`
+}
+
+// Done returns true when the operation reached a terminal state
+func (o *RecursiveDeleteOperation) Done() bool {
+	return o.State == OperationStateSucceeded || o.State == OperationStateFailed || o.State == OperationStateCancelled
+}
+
+// IsResumable returns true if the operation can be restarted
+func (o *RecursiveDeleteOperation) IsResumable() bool {
+	return o.State == OperationStateFailed || o.State == OperationStateCancelled
+}
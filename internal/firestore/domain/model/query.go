@@ -3,6 +3,7 @@ package model
 // Firestore query model definitions
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -34,6 +35,12 @@ type Query struct {
 
 	// LimitToLast for reverse pagination
 	LimitToLast bool `json:"limitToLast,omitempty" bson:"limit_to_last,omitempty"`
+
+	// Geo restricts results to a radius or bounding box around a GeoPoint field
+	Geo *GeoQuery `json:"geo,omitempty" bson:"geo,omitempty"`
+
+	// FindNearest turns the query into a vector similarity search over the filtered documents
+	FindNearest *FindNearest `json:"findNearest,omitempty" bson:"find_nearest,omitempty"`
 }
 
 // Filter represents a single filter condition in a query (where clause)
@@ -170,6 +177,24 @@ func (q *Query) ValidateQuery() error {
 		}
 	}
 
+	if q.Geo != nil {
+		if err := q.Geo.Validate(); err != nil {
+			return err
+		}
+		if q.Geo.OrderByDistance && (len(q.Orders) > 0 || q.LimitToLast) {
+			return fmt.Errorf("%w: ordering by distance cannot be combined with orderBy or limitToLast", ErrInvalidGeoQuery)
+		}
+	}
+
+	if q.FindNearest != nil {
+		if err := q.FindNearest.Validate(); err != nil {
+			return err
+		}
+		if len(q.Orders) > 0 || q.LimitToLast || (q.Geo != nil && q.Geo.OrderByDistance) {
+			return fmt.Errorf("%w: results are ordered by vector distance and cannot have another ordering", ErrInvalidFindNearest)
+		}
+	}
+
 	return nil
 }
 
@@ -19,6 +19,8 @@ const (
 	EventTypeRemoved EventType = "removed"
 	// EventTypeHeartbeat is used for connection health checks
 	EventTypeHeartbeat EventType = "heartbeat"
+	// EventTypeSnapshot groups the changes of one commit, which listeners apply atomically
+	EventTypeSnapshot EventType = "snapshot"
 )
 
 // ResumeToken represents a token that allows resuming a stream from a specific point
@@ -61,6 +63,9 @@ type RealtimeEvent struct {
 
 	// SubscriptionID identifies which subscription this event belongs to
 	SubscriptionID string `json:"subscriptionId,omitempty"`
+
+	// Changes holds the document events of a snapshot event, all committed at Timestamp
+	Changes []RealtimeEvent `json:"changes,omitempty"`
 }
 
 // GenerateResumeToken creates a resume token based on timestamp and sequence
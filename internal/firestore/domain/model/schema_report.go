@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// CollectionSchemaReport describes the shape of the documents observed in a collection
+type CollectionSchemaReport struct {
+	ProjectID        string            `json:"projectId"`
+	DatabaseID       string            `json:"databaseId"`
+	CollectionID     string            `json:"collectionId"`
+	DocumentsScanned int64             `json:"documentsScanned"`
+	Sampled          bool              `json:"sampled"` // True when the collection has more documents than were scanned
+	Fields           []*FieldPathStats `json:"fields"`
+	GeneratedAt      time.Time         `json:"generatedAt"`
+}
+
+// FieldPathStats aggregates the values observed at one field path.
+//
+// Nested map fields use dotted paths ("address.zip") and array elements use
+// a "[]" suffix ("tags[]", "items[].sku").
+type FieldPathStats struct {
+	FieldPath        string               `json:"fieldPath"`
+	Presence         int64                `json:"presence"`      // Documents containing the path
+	PresenceRatio    float64              `json:"presenceRatio"` // Presence / documents scanned
+	Types            map[SchemaType]int64 `json:"types"`         // Observed value type distribution
+	Examples         []interface{}        `json:"examples,omitempty"`
+	Cardinality      int64                `json:"cardinality"`      // Distinct scalar values, estimated
+	CardinalityExact bool                 `json:"cardinalityExact"` // False when Cardinality is a sketch estimate
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// SearchIndexConfig declares the string fields of a collection that are full-text searchable
+type SearchIndexConfig struct {
+	ProjectID    string        `json:"projectId" bson:"project_id"`
+	DatabaseID   string        `json:"databaseId" bson:"database_id"`
+	CollectionID string        `json:"collectionId" bson:"collection_id"`
+	Fields       []SearchField `json:"fields" bson:"fields"`
+	CreatedAt    time.Time     `json:"createdAt" bson:"created_at"`
+	UpdatedAt    time.Time     `json:"updatedAt" bson:"updated_at"`
+}
+
+// SearchField is a searchable field path; string arrays are indexed element by element
+type SearchField struct {
+	FieldPath string  `json:"fieldPath" bson:"field_path"`              // e.g. "name" or "details.description"
+	Weight    float64 `json:"weight,omitempty" bson:"weight,omitempty"` // Relative boost, defaults to 1
+}
+
+// SearchHit is a ranked search result
+type SearchHit struct {
+	Document   *Document           `json:"document"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"` // Field path -> snippets with <em> marked terms
+}
+
+// SearchResult is the response of a full-text search
+type SearchResult struct {
+	Query     string       `json:"query"`
+	Hits      []*SearchHit `json:"hits"`
+	TotalHits int          `json:"totalHits"` // Scanned candidates that exist and match the filters, before the limit
+}
@@ -0,0 +1,337 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DocumentTriggerEventType selects the document changes a trigger fires on,
+// as the Cloud Functions for Firestore event handlers do
+type DocumentTriggerEventType string
+
+const (
+	TriggerOnCreate  DocumentTriggerEventType = "onCreate"
+	TriggerOnUpdate  DocumentTriggerEventType = "onUpdate"
+	TriggerOnDelete  DocumentTriggerEventType = "onDelete"
+	TriggerOnWritten DocumentTriggerEventType = "onWritten"
+)
+
+// IsValid reports whether the event type is supported
+func (t DocumentTriggerEventType) IsValid() bool {
+	switch t {
+	case TriggerOnCreate, TriggerOnUpdate, TriggerOnDelete, TriggerOnWritten:
+		return true
+	}
+	return false
+}
+
+// CloudEventType returns the CloudEvent type delivered for the event type
+func (t DocumentTriggerEventType) CloudEventType() string {
+	switch t {
+	case TriggerOnCreate:
+		return "google.cloud.firestore.document.v1.created"
+	case TriggerOnUpdate:
+		return "google.cloud.firestore.document.v1.updated"
+	case TriggerOnDelete:
+		return "google.cloud.firestore.document.v1.deleted"
+	default:
+		return "google.cloud.firestore.document.v1.written"
+	}
+}
+
+var (
+	ErrInvalidTrigger = errors.New("invalid document trigger")
+)
+
+// DocumentTrigger delivers the changes of the documents matching DocumentPattern to an
+// HTTP webhook.
+//
+// DocumentPattern uses the same wildcards as security rules match paths and must end with a
+// document segment: "users/{userId}" matches every user document and
+// "users/{userId}/{path=**}" every document below a user. Wildcard values are delivered as
+// the event params.
+type DocumentTrigger struct {
+	TriggerID       string                   `json:"triggerId" bson:"trigger_id"`
+	OrganizationID  string                   `json:"organizationId,omitempty" bson:"organization_id,omitempty"` // Only writes of this organization fire the trigger
+	ProjectID       string                   `json:"projectId" bson:"project_id"`
+	DatabaseID      string                   `json:"databaseId" bson:"database_id"`
+	EventType       DocumentTriggerEventType `json:"eventType" bson:"event_type"`
+	DocumentPattern string                   `json:"document" bson:"document_pattern"`
+	URL             string                   `json:"url" bson:"url"`
+	// Headers are sent with every delivery, e.g. an Authorization header expected by the endpoint
+	Headers     map[string]string `json:"headers,omitempty" bson:"headers,omitempty"`
+	Description string            `json:"description,omitempty" bson:"description,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt" bson:"created_at"`
+	UpdatedAt   time.Time         `json:"updatedAt" bson:"updated_at"`
+}
+
+// Validate checks the event type, the document pattern and the webhook URL
+func (t *DocumentTrigger) Validate() error {
+	if !t.EventType.IsValid() {
+		return fmt.Errorf("%w: unsupported event type %q", ErrInvalidTrigger, t.EventType)
+	}
+	if err := ValidateDocumentPattern(t.DocumentPattern); err != nil {
+		return err
+	}
+	target, err := url.Parse(t.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidTrigger)
+	}
+	return nil
+}
+
+// ValidateDocumentPattern checks that a document pattern such as "users/{uid}" names documents
+func ValidateDocumentPattern(pattern string) error {
+	segments := splitSchemaPath(pattern)
+	if len(segments) == 0 {
+		return fmt.Errorf("%w: document pattern is required", ErrInvalidTrigger)
+	}
+	fixed := 0
+	for _, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("%w: %q contains an empty segment", ErrInvalidTrigger, pattern)
+		}
+		if !isRecursiveWildcard(segment) {
+			fixed++
+		}
+	}
+	// Without a recursive wildcard the pattern must alternate collection and document segments
+	if fixed == len(segments) && len(segments)%2 != 0 {
+		return fmt.Errorf("%w: %q must end with a document segment", ErrInvalidTrigger, pattern)
+	}
+	return nil
+}
+
+// Match reports whether the trigger fires on the change and returns the wildcard values
+// of its document pattern
+func (t *DocumentTrigger) Match(change *DocumentChange) (map[string]string, bool) {
+	if !t.EventType.Accepts(change.Kind()) {
+		return nil, false
+	}
+	return MatchDocumentPattern(t.DocumentPattern, change.DocumentPath)
+}
+
+// Accepts reports whether a change of the given kind fires the event type
+func (t DocumentTriggerEventType) Accepts(kind DocumentChangeKind) bool {
+	switch t {
+	case TriggerOnCreate:
+		return kind == DocumentChangeCreated
+	case TriggerOnUpdate:
+		return kind == DocumentChangeUpdated
+	case TriggerOnDelete:
+		return kind == DocumentChangeDeleted
+	case TriggerOnWritten:
+		return true
+	}
+	return false
+}
+
+// MatchDocumentPattern matches a documents-relative document path (e.g. "users/u1/orders/o1")
+// against a match pattern and returns the wildcard values by name. Recursive wildcards
+// capture the matched segments joined by "/".
+func MatchDocumentPattern(pattern, documentPath string) (map[string]string, bool) {
+	params := make(map[string]string)
+	if !capturePatternSegments(splitSchemaPath(pattern), splitSchemaPath(documentPath), params) {
+		return nil, false
+	}
+	return params, true
+}
+
+func capturePatternSegments(pattern, path []string, params map[string]string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	head := pattern[0]
+	if isRecursiveWildcard(head) {
+		for i := len(path); i >= 0; i-- {
+			if capturePatternSegments(pattern[1:], path[i:], params) {
+				if name := wildcardName(head); name != "" {
+					params[name] = strings.Join(path[:i], "/")
+				}
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if isSingleWildcard(head) {
+		if !capturePatternSegments(pattern[1:], path[1:], params) {
+			return false
+		}
+		if name := wildcardName(head); name != "" {
+			params[name] = path[0]
+		}
+		return true
+	}
+	return head == path[0] && capturePatternSegments(pattern[1:], path[1:], params)
+}
+
+// wildcardName returns the name of a "{name}" or "{name=**}" wildcard, or "" for "*" and "**"
+func wildcardName(segment string) string {
+	if !strings.HasPrefix(segment, "{") {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"), "=**")
+}
+
+// DocumentChangeKind classifies a document change
+type DocumentChangeKind string
+
+const (
+	DocumentChangeCreated DocumentChangeKind = "created"
+	DocumentChangeUpdated DocumentChangeKind = "updated"
+	DocumentChangeDeleted DocumentChangeKind = "deleted"
+)
+
+// DocumentChange is a committed write of one document with its state before and after
+// the write. Before is nil for a creation and After is nil for a deletion.
+type DocumentChange struct {
+	ProjectID    string    `json:"projectId" bson:"project_id"`
+	DatabaseID   string    `json:"databaseId" bson:"database_id"`
+	DocumentPath string    `json:"document" bson:"document_path"` // Relative document path, e.g. "users/u1"
+	Before       *Document `json:"before,omitempty" bson:"before,omitempty"`
+	After        *Document `json:"after,omitempty" bson:"after,omitempty"`
+	CommitTime   time.Time `json:"commitTime" bson:"commit_time"`
+}
+
+// Kind classifies the change
+func (c *DocumentChange) Kind() DocumentChangeKind {
+	switch {
+	case c.Before == nil:
+		return DocumentChangeCreated
+	case c.After == nil:
+		return DocumentChangeDeleted
+	default:
+		return DocumentChangeUpdated
+	}
+}
+
+// CloudEvent is the CloudEvents 1.0 envelope of a document trigger delivery, in the format
+// of Firestore events (google.cloud.firestore.document.v1.*) sent as structured JSON
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject"`
+	Time            time.Time         `json:"time"`
+	DataContentType string            `json:"datacontenttype"`
+	Project         string            `json:"project"`
+	Database        string            `json:"database"`
+	Document        string            `json:"document"`
+	Params          map[string]string `json:"params,omitempty"`
+	Data            DocumentEventData `json:"data"`
+}
+
+// DocumentEventData carries the document snapshots of a Firestore event
+type DocumentEventData struct {
+	OldValue   *EventDocument   `json:"oldValue,omitempty"`
+	Value      *EventDocument   `json:"value,omitempty"`
+	UpdateMask *EventUpdateMask `json:"updateMask,omitempty"`
+}
+
+// EventDocument is a document snapshot in the Firestore REST representation
+type EventDocument struct {
+	Name       string                 `json:"name"`
+	Fields     map[string]*FieldValue `json:"fields,omitempty"`
+	CreateTime time.Time              `json:"createTime"`
+	UpdateTime time.Time              `json:"updateTime"`
+}
+
+// EventUpdateMask lists the top-level fields changed by an update
+type EventUpdateMask struct {
+	FieldPaths []string `json:"fieldPaths"`
+}
+
+// NewDocumentCloudEvent builds the CloudEvent of a change delivered for an event type
+func NewDocumentCloudEvent(id string, eventType DocumentTriggerEventType, change *DocumentChange, params map[string]string) *CloudEvent {
+	databaseName := fmt.Sprintf("projects/%s/databases/%s", change.ProjectID, change.DatabaseID)
+	data := DocumentEventData{
+		OldValue: newEventDocument(databaseName, change.DocumentPath, change.Before),
+		Value:    newEventDocument(databaseName, change.DocumentPath, change.After),
+	}
+	if change.Kind() == DocumentChangeUpdated {
+		data.UpdateMask = &EventUpdateMask{FieldPaths: changedFieldPaths(change.Before.Fields, change.After.Fields)}
+	}
+	return &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              id,
+		Source:          "//firestore.googleapis.com/" + databaseName,
+		Type:            eventType.CloudEventType(),
+		Subject:         "documents/" + change.DocumentPath,
+		Time:            change.CommitTime,
+		DataContentType: "application/json",
+		Project:         change.ProjectID,
+		Database:        change.DatabaseID,
+		Document:        change.DocumentPath,
+		Params:          params,
+		Data:            data,
+	}
+}
+
+func newEventDocument(databaseName, documentPath string, doc *Document) *EventDocument {
+	if doc == nil {
+		return nil
+	}
+	return &EventDocument{
+		Name:       databaseName + "/documents/" + documentPath,
+		Fields:     doc.Fields,
+		CreateTime: doc.CreateTime,
+		UpdateTime: doc.UpdateTime,
+	}
+}
+
+// changedFieldPaths returns the sorted top-level fields added, removed or modified
+func changedFieldPaths(before, after map[string]*FieldValue) []string {
+	changed := make([]string, 0)
+	for name, value := range after {
+		if previous, exists := before[name]; !exists || !reflect.DeepEqual(previous, value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, exists := after[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// TriggerDeliveryState is the state of a webhook delivery in the delivery queue
+type TriggerDeliveryState string
+
+const (
+	TriggerDeliveryPending    TriggerDeliveryState = "PENDING"
+	TriggerDeliveryDelivered  TriggerDeliveryState = "DELIVERED"
+	TriggerDeliveryDeadLetter TriggerDeliveryState = "DEAD_LETTER"
+)
+
+// TriggerDelivery is one CloudEvent queued for delivery to a trigger webhook. Failed
+// attempts are retried at NextAttemptAt until the delivery is dead-lettered.
+type TriggerDelivery struct {
+	DeliveryID     string               `json:"deliveryId" bson:"delivery_id"`
+	TriggerID      string               `json:"triggerId" bson:"trigger_id"`
+	OrganizationID string               `json:"organizationId,omitempty" bson:"organization_id,omitempty"`
+	ProjectID      string               `json:"projectId" bson:"project_id"`
+	DatabaseID     string               `json:"databaseId" bson:"database_id"`
+	URL            string               `json:"url" bson:"url"`
+	Headers        map[string]string    `json:"-" bson:"headers,omitempty"`
+	Event          json.RawMessage      `json:"event" bson:"event"` // Encoded CloudEvent, the request body
+	State          TriggerDeliveryState `json:"state" bson:"state"`
+	Attempts       int                  `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time            `json:"nextAttemptAt" bson:"next_attempt_at"`
+	LastStatusCode int                  `json:"lastStatusCode,omitempty" bson:"last_status_code,omitempty"`
+	LastError      string               `json:"lastError,omitempty" bson:"last_error,omitempty"`
+	CreatedAt      time.Time            `json:"createdAt" bson:"created_at"`
+	UpdatedAt      time.Time            `json:"updatedAt" bson:"updated_at"`
+	DeliveredAt    *time.Time           `json:"deliveredAt,omitempty" bson:"delivered_at,omitempty"`
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchDocumentPattern(t *testing.T) {
+	params, ok := MatchDocumentPattern("users/{uid}/orders/{oid}", "users/u1/orders/o1")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"uid": "u1", "oid": "o1"}, params)
+
+	params, ok = MatchDocumentPattern("users/{uid}/{rest=**}", "users/u1/orders/o1/items/i1")
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"uid": "u1", "rest": "orders/o1/items/i1"}, params)
+
+	params, ok = MatchDocumentPattern("**/items/*", "users/u1/orders/o1/items/i1")
+	require.True(t, ok)
+	assert.Empty(t, params)
+
+	_, ok = MatchDocumentPattern("users/{uid}", "users/u1/orders/o1")
+	assert.False(t, ok)
+	_, ok = MatchDocumentPattern("users/{uid}/orders/{oid}", "accounts/u1/orders/o1")
+	assert.False(t, ok)
+}
+
+func TestDocumentTrigger_Validate(t *testing.T) {
+	valid := DocumentTrigger{EventType: TriggerOnCreate, DocumentPattern: "users/{uid}", URL: "https://hooks.example.com/users"}
+	require.NoError(t, valid.Validate())
+
+	for _, mutate := range []func(tr *DocumentTrigger){
+		func(tr *DocumentTrigger) { tr.EventType = "onRead" },
+		func(tr *DocumentTrigger) { tr.DocumentPattern = "users" },
+		func(tr *DocumentTrigger) { tr.DocumentPattern = "" },
+		func(tr *DocumentTrigger) { tr.URL = "ftp://hooks.example.com" },
+		func(tr *DocumentTrigger) { tr.URL = "/relative" },
+	} {
+		tr := valid
+		mutate(&tr)
+		assert.True(t, errors.Is(tr.Validate(), ErrInvalidTrigger))
+	}
+}
+
+func TestDocumentTrigger_MatchesEventTypes(t *testing.T) {
+	doc := &Document{Fields: map[string]*FieldValue{"name": NewFieldValue("Ada")}}
+	created := &DocumentChange{DocumentPath: "users/u1", After: doc}
+	deleted := &DocumentChange{DocumentPath: "users/u1", Before: doc}
+
+	onCreate := &DocumentTrigger{EventType: TriggerOnCreate, DocumentPattern: "users/{uid}"}
+	_, ok := onCreate.Match(created)
+	assert.True(t, ok)
+	_, ok = onCreate.Match(deleted)
+	assert.False(t, ok)
+
+	onWritten := &DocumentTrigger{EventType: TriggerOnWritten, DocumentPattern: "users/{uid}"}
+	_, ok = onWritten.Match(deleted)
+	assert.True(t, ok)
+}
+
+func TestNewDocumentCloudEvent(t *testing.T) {
+	commit := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	change := &DocumentChange{
+		ProjectID:    "p",
+		DatabaseID:   "d",
+		DocumentPath: "users/u1",
+		Before:       &Document{Fields: map[string]*FieldValue{"name": NewFieldValue("Ada"), "age": NewFieldValue(int64(36)), "old": NewFieldValue(true)}},
+		After:        &Document{Fields: map[string]*FieldValue{"name": NewFieldValue("Ada"), "age": NewFieldValue(int64(37)), "new": NewFieldValue(true)}},
+		CommitTime:   commit,
+	}
+
+	event := NewDocumentCloudEvent("evt-1", TriggerOnUpdate, change, map[string]string{"uid": "u1"})
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.Equal(t, "google.cloud.firestore.document.v1.updated", event.Type)
+	assert.Equal(t, "//firestore.googleapis.com/projects/p/databases/d", event.Source)
+	assert.Equal(t, "documents/users/u1", event.Subject)
+	assert.Equal(t, commit, event.Time)
+	assert.Equal(t, "projects/p/databases/d/documents/users/u1", event.Data.Value.Name)
+	assert.Equal(t, "Ada", event.Data.OldValue.Fields["name"].Value)
+	assert.Equal(t, []string{"age", "new", "old"}, event.Data.UpdateMask.FieldPaths)
+
+	change.After = nil
+	event = NewDocumentCloudEvent("evt-2", TriggerOnWritten, change, nil)
+	assert.Equal(t, "google.cloud.firestore.document.v1.written", event.Type)
+	assert.Nil(t, event.Data.Value)
+	assert.Nil(t, event.Data.UpdateMask)
+}
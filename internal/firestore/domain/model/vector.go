@@ -0,0 +1,335 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxVectorDimension is the largest supported vector dimension
+	MaxVectorDimension = 2048
+	// MaxFindNearestLimit bounds the number of neighbors returned by a single query
+	MaxFindNearestLimit = 1000
+)
+
+// Vector query errors
+var (
+	ErrInvalidVector              = errors.New("invalid vector")
+	ErrInvalidFindNearest         = errors.New("invalid findNearest query")
+	ErrVectorDimensionMismatch    = errors.New("vector dimension mismatch")
+	ErrUnsupportedDistanceMeasure = errors.New("unsupported distance measure")
+)
+
+// VectorValue is a dense vector of doubles, stored as FieldTypeVector
+type VectorValue struct {
+	Values []float64 `json:"values" bson:"values"`
+}
+
+// Validate checks that a vector has a supported dimension and finite components
+func (v *VectorValue) Validate() error {
+	if v == nil || len(v.Values) == 0 {
+		return fmt.Errorf("%w: a vector needs at least one dimension", ErrInvalidVector)
+	}
+	if len(v.Values) > MaxVectorDimension {
+		return fmt.Errorf("%w: dimension %d exceeds %d", ErrInvalidVector, len(v.Values), MaxVectorDimension)
+	}
+	for i, value := range v.Values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: component %d is not finite", ErrInvalidVector, i)
+		}
+	}
+	return nil
+}
+
+// DistanceMeasure is the similarity function of a findNearest query
+type DistanceMeasure string
+
+const (
+	DistanceMeasureEuclidean  DistanceMeasure = "EUCLIDEAN"
+	DistanceMeasureCosine     DistanceMeasure = "COSINE"
+	DistanceMeasureDotProduct DistanceMeasure = "DOT_PRODUCT"
+)
+
+// IsValid reports whether the measure is supported
+func (m DistanceMeasure) IsValid() bool {
+	switch m {
+	case DistanceMeasureEuclidean, DistanceMeasureCosine, DistanceMeasureDotProduct:
+		return true
+	}
+	return false
+}
+
+// Ranks reports whether distance a ranks before distance b. Dot products rank
+// the largest first, the other measures the smallest first.
+func (m DistanceMeasure) Ranks(a, b float64) bool {
+	if m == DistanceMeasureDotProduct {
+		return a > b
+	}
+	return a < b
+}
+
+// Within reports whether a distance satisfies a distance threshold
+func (m DistanceMeasure) Within(distance, threshold float64) bool {
+	if m == DistanceMeasureDotProduct {
+		return distance >= threshold
+	}
+	return distance <= threshold
+}
+
+// VectorDistance computes the distance between two vectors of the same dimension.
+// COSINE returns 1 - cosine similarity; vectors of zero magnitude have no cosine distance.
+func VectorDistance(measure DistanceMeasure, a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("%w: %d != %d", ErrVectorDimensionMismatch, len(a), len(b))
+	}
+	switch measure {
+	case DistanceMeasureEuclidean:
+		sum := 0.0
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return math.Sqrt(sum), nil
+	case DistanceMeasureDotProduct:
+		dot := 0.0
+		for i := range a {
+			dot += a[i] * b[i]
+		}
+		return dot, nil
+	case DistanceMeasureCosine:
+		dot, normA, normB := 0.0, 0.0, 0.0
+		for i := range a {
+			dot += a[i] * b[i]
+			normA += a[i] * a[i]
+			normB += b[i] * b[i]
+		}
+		if normA == 0 || normB == 0 {
+			return 0, fmt.Errorf("%w: cosine distance of a zero vector", ErrInvalidVector)
+		}
+		return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB)), nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnsupportedDistanceMeasure, measure)
+}
+
+// FindNearest is a vector similarity query: the Limit documents whose VectorField
+// is closest to QueryVector, after the query filters have been applied.
+type FindNearest struct {
+	VectorField         string          `json:"vectorField" bson:"vector_field"`
+	QueryVector         []float64       `json:"queryVector" bson:"query_vector"`
+	DistanceMeasure     DistanceMeasure `json:"distanceMeasure" bson:"distance_measure"`
+	Limit               int             `json:"limit" bson:"limit"`
+	DistanceResultField string          `json:"distanceResultField,omitempty" bson:"distance_result_field,omitempty"` // Receives the computed distance
+	DistanceThreshold   *float64        `json:"distanceThreshold,omitempty" bson:"distance_threshold,omitempty"`
+
+	// CandidateIDs restricts the search to documents preselected by an approximate
+	// index. It is set internally and never read from requests.
+	CandidateIDs []string `json:"-" bson:"-"`
+}
+
+// Validate checks the findNearest constraints
+func (f *FindNearest) Validate() error {
+	if f.VectorField == "" {
+		return fmt.Errorf("%w: vectorField is required", ErrInvalidFindNearest)
+	}
+	if err := (&VectorValue{Values: f.QueryVector}).Validate(); err != nil {
+		return fmt.Errorf("%w: queryVector: %v", ErrInvalidFindNearest, err)
+	}
+	if !f.DistanceMeasure.IsValid() {
+		return fmt.Errorf("%w: unsupported distance measure %q", ErrInvalidFindNearest, f.DistanceMeasure)
+	}
+	if f.Limit <= 0 || f.Limit > MaxFindNearestLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFindNearest, MaxFindNearestLimit)
+	}
+	if f.DistanceThreshold != nil && (math.IsNaN(*f.DistanceThreshold) || math.IsInf(*f.DistanceThreshold, 0)) {
+		return fmt.Errorf("%w: distanceThreshold must be finite", ErrInvalidFindNearest)
+	}
+	return nil
+}
+
+// Rank returns the Limit nearest documents, nearest first. Documents whose field is not
+// a vector of the query dimension are skipped. When DistanceResultField is set, the
+// returned documents are copies carrying the distance in that field.
+func (f *FindNearest) Rank(docs []*Document) []*Document {
+	type scored struct {
+		doc      *Document
+		distance float64
+	}
+	matched := make([]scored, 0, len(docs))
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		vector, ok := VectorFromValue(lookupFieldValue(doc.Fields, f.VectorField))
+		if !ok {
+			continue
+		}
+		distance, err := VectorDistance(f.DistanceMeasure, f.QueryVector, vector.Values)
+		if err != nil {
+			continue
+		}
+		if f.DistanceThreshold != nil && !f.DistanceMeasure.Within(distance, *f.DistanceThreshold) {
+			continue
+		}
+		matched = append(matched, scored{doc, distance})
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].distance != matched[j].distance {
+			return f.DistanceMeasure.Ranks(matched[i].distance, matched[j].distance)
+		}
+		return matched[i].doc.DocumentID < matched[j].doc.DocumentID
+	})
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+	}
+
+	result := make([]*Document, len(matched))
+	for i, m := range matched {
+		result[i] = m.doc
+		if f.DistanceResultField != "" {
+			result[i] = withDistanceField(m.doc, f.DistanceResultField, m.distance)
+		}
+	}
+	return result
+}
+
+// withDistanceField copies a document and sets a top-level field to the distance
+func withDistanceField(doc *Document, field string, distance float64) *Document {
+	copied := *doc
+	copied.Fields = make(map[string]*FieldValue, len(doc.Fields)+1)
+	for k, v := range doc.Fields {
+		copied.Fields[k] = v
+	}
+	copied.Fields[field] = &FieldValue{ValueType: FieldTypeDouble, Value: distance}
+	return &copied
+}
+
+// VectorFromValue extracts a vector from the representations it takes across layers:
+// *VectorValue, VectorValue, FieldValue, number lists, {"values": [...]} maps (plain or
+// BSON), typed {"vectorValue": {...}} maps and the Firestore vector map, either plain
+// {"__type__": "__vector__", "value": [...]} or REST encoded as a typed mapValue.
+func VectorFromValue(value interface{}) (*VectorValue, bool) {
+	switch v := value.(type) {
+	case *VectorValue:
+		return v, v != nil
+	case VectorValue:
+		return &v, true
+	case *FieldValue:
+		if v == nil {
+			return nil, false
+		}
+		switch v.ValueType {
+		case FieldTypeVector:
+			return VectorFromValue(v.Value)
+		case FieldTypeMap:
+			if m, ok := v.Value.(*MapValue); ok && m != nil {
+				return vectorFromTypedMap(m.Fields)
+			}
+		}
+		return nil, false
+	case primitive.M:
+		return VectorFromValue(map[string]interface{}(v))
+	case primitive.D:
+		return VectorFromValue(map[string]interface{}(v.Map()))
+	case []float64, []interface{}, primitive.A:
+		return vectorFromComponents(v)
+	case map[string]interface{}:
+		if typed, exists := v["vectorValue"]; exists && len(v) == 1 {
+			return VectorFromValue(typed)
+		}
+		if typed, exists := v["mapValue"]; exists && len(v) == 1 {
+			return vectorFromFirestoreMapValue(typed)
+		}
+		if v["__type__"] == "__vector__" {
+			return vectorFromComponents(v["value"])
+		}
+		return vectorFromComponents(v["values"])
+	}
+	return nil, false
+}
+
+// vectorFromFirestoreMapValue reads the Firestore REST encoding of a vector:
+// {"fields": {"__type__": {"stringValue": "__vector__"}, "value": {"arrayValue": {...}}}}
+func vectorFromFirestoreMapValue(mapValue interface{}) (*VectorValue, bool) {
+	m, ok := mapValue.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	fields, ok := m["fields"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	marker, ok := fields["__type__"].(map[string]interface{})
+	if !ok || marker["stringValue"] != "__vector__" {
+		return nil, false
+	}
+	value, ok := fields["value"].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return vectorFromComponents(value["arrayValue"])
+}
+
+// vectorFromTypedMap reads the Firestore vector map stored as typed map fields
+func vectorFromTypedMap(fields map[string]*FieldValue) (*VectorValue, bool) {
+	marker, ok := fields["__type__"]
+	if !ok || marker == nil || marker.Value != "__vector__" {
+		return nil, false
+	}
+	components, ok := fields["value"]
+	if !ok || components == nil {
+		return nil, false
+	}
+	array, ok := components.Value.(*ArrayValue)
+	if !ok || array == nil {
+		return nil, false
+	}
+	values := make([]interface{}, len(array.Values))
+	for i, item := range array.Values {
+		if item == nil {
+			return nil, false
+		}
+		values[i] = item.Value
+	}
+	return vectorFromComponents(values)
+}
+
+// vectorFromComponents converts a list of numbers into a vector
+func vectorFromComponents(components interface{}) (*VectorValue, bool) {
+	var items []interface{}
+	switch c := components.(type) {
+	case []float64:
+		return &VectorValue{Values: c}, len(c) > 0
+	case []interface{}:
+		items = c
+	case primitive.A:
+		items = c
+	case map[string]interface{}:
+		// Firestore arrayValue {"values": [{"doubleValue": ...}]}
+		return vectorFromComponents(c["values"])
+	default:
+		return nil, false
+	}
+	if len(items) == 0 {
+		return nil, false
+	}
+	values := make([]float64, len(items))
+	for i, item := range items {
+		if typed, ok := item.(map[string]interface{}); ok {
+			if d, exists := typed["doubleValue"]; exists {
+				item = d
+			} else if n, exists := typed["integerValue"]; exists {
+				item = n
+			}
+		}
+		f, ok := toFloat64(item)
+		if !ok {
+			return nil, false
+		}
+		values[i] = f
+	}
+	return &VectorValue{Values: values}, true
+}
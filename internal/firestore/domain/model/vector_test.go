@@ -0,0 +1,113 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func vectorDoc(id string, values ...float64) *Document {
+	return &Document{DocumentID: id, Fields: map[string]*FieldValue{
+		"embedding": NewFieldValue(&VectorValue{Values: values}),
+	}}
+}
+
+func TestVectorDistance(t *testing.T) {
+	d, err := VectorDistance(DistanceMeasureEuclidean, []float64{0, 0}, []float64{3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, d)
+
+	d, err = VectorDistance(DistanceMeasureCosine, []float64{1, 0}, []float64{0, 2})
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, d, 1e-12)
+
+	d, err = VectorDistance(DistanceMeasureDotProduct, []float64{1, 2}, []float64{3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, 11.0, d)
+
+	_, err = VectorDistance(DistanceMeasureEuclidean, []float64{1}, []float64{1, 2})
+	assert.True(t, errors.Is(err, ErrVectorDimensionMismatch))
+	_, err = VectorDistance(DistanceMeasureCosine, []float64{0, 0}, []float64{1, 2})
+	assert.True(t, errors.Is(err, ErrInvalidVector))
+}
+
+func TestFindNearest_Rank(t *testing.T) {
+	docs := []*Document{
+		vectorDoc("far", 10, 10),
+		vectorDoc("near", 1, 0),
+		vectorDoc("mid", 2, 2),
+		vectorDoc("other-dimension", 1, 0, 0),
+		{DocumentID: "none", Fields: map[string]*FieldValue{"embedding": NewFieldValue("text")}},
+	}
+
+	nearest := &FindNearest{VectorField: "embedding", QueryVector: []float64{0, 0}, DistanceMeasure: DistanceMeasureEuclidean, Limit: 2, DistanceResultField: "distance"}
+	ranked := nearest.Rank(docs)
+	require.Len(t, ranked, 2)
+	assert.Equal(t, "near", ranked[0].DocumentID)
+	assert.Equal(t, "mid", ranked[1].DocumentID)
+	assert.Equal(t, 1.0, ranked[0].Fields["distance"].Value)
+	assert.NotContains(t, docs[1].Fields, "distance", "ranking must not modify the input documents")
+
+	// Dot products rank the largest first and thresholds keep the closest side
+	threshold := 15.0
+	nearest = &FindNearest{VectorField: "embedding", QueryVector: []float64{1, 1}, DistanceMeasure: DistanceMeasureDotProduct, Limit: 5, DistanceThreshold: &threshold}
+	ranked = nearest.Rank(docs)
+	require.Len(t, ranked, 1)
+	assert.Equal(t, "far", ranked[0].DocumentID)
+}
+
+func TestFindNearest_Validate(t *testing.T) {
+	valid := FindNearest{VectorField: "embedding", QueryVector: []float64{1, 2}, DistanceMeasure: DistanceMeasureCosine, Limit: 10}
+	require.NoError(t, valid.Validate())
+
+	for _, mutate := range []func(f *FindNearest){
+		func(f *FindNearest) { f.VectorField = "" },
+		func(f *FindNearest) { f.QueryVector = nil },
+		func(f *FindNearest) { f.DistanceMeasure = "MANHATTAN" },
+		func(f *FindNearest) { f.Limit = 0 },
+		func(f *FindNearest) { f.Limit = MaxFindNearestLimit + 1 },
+	} {
+		f := valid
+		mutate(&f)
+		assert.True(t, errors.Is(f.Validate(), ErrInvalidFindNearest))
+	}
+
+	query := &Query{Path: "docs", FindNearest: &valid, Orders: []Order{{Field: "name", Direction: DirectionAscending}}}
+	assert.True(t, errors.Is(query.ValidateQuery(), ErrInvalidFindNearest))
+}
+
+func TestVectorValue_JSONRoundTrip(t *testing.T) {
+	original := NewFieldValue(&VectorValue{Values: []float64{0.5, -1}})
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"vectorValue":{"values":[0.5,-1]}}`, string(data))
+
+	var decoded FieldValue
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, FieldTypeVector, decoded.ValueType)
+	assert.Equal(t, &VectorValue{Values: []float64{0.5, -1}}, decoded.Value)
+
+	// Plain JSON request bodies use either the typed or the Firestore vector map
+	fromTyped := NewFieldValue(map[string]interface{}{"vectorValue": map[string]interface{}{"values": []interface{}{1.0, 2.0}}})
+	assert.Equal(t, FieldTypeVector, fromTyped.ValueType)
+	fromFirestore := NewFieldValue(map[string]interface{}{"__type__": "__vector__", "value": []interface{}{1.0, 2.0}})
+	assert.Equal(t, &VectorValue{Values: []float64{1, 2}}, fromFirestore.Value)
+}
+
+func TestVectorFromValue_FirestoreMapValue(t *testing.T) {
+	vector, ok := VectorFromValue(map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+		"__type__": map[string]interface{}{"stringValue": "__vector__"},
+		"value": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+			map[string]interface{}{"doubleValue": 0.25},
+			map[string]interface{}{"integerValue": "3"},
+		}}},
+	}}})
+	require.True(t, ok)
+	assert.Equal(t, []float64{0.25, 3}, vector.Values)
+
+	_, ok = VectorFromValue(map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{}}})
+	assert.False(t, ok)
+}
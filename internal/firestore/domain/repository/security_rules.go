@@ -46,6 +46,15 @@ type SecurityRule struct {
 
 	// Optional metadata
 	Description string `json:"description,omitempty"`
+
+	// Positions of the match and of the allow and deny conditions in the .rules source,
+	// when the rule was translated from one
+	Line         int                   `json:"line,omitempty"`
+	Column       int                   `json:"column,omitempty"`
+	AllowLines   map[OperationType]int `json:"allowLines,omitempty"`
+	AllowColumns map[OperationType]int `json:"allowColumns,omitempty"`
+	DenyLines    map[OperationType]int `json:"denyLines,omitempty"`
+	DenyColumns  map[OperationType]int `json:"denyColumns,omitempty"`
 }
 
 // RuleEvaluationResult represents the result of rule evaluation
@@ -0,0 +1,139 @@
+package repository
+
+// ConditionOutcome is the result of an evaluated allow or deny condition
+type ConditionOutcome string
+
+const (
+	ConditionTrue  ConditionOutcome = "true"
+	ConditionFalse ConditionOutcome = "false"
+	ConditionError ConditionOutcome = "error"
+)
+
+// RulesCoverageRecorder records which rules of a database the evaluated requests exercise
+type RulesCoverageRecorder interface {
+	// RecordRule records a rule whose match pattern matched a request
+	RecordRule(projectID, databaseID, match string)
+	// RecordCondition records the outcome of an allow or deny condition of a rule
+	RecordCondition(projectID, databaseID, match, effect string, operation OperationType, outcome ConditionOutcome)
+}
+
+// RulesCoverageStore keeps the coverage of the rules of each database
+type RulesCoverageStore interface {
+	RulesCoverageRecorder
+	// RecordSimulation records the rules and conditions traced by a simulation
+	RecordSimulation(projectID, databaseID string, result *SimulationResult)
+	// Coverage returns a copy of the coverage recorded for a database
+	Coverage(projectID, databaseID string) *RulesCoverage
+	// Reset forgets the coverage recorded for a database
+	Reset(projectID, databaseID string)
+}
+
+// RulesCoverageInstrumented is implemented by rules engines which can record the
+// coverage of the requests they evaluate
+type RulesCoverageInstrumented interface {
+	SetCoverageRecorder(recorder RulesCoverageRecorder)
+}
+
+// RulesCoverage counts how often the rules of a database were matched and how their
+// conditions evaluated
+type RulesCoverage struct {
+	ProjectID  string `json:"projectId,omitempty"`
+	DatabaseID string `json:"databaseId,omitempty"`
+	// Rules are the matched rules by match pattern
+	Rules map[string]*RuleCoverage `json:"rules"`
+}
+
+// RuleCoverage is the coverage of a rule
+type RuleCoverage struct {
+	Hits int64 `json:"hits"`
+	// Conditions are the evaluated conditions by ConditionKey
+	Conditions map[string]*ConditionCoverage `json:"conditions"`
+}
+
+// ConditionCoverage counts the outcomes of an allow or deny condition
+type ConditionCoverage struct {
+	True  int64 `json:"true"`
+	False int64 `json:"false"`
+	Error int64 `json:"error"`
+}
+
+// NewRulesCoverage creates an empty coverage of the rules of a database
+func NewRulesCoverage(projectID, databaseID string) *RulesCoverage {
+	return &RulesCoverage{ProjectID: projectID, DatabaseID: databaseID, Rules: make(map[string]*RuleCoverage)}
+}
+
+// ConditionKey identifies a condition of a rule, such as "allow read"
+func ConditionKey(effect string, operation OperationType) string {
+	return effect + " " + string(operation)
+}
+
+// Rule returns the coverage of a rule, adding it when the rule was never matched
+func (c *RulesCoverage) Rule(match string) *RuleCoverage {
+	rule, ok := c.Rules[match]
+	if !ok {
+		rule = &RuleCoverage{Conditions: make(map[string]*ConditionCoverage)}
+		c.Rules[match] = rule
+	}
+	return rule
+}
+
+// Condition returns the coverage of a condition of a rule, adding it when the condition
+// was never evaluated
+func (r *RuleCoverage) Condition(effect string, operation OperationType) *ConditionCoverage {
+	key := ConditionKey(effect, operation)
+	condition, ok := r.Conditions[key]
+	if !ok {
+		condition = &ConditionCoverage{}
+		r.Conditions[key] = condition
+	}
+	return condition
+}
+
+// Record counts an outcome of the condition
+func (c *ConditionCoverage) Record(outcome ConditionOutcome) {
+	switch outcome {
+	case ConditionTrue:
+		c.True++
+	case ConditionFalse:
+		c.False++
+	default:
+		c.Error++
+	}
+}
+
+// Hits is the number of evaluations of the condition
+func (c *ConditionCoverage) Hits() int64 {
+	return c.True + c.False + c.Error
+}
+
+// AddSimulation counts the rules and conditions traced by a simulation
+func (c *RulesCoverage) AddSimulation(result *SimulationResult) {
+	for _, match := range result.Matches {
+		rule := c.Rule(match.Match)
+		rule.Hits++
+		for _, condition := range match.Conditions {
+			outcome := ConditionFalse
+			switch {
+			case condition.Error != "":
+				outcome = ConditionError
+			case condition.Result:
+				outcome = ConditionTrue
+			}
+			rule.Condition(condition.Effect, condition.Operation).Record(outcome)
+		}
+	}
+}
+
+// Clone returns a deep copy of the coverage
+func (c *RulesCoverage) Clone() *RulesCoverage {
+	clone := NewRulesCoverage(c.ProjectID, c.DatabaseID)
+	for match, rule := range c.Rules {
+		ruleClone := clone.Rule(match)
+		ruleClone.Hits = rule.Hits
+		for key, condition := range rule.Conditions {
+			conditionClone := *condition
+			ruleClone.Conditions[key] = &conditionClone
+		}
+	}
+	return clone
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"firestore-clone/internal/firestore/domain/model"
+)
+
+// QueryRulesEvaluator checks collection queries against the list rules before they
+// run. Rules are not filters: a query is allowed only when its constraints guarantee
+// that every document it can return satisfies a list condition, without reading them.
+type QueryRulesEvaluator interface {
+	// EvaluateQueryAccess checks a query of the collection at securityContext.Path,
+	// relative to the database
+	EvaluateQueryAccess(ctx context.Context, securityContext *SecurityContext, query *model.Query) (*RuleEvaluationResult, error)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import "context"
+
+// RulesSimulator evaluates security rules for a simulated request and explains the
+// decision, for debugging denied requests and trying rules before deploying them
+type RulesSimulator interface {
+	SimulateAccess(ctx context.Context, request *SimulationRequest) (*SimulationResult, error)
+}
+
+// SimulationRequest is a request evaluated by the rules simulator
+type SimulationRequest struct {
+	ProjectID  string        `json:"projectId"`
+	DatabaseID string        `json:"databaseId"`
+	Operation  OperationType `json:"operation"`
+	// Path of the document relative to the database, such as users/alice
+	Path string `json:"path"`
+	// Auth is request.auth: the uid and the token claims; nil for unauthenticated requests
+	Auth map[string]interface{} `json:"auth,omitempty"`
+	// RequestData is request.resource.data, the document as it would be after a write
+	RequestData map[string]interface{} `json:"requestData,omitempty"`
+	// Resource mocks the data of the existing document
+	Resource map[string]interface{} `json:"resource,omitempty"`
+	// UseExistingDocument reads the stored document as resource when Resource is not set
+	UseExistingDocument bool `json:"useExistingDocument,omitempty"`
+	// Documents mocks the documents read by get() and exists(), by path relative to the
+	// database; a null document does not exist. Other paths are read from the database.
+	Documents map[string]map[string]interface{} `json:"documents,omitempty"`
+	// Rules are candidate rules to evaluate instead of the deployed ones
+	Rules []*SecurityRule `json:"rules,omitempty"`
+}
+
+// SimulationResult is the decision of the rules with the trace of the evaluation
+type SimulationResult struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// DecidedBy is the match of the rule whose condition allowed or denied the request
+	DecidedBy string `json:"decidedBy,omitempty"`
+	// Matches are the rules whose match pattern matched the path, in evaluation order
+	Matches []*MatchTrace `json:"matches"`
+	// Lookups are the get() and exists() calls made by the evaluated conditions
+	Lookups []*DocumentLookupTrace `json:"lookups"`
+}
+
+// MatchTrace is a rule whose match pattern matched the simulated path
+type MatchTrace struct {
+	Match      string            `json:"match"`
+	Variables  map[string]string `json:"variables"`
+	Conditions []*ConditionTrace `json:"conditions"`
+}
+
+// ConditionTrace is an allow or deny condition evaluated for the simulated operation
+type ConditionTrace struct {
+	Effect    string        `json:"effect"` // "allow" or "deny"
+	Operation OperationType `json:"operation"`
+	Condition string        `json:"condition"`
+	Result    bool          `json:"result"`
+	Error     string        `json:"error,omitempty"`
+	// Expressions are the values of the sub-expressions that were evaluated, outermost first
+	Expressions []*ExpressionTrace `json:"expressions,omitempty"`
+}
+
+// ExpressionTrace is the value of a sub-expression of a condition
+type ExpressionTrace struct {
+	Expression string      `json:"expression"`
+	Value      interface{} `json:"value,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// Redacted is set when the value was removed because the simulation read stored
+	// documents and the value may carry their data
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// DocumentLookupTrace is a get() or exists() call made while evaluating a condition
+type DocumentLookupTrace struct {
+	Function string `json:"function"`
+	Path     string `json:"path"`
+	Found    bool   `json:"found"`
+	Mocked   bool   `json:"mocked,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
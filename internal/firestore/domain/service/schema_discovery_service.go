@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+)
+
+const (
+	// DefaultSchemaExamples is the number of distinct example values kept per field path
+	DefaultSchemaExamples = 3
+	// cardinalitySketchSize is the number of hashes kept by the distinct value estimator.
+	// Cardinalities below it are exact.
+	cardinalitySketchSize = 1024
+)
+
+// SchemaDiscoveryService profiles documents to describe the shape of a collection
+type SchemaDiscoveryService interface {
+	// NewCollector creates a collector keeping up to maxExamples example values per field path
+	NewCollector(maxExamples int) SchemaCollector
+	// ExportJSONSchema builds a starting schema, usable as a collection schema, from a report
+	ExportJSONSchema(report *model.CollectionSchemaReport) *model.FieldSchema
+}
+
+// SchemaCollector accumulates field statistics document by document
+type SchemaCollector interface {
+	AddDocument(doc *model.Document)
+	DocumentCount() int64
+	// FieldStats returns the statistics of every observed field path ordered by path
+	FieldStats() []*model.FieldPathStats
+}
+
+// schemaDiscoveryService implements SchemaDiscoveryService
+type schemaDiscoveryService struct{}
+
+// NewSchemaDiscoveryService creates a new schema discovery service
+func NewSchemaDiscoveryService() SchemaDiscoveryService {
+	return &schemaDiscoveryService{}
+}
+
+// NewCollector implements SchemaDiscoveryService
+func (s *schemaDiscoveryService) NewCollector(maxExamples int) SchemaCollector {
+	if maxExamples < 0 {
+		maxExamples = DefaultSchemaExamples
+	}
+	return &schemaCollector{
+		maxExamples: maxExamples,
+		fields:      make(map[string]*fieldCollector),
+	}
+}
+
+// schemaCollector implements SchemaCollector
+type schemaCollector struct {
+	maxExamples int
+	documents   int64
+	fields      map[string]*fieldCollector
+}
+
+// fieldCollector accumulates the values of one field path
+type fieldCollector struct {
+	presence    int64
+	lastDoc     int64 // Sequence of the last document counted in presence
+	types       map[model.SchemaType]int64
+	examples    []interface{}
+	exampleKeys map[string]bool
+	distinct    *distinctSketch
+}
+
+// AddDocument implements SchemaCollector
+func (c *schemaCollector) AddDocument(doc *model.Document) {
+	if doc == nil {
+		return
+	}
+	c.documents++
+	for name, value := range doc.Fields {
+		c.addValue(name, value)
+	}
+}
+
+// DocumentCount implements SchemaCollector
+func (c *schemaCollector) DocumentCount() int64 {
+	return c.documents
+}
+
+func (c *schemaCollector) addValue(path string, value *model.FieldValue) {
+	field := c.field(path)
+	if field.lastDoc != c.documents {
+		field.presence++
+		field.lastDoc = c.documents
+	}
+
+	valueType := schemaTypeOf(value)
+	field.types[valueType]++
+
+	raw := plainValue(value)
+	switch valueType {
+	case model.SchemaTypeObject:
+		for name, nested := range mapFields(raw) {
+			c.addValue(joinFieldPath(path, name), nested)
+		}
+	case model.SchemaTypeArray:
+		for _, item := range arrayElements(raw) {
+			c.addValue(path+"[]", item)
+		}
+	default:
+		key := fmt.Sprintf("%s:%v", valueType, raw)
+		field.distinct.add(key)
+		if len(field.examples) < c.maxExamples && !field.exampleKeys[key] {
+			field.exampleKeys[key] = true
+			field.examples = append(field.examples, raw)
+		}
+	}
+}
+
+func (c *schemaCollector) field(path string) *fieldCollector {
+	field, exists := c.fields[path]
+	if !exists {
+		field = &fieldCollector{
+			types:       make(map[model.SchemaType]int64),
+			exampleKeys: make(map[string]bool),
+			distinct:    newDistinctSketch(cardinalitySketchSize),
+		}
+		c.fields[path] = field
+	}
+	return field
+}
+
+// FieldStats implements SchemaCollector
+func (c *schemaCollector) FieldStats() []*model.FieldPathStats {
+	paths := make([]string, 0, len(c.fields))
+	for path := range c.fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	stats := make([]*model.FieldPathStats, 0, len(paths))
+	for _, path := range paths {
+		field := c.fields[path]
+		types := make(map[model.SchemaType]int64, len(field.types))
+		for t, count := range field.types {
+			types[t] = count
+		}
+		cardinality, exact := field.distinct.estimate()
+		stat := &model.FieldPathStats{
+			FieldPath:        path,
+			Presence:         field.presence,
+			Types:            types,
+			Examples:         append([]interface{}(nil), field.examples...),
+			Cardinality:      cardinality,
+			CardinalityExact: exact,
+		}
+		if c.documents > 0 {
+			stat.PresenceRatio = float64(field.presence) / float64(c.documents)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// ExportJSONSchema implements SchemaDiscoveryService.
+// Top-level and nested map fields present whenever their parent is are marked required.
+func (s *schemaDiscoveryService) ExportJSONSchema(report *model.CollectionSchemaReport) *model.FieldSchema {
+	root := &model.FieldSchema{
+		Type:       model.SchemaTypes{model.SchemaTypeObject},
+		Properties: make(map[string]*model.FieldSchema),
+	}
+	if report == nil {
+		return root
+	}
+
+	byPath := make(map[string]*model.FieldPathStats, len(report.Fields))
+	for _, stat := range report.Fields {
+		byPath[stat.FieldPath] = stat
+	}
+
+	// Parents sort before their children, so every parent schema exists when a child is added
+	for _, stat := range report.Fields {
+		tokens := splitReportPath(stat.FieldPath)
+		parent, current := (*model.FieldSchema)(nil), root
+		for _, token := range tokens {
+			parent = current
+			if token == "[]" {
+				if current.Items == nil {
+					current.Items = &model.FieldSchema{}
+				}
+				current = current.Items
+				continue
+			}
+			if current.Properties == nil {
+				current.Properties = make(map[string]*model.FieldSchema)
+			}
+			child, exists := current.Properties[token]
+			if !exists {
+				child = &model.FieldSchema{}
+				current.Properties[token] = child
+			}
+			current = child
+		}
+		current.Type = exportedTypes(stat.Types)
+
+		last := tokens[len(tokens)-1]
+		if last == "[]" || strings.Contains(stat.FieldPath, "[]") {
+			// Presence counts documents, not array elements, so it says nothing about requiredness
+			continue
+		}
+		parentCount := report.DocumentsScanned
+		if parentPath := strings.TrimSuffix(stat.FieldPath, "."+last); parentPath != stat.FieldPath {
+			if parentStat, ok := byPath[parentPath]; ok {
+				parentCount = parentStat.Types[model.SchemaTypeObject]
+			}
+		}
+		if parentCount > 0 && stat.Presence >= parentCount {
+			parent.Required = append(parent.Required, last)
+		}
+	}
+	return root
+}
+
+// splitReportPath splits "items[].sku" into ["items", "[]", "sku"]
+func splitReportPath(path string) []string {
+	var tokens []string
+	for _, segment := range strings.Split(path, ".") {
+		name := strings.TrimRight(segment, "[]")
+		if name != "" {
+			tokens = append(tokens, name)
+		}
+		for i := 0; i < (len(segment)-len(name))/2; i++ {
+			tokens = append(tokens, "[]")
+		}
+	}
+	return tokens
+}
+
+// exportedTypes lists the observed types; integers are folded into number when both were seen
+func exportedTypes(observed map[model.SchemaType]int64) model.SchemaTypes {
+	_, hasNumber := observed[model.SchemaTypeNumber]
+	types := make(model.SchemaTypes, 0, len(observed))
+	for t := range observed {
+		if t == model.SchemaTypeInteger && hasNumber {
+			continue
+		}
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// distinctSketch estimates the number of distinct values with a k-minimum-values sketch
+type distinctSketch struct {
+	k      int
+	hashes []uint64 // Smallest hashes seen, ascending
+}
+
+func newDistinctSketch(k int) *distinctSketch {
+	return &distinctSketch{k: k}
+}
+
+func (d *distinctSketch) add(value string) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	hash := mix64(hasher.Sum64())
+
+	idx := sort.Search(len(d.hashes), func(i int) bool { return d.hashes[i] >= hash })
+	if idx < len(d.hashes) && d.hashes[idx] == hash {
+		return
+	}
+	if len(d.hashes) >= d.k {
+		if idx == len(d.hashes) {
+			return
+		}
+		d.hashes = d.hashes[:len(d.hashes)-1]
+	}
+	d.hashes = append(d.hashes, 0)
+	copy(d.hashes[idx+1:], d.hashes[idx:])
+	d.hashes[idx] = hash
+}
+
+// mix64 is the murmur3 finalizer: FNV alone is not uniform enough for order statistics
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (d *distinctSketch) estimate() (int64, bool) {
+	if len(d.hashes) < d.k {
+		return int64(len(d.hashes)), true
+	}
+	kth := float64(d.hashes[d.k-1]) / float64(math.MaxUint64)
+	if kth == 0 {
+		return int64(d.k), false
+	}
+	return int64(float64(d.k-1) / kth), false
+}
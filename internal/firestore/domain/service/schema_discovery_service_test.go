@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discoveryDocs() []*model.Document {
+	return []*model.Document{
+		{Fields: fieldsOf(map[string]interface{}{
+			"name":    "Ada",
+			"age":     int64(36),
+			"tags":    []interface{}{"math", "poetry"},
+			"address": map[string]interface{}{"zip": "12345", "city": "London"},
+		})},
+		{Fields: fieldsOf(map[string]interface{}{
+			"name":    "Grace",
+			"age":     85.5,
+			"address": map[string]interface{}{"zip": "54321"},
+		})},
+		{Fields: fieldsOf(map[string]interface{}{
+			"name": "Ada",
+			"age":  int64(36),
+			"tags": []interface{}{"navy"},
+		})},
+	}
+}
+
+func statsByPath(stats []*model.FieldPathStats) map[string]*model.FieldPathStats {
+	result := make(map[string]*model.FieldPathStats, len(stats))
+	for _, stat := range stats {
+		result[stat.FieldPath] = stat
+	}
+	return result
+}
+
+func TestSchemaDiscoveryService_FieldStats(t *testing.T) {
+	collector := NewSchemaDiscoveryService().NewCollector(2)
+	for _, doc := range discoveryDocs() {
+		collector.AddDocument(doc)
+	}
+	require.Equal(t, int64(3), collector.DocumentCount())
+
+	stats := statsByPath(collector.FieldStats())
+	assert.ElementsMatch(t, []string{"address", "address.city", "address.zip", "age", "name", "tags", "tags[]"}, keysOf(stats))
+
+	name := stats["name"]
+	assert.Equal(t, 1.0, name.PresenceRatio)
+	assert.Equal(t, map[model.SchemaType]int64{model.SchemaTypeString: 3}, name.Types)
+	assert.Equal(t, []interface{}{"Ada", "Grace"}, name.Examples)
+	assert.Equal(t, int64(2), name.Cardinality)
+	assert.True(t, name.CardinalityExact)
+
+	age := stats["age"]
+	assert.Equal(t, map[model.SchemaType]int64{model.SchemaTypeInteger: 2, model.SchemaTypeNumber: 1}, age.Types)
+
+	zip := stats["address.zip"]
+	assert.Equal(t, int64(2), zip.Presence)
+	assert.InDelta(t, 2.0/3.0, zip.PresenceRatio, 0.0001)
+
+	// Array elements are counted once per document for presence
+	tags := stats["tags[]"]
+	assert.Equal(t, int64(2), tags.Presence)
+	assert.Equal(t, int64(3), tags.Types[model.SchemaTypeString])
+	assert.Equal(t, int64(3), tags.Cardinality)
+}
+
+func TestSchemaDiscoveryService_CardinalityEstimate(t *testing.T) {
+	collector := NewSchemaDiscoveryService().NewCollector(0)
+	const distinct = 20000
+	for i := 0; i < distinct; i++ {
+		collector.AddDocument(&model.Document{Fields: fieldsOf(map[string]interface{}{"id": fmt.Sprintf("id-%d", i)})})
+	}
+
+	id := collector.FieldStats()[0]
+	assert.False(t, id.CardinalityExact)
+	assert.Empty(t, id.Examples)
+	assert.InDelta(t, distinct, id.Cardinality, distinct*0.1)
+}
+
+func TestSchemaDiscoveryService_ExportJSONSchema(t *testing.T) {
+	svc := NewSchemaDiscoveryService()
+	collector := svc.NewCollector(DefaultSchemaExamples)
+	for _, doc := range discoveryDocs() {
+		collector.AddDocument(doc)
+	}
+	report := &model.CollectionSchemaReport{DocumentsScanned: collector.DocumentCount(), Fields: collector.FieldStats()}
+
+	schema := svc.ExportJSONSchema(report)
+	assert.Equal(t, []string{"age", "name"}, schema.Required)
+	assert.Equal(t, model.SchemaTypes{model.SchemaTypeNumber}, schema.Properties["age"].Type)
+	assert.Equal(t, model.SchemaTypes{model.SchemaTypeArray}, schema.Properties["tags"].Type)
+	assert.Equal(t, model.SchemaTypes{model.SchemaTypeString}, schema.Properties["tags"].Items.Type)
+	assert.Equal(t, []string{"zip"}, schema.Properties["address"].Required)
+
+	// The exported schema accepts the documents it was discovered from
+	validator := NewSchemaValidationService()
+	for _, doc := range discoveryDocs() {
+		assert.False(t, validator.ValidateDocument(schema, doc.Fields).HasErrors())
+	}
+}
+
+func keysOf(stats map[string]*model.FieldPathStats) []string {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/errors"
+)
+
+// SchemaValidationService validates typed document fields against a collection schema
+type SchemaValidationService interface {
+	// ValidateDocument checks document fields against the root schema and returns field-level violations
+	ValidateDocument(schema *model.FieldSchema, fields map[string]*model.FieldValue) *errors.ValidationErrors
+}
+
+// schemaValidationService implements SchemaValidationService with a cache of compiled patterns
+type schemaValidationService struct {
+	patterns sync.Map // pattern string -> *regexp.Regexp
+}
+
+// NewSchemaValidationService creates a new schema validation service
+func NewSchemaValidationService() SchemaValidationService {
+	return &schemaValidationService{}
+}
+
+// ValidateDocument implements SchemaValidationService
+func (s *schemaValidationService) ValidateDocument(schema *model.FieldSchema, fields map[string]*model.FieldValue) *errors.ValidationErrors {
+	violations := errors.NewValidationErrors()
+	if schema == nil {
+		return violations
+	}
+	if !schema.Type.Allows(model.SchemaTypeObject) {
+		violations.Add("", "document root must be described as an object schema", nil)
+		return violations
+	}
+	s.validateObject("", schema, fields, violations)
+	return violations
+}
+
+func (s *schemaValidationService) validateValue(path string, schema *model.FieldSchema, value *model.FieldValue, violations *errors.ValidationErrors) {
+	actual := schemaTypeOf(value)
+	if actual == model.SchemaTypeNumber && isWholeNumber(value.Value) {
+		// JSON bodies decode every number as a double: whole numbers still satisfy "integer"
+		actual = model.SchemaTypeInteger
+	}
+	if !schema.Type.Allows(actual) {
+		violations.Add(path, fmt.Sprintf("expected %s, got %s", describeTypes(schema.Type), actual), nil)
+		return
+	}
+
+	raw := interface{}(nil)
+	if value != nil {
+		raw = value.Value
+	}
+
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, value) {
+		violations.Add(path, "value is not one of the allowed values", plainValue(value))
+	}
+
+	switch actual {
+	case model.SchemaTypeString:
+		str, _ := raw.(string)
+		length := utf8.RuneCountInString(str)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			violations.Add(path, fmt.Sprintf("string is shorter than %d characters", *schema.MinLength), str)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			violations.Add(path, fmt.Sprintf("string is longer than %d characters", *schema.MaxLength), str)
+		}
+		if schema.Pattern != "" {
+			if re := s.compile(schema.Pattern); re != nil && !re.MatchString(str) {
+				violations.Add(path, fmt.Sprintf("string does not match pattern %q", schema.Pattern), str)
+			}
+		}
+	case model.SchemaTypeInteger, model.SchemaTypeNumber:
+		if number, ok := toFloat(raw); ok {
+			if schema.Minimum != nil && number < *schema.Minimum {
+				violations.Add(path, fmt.Sprintf("value must be >= %v", *schema.Minimum), plainValue(value))
+			}
+			if schema.Maximum != nil && number > *schema.Maximum {
+				violations.Add(path, fmt.Sprintf("value must be <= %v", *schema.Maximum), plainValue(value))
+			}
+		}
+	case model.SchemaTypeArray:
+		items := arrayElements(raw)
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			violations.Add(path, fmt.Sprintf("array has fewer than %d items", *schema.MinItems), nil)
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			violations.Add(path, fmt.Sprintf("array has more than %d items", *schema.MaxItems), nil)
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				s.validateValue(path+"["+strconv.Itoa(i)+"]", schema.Items, item, violations)
+			}
+		}
+	case model.SchemaTypeObject:
+		s.validateObject(path, schema, mapFields(raw), violations)
+	}
+}
+
+func (s *schemaValidationService) validateObject(path string, schema *model.FieldSchema, fields map[string]*model.FieldValue, violations *errors.ValidationErrors) {
+	for _, name := range schema.Required {
+		if _, exists := fields[name]; !exists {
+			violations.Add(joinFieldPath(path, name), "required field is missing", nil)
+		}
+	}
+
+	// Sorted for deterministic error ordering
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldPath := joinFieldPath(path, name)
+		if propSchema, declared := schema.Properties[name]; declared {
+			s.validateValue(fieldPath, propSchema, fields[name], violations)
+			continue
+		}
+		if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+			violations.Add(fieldPath, "field is not allowed by the schema", nil)
+		}
+	}
+}
+
+func (s *schemaValidationService) compile(pattern string) *regexp.Regexp {
+	if cached, ok := s.patterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	s.patterns.Store(pattern, re)
+	return re
+}
+
+// schemaTypeOf maps a Firestore value type to its schema type
+func schemaTypeOf(value *model.FieldValue) model.SchemaType {
+	if value == nil {
+		return model.SchemaTypeNull
+	}
+	switch value.ValueType {
+	case model.FieldTypeNull:
+		return model.SchemaTypeNull
+	case model.FieldTypeBool:
+		return model.SchemaTypeBoolean
+	case model.FieldTypeInt:
+		return model.SchemaTypeInteger
+	case model.FieldTypeDouble:
+		return model.SchemaTypeNumber
+	case model.FieldTypeString:
+		return model.SchemaTypeString
+	case model.FieldTypeBytes:
+		return model.SchemaTypeBytes
+	case model.FieldTypeTimestamp:
+		return model.SchemaTypeTimestamp
+	case model.FieldTypeReference:
+		return model.SchemaTypeReference
+	case model.FieldTypeGeoPoint:
+		return model.SchemaTypeGeoPoint
+	case model.FieldTypeVector:
+		return model.SchemaTypeVector
+	case model.FieldTypeArray:
+		return model.SchemaTypeArray
+	case model.FieldTypeMap:
+		return model.SchemaTypeObject
+	default:
+		return model.SchemaType(value.ValueType)
+	}
+}
+
+// arrayElements normalizes the different in-memory representations of array values
+func arrayElements(raw interface{}) []*model.FieldValue {
+	switch v := raw.(type) {
+	case *model.ArrayValue:
+		if v == nil {
+			return nil
+		}
+		return v.Values
+	case []*model.FieldValue:
+		return v
+	case []interface{}:
+		items := make([]*model.FieldValue, len(v))
+		for i, item := range v {
+			items[i] = toFieldValue(item)
+		}
+		return items
+	}
+	return nil
+}
+
+// mapFields normalizes the different in-memory representations of map values
+func mapFields(raw interface{}) map[string]*model.FieldValue {
+	switch v := raw.(type) {
+	case *model.MapValue:
+		if v == nil {
+			return nil
+		}
+		return v.Fields
+	case map[string]*model.FieldValue:
+		return v
+	case map[string]interface{}:
+		fields := make(map[string]*model.FieldValue, len(v))
+		for key, item := range v {
+			fields[key] = toFieldValue(item)
+		}
+		return fields
+	}
+	return nil
+}
+
+func toFieldValue(value interface{}) *model.FieldValue {
+	if fv, ok := value.(*model.FieldValue); ok {
+		return fv
+	}
+	return model.NewFieldValue(value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isWholeNumber(value interface{}) bool {
+	number, ok := toFloat(value)
+	return ok && number == math.Trunc(number) && !math.IsInf(number, 0)
+}
+
+func plainValue(value *model.FieldValue) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.Value
+}
+
+func enumContains(enum []interface{}, value *model.FieldValue) bool {
+	actual := plainValue(value)
+	actualNumber, actualIsNumber := 0.0, false
+	if valueType := schemaTypeOf(value); valueType == model.SchemaTypeInteger || valueType == model.SchemaTypeNumber {
+		actualNumber, actualIsNumber = toFloat(actual)
+	}
+	for _, candidate := range enum {
+		if _, isString := candidate.(string); !isString && actualIsNumber {
+			// JSON decodes every number as float64, so compare numerically
+			if candidateNumber, ok := toFloat(candidate); ok && candidateNumber == actualNumber {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(candidate, actual) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeTypes(types model.SchemaTypes) string {
+	if len(types) == 1 {
+		return string(types[0])
+	}
+	return fmt.Sprintf("one of %v", []model.SchemaType(types))
+}
+
+func joinFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userSchemaJSON = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name":   {"type": "string", "minLength": 2, "maxLength": 20},
+		"age":    {"type": "integer", "minimum": 0, "maximum": 150},
+		"email":  {"type": ["string", "null"], "pattern": "^[^@]+@[^@]+$"},
+		"role":   {"type": "string", "enum": ["admin", "member"]},
+		"tags":   {"type": "array", "maxItems": 3, "items": {"type": "string"}},
+		"address": {
+			"type": "object",
+			"required": ["zip"],
+			"properties": {"zip": {"type": "string", "pattern": "^[0-9]{5}$"}}
+		}
+	}
+}`
+
+func loadUserSchema(t *testing.T) *model.FieldSchema {
+	t.Helper()
+	var schema model.FieldSchema
+	require.NoError(t, json.Unmarshal([]byte(userSchemaJSON), &schema))
+	return &schema
+}
+
+func fieldsOf(data map[string]interface{}) map[string]*model.FieldValue {
+	fields := make(map[string]*model.FieldValue, len(data))
+	for k, v := range data {
+		fields[k] = model.NewFieldValue(v)
+	}
+	return fields
+}
+
+func violatedFields(t *testing.T, schema *model.FieldSchema, data map[string]interface{}) []string {
+	t.Helper()
+	violations := NewSchemaValidationService().ValidateDocument(schema, fieldsOf(data))
+	var fields []string
+	for _, v := range violations.Errors {
+		fields = append(fields, v.Field)
+	}
+	return fields
+}
+
+func TestSchemaValidationService_ValidDocument(t *testing.T) {
+	schema := loadUserSchema(t)
+
+	// Whole JSON numbers decode as float64 and still satisfy "integer"
+	assert.Empty(t, violatedFields(t, schema, map[string]interface{}{
+		"name":    "Ada",
+		"age":     float64(36),
+		"email":   nil,
+		"role":    "admin",
+		"tags":    []interface{}{"math"},
+		"address": map[string]interface{}{"zip": "12345"},
+	}))
+}
+
+func TestSchemaValidationService_Violations(t *testing.T) {
+	schema := loadUserSchema(t)
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want []string
+	}{
+		{"missing required", map[string]interface{}{"name": "Ada"}, []string{"age"}},
+		{"wrong type", map[string]interface{}{"name": "Ada", "age": "36"}, []string{"age"}},
+		{"fractional integer", map[string]interface{}{"name": "Ada", "age": 36.5}, []string{"age"}},
+		{"out of range", map[string]interface{}{"name": "Ada", "age": int64(200)}, []string{"age"}},
+		{"string length", map[string]interface{}{"name": "A", "age": 1}, []string{"name"}},
+		{"pattern", map[string]interface{}{"name": "Ada", "age": 1, "email": "nope"}, []string{"email"}},
+		{"enum", map[string]interface{}{"name": "Ada", "age": 1, "role": "owner"}, []string{"role"}},
+		{"additional property", map[string]interface{}{"name": "Ada", "age": 1, "legacy": true}, []string{"legacy"}},
+		{"array items", map[string]interface{}{"name": "Ada", "age": 1, "tags": []interface{}{"a", 2}}, []string{"tags[1]"}},
+		{"nested object", map[string]interface{}{"name": "Ada", "age": 1, "address": map[string]interface{}{"zip": "abc"}}, []string{"address.zip"}},
+		{"nested required", map[string]interface{}{"name": "Ada", "age": 1, "address": map[string]interface{}{}}, []string{"address.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, violatedFields(t, schema, tt.data))
+		})
+	}
+}
+
+func TestSchemaValidationService_NilSchema(t *testing.T) {
+	violations := NewSchemaValidationService().ValidateDocument(nil, fieldsOf(map[string]interface{}{"any": 1}))
+	assert.False(t, violations.HasErrors())
+}
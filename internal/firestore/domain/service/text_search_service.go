@@ -0,0 +1,327 @@
+package service
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// bm25K1 and bm25B are the usual BM25 term saturation and length normalization parameters
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// prefixMatchFactor discounts terms matched only as a prefix of the last query word
+	prefixMatchFactor = 0.5
+	// highlightWindow is the maximum number of characters in a highlighted snippet
+	highlightWindow = 160
+)
+
+// ScoredDocument is a document ID ranked by a text search
+type ScoredDocument struct {
+	DocumentID string
+	Score      float64
+}
+
+// TextSearchService maintains in-memory inverted indexes for full-text search.
+// Each index key identifies an isolated index, e.g. one per tenant collection.
+type TextSearchService interface {
+	// IndexDocument replaces the indexed text of a document: field path -> text values
+	IndexDocument(indexKey, documentID string, fields map[string][]string, weights map[string]float64)
+	RemoveDocument(indexKey, documentID string)
+	DropIndex(indexKey string)
+	// Search ranks the documents matching the query with BM25. The last query word also matches as a prefix.
+	// With matchAll every query word must match, otherwise any word is enough.
+	Search(indexKey, query string, matchAll bool) []ScoredDocument
+	// Highlight returns a snippet of text with query matches wrapped in <em>, or "" without matches
+	Highlight(text, query string) string
+}
+
+// textSearchService implements TextSearchService
+type textSearchService struct {
+	mu      sync.RWMutex
+	indexes map[string]*textIndex
+}
+
+// textIndex is the inverted index of one collection
+type textIndex struct {
+	docs         map[string]*indexedDocument
+	postings     map[string]map[string]bool // term -> document IDs
+	fieldLengths map[string]int             // field path -> total tokens, for average lengths
+}
+
+type indexedDocument struct {
+	terms   map[string]map[string]int // term -> field path -> frequency
+	lengths map[string]int            // field path -> tokens
+	weights map[string]float64
+}
+
+// NewTextSearchService creates a new in-memory text search service
+func NewTextSearchService() TextSearchService {
+	return &textSearchService{indexes: make(map[string]*textIndex)}
+}
+
+// IndexDocument implements TextSearchService
+func (s *textSearchService) IndexDocument(indexKey, documentID string, fields map[string][]string, weights map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexKey]
+	if !exists {
+		index = &textIndex{
+			docs:         make(map[string]*indexedDocument),
+			postings:     make(map[string]map[string]bool),
+			fieldLengths: make(map[string]int),
+		}
+		s.indexes[indexKey] = index
+	}
+	index.remove(documentID)
+
+	doc := &indexedDocument{
+		terms:   make(map[string]map[string]int),
+		lengths: make(map[string]int),
+		weights: weights,
+	}
+	for fieldPath, values := range fields {
+		for _, value := range values {
+			for _, term := range tokenize(value) {
+				if doc.terms[term] == nil {
+					doc.terms[term] = make(map[string]int)
+				}
+				doc.terms[term][fieldPath]++
+				doc.lengths[fieldPath]++
+			}
+		}
+	}
+	if len(doc.terms) == 0 {
+		return
+	}
+
+	index.docs[documentID] = doc
+	for term := range doc.terms {
+		if index.postings[term] == nil {
+			index.postings[term] = make(map[string]bool)
+		}
+		index.postings[term][documentID] = true
+	}
+	for fieldPath, length := range doc.lengths {
+		index.fieldLengths[fieldPath] += length
+	}
+}
+
+// RemoveDocument implements TextSearchService
+func (s *textSearchService) RemoveDocument(indexKey, documentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index, exists := s.indexes[indexKey]; exists {
+		index.remove(documentID)
+	}
+}
+
+// DropIndex implements TextSearchService
+func (s *textSearchService) DropIndex(indexKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.indexes, indexKey)
+}
+
+func (index *textIndex) remove(documentID string) {
+	doc, exists := index.docs[documentID]
+	if !exists {
+		return
+	}
+	for term := range doc.terms {
+		delete(index.postings[term], documentID)
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+	for fieldPath, length := range doc.lengths {
+		index.fieldLengths[fieldPath] -= length
+	}
+	delete(index.docs, documentID)
+}
+
+// Search implements TextSearchService
+func (s *textSearchService) Search(indexKey, query string, matchAll bool) []ScoredDocument {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, exists := s.indexes[indexKey]
+	queryTerms := tokenize(query)
+	if !exists || len(queryTerms) == 0 {
+		return nil
+	}
+
+	// Expand every query word to the index terms it matches, with their match factor
+	expansions := make([]map[string]float64, len(queryTerms))
+	for i, queryTerm := range queryTerms {
+		expansions[i] = make(map[string]float64)
+		if _, ok := index.postings[queryTerm]; ok {
+			expansions[i][queryTerm] = 1
+		}
+		if i == len(queryTerms)-1 {
+			for term := range index.postings {
+				if term != queryTerm && strings.HasPrefix(term, queryTerm) {
+					expansions[i][term] = prefixMatchFactor
+				}
+			}
+		}
+	}
+
+	totalDocs := float64(len(index.docs))
+	scores := make(map[string]float64)
+	matchedWords := make(map[string]int)
+	for _, expansion := range expansions {
+		best := make(map[string]float64)
+		for term, factor := range expansion {
+			df := float64(len(index.postings[term]))
+			idf := math.Log(1 + (totalDocs-df+0.5)/(df+0.5))
+			for documentID := range index.postings[term] {
+				score := factor * idf * index.termWeight(index.docs[documentID], term)
+				if score > best[documentID] {
+					best[documentID] = score
+				}
+			}
+		}
+		for documentID, score := range best {
+			scores[documentID] += score
+			matchedWords[documentID]++
+		}
+	}
+
+	results := make([]ScoredDocument, 0, len(scores))
+	for documentID, score := range scores {
+		if matchAll && matchedWords[documentID] < len(queryTerms) {
+			continue
+		}
+		results = append(results, ScoredDocument{DocumentID: documentID, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].DocumentID < results[j].DocumentID
+	})
+	return results
+}
+
+// termWeight is the weighted BM25 term frequency component summed over the document fields
+func (index *textIndex) termWeight(doc *indexedDocument, term string) float64 {
+	total := 0.0
+	for fieldPath, tf := range doc.terms[term] {
+		avgLength := float64(index.fieldLengths[fieldPath]) / float64(len(index.docs))
+		if avgLength == 0 {
+			avgLength = 1
+		}
+		weight := 1.0
+		if w, ok := doc.weights[fieldPath]; ok && w > 0 {
+			weight = w
+		}
+		norm := 1 - bm25B + bm25B*float64(doc.lengths[fieldPath])/avgLength
+		total += weight * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+	}
+	return total
+}
+
+// Highlight implements TextSearchService
+func (s *textSearchService) Highlight(text, query string) string {
+	queryTerms := tokenize(query)
+	if len(queryTerms) == 0 {
+		return ""
+	}
+	exact := make(map[string]bool, len(queryTerms))
+	for _, term := range queryTerms {
+		exact[term] = true
+	}
+	prefix := queryTerms[len(queryTerms)-1]
+
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var matches []span
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		word := normalizeWord(string(runes[start:end]))
+		if exact[word] || strings.HasPrefix(word, prefix) {
+			matches = append(matches, span{start, end})
+		}
+		start = end
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	// Center the snippet window a little before the first match
+	from, to := 0, len(runes)
+	if len(runes) > highlightWindow {
+		from = matches[0].start - highlightWindow/4
+		if from < 0 {
+			from = 0
+		}
+		to = from + highlightWindow
+		if to > len(runes) {
+			to = len(runes)
+			from = to - highlightWindow
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	cursor := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[cursor:m.start])))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</em>")
+		cursor = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[cursor:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// tokenize splits text into lowercase, accent-folded words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, normalizeWord(word))
+	}
+	return terms
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// accentFolds maps accented Latin letters to their base letter so "cafe" finds "café"
+var accentFolds = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c",
+)
+
+func normalizeWord(word string) string {
+	return accentFolds.Replace(strings.ToLower(word))
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSearchTestIndex() TextSearchService {
+	s := NewTextSearchService()
+	s.IndexDocument("k", "1", map[string][]string{"title": {"Red running shoes"}}, nil)
+	s.IndexDocument("k", "2", map[string][]string{"title": {"Blue shoes"}, "body": {"Shoes for running, walking and running again"}}, nil)
+	s.IndexDocument("k", "3", map[string][]string{"title": {"Café racer jacket"}}, nil)
+	return s
+}
+
+func TestTextSearch_RankingAndMatchModes(t *testing.T) {
+	s := newSearchTestIndex()
+
+	results := s.Search("k", "running shoes", true)
+	require.Len(t, results, 2)
+	assert.ElementsMatch(t, []string{"1", "2"}, []string{results[0].DocumentID, results[1].DocumentID})
+
+	assert.Len(t, s.Search("k", "red jacket", true), 0)
+	assert.Len(t, s.Search("k", "red jacket", false), 2)
+
+	// Accents are folded and the last word matches as a prefix
+	results = s.Search("k", "cafe rac", true)
+	require.Len(t, results, 1)
+	assert.Equal(t, "3", results[0].DocumentID)
+
+	assert.Empty(t, s.Search("other", "shoes", false))
+}
+
+func TestTextSearch_FieldWeights(t *testing.T) {
+	s := NewTextSearchService()
+	s.IndexDocument("k", "title", map[string][]string{"title": {"lamp"}, "body": {"desk"}}, map[string]float64{"title": 3})
+	s.IndexDocument("k", "body", map[string][]string{"title": {"desk"}, "body": {"lamp"}}, map[string]float64{"title": 3})
+
+	results := s.Search("k", "lamp", true)
+	require.Len(t, results, 2)
+	assert.Equal(t, "title", results[0].DocumentID)
+}
+
+func TestTextSearch_ReindexAndRemove(t *testing.T) {
+	s := newSearchTestIndex()
+
+	s.IndexDocument("k", "1", map[string][]string{"title": {"Green hat"}}, nil)
+	assert.Len(t, s.Search("k", "red", false), 0)
+	assert.Len(t, s.Search("k", "green", false), 1)
+
+	s.RemoveDocument("k", "1")
+	assert.Len(t, s.Search("k", "green", false), 0)
+
+	s.DropIndex("k")
+	assert.Empty(t, s.Search("k", "shoes", false))
+}
+
+func TestTextSearch_Highlight(t *testing.T) {
+	s := NewTextSearchService()
+
+	assert.Equal(t, "<em>Red</em> running &amp; <em>shoes</em>", s.Highlight("Red running & shoes", "red sho"))
+	assert.Equal(t, "", s.Highlight("Blue hat", "shoes"))
+
+	long := "start " + strings.Repeat("filler ", 40) + "needle " + strings.Repeat("tail ", 40)
+	snippet := s.Highlight(long, "needle")
+	assert.Contains(t, snippet, "<em>needle</em>")
+	assert.True(t, len([]rune(snippet)) < len([]rune(long)))
+	assert.Equal(t, "…", string([]rune(snippet)[0]))
+}
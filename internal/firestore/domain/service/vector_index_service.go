@@ -0,0 +1,246 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"firestore-clone/internal/firestore/domain/model"
+)
+
+const (
+	// VectorIndexMinTrainingSize is the number of vectors below which an index stays
+	// unpartitioned: exact search is cheap enough and approximate search is not offered
+	VectorIndexMinTrainingSize = 1000
+	// vectorIndexMaxPartitions bounds the number of k-means partitions
+	vectorIndexMaxPartitions = 1024
+	// vectorIndexTrainingIterations is the number of k-means refinement passes
+	vectorIndexTrainingIterations = 8
+	// vectorIndexTrainingSamplesPerPartition bounds the k-means sample size
+	vectorIndexTrainingSamplesPerPartition = 64
+)
+
+// VectorIndexService maintains in-memory approximate nearest neighbor indexes. Vectors are
+// partitioned with k-means (an inverted file index); a query only looks at the partitions
+// whose centroids are closest to the query vector. Each index key identifies an isolated
+// index, e.g. one per tenant collection and vector field.
+type VectorIndexService interface {
+	Upsert(indexKey, documentID string, vector []float64)
+	Remove(indexKey, documentID string)
+	DropIndex(indexKey string)
+	Size(indexKey string) int
+	// Candidates returns the documents of the partitions closest to the query, probing
+	// partitions nearest first until at least minCandidates are collected. ok is false while
+	// the index is too small to be partitioned, in which case an exact search should be used.
+	Candidates(indexKey string, query []float64, measure model.DistanceMeasure, minCandidates int) (documentIDs []string, ok bool)
+}
+
+// vectorIndexService implements VectorIndexService
+type vectorIndexService struct {
+	mu      sync.Mutex
+	indexes map[string]*vectorIndex
+}
+
+// vectorIndex is the inverted file index of one vector field
+type vectorIndex struct {
+	vectors     map[string][]float64
+	centroids   [][]float64
+	partitions  []map[string]struct{} // partition -> document IDs
+	assignments map[string]int        // document ID -> partition
+	trainedSize int                   // number of vectors when the centroids were computed
+}
+
+// NewVectorIndexService creates a new in-memory vector index service
+func NewVectorIndexService() VectorIndexService {
+	return &vectorIndexService{indexes: make(map[string]*vectorIndex)}
+}
+
+// Upsert implements VectorIndexService
+func (s *vectorIndexService) Upsert(indexKey, documentID string, vector []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexKey]
+	if !exists {
+		index = &vectorIndex{vectors: make(map[string][]float64), assignments: make(map[string]int)}
+		s.indexes[indexKey] = index
+	}
+	index.remove(documentID)
+	stored := append([]float64(nil), vector...)
+	index.vectors[documentID] = stored
+	if index.centroids != nil {
+		index.assign(documentID, stored)
+	}
+}
+
+// Remove implements VectorIndexService
+func (s *vectorIndexService) Remove(indexKey, documentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, exists := s.indexes[indexKey]; exists {
+		index.remove(documentID)
+	}
+}
+
+// DropIndex implements VectorIndexService
+func (s *vectorIndexService) DropIndex(indexKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.indexes, indexKey)
+}
+
+// Size implements VectorIndexService
+func (s *vectorIndexService) Size(indexKey string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, exists := s.indexes[indexKey]; exists {
+		return len(index.vectors)
+	}
+	return 0
+}
+
+// Candidates implements VectorIndexService. Partitions are retrained lazily once the
+// index has doubled in size since the last training.
+func (s *vectorIndexService) Candidates(indexKey string, query []float64, measure model.DistanceMeasure, minCandidates int) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, exists := s.indexes[indexKey]
+	if !exists || len(index.vectors) < VectorIndexMinTrainingSize {
+		return nil, false
+	}
+	if index.centroids == nil || len(index.vectors) >= 2*index.trainedSize {
+		index.train()
+	}
+	if len(index.centroids) == 0 || len(index.centroids[0]) != len(query) {
+		return nil, false
+	}
+
+	type rankedPartition struct {
+		partition int
+		distance  float64
+	}
+	ranked := make([]rankedPartition, 0, len(index.centroids))
+	for i, centroid := range index.centroids {
+		distance, err := model.VectorDistance(measure, query, centroid)
+		if err != nil {
+			continue
+		}
+		ranked = append(ranked, rankedPartition{i, distance})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return measure.Ranks(ranked[i].distance, ranked[j].distance) })
+
+	candidates := make([]string, 0, minCandidates)
+	for _, r := range ranked {
+		for documentID := range index.partitions[r.partition] {
+			candidates = append(candidates, documentID)
+		}
+		if len(candidates) >= minCandidates {
+			break
+		}
+	}
+	return candidates, true
+}
+
+func (index *vectorIndex) remove(documentID string) {
+	if partition, assigned := index.assignments[documentID]; assigned {
+		delete(index.partitions[partition], documentID)
+		delete(index.assignments, documentID)
+	}
+	delete(index.vectors, documentID)
+}
+
+func (index *vectorIndex) assign(documentID string, vector []float64) {
+	partition := nearestCentroid(index.centroids, vector)
+	index.partitions[partition][documentID] = struct{}{}
+	index.assignments[documentID] = partition
+}
+
+// train computes sqrt(n) centroids with k-means over a deterministic sample and
+// reassigns every vector to its nearest centroid
+func (index *vectorIndex) train() {
+	ids := make([]string, 0, len(index.vectors))
+	for id := range index.vectors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	partitions := int(math.Sqrt(float64(len(ids))))
+	if partitions > vectorIndexMaxPartitions {
+		partitions = vectorIndexMaxPartitions
+	}
+	if partitions < 1 {
+		partitions = 1
+	}
+
+	sampleSize := partitions * vectorIndexTrainingSamplesPerPartition
+	if sampleSize > len(ids) {
+		sampleSize = len(ids)
+	}
+	sample := make([][]float64, sampleSize)
+	for i := range sample {
+		sample[i] = index.vectors[ids[i*len(ids)/sampleSize]]
+	}
+
+	centroids := make([][]float64, partitions)
+	for i := range centroids {
+		centroids[i] = append([]float64(nil), sample[i*len(sample)/partitions]...)
+	}
+	dimension := len(centroids[0])
+	for iteration := 0; iteration < vectorIndexTrainingIterations; iteration++ {
+		sums := make([][]float64, partitions)
+		counts := make([]int, partitions)
+		for _, vector := range sample {
+			if len(vector) != dimension {
+				continue
+			}
+			partition := nearestCentroid(centroids, vector)
+			if sums[partition] == nil {
+				sums[partition] = make([]float64, dimension)
+			}
+			for d, value := range vector {
+				sums[partition][d] += value
+			}
+			counts[partition]++
+		}
+		for i := range centroids {
+			// Empty partitions keep their previous centroid
+			if counts[i] == 0 {
+				continue
+			}
+			for d := range centroids[i] {
+				centroids[i][d] = sums[i][d] / float64(counts[i])
+			}
+		}
+	}
+
+	index.centroids = centroids
+	index.partitions = make([]map[string]struct{}, partitions)
+	for i := range index.partitions {
+		index.partitions[i] = make(map[string]struct{})
+	}
+	index.assignments = make(map[string]int, len(ids))
+	for _, id := range ids {
+		index.assign(id, index.vectors[id])
+	}
+	index.trainedSize = len(ids)
+}
+
+// nearestCentroid returns the partition whose centroid is closest in Euclidean distance
+func nearestCentroid(centroids [][]float64, vector []float64) int {
+	best, bestDistance := 0, math.Inf(1)
+	for i, centroid := range centroids {
+		if len(centroid) != len(vector) {
+			continue
+		}
+		distance := 0.0
+		for d := range centroid {
+			diff := centroid[d] - vector[d]
+			distance += diff * diff
+		}
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
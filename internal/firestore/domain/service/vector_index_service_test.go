@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClusteredVectorIndex indexes vectors around four well separated centers
+func newClusteredVectorIndex(perCluster int) VectorIndexService {
+	s := NewVectorIndexService()
+	centers := [][]float64{{0, 0}, {100, 0}, {0, 100}, {100, 100}}
+	for c, center := range centers {
+		for i := 0; i < perCluster; i++ {
+			offset := float64(i%10) / 10
+			s.Upsert("k", fmt.Sprintf("c%d-%d", c, i), []float64{center[0] + offset, center[1] - offset})
+		}
+	}
+	return s
+}
+
+func TestVectorIndex_SmallIndexesAreNotPartitioned(t *testing.T) {
+	s := newClusteredVectorIndex(10)
+	assert.Equal(t, 40, s.Size("k"))
+
+	_, ok := s.Candidates("k", []float64{0, 0}, model.DistanceMeasureEuclidean, 10)
+	assert.False(t, ok)
+	_, ok = s.Candidates("missing", []float64{0, 0}, model.DistanceMeasureEuclidean, 10)
+	assert.False(t, ok)
+}
+
+func TestVectorIndex_CandidatesComeFromTheNearestPartitions(t *testing.T) {
+	s := newClusteredVectorIndex(VectorIndexMinTrainingSize / 4)
+
+	candidates, ok := s.Candidates("k", []float64{99, 99}, model.DistanceMeasureEuclidean, 20)
+	require.True(t, ok)
+	require.NotEmpty(t, candidates)
+	assert.Less(t, len(candidates), s.Size("k"), "an approximate search must not return every vector")
+	for _, id := range candidates {
+		assert.Contains(t, id, "c3-")
+	}
+
+	// Writes after training are assigned to their nearest partition
+	s.Upsert("k", "new", []float64{99.5, 99.5})
+	candidates, _ = s.Candidates("k", []float64{99, 99}, model.DistanceMeasureEuclidean, 20)
+	assert.Contains(t, candidates, "new")
+
+	s.Remove("k", "new")
+	candidates, _ = s.Candidates("k", []float64{99, 99}, model.DistanceMeasureEuclidean, 20)
+	assert.NotContains(t, candidates, "new")
+
+	_, ok = s.Candidates("k", []float64{1, 2, 3}, model.DistanceMeasureEuclidean, 20)
+	assert.False(t, ok, "queries of another dimension need an exact search")
+
+	s.DropIndex("k")
+	assert.Zero(t, s.Size("k"))
+}
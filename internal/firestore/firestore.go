@@ -1,14 +1,25 @@
 package firestore
 
 import ( // Added imports
+	"context"
+	"fmt"
+	"os"
+	"time"
+
 	httpadapter "firestore-clone/internal/firestore/adapter/http"
 	redispersistence "firestore-clone/internal/firestore/adapter/persistence"
 	mongodbpersistence "firestore-clone/internal/firestore/adapter/persistence/mongodb"
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/adapter/webhook"
 	"firestore-clone/internal/firestore/config"
 	"firestore-clone/internal/firestore/domain/client"
 	"firestore-clone/internal/firestore/domain/repository" // May keep for interfaces
 	"firestore-clone/internal/firestore/domain/service"
 	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtparser "firestore-clone/internal/rules_translator/adapter/parser"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
 	"firestore-clone/internal/shared/database"
 	"firestore-clone/internal/shared/eventbus"
 	"firestore-clone/internal/shared/logger"
@@ -22,15 +33,27 @@ import ( // Added imports
 
 // FirestoreModule represents the core Firestore module with multi-tenant support.
 type FirestoreModule struct {
-	Config           *config.FirestoreConfig
-	AuthClient       client.AuthClient                 // Client to interact with Auth module
-	TenantAwareRepo  repository.FirestoreRepository    // Multi-tenant repository
-	QueryEngine      repository.QueryEngine            // MongoDB query engine implementation
-	SecurityRules    repository.SecurityRulesEngine    // MongoDB security rules engine implementation
-	FirestoreUsecase usecase.FirestoreUsecaseInterface // Interface type
-	RealtimeUsecase  usecase.RealtimeUsecase           // Enhanced real-time usecase (100% Firestore compatible)
-	SecurityUsecase  usecase.SecurityUsecase           // Interface type
-	Logger           logger.Logger
+	Config                 *config.FirestoreConfig
+	AuthClient             client.AuthClient                     // Client to interact with Auth module
+	TenantAwareRepo        repository.FirestoreRepository        // Multi-tenant repository
+	QueryEngine            repository.QueryEngine                // MongoDB query engine implementation
+	SecurityRules          repository.SecurityRulesEngine        // MongoDB security rules engine implementation
+	FirestoreUsecase       usecase.FirestoreUsecaseInterface     // Interface type
+	RealtimeUsecase        usecase.RealtimeUsecase               // Enhanced real-time usecase (100% Firestore compatible)
+	SecurityUsecase        usecase.SecurityUsecase               // Interface type
+	RecursiveDeleteUsecase usecase.RecursiveDeleteUsecase        // Recursive delete long-running operations
+	SchemaUsecase          usecase.CollectionSchemaUsecase       // Per-collection schema validation on writes
+	SchemaDiscoveryUsecase usecase.SchemaDiscoveryUsecase        // Collection schema discovery reports
+	SearchUsecase          usecase.SearchUsecase                 // Full-text search over declared string fields
+	TriggerUsecase         usecase.TriggerUsecase                // Document triggers delivered to HTTP webhooks
+	DocumentEvents         usecase.DocumentEventsUsecase         // In-process document change handlers on the event bus
+	ChangeFeedUsecase      usecase.ChangeFeedUsecase             // Pull-based change feed over the durable changelog
+	RulesCRUDUsecase       usecase.SecurityRulesCRUDUsecase      // Firestore-style security rules get, put, patch and delete
+	RulesReleaseUsecase    usecase.SecurityRulesReleaseUsecase   // Security rules rulesets, releases and rollback
+	RulesSimulatorUsecase  usecase.SecurityRulesSimulatorUsecase // Security rules playground with evaluation traces
+	RulesTestUsecase       usecase.SecurityRulesTestUsecase      // In-memory security rules unit tests
+	RulesCoverageUsecase   usecase.SecurityRulesCoverageUsecase  // Coverage of the released security rules
+	Logger                 logger.Logger
 
 	// Multi-tenant components
 	TenantManager       *database.TenantManager
@@ -40,6 +63,29 @@ type FirestoreModule struct {
 	// Redis components for distributed event storage
 	RedisClient     *redis.Client
 	RedisEventStore usecase.EventStore
+
+	// Persistent document trigger storage in the master database
+	TriggerStore         *mongodbpersistence.TriggerStore
+	TriggerDeliveryQueue *mongodbpersistence.TriggerDeliveryQueue
+	DocumentEventOutbox  *mongodbpersistence.DocumentEventOutbox
+	ChangeLogStore       *mongodbpersistence.ChangeLogStore
+
+	// Persistent security rules rulesets and releases in the master database
+	RulesHistoryStore *mongodbpersistence.RulesHistoryStore
+
+	// Persistent long-running operations in the master database
+	OperationStore *mongodbpersistence.OperationStore
+
+	// Optional MongoDB change stream feeding realtime listeners across instances
+	ChangeStreamSource *mongodbpersistence.ChangeStreamSource
+
+	// Revalidation of the active listeners after rules or role changes, fanned out to every
+	// instance when the realtime events are; the usecase is created with the listen handlers
+	RevalidationFanout   usecase.ListenerRevalidationFanout
+	ListenerRevalidation usecase.ListenerRevalidationUsecase
+
+	// In-process event bus of document changes and security rules events
+	EventBus *eventbus.EventBus
 }
 
 // NewFirestoreModule creates and initializes a new Firestore module with multi-tenant support.
@@ -60,63 +106,7 @@ func NewFirestoreModule(
 	}
 	log.Info("Firestore configuration loaded successfully.")
 
-	// Initialize EventBus
-	eventBus := eventbus.NewEventBus(log)
-
-	// Initialize TenantManager for multi-tenant support
-	tenantConfig := &database.TenantConfig{
-		DatabasePrefix:     "firestore_org_",
-		MaxConnections:     100,
-		AutoCreateDatabase: true,
-	}
-	tenantManager := database.NewTenantManager(mongoClient, tenantConfig, log)
-	log.Info("TenantManager initialized successfully.")
-
-	// Initialize OrganizationRepository
-	orgRepo := mongodbpersistence.NewOrganizationRepository(mongoClient, masterDB, tenantManager, log)
-	log.Info("OrganizationRepository initialized successfully.")
-
-	// Initialize TenantAwareDocumentRepository
-	tenantAwareRepo := mongodbpersistence.NewTenantAwareDocumentRepository(mongoClient, tenantManager, eventBus, log)
-	log.Info("TenantAwareDocumentRepository initialized successfully.")
-
-	// Initialize query engine with tenant-aware MongoDB implementation
-	queryEngine := mongodbpersistence.NewTenantAwareQueryEngine(mongoClient, tenantManager, log)
-	log.Info("TenantAwareQueryEngine initialized successfully.") // Initialize security rules engine
-	securityRulesEngine := mongodbpersistence.NewSecurityRulesEngine(masterDB, log)
-	log.Info("SecurityRulesEngine initialized successfully.") // Initialize projection service
-	projectionService := service.NewProjectionService()
-	log.Info("ProjectionService initialized successfully.")
-
-	// Initialize Redis Event Store for distributed realtime events
-	redisEventStore := redispersistence.NewRedisEventStore(redisClient, log)
-	log.Info("RedisEventStore initialized successfully.")
-
-	// Initialize use cases with enhanced real-time capabilities using Redis
-	realtimeUC := usecase.NewRealtimeUsecaseWithEventStore(log, redisEventStore) // Enhanced with Redis persistence
-	securityUC := usecase.NewSecurityUsecase(securityRulesEngine, log)
-
-	// Initialize FirestoreUsecase with tenant-aware repository and projection service
-	firestoreUC := usecase.NewFirestoreUsecase(tenantAwareRepo, securityRulesEngine, queryEngine, projectionService, log)
-
-	// Initialize OrganizationHandler
-	orgHandler := httpadapter.NewOrganizationHandler(orgRepo)
-	log.Info("OrganizationHandler initialized successfully.")
-	return &FirestoreModule{
-		Config:           cfg,
-		AuthClient:       authClient,
-		Logger:           log,
-		TenantAwareRepo:  tenantAwareRepo,
-		QueryEngine:      queryEngine,
-		SecurityRules:    securityRulesEngine,
-		FirestoreUsecase: firestoreUC,
-		RealtimeUsecase:  realtimeUC,
-		SecurityUsecase:  securityUC, TenantManager: tenantManager,
-		OrganizationRepo:    orgRepo,
-		OrganizationHandler: orgHandler,
-		RedisClient:         redisClient,
-		RedisEventStore:     redisEventStore,
-	}, nil
+	return newFirestoreModule(authClient, log, mongoClient, masterDB, cfg, redisClient)
 }
 
 // NewFirestoreModuleWithConfig creates and initializes a new Firestore module with provided configuration.
@@ -138,6 +128,18 @@ func NewFirestoreModuleWithConfig(
 	}
 	log.Info("Firestore configuration set successfully.")
 
+	return newFirestoreModule(authClient, log, mongoClient, masterDB, cfg, redisClient)
+}
+
+// newFirestoreModule wires the components of the module once the configuration is known
+func newFirestoreModule(
+	authClient client.AuthClient,
+	log logger.Logger,
+	mongoClient *mongo.Client,
+	masterDB *mongo.Database,
+	cfg *config.FirestoreConfig,
+	redisClient *redis.Client,
+) (*FirestoreModule, error) {
 	// Initialize EventBus
 	eventBus := eventbus.NewEventBus(log)
 
@@ -159,68 +161,390 @@ func NewFirestoreModuleWithConfig(
 	log.Info("TenantAwareDocumentRepository initialized successfully.")
 
 	// Initialize query engine with tenant-aware MongoDB implementation
-	queryEngine := mongodbpersistence.NewTenantAwareQueryEngine(mongoClient, tenantManager, log) // Initialize security rules engine
+	queryEngine := mongodbpersistence.NewTenantAwareQueryEngine(mongoClient, tenantManager, log)
+	log.Info("TenantAwareQueryEngine initialized successfully.") // Initialize security rules engine
 	securityRulesEngine := mongodbpersistence.NewSecurityRulesEngine(masterDB, log)
-	log.Info("SecurityRulesEngine initialized successfully.")
+	log.Info("SecurityRulesEngine initialized successfully.") // Initialize projection service
+	projectionService := service.NewProjectionService()
+	log.Info("ProjectionService initialized successfully.")
 
 	// Initialize Redis Event Store for distributed realtime events
-	redisEventStore2 := redispersistence.NewRedisEventStore(redisClient, log)
+	redisEventStore := redispersistence.NewRedisEventStore(redisClient, log)
 	log.Info("RedisEventStore initialized successfully.")
 
 	// Initialize use cases with enhanced real-time capabilities using Redis
-	realtimeUC := usecase.NewRealtimeUsecaseWithEventStore(log, redisEventStore2) // Enhanced with Redis persistence
+	realtimeUC, err := newRealtimeUsecase(cfg, log, redisEventStore)
+	if err != nil {
+		return nil, err
+	}
 	securityUC := usecase.NewSecurityUsecase(securityRulesEngine, log)
 
-	// Initialize projection service
-	projectionService2 := service.NewProjectionService()
-	log.Info("ProjectionService initialized successfully.")
+	// Durable changelog of every database, appended by the change feed below
+	changeLogStore := mongodbpersistence.NewChangeLogStore(masterDB)
+
+	// Initialize full-text search and vector indexes; both follow local writes through the indexing
+	// repository and apply the changelog before a query, so they serve the writes of other instances
+	searchUC := usecase.NewSearchUsecaseWithStores(tenantAwareRepo, service.NewTextSearchService(), mongodbpersistence.NewSearchConfigStore(tenantManager), changeLogStore, log)
+	vectorUC := usecase.NewVectorIndexUsecaseWithChangeLog(tenantAwareRepo, service.NewVectorIndexService(), changeLogStore, log)
+	indexedRepo := usecase.NewIndexingRepository(tenantAwareRepo, searchUC, vectorUC)
+
+	// Initialize document triggers; writes are queued for webhook delivery in their own transaction by the document change repository
+	triggerStore := mongodbpersistence.NewTriggerStore(masterDB)
+	triggerQueue := mongodbpersistence.NewTriggerDeliveryQueue(masterDB)
+	triggerUC := usecase.NewTriggerUsecase(triggerStore, triggerQueue, webhook.NewHTTPSenderWithConfig(webhook.HTTPSenderConfig{AllowPrivateNetworks: cfg.WebhookAllowPrivateNetworks}), usecase.DefaultTriggerDeliveryConfig(), log)
+
+	// Initialize security rules releases; every deployment is kept as a ruleset in the master database
+	rulesHistoryStore := mongodbpersistence.NewRulesHistoryStore(masterDB)
+	rulesDeployer := newRulesDeployer(securityRulesEngine, rulesHistoryStore)
+	rulesReleaseUC := newRulesReleaseUsecase(rulesDeployer, eventBus)
+	rulesCRUDUC := newRulesCRUDUsecase(securityRulesEngine, eventBus)
+	rulesCoverage := newRulesCoverageStore(cfg, securityRulesEngine)
+	rulesCoverageUC := newRulesCoverageUsecase(rulesCoverage, rulesDeployer)
+	rulesSimulatorUC := newRulesSimulatorUsecase(securityRulesEngine, rulesCoverage)
+	rulesTestUC, err := newRulesTestUsecase(rulesDeployer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize in-process document event handlers; matching changes go through a persistent outbox to the event bus
+	documentEventOutbox := mongodbpersistence.NewDocumentEventOutbox(masterDB)
+	documentEventsUC := usecase.NewDocumentEventsUsecase(eventBus, documentEventOutbox, usecase.DefaultTriggerDeliveryConfig(), log)
+
+	// Initialize the change feed; every change is appended to the durable changelog of its database in the transaction of its write
+	changeFeedConfig := usecase.DefaultChangeFeedConfig()
+	changeFeedConfig.Retention = cfg.Realtime.ChangeFeedRetention
+	changeFeedUC := usecase.NewChangeFeedUsecase(changeLogStore, changeFeedConfig, log)
+	changeHandlers := []usecase.DocumentChangeHandler{triggerUC, documentEventsUC, changeFeedUC}
+
+	// Publish committed changes to realtime listeners; with change streams every instance publishes the writes of all instances
+	realtimePublisher := usecase.NewRealtimeChangePublisher(realtimeUC, log)
+	var changeStreamSource *mongodbpersistence.ChangeStreamSource
+	if cfg.Realtime.ChangeStreamsEnabled {
+		changeStreamSource = mongodbpersistence.NewChangeStreamSource(mongoClient, masterDB, tenantConfig.DatabasePrefix, changeStreamID(cfg), realtimePublisher, log)
+	} else {
+		changeHandlers = append(changeHandlers, realtimePublisher)
+	}
+	changeRepo := usecase.NewDocumentChangeRepositoryWithCommitScope(indexedRepo, mongodbpersistence.NewTransactionalCommitScope(mongoClient, log), changeHandlers...)
 
-	// Initialize FirestoreUsecase with tenant-aware repository and projection service
-	firestoreUC := usecase.NewFirestoreUsecase(tenantAwareRepo, securityRulesEngine, queryEngine, projectionService2, log)
+	// Initialize collection schemas, stored in the organization databases; every write of the FirestoreUsecase goes through schema validation
+	schemaUC := usecase.NewCollectionSchemaUsecase(mongodbpersistence.NewCollectionSchemaStore(tenantManager), service.NewSchemaValidationService(), log)
+	validatingRepo := usecase.NewSchemaValidatingRepository(changeRepo, schemaUC)
+
+	// Initialize FirestoreUsecase with tenant-aware repository and projection service; findNearest queries use the vector indexes
+	firestoreUC := usecase.NewFirestoreUsecase(validatingRepo, securityRulesEngine, usecase.NewVectorSearchQueryEngine(queryEngine, vectorUC), projectionService, log)
+
+	// Initialize recursive delete usecase; operations are kept in the master database so any instance can resume them,
+	// and its deletes reach listeners through the document change repository
+	operationStore := mongodbpersistence.NewOperationStore(masterDB)
+	recursiveDeleteUC := usecase.NewRecursiveDeleteUsecase(changeRepo, securityUC, nil, operationStore, log)
+
+	// Initialize schema discovery over the tenant-aware repository
+	schemaDiscoveryUC := usecase.NewSchemaDiscoveryUsecase(tenantAwareRepo, service.NewSchemaDiscoveryService(), log)
 
 	// Initialize OrganizationHandler
 	orgHandler := httpadapter.NewOrganizationHandler(orgRepo)
 	log.Info("OrganizationHandler initialized successfully.")
 	return &FirestoreModule{
-		Config:           cfg,
-		AuthClient:       authClient,
-		Logger:           log,
-		TenantAwareRepo:  tenantAwareRepo,
-		QueryEngine:      queryEngine,
-		SecurityRules:    securityRulesEngine,
-		FirestoreUsecase: firestoreUC,
-		RealtimeUsecase:  realtimeUC,
-		SecurityUsecase:  securityUC, TenantManager: tenantManager,
-		OrganizationRepo:    orgRepo,
-		OrganizationHandler: orgHandler,
-		RedisClient:         redisClient,
-		RedisEventStore:     redisEventStore2,
+		Config:                 cfg,
+		AuthClient:             authClient,
+		Logger:                 log,
+		TenantAwareRepo:        tenantAwareRepo,
+		QueryEngine:            queryEngine,
+		SecurityRules:          securityRulesEngine,
+		FirestoreUsecase:       firestoreUC,
+		RealtimeUsecase:        realtimeUC,
+		SecurityUsecase:        securityUC,
+		RecursiveDeleteUsecase: recursiveDeleteUC,
+		SchemaUsecase:          schemaUC,
+		SchemaDiscoveryUsecase: schemaDiscoveryUC,
+		SearchUsecase:          searchUC,
+		TriggerUsecase:         triggerUC,
+		DocumentEvents:         documentEventsUC,
+		ChangeFeedUsecase:      changeFeedUC,
+		RulesCRUDUsecase:       rulesCRUDUC,
+		RulesReleaseUsecase:    rulesReleaseUC,
+		RulesSimulatorUsecase:  rulesSimulatorUC,
+		RulesTestUsecase:       rulesTestUC,
+		RulesCoverageUsecase:   rulesCoverageUC,
+		TenantManager:          tenantManager,
+		OrganizationRepo:       orgRepo,
+		OrganizationHandler:    orgHandler,
+		RedisClient:            redisClient,
+		RedisEventStore:        redisEventStore,
+		TriggerStore:           triggerStore,
+		TriggerDeliveryQueue:   triggerQueue,
+		DocumentEventOutbox:    documentEventOutbox,
+		ChangeLogStore:         changeLogStore,
+		RulesHistoryStore:      rulesHistoryStore,
+		OperationStore:         operationStore,
+		ChangeStreamSource:     changeStreamSource,
+		RevalidationFanout:     newListenerRevalidationFanout(cfg, redisClient, log),
+		EventBus:               eventBus,
 	}, nil
 }
 
 // RegisterRoutes registers the HTTP routes for the Firestore module.
 func (m *FirestoreModule) RegisterRoutes(router fiber.Router, authMiddleware *authhttp.AuthMiddleware) { // Register Enhanced WebSocket handler for 100% Firestore-compatible real-time updates
 	enhancedWSHandler := httpadapter.NewEnhancedWebSocketHandler(m.RealtimeUsecase, m.SecurityUsecase, m.AuthClient, m.Logger)
+	enhancedWSHandler.FirestoreUC = m.FirestoreUsecase
 	enhancedWSHandler.RegisterRoutes(router, authMiddleware.RequireAuth())
 
+	// Server-Sent Events transport for clients that cannot open WebSockets
+	sseListenHandler := httpadapter.NewSSEListenHandler(m.RealtimeUsecase, m.Logger)
+	sseListenHandler.FirestoreUC = m.FirestoreUsecase
+	sseListenHandler.SecurityUC = m.SecurityUsecase
+	sseListenHandler.AuthClient = m.AuthClient
+	sseListenHandler.RegisterRoutes(router, authMiddleware.RequireAuth())
+
+	// Listeners are checked again whenever the rules of their database change
+	m.revalidateListenersOnRulesChange(enhancedWSHandler, sseListenHandler)
+
 	// Register HTTP adapter for Firestore REST API (now with Enhanced WebSocket handler included)
 	httpHandler := httpadapter.NewFirestoreHTTPHandler(m.FirestoreUsecase, m.SecurityUsecase, m.RealtimeUsecase, m.AuthClient, m.Logger, m.OrganizationHandler, enhancedWSHandler)
+	httpHandler.RecursiveDeleteUC = m.RecursiveDeleteUsecase
+	httpHandler.CollectionSchemaUC = m.SchemaUsecase
+	httpHandler.SchemaDiscoveryUC = m.SchemaDiscoveryUsecase
+	httpHandler.SearchUC = m.SearchUsecase
+	httpHandler.TriggerUC = m.TriggerUsecase
+	httpHandler.ChangeFeedUC = m.ChangeFeedUsecase
+	httpHandler.RulesCRUDUC = m.RulesCRUDUsecase
+	httpHandler.RulesReleaseUC = m.RulesReleaseUsecase
+	httpHandler.RulesSimulatorUC = m.RulesSimulatorUsecase
+	httpHandler.RulesTestUC = m.RulesTestUsecase
+	httpHandler.RulesCoverageUC = m.RulesCoverageUsecase
+	httpHandler.RulesAdminAuth = []fiber.Handler{authMiddleware.RequireAuth(), authMiddleware.RequireRole(usecase.AdminRole)}
 	httpHandler.RegisterRoutes(router)
 
 	m.Logger.Info("Firestore HTTP routes and Enhanced WebSocket handler registered with 100% compatibility.")
 }
 
+// revalidateListenersOnRulesChange revalidates the listeners of the listen transports
+// whenever the rules of their database change, on every instance. Listeners the new rules
+// deny are closed and audited as rule violations.
+func (m *FirestoreModule) revalidateListenersOnRulesChange(wsHandler *httpadapter.EnhancedWebSocketHandler, sseHandler *httpadapter.SSEListenHandler) {
+	m.ListenerRevalidation = usecase.NewListenerRevalidationUsecase(m.RevalidationFanout, m.EventBus, m.Logger, wsHandler, sseHandler)
+	if m.EventBus == nil {
+		return
+	}
+	m.EventBus.Subscribe(eventbus.EventTypeSecurityRulesChanged, func(ctx context.Context, event eventbus.Event) error {
+		change, ok := event.Data().(usecase.RulesChange)
+		if !ok {
+			return nil
+		}
+		m.ListenerRevalidation.Revalidate(ctx, usecase.ListenerRevalidation{
+			ProjectID:  change.ProjectID,
+			DatabaseID: change.DatabaseID,
+			Version:    change.Version,
+		})
+		return nil
+	})
+}
+
+// RevalidateUserListeners checks the listeners of a user again on every instance, after
+// the roles or tenants of the user change
+func (m *FirestoreModule) RevalidateUserListeners(ctx context.Context, userID string) {
+	if m.ListenerRevalidation == nil || userID == "" {
+		return
+	}
+	m.ListenerRevalidation.Revalidate(ctx, usecase.ListenerRevalidation{UserID: userID})
+}
+
 // StartRealtimeServices starts any background services for real-time functionality.
 func (m *FirestoreModule) StartRealtimeServices() {
 	// e.g., connect to message queues, start event listeners for RealtimeUsecase if it had any.
 	// For the current in-memory RealtimeUsecase, there might be nothing explicit to start here
 	// unless it needs to initialize some internal goroutines or listeners.
 	m.Logger.Info("Real-time services (if any) would be started here.")
+
+	m.ensureEventStorageIndexes()
+	if receiver, ok := m.RealtimeUsecase.(usecase.RealtimeFanoutReceiver); ok {
+		receiver.Start(context.Background())
+	}
+	if m.TriggerUsecase != nil {
+		m.TriggerUsecase.Start(context.Background())
+	}
+	if m.DocumentEvents != nil {
+		m.DocumentEvents.Start(context.Background())
+	}
+	if m.ChangeFeedUsecase != nil {
+		m.ChangeFeedUsecase.Start(context.Background())
+	}
+	if m.ChangeStreamSource != nil {
+		m.ChangeStreamSource.Start(context.Background())
+	}
+	if m.ListenerRevalidation != nil {
+		m.ListenerRevalidation.Start(context.Background())
+	}
+}
+
+// ensureEventStorageIndexes creates the indexes of the persistent trigger, document event, changelog, rules history and operation storage
+func (m *FirestoreModule) ensureEventStorageIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if m.TriggerStore != nil {
+		if err := m.TriggerStore.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create document trigger indexes", "error", err)
+		}
+	}
+	if m.TriggerDeliveryQueue != nil {
+		if err := m.TriggerDeliveryQueue.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create trigger delivery indexes", "error", err)
+		}
+	}
+	if m.DocumentEventOutbox != nil {
+		if err := m.DocumentEventOutbox.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create document event outbox indexes", "error", err)
+		}
+	}
+	if m.ChangeLogStore != nil {
+		if err := m.ChangeLogStore.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create changelog indexes", "error", err)
+		}
+	}
+	if m.RulesHistoryStore != nil {
+		if err := m.RulesHistoryStore.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create security rules history indexes", "error", err)
+		}
+	}
+	if m.OperationStore != nil {
+		if err := m.OperationStore.EnsureIndexes(ctx); err != nil {
+			m.Logger.Warn("Failed to create long-running operation indexes", "error", err)
+		}
+	}
+}
+
+// newRulesTranslator creates the translator of rules sources into engine rules
+func newRulesTranslator() rtdomain.RulesTranslator {
+	return rtusecase.NewFastTranslator(
+		rtadapter.NewMemoryCache(rtadapter.DefaultCacheConfig()),
+		rtadapter.NewRulesOptimizer(rtadapter.DefaultOptimizerConfig()),
+		rtusecase.DefaultTranslatorConfig(),
+	)
+}
+
+// newRulesDeployer creates the deployer of rulesets into the engine, with their history
+func newRulesDeployer(engine repository.SecurityRulesEngine, history rtadapter.DeployHistoryStore) rtdomain.RulesDeployer {
+	return rtadapter.NewRulesDeployer(engine, rtadapter.NewSimpleValidator(), history, rtadapter.DefaultDeployerConfig())
+}
+
+// newRulesReleaseUsecase creates the security rules release usecase, which parses and
+// translates rules sources and deploys them through the rules deployer, announcing every
+// release on the event bus
+func newRulesReleaseUsecase(deployer rtdomain.RulesDeployer, bus *eventbus.EventBus) usecase.SecurityRulesReleaseUsecase {
+	return usecase.NewSecurityRulesReleaseUsecaseWithEvents(rtparser.NewModernParserInstance(), newRulesTranslator(), deployer, bus)
+}
+
+// newRulesCRUDUsecase creates the Firestore-style rules CRUD, which deploys rules directly
+// into the engine and announces every change on the event bus. Like releases, it refuses
+// rules with security lint errors unless the request allows them.
+func newRulesCRUDUsecase(engine repository.SecurityRulesEngine, bus *eventbus.EventBus) usecase.SecurityRulesCRUDUsecase {
+	orchestrator := usecase.NewSecurityRulesTranslatorOrchestrator(rtparser.NewModernParserInstance(), newRulesTranslator(), nil, engine)
+	return usecase.NewSecurityRulesCRUDUsecaseWithLinter(orchestrator, engine, bus, rtadapter.NewRulesLinter())
+}
+
+// newListenerRevalidationFanout returns the Redis fan-out of listener revalidations when the
+// realtime events reach several instances, through Redis or change streams, or nil when
+// listeners only live on this instance
+func newListenerRevalidationFanout(cfg *config.FirestoreConfig, client *redis.Client, log logger.Logger) usecase.ListenerRevalidationFanout {
+	if client == nil || !(cfg.Realtime.RedisFanoutEnabled || cfg.Realtime.ChangeStreamsEnabled) {
+		return nil
+	}
+	return redispersistence.NewRedisListenerRevalidationFanout(client, log)
+}
+
+// newRulesTestUsecase creates the rules test runner. It simulates requests with an
+// in-memory resource accessor, so tests only see the documents they seed.
+func newRulesTestUsecase(deployer rtdomain.RulesDeployer) (usecase.SecurityRulesTestUsecase, error) {
+	env, err := rules_cel.NewEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rules CEL environment: %w", err)
+	}
+	simulator := rules_cel.NewSimulator(env, rtadapter.NewMemoryResourceAccessor(nil))
+	runner := rtusecase.NewRulesTestRunner(rtparser.NewModernParserInstance(), newRulesTranslator(), simulator)
+	return usecase.NewSecurityRulesTestUsecase(runner, deployer), nil
+}
+
+// newRulesSimulatorUsecase creates the rules simulator over the engine, or returns nil
+// when the engine cannot simulate requests
+func newRulesSimulatorUsecase(engine repository.SecurityRulesEngine, coverage repository.RulesCoverageStore) usecase.SecurityRulesSimulatorUsecase {
+	simulator, ok := engine.(repository.RulesSimulator)
+	if !ok {
+		return nil
+	}
+	return usecase.NewSecurityRulesSimulatorUsecaseWithCoverage(simulator, rtparser.NewModernParserInstance(), newRulesTranslator(), coverage)
+}
+
+// newRulesCoverageStore makes the engine record the coverage of the requests it evaluates,
+// or returns nil when coverage is disabled or the engine cannot record it
+func newRulesCoverageStore(cfg *config.FirestoreConfig, engine repository.SecurityRulesEngine) repository.RulesCoverageStore {
+	if !cfg.RulesCoverageEnabled {
+		return nil
+	}
+	instrumented, ok := engine.(repository.RulesCoverageInstrumented)
+	if !ok {
+		return nil
+	}
+	store := rules_cel.NewCoverageStore()
+	instrumented.SetCoverageRecorder(store)
+	return store
+}
+
+// newRulesCoverageUsecase creates the rules coverage report, or returns nil without a
+// coverage store
+func newRulesCoverageUsecase(coverage repository.RulesCoverageStore, deployer rtdomain.RulesDeployer) usecase.SecurityRulesCoverageUsecase {
+	if coverage == nil {
+		return nil
+	}
+	return usecase.NewSecurityRulesCoverageUsecase(coverage, deployer, rtparser.NewModernParserInstance(), newRulesTranslator())
+}
+
+// newRealtimeUsecase creates the realtime usecase over the Redis event store, fanning events
+// out to every instance through Redis when enabled. Fan-out and change streams are two
+// sources of the same events, so a configuration enabling both is refused.
+func newRealtimeUsecase(cfg *config.FirestoreConfig, log logger.Logger, eventStore *redispersistence.RedisEventStore) (usecase.RealtimeUsecase, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.Realtime.RedisFanoutEnabled {
+		return usecase.NewRealtimeUsecaseWithEventStore(log, eventStore), nil // Enhanced with Redis persistence
+	}
+	fanout := redispersistence.NewRedisEventFanout(eventStore, cfg.Redis.StreamMaxLength, log)
+	return usecase.NewRealtimeUsecaseWithFanout(log, eventStore, fanout), nil
+}
+
+// changeStreamID returns the configured change stream ID, or the hostname so that every
+// instance keeps its own resume token
+func changeStreamID(cfg *config.FirestoreConfig) string {
+	if cfg.Realtime.ChangeStreamID != "" {
+		return cfg.Realtime.ChangeStreamID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "default"
 }
 
 // Stop gracefully shuts down the Firestore module.
 func (m *FirestoreModule) Stop() error {
 	m.Logger.Info("Stopping Firestore Module...")
+	if m.TriggerUsecase != nil {
+		m.TriggerUsecase.Stop()
+	}
+	if m.DocumentEvents != nil {
+		m.DocumentEvents.Stop()
+	}
+	if m.ChangeFeedUsecase != nil {
+		m.ChangeFeedUsecase.Stop()
+	}
+	if m.ChangeStreamSource != nil {
+		m.ChangeStreamSource.Stop()
+	}
+	if m.ListenerRevalidation != nil {
+		m.ListenerRevalidation.Stop()
+	}
+	if receiver, ok := m.RealtimeUsecase.(usecase.RealtimeFanoutReceiver); ok {
+		receiver.Stop()
+	}
 	// Any cleanup operations would go here
 	m.Logger.Info("Firestore Module stopped.")
 	return nil
@@ -126,6 +126,23 @@ func TestFirestoreModule_InitializationWithConfig(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// TestFirestoreModule_RefusesTwoRealtimeSources checks that change streams and Redis fan-out,
+// which would each publish every write, cannot be enabled together
+func TestFirestoreModule_RefusesTwoRealtimeSources(t *testing.T) {
+	mongoClient, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	defer mongoClient.Disconnect(context.Background())
+
+	cfg := config.DefaultFirestoreConfig()
+	cfg.Realtime.ChangeStreamsEnabled = true
+	cfg.Realtime.RedisFanoutEnabled = true
+	assert.Error(t, cfg.Validate())
+
+	module, err := NewFirestoreModuleWithConfig(&MockAuthClient{}, logger.NewLogger(), mongoClient, mongoClient.Database("firestore_test_master"), cfg, createTestRedisClientMock())
+	assert.Error(t, err)
+	assert.Nil(t, module)
+}
+
 // TestFirestoreModule_QueryEngine_NestedFieldSupport tests the enhanced query capabilities
 // This tests the main feature we implemented: nested field path support
 func TestFirestoreModule_QueryEngine_NestedFieldSupport(t *testing.T) {
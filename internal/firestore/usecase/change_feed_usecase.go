@@ -0,0 +1,450 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+)
+
+const (
+	defaultChangeFeedLimit = 100
+	maxChangeFeedLimit     = 1000
+
+	// changeLogGapTimeout is how long a reader waits for a missing sequence to be stored.
+	// Under a commit scope a sequence is taken in the transaction of its write and commits
+	// with it, so gaps only appear without one: a young gap is a write in flight, an older
+	// one a write that failed after taking its sequence.
+	changeLogGapTimeout = 10 * time.Second
+)
+
+// ChangeFeedUsecase defines the primary port for the pull-based change feed. Every committed
+// document change is appended to a durable per-database changelog with an increasing
+// sequence; readers page through it with cursors and long-poll when they are caught up.
+// The changelog is recorded in the transaction of the write when there is a commit scope.
+type ChangeFeedUsecase interface {
+	// ReadChanges returns the changes after the request cursor in commit order. When there
+	// are none it waits up to req.Wait for new changes before returning an empty page.
+	ReadChanges(ctx context.Context, req ReadChangesRequest) (*model.ChangeFeedPage, error)
+	// Prune removes the changes older than the retention period and returns how many were removed
+	Prune(ctx context.Context) (int, error)
+	// Start runs the retention worker until Stop is called
+	Start(ctx context.Context)
+	Stop()
+
+	// Write notifications, called by the document change repository
+	DocumentChangeRecorder
+}
+
+// ChangeLogStore defines the secondary port for the durable changelog. Changelogs belong
+// to the organization in the context: projects of different organizations can share an ID.
+type ChangeLogStore interface {
+	// Append assigns the next sequences of the database to the entries, in order, and stores them
+	Append(ctx context.Context, projectID, databaseID string, entries []*model.ChangeLogEntry) error
+	// ReadAfter returns up to limit entries with a sequence above after, in sequence order
+	ReadAfter(ctx context.Context, projectID, databaseID string, after int64, limit int) ([]*model.ChangeLogEntry, error)
+	// GetState returns the sequence state of the database, zero when nothing was recorded
+	GetState(ctx context.Context, projectID, databaseID string) (*model.ChangeLogState, error)
+	// Prune removes the entries recorded before cutoff and advances PrunedThrough of their databases
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// ChangeFeedConfig holds the retention of the changelog and the pacing of readers
+type ChangeFeedConfig struct {
+	Retention     time.Duration // How long changes stay readable
+	MaxWait       time.Duration // Upper bound of a long poll
+	PollInterval  time.Duration // How often a long poll rereads the changelog for changes of other instances
+	PruneInterval time.Duration // How often expired changes are removed
+}
+
+// DefaultChangeFeedConfig returns the default change feed configuration
+func DefaultChangeFeedConfig() ChangeFeedConfig {
+	return ChangeFeedConfig{
+		Retention:     7 * 24 * time.Hour,
+		MaxWait:       60 * time.Second,
+		PollInterval:  time.Second,
+		PruneInterval: 10 * time.Minute,
+	}
+}
+
+// InMemoryChangeLogStore implements ChangeLogStore with in-memory storage.
+// The changelog is lost on restart; production deployments use a persistent store.
+type InMemoryChangeLogStore struct {
+	logs map[string]*inMemoryChangeLog // organizationID/projectID/databaseID -> changelog
+	mu   sync.RWMutex
+}
+
+type inMemoryChangeLog struct {
+	state   model.ChangeLogState
+	entries []*model.ChangeLogEntry
+}
+
+// NewInMemoryChangeLogStore creates a new in-memory changelog store
+func NewInMemoryChangeLogStore() ChangeLogStore {
+	return &InMemoryChangeLogStore{
+		logs: make(map[string]*inMemoryChangeLog),
+	}
+}
+
+func changeLogKey(organizationID, projectID, databaseID string) string {
+	return organizationID + "/" + projectID + "/" + databaseID
+}
+
+// Append stores copies of the entries with the next sequences
+func (s *InMemoryChangeLogStore) Append(ctx context.Context, projectID, databaseID string, entries []*model.ChangeLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	key := changeLogKey(organizationID, projectID, databaseID)
+	log, exists := s.logs[key]
+	if !exists {
+		log = &inMemoryChangeLog{state: model.ChangeLogState{OrganizationID: organizationID, ProjectID: projectID, DatabaseID: databaseID}}
+		s.logs[key] = log
+	}
+	for _, entry := range entries {
+		log.state.LastSequence++
+		entry.Sequence = log.state.LastSequence
+		entry.OrganizationID = organizationID
+		stored := *entry
+		log.entries = append(log.entries, &stored)
+	}
+	return nil
+}
+
+// ReadAfter returns copies of the entries following a sequence
+func (s *InMemoryChangeLogStore) ReadAfter(ctx context.Context, projectID, databaseID string, after int64, limit int) ([]*model.ChangeLogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	log, exists := s.logs[changeLogKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID)]
+	if !exists {
+		return []*model.ChangeLogEntry{}, nil
+	}
+	start := sort.Search(len(log.entries), func(i int) bool { return log.entries[i].Sequence > after })
+	result := make([]*model.ChangeLogEntry, 0)
+	for _, entry := range log.entries[start:] {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		copied := *entry
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+// GetState returns a copy of the sequence state of the database
+func (s *InMemoryChangeLogStore) GetState(ctx context.Context, projectID, databaseID string) (*model.ChangeLogState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	log, exists := s.logs[changeLogKey(organizationID, projectID, databaseID)]
+	if !exists {
+		return &model.ChangeLogState{OrganizationID: organizationID, ProjectID: projectID, DatabaseID: databaseID}, nil
+	}
+	state := log.state
+	return &state, nil
+}
+
+// Prune removes the entries recorded before cutoff
+func (s *InMemoryChangeLogStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, log := range s.logs {
+		kept := 0
+		for kept < len(log.entries) && log.entries[kept].RecordedAt.Before(cutoff) {
+			kept++
+		}
+		if kept == 0 {
+			continue
+		}
+		log.state.PrunedThrough = log.entries[kept-1].Sequence
+		log.entries = append([]*model.ChangeLogEntry(nil), log.entries[kept:]...)
+		removed += kept
+	}
+	return removed, nil
+}
+
+type changeFeedUsecase struct {
+	store  ChangeLogStore
+	config ChangeFeedConfig
+	logger logger.Logger
+
+	notifyMu sync.Mutex
+	notify   map[string]chan struct{} // organizationID/projectID/databaseID -> closed on the next append
+	recorded map[string]struct{}      // changelogs appended in transactions not yet reported as committed
+
+	worker *pollWorker
+}
+
+// NewChangeFeedUsecase creates a new change feed usecase
+func NewChangeFeedUsecase(store ChangeLogStore, config ChangeFeedConfig, log logger.Logger) ChangeFeedUsecase {
+	if store == nil {
+		store = NewInMemoryChangeLogStore()
+	}
+	defaults := DefaultChangeFeedConfig()
+	if config.Retention <= 0 {
+		config.Retention = defaults.Retention
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = defaults.MaxWait
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.PruneInterval <= 0 {
+		config.PruneInterval = defaults.PruneInterval
+	}
+	uc := &changeFeedUsecase{
+		store:    store,
+		config:   config,
+		logger:   log,
+		notify:   make(map[string]chan struct{}),
+		recorded: make(map[string]struct{}),
+	}
+	uc.worker = newPollWorker("Change feed retention worker", config.PruneInterval, 1, func(ctx context.Context) (int, error) {
+		_, err := uc.Prune(ctx)
+		return 0, err
+	}, log)
+	return uc
+}
+
+// ReadChanges implements ChangeFeedUsecase
+func (uc *changeFeedUsecase) ReadChanges(ctx context.Context, req ReadChangesRequest) (*model.ChangeFeedPage, error) {
+	if req.ProjectID == "" || req.DatabaseID == "" {
+		return nil, errors.NewValidationError("project ID and database ID are required")
+	}
+	since, err := model.ParseChangeFeedCursor(req.Since)
+	if err != nil {
+		return nil, errors.NewValidationError(err.Error()).WithCause(err)
+	}
+	if req.View == "" {
+		req.View = model.ChangeFeedViewFull
+	}
+	if !req.View.IsValid() {
+		return nil, errors.NewValidationError(fmt.Sprintf("unsupported change feed view %q", req.View))
+	}
+	switch {
+	case req.Limit < 0:
+		return nil, errors.NewValidationError("limit must not be negative")
+	case req.Limit == 0:
+		req.Limit = defaultChangeFeedLimit
+	case req.Limit > maxChangeFeedLimit:
+		req.Limit = maxChangeFeedLimit
+	}
+	if req.Wait > uc.config.MaxWait {
+		req.Wait = uc.config.MaxWait
+	}
+
+	deadline := time.Now().Add(req.Wait)
+	for {
+		// Register for notifications before reading so that no append is missed in between
+		notified := uc.subscribe(changeLogKey(utils.GetOrganizationIDOrDefault(ctx, ""), req.ProjectID, req.DatabaseID))
+		page, position, err := uc.readPage(ctx, req, since)
+		if err != nil || len(page.Changes) > 0 {
+			return page, err
+		}
+		since = position
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return page, nil
+		}
+		if remaining > uc.config.PollInterval {
+			remaining = uc.config.PollInterval
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return page, nil
+		case <-notified:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// readPage reads the changes after since matching the request, skipping the others, and
+// returns the page with the sequence it stopped at
+func (uc *changeFeedUsecase) readPage(ctx context.Context, req ReadChangesRequest, since int64) (*model.ChangeFeedPage, int64, error) {
+	state, err := uc.store.GetState(ctx, req.ProjectID, req.DatabaseID)
+	if err != nil {
+		return nil, since, err
+	}
+	if since < state.PrunedThrough {
+		if req.Since != "" {
+			return nil, since, fmt.Errorf("%w: changes after %d are past the retention period", model.ErrChangeFeedCursorExpired, since)
+		}
+		since = state.PrunedThrough
+	}
+
+	page := &model.ChangeFeedPage{Changes: make([]*model.ChangeFeedEntry, 0)}
+	position := since
+	// Filtered reads skip non-matching changes, up to a bounded number per request
+	for scanned := 0; len(page.Changes) < req.Limit && scanned < req.Limit*10; {
+		entries, err := uc.store.ReadAfter(ctx, req.ProjectID, req.DatabaseID, position, req.Limit)
+		if err != nil {
+			return nil, since, err
+		}
+		for _, entry := range entries {
+			if entry.Sequence != position+1 && time.Since(entry.RecordedAt) < changeLogGapTimeout {
+				// A preceding change is still being written; resume from here next time
+				page.NextCursor = model.FormatChangeFeedCursor(position)
+				return page, position, nil
+			}
+			position = entry.Sequence
+			if req.CollectionGroup == "" || entry.CollectionGroup == req.CollectionGroup {
+				page.Changes = append(page.Changes, model.NewChangeFeedEntry(entry, req.View))
+				if len(page.Changes) == req.Limit {
+					break
+				}
+			}
+		}
+		scanned += len(entries)
+		if len(entries) < req.Limit {
+			break
+		}
+	}
+	page.NextCursor = model.FormatChangeFeedCursor(position)
+	return page, position, nil
+}
+
+// Prune implements ChangeFeedUsecase
+func (uc *changeFeedUsecase) Prune(ctx context.Context) (int, error) {
+	removed, err := uc.store.Prune(ctx, time.Now().Add(-uc.config.Retention))
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune the changelog: %w", err)
+	}
+	if removed > 0 {
+		uc.logger.Info("Pruned expired changes from the changelog", "removed", removed, "retention", uc.config.Retention)
+	}
+	return removed, nil
+}
+
+// WatchesChanges implements DocumentChangeHandler; every database has a changelog
+func (uc *changeFeedUsecase) WatchesChanges(ctx context.Context, projectID, databaseID string) bool {
+	return true
+}
+
+// HandleChanges implements DocumentChangeHandler by appending the changes to the changelog
+// of their database once they have committed
+func (uc *changeFeedUsecase) HandleChanges(ctx context.Context, changes []*model.DocumentChange) {
+	for _, entries := range changeLogEntries(ctx, changes) {
+		if err := uc.store.Append(ctx, entries[0].ProjectID, entries[0].DatabaseID, entries); err != nil {
+			uc.logger.Error("Failed to append changes to the changelog",
+				"projectID", entries[0].ProjectID,
+				"databaseID", entries[0].DatabaseID,
+				"changes", len(entries),
+				"error", err)
+			continue
+		}
+		uc.broadcast(changeLogKey(entries[0].OrganizationID, entries[0].ProjectID, entries[0].DatabaseID))
+	}
+}
+
+// RecordChanges implements DocumentChangeRecorder by appending the changes to the changelog
+// of their database in the transaction of the write. The sequences are taken in that
+// transaction too, so concurrent writes commit their changes in sequence order.
+func (uc *changeFeedUsecase) RecordChanges(ctx context.Context, changes []*model.DocumentChange) error {
+	for _, entries := range changeLogEntries(ctx, changes) {
+		if err := uc.store.Append(ctx, entries[0].ProjectID, entries[0].DatabaseID, entries); err != nil {
+			return err
+		}
+		uc.notifyMu.Lock()
+		uc.recorded[changeLogKey(entries[0].OrganizationID, entries[0].ProjectID, entries[0].DatabaseID)] = struct{}{}
+		uc.notifyMu.Unlock()
+	}
+	return nil
+}
+
+// ChangesRecorded implements DocumentChangeRecorder by waking up the long polls of the
+// changelogs appended since the last commit. Those of a transaction still in progress are
+// woken up early and read again on their next poll.
+func (uc *changeFeedUsecase) ChangesRecorded(ctx context.Context) {
+	uc.notifyMu.Lock()
+	recorded := uc.recorded
+	uc.recorded = make(map[string]struct{})
+	uc.notifyMu.Unlock()
+	for key := range recorded {
+		uc.broadcast(key)
+	}
+}
+
+// changeLogEntries groups the changes by database, in order, as entries of the changelogs
+// of the organization in the context
+func changeLogEntries(ctx context.Context, changes []*model.DocumentChange) [][]*model.ChangeLogEntry {
+	now := time.Now()
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	byDatabase := make(map[string]int)
+	var groups [][]*model.ChangeLogEntry
+	for _, change := range changes {
+		key := changeLogKey(organizationID, change.ProjectID, change.DatabaseID)
+		index, exists := byDatabase[key]
+		if !exists {
+			index = len(groups)
+			byDatabase[key] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], &model.ChangeLogEntry{
+			OrganizationID:  organizationID,
+			ProjectID:       change.ProjectID,
+			DatabaseID:      change.DatabaseID,
+			CollectionGroup: collectionGroupOf(change.DocumentPath),
+			Change:          change,
+			RecordedAt:      now,
+		})
+	}
+	return groups
+}
+
+// collectionGroupOf returns the collection ID of a relative document path
+func collectionGroupOf(documentPath string) string {
+	segments := strings.Split(strings.Trim(documentPath, "/"), "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-2]
+}
+
+// subscribe returns a channel closed on the next append to the changelog with the key
+func (uc *changeFeedUsecase) subscribe(key string) <-chan struct{} {
+	uc.notifyMu.Lock()
+	defer uc.notifyMu.Unlock()
+	ch, exists := uc.notify[key]
+	if !exists {
+		ch = make(chan struct{})
+		uc.notify[key] = ch
+	}
+	return ch
+}
+
+// broadcast wakes up the long polls waiting on the database
+func (uc *changeFeedUsecase) broadcast(key string) {
+	uc.notifyMu.Lock()
+	defer uc.notifyMu.Unlock()
+	if ch, exists := uc.notify[key]; exists {
+		close(ch)
+		delete(uc.notify, key)
+	}
+}
+
+// Start implements ChangeFeedUsecase
+func (uc *changeFeedUsecase) Start(ctx context.Context) {
+	uc.worker.Start(ctx)
+}
+
+// Stop implements ChangeFeedUsecase
+func (uc *changeFeedUsecase) Stop() {
+	uc.worker.Stop()
+}
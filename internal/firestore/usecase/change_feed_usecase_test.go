@@ -0,0 +1,252 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChangeFeedTestSetup(store ChangeLogStore, config ChangeFeedConfig) (ChangeFeedUsecase, repository.FirestoreRepository) {
+	uc := NewChangeFeedUsecase(store, config, &MockLogger{})
+	return uc, NewDocumentChangeRepository(newMemDocRepo(), uc)
+}
+
+func TestChangeFeed_PagesThroughChangesInOrder(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newChangeFeedTestSetup(nil, DefaultChangeFeedConfig())
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "placed"}))
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "shipped"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "users/u1/orders", "o1"))
+	_, err = repo.CreateDocument(ctx, "p", "other", "users", "u2", fields(map[string]interface{}{"name": "Bob"}))
+	require.NoError(t, err)
+
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "users/u1/orders/o1", page.Changes[0].Document)
+	assert.Equal(t, model.DocumentChangeCreated, page.Changes[0].Kind)
+	assert.Equal(t, "users/u1", page.Changes[1].Document)
+	assert.Equal(t, page.Changes[1].Cursor, page.NextCursor)
+
+	page, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, model.DocumentChangeUpdated, page.Changes[0].Kind)
+	assert.Equal(t, "placed", page.Changes[0].Before.Fields["status"].Value)
+	assert.Equal(t, "shipped", page.Changes[0].After.Fields["status"].Value)
+	assert.Equal(t, model.DocumentChangeDeleted, page.Changes[1].Kind)
+	assert.Nil(t, page.Changes[1].After)
+
+	// Caught up: an empty page keeps the cursor
+	empty, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: page.NextCursor})
+	require.NoError(t, err)
+	assert.Empty(t, empty.Changes)
+	assert.Equal(t, page.NextCursor, empty.NextCursor)
+}
+
+func TestChangeFeed_CollectionGroupAndView(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newChangeFeedTestSetup(nil, DefaultChangeFeedConfig())
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "placed"}))
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "shops/s1/orders", "o2", fields(map[string]interface{}{"status": "placed"}))
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada L."}), nil)
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "shops/s1/orders", "o2", fields(map[string]interface{}{"status": "shipped"}), nil)
+	require.NoError(t, err)
+
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", CollectionGroup: "orders", View: model.ChangeFeedViewAfter})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 3)
+	assert.Equal(t, "users/u1/orders/o1", page.Changes[0].Document)
+	assert.Equal(t, "shops/s1/orders/o2", page.Changes[1].Document)
+	assert.Nil(t, page.Changes[2].Before, "the AFTER view leaves the before snapshot out")
+	assert.NotNil(t, page.Changes[2].After)
+	assert.Equal(t, "5", page.NextCursor)
+
+	_, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: "not-a-cursor"})
+	assert.Error(t, err)
+	_, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", View: "DIFF"})
+	assert.Error(t, err)
+}
+
+func TestChangeFeed_LongPollReturnsOnNewChanges(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newChangeFeedTestSetup(nil, ChangeFeedConfig{PollInterval: time.Hour})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	}()
+	started := time.Now()
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Wait: 5 * time.Second})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	// Without changes the long poll ends at the wait
+	started = time.Now()
+	page, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: page.NextCursor, Wait: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Empty(t, page.Changes)
+	assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+}
+
+func TestChangeFeed_RetentionExpiresOldCursors(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryChangeLogStore()
+	uc, repo := newChangeFeedTestSetup(store, ChangeFeedConfig{Retention: 30 * time.Millisecond})
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u2", fields(map[string]interface{}{"name": "Bob"}))
+	require.NoError(t, err)
+	time.Sleep(40 * time.Millisecond)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u3", fields(map[string]interface{}{"name": "Cy"}))
+	require.NoError(t, err)
+
+	removed, err := uc.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	_, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: "1"})
+	assert.True(t, errors.Is(err, model.ErrChangeFeedCursorExpired))
+
+	// Reading from the start or from the pruned cursor returns the retained changes
+	for _, since := range []string{"", "2"} {
+		page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: since})
+		require.NoError(t, err)
+		require.Len(t, page.Changes, 1)
+		assert.Equal(t, "users/u3", page.Changes[0].Document)
+		assert.Equal(t, "3", page.NextCursor)
+	}
+}
+
+// gapChangeLogStore hides a sequence of the changelog, like an append still in flight
+type gapChangeLogStore struct {
+	ChangeLogStore
+	hidden int64
+}
+
+func (s *gapChangeLogStore) ReadAfter(ctx context.Context, projectID, databaseID string, after int64, limit int) ([]*model.ChangeLogEntry, error) {
+	entries, err := s.ChangeLogStore.ReadAfter(ctx, projectID, databaseID, after, limit)
+	visible := make([]*model.ChangeLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Sequence != s.hidden {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, err
+}
+
+func TestChangeFeed_WaitsForSequencesInFlight(t *testing.T) {
+	ctx := context.Background()
+	store := &gapChangeLogStore{ChangeLogStore: NewInMemoryChangeLogStore(), hidden: 2}
+	uc := NewChangeFeedUsecase(store, DefaultChangeFeedConfig(), &MockLogger{})
+
+	appendAt := func(path string, recordedAt time.Time) {
+		entry := &model.ChangeLogEntry{ProjectID: "p", DatabaseID: "d", Change: &model.DocumentChange{DocumentPath: path, After: &model.Document{}}, RecordedAt: recordedAt}
+		require.NoError(t, store.Append(ctx, "p", "d", []*model.ChangeLogEntry{entry}))
+	}
+	appendAt("users/u1", time.Now())
+	appendAt("users/u2", time.Now())
+	appendAt("users/u3", time.Now())
+
+	// A young gap stops the page before it, so the missing change is not skipped
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d"})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "1", page.NextCursor)
+
+	// A gap older than the timeout is a failed append and is skipped
+	store.hidden = 5
+	appendAt("users/u4", time.Now().Add(-time.Minute))
+	appendAt("users/u5", time.Now().Add(-time.Minute))
+	appendAt("users/u6", time.Now().Add(-time.Minute))
+	page, err = uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Since: "3"})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 2)
+	assert.Equal(t, "users/u6", page.Changes[1].Document)
+	assert.Equal(t, "6", page.NextCursor)
+}
+
+// unwritableChangeLogStore cannot append to the changelog
+type unwritableChangeLogStore struct {
+	ChangeLogStore
+}
+
+func (s *unwritableChangeLogStore) Append(ctx context.Context, projectID, databaseID string, entries []*model.ChangeLogEntry) error {
+	return errors.New("changelog unavailable")
+}
+
+func TestChangeFeed_ChangesAreRecordedWithTheWrite(t *testing.T) {
+	ctx := context.Background()
+	uc := NewChangeFeedUsecase(nil, DefaultChangeFeedConfig(), &MockLogger{})
+	scope := &countingCommitScope{}
+	repo := NewDocumentChangeRepositoryWithCommitScope(newMemDocRepo(), scope, uc)
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, scope.writes)
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d"})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+
+	// A change that cannot be recorded fails the write, so the transaction is rolled back
+	failing := NewChangeFeedUsecase(&unwritableChangeLogStore{ChangeLogStore: NewInMemoryChangeLogStore()}, DefaultChangeFeedConfig(), &MockLogger{})
+	repo = NewDocumentChangeRepositoryWithCommitScope(newMemDocRepo(), scope, failing)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	assert.ErrorContains(t, err, "changelog unavailable")
+}
+
+func TestChangeFeed_LongPollWakesUpOnRecordedChanges(t *testing.T) {
+	ctx := context.Background()
+	uc := NewChangeFeedUsecase(nil, ChangeFeedConfig{PollInterval: time.Hour}, &MockLogger{})
+	repo := NewDocumentChangeRepositoryWithCommitScope(newMemDocRepo(), &countingCommitScope{}, uc)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	}()
+	started := time.Now()
+	page, err := uc.ReadChanges(ctx, ReadChangesRequest{ProjectID: "p", DatabaseID: "d", Wait: 5 * time.Second})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
+
+func TestChangeFeed_ChangelogsBelongToTheirOrganization(t *testing.T) {
+	uc, repo := newChangeFeedTestSetup(nil, DefaultChangeFeedConfig())
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+
+	// Both organizations have a project "p"; only org-a writes to it
+	_, err := repo.CreateDocument(orgA, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	page, err := uc.ReadChanges(orgB, ReadChangesRequest{ProjectID: "p", DatabaseID: "d"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Changes)
+	page, err = uc.ReadChanges(orgA, ReadChangesRequest{ProjectID: "p", DatabaseID: "d"})
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "users/u1", page.Changes[0].Document)
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/utils"
+)
+
+// changeLogFollowPageSize is the number of changelog entries read per page when an index catches up
+const changeLogFollowPageSize = 500
+
+// changeLogFollower keeps in-memory indexes in step with the durable changelog of their
+// database. Writes handled by other instances only reach an index through the changelog,
+// so an index catches up before it answers a query. Without a changelog store every
+// method is a no-op and indexes only follow the writes of their own instance.
+type changeLogFollower struct {
+	store ChangeLogStore
+
+	mu      sync.Mutex
+	applied map[string]int64 // index key -> last changelog sequence applied to the index
+}
+
+func newChangeLogFollower(store ChangeLogStore) *changeLogFollower {
+	return &changeLogFollower{store: store, applied: make(map[string]int64)}
+}
+
+// head returns the last sequence of the changelog of a database of the organization. An
+// index built from storage after reading the head holds every change up to it.
+func (f *changeLogFollower) head(ctx context.Context, organizationID, projectID, databaseID string) (int64, error) {
+	if f.store == nil {
+		return 0, nil
+	}
+	state, err := f.store.GetState(utils.WithOrganizationID(ctx, organizationID), projectID, databaseID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the changelog state: %w", err)
+	}
+	return state.LastSequence, nil
+}
+
+// markBuilt records that an index holds every change up to sequence
+func (f *changeLogFollower) markBuilt(key string, sequence int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied[key] = sequence
+}
+
+// forget drops the position of an index that was removed
+func (f *changeLogFollower) forget(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.applied, key)
+}
+
+// catchUp passes to apply, in commit order, the changes of the collection recorded by the
+// organization after the last sequence applied to the index. It stops before a young gap in
+// the sequences, a write still in flight, and resumes from there on the next call. It returns
+// model.ErrChangeFeedCursorExpired when some of those changes were pruned, and the index has
+// to be rebuilt from storage.
+func (f *changeLogFollower) catchUp(ctx context.Context, key, organizationID, projectID, databaseID, collectionID string, apply func(documentID string, after *model.Document)) error {
+	if f.store == nil {
+		return nil
+	}
+	f.mu.Lock()
+	after, known := f.applied[key]
+	f.mu.Unlock()
+	if !known {
+		return model.ErrChangeFeedCursorExpired
+	}
+
+	ctx = utils.WithOrganizationID(ctx, organizationID)
+	state, err := f.store.GetState(ctx, projectID, databaseID)
+	if err != nil {
+		return fmt.Errorf("failed to read the changelog state: %w", err)
+	}
+	if state.LastSequence <= after {
+		return nil
+	}
+	if state.PrunedThrough > after {
+		return model.ErrChangeFeedCursorExpired
+	}
+
+	for after < state.LastSequence {
+		entries, err := f.store.ReadAfter(ctx, projectID, databaseID, after, changeLogFollowPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to read the changelog: %w", err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			if entry.Sequence != after+1 && time.Since(entry.RecordedAt) < changeLogGapTimeout {
+				// A preceding change is still being written; resume from here next time
+				f.markBuilt(key, after)
+				return nil
+			}
+			after = entry.Sequence
+			if entry.Change == nil || entry.OrganizationID != organizationID {
+				continue
+			}
+			separator := strings.LastIndex(entry.Change.DocumentPath, "/")
+			if separator < 0 || entry.Change.DocumentPath[:separator] != collectionID {
+				continue
+			}
+			apply(entry.Change.DocumentPath[separator+1:], entry.Change.After)
+		}
+		f.markBuilt(key, after)
+		if len(entries) < changeLogFollowPageSize {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/service"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/google/uuid"
+)
+
+// CollectionSchemaUsecase defines the primary port for collection data contracts
+type CollectionSchemaUsecase interface {
+	// SetSchema creates or replaces the schema attached to a collection pattern
+	SetSchema(ctx context.Context, req SetCollectionSchemaRequest) (*model.CollectionSchema, error)
+	GetSchema(ctx context.Context, projectID, databaseID, schemaID string) (*model.CollectionSchema, error)
+	ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error)
+	DeleteSchema(ctx context.Context, projectID, databaseID, schemaID string) error
+
+	// ValidateWrite checks the resulting document fields of a write against the most specific
+	// schema matching the collection path. Only enforced violations are returned as errors.
+	ValidateWrite(ctx context.Context, projectID, databaseID, collectionPath string, fields map[string]*model.FieldValue) error
+	GetMetrics() CollectionSchemaMetrics
+}
+
+// CollectionSchemaMetrics counts schema evaluations on writes
+type CollectionSchemaMetrics struct {
+	WritesValidated  int64 `json:"writes_validated"`
+	WritesRejected   int64 `json:"writes_rejected"`
+	ViolationsWarned int64 `json:"violations_warned"`
+}
+
+// CollectionSchemaStore defines the secondary port for schema persistence
+type CollectionSchemaStore interface {
+	SaveSchema(ctx context.Context, schema *model.CollectionSchema) error
+	GetSchema(ctx context.Context, projectID, databaseID, schemaID string) (*model.CollectionSchema, error)
+	ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error)
+	DeleteSchema(ctx context.Context, projectID, databaseID, schemaID string) error
+}
+
+// InMemoryCollectionSchemaStore implements CollectionSchemaStore with in-memory storage
+type InMemoryCollectionSchemaStore struct {
+	schemas map[string]*model.CollectionSchema // projectID/databaseID/schemaID -> schema
+	mu      sync.RWMutex
+}
+
+// NewInMemoryCollectionSchemaStore creates a new in-memory schema store
+func NewInMemoryCollectionSchemaStore() CollectionSchemaStore {
+	return &InMemoryCollectionSchemaStore{
+		schemas: make(map[string]*model.CollectionSchema),
+	}
+}
+
+func schemaStoreKey(projectID, databaseID, schemaID string) string {
+	return projectID + "/" + databaseID + "/" + schemaID
+}
+
+// SaveSchema stores a copy of the schema
+func (s *InMemoryCollectionSchemaStore) SaveSchema(ctx context.Context, schema *model.CollectionSchema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *schema
+	s.schemas[schemaStoreKey(schema.ProjectID, schema.DatabaseID, schema.SchemaID)] = &stored
+	return nil
+}
+
+// GetSchema returns a copy of the stored schema
+func (s *InMemoryCollectionSchemaStore) GetSchema(ctx context.Context, projectID, databaseID, schemaID string) (*model.CollectionSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schema, exists := s.schemas[schemaStoreKey(projectID, databaseID, schemaID)]
+	if !exists {
+		return nil, errors.NewNotFoundError("collection schema")
+	}
+	result := *schema
+	return &result, nil
+}
+
+// ListSchemas returns copies of all schemas of a database ordered by pattern
+func (s *InMemoryCollectionSchemaStore) ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := schemaStoreKey(projectID, databaseID, "")
+	result := make([]*model.CollectionSchema, 0)
+	for key, schema := range s.schemas {
+		if strings.HasPrefix(key, prefix) {
+			copied := *schema
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CollectionPattern < result[j].CollectionPattern
+	})
+	return result, nil
+}
+
+// DeleteSchema removes a stored schema
+func (s *InMemoryCollectionSchemaStore) DeleteSchema(ctx context.Context, projectID, databaseID, schemaID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := schemaStoreKey(projectID, databaseID, schemaID)
+	if _, exists := s.schemas[key]; !exists {
+		return errors.NewNotFoundError("collection schema")
+	}
+	delete(s.schemas, key)
+	return nil
+}
+
+// schemaCacheTTL bounds how long a schema change made through another server instance
+// takes to be enforced by this one; changes made here are enforced immediately
+const schemaCacheTTL = 30 * time.Second
+
+// cachedSchemas are the schemas of a database as loaded from the store, with the schema
+// resolved for each collection path written since
+type cachedSchemas struct {
+	schemas     []*model.CollectionSchema
+	loadedAt    time.Time
+	collections sync.Map // collection path -> *model.CollectionSchema, nil without a schema
+}
+
+// collectionSchemaUsecase implements CollectionSchemaUsecase
+type collectionSchemaUsecase struct {
+	store     CollectionSchemaStore
+	validator service.SchemaValidationService
+	logger    logger.Logger
+
+	// cache holds the schemas of each database by organization, so writes do not read the
+	// store every time
+	cache sync.Map // schemaCacheKey -> *cachedSchemas
+	// invalidations counts schema changes, so schemas loaded while one was saved are not cached
+	invalidations atomic.Int64
+
+	writesValidated  atomic.Int64
+	writesRejected   atomic.Int64
+	violationsWarned atomic.Int64
+}
+
+// NewCollectionSchemaUsecase creates a new collection schema usecase
+func NewCollectionSchemaUsecase(store CollectionSchemaStore, validator service.SchemaValidationService, log logger.Logger) CollectionSchemaUsecase {
+	if store == nil {
+		store = NewInMemoryCollectionSchemaStore()
+	}
+	if validator == nil {
+		validator = service.NewSchemaValidationService()
+	}
+	return &collectionSchemaUsecase{
+		store:     store,
+		validator: validator,
+		logger:    log,
+	}
+}
+
+// SetSchema implements CollectionSchemaUsecase. A schema for an already configured pattern replaces it.
+func (uc *collectionSchemaUsecase) SetSchema(ctx context.Context, req SetCollectionSchemaRequest) (*model.CollectionSchema, error) {
+	if req.ProjectID == "" || req.DatabaseID == "" {
+		return nil, errors.NewValidationError("project ID and database ID are required")
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = model.SchemaModeEnforce
+	}
+	schema := &model.CollectionSchema{
+		ProjectID:         req.ProjectID,
+		DatabaseID:        req.DatabaseID,
+		CollectionPattern: strings.Trim(req.CollectionPattern, "/"),
+		Mode:              mode,
+		Schema:            req.Schema,
+		Description:       req.Description,
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, errors.NewValidationError(err.Error()).WithCause(err)
+	}
+
+	existing, err := uc.store.ListSchemas(ctx, req.ProjectID, req.DatabaseID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	schema.SchemaID = uuid.New().String()
+	schema.CreatedAt = now
+	for _, current := range existing {
+		if current.CollectionPattern == schema.CollectionPattern {
+			schema.SchemaID = current.SchemaID
+			schema.CreatedAt = current.CreatedAt
+			break
+		}
+	}
+	schema.UpdatedAt = now
+
+	if err := uc.store.SaveSchema(ctx, schema); err != nil {
+		return nil, err
+	}
+	uc.invalidate(ctx, req.ProjectID, req.DatabaseID)
+	uc.logger.Info("Collection schema saved",
+		"projectID", schema.ProjectID,
+		"databaseID", schema.DatabaseID,
+		"pattern", schema.CollectionPattern,
+		"mode", schema.Mode)
+	return schema, nil
+}
+
+// GetSchema implements CollectionSchemaUsecase
+func (uc *collectionSchemaUsecase) GetSchema(ctx context.Context, projectID, databaseID, schemaID string) (*model.CollectionSchema, error) {
+	return uc.store.GetSchema(ctx, projectID, databaseID, schemaID)
+}
+
+// ListSchemas implements CollectionSchemaUsecase
+func (uc *collectionSchemaUsecase) ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error) {
+	return uc.store.ListSchemas(ctx, projectID, databaseID)
+}
+
+// DeleteSchema implements CollectionSchemaUsecase
+func (uc *collectionSchemaUsecase) DeleteSchema(ctx context.Context, projectID, databaseID, schemaID string) error {
+	if err := uc.store.DeleteSchema(ctx, projectID, databaseID, schemaID); err != nil {
+		return err
+	}
+	uc.invalidate(ctx, projectID, databaseID)
+	uc.logger.Info("Collection schema deleted", "projectID", projectID, "databaseID", databaseID, "schemaID", schemaID)
+	return nil
+}
+
+// ValidateWrite implements CollectionSchemaUsecase
+func (uc *collectionSchemaUsecase) ValidateWrite(ctx context.Context, projectID, databaseID, collectionPath string, fields map[string]*model.FieldValue) error {
+	schema, err := uc.resolveSchema(ctx, projectID, databaseID, collectionPath)
+	if err != nil || schema == nil || schema.Mode == model.SchemaModeOff {
+		return err
+	}
+
+	uc.writesValidated.Add(1)
+	violations := uc.validator.ValidateDocument(schema.Schema, fields)
+	if !violations.HasErrors() {
+		return nil
+	}
+
+	if schema.Mode == model.SchemaModeWarn {
+		uc.violationsWarned.Add(1)
+		uc.logger.Warn("Write violates collection schema",
+			"projectID", projectID,
+			"databaseID", databaseID,
+			"collection", collectionPath,
+			"pattern", schema.CollectionPattern,
+			"violations", violations.Errors)
+		return nil
+	}
+
+	uc.writesRejected.Add(1)
+	appErr := violations.ToAppError()
+	appErr.Code = "INVALID_ARGUMENT"
+	appErr.Message = "document does not match the schema of collection " + collectionPath
+	return appErr
+}
+
+// GetMetrics implements CollectionSchemaUsecase
+func (uc *collectionSchemaUsecase) GetMetrics() CollectionSchemaMetrics {
+	return CollectionSchemaMetrics{
+		WritesValidated:  uc.writesValidated.Load(),
+		WritesRejected:   uc.writesRejected.Load(),
+		ViolationsWarned: uc.violationsWarned.Load(),
+	}
+}
+
+// resolveSchema returns the most specific schema matching the collection path, or nil
+func (uc *collectionSchemaUsecase) resolveSchema(ctx context.Context, projectID, databaseID, collectionPath string) (*model.CollectionSchema, error) {
+	cached, err := uc.databaseSchemas(ctx, projectID, databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, ok := cached.collections.Load(collectionPath); ok {
+		return resolved.(*model.CollectionSchema), nil
+	}
+	best := mostSpecificSchema(cached.schemas, collectionPath)
+	cached.collections.Store(collectionPath, best)
+	return best, nil
+}
+
+// databaseSchemas returns the cached schemas of a database, loading them from the store
+// when they are missing or older than schemaCacheTTL
+func (uc *collectionSchemaUsecase) databaseSchemas(ctx context.Context, projectID, databaseID string) (*cachedSchemas, error) {
+	key := schemaCacheKey(ctx, projectID, databaseID)
+	if value, ok := uc.cache.Load(key); ok {
+		if cached := value.(*cachedSchemas); time.Since(cached.loadedAt) < schemaCacheTTL {
+			return cached, nil
+		}
+	}
+	invalidations := uc.invalidations.Load()
+	schemas, err := uc.store.ListSchemas(ctx, projectID, databaseID)
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedSchemas{schemas: schemas, loadedAt: time.Now()}
+	if uc.invalidations.Load() == invalidations {
+		uc.cache.Store(key, cached)
+	}
+	return cached, nil
+}
+
+// invalidate forgets the cached schemas of a database after one of them changed
+func (uc *collectionSchemaUsecase) invalidate(ctx context.Context, projectID, databaseID string) {
+	uc.invalidations.Add(1)
+	uc.cache.Delete(schemaCacheKey(ctx, projectID, databaseID))
+}
+
+// schemaCacheKey identifies the schemas of a database; the store keeps them in the
+// database of the organization in the context
+func schemaCacheKey(ctx context.Context, projectID, databaseID string) string {
+	return utils.GetOrganizationIDOrDefault(ctx, "") + "/" + schemaStoreKey(projectID, databaseID, "")
+}
+
+// mostSpecificSchema returns the most specific schema matching the collection path, or nil
+func mostSpecificSchema(schemas []*model.CollectionSchema, collectionPath string) *model.CollectionSchema {
+	var best *model.CollectionSchema
+	for _, schema := range schemas {
+		if !schema.Matches(collectionPath) {
+			continue
+		}
+		if best == nil || schema.Specificity() > best.Specificity() {
+			best = schema
+		}
+	}
+	return best
+}
+
+// IsSchemaViolation reports whether err (possibly wrapped) is a rejected collection schema write
+func IsSchemaViolation(err error) (*errors.AppError, bool) {
+	var appErr *errors.AppError
+	if stderrors.As(err, &appErr) && appErr.Type == errors.ErrorTypeValidation {
+		if _, ok := appErr.Details["validation_errors"]; ok {
+			return appErr, true
+		}
+	}
+	return nil, false
+}
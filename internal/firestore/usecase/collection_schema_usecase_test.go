@@ -0,0 +1,246 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRecorderRepo records writes that reach storage and serves a single existing document
+type writeRecorderRepo struct {
+	repository.FirestoreRepository
+	existing *model.Document
+	readErr  error
+	writes   int
+}
+
+func (r *writeRecorderRepo) GetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) (*model.Document, error) {
+	if r.readErr != nil {
+		return nil, r.readErr
+	}
+	if r.existing == nil {
+		return nil, errors.NewNotFoundError("document")
+	}
+	return r.existing, nil
+}
+
+func (r *writeRecorderRepo) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
+	r.writes++
+	return &model.Document{DocumentID: documentID, Fields: data}, nil
+}
+
+func (r *writeRecorderRepo) UpdateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	r.writes++
+	return &model.Document{DocumentID: documentID, Fields: data}, nil
+}
+
+func (r *writeRecorderRepo) SetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) (*model.Document, error) {
+	r.writes++
+	return &model.Document{DocumentID: documentID, Fields: data}, nil
+}
+
+func (r *writeRecorderRepo) RunBatchWrite(ctx context.Context, projectID, databaseID string, writes []*model.WriteOperation) ([]*model.WriteResult, error) {
+	r.writes += len(writes)
+	return make([]*model.WriteResult, len(writes)), nil
+}
+
+func userSchema() *model.FieldSchema {
+	return &model.FieldSchema{
+		Type:     model.SchemaTypes{model.SchemaTypeObject},
+		Required: []string{"name"},
+		Properties: map[string]*model.FieldSchema{
+			"name": {Type: model.SchemaTypes{model.SchemaTypeString}},
+			"age":  {Type: model.SchemaTypes{model.SchemaTypeInteger}},
+		},
+	}
+}
+
+func fields(data map[string]interface{}) map[string]*model.FieldValue {
+	result := make(map[string]*model.FieldValue, len(data))
+	for k, v := range data {
+		result[k] = model.NewFieldValue(v)
+	}
+	return result
+}
+
+func newSchemaTestSetup(t *testing.T, mode model.SchemaMode) (CollectionSchemaUsecase, *writeRecorderRepo, repository.FirestoreRepository) {
+	t.Helper()
+	schemaUC := NewCollectionSchemaUsecase(nil, nil, &MockLogger{})
+	_, err := schemaUC.SetSchema(context.Background(), SetCollectionSchemaRequest{
+		ProjectID:         "p",
+		DatabaseID:        "d",
+		CollectionPattern: "users",
+		Mode:              mode,
+		Schema:            userSchema(),
+	})
+	require.NoError(t, err)
+	inner := &writeRecorderRepo{}
+	return schemaUC, inner, NewSchemaValidatingRepository(inner, schemaUC)
+}
+
+func TestCollectionSchema_EnforceRejectsWrite(t *testing.T) {
+	schemaUC, inner, repo := newSchemaTestSetup(t, model.SchemaModeEnforce)
+
+	_, err := repo.CreateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": "old"}))
+	require.Error(t, err)
+	appErr, ok := IsSchemaViolation(err)
+	require.True(t, ok)
+	assert.Equal(t, "INVALID_ARGUMENT", appErr.Code)
+	violations := appErr.Details["validation_errors"].([]errors.ValidationError)
+	assert.Len(t, violations, 2)
+	assert.Equal(t, 0, inner.writes)
+
+	_, err = repo.CreateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada", "age": 36}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.writes)
+
+	// Collections without a matching schema are not validated
+	_, err = repo.CreateDocument(context.Background(), "p", "d", "orders", "o1", fields(map[string]interface{}{"age": "old"}))
+	require.NoError(t, err)
+
+	metrics := schemaUC.GetMetrics()
+	assert.Equal(t, int64(2), metrics.WritesValidated)
+	assert.Equal(t, int64(1), metrics.WritesRejected)
+}
+
+func TestCollectionSchema_WarnAndOffModes(t *testing.T) {
+	schemaUC, inner, repo := newSchemaTestSetup(t, model.SchemaModeWarn)
+
+	_, err := repo.SetDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": "old"}), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.writes)
+	assert.Equal(t, int64(1), schemaUC.GetMetrics().ViolationsWarned)
+
+	schemaUC, inner, repo = newSchemaTestSetup(t, model.SchemaModeOff)
+	_, err = repo.SetDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": "old"}), false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.writes)
+	assert.Equal(t, int64(0), schemaUC.GetMetrics().WritesValidated)
+}
+
+func TestCollectionSchema_UpdateValidatedAfterMask(t *testing.T) {
+	_, inner, repo := newSchemaTestSetup(t, model.SchemaModeEnforce)
+	inner.existing = &model.Document{Fields: fields(map[string]interface{}{"name": "Ada"})}
+
+	// The stored name satisfies the required field once the mask is applied
+	_, err := repo.UpdateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": 37}), []string{"age"})
+	require.NoError(t, err)
+
+	// Masked paths missing from the data are removed, dropping the required field
+	_, err = repo.UpdateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": 37}), []string{"age", "name"})
+	_, ok := IsSchemaViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, inner.writes)
+}
+
+func TestCollectionSchema_UpdateFailsWhenTheStoredDocumentCannotBeRead(t *testing.T) {
+	_, inner, repo := newSchemaTestSetup(t, model.SchemaModeEnforce)
+	inner.readErr = errors.NewInternalError("storage unavailable")
+
+	_, err := repo.UpdateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": "old"}), []string{"age"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage unavailable")
+	_, err = repo.SetDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": "old"}), true)
+	require.Error(t, err)
+	assert.Equal(t, 0, inner.writes)
+
+	// Missing documents still reach storage, which reports them
+	inner.readErr = nil
+	_, err = repo.UpdateDocument(context.Background(), "p", "d", "users", "u1", fields(map[string]interface{}{"age": 37}), []string{"age"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.writes)
+}
+
+// countingSchemaStore counts how many times the schemas of a database are loaded
+type countingSchemaStore struct {
+	CollectionSchemaStore
+	lists int
+}
+
+func (s *countingSchemaStore) ListSchemas(ctx context.Context, projectID, databaseID string) ([]*model.CollectionSchema, error) {
+	s.lists++
+	return s.CollectionSchemaStore.ListSchemas(ctx, projectID, databaseID)
+}
+
+func TestCollectionSchema_SchemasAreCachedUntilChanged(t *testing.T) {
+	store := &countingSchemaStore{CollectionSchemaStore: NewInMemoryCollectionSchemaStore()}
+	schemaUC := NewCollectionSchemaUsecase(store, nil, &MockLogger{})
+	ctx := context.Background()
+
+	created, err := schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionPattern: "users", Schema: userSchema(),
+	})
+	require.NoError(t, err)
+
+	lists := store.lists
+	for i := 0; i < 3; i++ {
+		assert.Error(t, schemaUC.ValidateWrite(ctx, "p", "d", "users", fields(map[string]interface{}{})))
+	}
+	assert.Equal(t, lists+1, store.lists)
+
+	// Changing the schemas of the database invalidates the cache
+	_, err = schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionPattern: "users", Mode: model.SchemaModeOff, Schema: userSchema(),
+	})
+	require.NoError(t, err)
+	assert.NoError(t, schemaUC.ValidateWrite(ctx, "p", "d", "users", fields(map[string]interface{}{})))
+
+	schemas, err := schemaUC.ListSchemas(ctx, "p", "d")
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+	assert.Equal(t, created.CollectionPattern, schemas[0].CollectionPattern)
+	require.NoError(t, schemaUC.DeleteSchema(ctx, "p", "d", schemas[0].SchemaID))
+	lists = store.lists
+	assert.NoError(t, schemaUC.ValidateWrite(ctx, "p", "d", "users", fields(map[string]interface{}{})))
+	assert.Equal(t, lists+1, store.lists)
+}
+
+func TestCollectionSchema_BatchWriteRejectedAsAWhole(t *testing.T) {
+	_, inner, repo := newSchemaTestSetup(t, model.SchemaModeEnforce)
+
+	_, err := repo.RunBatchWrite(context.Background(), "p", "d", []*model.WriteOperation{
+		{Type: model.WriteTypeCreate, Path: "projects/p/databases/d/documents/users/u1", Data: map[string]interface{}{"name": "Ada"}},
+		{Type: model.WriteTypeSet, Path: "users/u2", Data: map[string]interface{}{"name": 42.0}},
+		{Type: model.WriteTypeDelete, Path: "users/u3"},
+	})
+	_, ok := IsSchemaViolation(err)
+	assert.True(t, ok)
+	assert.Equal(t, 0, inner.writes)
+}
+
+func TestCollectionSchema_MostSpecificSchemaWins(t *testing.T) {
+	schemaUC := NewCollectionSchemaUsecase(nil, nil, &MockLogger{})
+	ctx := context.Background()
+
+	_, err := schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionPattern: "{path=**}/posts", Schema: userSchema(),
+	})
+	require.NoError(t, err)
+	_, err = schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionPattern: "users/{userId}/posts", Mode: model.SchemaModeOff, Schema: userSchema(),
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, schemaUC.ValidateWrite(ctx, "p", "d", "users/u1/posts", fields(map[string]interface{}{})))
+	assert.Error(t, schemaUC.ValidateWrite(ctx, "p", "d", "groups/g1/posts", fields(map[string]interface{}{})))
+
+	// Setting a schema for an existing pattern replaces it
+	replaced, err := schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionPattern: "/users/{userId}/posts/", Schema: userSchema(),
+	})
+	require.NoError(t, err)
+	schemas, err := schemaUC.ListSchemas(ctx, "p", "d")
+	require.NoError(t, err)
+	assert.Len(t, schemas, 2)
+	assert.Equal(t, model.SchemaModeEnforce, replaced.Mode)
+
+	_, err = schemaUC.SetSchema(ctx, SetCollectionSchemaRequest{ProjectID: "p", DatabaseID: "d", CollectionPattern: "users"})
+	assert.True(t, errors.IsValidation(err))
+}
@@ -0,0 +1,459 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/shared/utils"
+)
+
+// DocumentChangeHandler receives the document changes committed through the document
+// change repository, such as the webhook triggers
+type DocumentChangeHandler interface {
+	// WatchesChanges reports whether the changes of a database are handled. Snapshots are
+	// only read around the writes of watched databases.
+	WatchesChanges(ctx context.Context, projectID, databaseID string) bool
+	HandleChanges(ctx context.Context, changes []*model.DocumentChange)
+}
+
+// DocumentChangeRecorder is a DocumentChangeHandler that queues the changes durably, such
+// as the webhook triggers and the document event outbox. Under a commit scope the changes
+// are recorded in the storage transaction of the write instead of being handled after it.
+type DocumentChangeRecorder interface {
+	DocumentChangeHandler
+	// RecordChanges queues the changes with ctx, the context of the write transaction.
+	// An error aborts the write.
+	RecordChanges(ctx context.Context, changes []*model.DocumentChange) error
+	// ChangesRecorded is called once the transaction recording changes has committed
+	ChangesRecorded(ctx context.Context)
+}
+
+// CommitScope defines the secondary port running a write and the records that go with it
+// in one storage transaction
+type CommitScope interface {
+	// Atomically runs fn; the storage operations made with the context passed to fn commit
+	// together or not at all. fn may run again when the transaction is retried.
+	Atomically(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// documentChangeRepository decorates a FirestoreRepository so that every successful write
+// is reported as a DocumentChange with the document snapshots before and after the write.
+// Both snapshots are read from storage, so update masks, merges and transforms are
+// reported exactly as stored. Writes leaving a document missing before and after, such as
+// the delete of a missing document, are not reported.
+type documentChangeRepository struct {
+	repository.FirestoreRepository
+	handlers  []DocumentChangeHandler
+	recorders []DocumentChangeRecorder
+	scope     CommitScope
+}
+
+// NewDocumentChangeRepository wraps repo with the reporting of document changes to handlers
+// once the writes have committed
+func NewDocumentChangeRepository(repo repository.FirestoreRepository, handlers ...DocumentChangeHandler) repository.FirestoreRepository {
+	return NewDocumentChangeRepositoryWithCommitScope(repo, nil, handlers...)
+}
+
+// NewDocumentChangeRepositoryWithCommitScope wraps repo with the reporting of document
+// changes to handlers; the handlers that are recorders record them in the transaction of
+// the write run by scope
+func NewDocumentChangeRepositoryWithCommitScope(repo repository.FirestoreRepository, scope CommitScope, handlers ...DocumentChangeHandler) repository.FirestoreRepository {
+	r := &documentChangeRepository{FirestoreRepository: repo, handlers: handlers, scope: scope}
+	for _, handler := range handlers {
+		if recorder, ok := handler.(DocumentChangeRecorder); ok {
+			r.recorders = append(r.recorders, recorder)
+		}
+	}
+	return r
+}
+
+// pendingChange is a write in progress with the snapshot taken before it
+type pendingChange struct {
+	projectID, databaseID, collectionPath, documentID string
+	path                                              string // Set for path-based writes
+	before                                            *model.Document
+}
+
+// CreateDocument reports the created document
+func (r *documentChangeRepository) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	var doc *model.Document
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		doc, err = r.FirestoreRepository.CreateDocument(ctx, projectID, databaseID, collectionID, documentID, data)
+		if err == nil && doc != nil && pending != nil {
+			// Generated IDs are only known once the document is created
+			pending.documentID = doc.DocumentID
+		}
+		return err
+	}, pending)
+	return doc, err
+}
+
+// UpdateDocument reports the updated document
+func (r *documentChangeRepository) UpdateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	var doc *model.Document
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		doc, err = r.FirestoreRepository.UpdateDocument(ctx, projectID, databaseID, collectionID, documentID, data, updateMask)
+		return err
+	}, pending)
+	return doc, err
+}
+
+// SetDocument reports the written document
+func (r *documentChangeRepository) SetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) (*model.Document, error) {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	var doc *model.Document
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		doc, err = r.FirestoreRepository.SetDocument(ctx, projectID, databaseID, collectionID, documentID, data, merge)
+		return err
+	}, pending)
+	return doc, err
+}
+
+// DeleteDocument reports the deleted document
+func (r *documentChangeRepository) DeleteDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) error {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.DeleteDocument(ctx, projectID, databaseID, collectionID, documentID)
+	}, pending)
+}
+
+// CreateDocumentByPath reports the created document
+func (r *documentChangeRepository) CreateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue) (*model.Document, error) {
+	pending := r.captureByPath(ctx, path)
+	var doc *model.Document
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		doc, err = r.FirestoreRepository.CreateDocumentByPath(ctx, path, data)
+		return err
+	}, pending)
+	return doc, err
+}
+
+// UpdateDocumentByPath reports the updated document
+func (r *documentChangeRepository) UpdateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	pending := r.captureByPath(ctx, path)
+	var doc *model.Document
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		doc, err = r.FirestoreRepository.UpdateDocumentByPath(ctx, path, data, updateMask)
+		return err
+	}, pending)
+	return doc, err
+}
+
+// DeleteDocumentByPath reports the deleted document
+func (r *documentChangeRepository) DeleteDocumentByPath(ctx context.Context, path string) error {
+	pending := r.captureByPath(ctx, path)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.DeleteDocumentByPath(ctx, path)
+	}, pending)
+}
+
+// RunBatchWrite reports every document changed by the batch together
+func (r *documentChangeRepository) RunBatchWrite(ctx context.Context, projectID, databaseID string, writes []*model.WriteOperation) ([]*model.WriteResult, error) {
+	var pending []*pendingChange
+	if r.watched(ctx, projectID, databaseID) {
+		for _, write := range writes {
+			if write == nil {
+				continue
+			}
+			if _, _, collectionPath, ok := splitDocumentPath(write.Path); ok {
+				pending = append(pending, r.capture(ctx, projectID, databaseID, collectionPath, lastPathSegment(write.Path)))
+			}
+		}
+	}
+	var results []*model.WriteResult
+	err := r.apply(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = r.FirestoreRepository.RunBatchWrite(ctx, projectID, databaseID, writes)
+		return err
+	}, pending...)
+	return results, err
+}
+
+// RunTransaction reports the documents written by the transaction once it has committed.
+// Before snapshots are read outside the transaction when a write is staged, so they hold
+// the last committed state.
+func (r *documentChangeRepository) RunTransaction(ctx context.Context, fn func(tx repository.Transaction) error) error {
+	tx := &changeCapturingTransaction{repo: r, ctx: ctx}
+	return r.applyAll(ctx, func(commitCtx context.Context) error {
+		return r.FirestoreRepository.RunTransaction(commitCtx, func(inner repository.Transaction) error {
+			tx.Transaction = inner
+			tx.reset()
+			return fn(tx)
+		})
+	}, func() []*pendingChange {
+		return tx.pending
+	})
+}
+
+// AtomicIncrement reports the modified document
+func (r *documentChangeRepository) AtomicIncrement(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, value int64) error {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.AtomicIncrement(ctx, projectID, databaseID, collectionID, documentID, field, value)
+	}, pending)
+}
+
+// AtomicArrayUnion reports the modified document
+func (r *documentChangeRepository) AtomicArrayUnion(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, elements []*model.FieldValue) error {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.AtomicArrayUnion(ctx, projectID, databaseID, collectionID, documentID, field, elements)
+	}, pending)
+}
+
+// AtomicArrayRemove reports the modified document
+func (r *documentChangeRepository) AtomicArrayRemove(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, elements []*model.FieldValue) error {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.AtomicArrayRemove(ctx, projectID, databaseID, collectionID, documentID, field, elements)
+	}, pending)
+}
+
+// AtomicServerTimestamp reports the modified document
+func (r *documentChangeRepository) AtomicServerTimestamp(ctx context.Context, projectID, databaseID, collectionID, documentID, field string) error {
+	pending := r.capture(ctx, projectID, databaseID, collectionID, documentID)
+	return r.apply(ctx, func(ctx context.Context) error {
+		return r.FirestoreRepository.AtomicServerTimestamp(ctx, projectID, databaseID, collectionID, documentID, field)
+	}, pending)
+}
+
+// apply runs a write and reports the changes of the pending snapshots. Writes to databases
+// nobody watches have no snapshots and run on their own.
+func (r *documentChangeRepository) apply(ctx context.Context, write func(ctx context.Context) error, pending ...*pendingChange) error {
+	for _, p := range pending {
+		if p != nil {
+			return r.applyAll(ctx, write, func() []*pendingChange { return pending })
+		}
+	}
+	return write(utils.WithCommitTime(ctx, newCommitTime()))
+}
+
+// newCommitTime returns the time shared by the writes of one commit, at the millisecond
+// precision of storage so that it reads back unchanged as their update time
+func newCommitTime() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// applyAll runs a write and reports its changes. Every document of the write is stored
+// with one commit time, which the changes carry. With a commit scope, the recorders record
+// the changes in the storage transaction of the write: a write is never committed without
+// its queued deliveries, and a failure to record them fails the write.
+func (r *documentChangeRepository) applyAll(ctx context.Context, write func(ctx context.Context) error, pending func() []*pendingChange) error {
+	ctx = utils.WithCommitTime(ctx, newCommitTime())
+	if r.scope == nil || len(r.recorders) == 0 {
+		if err := write(ctx); err != nil {
+			return err
+		}
+		r.notify(ctx, r.changes(ctx, pending()...), false)
+		return nil
+	}
+
+	var changes []*model.DocumentChange
+	err := r.scope.Atomically(ctx, func(commitCtx context.Context) error {
+		if err := write(commitCtx); err != nil {
+			return err
+		}
+		changes = r.changes(commitCtx, pending()...)
+		for _, recorder := range r.recorders {
+			if watched := watchedChanges(ctx, recorder, changes); len(watched) > 0 {
+				if err := recorder.RecordChanges(commitCtx, watched); err != nil {
+					return fmt.Errorf("failed to record document changes: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.notify(ctx, changes, true)
+	return nil
+}
+func (r *documentChangeRepository) watched(ctx context.Context, projectID, databaseID string) bool {
+	for _, handler := range r.handlers {
+		if handler.WatchesChanges(ctx, projectID, databaseID) {
+			return true
+		}
+	}
+	return false
+}
+
+// capture reads the snapshot before a write, or returns nil when the database is not watched
+func (r *documentChangeRepository) capture(ctx context.Context, projectID, databaseID, collectionPath, documentID string) *pendingChange {
+	if !r.watched(ctx, projectID, databaseID) {
+		return nil
+	}
+	pending := &pendingChange{projectID: projectID, databaseID: databaseID, collectionPath: collectionPath, documentID: documentID}
+	if documentID != "" {
+		if doc, err := r.FirestoreRepository.GetDocument(ctx, projectID, databaseID, collectionPath, documentID); err == nil {
+			pending.before = doc
+		}
+	}
+	return pending
+}
+
+// captureByPath is capture for path-based writes. Relative paths are only resolved to a
+// project and database once a snapshot of the document exists.
+func (r *documentChangeRepository) captureByPath(ctx context.Context, path string) *pendingChange {
+	projectID, databaseID, collectionPath, ok := splitDocumentPath(path)
+	if !ok || len(r.handlers) == 0 {
+		return nil
+	}
+	if projectID != "" {
+		return r.capture(ctx, projectID, databaseID, collectionPath, lastPathSegment(path))
+	}
+	pending := &pendingChange{collectionPath: collectionPath, documentID: lastPathSegment(path), path: path}
+	if doc, err := r.FirestoreRepository.GetDocumentByPath(ctx, path); err == nil && doc != nil {
+		pending.before = doc
+	}
+	return pending
+}
+
+// changes reads the snapshots after the writes and returns the changes with the commit
+// time of the write
+func (r *documentChangeRepository) changes(ctx context.Context, pending ...*pendingChange) []*model.DocumentChange {
+	commitTime := utils.GetCommitTimeOrNow(ctx)
+	var changes []*model.DocumentChange
+	for _, p := range pending {
+		if p == nil || p.documentID == "" {
+			continue
+		}
+		var after *model.Document
+		var doc *model.Document
+		var err error
+		if p.path != "" {
+			doc, err = r.FirestoreRepository.GetDocumentByPath(ctx, p.path)
+		} else {
+			doc, err = r.FirestoreRepository.GetDocument(ctx, p.projectID, p.databaseID, p.collectionPath, p.documentID)
+		}
+		if err == nil {
+			after = doc
+		}
+		if p.before == nil && after == nil {
+			continue
+		}
+		change := &model.DocumentChange{
+			ProjectID:    p.projectID,
+			DatabaseID:   p.databaseID,
+			DocumentPath: p.collectionPath + "/" + p.documentID,
+			Before:       p.before,
+			After:        after,
+			CommitTime:   commitTime,
+		}
+		if change.ProjectID == "" {
+			snapshot := after
+			if snapshot == nil {
+				snapshot = p.before
+			}
+			change.ProjectID, change.DatabaseID = snapshot.ProjectID, snapshot.DatabaseID
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// notify hands committed changes to the handlers. Recorders that already recorded them in
+// the transaction of the write are only told that the transaction committed.
+func (r *documentChangeRepository) notify(ctx context.Context, changes []*model.DocumentChange, recorded bool) {
+	if len(changes) == 0 {
+		return
+	}
+	for _, handler := range r.handlers {
+		watched := watchedChanges(ctx, handler, changes)
+		if len(watched) == 0 {
+			continue
+		}
+		if recorder, ok := handler.(DocumentChangeRecorder); ok && recorded {
+			recorder.ChangesRecorded(ctx)
+			continue
+		}
+		handler.HandleChanges(ctx, watched)
+	}
+}
+
+// watchedChanges returns the changes of the databases watched by handler
+func watchedChanges(ctx context.Context, handler DocumentChangeHandler, changes []*model.DocumentChange) []*model.DocumentChange {
+	var watched []*model.DocumentChange
+	for _, change := range changes {
+		if handler.WatchesChanges(ctx, change.ProjectID, change.DatabaseID) {
+			watched = append(watched, change)
+		}
+	}
+	return watched
+}
+
+// changeCapturingTransaction captures the snapshots before the writes of a transaction
+type changeCapturingTransaction struct {
+	repository.Transaction
+	repo    *documentChangeRepository
+	ctx     context.Context
+	mu      sync.Mutex
+	pending []*pendingChange
+}
+
+// reset forgets the writes of a previous attempt when the transaction is retried
+func (t *changeCapturingTransaction) reset() {
+	t.mu.Lock()
+	t.pending = nil
+	t.mu.Unlock()
+}
+
+func (t *changeCapturingTransaction) record(pending *pendingChange) {
+	if pending == nil {
+		return
+	}
+	t.mu.Lock()
+	t.pending = append(t.pending, pending)
+	t.mu.Unlock()
+}
+
+func (t *changeCapturingTransaction) Create(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) error {
+	t.record(t.repo.capture(t.ctx, projectID, databaseID, collectionID, documentID))
+	return t.Transaction.Create(projectID, databaseID, collectionID, documentID, data)
+}
+
+func (t *changeCapturingTransaction) CreateByPath(path string, data map[string]*model.FieldValue) error {
+	t.record(t.repo.captureByPath(t.ctx, path))
+	return t.Transaction.CreateByPath(path, data)
+}
+
+func (t *changeCapturingTransaction) Update(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) error {
+	t.record(t.repo.capture(t.ctx, projectID, databaseID, collectionID, documentID))
+	return t.Transaction.Update(projectID, databaseID, collectionID, documentID, data, updateMask)
+}
+
+func (t *changeCapturingTransaction) UpdateByPath(path string, data map[string]*model.FieldValue, updateMask []string) error {
+	t.record(t.repo.captureByPath(t.ctx, path))
+	return t.Transaction.UpdateByPath(path, data, updateMask)
+}
+
+func (t *changeCapturingTransaction) Set(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) error {
+	t.record(t.repo.capture(t.ctx, projectID, databaseID, collectionID, documentID))
+	return t.Transaction.Set(projectID, databaseID, collectionID, documentID, data, merge)
+}
+
+func (t *changeCapturingTransaction) SetByPath(path string, data map[string]*model.FieldValue, merge bool) error {
+	t.record(t.repo.captureByPath(t.ctx, path))
+	return t.Transaction.SetByPath(path, data, merge)
+}
+
+func (t *changeCapturingTransaction) Delete(projectID, databaseID, collectionID, documentID string) error {
+	t.record(t.repo.capture(t.ctx, projectID, databaseID, collectionID, documentID))
+	return t.Transaction.Delete(projectID, databaseID, collectionID, documentID)
+}
+
+func (t *changeCapturingTransaction) DeleteByPath(path string) error {
+	t.record(t.repo.captureByPath(t.ctx, path))
+	return t.Transaction.DeleteByPath(path)
+}
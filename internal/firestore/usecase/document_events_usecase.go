@@ -0,0 +1,456 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/eventbus"
+	"firestore-clone/internal/shared/logger"
+
+	"github.com/google/uuid"
+)
+
+// documentEventSource is the event bus source of the document change events
+const documentEventSource = "document_events"
+
+// Change is a committed document change delivered to an in-process handler. Before is nil
+// for a creation and After is nil for a deletion. Params holds the wildcard values of the
+// document pattern the handler was registered with.
+type Change struct {
+	EventID      string // Stable across redeliveries of the same change
+	Kind         model.DocumentChangeKind
+	ProjectID    string
+	DatabaseID   string
+	DocumentPath string // Relative document path, e.g. "users/u1/orders/o1"
+	Before       *model.Document
+	After        *model.Document
+	Params       map[string]string
+	CommitTime   time.Time
+}
+
+// DocumentEventHandler handles a document change. A returned error or a panic is retried
+// by the event bus and the change is dispatched again later from the outbox.
+type DocumentEventHandler func(ctx context.Context, change Change) error
+
+// DocumentEventsUsecase defines the primary port for in-process document change handlers.
+// Handlers are registered on a document pattern such as "users/{uid}/orders/{oid}" and are
+// subscribed to the event bus. Matching changes are recorded in an outbox together with
+// the write, in its transaction when the document change repository has a commit scope,
+// and published on the bus by a background worker, so handlers run asynchronously and
+// every committed change is delivered at least once.
+type DocumentEventsUsecase interface {
+	OnDocumentWritten(pattern string, handler DocumentEventHandler) error
+	OnDocumentCreated(pattern string, handler DocumentEventHandler) error
+	OnDocumentUpdated(pattern string, handler DocumentEventHandler) error
+	OnDocumentDeleted(pattern string, handler DocumentEventHandler) error
+
+	// ListEvents lists the outbox events in a state, newest first. Dead-lettered events
+	// are the changes whose handlers kept failing.
+	ListEvents(ctx context.Context, state model.TriggerDeliveryState, limit int) ([]*model.DocumentEvent, error)
+
+	// DispatchDue publishes the outbox events that are due and returns how many were dispatched
+	DispatchDue(ctx context.Context) (int, error)
+	// Start runs the outbox worker until Stop is called
+	Start(ctx context.Context)
+	Stop()
+
+	// Write notifications, called by the document change repository
+	DocumentChangeHandler
+}
+
+// DocumentEventOutbox defines the secondary port for the persistent document event outbox
+type DocumentEventOutbox interface {
+	Enqueue(ctx context.Context, events []*model.DocumentEvent) error
+	// ClaimDue leases up to limit pending events due at now by moving their next attempt
+	// to now+lease, so that other workers skip them while they are being dispatched
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.DocumentEvent, error)
+	SaveEvent(ctx context.Context, event *model.DocumentEvent) error
+	ListEvents(ctx context.Context, state model.TriggerDeliveryState, limit int) ([]*model.DocumentEvent, error)
+}
+
+// InMemoryDocumentEventOutbox implements DocumentEventOutbox with in-memory storage.
+// Events are lost on restart; production deployments use a persistent outbox.
+type InMemoryDocumentEventOutbox struct {
+	events map[string]*model.DocumentEvent // eventID -> event
+	mu     sync.Mutex
+}
+
+// NewInMemoryDocumentEventOutbox creates a new in-memory document event outbox
+func NewInMemoryDocumentEventOutbox() DocumentEventOutbox {
+	return &InMemoryDocumentEventOutbox{
+		events: make(map[string]*model.DocumentEvent),
+	}
+}
+
+// Enqueue stores copies of the events
+func (o *InMemoryDocumentEventOutbox) Enqueue(ctx context.Context, events []*model.DocumentEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, event := range events {
+		stored := *event
+		o.events[event.EventID] = &stored
+	}
+	return nil
+}
+
+// ClaimDue leases the oldest pending events due at now
+func (o *InMemoryDocumentEventOutbox) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.DocumentEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	due := make([]*model.DocumentEvent, 0)
+	for _, event := range o.events {
+		if event.State == model.TriggerDeliveryPending && !event.NextAttemptAt.After(now) {
+			due = append(due, event)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*model.DocumentEvent, 0, len(due))
+	for _, event := range due {
+		event.NextAttemptAt = now.Add(lease)
+		copied := *event
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+// SaveEvent stores a copy of the event
+func (o *InMemoryDocumentEventOutbox) SaveEvent(ctx context.Context, event *model.DocumentEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stored := *event
+	o.events[event.EventID] = &stored
+	return nil
+}
+
+// ListEvents returns copies of the events in a state, newest first
+func (o *InMemoryDocumentEventOutbox) ListEvents(ctx context.Context, state model.TriggerDeliveryState, limit int) ([]*model.DocumentEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := make([]*model.DocumentEvent, 0)
+	for _, event := range o.events {
+		if state == "" || event.State == state {
+			copied := *event
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// documentEventRegistration is a handler registered on a document pattern
+type documentEventRegistration struct {
+	// id identifies the handler in the outbox. It is derived from the event type, the
+	// pattern and the registration order, so it is the same on every instance and restart.
+	id        string
+	eventType model.DocumentTriggerEventType
+	pattern   string
+}
+
+// documentHandlerEventType is the event bus type on which a single handler is subscribed
+func documentHandlerEventType(handlerID string) string {
+	return eventbus.EventTypeDocumentWritten + "/" + handlerID
+}
+
+// match reports whether the registration handles the change and returns the pattern params
+func (r *documentEventRegistration) match(change *model.DocumentChange) (map[string]string, bool) {
+	if !r.eventType.Accepts(change.Kind()) {
+		return nil, false
+	}
+	return model.MatchDocumentPattern(r.pattern, change.DocumentPath)
+}
+
+type documentEventsUsecase struct {
+	bus    *eventbus.EventBus
+	outbox DocumentEventOutbox
+	config TriggerDeliveryConfig
+	logger logger.Logger
+
+	mu            sync.RWMutex
+	registrations []*documentEventRegistration
+
+	worker *pollWorker
+}
+
+// NewDocumentEventsUsecase creates a new document events usecase publishing on bus. The
+// bus retries failing handlers BusConfig.MaxRetries times, RetryDelay apart; config sets
+// how often the outbox dispatches a change again before it is dead-lettered.
+func NewDocumentEventsUsecase(bus *eventbus.EventBus, outbox DocumentEventOutbox, config TriggerDeliveryConfig, log logger.Logger) DocumentEventsUsecase {
+	if bus == nil {
+		bus = eventbus.NewEventBus(log)
+	}
+	if outbox == nil {
+		outbox = NewInMemoryDocumentEventOutbox()
+	}
+	uc := &documentEventsUsecase{
+		bus:    bus,
+		outbox: outbox,
+		config: config.withDefaults(),
+		logger: log,
+	}
+	uc.worker = newPollWorker("Document event outbox worker", uc.config.PollInterval, uc.config.BatchSize, uc.DispatchDue, log)
+	return uc
+}
+
+// OnDocumentWritten implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) OnDocumentWritten(pattern string, handler DocumentEventHandler) error {
+	return uc.register(model.TriggerOnWritten, pattern, handler)
+}
+
+// OnDocumentCreated implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) OnDocumentCreated(pattern string, handler DocumentEventHandler) error {
+	return uc.register(model.TriggerOnCreate, pattern, handler)
+}
+
+// OnDocumentUpdated implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) OnDocumentUpdated(pattern string, handler DocumentEventHandler) error {
+	return uc.register(model.TriggerOnUpdate, pattern, handler)
+}
+
+// OnDocumentDeleted implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) OnDocumentDeleted(pattern string, handler DocumentEventHandler) error {
+	return uc.register(model.TriggerOnDelete, pattern, handler)
+}
+
+// register subscribes handler to the document change events matching the pattern
+func (uc *documentEventsUsecase) register(eventType model.DocumentTriggerEventType, pattern string, handler DocumentEventHandler) error {
+	if handler == nil {
+		return errors.NewValidationError("document event handler is required")
+	}
+	pattern = strings.Trim(pattern, "/")
+	if err := model.ValidateDocumentPattern(pattern); err != nil {
+		return errors.NewValidationError(err.Error()).WithCause(err)
+	}
+	registration := &documentEventRegistration{eventType: eventType, pattern: pattern}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	base := fmt.Sprintf("%s %s", eventType, pattern)
+	registration.id = base
+	for n := 2; uc.registered(registration.id); n++ {
+		registration.id = fmt.Sprintf("%s #%d", base, n)
+	}
+
+	uc.bus.Subscribe(documentHandlerEventType(registration.id), func(ctx context.Context, event eventbus.Event) error {
+		documentEvent, ok := event.Data().(*model.DocumentEvent)
+		if !ok || documentEvent.Change == nil {
+			return nil
+		}
+		params, ok := registration.match(documentEvent.Change)
+		if !ok {
+			return nil
+		}
+		change := documentEvent.Change
+		return handler(ctx, Change{
+			EventID:      documentEvent.EventID,
+			Kind:         change.Kind(),
+			ProjectID:    change.ProjectID,
+			DatabaseID:   change.DatabaseID,
+			DocumentPath: change.DocumentPath,
+			Before:       change.Before,
+			After:        change.After,
+			Params:       params,
+			CommitTime:   change.CommitTime,
+		})
+	})
+
+	uc.registrations = append(uc.registrations, registration)
+	uc.logger.Info("Document event handler registered", "eventType", eventType, "document", pattern, "handler", registration.id)
+	return nil
+}
+
+// registered reports whether a handler ID is taken; the caller holds uc.mu
+func (uc *documentEventsUsecase) registered(id string) bool {
+	for _, registration := range uc.registrations {
+		if registration.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ListEvents implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) ListEvents(ctx context.Context, state model.TriggerDeliveryState, limit int) ([]*model.DocumentEvent, error) {
+	switch state {
+	case "", model.TriggerDeliveryPending, model.TriggerDeliveryDelivered, model.TriggerDeliveryDeadLetter, model.DocumentEventNoSubscriber:
+	default:
+		return nil, errors.NewValidationError(fmt.Sprintf("unknown event state %q", state))
+	}
+	return uc.outbox.ListEvents(ctx, state, limit)
+}
+
+// WatchesChanges implements DocumentChangeHandler. Handlers apply to every database.
+func (uc *documentEventsUsecase) WatchesChanges(ctx context.Context, projectID, databaseID string) bool {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return len(uc.registrations) > 0
+}
+
+// HandleChanges implements DocumentChangeHandler by recording the changes matching a
+// registered handler in the outbox
+func (uc *documentEventsUsecase) HandleChanges(ctx context.Context, changes []*model.DocumentChange) {
+	if err := uc.RecordChanges(ctx, changes); err != nil {
+		uc.logger.Error("Failed to record document events", "changes", len(changes), "error", err)
+		return
+	}
+	uc.worker.signal()
+}
+
+// RecordChanges implements DocumentChangeRecorder by recording the changes matching a
+// registered handler in the outbox
+func (uc *documentEventsUsecase) RecordChanges(ctx context.Context, changes []*model.DocumentChange) error {
+	now := time.Now()
+	var events []*model.DocumentEvent
+	for _, change := range changes {
+		if !uc.matchesAnyRegistration(change) {
+			continue
+		}
+		events = append(events, &model.DocumentEvent{
+			EventID:       uuid.New().String(),
+			Change:        change,
+			State:         model.TriggerDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return uc.outbox.Enqueue(ctx, events)
+}
+
+// ChangesRecorded implements DocumentChangeRecorder
+func (uc *documentEventsUsecase) ChangesRecorded(ctx context.Context) {
+	uc.worker.signal()
+}
+
+func (uc *documentEventsUsecase) matchesAnyRegistration(change *model.DocumentChange) bool {
+	return len(uc.matchingRegistrations(change)) > 0
+}
+
+// matchingRegistrations returns the handlers of a change in registration order
+func (uc *documentEventsUsecase) matchingRegistrations(change *model.DocumentChange) []*documentEventRegistration {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	var matching []*documentEventRegistration
+	for _, registration := range uc.registrations {
+		if _, ok := registration.match(change); ok {
+			matching = append(matching, registration)
+		}
+	}
+	return matching
+}
+
+// DispatchDue implements DocumentEventsUsecase. Claimed events are dispatched concurrently.
+func (uc *documentEventsUsecase) DispatchDue(ctx context.Context) (int, error) {
+	events, err := uc.outbox.ClaimDue(ctx, time.Now(), uc.config.BatchSize, uc.config.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim document events: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, event := range events {
+		wg.Add(1)
+		go func(event *model.DocumentEvent) {
+			defer wg.Done()
+			uc.dispatch(ctx, event)
+		}(event)
+	}
+	wg.Wait()
+	return len(events), nil
+}
+
+// dispatch publishes an event once to each matching handler that has not processed it yet
+// and records the outcome per handler. Handlers are published to concurrently, so that a
+// failing handler retried by the bus does not hold the others back.
+func (uc *documentEventsUsecase) dispatch(ctx context.Context, event *model.DocumentEvent) {
+	registrations := uc.matchingRegistrations(event.Change)
+	event.Attempts++
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []string
+		handled  []string
+	)
+	for _, registration := range registrations {
+		if event.Handled(registration.id) {
+			continue
+		}
+		wg.Add(1)
+		go func(registration *documentEventRegistration) {
+			defer wg.Done()
+			err := uc.bus.Publish(ctx, eventbus.NewBasicEventWithSource(documentHandlerEventType(registration.id), event, documentEventSource))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", registration.id, err))
+				return
+			}
+			handled = append(handled, registration.id)
+		}(registration)
+	}
+	wg.Wait()
+	sort.Strings(failures)
+	sort.Strings(handled)
+	event.HandledBy = append(event.HandledBy, handled...)
+	now := time.Now()
+	event.UpdatedAt = now
+
+	switch {
+	case len(registrations) == 0:
+		// Not delivered to anyone: kept apart from the delivered events for inspection
+		event.State = model.DocumentEventNoSubscriber
+		event.LastError = ""
+		uc.logger.Warn("Document event has no subscriber", "event", event.EventID, "document", event.Change.DocumentPath)
+	case len(failures) == 0:
+		event.State = model.TriggerDeliveryDelivered
+		event.LastError = ""
+		event.DeliveredAt = &now
+	default:
+		event.LastError = strings.Join(failures, "; ")
+		if event.Attempts >= uc.config.MaxAttempts {
+			event.State = model.TriggerDeliveryDeadLetter
+			uc.logger.Warn("Document event dead-lettered",
+				"event", event.EventID,
+				"document", event.Change.DocumentPath,
+				"attempts", event.Attempts,
+				"error", event.LastError)
+		} else {
+			event.NextAttemptAt = now.Add(uc.config.backoff(event.Attempts))
+		}
+	}
+	if err := uc.outbox.SaveEvent(ctx, event); err != nil {
+		// The lease expires and the event is dispatched again
+		uc.logger.Error("Failed to record document event dispatch", "event", event.EventID, "error", err)
+	}
+}
+
+// Start implements DocumentEventsUsecase
+func (uc *documentEventsUsecase) Start(ctx context.Context) {
+	uc.worker.Start(ctx)
+}
+
+// Stop implements DocumentEventsUsecase and waits for the dispatches in progress
+func (uc *documentEventsUsecase) Stop() {
+	uc.worker.Stop()
+}
@@ -0,0 +1,221 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changeRecorder collects the changes received by a document event handler
+type changeRecorder struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+func (r *changeRecorder) record(change Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *changeRecorder) received() []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Change(nil), r.changes...)
+}
+
+func newDocumentEventsTestSetup(busConfig eventbus.BusConfig, config TriggerDeliveryConfig) (DocumentEventsUsecase, repository.FirestoreRepository) {
+	bus := eventbus.NewEventBusWithConfig(nil, busConfig)
+	uc := NewDocumentEventsUsecase(bus, nil, config, &MockLogger{})
+	return uc, NewDocumentChangeRepository(newMemDocRepo(), uc)
+}
+
+func TestDocumentEvents_HandlersReceiveChangesAsynchronously(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newDocumentEventsTestSetup(eventbus.DefaultBusConfig(), TriggerDeliveryConfig{PollInterval: time.Hour})
+	uc.Start(ctx)
+	defer uc.Stop()
+
+	written, created := &changeRecorder{}, &changeRecorder{}
+	require.NoError(t, uc.OnDocumentWritten("users/{uid}/orders/{oid}", func(ctx context.Context, change Change) error {
+		written.record(change)
+		return nil
+	}))
+	require.NoError(t, uc.OnDocumentCreated("users/{uid}", func(ctx context.Context, change Change) error {
+		created.record(change)
+		return nil
+	}))
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "placed"}))
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "shipped"}), nil)
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(written.received()) == 2 && len(created.received()) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	byKind := map[model.DocumentChangeKind]Change{}
+	for _, change := range written.received() {
+		assert.Equal(t, map[string]string{"uid": "u1", "oid": "o1"}, change.Params)
+		assert.Equal(t, "users/u1/orders/o1", change.DocumentPath)
+		assert.NotEmpty(t, change.EventID)
+		byKind[change.Kind] = change
+	}
+	require.Contains(t, byKind, model.DocumentChangeCreated)
+	require.Contains(t, byKind, model.DocumentChangeUpdated)
+	assert.Nil(t, byKind[model.DocumentChangeCreated].Before)
+	assert.Equal(t, "placed", byKind[model.DocumentChangeUpdated].Before.Fields["status"].Value)
+	assert.Equal(t, "shipped", byKind[model.DocumentChangeUpdated].After.Fields["status"].Value)
+	assert.Equal(t, map[string]string{"uid": "u1"}, created.received()[0].Params)
+}
+
+func TestDocumentEvents_FailuresAreRetriedAndRedelivered(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newDocumentEventsTestSetup(
+		eventbus.BusConfig{MaxRetries: 1, RetryDelay: time.Millisecond},
+		TriggerDeliveryConfig{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+	)
+
+	calls := 0
+	var eventIDs []string
+	require.NoError(t, uc.OnDocumentWritten("users/{uid}", func(ctx context.Context, change Change) error {
+		calls++
+		eventIDs = append(eventIDs, change.EventID)
+		if calls < 3 {
+			panic("handler bug")
+		}
+		return nil
+	}))
+	healthy := &changeRecorder{}
+	require.NoError(t, uc.OnDocumentWritten("users/{uid}", func(ctx context.Context, change Change) error {
+		healthy.record(change)
+		return nil
+	}))
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	// The bus retries the panicking handler once, then the outbox dispatches the change again
+	dispatched, err := uc.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+	assert.Equal(t, 2, calls)
+	pending, err := uc.ListEvents(ctx, model.TriggerDeliveryPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Contains(t, pending[0].LastError, "handler panicked")
+	assert.Equal(t, []string{"onWritten users/{uid} #2"}, pending[0].HandledBy)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = uc.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	delivered, err := uc.ListEvents(ctx, model.TriggerDeliveryDelivered, 0)
+	require.NoError(t, err)
+	assert.Len(t, delivered, 1)
+	for _, id := range eventIDs {
+		assert.Equal(t, delivered[0].EventID, id, "redeliveries keep the event ID")
+	}
+	// Delivery is tracked per handler: the healthy handler is not called again on redelivery
+	assert.Len(t, healthy.received(), 1)
+	assert.Len(t, delivered[0].HandledBy, 2)
+}
+
+func TestDocumentEvents_HandlersAreDispatchedConcurrentlyOnASynchronousBus(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newDocumentEventsTestSetup(eventbus.DefaultBusConfig(), DefaultTriggerDeliveryConfig())
+
+	// The first handler only succeeds once the second one has run
+	secondRan := make(chan struct{})
+	require.NoError(t, uc.OnDocumentWritten("users/{uid}", func(ctx context.Context, change Change) error {
+		select {
+		case <-secondRan:
+			return nil
+		case <-time.After(2 * time.Second):
+			return fmt.Errorf("second handler did not run")
+		}
+	}))
+	require.NoError(t, uc.OnDocumentWritten("users/{uid}", func(ctx context.Context, change Change) error {
+		close(secondRan)
+		return nil
+	}))
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = uc.DispatchDue(ctx)
+	require.NoError(t, err)
+
+	delivered, err := uc.ListEvents(ctx, model.TriggerDeliveryDelivered, 0)
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	assert.Equal(t, []string{"onWritten users/{uid}", "onWritten users/{uid} #2"}, delivered[0].HandledBy)
+}
+
+func TestDocumentEvents_DeadLetterAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	uc, repo := newDocumentEventsTestSetup(
+		eventbus.BusConfig{MaxRetries: 0},
+		TriggerDeliveryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	)
+	require.NoError(t, uc.OnDocumentDeleted("users/{uid}", func(ctx context.Context, change Change) error {
+		return fmt.Errorf("downstream unavailable")
+	}))
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "users", "u1"))
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		_, err = uc.DispatchDue(ctx)
+		require.NoError(t, err)
+	}
+	dead, err := uc.ListEvents(ctx, model.TriggerDeliveryDeadLetter, 0)
+	require.NoError(t, err)
+	require.Len(t, dead, 1, "only the delete is recorded for an onDelete handler")
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, model.DocumentChangeDeleted, dead[0].Change.Kind())
+}
+
+func TestDocumentEvents_RegistrationIsValidated(t *testing.T) {
+	uc, _ := newDocumentEventsTestSetup(eventbus.DefaultBusConfig(), DefaultTriggerDeliveryConfig())
+	assert.Error(t, uc.OnDocumentWritten("users", func(ctx context.Context, change Change) error { return nil }))
+	assert.Error(t, uc.OnDocumentWritten("users/{uid}", nil))
+}
+
+func TestDocumentEvents_EventsWithoutSubscriberAreKeptApart(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewInMemoryDocumentEventOutbox()
+	writer := NewDocumentEventsUsecase(nil, outbox, DefaultTriggerDeliveryConfig(), &MockLogger{})
+	require.NoError(t, writer.OnDocumentCreated("users/{uid}", func(ctx context.Context, change Change) error {
+		return nil
+	}))
+	repo := NewDocumentChangeRepository(newMemDocRepo(), writer)
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	// An instance whose handlers do not match the recorded change does not mark it delivered
+	dispatcher := NewDocumentEventsUsecase(nil, outbox, DefaultTriggerDeliveryConfig(), &MockLogger{})
+	dispatched, err := dispatcher.DispatchDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+
+	delivered, err := dispatcher.ListEvents(ctx, model.TriggerDeliveryDelivered, 0)
+	require.NoError(t, err)
+	assert.Empty(t, delivered)
+	unsubscribed, err := dispatcher.ListEvents(ctx, model.DocumentEventNoSubscriber, 0)
+	require.NoError(t, err)
+	require.Len(t, unsubscribed, 1)
+	assert.Empty(t, unsubscribed[0].HandledBy)
+}
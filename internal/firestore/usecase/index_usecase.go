@@ -100,6 +100,19 @@ func (uc *FirestoreUsecase) validateIndex(index *model.Index) error {
 			return fmt.Errorf("field path is required at index %d", i)
 		}
 
+		// Geo fields are indexed spatially and have no order
+		if field.Mode == model.IndexFieldModeGeo {
+			continue
+		}
+
+		// Vector fields are ranked by distance and have no order
+		if field.VectorConfig != nil {
+			if field.VectorConfig.Dimension <= 0 || field.VectorConfig.Dimension > model.MaxVectorDimension {
+				return fmt.Errorf("vector dimension at index %d must be between 1 and %d", i, model.MaxVectorDimension)
+			}
+			continue
+		}
+
 		if field.Order != model.IndexFieldOrderAscending && field.Order != model.IndexFieldOrderDescending {
 			return fmt.Errorf("invalid field order at index %d", i)
 		}
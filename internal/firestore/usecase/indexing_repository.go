@@ -0,0 +1,294 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+)
+
+// DocumentIndexer is a secondary index over documents, such as the full-text search or
+// the approximate vector index, that follows writes through the indexing repository
+type DocumentIndexer interface {
+	// IsIndexed reports whether the collection has an index to maintain
+	IsIndexed(ctx context.Context, projectID, databaseID, collectionID string) bool
+	IndexDocument(ctx context.Context, projectID, databaseID, collectionID string, doc *model.Document)
+	RemoveDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string)
+	RemoveCollection(ctx context.Context, projectID, databaseID, collectionID string)
+}
+
+// indexingRepository decorates a FirestoreRepository so that secondary indexes follow
+// every successful write. Written documents are re-read from storage, so update masks,
+// merges and transforms are indexed exactly as stored.
+type indexingRepository struct {
+	repository.FirestoreRepository
+	indexers []DocumentIndexer
+}
+
+// NewIndexingRepository wraps repo with the maintenance of the given indexes
+func NewIndexingRepository(repo repository.FirestoreRepository, indexers ...DocumentIndexer) repository.FirestoreRepository {
+	return &indexingRepository{FirestoreRepository: repo, indexers: indexers}
+}
+
+// CreateDocument indexes the created document
+func (r *indexingRepository) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
+	doc, err := r.FirestoreRepository.CreateDocument(ctx, projectID, databaseID, collectionID, documentID, data)
+	if err == nil && doc != nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, doc.DocumentID)
+	}
+	return doc, err
+}
+
+// UpdateDocument reindexes the updated document
+func (r *indexingRepository) UpdateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	doc, err := r.FirestoreRepository.UpdateDocument(ctx, projectID, databaseID, collectionID, documentID, data, updateMask)
+	if err == nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return doc, err
+}
+
+// SetDocument reindexes the written document
+func (r *indexingRepository) SetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) (*model.Document, error) {
+	doc, err := r.FirestoreRepository.SetDocument(ctx, projectID, databaseID, collectionID, documentID, data, merge)
+	if err == nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return doc, err
+}
+
+// DeleteDocument removes the document from the indexes
+func (r *indexingRepository) DeleteDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) error {
+	err := r.FirestoreRepository.DeleteDocument(ctx, projectID, databaseID, collectionID, documentID)
+	if err == nil {
+		r.removeDocument(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return err
+}
+
+// DeleteCollection drops the collection's indexes
+func (r *indexingRepository) DeleteCollection(ctx context.Context, projectID, databaseID, collectionID string) error {
+	err := r.FirestoreRepository.DeleteCollection(ctx, projectID, databaseID, collectionID)
+	if err == nil {
+		for _, indexer := range r.indexers {
+			indexer.RemoveCollection(ctx, projectID, databaseID, collectionID)
+		}
+	}
+	return err
+}
+
+// CreateDocumentByPath indexes the created document
+func (r *indexingRepository) CreateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue) (*model.Document, error) {
+	doc, err := r.FirestoreRepository.CreateDocumentByPath(ctx, path, data)
+	if err == nil {
+		r.refreshByPath(ctx, path)
+	}
+	return doc, err
+}
+
+// UpdateDocumentByPath reindexes the updated document
+func (r *indexingRepository) UpdateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	doc, err := r.FirestoreRepository.UpdateDocumentByPath(ctx, path, data, updateMask)
+	if err == nil {
+		r.refreshByPath(ctx, path)
+	}
+	return doc, err
+}
+
+// DeleteDocumentByPath removes the document from the indexes
+func (r *indexingRepository) DeleteDocumentByPath(ctx context.Context, path string) error {
+	err := r.FirestoreRepository.DeleteDocumentByPath(ctx, path)
+	if err == nil {
+		if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok && projectID != "" {
+			r.removeDocument(ctx, projectID, databaseID, collectionPath, lastPathSegment(path))
+		}
+	}
+	return err
+}
+
+// RunBatchWrite reindexes every document touched by the batch
+func (r *indexingRepository) RunBatchWrite(ctx context.Context, projectID, databaseID string, writes []*model.WriteOperation) ([]*model.WriteResult, error) {
+	results, err := r.FirestoreRepository.RunBatchWrite(ctx, projectID, databaseID, writes)
+	if err != nil {
+		return results, err
+	}
+	for _, write := range writes {
+		if write == nil {
+			continue
+		}
+		if _, _, collectionPath, ok := splitDocumentPath(write.Path); ok {
+			r.refresh(ctx, projectID, databaseID, collectionPath, lastPathSegment(write.Path))
+		}
+	}
+	return results, nil
+}
+
+// RunTransaction reindexes the documents written by the transaction once it has committed
+func (r *indexingRepository) RunTransaction(ctx context.Context, fn func(tx repository.Transaction) error) error {
+	tx := &indexingTransaction{}
+	err := r.FirestoreRepository.RunTransaction(ctx, func(inner repository.Transaction) error {
+		tx.Transaction = inner
+		tx.reset()
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	for _, ref := range tx.touched {
+		if ref.path != "" {
+			r.refreshByPath(ctx, ref.path)
+		} else {
+			r.refresh(ctx, ref.projectID, ref.databaseID, ref.collectionID, ref.documentID)
+		}
+	}
+	return nil
+}
+
+// AtomicIncrement reindexes the modified document
+func (r *indexingRepository) AtomicIncrement(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, value int64) error {
+	err := r.FirestoreRepository.AtomicIncrement(ctx, projectID, databaseID, collectionID, documentID, field, value)
+	if err == nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return err
+}
+
+// AtomicArrayUnion reindexes the modified document
+func (r *indexingRepository) AtomicArrayUnion(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, elements []*model.FieldValue) error {
+	err := r.FirestoreRepository.AtomicArrayUnion(ctx, projectID, databaseID, collectionID, documentID, field, elements)
+	if err == nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return err
+}
+
+// AtomicArrayRemove reindexes the modified document
+func (r *indexingRepository) AtomicArrayRemove(ctx context.Context, projectID, databaseID, collectionID, documentID, field string, elements []*model.FieldValue) error {
+	err := r.FirestoreRepository.AtomicArrayRemove(ctx, projectID, databaseID, collectionID, documentID, field, elements)
+	if err == nil {
+		r.refresh(ctx, projectID, databaseID, collectionID, documentID)
+	}
+	return err
+}
+
+// refresh re-reads a document of an indexed collection and updates its index entries
+func (r *indexingRepository) refresh(ctx context.Context, projectID, databaseID, collectionID, documentID string) {
+	if documentID == "" {
+		return
+	}
+	var indexers []DocumentIndexer
+	for _, indexer := range r.indexers {
+		if indexer.IsIndexed(ctx, projectID, databaseID, collectionID) {
+			indexers = append(indexers, indexer)
+		}
+	}
+	if len(indexers) == 0 {
+		return
+	}
+	doc, err := r.FirestoreRepository.GetDocument(ctx, projectID, databaseID, collectionID, documentID)
+	for _, indexer := range indexers {
+		if err != nil {
+			// Missing documents are removed; on other errors the indexes drop stale entries lazily
+			indexer.RemoveDocument(ctx, projectID, databaseID, collectionID, documentID)
+			continue
+		}
+		indexer.IndexDocument(ctx, projectID, databaseID, collectionID, doc)
+	}
+}
+
+// removeDocument removes a deleted document from every index
+func (r *indexingRepository) removeDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) {
+	for _, indexer := range r.indexers {
+		indexer.RemoveDocument(ctx, projectID, databaseID, collectionID, documentID)
+	}
+}
+
+// refreshByPath is refresh for path-based writes. Relative paths take the project and
+// database of the stored document.
+func (r *indexingRepository) refreshByPath(ctx context.Context, path string) {
+	projectID, databaseID, collectionPath, ok := splitDocumentPath(path)
+	if !ok {
+		return
+	}
+	if projectID != "" {
+		r.refresh(ctx, projectID, databaseID, collectionPath, lastPathSegment(path))
+		return
+	}
+	if doc, err := r.FirestoreRepository.GetDocumentByPath(ctx, path); err == nil && doc != nil {
+		r.refresh(ctx, doc.ProjectID, doc.DatabaseID, collectionPath, doc.DocumentID)
+	}
+}
+
+// indexingTransaction records the documents written by a transaction
+type indexingTransaction struct {
+	repository.Transaction
+	mu      sync.Mutex
+	touched []indexedDocumentRef
+}
+
+// indexedDocumentRef identifies a written document by its IDs or by its path
+type indexedDocumentRef struct {
+	projectID, databaseID, collectionID, documentID string
+	path                                            string
+}
+
+// reset forgets the writes of a previous attempt when the transaction is retried
+func (t *indexingTransaction) reset() {
+	t.mu.Lock()
+	t.touched = nil
+	t.mu.Unlock()
+}
+
+func (t *indexingTransaction) record(ref indexedDocumentRef) {
+	t.mu.Lock()
+	t.touched = append(t.touched, ref)
+	t.mu.Unlock()
+}
+
+func (t *indexingTransaction) Create(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) error {
+	t.record(indexedDocumentRef{projectID: projectID, databaseID: databaseID, collectionID: collectionID, documentID: documentID})
+	return t.Transaction.Create(projectID, databaseID, collectionID, documentID, data)
+}
+
+func (t *indexingTransaction) CreateByPath(path string, data map[string]*model.FieldValue) error {
+	t.record(indexedDocumentRef{path: path})
+	return t.Transaction.CreateByPath(path, data)
+}
+
+func (t *indexingTransaction) Update(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) error {
+	t.record(indexedDocumentRef{projectID: projectID, databaseID: databaseID, collectionID: collectionID, documentID: documentID})
+	return t.Transaction.Update(projectID, databaseID, collectionID, documentID, data, updateMask)
+}
+
+func (t *indexingTransaction) UpdateByPath(path string, data map[string]*model.FieldValue, updateMask []string) error {
+	t.record(indexedDocumentRef{path: path})
+	return t.Transaction.UpdateByPath(path, data, updateMask)
+}
+
+func (t *indexingTransaction) Set(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) error {
+	t.record(indexedDocumentRef{projectID: projectID, databaseID: databaseID, collectionID: collectionID, documentID: documentID})
+	return t.Transaction.Set(projectID, databaseID, collectionID, documentID, data, merge)
+}
+
+func (t *indexingTransaction) SetByPath(path string, data map[string]*model.FieldValue, merge bool) error {
+	t.record(indexedDocumentRef{path: path})
+	return t.Transaction.SetByPath(path, data, merge)
+}
+
+func (t *indexingTransaction) Delete(projectID, databaseID, collectionID, documentID string) error {
+	t.record(indexedDocumentRef{projectID: projectID, databaseID: databaseID, collectionID: collectionID, documentID: documentID})
+	return t.Transaction.Delete(projectID, databaseID, collectionID, documentID)
+}
+
+func (t *indexingTransaction) DeleteByPath(path string) error {
+	t.record(indexedDocumentRef{path: path})
+	return t.Transaction.DeleteByPath(path)
+}
+
+// lastPathSegment returns the document ID of a document path
+func lastPathSegment(path string) string {
+	trimmed := strings.Trim(path, "/")
+	return trimmed[strings.LastIndex(trimmed, "/")+1:]
+}
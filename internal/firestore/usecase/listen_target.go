@@ -0,0 +1,393 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/firestore"
+)
+
+// ListenTarget is a target of the Firestore Listen protocol: a document or a collection
+// query. It remembers which documents the client holds for the target, so that snapshots
+// and realtime events turn into the documentChange, documentDelete and documentRemove
+// messages keeping the client view in sync.
+//
+// For queries with a limit the view is the result window: documents pushed past the limit
+// are removed, and when a document leaves a full window the target asks to be refreshed so
+// that the next document is backfilled from a new read.
+type ListenTarget struct {
+	ID       model.SubscriptionID
+	FullPath string
+	Query    *model.Query
+
+	pathInfo      *firestore.PathInfo
+	documents     map[string]map[string]interface{} // Documents in the client view by name
+	current       bool
+	resumeToken   model.ResumeToken
+	refreshNeeded bool
+}
+
+// NewListenTarget creates a listen target over a document or collection path
+func NewListenTarget(id model.SubscriptionID, fullPath string, query *model.Query) (*ListenTarget, error) {
+	pathInfo, err := firestore.ParseFirestorePath(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if pathInfo.IsDocument && query != nil {
+		return nil, fmt.Errorf("queries are only supported on collection targets")
+	}
+	return &ListenTarget{
+		ID:        id,
+		FullPath:  strings.Trim(fullPath, "/"),
+		Query:     query,
+		pathInfo:  pathInfo,
+		documents: make(map[string]map[string]interface{}),
+	}, nil
+}
+
+// IsCurrent reports whether the client view of the target is consistent
+func (t *ListenTarget) IsCurrent() bool {
+	return t.current
+}
+
+// NeedsRefresh reports whether the view must be read again with ReadSnapshot and Refresh,
+// because a limited window lost a document it cannot backfill from events
+func (t *ListenTarget) NeedsRefresh() bool {
+	return t.refreshNeeded
+}
+
+// ResumeToken returns the token of the last event applied to the target
+func (t *ListenTarget) ResumeToken() model.ResumeToken {
+	return t.resumeToken
+}
+
+// ReadSnapshot reads the documents currently matching the target
+func (t *ListenTarget) ReadSnapshot(ctx context.Context, firestoreUC FirestoreUsecaseInterface) ([]*model.Document, error) {
+	if t.pathInfo.IsDocument {
+		collectionPath, documentID := path.Split(t.pathInfo.DocumentPath)
+		doc, err := firestoreUC.GetDocument(ctx, GetDocumentRequest{
+			ProjectID:    t.pathInfo.ProjectID,
+			DatabaseID:   t.pathInfo.DatabaseID,
+			CollectionID: strings.TrimSuffix(collectionPath, "/"),
+			DocumentID:   documentID,
+		})
+		if err != nil {
+			if isNotFoundErr(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if doc == nil || !doc.Exists {
+			return nil, nil
+		}
+		return []*model.Document{doc}, nil
+	}
+
+	query := model.Query{}
+	if t.Query != nil {
+		query = *t.Query
+	}
+	if query.Path == "" {
+		query.Path = t.pathInfo.DocumentPath
+	}
+	if query.CollectionID == "" {
+		query.CollectionID = path.Base(t.pathInfo.DocumentPath)
+	}
+	return firestoreUC.RunQuery(ctx, QueryRequest{
+		ProjectID:       t.pathInfo.ProjectID,
+		DatabaseID:      t.pathInfo.DatabaseID,
+		StructuredQuery: &query,
+		Parent:          t.FullPath,
+	})
+}
+
+// Snapshot returns the messages delivering a full snapshot read at readTime, ending with
+// CURRENT. With reset, the client first discards the documents it holds for the target.
+func (t *ListenTarget) Snapshot(docs []*model.Document, readTime time.Time, reset bool) []model.ListenResponse {
+	responses := make([]model.ListenResponse, 0, len(docs)+2)
+	if reset {
+		responses = append(responses, model.NewTargetChange(model.TargetChangeReset, t.ID))
+	}
+	t.documents = make(map[string]map[string]interface{}, len(docs))
+	for _, doc := range docs {
+		name := t.documentName(doc)
+		data := documentToMap(doc)
+		t.documents[name] = data
+		responses = append(responses, t.documentChange(name, data, doc.UpdateTime))
+	}
+	t.current = true
+	t.refreshNeeded = false
+	return append(responses, t.targetChange(model.TargetChangeCurrent, readTime))
+}
+
+// Refresh returns the messages turning the client view into the documents of a new read:
+// changes for new or modified documents and removes for the ones no longer in the results
+func (t *ListenTarget) Refresh(docs []*model.Document, readTime time.Time) []model.ListenResponse {
+	var responses []model.ListenResponse
+	documents := make(map[string]map[string]interface{}, len(docs))
+	for _, doc := range docs {
+		name := t.documentName(doc)
+		data := documentToMap(doc)
+		documents[name] = data
+		if previous, ok := t.documents[name]; !ok || !sameDocumentData(previous, data) {
+			responses = append(responses, t.documentChange(name, data, doc.UpdateTime))
+		}
+	}
+	for name := range t.documents {
+		if _, ok := documents[name]; !ok {
+			responses = append(responses, t.documentRemove(name, readTime))
+		}
+	}
+	t.documents = documents
+	t.refreshNeeded = false
+	return responses
+}
+
+// Apply returns the messages for a realtime event of the target. The changes of a snapshot
+// event are followed by a NO_CHANGE target change at the commit time, so that clients
+// raise one snapshot for the whole commit.
+func (t *ListenTarget) Apply(event model.RealtimeEvent) []model.ListenResponse {
+	switch event.Type {
+	case model.EventTypeHeartbeat:
+		return nil
+	case model.EventTypeSnapshot:
+		var responses []model.ListenResponse
+		for _, change := range event.Changes {
+			responses = append(responses, t.applyChange(change)...)
+		}
+		if event.ResumeToken != "" {
+			t.resumeToken = event.ResumeToken
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		return append(responses, t.targetChange(model.TargetChangeNoChange, event.Timestamp))
+	default:
+		return t.applyChange(event)
+	}
+}
+
+// applyChange returns the messages for a document event of the target
+func (t *ListenTarget) applyChange(event model.RealtimeEvent) []model.ListenResponse {
+	if event.ResumeToken != "" {
+		t.resumeToken = event.ResumeToken
+	}
+
+	name := strings.Trim(event.FullPath, "/")
+	matches := event.Type != model.EventTypeRemoved && event.Data != nil && eventMatchesQuery(event, t.Query) && hasOrderFields(event.Data, t.Query)
+	if t.windowed() {
+		return t.applyToWindow(name, matches, event)
+	}
+	if matches {
+		t.documents[name] = event.Data
+		return []model.ListenResponse{t.documentChange(name, event.Data, event.Timestamp)}
+	}
+	if _, ok := t.documents[name]; !ok {
+		return nil
+	}
+	delete(t.documents, name)
+	return []model.ListenResponse{t.documentLeft(name, event)}
+}
+
+// applyToWindow applies an event to the result window of a limited query
+func (t *ListenTarget) applyToWindow(name string, matches bool, event model.RealtimeEvent) []model.ListenResponse {
+	_, inWindow := t.documents[name]
+	full := len(t.documents) >= t.Query.Limit
+
+	// With an offset, any change may shift the window
+	if t.Query.Offset > 0 {
+		if matches || inWindow {
+			t.refreshNeeded = true
+		}
+		return nil
+	}
+
+	if !matches {
+		if !inWindow {
+			return nil
+		}
+		// The freed slot is backfilled by the next document of the query
+		delete(t.documents, name)
+		t.refreshNeeded = full
+		return []model.ListenResponse{t.documentLeft(name, event)}
+	}
+
+	if !inWindow && full {
+		edge := t.windowEdge()
+		if !t.insideEdge(name, event.Data, edge) {
+			return nil
+		}
+	}
+	t.documents[name] = event.Data
+	responses := []model.ListenResponse{t.documentChange(name, event.Data, event.Timestamp)}
+
+	if len(t.documents) > t.Query.Limit {
+		// The document pushed the edge past the limit
+		edge := t.windowEdge()
+		delete(t.documents, edge)
+		responses = append(responses, t.documentRemove(edge, event.Timestamp))
+	} else if inWindow && full && t.windowEdge() == name {
+		// A document moved to the edge; one outside the window may now precede it
+		t.refreshNeeded = true
+	}
+	return responses
+}
+
+// windowed reports whether the target keeps a limited result window
+func (t *ListenTarget) windowed() bool {
+	return t.Query != nil && t.Query.Limit > 0
+}
+
+// windowEdge returns the document of the window that the limit evicts first: the last one
+// in query order, or the first one for limitToLast
+func (t *ListenTarget) windowEdge() string {
+	edge := ""
+	for name := range t.documents {
+		if edge == "" || t.insideEdge(edge, t.documents[edge], name) {
+			edge = name
+		}
+	}
+	return edge
+}
+
+// insideEdge reports whether a document belongs in the window before the edge document
+func (t *ListenTarget) insideEdge(name string, data map[string]interface{}, edge string) bool {
+	order := t.compareDocuments(name, data, edge, t.documents[edge])
+	if t.Query.LimitToLast {
+		return order > 0
+	}
+	return order < 0
+}
+
+// compareDocuments orders two documents by the query orders, then by name
+func (t *ListenTarget) compareDocuments(nameA string, dataA map[string]interface{}, nameB string, dataB map[string]interface{}) int {
+	for _, order := range t.Query.Orders {
+		segments := strings.Split(order.Field, ".")
+		result := compareOrderValues(getNestedField(dataA, segments), getNestedField(dataB, segments))
+		if order.Direction == model.DirectionDescending {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return strings.Compare(nameA, nameB)
+}
+
+// compareOrderValues compares field values for ordering, treating all numbers alike
+func compareOrderValues(a, b interface{}) int {
+	if numberA, ok := toFloat64(a); ok {
+		if numberB, ok := toFloat64(b); ok {
+			a, b = numberA, numberB
+		}
+	}
+	switch result := compare(a, b); result {
+	case -1, 0, 1:
+		return result
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+// sameDocumentData compares document data by its JSON encoding, so that numbers decoded
+// from events and read from storage with different Go types compare equal
+func sameDocumentData(a, b map[string]interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// toFloat64 converts numeric values to float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float32:
+		return float64(number), true
+	case float64:
+		return number, true
+	default:
+		return 0, false
+	}
+}
+
+// hasOrderFields reports whether the data has every field the query orders by; as in
+// Firestore, documents missing one are not part of ordered results
+func hasOrderFields(data map[string]interface{}, query *model.Query) bool {
+	if query == nil {
+		return true
+	}
+	for _, order := range query.Orders {
+		if getNestedField(data, strings.Split(order.Field, ".")) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// documentLeft returns the message for a document of the view that an event took out of it
+func (t *ListenTarget) documentLeft(name string, event model.RealtimeEvent) model.ListenResponse {
+	if event.Type == model.EventTypeRemoved {
+		return model.ListenResponse{DocumentDelete: &model.ListenDocumentDelete{
+			Document:         name,
+			RemovedTargetIDs: []model.SubscriptionID{t.ID},
+			ReadTime:         event.Timestamp,
+		}}
+	}
+	return t.documentRemove(name, event.Timestamp)
+}
+
+func (t *ListenTarget) documentRemove(name string, readTime time.Time) model.ListenResponse {
+	return model.ListenResponse{DocumentRemove: &model.ListenDocumentRemove{
+		Document:         name,
+		RemovedTargetIDs: []model.SubscriptionID{t.ID},
+		ReadTime:         readTime,
+	}}
+}
+
+// Remove returns the REMOVE message of the target, with the cause when it failed
+func (t *ListenTarget) Remove(code, message string) model.ListenResponse {
+	response := model.NewTargetChange(model.TargetChangeRemove, t.ID)
+	if code != "" {
+		response.TargetChange.Cause = &model.TargetChangeCause{Code: code, Message: message}
+	}
+	return response
+}
+
+func (t *ListenTarget) targetChange(changeType model.TargetChangeType, readTime time.Time) model.ListenResponse {
+	response := model.NewTargetChange(changeType, t.ID)
+	response.TargetChange.ReadTime = &readTime
+	response.TargetChange.ResumeToken = t.resumeToken
+	return response
+}
+
+func (t *ListenTarget) documentChange(name string, data map[string]interface{}, updateTime time.Time) model.ListenResponse {
+	if t.Query != nil {
+		data = projectFields(data, t.Query.SelectFields)
+	}
+	return model.ListenResponse{DocumentChange: &model.ListenDocumentChange{
+		Document:  &model.ListenDocument{Name: name, Fields: data, UpdateTime: updateTime},
+		TargetIDs: []model.SubscriptionID{t.ID},
+	}}
+}
+
+// documentName returns the full path of a document read for the target
+func (t *ListenTarget) documentName(doc *model.Document) string {
+	if index := strings.Index(doc.Path, "projects/"); index >= 0 && strings.Contains(doc.Path, "/documents/") {
+		return doc.Path[index:]
+	}
+	if t.pathInfo.IsDocument {
+		return t.FullPath
+	}
+	return t.FullPath + "/" + doc.DocumentID
+}
@@ -0,0 +1,178 @@
+package usecase_test
+
+import (
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const listenCollection = "projects/p/databases/d/documents/rooms"
+
+func TestNewListenTarget_RejectsQueryOnDocument(t *testing.T) {
+	_, err := usecase.NewListenTarget("t1", listenCollection+"/r1", &model.Query{})
+	assert.Error(t, err)
+
+	target, err := usecase.NewListenTarget("t1", listenCollection+"/r1", nil)
+	require.NoError(t, err)
+	assert.False(t, target.IsCurrent())
+}
+
+func TestListenTarget_Snapshot(t *testing.T) {
+	target, err := usecase.NewListenTarget("t1", listenCollection, nil)
+	require.NoError(t, err)
+
+	readTime := time.Now()
+	docs := []*model.Document{{DocumentID: "r1", Fields: map[string]*model.FieldValue{"name": model.NewFieldValue("lobby")}}}
+	responses := target.Snapshot(docs, readTime, true)
+
+	require.Len(t, responses, 3)
+	assert.Equal(t, model.TargetChangeReset, responses[0].TargetChange.TargetChangeType)
+	require.NotNil(t, responses[1].DocumentChange)
+	assert.Equal(t, listenCollection+"/r1", responses[1].DocumentChange.Document.Name)
+	assert.Equal(t, []model.SubscriptionID{"t1"}, responses[1].DocumentChange.TargetIDs)
+	current := responses[2].TargetChange
+	assert.Equal(t, model.TargetChangeCurrent, current.TargetChangeType)
+	assert.Equal(t, readTime, *current.ReadTime)
+	assert.True(t, target.IsCurrent())
+}
+
+func TestListenTarget_Apply(t *testing.T) {
+	query := &model.Query{Filters: []model.Filter{{Field: "open", Operator: model.OperatorEqual, Value: "yes"}}}
+	target, err := usecase.NewListenTarget("t1", listenCollection, query)
+	require.NoError(t, err)
+	target.Snapshot(nil, time.Now(), false)
+
+	name := listenCollection + "/r1"
+	event := func(eventType model.EventType, open string) model.RealtimeEvent {
+		event := model.RealtimeEvent{Type: eventType, FullPath: name, Timestamp: time.Now(), ResumeToken: "token"}
+		if open != "" {
+			event.Data = map[string]interface{}{"open": open}
+		}
+		return event
+	}
+
+	// Entering the query
+	responses := target.Apply(event(model.EventTypeAdded, "yes"))
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].DocumentChange)
+	assert.Equal(t, name, responses[0].DocumentChange.Document.Name)
+	assert.Equal(t, model.ResumeToken("token"), target.ResumeToken())
+
+	// Leaving the query
+	responses = target.Apply(event(model.EventTypeModified, "no"))
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].DocumentRemove)
+	assert.Equal(t, name, responses[0].DocumentRemove.Document)
+
+	// Changes outside the view are ignored
+	assert.Empty(t, target.Apply(event(model.EventTypeModified, "no")))
+	assert.Empty(t, target.Apply(event(model.EventTypeRemoved, "")))
+
+	// Deleting a document of the view
+	target.Apply(event(model.EventTypeModified, "yes"))
+	responses = target.Apply(event(model.EventTypeRemoved, ""))
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].DocumentDelete)
+	assert.Equal(t, []model.SubscriptionID{"t1"}, responses[0].DocumentDelete.RemovedTargetIDs)
+}
+
+func TestListenTarget_Remove(t *testing.T) {
+	target, err := usecase.NewListenTarget("t1", listenCollection, nil)
+	require.NoError(t, err)
+
+	response := target.Remove("INTERNAL", "read failed")
+	assert.Equal(t, model.TargetChangeRemove, response.TargetChange.TargetChangeType)
+	assert.Equal(t, "INTERNAL", response.TargetChange.Cause.Code)
+	assert.Nil(t, target.Remove("", "").TargetChange.Cause)
+}
+
+func windowDoc(id string, score int64) *model.Document {
+	return &model.Document{DocumentID: id, Fields: map[string]*model.FieldValue{"score": model.NewFieldValue(score)}}
+}
+
+func scoreEvent(eventType model.EventType, id string, score int) model.RealtimeEvent {
+	event := model.RealtimeEvent{Type: eventType, FullPath: listenCollection + "/" + id, Timestamp: time.Now()}
+	if eventType != model.EventTypeRemoved {
+		event.Data = map[string]interface{}{"score": score}
+	}
+	return event
+}
+
+func TestListenTarget_LimitWindow(t *testing.T) {
+	query := &model.Query{Orders: []model.Order{{Field: "score", Direction: model.DirectionDescending}}, Limit: 2}
+	target, err := usecase.NewListenTarget("top", listenCollection, query)
+	require.NoError(t, err)
+	target.Snapshot([]*model.Document{windowDoc("a", 90), windowDoc("b", 80)}, time.Now(), false)
+
+	// A write below the window is ignored
+	assert.Empty(t, target.Apply(scoreEvent(model.EventTypeAdded, "d", 10)))
+
+	// A write entering the window pushes the last document out
+	responses := target.Apply(scoreEvent(model.EventTypeAdded, "c", 85))
+	require.Len(t, responses, 2)
+	assert.Equal(t, listenCollection+"/c", responses[0].DocumentChange.Document.Name)
+	require.NotNil(t, responses[1].DocumentRemove)
+	assert.Equal(t, listenCollection+"/b", responses[1].DocumentRemove.Document)
+	assert.False(t, target.NeedsRefresh())
+
+	// Deleting a document frees a slot that is backfilled by a new read
+	responses = target.Apply(scoreEvent(model.EventTypeRemoved, "a", 0))
+	require.Len(t, responses, 1)
+	require.NotNil(t, responses[0].DocumentDelete)
+	assert.True(t, target.NeedsRefresh())
+
+	responses = target.Refresh([]*model.Document{windowDoc("c", 85), windowDoc("b", 80)}, time.Now())
+	require.Len(t, responses, 1)
+	assert.Equal(t, listenCollection+"/b", responses[0].DocumentChange.Document.Name)
+	assert.False(t, target.NeedsRefresh())
+
+	// A document dropping to the edge may be overtaken by one outside the window
+	responses = target.Apply(scoreEvent(model.EventTypeModified, "c", 5))
+	require.Len(t, responses, 1)
+	assert.True(t, target.NeedsRefresh())
+}
+
+func TestListenTarget_LimitToLastWindow(t *testing.T) {
+	query := &model.Query{Orders: []model.Order{{Field: "score", Direction: model.DirectionAscending}}, Limit: 2, LimitToLast: true}
+	target, err := usecase.NewListenTarget("last", listenCollection, query)
+	require.NoError(t, err)
+	target.Snapshot([]*model.Document{windowDoc("b", 80), windowDoc("a", 90)}, time.Now(), false)
+
+	assert.Empty(t, target.Apply(scoreEvent(model.EventTypeAdded, "d", 10)))
+
+	responses := target.Apply(scoreEvent(model.EventTypeAdded, "c", 95))
+	require.Len(t, responses, 2)
+	assert.Equal(t, listenCollection+"/c", responses[0].DocumentChange.Document.Name)
+	assert.Equal(t, listenCollection+"/b", responses[1].DocumentRemove.Document)
+}
+
+func TestListenTarget_ApplySnapshotEvent(t *testing.T) {
+	target, err := usecase.NewListenTarget("t1", listenCollection, nil)
+	require.NoError(t, err)
+	target.Snapshot(nil, time.Now(), false)
+
+	commitTime := time.Now()
+	responses := target.Apply(model.RealtimeEvent{
+		Type:        model.EventTypeSnapshot,
+		Timestamp:   commitTime,
+		ResumeToken: "commit",
+		Changes: []model.RealtimeEvent{
+			{Type: model.EventTypeAdded, FullPath: listenCollection + "/r1", Data: map[string]interface{}{"n": "1"}, Timestamp: commitTime},
+			{Type: model.EventTypeAdded, FullPath: listenCollection + "/r2", Data: map[string]interface{}{"n": "2"}, Timestamp: commitTime},
+		},
+	})
+
+	require.Len(t, responses, 3)
+	assert.NotNil(t, responses[0].DocumentChange)
+	assert.NotNil(t, responses[1].DocumentChange)
+	consistent := responses[2].TargetChange
+	require.NotNil(t, consistent)
+	assert.Equal(t, model.TargetChangeNoChange, consistent.TargetChangeType)
+	assert.Equal(t, commitTime, *consistent.ReadTime)
+	assert.Equal(t, model.ResumeToken("commit"), consistent.ResumeToken)
+}
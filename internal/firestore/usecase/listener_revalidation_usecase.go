@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/shared/eventbus"
+	"firestore-clone/internal/shared/logger"
+
+	"go.uber.org/zap"
+)
+
+// ListenerRevalidation asks for the active listeners to be checked again: those on a
+// database after its rules change, or those of a user after their roles change. Empty
+// fields do not restrict the listeners checked.
+type ListenerRevalidation struct {
+	ProjectID  string `json:"projectId,omitempty"`
+	DatabaseID string `json:"databaseId,omitempty"`
+	UserID     string `json:"userId,omitempty"`
+	// Version is the released ruleset which triggered the revalidation, for the logs
+	Version string `json:"version,omitempty"`
+}
+
+// ListenerRevalidator is implemented by the listen transports: it closes the listeners
+// in scope that the current rules deny and returns them
+type ListenerRevalidator interface {
+	RevalidateListeners(ctx context.Context, scope ListenerRevalidation) []RevokedListener
+}
+
+// ListenerRevalidationFanout is the secondary port distributing revalidations to every
+// server instance. Receive delivers the revalidations published by any instance, this one
+// included, until ctx is done or the subscription fails.
+type ListenerRevalidationFanout interface {
+	Publish(ctx context.Context, revalidation ListenerRevalidation) error
+	Receive(ctx context.Context, deliver func(ListenerRevalidation)) error
+}
+
+// ListenerRevalidationUsecase checks the active listeners of every server instance again
+// when the access they were granted may have changed
+type ListenerRevalidationUsecase interface {
+	// Revalidate checks the listeners in scope on every instance. Without a fan-out, or
+	// when publishing fails, only the listeners of this instance are checked.
+	Revalidate(ctx context.Context, scope ListenerRevalidation)
+	// Start receives the revalidations of every instance until Stop
+	Start(ctx context.Context)
+	Stop()
+}
+
+type listenerRevalidationUsecase struct {
+	revalidators []ListenerRevalidator
+	fanout       ListenerRevalidationFanout
+	bus          *eventbus.EventBus
+	log          logger.Logger
+
+	stop context.CancelFunc
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// NewListenerRevalidationUsecase creates the revalidation of the listeners of the given
+// transports; fanout may be nil on a single instance. Revoked listeners are published
+// on the bus as security.rule_violation events.
+func NewListenerRevalidationUsecase(fanout ListenerRevalidationFanout, bus *eventbus.EventBus, log logger.Logger, revalidators ...ListenerRevalidator) ListenerRevalidationUsecase {
+	return &listenerRevalidationUsecase{revalidators: revalidators, fanout: fanout, bus: bus, log: log}
+}
+
+func (uc *listenerRevalidationUsecase) Revalidate(ctx context.Context, scope ListenerRevalidation) {
+	if uc.fanout != nil {
+		err := uc.fanout.Publish(ctx, scope)
+		if err == nil {
+			return
+		}
+		uc.log.Warn("Failed to publish listener revalidation, checking local listeners only",
+			zap.String("projectID", scope.ProjectID),
+			zap.String("databaseID", scope.DatabaseID),
+			zap.String("userID", scope.UserID),
+			zap.Error(err))
+	}
+	uc.revalidateLocal(ctx, scope)
+}
+
+// revalidateLocal checks the listeners of this instance and audits those revoked
+func (uc *listenerRevalidationUsecase) revalidateLocal(ctx context.Context, scope ListenerRevalidation) {
+	for _, revalidator := range uc.revalidators {
+		for _, listener := range revalidator.RevalidateListeners(ctx, scope) {
+			uc.log.Warn("Listener closed after access change",
+				zap.String("projectID", listener.ProjectID),
+				zap.String("databaseID", listener.DatabaseID),
+				zap.String("subscriptionID", listener.SubscriptionID),
+				zap.String("path", listener.Path),
+				zap.String("userID", listener.UserID),
+				zap.String("rulesVersion", scope.Version),
+				zap.String("reason", listener.Reason))
+			PublishRevokedListener(ctx, uc.bus, listener)
+		}
+	}
+}
+
+func (uc *listenerRevalidationUsecase) Start(ctx context.Context) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.fanout == nil || uc.stop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	uc.stop = cancel
+	uc.done = make(chan struct{})
+	go uc.receive(ctx, uc.done)
+}
+
+func (uc *listenerRevalidationUsecase) Stop() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.stop == nil {
+		return
+	}
+	uc.stop()
+	<-uc.done
+	uc.stop = nil
+}
+
+// receive checks the local listeners for every published revalidation, resubscribing
+// with backoff after failures
+func (uc *listenerRevalidationUsecase) receive(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := uc.fanout.Receive(ctx, func(scope ListenerRevalidation) {
+			backoff = time.Second
+			uc.revalidateLocal(ctx, scope)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		uc.log.Warn("Listener revalidation fan-out interrupted, resubscribing", zap.Error(err), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/eventbus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedRevalidationFanout stands in for Redis pub/sub between instances
+type sharedRevalidationFanout struct {
+	mu         sync.Mutex
+	receivers  []chan usecase.ListenerRevalidation
+	publishErr error
+}
+
+func (f *sharedRevalidationFanout) Publish(ctx context.Context, revalidation usecase.ListenerRevalidation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.publishErr != nil {
+		return f.publishErr
+	}
+	for _, receiver := range f.receivers {
+		receiver <- revalidation
+	}
+	return nil
+}
+
+func (f *sharedRevalidationFanout) Receive(ctx context.Context, deliver func(usecase.ListenerRevalidation)) error {
+	receiver := make(chan usecase.ListenerRevalidation, 16)
+	f.mu.Lock()
+	f.receivers = append(f.receivers, receiver)
+	f.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case revalidation := <-receiver:
+			deliver(revalidation)
+		}
+	}
+}
+
+func (f *sharedRevalidationFanout) subscribed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.receivers)
+}
+
+// recordingRevalidator records the scopes it is asked to revalidate and revokes one listener each time
+type recordingRevalidator struct {
+	scopes chan usecase.ListenerRevalidation
+}
+
+func (r *recordingRevalidator) RevalidateListeners(ctx context.Context, scope usecase.ListenerRevalidation) []usecase.RevokedListener {
+	r.scopes <- scope
+	return []usecase.RevokedListener{{ProjectID: scope.ProjectID, DatabaseID: scope.DatabaseID, UserID: scope.UserID}}
+}
+
+func TestListenerRevalidation_ReachesEveryInstance(t *testing.T) {
+	fanout := &sharedRevalidationFanout{}
+	first := &recordingRevalidator{scopes: make(chan usecase.ListenerRevalidation, 4)}
+	second := &recordingRevalidator{scopes: make(chan usecase.ListenerRevalidation, 4)}
+	bus := eventbus.NewEventBus(nil)
+	violations := make(chan struct{}, 4)
+	bus.Subscribe(eventbus.EventTypeSecurityRuleViolation, func(ctx context.Context, event eventbus.Event) error {
+		violations <- struct{}{}
+		return nil
+	})
+
+	instance1 := usecase.NewListenerRevalidationUsecase(fanout, bus, &LegacyMockLogger{}, first)
+	instance2 := usecase.NewListenerRevalidationUsecase(fanout, nil, &LegacyMockLogger{}, second)
+	instance1.Start(context.Background())
+	instance2.Start(context.Background())
+	t.Cleanup(instance1.Stop)
+	t.Cleanup(instance2.Stop)
+	require.Eventually(t, func() bool { return fanout.subscribed() == 2 }, time.Second, 10*time.Millisecond)
+
+	// A role change seen by one instance revalidates the listeners of the user on both
+	scope := usecase.ListenerRevalidation{UserID: "alice"}
+	instance1.Revalidate(context.Background(), scope)
+	for _, revalidator := range []*recordingRevalidator{first, second} {
+		select {
+		case received := <-revalidator.scopes:
+			assert.Equal(t, scope, received)
+		case <-time.After(time.Second):
+			t.Fatal("revalidation did not reach every instance")
+		}
+	}
+	select {
+	case <-violations:
+	case <-time.After(time.Second):
+		t.Fatal("revoked listener was not audited")
+	}
+}
+
+func TestListenerRevalidation_FallsBackToLocalListeners(t *testing.T) {
+	fanout := &sharedRevalidationFanout{publishErr: errors.New("redis down")}
+	local := &recordingRevalidator{scopes: make(chan usecase.ListenerRevalidation, 4)}
+	uc := usecase.NewListenerRevalidationUsecase(fanout, nil, &LegacyMockLogger{}, local)
+
+	scope := usecase.ListenerRevalidation{ProjectID: "p", DatabaseID: "d"}
+	uc.Revalidate(context.Background(), scope)
+	require.Len(t, local.scopes, 1)
+	assert.Equal(t, scope, <-local.scopes)
+
+	// Without a fan-out the local listeners are checked directly
+	uc = usecase.NewListenerRevalidationUsecase(nil, nil, &LegacyMockLogger{}, local)
+	uc.Revalidate(context.Background(), scope)
+	assert.Len(t, local.scopes, 1)
+}
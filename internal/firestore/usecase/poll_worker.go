@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/shared/logger"
+)
+
+// pollWorker runs a drain function in the background on every poll interval and whenever
+// it is signalled. The drain function returns how many items it processed; the worker
+// keeps draining while full batches come back.
+type pollWorker struct {
+	name      string
+	interval  time.Duration
+	batchSize int
+	drain     func(ctx context.Context) (int, error)
+	logger    logger.Logger
+
+	wake  chan struct{}
+	stop  context.CancelFunc
+	done  chan struct{}
+	runMu sync.Mutex
+}
+
+func newPollWorker(name string, interval time.Duration, batchSize int, drain func(ctx context.Context) (int, error), log logger.Logger) *pollWorker {
+	return &pollWorker{
+		name:      name,
+		interval:  interval,
+		batchSize: batchSize,
+		drain:     drain,
+		logger:    log,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Start runs the worker until Stop is called; starting a running worker does nothing
+func (w *pollWorker) Start(ctx context.Context) {
+	w.runMu.Lock()
+	defer w.runMu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w.stop = cancel
+	w.done = make(chan struct{})
+	go w.run(ctx, w.done)
+	w.logger.Info(w.name+" started", "pollInterval", w.interval)
+}
+
+// Stop stops the worker and waits for the drain in progress
+func (w *pollWorker) Stop() {
+	w.runMu.Lock()
+	defer w.runMu.Unlock()
+	if w.stop == nil {
+		return
+	}
+	w.stop()
+	<-w.done
+	w.stop = nil
+}
+
+func (w *pollWorker) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		// Keep draining while full batches are due
+		for {
+			processed, err := w.drain(ctx)
+			if err != nil {
+				w.logger.Error(w.name+" failed", "error", err)
+			}
+			if err != nil || processed < w.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
+
+// signal wakes the worker up without blocking
+func (w *pollWorker) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
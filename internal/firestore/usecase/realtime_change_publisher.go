@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/logger"
+)
+
+// realtimeChangePublisher publishes committed document changes to realtime listeners. It
+// is fed by the document change repository of the instance handling the write, or by a
+// MongoDB change stream source when every instance has to see every write.
+type realtimeChangePublisher struct {
+	realtimeUC RealtimeUsecase
+	logger     logger.Logger
+}
+
+// NewRealtimeChangePublisher creates a DocumentChangeHandler publishing changes as realtime events
+func NewRealtimeChangePublisher(realtimeUC RealtimeUsecase, log logger.Logger) DocumentChangeHandler {
+	return &realtimeChangePublisher{realtimeUC: realtimeUC, logger: log}
+}
+
+// WatchesChanges implements DocumentChangeHandler; listeners may watch any database
+func (p *realtimeChangePublisher) WatchesChanges(ctx context.Context, projectID, databaseID string) bool {
+	return true
+}
+
+// HandleChanges implements DocumentChangeHandler. The changes of one commit are published
+// together when the realtime usecase supports it, so listeners never see part of a commit.
+func (p *realtimeChangePublisher) HandleChanges(ctx context.Context, changes []*model.DocumentChange) {
+	if commitPublisher, ok := p.realtimeUC.(RealtimeCommitPublisher); ok && len(changes) > 1 {
+		events := make([]model.RealtimeEvent, 0, len(changes))
+		for _, change := range changes {
+			events = append(events, RealtimeEventFromChange(change))
+		}
+		if err := commitPublisher.PublishCommit(ctx, events); err != nil {
+			p.logger.Warn("Failed to publish realtime commit", "changes", len(events), "error", err)
+		}
+		return
+	}
+	for _, change := range changes {
+		event := RealtimeEventFromChange(change)
+		if err := p.realtimeUC.PublishEvent(ctx, event); err != nil {
+			p.logger.Warn("Failed to publish realtime event", "path", event.FullPath, "error", err)
+		}
+	}
+}
+
+// RealtimeEventFromChange converts a document change into the realtime event sent to
+// listeners, with the previous document data as OldData
+func RealtimeEventFromChange(change *model.DocumentChange) model.RealtimeEvent {
+	event := model.RealtimeEvent{
+		FullPath:     fmt.Sprintf("projects/%s/databases/%s/documents/%s", change.ProjectID, change.DatabaseID, change.DocumentPath),
+		ProjectID:    change.ProjectID,
+		DatabaseID:   change.DatabaseID,
+		DocumentPath: change.DocumentPath,
+		Timestamp:    change.CommitTime,
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	switch change.Kind() {
+	case model.DocumentChangeCreated:
+		event.Type = model.EventTypeAdded
+	case model.DocumentChangeUpdated:
+		event.Type = model.EventTypeModified
+	default:
+		event.Type = model.EventTypeRemoved
+	}
+	if change.After != nil {
+		event.Data = documentToMap(change.After)
+	}
+	if change.Before != nil {
+		event.OldData = documentToMap(change.Before)
+	}
+	return event
+}
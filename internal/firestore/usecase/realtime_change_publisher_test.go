@@ -0,0 +1,134 @@
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealtimeChangePublisher_PublishesWritesWithPreviousData(t *testing.T) {
+	ctx := context.Background()
+	realtimeUC := NewMockRealtimeUsecase()
+	repo := NewDocumentChangeRepository(newMemDocRepo(), NewRealtimeChangePublisher(realtimeUC, &MockLogger{}))
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Grace"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "users", "u1"))
+
+	events, err := realtimeUC.GetEventsSince(ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	for _, event := range events {
+		assert.Equal(t, "projects/p/databases/d/documents/users/u1", event.FullPath)
+		assert.Equal(t, "users/u1", event.DocumentPath)
+	}
+	assert.Equal(t, model.EventTypeAdded, events[0].Type)
+	assert.Equal(t, "Ada", events[0].Data["name"])
+	assert.Nil(t, events[0].OldData)
+
+	assert.Equal(t, model.EventTypeModified, events[1].Type)
+	assert.Equal(t, "Grace", events[1].Data["name"])
+	assert.Equal(t, "Ada", events[1].OldData["name"])
+
+	assert.Equal(t, model.EventTypeRemoved, events[2].Type)
+	assert.Nil(t, events[2].Data)
+	assert.Equal(t, "Grace", events[2].OldData["name"])
+}
+
+func TestRealtimeEventFromChange_UsesCommitTime(t *testing.T) {
+	commitTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := RealtimeEventFromChange(&model.DocumentChange{
+		ProjectID:    "p",
+		DatabaseID:   "d",
+		DocumentPath: "rooms/r1/messages/m1",
+		After:        &model.Document{Fields: fields(map[string]interface{}{"text": "hi"})},
+		CommitTime:   commitTime,
+	})
+
+	assert.Equal(t, model.EventTypeAdded, event.Type)
+	assert.Equal(t, "projects/p/databases/d/documents/rooms/r1/messages/m1", event.FullPath)
+	assert.Equal(t, commitTime, event.Timestamp)
+	assert.Equal(t, "hi", event.Data["text"])
+}
+
+// batchDocRepo applies batch writes one document at a time and stamps them with the
+// commit time of the context, as storage does
+type batchDocRepo struct {
+	*memDocRepo
+}
+
+func (r *batchDocRepo) RunBatchWrite(ctx context.Context, projectID, databaseID string, writes []*model.WriteOperation) ([]*model.WriteResult, error) {
+	for _, write := range writes {
+		segments := strings.Split(write.Path, "/")
+		doc, err := r.CreateDocument(ctx, projectID, databaseID, segments[len(segments)-2], segments[len(segments)-1], fields(write.Data))
+		if err != nil {
+			return nil, err
+		}
+		doc.UpdateTime = utils.GetCommitTimeOrNow(ctx)
+		time.Sleep(time.Millisecond)
+	}
+	return make([]*model.WriteResult, len(writes)), nil
+}
+
+func TestRealtimeChangePublisher_DeliversBatchAsOneSnapshot(t *testing.T) {
+	ctx := context.Background()
+	realtimeUC := NewRealtimeUsecase(&MockLogger{})
+	store := &batchDocRepo{newMemDocRepo()}
+	repo := NewDocumentChangeRepository(store, NewRealtimeChangePublisher(realtimeUC, &MockLogger{}))
+
+	collectionEvents := make(chan model.RealtimeEvent, 10)
+	_, err := realtimeUC.Subscribe(ctx, SubscribeRequest{SubscriberID: "c1", SubscriptionID: "users", FirestorePath: "projects/p/databases/d/documents/users", EventChannel: collectionEvents})
+	require.NoError(t, err)
+	documentEvents := make(chan model.RealtimeEvent, 10)
+	_, err = realtimeUC.Subscribe(ctx, SubscribeRequest{SubscriberID: "c1", SubscriptionID: "u1", FirestorePath: "projects/p/databases/d/documents/users/u1", EventChannel: documentEvents})
+	require.NoError(t, err)
+
+	var writes []*model.WriteOperation
+	for _, id := range []string{"u1", "u2", "u3"} {
+		writes = append(writes, &model.WriteOperation{Type: model.WriteTypeCreate, Path: "projects/p/databases/d/documents/users/" + id, Data: map[string]interface{}{"name": id}})
+	}
+	_, err = repo.RunBatchWrite(ctx, "p", "d", writes)
+	require.NoError(t, err)
+
+	var snapshot model.RealtimeEvent
+	select {
+	case snapshot = <-collectionEvents:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the batch snapshot")
+	}
+	assert.Equal(t, model.EventTypeSnapshot, snapshot.Type)
+	require.Len(t, snapshot.Changes, 3)
+	for _, change := range snapshot.Changes {
+		assert.Equal(t, snapshot.Timestamp, change.Timestamp)
+		assert.Equal(t, snapshot.ResumeToken, change.ResumeToken)
+	}
+	assert.Empty(t, collectionEvents)
+
+	// The commit time was assigned before the writes and stored on every document
+	for _, id := range []string{"u1", "u2", "u3"} {
+		doc, err := store.GetDocument(ctx, "p", "d", "users", id)
+		require.NoError(t, err)
+		assert.True(t, snapshot.Timestamp.Equal(doc.UpdateTime), id)
+	}
+
+	// A listener seeing one change of the commit gets it alone, with the commit token
+	select {
+	case event := <-documentEvents:
+		assert.Equal(t, model.EventTypeAdded, event.Type)
+		assert.Equal(t, snapshot.ResumeToken, event.ResumeToken)
+		assert.Equal(t, snapshot.Timestamp, event.Timestamp)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the document event")
+	}
+}
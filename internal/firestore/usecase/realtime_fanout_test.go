@@ -0,0 +1,180 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/usecase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedFanout stands in for Redis: every instance publishes to and receives from it, and
+// its event store is shared as well
+type sharedFanout struct {
+	mu        sync.Mutex
+	sequences map[string]int64
+	receivers []chan model.RealtimeEvent
+	store     usecase.EventStore
+}
+
+func newSharedFanout() *sharedFanout {
+	return &sharedFanout{sequences: make(map[string]int64), store: usecase.NewInMemoryEventStore(&LegacyMockLogger{})}
+}
+
+func (f *sharedFanout) Publish(ctx context.Context, event model.RealtimeEvent) (model.RealtimeEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if event.Type == model.EventTypeSnapshot {
+		event.Changes = append([]model.RealtimeEvent(nil), event.Changes...)
+		for i := range event.Changes {
+			change := &event.Changes[i]
+			f.sequences[change.FullPath]++
+			change.SequenceNumber = f.sequences[change.FullPath]
+			change.ResumeToken = model.ResumeToken(fmt.Sprintf("%020d", change.SequenceNumber))
+			if err := f.store.StoreEvent(ctx, *change); err != nil {
+				return event, err
+			}
+		}
+	} else {
+		f.sequences[event.FullPath]++
+		event.SequenceNumber = f.sequences[event.FullPath]
+		event.ResumeToken = model.ResumeToken(fmt.Sprintf("%020d", event.SequenceNumber))
+		if err := f.store.StoreEvent(ctx, event); err != nil {
+			return event, err
+		}
+	}
+	for _, receiver := range f.receivers {
+		receiver <- event
+	}
+	return event, nil
+}
+
+func (f *sharedFanout) Receive(ctx context.Context, deliver func(model.RealtimeEvent)) error {
+	receiver := make(chan model.RealtimeEvent, 100)
+	f.mu.Lock()
+	f.receivers = append(f.receivers, receiver)
+	f.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-receiver:
+			deliver(event)
+		}
+	}
+}
+
+func (f *sharedFanout) receiverCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.receivers)
+}
+
+func startFanoutInstance(t *testing.T, fanout *sharedFanout) usecase.RealtimeUsecase {
+	realtimeUC := usecase.NewRealtimeUsecaseWithFanout(&LegacyMockLogger{}, fanout.store, fanout)
+	receiver, ok := realtimeUC.(usecase.RealtimeFanoutReceiver)
+	require.True(t, ok)
+	receiver.Start(context.Background())
+	t.Cleanup(receiver.Stop)
+	return realtimeUC
+}
+
+func receiveEvent(t *testing.T, events <-chan model.RealtimeEvent) model.RealtimeEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for realtime event")
+		return model.RealtimeEvent{}
+	}
+}
+
+func TestRealtimeFanout_DeliversEventsOfOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	fanout := newSharedFanout()
+	instanceA := startFanoutInstance(t, fanout)
+	instanceB := startFanoutInstance(t, fanout)
+	require.Eventually(t, func() bool { return fanout.receiverCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	path := "projects/p/databases/d/documents/users/u1"
+	events := make(chan model.RealtimeEvent, 10)
+	_, err := instanceB.Subscribe(ctx, usecase.SubscribeRequest{SubscriberID: "client", SubscriptionID: "s1", FirestorePath: path, EventChannel: events})
+	require.NoError(t, err)
+
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeAdded, FullPath: path, Data: map[string]interface{}{"n": "1"}}))
+	require.NoError(t, instanceB.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeModified, FullPath: path, Data: map[string]interface{}{"n": "2"}}))
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeModified, FullPath: path, Data: map[string]interface{}{"n": "3"}}))
+
+	for i := int64(1); i <= 3; i++ {
+		event := receiveEvent(t, events)
+		assert.Equal(t, i, event.SequenceNumber)
+		assert.Equal(t, fmt.Sprint(i), event.Data["n"])
+		assert.Equal(t, "s1", event.SubscriptionID)
+	}
+}
+
+func TestRealtimeFanout_ResumeTokenHonoredByOtherInstance(t *testing.T) {
+	ctx := context.Background()
+	fanout := newSharedFanout()
+	instanceA := startFanoutInstance(t, fanout)
+	instanceB := startFanoutInstance(t, fanout)
+	require.Eventually(t, func() bool { return fanout.receiverCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	path := "projects/p/databases/d/documents/rooms/r1"
+	eventsA := make(chan model.RealtimeEvent, 10)
+	_, err := instanceA.Subscribe(ctx, usecase.SubscribeRequest{SubscriberID: "client", SubscriptionID: "s1", FirestorePath: path, EventChannel: eventsA})
+	require.NoError(t, err)
+
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeAdded, FullPath: path}))
+	token := receiveEvent(t, eventsA).ResumeToken
+	require.NotEmpty(t, token)
+
+	// The client disconnects from A; changes keep coming while it reconnects to B
+	require.NoError(t, instanceA.UnsubscribeAll(ctx, "client"))
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeModified, FullPath: path}))
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeRemoved, FullPath: path}))
+
+	eventsB := make(chan model.RealtimeEvent, 10)
+	_, err = instanceB.Subscribe(ctx, usecase.SubscribeRequest{SubscriberID: "client", SubscriptionID: "s1", FirestorePath: path, EventChannel: eventsB, ResumeToken: token})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), receiveEvent(t, eventsB).SequenceNumber)
+	assert.Equal(t, int64(3), receiveEvent(t, eventsB).SequenceNumber)
+}
+
+func TestRealtimeFanout_CommitDeliveredAsOneSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fanout := newSharedFanout()
+	instanceA := startFanoutInstance(t, fanout)
+	instanceB := startFanoutInstance(t, fanout)
+	require.Eventually(t, func() bool { return fanout.receiverCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	collection := "projects/p/databases/d/documents/rooms"
+	events := make(chan model.RealtimeEvent, 10)
+	_, err := instanceB.Subscribe(ctx, usecase.SubscribeRequest{SubscriberID: "client", SubscriptionID: "rooms", FirestorePath: collection, EventChannel: events})
+	require.NoError(t, err)
+
+	// An earlier write of another document does not hide the commit from the collection listener
+	require.NoError(t, instanceA.PublishEvent(ctx, model.RealtimeEvent{Type: model.EventTypeAdded, FullPath: collection + "/r9"}))
+	assert.Equal(t, collection+"/r9", receiveEvent(t, events).FullPath)
+
+	commitTime := time.Now()
+	publisher, ok := instanceA.(usecase.RealtimeCommitPublisher)
+	require.True(t, ok)
+	require.NoError(t, publisher.PublishCommit(ctx, []model.RealtimeEvent{
+		{Type: model.EventTypeAdded, FullPath: collection + "/r1", ProjectID: "p", DatabaseID: "d", Timestamp: commitTime},
+		{Type: model.EventTypeAdded, FullPath: collection + "/r2", ProjectID: "p", DatabaseID: "d", Timestamp: commitTime},
+	}))
+
+	snapshot := receiveEvent(t, events)
+	assert.Equal(t, model.EventTypeSnapshot, snapshot.Type)
+	require.Len(t, snapshot.Changes, 2)
+	assert.Equal(t, "rooms", snapshot.SubscriptionID)
+	assert.Equal(t, collection+"/r2", snapshot.Changes[1].FullPath)
+}
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -73,8 +74,9 @@ type SubscriptionOptions struct {
 	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
 }
 
-// PermissionValidator validates access permissions
-type PermissionValidator func(subscriberID, firestorePath string) error
+// PermissionValidator validates the access permissions of an active subscription, whose
+// query is checked along with its path
+type PermissionValidator func(subscription *Subscription) error
 
 // HealthStatus represents service health state
 type HealthStatus struct {
@@ -107,6 +109,11 @@ type Subscription struct {
 	Query          *model.Query               `json:"query,omitempty"`
 	IsActive       bool                       `json:"is_active"`
 	Options        SubscriptionOptions        `json:"options"`
+
+	// With a fan-out, deliveries are serialized per subscription and events already
+	// delivered by a resume replay are skipped by their per-path sequence
+	deliverMu     sync.Mutex
+	lastSequences map[string]int64
 }
 
 // EventStore defines the secondary port for event persistence
@@ -117,6 +124,30 @@ type EventStore interface {
 	GetEventCount(firestorePath string) int
 }
 
+// RealtimeFanout is the secondary port distributing realtime events to every server
+// instance. Publish records an event and assigns its per-path sequence number and resume
+// token, shared by all instances; Receive delivers the events published by any instance,
+// in sequence order per path, until ctx is done or the subscription fails. A snapshot
+// event is published and received as a whole, with the sequences and tokens of its changes.
+type RealtimeFanout interface {
+	Publish(ctx context.Context, event model.RealtimeEvent) (model.RealtimeEvent, error)
+	Receive(ctx context.Context, deliver func(model.RealtimeEvent)) error
+}
+
+// RealtimeFanoutReceiver is implemented by realtime usecases that deliver the events of
+// other server instances to their local subscribers while started
+type RealtimeFanoutReceiver interface {
+	Start(ctx context.Context)
+	Stop()
+}
+
+// RealtimeCommitPublisher is implemented by realtime usecases that deliver the events of
+// one commit together: each subscription gets the changes it sees in a single snapshot
+// event sharing the commit time and resume token
+type RealtimeCommitPublisher interface {
+	PublishCommit(ctx context.Context, events []model.RealtimeEvent) error
+}
+
 // InMemoryEventStore implements EventStore with in-memory storage
 type InMemoryEventStore struct {
 	events       map[string][]model.RealtimeEvent
@@ -255,6 +286,12 @@ type realtimeUsecaseImpl struct {
 	eventStore      EventStore
 	sequenceCounter int64
 
+	// Cross-instance fan-out; when set, local subscribers receive events only through it
+	fanout     RealtimeFanout
+	fanoutStop context.CancelFunc
+	fanoutDone chan struct{}
+	fanoutMu   sync.Mutex
+
 	// Synchronization and logging
 	mu     sync.RWMutex
 	logger logger.Logger
@@ -289,6 +326,72 @@ func NewRealtimeUsecaseWithEventStore(log logger.Logger, eventStore EventStore)
 	}
 }
 
+// NewRealtimeUsecaseWithFanout creates a realtime usecase whose events go through a
+// fan-out to the subscribers of every server instance. Resume tokens are read back from
+// the event store, which must share its storage with the fan-out.
+func NewRealtimeUsecaseWithFanout(log logger.Logger, eventStore EventStore, fanout RealtimeFanout) RealtimeUsecase {
+	return &realtimeUsecaseImpl{
+		subscriptions:   make(map[string]map[model.SubscriptionID]*Subscription),
+		pathSubscribers: make(map[string]map[string]map[model.SubscriptionID]bool),
+		eventStore:      eventStore,
+		fanout:          fanout,
+		logger:          log,
+		startTime:       time.Now(),
+	}
+}
+
+// Start receives the fanned-out events until Stop, resubscribing with backoff after failures
+func (r *realtimeUsecaseImpl) Start(ctx context.Context) {
+	r.fanoutMu.Lock()
+	defer r.fanoutMu.Unlock()
+	if r.fanout == nil || r.fanoutStop != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r.fanoutStop = cancel
+	r.fanoutDone = make(chan struct{})
+	go r.receiveFanout(ctx, r.fanoutDone)
+}
+
+// Stop stops receiving fanned-out events
+func (r *realtimeUsecaseImpl) Stop() {
+	r.fanoutMu.Lock()
+	defer r.fanoutMu.Unlock()
+	if r.fanoutStop == nil {
+		return
+	}
+	r.fanoutStop()
+	<-r.fanoutDone
+	r.fanoutStop = nil
+}
+
+func (r *realtimeUsecaseImpl) receiveFanout(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := r.fanout.Receive(ctx, func(event model.RealtimeEvent) {
+			backoff = time.Second
+			if event.Type == model.EventTypeSnapshot {
+				r.deliverCommit(ctx, event)
+				return
+			}
+			r.deliverEvent(ctx, event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn("Realtime fan-out interrupted, resubscribing", zap.Error(err), zap.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
 // Subscribe implements subscription with full Firestore compatibility
 func (r *realtimeUsecaseImpl) Subscribe(ctx context.Context, req SubscribeRequest) (*SubscribeResponse, error) {
 	if err := r.validateSubscribeRequest(req); err != nil {
@@ -326,6 +429,11 @@ func (r *realtimeUsecaseImpl) Subscribe(ctx context.Context, req SubscribeReques
 		Options:        req.Options,
 	}
 
+	// Live events wait for the resume replay, which releases the lock when done
+	if req.ResumeToken != "" && r.fanout != nil {
+		subscription.deliverMu.Lock()
+	}
+
 	// Store subscription
 	r.subscriptions[req.SubscriberID][req.SubscriptionID] = subscription
 	r.addToPathSubscribers(req.FirestorePath, req.SubscriberID, req.SubscriptionID)
@@ -427,6 +535,15 @@ func (r *realtimeUsecaseImpl) PublishEvent(ctx context.Context, event model.Real
 		return errors.NewValidationError("event path cannot be empty")
 	}
 
+	if r.fanout != nil {
+		// Subscribers of every instance, this one included, receive the event from the fan-out
+		if _, err := r.fanout.Publish(ctx, event); err != nil {
+			return fmt.Errorf("failed to publish event: %w", err)
+		}
+		atomic.AddInt64(&r.eventCounter, 1)
+		return nil
+	}
+
 	// Generate sequence and resume token
 	sequence := atomic.AddInt64(&r.sequenceCounter, 1)
 	event.SequenceNumber = sequence
@@ -439,22 +556,126 @@ func (r *realtimeUsecaseImpl) PublishEvent(ctx context.Context, event model.Real
 
 	atomic.AddInt64(&r.eventCounter, 1)
 
-	// Find target subscriptions
-	r.mu.RLock()
-	var targetSubscriptions []*Subscription
-
-	if pathSubscribers, exists := r.pathSubscribers[event.FullPath]; exists {
-		for subscriberID, subscriptionIDs := range pathSubscribers {
-			if subscriberSubs, exists := r.subscriptions[subscriberID]; exists {
-				for subscriptionID := range subscriptionIDs {
-					if subscription, exists := subscriberSubs[subscriptionID]; exists && subscription.IsActive {
-						targetSubscriptions = append(targetSubscriptions, subscription)
-					}
-				}
+	r.deliverEvent(ctx, event)
+	return nil
+}
+
+// PublishCommit implements RealtimeCommitPublisher. The events share one resume token and
+// are delivered to each subscription as a snapshot event holding the changes it sees.
+func (r *realtimeUsecaseImpl) PublishCommit(ctx context.Context, events []model.RealtimeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if len(events) == 1 {
+		return r.PublishEvent(ctx, events[0])
+	}
+	for _, event := range events {
+		if event.FullPath == "" {
+			return errors.NewValidationError("event path cannot be empty")
+		}
+	}
+
+	commit := model.RealtimeEvent{
+		Type:       model.EventTypeSnapshot,
+		FullPath:   fmt.Sprintf("projects/%s/databases/%s/documents", events[0].ProjectID, events[0].DatabaseID),
+		ProjectID:  events[0].ProjectID,
+		DatabaseID: events[0].DatabaseID,
+		Timestamp:  events[0].Timestamp,
+		Changes:    make([]model.RealtimeEvent, len(events)),
+	}
+	copy(commit.Changes, events)
+
+	if r.fanout != nil {
+		if _, err := r.fanout.Publish(ctx, commit); err != nil {
+			return fmt.Errorf("failed to publish commit: %w", err)
+		}
+		atomic.AddInt64(&r.eventCounter, int64(len(events)))
+		return nil
+	}
+
+	for i := range commit.Changes {
+		commit.Changes[i].SequenceNumber = atomic.AddInt64(&r.sequenceCounter, 1)
+	}
+	last := commit.Changes[len(commit.Changes)-1]
+	commit.SequenceNumber = last.SequenceNumber
+	commit.ResumeToken = last.GenerateResumeToken()
+	for i := range commit.Changes {
+		commit.Changes[i].ResumeToken = commit.ResumeToken
+		if err := r.eventStore.StoreEvent(ctx, commit.Changes[i]); err != nil {
+			r.logger.Error("Failed to store event", zap.Error(err))
+		}
+	}
+	atomic.AddInt64(&r.eventCounter, int64(len(events)))
+
+	r.deliverCommit(ctx, commit)
+	return nil
+}
+
+// deliverCommit sends the changes of a snapshot event to the local subscriptions, one
+// event per subscription: a single change as is, several as a snapshot event
+func (r *realtimeUsecaseImpl) deliverCommit(ctx context.Context, commit model.RealtimeEvent) {
+	var subscriptions []*Subscription
+	changes := make(map[*Subscription][]model.RealtimeEvent)
+	for _, change := range commit.Changes {
+		for _, subscription := range r.targetSubscriptions(change.FullPath) {
+			if _, seen := changes[subscription]; !seen {
+				subscriptions = append(subscriptions, subscription)
 			}
+			changes[subscription] = append(changes[subscription], change)
 		}
 	}
-	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(sub *Subscription, subChanges []model.RealtimeEvent) {
+			defer wg.Done()
+			if r.fanout != nil {
+				sub.deliverMu.Lock()
+				defer sub.deliverMu.Unlock()
+			}
+			var accepted []model.RealtimeEvent
+			for _, change := range subChanges {
+				if r.acceptEvent(change, sub) {
+					change.SubscriptionID = string(sub.SubscriptionID)
+					accepted = append(accepted, change)
+				}
+			}
+			switch len(accepted) {
+			case 0:
+			case 1:
+				r.sendToSubscription(ctx, accepted[0], sub)
+			default:
+				event := commit
+				event.Changes = accepted
+				event.SubscriptionID = string(sub.SubscriptionID)
+				r.sendToSubscription(ctx, event, sub)
+			}
+		}(subscription, changes[subscription])
+	}
+	wg.Wait()
+
+	r.logger.Debug("Commit published",
+		zap.String("path", commit.FullPath),
+		zap.Int("changes", len(commit.Changes)),
+		zap.Int("subscribers", len(subscriptions)))
+}
+
+// targetSubscriptions returns the listeners of a document and of the collections containing it
+func (r *realtimeUsecaseImpl) targetSubscriptions(fullPath string) []*Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscriptions := r.pathSubscriptions(fullPath, false)
+	for i, collectionPath := range collectionPathsOf(fullPath) {
+		// Only the parent collection holds the document; ancestors see it through allDescendants
+		subscriptions = append(subscriptions, r.pathSubscriptions(collectionPath, i > 0)...)
+	}
+	return subscriptions
+}
+
+// deliverEvent sends an event to the local subscriptions of its path
+func (r *realtimeUsecaseImpl) deliverEvent(ctx context.Context, event model.RealtimeEvent) {
+	targetSubscriptions := r.targetSubscriptions(event.FullPath)
 
 	// Send events concurrently
 	var wg sync.WaitGroup
@@ -462,6 +683,10 @@ func (r *realtimeUsecaseImpl) PublishEvent(ctx context.Context, event model.Real
 		wg.Add(1)
 		go func(sub *Subscription) {
 			defer wg.Done()
+			if r.fanout != nil {
+				sub.deliverMu.Lock()
+				defer sub.deliverMu.Unlock()
+			}
 			r.sendEventToSubscription(ctx, event, sub)
 		}(subscription)
 	}
@@ -471,8 +696,6 @@ func (r *realtimeUsecaseImpl) PublishEvent(ctx context.Context, event model.Real
 		zap.String("path", event.FullPath),
 		zap.String("eventType", string(event.Type)),
 		zap.Int("subscribers", len(targetSubscriptions)))
-
-	return nil
 }
 
 // GetEventsSince returns events for replay
@@ -625,13 +848,15 @@ func (r *realtimeUsecaseImpl) ValidatePermissions(ctx context.Context, subscribe
 	r.mu.RUnlock()
 
 	for _, subscription := range subscriptionsToValidate {
-		if err := permissionValidator(subscriberID, subscription.FirestorePath); err != nil {
+		if err := permissionValidator(subscription); err != nil {
 			r.Unsubscribe(ctx, UnsubscribeRequest{
 				SubscriberID:   subscriberID,
 				SubscriptionID: subscription.SubscriptionID,
 			})
 			r.logger.Warn("Subscription removed due to permission failure",
 				zap.String("subscriberID", subscriberID),
+				zap.String("subscriptionID", string(subscription.SubscriptionID)),
+				zap.String("path", subscription.FirestorePath),
 				zap.Error(err))
 		}
 	}
@@ -751,7 +976,51 @@ func (r *realtimeUsecaseImpl) removeFromPathSubscribers(firestorePath, subscribe
 	}
 }
 
+// pathSubscriptions returns the active subscriptions of a path; the caller holds r.mu
+func (r *realtimeUsecaseImpl) pathSubscriptions(firestorePath string, descendantsOnly bool) []*Subscription {
+	var result []*Subscription
+	for subscriberID, subscriptionIDs := range r.pathSubscribers[firestorePath] {
+		subscriberSubs, exists := r.subscriptions[subscriberID]
+		if !exists {
+			continue
+		}
+		for subscriptionID := range subscriptionIDs {
+			subscription, exists := subscriberSubs[subscriptionID]
+			if !exists || !subscription.IsActive {
+				continue
+			}
+			if descendantsOnly && (subscription.Query == nil || !subscription.Query.AllDescendants) {
+				continue
+			}
+			result = append(result, subscription)
+		}
+	}
+	return result
+}
+
+// collectionPathsOf returns the paths of the collections containing a document, from its
+// parent collection up to the root collection
+func collectionPathsOf(fullPath string) []string {
+	index := strings.Index(fullPath, "/documents/")
+	if index < 0 {
+		return nil
+	}
+	prefix := fullPath[:index+len("/documents/")]
+	segments := strings.Split(fullPath[len(prefix):], "/")
+	if len(segments)%2 != 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(segments)/2)
+	for n := len(segments) - 1; n >= 1; n -= 2 {
+		paths = append(paths, prefix+strings.Join(segments[:n], "/"))
+	}
+	return paths
+}
+
 func (r *realtimeUsecaseImpl) sendEventsFromResumeToken(ctx context.Context, subscription *Subscription, resumeToken model.ResumeToken) {
+	if r.fanout != nil {
+		defer subscription.deliverMu.Unlock()
+	}
 	events, err := r.eventStore.GetEventsSince(ctx, subscription.FirestorePath, resumeToken)
 	if err != nil {
 		r.logger.Error("Failed to get events from resume token",
@@ -766,18 +1035,38 @@ func (r *realtimeUsecaseImpl) sendEventsFromResumeToken(ctx context.Context, sub
 }
 
 func (r *realtimeUsecaseImpl) sendEventToSubscription(ctx context.Context, event model.RealtimeEvent, subscription *Subscription) {
-	if !subscription.IsActive {
-		return
-	}
-
-	// Apply query filtering if present
-	if subscription.Query != nil && !r.matchesQuery(event, subscription.Query) {
+	if !r.acceptEvent(event, subscription) {
 		return
 	}
 
 	// Set subscription ID in event
 	event.SubscriptionID = string(subscription.SubscriptionID)
+	r.sendToSubscription(ctx, event, subscription)
+}
+
+// acceptEvent reports whether a document event is delivered to a subscription
+func (r *realtimeUsecaseImpl) acceptEvent(event model.RealtimeEvent, subscription *Subscription) bool {
+	if !subscription.IsActive {
+		return false
+	}
+
+	// Fanned-out sequences increase per path; a resumed subscription may see an event twice
+	if r.fanout != nil && event.SequenceNumber > 0 {
+		if event.SequenceNumber <= subscription.lastSequences[event.FullPath] {
+			return false
+		}
+		if subscription.lastSequences == nil {
+			subscription.lastSequences = make(map[string]int64)
+		}
+		subscription.lastSequences[event.FullPath] = event.SequenceNumber
+	}
 
+	// Apply query filtering if present; documents leaving the query are delivered as well
+	return subscription.Query == nil || r.matchesQuery(event, subscription.Query) || r.matchedQueryBefore(event, subscription.Query)
+}
+
+// sendToSubscription queues an event on the channel of a subscription, dropping it when full
+func (r *realtimeUsecaseImpl) sendToSubscription(ctx context.Context, event model.RealtimeEvent, subscription *Subscription) {
 	select {
 	case subscription.EventChannel <- event:
 		r.logger.Debug("Event sent to subscription",
@@ -791,7 +1080,22 @@ func (r *realtimeUsecaseImpl) sendEventToSubscription(ctx context.Context, event
 	}
 }
 
+// matchedQueryBefore reports whether the document matched the query before the event
+func (r *realtimeUsecaseImpl) matchedQueryBefore(event model.RealtimeEvent, query *model.Query) bool {
+	if event.OldData == nil {
+		return false
+	}
+	previous := event
+	previous.Data = event.OldData
+	return r.matchesQuery(previous, query)
+}
+
 func (r *realtimeUsecaseImpl) matchesQuery(event model.RealtimeEvent, query *model.Query) bool {
+	return eventMatchesQuery(event, query)
+}
+
+// eventMatchesQuery reports whether the document data of an event matches a query
+func eventMatchesQuery(event model.RealtimeEvent, query *model.Query) bool {
 	if query == nil {
 		return true
 	}
@@ -803,12 +1107,12 @@ func (r *realtimeUsecaseImpl) matchesQuery(event model.RealtimeEvent, query *mod
 	if !matchFilters(data, query.Filters) {
 		return false
 	}
-	// allDescendants: si está activo, permite coincidencia en subcolecciones
-	if query.AllDescendants && !pathMatchesDescendants(event.FullPath, query.Path) {
+	// Restricción geográfica: radio y/o bounding box sobre un campo GeoPoint
+	if query.Geo != nil && !query.Geo.MatchesData(data) {
 		return false
 	}
-	// Proyección de campos: si selectFields está definido, solo esos campos deben estar presentes
-	if len(query.SelectFields) > 0 && !fieldsMatchProjection(data, query.SelectFields) {
+	// allDescendants: si está activo, permite coincidencia en subcolecciones
+	if query.AllDescendants && !pathMatchesDescendants(event.FullPath, query.Path) {
 		return false
 	}
 	// Paginación y ordenamiento: para eventos individuales, solo se puede filtrar por startAt/startAfter/endAt/endBefore si están presentes
@@ -858,21 +1162,18 @@ func pathMatchesDescendants(fullPath, queryPath string) bool {
 	return len(fullPath) >= len(queryPath) && fullPath[:len(queryPath)] == queryPath
 }
 
-// fieldsMatchProjection verifica que solo los campos proyectados estén presentes
-func fieldsMatchProjection(data map[string]interface{}, selectFields []string) bool {
-	for k := range data {
-		found := false
-		for _, f := range selectFields {
-			if k == f {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
+// projectFields devuelve solo los campos proyectados por selectFields
+func projectFields(data map[string]interface{}, selectFields []string) map[string]interface{} {
+	if len(selectFields) == 0 || data == nil {
+		return data
+	}
+	projected := make(map[string]interface{}, len(selectFields))
+	for _, field := range selectFields {
+		if value, ok := data[field]; ok {
+			projected[field] = value
 		}
 	}
-	return true
+	return projected
 }
 
 // matchCursors soporta startAt, startAfter, endAt, endBefore, offset, limit, limitToLast
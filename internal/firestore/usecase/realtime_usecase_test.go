@@ -321,7 +321,7 @@ func TestRealtimeUsecase_EnhancedFeatures(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test permission validation
-	err = rtu.ValidatePermissions(ctx, subscriberID, func(subscriberID, path string) error {
+	err = rtu.ValidatePermissions(ctx, subscriberID, func(subscription *usecase.Subscription) error {
 		// Mock permission validator that always passes
 		return nil
 	})
@@ -522,3 +522,89 @@ func TestRealtimeUsecase_MatchesQuery_AdvancedFiltering(t *testing.T) {
 		t.Fatal("No event received for selectFields projection")
 	}
 }
+
+func TestRealtimeUsecase_MatchesQuery_GeoRadius(t *testing.T) {
+	rtu := newTestRealtimeUsecase(t)
+	ctx := context.Background()
+	path := "projects/test-project/databases/test-db/documents/stores/s1"
+	eventChan := make(chan model.RealtimeEvent, 2)
+
+	center := &model.GeoPoint{Latitude: 48.8566, Longitude: 2.3522}
+	query := &model.Query{Geo: &model.GeoQuery{Field: "location", Near: center, RadiusMeters: 5000}}
+	_, err := rtu.Subscribe(ctx, usecase.SubscribeRequest{
+		SubscriberID:   "client1",
+		SubscriptionID: model.SubscriptionID("geo"),
+		FirestorePath:  path,
+		EventChannel:   eventChan,
+		Query:          query,
+	})
+	require.NoError(t, err)
+
+	publish := func(location *model.GeoPoint) {
+		require.NoError(t, rtu.PublishEvent(ctx, model.RealtimeEvent{
+			Type:         model.EventTypeModified,
+			FullPath:     path,
+			ProjectID:    "test-project",
+			DatabaseID:   "test-db",
+			DocumentPath: "stores/s1",
+			Data:         map[string]interface{}{"location": location},
+			Timestamp:    time.Now(),
+		}))
+	}
+
+	// London is outside the radius, a point 1 km away is inside
+	publish(&model.GeoPoint{Latitude: 51.5074, Longitude: -0.1278})
+	publish(&model.GeoPoint{Latitude: 48.8656, Longitude: 2.3522})
+	select {
+	case received := <-eventChan:
+		assert.Equal(t, 48.8656, received.Data["location"].(*model.GeoPoint).Latitude)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("No event received for a point inside the radius")
+	}
+	assert.Empty(t, eventChan)
+}
+
+func TestRealtimeUsecase_PublishEvent_CollectionSubscription(t *testing.T) {
+	rtu := newTestRealtimeUsecase(t)
+	ctx := context.Background()
+	collection := "projects/test-project/databases/test-db/documents/rooms"
+	query := &model.Query{Filters: []model.Filter{{Field: "open", Operator: model.OperatorEqual, Value: "yes"}}}
+
+	eventChan := make(chan model.RealtimeEvent, 5)
+	_, err := rtu.Subscribe(ctx, usecase.SubscribeRequest{
+		SubscriberID:   "client1",
+		SubscriptionID: model.SubscriptionID("sub1"),
+		FirestorePath:  collection,
+		EventChannel:   eventChan,
+		Query:          query,
+	})
+	require.NoError(t, err)
+
+	publish := func(path string, data, oldData map[string]interface{}) {
+		require.NoError(t, rtu.PublishEvent(ctx, model.RealtimeEvent{
+			Type: model.EventTypeModified, FullPath: path, Data: data, OldData: oldData, Timestamp: time.Now(),
+		}))
+	}
+
+	// Documents of the collection that match or leave the query are delivered
+	publish(collection+"/r1", map[string]interface{}{"open": "yes"}, nil)
+	publish(collection+"/r1", map[string]interface{}{"open": "no"}, map[string]interface{}{"open": "yes"})
+	// Non-matching documents and documents of subcollections are not
+	publish(collection+"/r2", map[string]interface{}{"open": "no"}, nil)
+	publish(collection+"/r1/messages/m1", map[string]interface{}{"open": "yes"}, nil)
+
+	for _, expected := range []string{"yes", "no"} {
+		select {
+		case event := <-eventChan:
+			assert.Equal(t, collection+"/r1", event.FullPath)
+			assert.Equal(t, expected, event.Data["open"])
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("timed out waiting for collection event")
+		}
+	}
+	select {
+	case event := <-eventChan:
+		t.Fatalf("unexpected event: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
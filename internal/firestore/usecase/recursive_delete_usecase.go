@@ -0,0 +1,503 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRecursiveDeleteBatchSize is the number of documents deleted between checkpoints
+	DefaultRecursiveDeleteBatchSize = 100
+	// MaxRecursiveDeleteBatchSize mirrors the Firestore batch write limit
+	MaxRecursiveDeleteBatchSize = 500
+	// AdminRole is the role allowed to bypass security rules
+	AdminRole = "admin"
+)
+
+// RecursiveDeleteUsecase defines the primary port for recursive deletes of documents and collections
+type RecursiveDeleteUsecase interface {
+	// StartRecursiveDelete validates the request and runs the delete as a background operation
+	StartRecursiveDelete(ctx context.Context, req RecursiveDeleteRequest) (*model.RecursiveDeleteOperation, error)
+	// GetOperation returns a snapshot of a recursive delete operation started by the user
+	GetOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error)
+	// ResumeOperation restarts a FAILED or CANCELLED operation of the user from where it stopped
+	ResumeOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error)
+	// CancelOperation requests cancellation of a running operation of the user
+	CancelOperation(ctx context.Context, operationID string, user *authModel.User) error
+}
+
+// OperationStore defines the secondary port for long-running operation persistence.
+// A store shared by all server instances lets any instance resume an operation.
+type OperationStore interface {
+	SaveOperation(ctx context.Context, op *model.RecursiveDeleteOperation) error
+	GetOperation(ctx context.Context, operationID string) (*model.RecursiveDeleteOperation, error)
+}
+
+// InMemoryOperationStore implements OperationStore with in-memory storage
+type InMemoryOperationStore struct {
+	operations map[string]*model.RecursiveDeleteOperation
+	mu         sync.RWMutex
+}
+
+// NewInMemoryOperationStore creates a new in-memory operation store
+func NewInMemoryOperationStore() OperationStore {
+	return &InMemoryOperationStore{
+		operations: make(map[string]*model.RecursiveDeleteOperation),
+	}
+}
+
+// SaveOperation stores a copy of the operation
+func (s *InMemoryOperationStore) SaveOperation(ctx context.Context, op *model.RecursiveDeleteOperation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *op
+	s.operations[op.OperationID] = &stored
+	return nil
+}
+
+// GetOperation returns a copy of the stored operation
+func (s *InMemoryOperationStore) GetOperation(ctx context.Context, operationID string) (*model.RecursiveDeleteOperation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	op, exists := s.operations[operationID]
+	if !exists {
+		return nil, errors.NewNotFoundError("operation")
+	}
+	result := *op
+	return &result, nil
+}
+
+// recursiveDeleteUsecase implements RecursiveDeleteUsecase on top of the Firestore repository
+type recursiveDeleteUsecase struct {
+	firestoreRepo repository.FirestoreRepository
+	securityUC    SecurityUsecase
+	realtimeUC    RealtimeUsecase
+	store         OperationStore
+	logger        logger.Logger
+
+	// Cancel functions of the operations running on this instance
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewRecursiveDeleteUsecase creates a new recursive delete usecase.
+// securityUC and realtimeUC are optional: without them rules are not evaluated and no events are emitted.
+func NewRecursiveDeleteUsecase(
+	firestoreRepo repository.FirestoreRepository,
+	securityUC SecurityUsecase,
+	realtimeUC RealtimeUsecase,
+	store OperationStore,
+	log logger.Logger,
+) RecursiveDeleteUsecase {
+	if store == nil {
+		store = NewInMemoryOperationStore()
+	}
+	return &recursiveDeleteUsecase{
+		firestoreRepo: firestoreRepo,
+		securityUC:    securityUC,
+		realtimeUC:    realtimeUC,
+		store:         store,
+		logger:        log,
+		cancels:       make(map[string]context.CancelFunc),
+	}
+}
+
+// StartRecursiveDelete implements RecursiveDeleteUsecase
+func (uc *recursiveDeleteUsecase) StartRecursiveDelete(ctx context.Context, req RecursiveDeleteRequest) (*model.RecursiveDeleteOperation, error) {
+	if err := uc.validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	op := &model.RecursiveDeleteOperation{
+		OperationID:    uuid.NewString(),
+		OrganizationID: utils.GetOrganizationIDOrDefault(ctx, ""),
+		ProjectID:      req.ProjectID,
+		DatabaseID:     req.DatabaseID,
+		Path:           req.Path,
+		State:          model.OperationStateRunning,
+		BatchSize:      req.BatchSize,
+		BypassRules:    req.BypassRules,
+		RequestedBy:    userIdentifier(req.User),
+		StartTime:      now,
+		UpdatedAt:      now,
+	}
+	if err := uc.store.SaveOperation(ctx, op); err != nil {
+		return nil, fmt.Errorf("failed to save operation: %w", err)
+	}
+
+	uc.logger.Info("Starting recursive delete",
+		"operationID", op.OperationID,
+		"projectID", req.ProjectID,
+		"databaseID", req.DatabaseID,
+		"path", req.Path,
+		"bypassRules", req.BypassRules)
+
+	// Snapshot before launching: the background run owns op from here on
+	result := *op
+	uc.launch(ctx, op, req)
+	return &result, nil
+}
+
+// GetOperation implements RecursiveDeleteUsecase
+func (uc *recursiveDeleteUsecase) GetOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	return uc.getOwnedOperation(ctx, operationID, user)
+}
+
+// ResumeOperation implements RecursiveDeleteUsecase. The request is rebuilt from the
+// stored operation, so it can be resumed on any instance; security rules are evaluated
+// for the user resuming it.
+func (uc *recursiveDeleteUsecase) ResumeOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	op, err := uc.getOwnedOperation(ctx, operationID, user)
+	if err != nil {
+		return nil, err
+	}
+	if !op.IsResumable() {
+		return nil, errors.NewConflictError(fmt.Sprintf("operation %s is %s and cannot be resumed", operationID, op.State))
+	}
+
+	req := RecursiveDeleteRequest{
+		ProjectID:   op.ProjectID,
+		DatabaseID:  op.DatabaseID,
+		Path:        op.Path,
+		BatchSize:   op.BatchSize,
+		BypassRules: op.BypassRules,
+		User:        user,
+	}
+	if err := uc.validateRequest(&req); err != nil {
+		return nil, err
+	}
+
+	op.State = model.OperationStateRunning
+	op.ErrorMessage = ""
+	op.EndTime = nil
+	op.UpdatedAt = time.Now()
+	if err := uc.store.SaveOperation(ctx, op); err != nil {
+		return nil, fmt.Errorf("failed to save operation: %w", err)
+	}
+
+	uc.logger.Info("Resuming recursive delete", "operationID", operationID, "documentsDeleted", op.DocumentsDeleted)
+
+	result := *op
+	uc.launch(ctx, op, req)
+	return &result, nil
+}
+
+// CancelOperation implements RecursiveDeleteUsecase
+func (uc *recursiveDeleteUsecase) CancelOperation(ctx context.Context, operationID string, user *authModel.User) error {
+	op, err := uc.getOwnedOperation(ctx, operationID, user)
+	if err != nil {
+		return err
+	}
+	if op.Done() {
+		return nil
+	}
+
+	uc.mu.Lock()
+	cancel, exists := uc.cancels[operationID]
+	uc.mu.Unlock()
+	if !exists {
+		return errors.NewConflictError("operation is not running on this instance")
+	}
+	cancel()
+	return nil
+}
+
+// getOwnedOperation loads an operation the user may manage: only the user who started it
+// and administrators of its organization can. Other users get the same error as for a
+// missing operation.
+func (uc *recursiveDeleteUsecase) getOwnedOperation(ctx context.Context, operationID string, user *authModel.User) (*model.RecursiveDeleteOperation, error) {
+	op, err := uc.store.GetOperation(ctx, operationID)
+	if err != nil {
+		return nil, err
+	}
+	if op.OrganizationID != utils.GetOrganizationIDOrDefault(ctx, "") {
+		return nil, errors.NewNotFoundError("operation")
+	}
+	if !isAdminUser(user) && (op.RequestedBy == "" || op.RequestedBy != userIdentifier(user)) {
+		return nil, errors.NewNotFoundError("operation")
+	}
+	return op, nil
+}
+
+// launch runs the operation in the background, detached from the request lifetime
+// but keeping its values (tenant, project, database) for the repository.
+func (uc *recursiveDeleteUsecase) launch(ctx context.Context, op *model.RecursiveDeleteOperation, req RecursiveDeleteRequest) {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	uc.mu.Lock()
+	uc.cancels[op.OperationID] = cancel
+	uc.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			uc.mu.Lock()
+			delete(uc.cancels, op.OperationID)
+			uc.mu.Unlock()
+		}()
+		uc.run(runCtx, op, req)
+	}()
+}
+
+// run walks the tree and records the final state of the operation
+func (uc *recursiveDeleteUsecase) run(ctx context.Context, op *model.RecursiveDeleteOperation, req RecursiveDeleteRequest) {
+	op.Attempts++
+	w := &deleteWalker{uc: uc, ctx: ctx, op: op, req: req}
+
+	err := w.deletePath(req.Path)
+	if err == nil {
+		err = w.flush()
+	}
+
+	now := time.Now()
+	op.UpdatedAt = now
+	op.EndTime = &now
+	switch {
+	case err == nil:
+		op.State = model.OperationStateSucceeded
+	case ctx.Err() != nil:
+		op.State = model.OperationStateCancelled
+		op.ErrorMessage = ctx.Err().Error()
+	default:
+		op.State = model.OperationStateFailed
+		op.ErrorMessage = err.Error()
+	}
+
+	// Use a fresh context so the final state is recorded even after cancellation
+	if saveErr := uc.store.SaveOperation(context.WithoutCancel(ctx), op); saveErr != nil {
+		uc.logger.Error("Failed to save recursive delete operation", "operationID", op.OperationID, "error", saveErr)
+	}
+
+	uc.logger.Info("Recursive delete finished",
+		"operationID", op.OperationID,
+		"state", string(op.State),
+		"documentsDeleted", op.DocumentsDeleted,
+		"collectionsDeleted", op.CollectionsDeleted)
+}
+
+func (uc *recursiveDeleteUsecase) validateRequest(req *RecursiveDeleteRequest) error {
+	if req.ProjectID == "" || req.DatabaseID == "" {
+		return errors.NewValidationError("projectID and databaseID are required")
+	}
+	req.Path = strings.Trim(req.Path, "/")
+	if req.Path == "" {
+		return errors.NewValidationError("path is required")
+	}
+	for _, segment := range strings.Split(req.Path, "/") {
+		if segment == "" {
+			return errors.NewValidationError(fmt.Sprintf("invalid path: %s", req.Path))
+		}
+	}
+	if req.BatchSize <= 0 {
+		req.BatchSize = DefaultRecursiveDeleteBatchSize
+	}
+	if req.BatchSize > MaxRecursiveDeleteBatchSize {
+		req.BatchSize = MaxRecursiveDeleteBatchSize
+	}
+	// The operation belongs to the user who starts it, so it cannot be started anonymously
+	if userIdentifier(req.User) == "" {
+		return errors.NewAuthenticationError("recursive delete requires an authenticated user")
+	}
+	if req.BypassRules && !isAdminUser(req.User) {
+		return errors.NewAuthorizationError("only administrators can bypass security rules")
+	}
+	return nil
+}
+
+// deleteWalker performs a depth-first delete of a document tree, checkpointing
+// the operation after every batch of deleted documents.
+type deleteWalker struct {
+	uc      *recursiveDeleteUsecase
+	ctx     context.Context
+	op      *model.RecursiveDeleteOperation
+	req     RecursiveDeleteRequest
+	pending int
+}
+
+// deletePath deletes the document or collection at a documents-relative path
+func (w *deleteWalker) deletePath(path string) error {
+	segments := strings.Split(path, "/")
+	if len(segments)%2 == 0 {
+		collectionID := strings.Join(segments[:len(segments)-1], "/")
+		return w.deleteDocumentTree(collectionID, segments[len(segments)-1])
+	}
+	return w.deleteCollectionTree(path)
+}
+
+// deleteCollectionTree deletes every document of a collection (and their descendants) page by page
+func (w *deleteWalker) deleteCollectionTree(collectionID string) error {
+	uc := w.uc
+	for {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		// Deleted documents disappear from the listing, so always read the first page
+		docs, _, err := uc.firestoreRepo.ListDocuments(w.ctx, w.req.ProjectID, w.req.DatabaseID, collectionID, int32(w.req.BatchSize), "", "", false)
+		if err != nil {
+			return fmt.Errorf("failed to list documents in %s: %w", collectionID, err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		before := w.op.DocumentsDeleted
+		for _, doc := range docs {
+			if err := w.deleteDocumentTree(collectionID, doc.DocumentID); err != nil {
+				return err
+			}
+		}
+		if w.op.DocumentsDeleted == before {
+			return fmt.Errorf("no progress deleting documents in %s", collectionID)
+		}
+	}
+
+	if err := uc.firestoreRepo.DeleteCollection(w.ctx, w.req.ProjectID, w.req.DatabaseID, collectionID); err != nil && !isNotFoundErr(err) {
+		return fmt.Errorf("failed to delete collection %s: %w", collectionID, err)
+	}
+	w.op.CollectionsDeleted++
+	return nil
+}
+
+// deleteDocumentTree deletes all descendant subcollections and then the document itself.
+// The delete of the document is checked against the rules first, so a denied document
+// keeps its subcollections.
+func (w *deleteWalker) deleteDocumentTree(collectionID, documentID string) error {
+	uc := w.uc
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	fullPath := w.fullPath(collectionID + "/" + documentID)
+	var existing *model.Document
+	var err error
+	if w.uc.realtimeUC != nil || !w.req.BypassRules {
+		existing, err = uc.firestoreRepo.GetDocument(w.ctx, w.req.ProjectID, w.req.DatabaseID, collectionID, documentID)
+		if err != nil && !isNotFoundErr(err) {
+			return fmt.Errorf("failed to read %s: %w", fullPath, err)
+		}
+	}
+
+	if !w.req.BypassRules && uc.securityUC != nil {
+		if err := uc.securityUC.ValidateDelete(w.ctx, w.req.User, fullPath); err != nil {
+			return fmt.Errorf("PERMISSION_DENIED on %s: %w", fullPath, err)
+		}
+	}
+
+	subcollections, err := uc.firestoreRepo.ListSubcollections(w.ctx, w.req.ProjectID, w.req.DatabaseID, collectionID, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to list subcollections of %s/%s: %w", collectionID, documentID, err)
+	}
+	for _, sub := range subcollections {
+		subPath := sub
+		if !strings.Contains(sub, "/") {
+			subPath = collectionID + "/" + documentID + "/" + sub
+		}
+		if err := w.deleteCollectionTree(subPath); err != nil {
+			return err
+		}
+	}
+
+	if err := uc.firestoreRepo.DeleteDocument(w.ctx, w.req.ProjectID, w.req.DatabaseID, collectionID, documentID); err != nil {
+		if !isNotFoundErr(err) {
+			return fmt.Errorf("failed to delete %s: %w", fullPath, err)
+		}
+		// Missing parent documents only hold subcollections
+		return nil
+	}
+
+	w.op.DocumentsDeleted++
+	w.op.LastPath = collectionID + "/" + documentID
+	w.publishRemoved(fullPath, collectionID+"/"+documentID, existing)
+
+	w.pending++
+	if w.pending >= w.req.BatchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush checkpoints the operation progress after a completed batch
+func (w *deleteWalker) flush() error {
+	if w.pending == 0 {
+		return nil
+	}
+	w.pending = 0
+	w.op.BatchesCompleted++
+	w.op.UpdatedAt = time.Now()
+	if err := w.uc.store.SaveOperation(w.ctx, w.op); err != nil {
+		return fmt.Errorf("failed to checkpoint operation: %w", err)
+	}
+	return nil
+}
+
+func (w *deleteWalker) publishRemoved(fullPath, documentPath string, existing *model.Document) {
+	if w.uc.realtimeUC == nil {
+		return
+	}
+	event := model.RealtimeEvent{
+		Type:         model.EventTypeRemoved,
+		FullPath:     fullPath,
+		ProjectID:    w.req.ProjectID,
+		DatabaseID:   w.req.DatabaseID,
+		DocumentPath: documentPath,
+		Timestamp:    time.Now(),
+	}
+	if existing != nil {
+		event.OldData = documentToMap(existing)
+	}
+	if err := w.uc.realtimeUC.PublishEvent(w.ctx, event); err != nil {
+		w.uc.logger.Warn("Failed to publish delete event", "path", fullPath, "error", err)
+	}
+}
+
+func (w *deleteWalker) fullPath(documentPath string) string {
+	return fmt.Sprintf("projects/%s/databases/%s/documents/%s", w.req.ProjectID, w.req.DatabaseID, documentPath)
+}
+
+// documentToMap converts document fields into plain Go values for realtime events
+func documentToMap(doc *model.Document) map[string]interface{} {
+	data := make(map[string]interface{}, len(doc.Fields))
+	for key, value := range doc.Fields {
+		if value != nil {
+			data[key] = value.ToInterface()
+		}
+	}
+	return data
+}
+
+// isAdminUser reports whether the user is allowed to bypass security rules
+func isAdminUser(user *authModel.User) bool {
+	return user != nil && user.HasRole(AdminRole)
+}
+
+// userIdentifier returns a stable identifier for auditing purposes
+func userIdentifier(user *authModel.User) string {
+	if user == nil {
+		return ""
+	}
+	if user.UserID != "" {
+		return user.UserID
+	}
+	return user.Email
+}
+
+// isNotFoundErr detects the not-found errors of the repositories
+func isNotFoundErr(err error) bool {
+	return err != nil && errors.IsNotFound(err)
+}
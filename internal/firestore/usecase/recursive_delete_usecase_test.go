@@ -0,0 +1,320 @@
+package usecase_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// treeRepoMock keeps documents keyed by their documents-relative path
+type treeRepoMock struct {
+	repository.FirestoreRepository
+	mu   sync.Mutex
+	docs map[string]bool
+}
+
+func newTreeRepoMock(paths ...string) *treeRepoMock {
+	m := &treeRepoMock{docs: make(map[string]bool)}
+	for _, p := range paths {
+		m.docs[p] = true
+	}
+	return m
+}
+
+func (m *treeRepoMock) remaining() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []string
+	for p := range m.docs {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (m *treeRepoMock) GetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) (*model.Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.docs[collectionID+"/"+documentID] {
+		return nil, errors.NewNotFoundError("document")
+	}
+	return &model.Document{DocumentID: documentID, Fields: map[string]*model.FieldValue{"name": model.NewFieldValue(documentID)}}, nil
+}
+
+func (m *treeRepoMock) DeleteDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path := collectionID + "/" + documentID
+	if !m.docs[path] {
+		return errors.NewNotFoundError("document")
+	}
+	delete(m.docs, path)
+	return nil
+}
+
+func (m *treeRepoMock) DeleteCollection(ctx context.Context, projectID, databaseID, collectionID string) error {
+	return nil
+}
+
+func (m *treeRepoMock) ListDocuments(ctx context.Context, projectID, databaseID, collectionID string, pageSize int32, pageToken, orderBy string, showMissing bool) ([]*model.Document, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var docs []*model.Document
+	for p := range m.docs {
+		idx := strings.LastIndex(p, "/")
+		if p[:idx] == collectionID {
+			docs = append(docs, &model.Document{DocumentID: p[idx+1:]})
+		}
+		if int32(len(docs)) == pageSize {
+			break
+		}
+	}
+	return docs, "", nil
+}
+
+func (m *treeRepoMock) ListSubcollections(ctx context.Context, projectID, databaseID, collectionID, documentID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := collectionID + "/" + documentID + "/"
+	seen := make(map[string]bool)
+	var result []string
+	for p := range m.docs {
+		if strings.HasPrefix(p, prefix) {
+			sub := strings.SplitN(strings.TrimPrefix(p, prefix), "/", 2)[0]
+			if !seen[sub] {
+				seen[sub] = true
+				result = append(result, sub)
+			}
+		}
+	}
+	return result, nil
+}
+
+// deleteOwner is the user who starts the recursive deletes of the tests
+var deleteOwner = &authModel.User{UserID: "owner"}
+
+func waitForOperation(t *testing.T, uc RecursiveDeleteUsecase, operationID string) *model.RecursiveDeleteOperation {
+	t.Helper()
+	var op *model.RecursiveDeleteOperation
+	require.Eventually(t, func() bool {
+		var err error
+		op, err = uc.GetOperation(context.Background(), operationID, &authModel.User{UserID: "admin", Roles: []string{AdminRole}})
+		return err == nil && op.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+	return op
+}
+
+func TestRecursiveDelete_DocumentWithNestedSubcollections(t *testing.T) {
+	repo := newTreeRepoMock(
+		"users/u1",
+		"users/u1/posts/p1",
+		"users/u1/posts/p2",
+		"users/u1/posts/p1/comments/c1",
+		"users/u2",
+	)
+	realtime := NewMockRealtimeUsecase()
+	uc := NewRecursiveDeleteUsecase(repo, NewMockSecurityUsecase(), realtime, nil, &MockLogger{})
+
+	op, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{
+		ProjectID:  "p",
+		DatabaseID: "d",
+		Path:       "users/u1",
+		BatchSize:  2,
+		User:       deleteOwner,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.OperationStateRunning, op.State)
+
+	op = waitForOperation(t, uc, op.OperationID)
+	assert.Equal(t, model.OperationStateSucceeded, op.State)
+	assert.Equal(t, int64(4), op.DocumentsDeleted)
+	assert.Equal(t, int64(2), op.BatchesCompleted)
+	assert.Equal(t, []string{"users/u2"}, repo.remaining())
+	assert.Equal(t, 4, realtime.GetEventCount())
+}
+
+func TestRecursiveDelete_Collection(t *testing.T) {
+	repo := newTreeRepoMock("users/u1", "users/u2", "users/u2/posts/p1", "orders/o1")
+	uc := NewRecursiveDeleteUsecase(repo, nil, nil, nil, &MockLogger{})
+
+	op, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{
+		ProjectID:  "p",
+		DatabaseID: "d",
+		Path:       "/users/",
+		User:       deleteOwner,
+	})
+	require.NoError(t, err)
+
+	op = waitForOperation(t, uc, op.OperationID)
+	assert.Equal(t, model.OperationStateSucceeded, op.State)
+	assert.Equal(t, int64(3), op.DocumentsDeleted)
+	assert.Equal(t, int64(2), op.CollectionsDeleted)
+	assert.Equal(t, []string{"orders/o1"}, repo.remaining())
+}
+
+func TestRecursiveDelete_RulesDeniedThenResumed(t *testing.T) {
+	repo := newTreeRepoMock("users/u1", "users/u1/posts/p1")
+	security := NewMockSecurityUsecase()
+	security.SetValidationResult(false, nil)
+	uc := NewRecursiveDeleteUsecase(repo, security, nil, nil, &MockLogger{})
+
+	op, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{
+		ProjectID:  "p",
+		DatabaseID: "d",
+		Path:       "users/u1",
+		User:       deleteOwner,
+	})
+	require.NoError(t, err)
+
+	// The denied parent keeps its subcollections
+	op = waitForOperation(t, uc, op.OperationID)
+	assert.Equal(t, model.OperationStateFailed, op.State)
+	assert.Contains(t, op.ErrorMessage, "PERMISSION_DENIED on projects/p/databases/d/documents/users/u1")
+	assert.Len(t, repo.remaining(), 2)
+
+	security.SetValidationResult(true, nil)
+	_, err = uc.ResumeOperation(context.Background(), op.OperationID, deleteOwner)
+	require.NoError(t, err)
+
+	op = waitForOperation(t, uc, op.OperationID)
+	assert.Equal(t, model.OperationStateSucceeded, op.State)
+	assert.Equal(t, 2, op.Attempts)
+	assert.Empty(t, repo.remaining())
+}
+
+func TestRecursiveDelete_BypassRulesRequiresAdmin(t *testing.T) {
+	repo := newTreeRepoMock("users/u1")
+	security := NewMockSecurityUsecase()
+	security.SetValidationResult(false, nil)
+	uc := NewRecursiveDeleteUsecase(repo, security, nil, nil, &MockLogger{})
+
+	_, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{
+		ProjectID:   "p",
+		DatabaseID:  "d",
+		Path:        "users/u1",
+		BypassRules: true,
+		User:        &authModel.User{UserID: "user"},
+	})
+	assert.True(t, errors.IsAuthorization(err))
+
+	op, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{
+		ProjectID:   "p",
+		DatabaseID:  "d",
+		Path:        "users/u1",
+		BypassRules: true,
+		User:        &authModel.User{UserID: "admin", Roles: []string{AdminRole}},
+	})
+	require.NoError(t, err)
+
+	op = waitForOperation(t, uc, op.OperationID)
+	assert.Equal(t, model.OperationStateSucceeded, op.State)
+	assert.Equal(t, "admin", op.RequestedBy)
+	assert.Empty(t, repo.remaining())
+}
+
+func TestRecursiveDelete_Validation(t *testing.T) {
+	uc := NewRecursiveDeleteUsecase(newTreeRepoMock(), nil, nil, nil, &MockLogger{})
+
+	_, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d"})
+	assert.True(t, errors.IsValidation(err))
+
+	_, err = uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d", Path: "users//u1"})
+	assert.True(t, errors.IsValidation(err))
+
+	_, err = uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d", Path: "users/u1"})
+	assert.True(t, errors.IsAuthentication(err))
+
+	_, err = uc.GetOperation(context.Background(), "missing", deleteOwner)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestRecursiveDelete_OperationsBelongToTheirOwner(t *testing.T) {
+	repo := newTreeRepoMock("users/u1")
+	security := NewMockSecurityUsecase()
+	security.SetValidationResult(false, nil)
+	uc := NewRecursiveDeleteUsecase(repo, security, nil, nil, &MockLogger{})
+
+	op, err := uc.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d", Path: "users/u1", User: deleteOwner})
+	require.NoError(t, err)
+	op = waitForOperation(t, uc, op.OperationID)
+	require.Equal(t, model.OperationStateFailed, op.State)
+
+	// Other users do not see the operation
+	other := &authModel.User{UserID: "other"}
+	_, err = uc.GetOperation(context.Background(), op.OperationID, other)
+	assert.True(t, errors.IsNotFound(err))
+	_, err = uc.ResumeOperation(context.Background(), op.OperationID, other)
+	assert.True(t, errors.IsNotFound(err))
+	assert.True(t, errors.IsNotFound(uc.CancelOperation(context.Background(), op.OperationID, other)))
+	_, err = uc.GetOperation(context.Background(), op.OperationID, nil)
+	assert.True(t, errors.IsNotFound(err))
+
+	got, err := uc.GetOperation(context.Background(), op.OperationID, deleteOwner)
+	require.NoError(t, err)
+	assert.Equal(t, "owner", got.RequestedBy)
+}
+
+func TestRecursiveDelete_OperationsBelongToTheirOrganization(t *testing.T) {
+	repo := newTreeRepoMock("users/u1")
+	security := NewMockSecurityUsecase()
+	security.SetValidationResult(false, nil)
+	uc := NewRecursiveDeleteUsecase(repo, security, nil, nil, &MockLogger{})
+	admin := &authModel.User{UserID: "admin", Roles: []string{AdminRole}}
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+
+	op, err := uc.StartRecursiveDelete(orgA, RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d", Path: "users/u1", User: deleteOwner})
+	require.NoError(t, err)
+	assert.Equal(t, "org-a", op.OrganizationID)
+	require.Eventually(t, func() bool {
+		op, err = uc.GetOperation(orgA, op.OperationID, admin)
+		return err == nil && op.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// The same project ID in another organization does not reach the operation, not even
+	// for its administrators
+	_, err = uc.GetOperation(orgB, op.OperationID, admin)
+	assert.True(t, errors.IsNotFound(err))
+	_, err = uc.ResumeOperation(orgB, op.OperationID, deleteOwner)
+	assert.True(t, errors.IsNotFound(err))
+	assert.True(t, errors.IsNotFound(uc.CancelOperation(orgB, op.OperationID, admin)))
+	_, err = uc.GetOperation(context.Background(), op.OperationID, admin)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestRecursiveDelete_ResumedByAnotherInstance(t *testing.T) {
+	repo := newTreeRepoMock("users/u1", "users/u1/posts/p1")
+	store := NewInMemoryOperationStore()
+	security := NewMockSecurityUsecase()
+	security.SetValidationResult(false, nil)
+	first := NewRecursiveDeleteUsecase(repo, security, nil, store, &MockLogger{})
+
+	op, err := first.StartRecursiveDelete(context.Background(), RecursiveDeleteRequest{ProjectID: "p", DatabaseID: "d", Path: "users/u1", BatchSize: 7, User: deleteOwner})
+	require.NoError(t, err)
+	op = waitForOperation(t, first, op.OperationID)
+	require.Equal(t, model.OperationStateFailed, op.State)
+	assert.Equal(t, 7, op.BatchSize)
+
+	// A second instance sharing the store resumes the operation from its stored request
+	security.SetValidationResult(true, nil)
+	second := NewRecursiveDeleteUsecase(repo, security, nil, store, &MockLogger{})
+	_, err = second.ResumeOperation(context.Background(), op.OperationID, deleteOwner)
+	require.NoError(t, err)
+
+	op = waitForOperation(t, second, op.OperationID)
+	assert.Equal(t, model.OperationStateSucceeded, op.State)
+	assert.Empty(t, repo.remaining())
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/domain/service"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+)
+
+const (
+	// DefaultSchemaSampleSize is the number of documents profiled when no sample size is requested
+	DefaultSchemaSampleSize = 1000
+	// MaxSchemaSampleSize bounds the documents kept for a sampled discovery; a full scan has no limit
+	MaxSchemaSampleSize = 10000
+	// schemaDiscoveryPageSize is the page size used to read the collection
+	schemaDiscoveryPageSize = 300
+)
+
+// SchemaDiscoveryUsecase defines the primary port for collection schema discovery
+type SchemaDiscoveryUsecase interface {
+	// DiscoverSchema samples (or fully scans) a collection and reports every observed field path.
+	// Documents are read without security rules, so callers must be administrators.
+	DiscoverSchema(ctx context.Context, req DiscoverSchemaRequest) (*model.CollectionSchemaReport, error)
+	// ExportJSONSchema converts a report into a starting collection schema
+	ExportJSONSchema(report *model.CollectionSchemaReport) *model.FieldSchema
+}
+
+// schemaDiscoveryUsecase implements SchemaDiscoveryUsecase on top of the Firestore repository
+type schemaDiscoveryUsecase struct {
+	firestoreRepo repository.FirestoreRepository
+	discovery     service.SchemaDiscoveryService
+	logger        logger.Logger
+}
+
+// NewSchemaDiscoveryUsecase creates a new schema discovery usecase
+func NewSchemaDiscoveryUsecase(firestoreRepo repository.FirestoreRepository, discovery service.SchemaDiscoveryService, log logger.Logger) SchemaDiscoveryUsecase {
+	if discovery == nil {
+		discovery = service.NewSchemaDiscoveryService()
+	}
+	return &schemaDiscoveryUsecase{
+		firestoreRepo: firestoreRepo,
+		discovery:     discovery,
+		logger:        log,
+	}
+}
+
+// DiscoverSchema implements SchemaDiscoveryUsecase
+func (uc *schemaDiscoveryUsecase) DiscoverSchema(ctx context.Context, req DiscoverSchemaRequest) (*model.CollectionSchemaReport, error) {
+	if req.ProjectID == "" || req.DatabaseID == "" || req.CollectionID == "" {
+		return nil, errors.NewValidationError("project ID, database ID and collection ID are required")
+	}
+	limit := req.SampleSize
+	if limit == 0 {
+		limit = DefaultSchemaSampleSize
+	}
+	if limit < 0 || limit > MaxSchemaSampleSize {
+		return nil, errors.NewValidationError(fmt.Sprintf("sample size must be between 1 and %d", MaxSchemaSampleSize))
+	}
+	maxExamples := req.MaxExamples
+	if maxExamples == 0 {
+		maxExamples = service.DefaultSchemaExamples
+	}
+
+	// A sampled discovery still reads the whole collection but only profiles a uniform
+	// sample of it (reservoir sampling), so the first documents do not stand for the rest
+	collector := uc.discovery.NewCollector(maxExamples)
+	var sample []*model.Document
+	read := int64(0)
+	pageToken := ""
+	for {
+		docs, nextPageToken, err := uc.firestoreRepo.ListDocuments(ctx, req.ProjectID, req.DatabaseID, req.CollectionID, schemaDiscoveryPageSize, pageToken, "", false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read collection %s: %w", req.CollectionID, err)
+		}
+		for _, doc := range docs {
+			read++
+			switch {
+			case req.FullScan:
+				collector.AddDocument(doc)
+			case len(sample) < limit:
+				sample = append(sample, doc)
+			default:
+				if i := rand.Int64N(read); i < int64(limit) {
+					sample[i] = doc
+				}
+			}
+		}
+		if nextPageToken == "" || nextPageToken == pageToken {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	for _, doc := range sample {
+		collector.AddDocument(doc)
+	}
+	sampled := !req.FullScan && read > int64(limit)
+
+	report := &model.CollectionSchemaReport{
+		ProjectID:        req.ProjectID,
+		DatabaseID:       req.DatabaseID,
+		CollectionID:     req.CollectionID,
+		DocumentsScanned: collector.DocumentCount(),
+		Sampled:          sampled,
+		Fields:           collector.FieldStats(),
+		GeneratedAt:      time.Now(),
+	}
+	uc.logger.Info("Collection schema discovered",
+		"collection", req.CollectionID,
+		"documents", report.DocumentsScanned,
+		"fields", len(report.Fields),
+		"sampled", report.Sampled)
+	return report, nil
+}
+
+// ExportJSONSchema implements SchemaDiscoveryUsecase
+func (uc *schemaDiscoveryUsecase) ExportJSONSchema(report *model.CollectionSchemaReport) *model.FieldSchema {
+	return uc.discovery.ExportJSONSchema(report)
+}
@@ -0,0 +1,107 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedRepoMock serves a fixed collection through ListDocuments pagination
+type pagedRepoMock struct {
+	repository.FirestoreRepository
+	docs  []*model.Document
+	pages int
+}
+
+func (m *pagedRepoMock) ListDocuments(ctx context.Context, projectID, databaseID, collectionID string, pageSize int32, pageToken, orderBy string, showMissing bool) ([]*model.Document, string, error) {
+	m.pages++
+	start := 0
+	if pageToken != "" {
+		start, _ = strconv.Atoi(pageToken)
+	}
+	end := start + int(pageSize)
+	if end >= len(m.docs) {
+		return m.docs[start:], "", nil
+	}
+	return m.docs[start:end], strconv.Itoa(end), nil
+}
+
+func newPagedRepoMock(count int) *pagedRepoMock {
+	m := &pagedRepoMock{}
+	for i := 0; i < count; i++ {
+		data := map[string]interface{}{"name": fmt.Sprintf("user-%d", i)}
+		if i%2 == 0 {
+			data["profile"] = map[string]interface{}{"age": int64(i)}
+		}
+		m.docs = append(m.docs, &model.Document{DocumentID: strconv.Itoa(i), Fields: fields(data)})
+	}
+	return m
+}
+
+func TestSchemaDiscovery_SampleAndFullScan(t *testing.T) {
+	repo := newPagedRepoMock(700)
+	uc := NewSchemaDiscoveryUsecase(repo, nil, &MockLogger{})
+	req := DiscoverSchemaRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "users", SampleSize: 400}
+
+	report, err := uc.DiscoverSchema(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), report.DocumentsScanned)
+	assert.True(t, report.Sampled)
+	require.Len(t, report.Fields, 3)
+	assert.Equal(t, "profile.age", report.Fields[2].FieldPath)
+	assert.InDelta(t, 0.5, report.Fields[2].PresenceRatio, 0.1)
+
+	req.FullScan = true
+	report, err = uc.DiscoverSchema(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), report.DocumentsScanned)
+	assert.False(t, report.Sampled)
+
+	schema := uc.ExportJSONSchema(report)
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.Equal(t, []string{"age"}, schema.Properties["profile"].Required)
+}
+
+func TestSchemaDiscovery_SampleSpansTheWholeCollection(t *testing.T) {
+	repo := newPagedRepoMock(1000)
+	for _, doc := range repo.docs[500:] {
+		doc.Fields["plan"] = model.NewFieldValue("pro")
+	}
+	uc := NewSchemaDiscoveryUsecase(repo, nil, &MockLogger{})
+
+	report, err := uc.DiscoverSchema(context.Background(), DiscoverSchemaRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "users", SampleSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), report.DocumentsScanned)
+	assert.True(t, report.Sampled)
+
+	// Documents after the first page are part of the sample
+	var paths []string
+	for _, field := range report.Fields {
+		paths = append(paths, field.FieldPath)
+	}
+	assert.Contains(t, paths, "plan")
+	assert.Equal(t, 4, repo.pages)
+}
+
+func TestSchemaDiscovery_Validation(t *testing.T) {
+	uc := NewSchemaDiscoveryUsecase(newPagedRepoMock(0), nil, &MockLogger{})
+
+	_, err := uc.DiscoverSchema(context.Background(), DiscoverSchemaRequest{ProjectID: "p", DatabaseID: "d"})
+	assert.True(t, errors.IsValidation(err))
+
+	_, err = uc.DiscoverSchema(context.Background(), DiscoverSchemaRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "c", SampleSize: MaxSchemaSampleSize + 1})
+	assert.True(t, errors.IsValidation(err))
+
+	report, err := uc.DiscoverSchema(context.Background(), DiscoverSchemaRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "c"})
+	require.NoError(t, err)
+	assert.Empty(t, report.Fields)
+}
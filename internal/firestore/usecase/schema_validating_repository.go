@@ -0,0 +1,342 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/shared/errors"
+)
+
+// schemaValidatingRepository decorates a FirestoreRepository so that every write path
+// (single document writes, path-based writes, batch writes and transactions) is checked
+// against the collection schemas before reaching storage.
+type schemaValidatingRepository struct {
+	repository.FirestoreRepository
+	schemaUC CollectionSchemaUsecase
+}
+
+// NewSchemaValidatingRepository wraps repo with collection schema validation
+func NewSchemaValidatingRepository(repo repository.FirestoreRepository, schemaUC CollectionSchemaUsecase) repository.FirestoreRepository {
+	return &schemaValidatingRepository{FirestoreRepository: repo, schemaUC: schemaUC}
+}
+
+// CreateDocument validates the new document before creating it
+func (r *schemaValidatingRepository) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
+	if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionID, data); err != nil {
+		return nil, err
+	}
+	return r.FirestoreRepository.CreateDocument(ctx, projectID, databaseID, collectionID, documentID, data)
+}
+
+// UpdateDocument validates the document as it will be after applying the update mask
+func (r *schemaValidatingRepository) UpdateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	result := data
+	if len(updateMask) > 0 {
+		existing, err := existingDocument(r.FirestoreRepository.GetDocument(ctx, projectID, databaseID, collectionID, documentID))
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			// Let the underlying repository report missing documents
+			return r.FirestoreRepository.UpdateDocument(ctx, projectID, databaseID, collectionID, documentID, data, updateMask)
+		}
+		result = applyUpdateMask(existing.Fields, data, updateMask)
+	}
+	if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionID, result); err != nil {
+		return nil, err
+	}
+	return r.FirestoreRepository.UpdateDocument(ctx, projectID, databaseID, collectionID, documentID, data, updateMask)
+}
+
+// SetDocument validates the resulting document, merged onto the existing one when merge is set
+func (r *schemaValidatingRepository) SetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) (*model.Document, error) {
+	result := data
+	if merge {
+		existing, err := existingDocument(r.FirestoreRepository.GetDocument(ctx, projectID, databaseID, collectionID, documentID))
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			result = mergeFields(existing.Fields, data)
+		}
+	}
+	if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionID, result); err != nil {
+		return nil, err
+	}
+	return r.FirestoreRepository.SetDocument(ctx, projectID, databaseID, collectionID, documentID, data, merge)
+}
+
+// CreateDocumentByPath validates the new document before creating it
+func (r *schemaValidatingRepository) CreateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue) (*model.Document, error) {
+	if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok {
+		if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionPath, data); err != nil {
+			return nil, err
+		}
+	}
+	return r.FirestoreRepository.CreateDocumentByPath(ctx, path, data)
+}
+
+// UpdateDocumentByPath validates the document as it will be after applying the update mask
+func (r *schemaValidatingRepository) UpdateDocumentByPath(ctx context.Context, path string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok {
+		result := data
+		if len(updateMask) > 0 {
+			existing, err := existingDocument(r.FirestoreRepository.GetDocumentByPath(ctx, path))
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				result = applyUpdateMask(existing.Fields, data, updateMask)
+			}
+		}
+		if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionPath, result); err != nil {
+			return nil, err
+		}
+	}
+	return r.FirestoreRepository.UpdateDocumentByPath(ctx, path, data, updateMask)
+}
+
+// RunBatchWrite validates every create, update and set before any write is applied
+func (r *schemaValidatingRepository) RunBatchWrite(ctx context.Context, projectID, databaseID string, writes []*model.WriteOperation) ([]*model.WriteResult, error) {
+	for i, write := range writes {
+		if write == nil || write.Type == model.WriteTypeDelete {
+			continue
+		}
+		_, _, collectionPath, ok := splitDocumentPath(write.Path)
+		if !ok {
+			continue
+		}
+		fields := make(map[string]*model.FieldValue, len(write.Data))
+		for name, value := range write.Data {
+			fields[name] = toFieldValue(value)
+		}
+		if err := r.schemaUC.ValidateWrite(ctx, projectID, databaseID, collectionPath, fields); err != nil {
+			return nil, fmt.Errorf("operation %d failed: %w", i, err)
+		}
+	}
+	return r.FirestoreRepository.RunBatchWrite(ctx, projectID, databaseID, writes)
+}
+
+// RunTransaction validates writes issued through the transaction
+func (r *schemaValidatingRepository) RunTransaction(ctx context.Context, fn func(tx repository.Transaction) error) error {
+	return r.FirestoreRepository.RunTransaction(ctx, func(tx repository.Transaction) error {
+		return fn(&schemaValidatingTransaction{Transaction: tx, ctx: ctx, schemaUC: r.schemaUC})
+	})
+}
+
+// schemaValidatingTransaction checks transactional writes against the collection schemas
+type schemaValidatingTransaction struct {
+	repository.Transaction
+	ctx      context.Context
+	schemaUC CollectionSchemaUsecase
+}
+
+func (t *schemaValidatingTransaction) Create(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) error {
+	if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionID, data); err != nil {
+		return err
+	}
+	return t.Transaction.Create(projectID, databaseID, collectionID, documentID, data)
+}
+
+func (t *schemaValidatingTransaction) CreateByPath(path string, data map[string]*model.FieldValue) error {
+	if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok {
+		if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionPath, data); err != nil {
+			return err
+		}
+	}
+	return t.Transaction.CreateByPath(path, data)
+}
+
+func (t *schemaValidatingTransaction) Update(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) error {
+	result := data
+	if len(updateMask) > 0 {
+		existing, err := existingDocument(t.Transaction.Get(projectID, databaseID, collectionID, documentID))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			result = applyUpdateMask(existing.Fields, data, updateMask)
+		}
+	}
+	if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionID, result); err != nil {
+		return err
+	}
+	return t.Transaction.Update(projectID, databaseID, collectionID, documentID, data, updateMask)
+}
+
+func (t *schemaValidatingTransaction) UpdateByPath(path string, data map[string]*model.FieldValue, updateMask []string) error {
+	if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok {
+		result := data
+		if len(updateMask) > 0 {
+			existing, err := existingDocument(t.Transaction.GetByPath(path))
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				result = applyUpdateMask(existing.Fields, data, updateMask)
+			}
+		}
+		if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionPath, result); err != nil {
+			return err
+		}
+	}
+	return t.Transaction.UpdateByPath(path, data, updateMask)
+}
+
+func (t *schemaValidatingTransaction) Set(projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, merge bool) error {
+	result := data
+	if merge {
+		existing, err := existingDocument(t.Transaction.Get(projectID, databaseID, collectionID, documentID))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			result = mergeFields(existing.Fields, data)
+		}
+	}
+	if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionID, result); err != nil {
+		return err
+	}
+	return t.Transaction.Set(projectID, databaseID, collectionID, documentID, data, merge)
+}
+
+func (t *schemaValidatingTransaction) SetByPath(path string, data map[string]*model.FieldValue, merge bool) error {
+	if projectID, databaseID, collectionPath, ok := splitDocumentPath(path); ok {
+		result := data
+		if merge {
+			existing, err := existingDocument(t.Transaction.GetByPath(path))
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				result = mergeFields(existing.Fields, data)
+			}
+		}
+		if err := t.schemaUC.ValidateWrite(t.ctx, projectID, databaseID, collectionPath, result); err != nil {
+			return err
+		}
+	}
+	return t.Transaction.SetByPath(path, data, merge)
+}
+
+// existingDocument returns the document a write applies to, or nil when it does not exist.
+// Any other read error fails the write: validating the partial data alone would let an
+// update store a document the schema rejects.
+func existingDocument(doc *model.Document, err error) (*model.Document, error) {
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the document to validate the write: %w", err)
+	}
+	return doc, nil
+}
+
+// splitDocumentPath extracts the collection path of a document path. Full resource names
+// (projects/{p}/databases/{d}/documents/...) also yield the project and database IDs.
+func splitDocumentPath(path string) (projectID, databaseID, collectionPath string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) >= 5 && segments[0] == "projects" && segments[2] == "databases" && segments[4] == "documents" {
+		projectID, databaseID = segments[1], segments[3]
+		segments = segments[5:]
+	}
+	if len(segments) < 2 || len(segments)%2 != 0 {
+		return "", "", "", false
+	}
+	return projectID, databaseID, strings.Join(segments[:len(segments)-1], "/"), true
+}
+
+// applyUpdateMask returns the fields of existing with the masked paths taken from data.
+// Masked paths absent from data are removed, as in Firestore.
+func applyUpdateMask(existing, data map[string]*model.FieldValue, updateMask []string) map[string]*model.FieldValue {
+	result := copyFields(existing)
+	for _, fieldPath := range updateMask {
+		parts := strings.Split(fieldPath, ".")
+		value, found := lookupFieldPath(data, parts)
+		setFieldPath(result, parts, value, found)
+	}
+	return result
+}
+
+func lookupFieldPath(fields map[string]*model.FieldValue, parts []string) (*model.FieldValue, bool) {
+	value, exists := fields[parts[0]]
+	if !exists {
+		return nil, false
+	}
+	if len(parts) == 1 {
+		return value, true
+	}
+	if value == nil {
+		return nil, false
+	}
+	nested, ok := value.Value.(*model.MapValue)
+	if !ok || nested == nil {
+		return nil, false
+	}
+	return lookupFieldPath(nested.Fields, parts[1:])
+}
+
+// setFieldPath sets (or deletes when !found) a dotted path, copying the maps it descends into
+func setFieldPath(fields map[string]*model.FieldValue, parts []string, value *model.FieldValue, found bool) {
+	if len(parts) == 1 {
+		if found {
+			fields[parts[0]] = value
+		} else {
+			delete(fields, parts[0])
+		}
+		return
+	}
+	var nested map[string]*model.FieldValue
+	if current, exists := fields[parts[0]]; exists && current != nil {
+		if mapValue, ok := current.Value.(*model.MapValue); ok && mapValue != nil {
+			nested = copyFields(mapValue.Fields)
+		}
+	}
+	if nested == nil {
+		if !found {
+			return
+		}
+		nested = make(map[string]*model.FieldValue)
+	}
+	setFieldPath(nested, parts[1:], value, found)
+	fields[parts[0]] = &model.FieldValue{ValueType: model.FieldTypeMap, Value: &model.MapValue{Fields: nested}}
+}
+
+// mergeFields deep-merges data onto existing the way set-with-merge does
+func mergeFields(existing, data map[string]*model.FieldValue) map[string]*model.FieldValue {
+	result := copyFields(existing)
+	for name, value := range data {
+		current, exists := result[name]
+		if exists && current != nil && value != nil {
+			currentMap, currentIsMap := current.Value.(*model.MapValue)
+			valueMap, valueIsMap := value.Value.(*model.MapValue)
+			if currentIsMap && valueIsMap && currentMap != nil && valueMap != nil {
+				result[name] = &model.FieldValue{
+					ValueType: model.FieldTypeMap,
+					Value:     &model.MapValue{Fields: mergeFields(currentMap.Fields, valueMap.Fields)},
+				}
+				continue
+			}
+		}
+		result[name] = value
+	}
+	return result
+}
+
+func copyFields(fields map[string]*model.FieldValue) map[string]*model.FieldValue {
+	result := make(map[string]*model.FieldValue, len(fields))
+	for name, value := range fields {
+		result[name] = value
+	}
+	return result
+}
+
+func toFieldValue(value interface{}) *model.FieldValue {
+	if fieldValue, ok := value.(*model.FieldValue); ok {
+		return fieldValue
+	}
+	return model.NewFieldValue(value)
+}
@@ -0,0 +1,551 @@
+package usecase
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/domain/service"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+)
+
+const (
+	// DefaultSearchLimit is the number of hits returned when no limit is requested
+	DefaultSearchLimit = 20
+	// MaxSearchLimit bounds the number of hits of a single search
+	MaxSearchLimit = 100
+	// searchRebuildPageSize is the page size used to (re)build a collection index
+	searchRebuildPageSize = 300
+	// maxSearchCandidates bounds the ranked documents loaded and filtered by a single search
+	maxSearchCandidates = 500
+	// searchLoadBatchSize is the number of candidates loaded concurrently
+	searchLoadBatchSize = 50
+)
+
+// SearchUsecase defines the primary port for full-text search over string fields
+type SearchUsecase interface {
+	// SetSearchConfig declares the searchable fields of a collection and rebuilds its index
+	SetSearchConfig(ctx context.Context, req SetSearchConfigRequest) (*model.SearchIndexConfig, error)
+	GetSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error)
+	DeleteSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) error
+	// Search returns ranked, highlighted documents matching the query and the optional filters
+	Search(ctx context.Context, req SearchRequest) (*model.SearchResult, error)
+
+	// Index maintenance, called after successful writes
+	DocumentIndexer
+}
+
+// SearchConfigStore defines the secondary port for search configuration persistence
+type SearchConfigStore interface {
+	SaveSearchConfig(ctx context.Context, config *model.SearchIndexConfig) error
+	GetSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error)
+	DeleteSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) error
+}
+
+// InMemorySearchConfigStore implements SearchConfigStore with in-memory storage
+type InMemorySearchConfigStore struct {
+	configs map[string]*model.SearchIndexConfig // index key -> config
+	mu      sync.RWMutex
+}
+
+// NewInMemorySearchConfigStore creates a new in-memory search configuration store
+func NewInMemorySearchConfigStore() SearchConfigStore {
+	return &InMemorySearchConfigStore{
+		configs: make(map[string]*model.SearchIndexConfig),
+	}
+}
+
+// SaveSearchConfig stores a copy of the configuration
+func (s *InMemorySearchConfigStore) SaveSearchConfig(ctx context.Context, config *model.SearchIndexConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *config
+	s.configs[searchIndexKey(ctx, config.ProjectID, config.DatabaseID, config.CollectionID)] = &stored
+	return nil
+}
+
+// GetSearchConfig returns a copy of the stored configuration
+func (s *InMemorySearchConfigStore) GetSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	config, exists := s.configs[searchIndexKey(ctx, projectID, databaseID, collectionID)]
+	if !exists {
+		return nil, errors.NewNotFoundError("search index")
+	}
+	result := *config
+	return &result, nil
+}
+
+// DeleteSearchConfig removes a stored configuration
+func (s *InMemorySearchConfigStore) DeleteSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	if _, exists := s.configs[key]; !exists {
+		return errors.NewNotFoundError("search index")
+	}
+	delete(s.configs, key)
+	return nil
+}
+
+// searchUsecase implements SearchUsecase with one in-memory index per tenant collection.
+// Configurations are read from the store, and indexes catch up with the changelog before
+// a search, so every instance returns the writes committed by the others.
+type searchUsecase struct {
+	firestoreRepo repository.FirestoreRepository
+	textSearch    service.TextSearchService
+	store         SearchConfigStore
+	changes       *changeLogFollower
+	logger        logger.Logger
+
+	mu      sync.RWMutex
+	configs map[string]*model.SearchIndexConfig // index key -> config the index was built for
+	built   map[string]bool                     // index keys built from storage by this instance
+}
+
+// NewSearchUsecase creates a search usecase with in-memory configurations that only indexes
+// the writes of its own instance. firestoreRepo is used to build indexes from the stored
+// documents and to load hits, so it must not be the indexing repository.
+func NewSearchUsecase(firestoreRepo repository.FirestoreRepository, textSearch service.TextSearchService, log logger.Logger) SearchUsecase {
+	return NewSearchUsecaseWithStores(firestoreRepo, textSearch, NewInMemorySearchConfigStore(), nil, log)
+}
+
+// NewSearchUsecaseWithStores creates a search usecase over a shared configuration store.
+// When changeLog is set, indexes also apply the changes recorded by other instances.
+func NewSearchUsecaseWithStores(firestoreRepo repository.FirestoreRepository, textSearch service.TextSearchService, store SearchConfigStore, changeLog ChangeLogStore, log logger.Logger) SearchUsecase {
+	if textSearch == nil {
+		textSearch = service.NewTextSearchService()
+	}
+	return &searchUsecase{
+		firestoreRepo: firestoreRepo,
+		textSearch:    textSearch,
+		store:         store,
+		changes:       newChangeLogFollower(changeLog),
+		logger:        log,
+		configs:       make(map[string]*model.SearchIndexConfig),
+		built:         make(map[string]bool),
+	}
+}
+
+// searchIndexKey isolates indexes per organization database and collection
+func searchIndexKey(ctx context.Context, projectID, databaseID, collectionID string) string {
+	organizationID, _ := utils.GetOrganizationIDFromContext(ctx)
+	return strings.Join([]string{organizationID, projectID, databaseID, collectionID}, "/")
+}
+
+// SetSearchConfig implements SearchUsecase
+func (uc *searchUsecase) SetSearchConfig(ctx context.Context, req SetSearchConfigRequest) (*model.SearchIndexConfig, error) {
+	if req.ProjectID == "" || req.DatabaseID == "" || req.CollectionID == "" {
+		return nil, errors.NewValidationError("project ID, database ID and collection ID are required")
+	}
+	if len(req.Fields) == 0 {
+		return nil, errors.NewValidationError("at least one searchable field is required")
+	}
+	fields := make([]model.SearchField, 0, len(req.Fields))
+	for _, field := range req.Fields {
+		if strings.Trim(field.FieldPath, ".") == "" || strings.Contains(field.FieldPath, "..") {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid field path %q", field.FieldPath))
+		}
+		if field.Weight < 0 {
+			return nil, errors.NewValidationError(fmt.Sprintf("weight of %q must not be negative", field.FieldPath))
+		}
+		if field.Weight == 0 {
+			field.Weight = 1
+		}
+		fields = append(fields, field)
+	}
+
+	key := searchIndexKey(ctx, req.ProjectID, req.DatabaseID, req.CollectionID)
+	// Stored timestamps have millisecond precision; instances compare UpdatedAt to detect changes
+	now := time.Now().Truncate(time.Millisecond)
+	config := &model.SearchIndexConfig{
+		ProjectID:    req.ProjectID,
+		DatabaseID:   req.DatabaseID,
+		CollectionID: req.CollectionID,
+		Fields:       fields,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	existing, err := uc.store.GetSearchConfig(ctx, req.ProjectID, req.DatabaseID, req.CollectionID)
+	if err == nil {
+		config.CreatedAt = existing.CreatedAt
+	} else if !isNotFoundErr(err) {
+		return nil, fmt.Errorf("failed to get search config: %w", err)
+	}
+	if err := uc.store.SaveSearchConfig(ctx, config); err != nil {
+		return nil, fmt.Errorf("failed to save search config: %w", err)
+	}
+
+	uc.mu.Lock()
+	uc.configs[key] = config
+	delete(uc.built, key)
+	uc.mu.Unlock()
+
+	if err := uc.rebuild(ctx, key, config); err != nil {
+		return nil, err
+	}
+	uc.logger.Info("Search index configured", "collection", req.CollectionID, "fields", len(fields))
+	result := *config
+	return &result, nil
+}
+
+// GetSearchConfig implements SearchUsecase
+func (uc *searchUsecase) GetSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error) {
+	return uc.store.GetSearchConfig(ctx, projectID, databaseID, collectionID)
+}
+
+// DeleteSearchConfig implements SearchUsecase
+func (uc *searchUsecase) DeleteSearchConfig(ctx context.Context, projectID, databaseID, collectionID string) error {
+	if err := uc.store.DeleteSearchConfig(ctx, projectID, databaseID, collectionID); err != nil {
+		return err
+	}
+	uc.drop(searchIndexKey(ctx, projectID, databaseID, collectionID))
+	return nil
+}
+
+// Search implements SearchUsecase
+func (uc *searchUsecase) Search(ctx context.Context, req SearchRequest) (*model.SearchResult, error) {
+	if strings.TrimSpace(req.Query) == "" {
+		return nil, errors.NewValidationError("search query is required")
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit < 0 || limit > MaxSearchLimit {
+		return nil, errors.NewValidationError(fmt.Sprintf("limit must be between 1 and %d", MaxSearchLimit))
+	}
+
+	key := searchIndexKey(ctx, req.ProjectID, req.DatabaseID, req.CollectionID)
+	config, err := uc.loadConfig(ctx, key, req.ProjectID, req.DatabaseID, req.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("collection %s has no searchable fields", req.CollectionID))
+	}
+	if err := uc.ensureBuilt(ctx, key, config); err != nil {
+		return nil, err
+	}
+
+	filters, err := normalizeSearchFilters(req.Filters)
+	if err != nil {
+		return nil, errors.NewValidationError(err.Error())
+	}
+
+	ranked := uc.textSearch.Search(key, req.Query, !req.MatchAny)
+	if len(ranked) > maxSearchCandidates {
+		ranked = ranked[:maxSearchCandidates]
+	}
+	result := &model.SearchResult{Query: req.Query, Hits: make([]*model.SearchHit, 0)}
+	for start := 0; start < len(ranked); start += searchLoadBatchSize {
+		batch := ranked[start:min(start+searchLoadBatchSize, len(ranked))]
+		docs, err := uc.loadCandidates(ctx, req, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, candidate := range batch {
+			doc := docs[i]
+			if doc == nil {
+				// Deleted behind the index's back: drop the stale entry
+				uc.textSearch.RemoveDocument(key, candidate.DocumentID)
+				continue
+			}
+			if len(filters) > 0 && !matchFilters(normalizeNumbers(documentToMap(doc)).(map[string]interface{}), filters) {
+				continue
+			}
+			result.TotalHits++
+			if len(result.Hits) < limit {
+				result.Hits = append(result.Hits, &model.SearchHit{
+					Document:   doc,
+					Score:      candidate.Score,
+					Highlights: uc.highlights(config, doc, req.Query),
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// loadCandidates loads a batch of ranked documents concurrently, in rank order.
+// Documents that no longer exist are returned as nil.
+func (uc *searchUsecase) loadCandidates(ctx context.Context, req SearchRequest, batch []service.ScoredDocument) ([]*model.Document, error) {
+	docs := make([]*model.Document, len(batch))
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, candidate := range batch {
+		wg.Add(1)
+		go func(i int, documentID string) {
+			defer wg.Done()
+			doc, err := uc.firestoreRepo.GetDocument(ctx, req.ProjectID, req.DatabaseID, req.CollectionID, documentID)
+			if err == nil {
+				docs[i] = doc
+			} else if !isNotFoundErr(err) {
+				errs[i] = fmt.Errorf("failed to load search hit %s: %w", documentID, err)
+			}
+		}(i, candidate.DocumentID)
+	}
+	wg.Wait()
+	if err := stderrors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// IsIndexed implements DocumentIndexer
+func (uc *searchUsecase) IsIndexed(ctx context.Context, projectID, databaseID, collectionID string) bool {
+	return uc.config(searchIndexKey(ctx, projectID, databaseID, collectionID)) != nil
+}
+
+// IndexDocument implements SearchUsecase
+func (uc *searchUsecase) IndexDocument(ctx context.Context, projectID, databaseID, collectionID string, doc *model.Document) {
+	if doc == nil {
+		return
+	}
+	key := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	if config := uc.config(key); config != nil {
+		uc.index(key, config, doc)
+	}
+}
+
+// RemoveDocument implements SearchUsecase
+func (uc *searchUsecase) RemoveDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) {
+	key := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	if uc.config(key) != nil {
+		uc.textSearch.RemoveDocument(key, documentID)
+	}
+}
+
+// RemoveCollection implements SearchUsecase. The configuration is kept for new documents.
+func (uc *searchUsecase) RemoveCollection(ctx context.Context, projectID, databaseID, collectionID string) {
+	key := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	if uc.config(key) != nil {
+		uc.textSearch.DropIndex(key)
+	}
+}
+
+func (uc *searchUsecase) config(key string) *model.SearchIndexConfig {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.configs[key]
+}
+
+// loadConfig returns the stored configuration of a collection, or nil when it has none.
+// An index built for an older configuration is dropped so it is rebuilt.
+func (uc *searchUsecase) loadConfig(ctx context.Context, key, projectID, databaseID, collectionID string) (*model.SearchIndexConfig, error) {
+	stored, err := uc.store.GetSearchConfig(ctx, projectID, databaseID, collectionID)
+	if isNotFoundErr(err) {
+		uc.drop(key)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search config: %w", err)
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if cached, ok := uc.configs[key]; ok && cached.UpdatedAt.Equal(stored.UpdatedAt) {
+		return cached, nil
+	}
+	uc.configs[key] = stored
+	delete(uc.built, key)
+	return stored, nil
+}
+
+// drop forgets the configuration and the index of a collection
+func (uc *searchUsecase) drop(key string) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if _, ok := uc.configs[key]; !ok {
+		return
+	}
+	delete(uc.configs, key)
+	delete(uc.built, key)
+	uc.textSearch.DropIndex(key)
+	uc.changes.forget(key)
+}
+
+// ensureBuilt builds the index from storage the first time a collection is searched, and
+// afterwards applies the changes committed since, including those of other instances
+func (uc *searchUsecase) ensureBuilt(ctx context.Context, key string, config *model.SearchIndexConfig) error {
+	uc.mu.RLock()
+	built := uc.built[key]
+	uc.mu.RUnlock()
+	if !built {
+		return uc.rebuild(ctx, key, config)
+	}
+
+	err := uc.changes.catchUp(ctx, key, utils.GetOrganizationIDOrDefault(ctx, ""), config.ProjectID, config.DatabaseID, config.CollectionID, func(documentID string, after *model.Document) {
+		if after == nil {
+			uc.textSearch.RemoveDocument(key, documentID)
+			return
+		}
+		uc.index(key, config, after)
+	})
+	if stderrors.Is(err, model.ErrChangeFeedCursorExpired) {
+		return uc.rebuild(ctx, key, config)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update search index for %s: %w", config.CollectionID, err)
+	}
+	return nil
+}
+
+func (uc *searchUsecase) rebuild(ctx context.Context, key string, config *model.SearchIndexConfig) error {
+	// Changes recorded after this point are applied by the next catch-up
+	head, err := uc.changes.head(ctx, utils.GetOrganizationIDOrDefault(ctx, ""), config.ProjectID, config.DatabaseID)
+	if err != nil {
+		return fmt.Errorf("failed to build search index for %s: %w", config.CollectionID, err)
+	}
+	uc.textSearch.DropIndex(key)
+	pageToken := ""
+	indexed := 0
+	for {
+		docs, nextPageToken, err := uc.firestoreRepo.ListDocuments(ctx, config.ProjectID, config.DatabaseID, config.CollectionID, searchRebuildPageSize, pageToken, "", false)
+		if err != nil {
+			return fmt.Errorf("failed to build search index for %s: %w", config.CollectionID, err)
+		}
+		for _, doc := range docs {
+			uc.index(key, config, doc)
+			indexed++
+		}
+		if nextPageToken == "" || nextPageToken == pageToken {
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	uc.mu.Lock()
+	uc.built[key] = true
+	uc.mu.Unlock()
+	uc.changes.markBuilt(key, head)
+	uc.logger.Debug("Search index built", "collection", config.CollectionID, "documents", indexed)
+	return nil
+}
+
+func (uc *searchUsecase) index(key string, config *model.SearchIndexConfig, doc *model.Document) {
+	texts := make(map[string][]string, len(config.Fields))
+	weights := make(map[string]float64, len(config.Fields))
+	for _, field := range config.Fields {
+		if values := searchableStrings(doc.Fields, field.FieldPath); len(values) > 0 {
+			texts[field.FieldPath] = values
+			weights[field.FieldPath] = field.Weight
+		}
+	}
+	if len(texts) == 0 {
+		uc.textSearch.RemoveDocument(key, doc.DocumentID)
+		return
+	}
+	uc.textSearch.IndexDocument(key, doc.DocumentID, texts, weights)
+}
+
+func (uc *searchUsecase) highlights(config *model.SearchIndexConfig, doc *model.Document, query string) map[string][]string {
+	highlights := make(map[string][]string)
+	for _, field := range config.Fields {
+		for _, value := range searchableStrings(doc.Fields, field.FieldPath) {
+			if snippet := uc.textSearch.Highlight(value, query); snippet != "" {
+				highlights[field.FieldPath] = append(highlights[field.FieldPath], snippet)
+			}
+		}
+	}
+	return highlights
+}
+
+// searchableStrings returns the string, or string array elements, stored at a dotted field path
+func searchableStrings(fields map[string]*model.FieldValue, fieldPath string) []string {
+	segments := strings.Split(fieldPath, ".")
+	current := fields
+	for i, segment := range segments {
+		value, exists := current[segment]
+		if !exists || value == nil {
+			return nil
+		}
+		if i < len(segments)-1 {
+			nested, ok := value.Value.(*model.MapValue)
+			if !ok || nested == nil {
+				return nil
+			}
+			current = nested.Fields
+			continue
+		}
+		switch v := value.Value.(type) {
+		case string:
+			return []string{v}
+		case *model.ArrayValue:
+			if v == nil {
+				return nil
+			}
+			var values []string
+			for _, item := range v.Values {
+				if item != nil {
+					if s, ok := item.Value.(string); ok {
+						values = append(values, s)
+					}
+				}
+			}
+			return values
+		}
+	}
+	return nil
+}
+
+// normalizeSearchFilters resolves dotted field paths and makes numbers comparable with stored values
+func normalizeSearchFilters(filters []model.Filter) ([]model.Filter, error) {
+	normalized := make([]model.Filter, len(filters))
+	for i, filter := range filters {
+		if len(filter.SubFilters) > 0 {
+			subFilters, err := normalizeSearchFilters(filter.SubFilters)
+			if err != nil {
+				return nil, err
+			}
+			filter.SubFilters = subFilters
+		} else {
+			fieldPath, err := filter.GetEffectiveFieldPath()
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter field %q: %w", filter.Field, err)
+			}
+			filter.FieldPath = fieldPath
+			filter.Value = normalizeNumbers(filter.Value)
+		}
+		normalized[i] = filter
+	}
+	return normalized, nil
+}
+
+// normalizeNumbers converts every number to float64: JSON filter values and stored integers compare equal
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalizeNumbers(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = normalizeNumbers(item)
+		}
+		return result
+	}
+	return value
+}
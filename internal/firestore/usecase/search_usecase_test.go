@@ -0,0 +1,323 @@
+package usecase_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memDocRepo stores documents per organization and collection
+type memDocRepo struct {
+	repository.FirestoreRepository
+	docs map[string]map[string]*model.Document // org/collection -> document ID -> document
+}
+
+func newMemDocRepo() *memDocRepo {
+	return &memDocRepo{docs: make(map[string]map[string]*model.Document)}
+}
+
+func (m *memDocRepo) collection(ctx context.Context, collectionID string) map[string]*model.Document {
+	organizationID, _ := utils.GetOrganizationIDFromContext(ctx)
+	key := organizationID + "/" + collectionID
+	if m.docs[key] == nil {
+		m.docs[key] = make(map[string]*model.Document)
+	}
+	return m.docs[key]
+}
+
+func (m *memDocRepo) GetDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) (*model.Document, error) {
+	// Read without creating the collection: searches load documents concurrently
+	organizationID, _ := utils.GetOrganizationIDFromContext(ctx)
+	if doc, ok := m.docs[organizationID+"/"+collectionID][documentID]; ok {
+		return doc, nil
+	}
+	return nil, errors.NewNotFoundError("document")
+}
+
+func (m *memDocRepo) CreateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue) (*model.Document, error) {
+	doc := &model.Document{ProjectID: projectID, DatabaseID: databaseID, CollectionID: collectionID, DocumentID: documentID, Fields: data}
+	m.collection(ctx, collectionID)[documentID] = doc
+	return doc, nil
+}
+
+func (m *memDocRepo) UpdateDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string, data map[string]*model.FieldValue, updateMask []string) (*model.Document, error) {
+	return m.CreateDocument(ctx, projectID, databaseID, collectionID, documentID, data)
+}
+
+func (m *memDocRepo) DeleteDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) error {
+	delete(m.collection(ctx, collectionID), documentID)
+	return nil
+}
+
+func (m *memDocRepo) ListDocuments(ctx context.Context, projectID, databaseID, collectionID string, pageSize int32, pageToken, orderBy string, showMissing bool) ([]*model.Document, string, error) {
+	var docs []*model.Document
+	for _, doc := range m.collection(ctx, collectionID) {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].DocumentID < docs[j].DocumentID })
+	return docs, "", nil
+}
+
+func newSearchTestSetup(t *testing.T, ctx context.Context) (SearchUsecase, repository.FirestoreRepository, *memDocRepo) {
+	store := newMemDocRepo()
+	uc := NewSearchUsecase(store, nil, &MockLogger{})
+	repo := NewIndexingRepository(store, uc)
+
+	_, err := repo.CreateDocument(ctx, "p", "d", "products", "before", fields(map[string]interface{}{"name": "Wool socks", "price": int64(5)}))
+	require.NoError(t, err)
+	_, err = uc.SetSearchConfig(ctx, SetSearchConfigRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionID: "products",
+		Fields: []model.SearchField{{FieldPath: "name", Weight: 2}, {FieldPath: "details.tags"}},
+	})
+	require.NoError(t, err)
+	return uc, repo, store
+}
+
+func hitIDs(result *model.SearchResult) []string {
+	ids := make([]string, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.Document.DocumentID)
+	}
+	return ids
+}
+
+func TestSearch_IndexFollowsWrites(t *testing.T) {
+	ctx := utils.WithOrganizationID(context.Background(), "org-a")
+	uc, repo, _ := newSearchTestSetup(t, ctx)
+	search := SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"}
+
+	// Documents written before the configuration are indexed when it is set
+	result, err := uc.Search(ctx, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before"}, hitIDs(result))
+	assert.Equal(t, []string{"Wool <em>socks</em>"}, result.Hits[0].Highlights["name"])
+
+	_, err = repo.CreateDocument(ctx, "p", "d", "products", "tagged", fields(map[string]interface{}{
+		"name":    "Running kit",
+		"details": map[string]interface{}{"tags": []interface{}{"socks", "shorts"}},
+		"price":   int64(30),
+	}))
+	require.NoError(t, err)
+	result, err = uc.Search(ctx, search)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"before", "tagged"}, hitIDs(result))
+
+	_, err = repo.UpdateDocument(ctx, "p", "d", "products", "before", fields(map[string]interface{}{"name": "Wool hat"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "products", "tagged"))
+	result, err = uc.Search(ctx, search)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+	assert.Equal(t, 0, result.TotalHits)
+}
+
+func TestSearch_FiltersAndLimit(t *testing.T) {
+	ctx := context.Background()
+	uc, repo, _ := newSearchTestSetup(t, ctx)
+	_, err := repo.CreateDocument(ctx, "p", "d", "products", "cheap", fields(map[string]interface{}{"name": "Cotton socks", "price": int64(3)}))
+	require.NoError(t, err)
+
+	result, err := uc.Search(ctx, SearchRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks",
+		Filters: []model.Filter{{Field: "price", Operator: model.OperatorLessThan, Value: float64(4)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cheap"}, hitIDs(result))
+	assert.Equal(t, 1, result.TotalHits)
+
+	result, err = uc.Search(ctx, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks", Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, result.Hits, 1)
+}
+
+func TestSearch_TotalHitsCountMatchingDocuments(t *testing.T) {
+	ctx := context.Background()
+	uc, repo, store := newSearchTestSetup(t, ctx)
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := repo.CreateDocument(ctx, "p", "d", "products", id, fields(map[string]interface{}{"name": "Cotton socks", "price": int64(3)}))
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.DeleteDocument(ctx, "p", "d", "products", "c"))
+
+	// Hits are limited, the total is not; documents deleted behind the index are not counted
+	result, err := uc.Search(ctx, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks", Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, result.Hits, 1)
+	assert.Equal(t, 3, result.TotalHits)
+
+	result, err = uc.Search(ctx, SearchRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks",
+		Filters: []model.Filter{{Field: "price", Operator: model.OperatorGreaterThan, Value: float64(4)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before"}, hitIDs(result))
+	assert.Equal(t, 1, result.TotalHits)
+}
+
+func TestSearch_TenantIsolation(t *testing.T) {
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+	uc, repo, _ := newSearchTestSetup(t, orgA)
+
+	_, err := uc.Search(orgB, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"})
+	assert.True(t, errors.IsValidation(err), "collection is not searchable in another organization")
+
+	_, err = uc.SetSearchConfig(orgB, SetSearchConfigRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Fields: []model.SearchField{{FieldPath: "name"}}})
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(orgB, "p", "d", "products", "b1", fields(map[string]interface{}{"name": "Silk socks"}))
+	require.NoError(t, err)
+
+	result, err := uc.Search(orgA, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before"}, hitIDs(result))
+	result, err = uc.Search(orgB, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b1"}, hitIDs(result))
+}
+
+func TestSearch_Validation(t *testing.T) {
+	ctx := context.Background()
+	uc, _, store := newSearchTestSetup(t, ctx)
+
+	_, err := uc.SetSearchConfig(ctx, SetSearchConfigRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "c"})
+	assert.True(t, errors.IsValidation(err))
+	_, err = uc.Search(ctx, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: " "})
+	assert.True(t, errors.IsValidation(err))
+	_, err = uc.Search(ctx, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "x", Limit: MaxSearchLimit + 1})
+	assert.True(t, errors.IsValidation(err))
+
+	// Documents deleted behind the index are skipped
+	require.NoError(t, store.DeleteDocument(ctx, "p", "d", "products", "before"))
+	result, err := uc.Search(ctx, SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	require.NoError(t, uc.DeleteSearchConfig(ctx, "p", "d", "products"))
+	_, err = uc.GetSearchConfig(ctx, "p", "d", "products")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestSearch_SharedAcrossInstances(t *testing.T) {
+	ctx := utils.WithOrganizationID(context.Background(), "org-a")
+	storage := newMemDocRepo()
+	configs := NewInMemorySearchConfigStore()
+	changeLog := NewInMemoryChangeLogStore()
+	instanceA := NewSearchUsecaseWithStores(storage, nil, configs, changeLog, &MockLogger{})
+	instanceB := NewSearchUsecaseWithStores(storage, nil, configs, changeLog, &MockLogger{})
+	repoA := NewIndexingRepository(storage, instanceA)
+
+	// write commits on instance A and records the change like the change feed does
+	write := func(documentID string, data map[string]interface{}) {
+		var after *model.Document
+		if data != nil {
+			doc, err := repoA.CreateDocument(ctx, "p", "d", "products", documentID, fields(data))
+			require.NoError(t, err)
+			after = doc
+		} else {
+			require.NoError(t, repoA.DeleteDocument(ctx, "p", "d", "products", documentID))
+		}
+		require.NoError(t, changeLog.Append(ctx, "p", "d", []*model.ChangeLogEntry{{
+			Change:     &model.DocumentChange{DocumentPath: "products/" + documentID, After: after},
+			RecordedAt: time.Now(),
+		}}))
+	}
+	search := SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"}
+
+	write("before", map[string]interface{}{"name": "Wool socks"})
+	_, err := instanceA.SetSearchConfig(ctx, SetSearchConfigRequest{
+		ProjectID: "p", DatabaseID: "d", CollectionID: "products",
+		Fields: []model.SearchField{{FieldPath: "name"}},
+	})
+	require.NoError(t, err)
+
+	// The configuration set on A is used by B
+	result, err := instanceB.Search(ctx, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before"}, hitIDs(result))
+
+	// Writes committed on A reach the index of B through the changelog
+	write("added", map[string]interface{}{"name": "Silk socks"})
+	write("before", map[string]interface{}{"name": "Wool hat"})
+	result, err = instanceB.Search(ctx, search)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"added"}, hitIDs(result))
+
+	// Changes pruned before B applied them make B rebuild from storage
+	write("third", map[string]interface{}{"name": "Cotton socks"})
+	_, err = changeLog.Prune(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	result, err = instanceB.Search(ctx, search)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"added", "third"}, hitIDs(result))
+
+	// A restarted instance keeps the configuration
+	restarted := NewSearchUsecaseWithStores(storage, nil, configs, changeLog, &MockLogger{})
+	config, err := restarted.GetSearchConfig(ctx, "p", "d", "products")
+	require.NoError(t, err)
+	assert.Equal(t, "name", config.Fields[0].FieldPath)
+
+	// A configuration deleted on A is no longer searchable on B
+	require.NoError(t, instanceA.DeleteSearchConfig(ctx, "p", "d", "products"))
+	_, err = instanceB.Search(ctx, search)
+	assert.True(t, errors.IsValidation(err))
+}
+
+func TestSearch_CatchUpFollowsTheOrganizationAndWaitsForSequencesInFlight(t *testing.T) {
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+	storage := newMemDocRepo()
+	configs := NewInMemorySearchConfigStore()
+	changeLog := &gapChangeLogStore{ChangeLogStore: NewInMemoryChangeLogStore()}
+	instanceA := NewSearchUsecaseWithStores(storage, nil, configs, changeLog, &MockLogger{})
+	instanceB := NewSearchUsecaseWithStores(storage, nil, configs, changeLog, &MockLogger{})
+	repoA := NewIndexingRepository(storage, instanceA)
+
+	write := func(ctx context.Context, documentID, name string) {
+		doc, err := repoA.CreateDocument(ctx, "p", "d", "products", documentID, fields(map[string]interface{}{"name": name}))
+		require.NoError(t, err)
+		require.NoError(t, changeLog.Append(ctx, "p", "d", []*model.ChangeLogEntry{{
+			Change:     &model.DocumentChange{DocumentPath: "products/" + documentID, After: doc},
+			RecordedAt: time.Now(),
+		}}))
+	}
+	for _, ctx := range []context.Context{orgA, orgB} {
+		_, err := instanceA.SetSearchConfig(ctx, SetSearchConfigRequest{
+			ProjectID: "p", DatabaseID: "d", CollectionID: "products",
+			Fields: []model.SearchField{{FieldPath: "name"}},
+		})
+		require.NoError(t, err)
+	}
+	search := SearchRequest{ProjectID: "p", DatabaseID: "d", CollectionID: "products", Query: "socks"}
+	result, err := instanceB.Search(orgA, search)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	// Changes of another organization never reach the index
+	write(orgB, "b1", "Silk socks")
+	result, err = instanceB.Search(orgA, search)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	// A young gap stops the catch-up before it, so the missing change is not skipped
+	changeLog.hidden = 1
+	write(orgA, "a1", "Wool socks")
+	write(orgA, "a2", "Cotton socks")
+	result, err = instanceB.Search(orgA, search)
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	changeLog.hidden = 0
+	result, err = instanceB.Search(orgA, search)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a1", "a2"}, hitIDs(result))
+}
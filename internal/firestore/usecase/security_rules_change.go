@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"context"
+
+	"firestore-clone/internal/shared/eventbus"
+)
+
+// rulesChangeSource is the event bus source of the security rules events
+const rulesChangeSource = "security_rules"
+
+// RulesChange is the data of the security.rules_changed event, published after the
+// active security rules of a database are replaced, so that the access granted under the
+// previous rules, such as active listeners, is checked again
+type RulesChange struct {
+	ProjectID  string `json:"projectId"`
+	DatabaseID string `json:"databaseId"`
+	// Version is the released ruleset, when the rules are versioned
+	Version string `json:"version,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// RevokedListener is the data of the security.rule_violation event published for each
+// listener closed because the rules of its database no longer allow it
+type RevokedListener struct {
+	ProjectID      string `json:"projectId"`
+	DatabaseID     string `json:"databaseId"`
+	SubscriberID   string `json:"subscriberId"`
+	SubscriptionID string `json:"subscriptionId"`
+	Path           string `json:"path"`
+	UserID         string `json:"userId"`
+	Reason         string `json:"reason"`
+}
+
+// publishRulesChange publishes a rules change on the bus, when there is one. The rules are
+// already deployed, so handlers run in the background and outlive the request.
+func publishRulesChange(ctx context.Context, bus *eventbus.EventBus, change RulesChange) {
+	if bus == nil {
+		return
+	}
+	bus.PublishAndForget(context.WithoutCancel(ctx), eventbus.NewBasicEventWithSource(eventbus.EventTypeSecurityRulesChanged, change, rulesChangeSource))
+}
+
+// PublishRevokedListener publishes the audit event of a listener closed by a rules change
+func PublishRevokedListener(ctx context.Context, bus *eventbus.EventBus, revoked RevokedListener) {
+	if bus == nil {
+		return
+	}
+	bus.PublishAndForget(context.WithoutCancel(ctx), eventbus.NewBasicEventWithSource(eventbus.EventTypeSecurityRuleViolation, revoked, rulesChangeSource))
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+	"firestore-clone/internal/shared/errors"
+)
+
+// SecurityRulesCoverageUsecase reports which lines of the released rules source the
+// evaluated requests exercised
+type SecurityRulesCoverageUsecase interface {
+	GetCoverage(ctx context.Context, projectID, databaseID string) (*rtdomain.RulesCoverageReport, error)
+	ResetCoverage(ctx context.Context, projectID, databaseID string) error
+}
+
+type securityRulesCoverageUsecase struct {
+	store      repository.RulesCoverageStore
+	deployer   rtdomain.RulesDeployer
+	parser     rtdomain.RulesParser
+	translator rtdomain.RulesTranslator
+}
+
+// NewSecurityRulesCoverageUsecase creates the rules coverage usecase
+func NewSecurityRulesCoverageUsecase(store repository.RulesCoverageStore, deployer rtdomain.RulesDeployer, parser rtdomain.RulesParser, translator rtdomain.RulesTranslator) SecurityRulesCoverageUsecase {
+	return &securityRulesCoverageUsecase{store: store, deployer: deployer, parser: parser, translator: translator}
+}
+
+func (uc *securityRulesCoverageUsecase) GetCoverage(ctx context.Context, projectID, databaseID string) (*rtdomain.RulesCoverageReport, error) {
+	release, err := uc.deployer.GetRelease(ctx, projectID, databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		return nil, errors.NewNotFoundError("rules release")
+	}
+	ruleset, err := uc.deployer.GetRuleset(ctx, projectID, databaseID, release.Version)
+	if err != nil {
+		return nil, err
+	}
+	if ruleset.Source == "" {
+		return nil, errors.NewValidationError(fmt.Sprintf("ruleset %s has no rules source to annotate", release.Version))
+	}
+
+	// The stored rules do not keep source lines, so the source is translated again
+	rules, err := translateRulesSource(ctx, uc.parser, uc.translator, ruleset.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	report := rtusecase.AnnotateRulesCoverage(ruleset.Source, rules, uc.store.Coverage(projectID, databaseID))
+	report.Version = release.Version
+	return report, nil
+}
+
+func (uc *securityRulesCoverageUsecase) ResetCoverage(ctx context.Context, projectID, databaseID string) error {
+	uc.store.Reset(projectID, databaseID)
+	return nil
+}
@@ -2,7 +2,13 @@ package usecase
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	"firestore-clone/internal/firestore/domain/repository"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/eventbus"
 )
 
 // SecurityRulesCRUDUsecase define el CRUD de reglas de seguridad al estilo Firestore
@@ -20,6 +26,8 @@ type SecurityRulesCRUDUsecase interface {
 // Debe tener métodos exportados para que los mocks funcionen correctamente en los tests
 type SecurityRulesOrchestrator interface {
 	ImportAndDeployFirestoreRules(ctx context.Context, rulesContent, projectID, databaseID string) error
+	// TranslateFirestoreRules traduce y valida reglas sin desplegarlas
+	TranslateFirestoreRules(ctx context.Context, rulesContent string) ([]*repository.SecurityRule, error)
 	Parser() interface {
 		ParseString(ctx context.Context, rulesText string) (interface{}, error)
 	}
@@ -28,13 +36,37 @@ type SecurityRulesOrchestrator interface {
 type securityRulesCRUDUsecase struct {
 	orchestrator SecurityRulesOrchestrator
 	engine       repository.SecurityRulesEngine
+	bus          *eventbus.EventBus
+	linter       rtdomain.RulesLinter
 }
 
 func NewSecurityRulesCRUDUsecase(
 	orchestrator SecurityRulesOrchestrator,
 	engine repository.SecurityRulesEngine,
 ) SecurityRulesCRUDUsecase {
-	return &securityRulesCRUDUsecase{orchestrator, engine}
+	return NewSecurityRulesCRUDUsecaseWithEvents(orchestrator, engine, nil)
+}
+
+// NewSecurityRulesCRUDUsecaseWithEvents crea el CRUD publicando security.rules_changed
+// en el bus cada vez que cambian las reglas activas
+func NewSecurityRulesCRUDUsecaseWithEvents(
+	orchestrator SecurityRulesOrchestrator,
+	engine repository.SecurityRulesEngine,
+	bus *eventbus.EventBus,
+) SecurityRulesCRUDUsecase {
+	return NewSecurityRulesCRUDUsecaseWithLinter(orchestrator, engine, bus, nil)
+}
+
+// NewSecurityRulesCRUDUsecaseWithLinter crea el CRUD revisando la seguridad de las reglas
+// antes de validarlas o guardarlas: los hallazgos de severidad error las rechazan salvo que
+// el contexto los acepte con rtdomain.WithInsecureRulesAllowed
+func NewSecurityRulesCRUDUsecaseWithLinter(
+	orchestrator SecurityRulesOrchestrator,
+	engine repository.SecurityRulesEngine,
+	bus *eventbus.EventBus,
+	linter rtdomain.RulesLinter,
+) SecurityRulesCRUDUsecase {
+	return &securityRulesCRUDUsecase{orchestrator: orchestrator, engine: engine, bus: bus, linter: linter}
 }
 
 func (uc *securityRulesCRUDUsecase) GetRules(ctx context.Context, projectID, databaseID string) (string, error) {
@@ -43,18 +75,110 @@ func (uc *securityRulesCRUDUsecase) GetRules(ctx context.Context, projectID, dat
 }
 
 func (uc *securityRulesCRUDUsecase) PutRules(ctx context.Context, projectID, databaseID, rulesText, user string) error {
-	return uc.orchestrator.ImportAndDeployFirestoreRules(ctx, rulesText, projectID, databaseID)
+	if uc.linter != nil {
+		rules, err := uc.orchestrator.TranslateFirestoreRules(ctx, rulesText)
+		if err != nil {
+			return errors.NewValidationError(err.Error())
+		}
+		if err := uc.lintRules(ctx, rules); err != nil {
+			return err
+		}
+	}
+	if err := uc.orchestrator.ImportAndDeployFirestoreRules(ctx, rulesText, projectID, databaseID); err != nil {
+		return err
+	}
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID, User: user})
+	return nil
 }
 
+// PatchRules fusiona reglas parciales con las desplegadas: cada bloque match del texto
+// parcial reemplaza al desplegado con el mismo patrón y los bloques nuevos se añaden
 func (uc *securityRulesCRUDUsecase) PatchRules(ctx context.Context, projectID, databaseID, partialText, user string) error {
-	return nil // PATCH opcional, implementar si se requiere
+	if strings.TrimSpace(partialText) == "" {
+		return errors.NewValidationError("rules source is required")
+	}
+	patch, err := uc.orchestrator.TranslateFirestoreRules(ctx, partialText)
+	if err != nil {
+		return errors.NewValidationError(err.Error())
+	}
+	current, err := uc.engine.LoadRules(ctx, projectID, databaseID)
+	if err != nil {
+		return err
+	}
+
+	merged := mergeSecurityRules(current, patch)
+	if err := uc.engine.ValidateRules(merged); err != nil {
+		return errors.NewValidationError(fmt.Sprintf("reglas fusionadas no válidas: %v", err))
+	}
+	if err := uc.lintRules(ctx, merged); err != nil {
+		return err
+	}
+	if err := uc.engine.SaveRules(ctx, projectID, databaseID, merged); err != nil {
+		return err
+	}
+	uc.engine.ClearCache(projectID, databaseID)
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID, User: user})
+	return nil
+}
+
+// mergeSecurityRules reemplaza las reglas con el mismo patrón match y añade las nuevas,
+// conservando el orden de las desplegadas
+func mergeSecurityRules(current, patch []*repository.SecurityRule) []*repository.SecurityRule {
+	patched := make(map[string]*repository.SecurityRule, len(patch))
+	for _, rule := range patch {
+		patched[rule.Match] = rule
+	}
+	merged := make([]*repository.SecurityRule, 0, len(current)+len(patch))
+	for _, rule := range current {
+		if replacement, ok := patched[rule.Match]; ok {
+			merged = append(merged, replacement)
+			delete(patched, rule.Match)
+			continue
+		}
+		merged = append(merged, rule)
+	}
+	for _, rule := range patch {
+		if _, ok := patched[rule.Match]; ok {
+			merged = append(merged, rule)
+		}
+	}
+	return merged
 }
 
 func (uc *securityRulesCRUDUsecase) DeleteRules(ctx context.Context, projectID, databaseID string) error {
-	return uc.engine.DeleteRules(ctx, projectID, databaseID)
+	if err := uc.engine.DeleteRules(ctx, projectID, databaseID); err != nil {
+		return err
+	}
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID})
+	return nil
 }
 
+// ValidateRules comprueba que las reglas se parsean y, con linter, que se traducen y no
+// tienen hallazgos de seguridad de severidad error
 func (uc *securityRulesCRUDUsecase) ValidateRules(ctx context.Context, rulesText string) error {
-	_, err := uc.orchestrator.Parser().ParseString(ctx, rulesText)
-	return err
+	if uc.linter == nil {
+		_, err := uc.orchestrator.Parser().ParseString(ctx, rulesText)
+		return err
+	}
+	rules, err := uc.orchestrator.TranslateFirestoreRules(ctx, rulesText)
+	if err != nil {
+		return errors.NewValidationError(err.Error())
+	}
+	return uc.lintRules(ctx, rules)
+}
+
+// lintRules rechaza las reglas con hallazgos de seguridad de severidad error, salvo que el
+// contexto los acepte
+func (uc *securityRulesCRUDUsecase) lintRules(ctx context.Context, rules []*repository.SecurityRule) error {
+	if uc.linter == nil || rtdomain.InsecureRulesAllowed(ctx) {
+		return nil
+	}
+	findings, err := uc.linter.Lint(ctx, rules)
+	if err != nil {
+		return fmt.Errorf("no se pudo revisar la seguridad de las reglas: %w", err)
+	}
+	if problems := rtdomain.LintErrors(findings); len(problems) > 0 {
+		return errors.NewValidationError(rtdomain.InsecureRulesError(problems).Error())
+	}
+	return nil
 }
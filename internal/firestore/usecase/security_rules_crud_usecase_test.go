@@ -4,8 +4,14 @@ import (
 	"context"
 	repository "firestore-clone/internal/firestore/domain/repository"
 	"firestore-clone/internal/firestore/usecase"
+	rtadapter "firestore-clone/internal/rules_translator/adapter"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/eventbus"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -20,15 +26,95 @@ func TestSecurityRulesCRUDUsecase_ValidateRules(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestSecurityRulesCRUDUsecase_PatchRules(t *testing.T) {
+	ctx := context.Background()
+	engine := &memoryCRUDRulesEngine{rules: []*repository.SecurityRule{
+		{Match: "/users/{userId}", Allow: map[repository.OperationType]string{repository.OperationRead: "true"}},
+		{Match: "/posts/{postId}", Allow: map[repository.OperationType]string{repository.OperationRead: "true"}},
+	}}
+	orchestrator := &MockOrchestrator{translated: []*repository.SecurityRule{
+		{Match: "/users/{userId}", Allow: map[repository.OperationType]string{repository.OperationRead: "request.auth.uid == userId"}},
+		{Match: "/notes/{noteId}", Allow: map[repository.OperationType]string{repository.OperationRead: "false"}},
+	}}
+	bus := eventbus.NewEventBus(nil)
+	changes := make(chan usecase.RulesChange, 1)
+	bus.Subscribe(eventbus.EventTypeSecurityRulesChanged, func(ctx context.Context, event eventbus.Event) error {
+		changes <- event.Data().(usecase.RulesChange)
+		return nil
+	})
+	uc := usecase.NewSecurityRulesCRUDUsecaseWithEvents(orchestrator, engine, bus)
+
+	require.NoError(t, uc.PatchRules(ctx, "p", "d", "match /users/{userId} { ... }", "alice"))
+	require.Len(t, engine.rules, 3)
+	assert.Equal(t, "request.auth.uid == userId", engine.rules[0].Allow[repository.OperationRead], "El bloque con el mismo match se reemplaza")
+	assert.Equal(t, "/posts/{postId}", engine.rules[1].Match, "Los bloques no parcheados se conservan")
+	assert.Equal(t, "/notes/{noteId}", engine.rules[2].Match, "Los bloques nuevos se añaden")
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, usecase.RulesChange{ProjectID: "p", DatabaseID: "d", User: "alice"}, change)
+	case <-time.After(time.Second):
+		t.Fatal("PatchRules no publicó security.rules_changed")
+	}
+
+	assert.Error(t, uc.PatchRules(ctx, "p", "d", " ", "alice"))
+}
+
+func TestSecurityRulesCRUDUsecase_LintsRules(t *testing.T) {
+	ctx := context.Background()
+	engine := &memoryCRUDRulesEngine{}
+	orchestrator := &MockOrchestrator{translated: []*repository.SecurityRule{
+		{Match: "/posts/{postId}", Allow: map[repository.OperationType]string{repository.OperationWrite: "true"}},
+	}}
+	uc := usecase.NewSecurityRulesCRUDUsecaseWithLinter(orchestrator, engine, nil, rtadapter.NewRulesLinter())
+
+	// Los hallazgos de severidad error rechazan las reglas al validarlas y al guardarlas
+	err := uc.ValidateRules(ctx, "allow write: if true")
+	require.Error(t, err)
+	assert.True(t, errors.IsValidation(err))
+	assert.Contains(t, err.Error(), "[public-write]")
+	assert.Error(t, uc.PutRules(ctx, "p", "d", "allow write: if true", "alice"))
+	assert.Error(t, uc.PatchRules(ctx, "p", "d", "allow write: if true", "alice"))
+	assert.Empty(t, engine.rules)
+
+	// Se aceptan si la petición lo pide explícitamente
+	allowed := rtdomain.WithInsecureRulesAllowed(ctx)
+	assert.NoError(t, uc.ValidateRules(allowed, "allow write: if true"))
+	require.NoError(t, uc.PatchRules(allowed, "p", "d", "allow write: if true", "alice"))
+	assert.Len(t, engine.rules, 1)
+}
+
+// memoryCRUDRulesEngine guarda en memoria las reglas desplegadas
+type memoryCRUDRulesEngine struct {
+	MockRulesEngine
+	rules []*repository.SecurityRule
+}
+
+func (m *memoryCRUDRulesEngine) LoadRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
+	return m.rules, nil
+}
+
+func (m *memoryCRUDRulesEngine) SaveRules(ctx context.Context, projectID, databaseID string, rules []*repository.SecurityRule) error {
+	m.rules = rules
+	return nil
+}
+
 // MockOrchestrator simula el orquestador
 // MockRulesEngine simula el motor de reglas
 
-type MockOrchestrator struct{}
+type MockOrchestrator struct {
+	// translated son las reglas devueltas por TranslateFirestoreRules
+	translated []*repository.SecurityRule
+}
 
 func (m *MockOrchestrator) ImportAndDeployFirestoreRules(ctx context.Context, rulesContent, projectID, databaseID string) error {
 	return nil
 }
 
+func (m *MockOrchestrator) TranslateFirestoreRules(ctx context.Context, rulesContent string) ([]*repository.SecurityRule, error) {
+	return m.translated, nil
+}
+
 // Implementa el método parser() correctamente según la interfaz
 func (m *MockOrchestrator) Parser() interface {
 	ParseString(ctx context.Context, rulesText string) (interface{}, error)
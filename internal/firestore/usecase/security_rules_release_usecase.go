@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	rtusecase "firestore-clone/internal/rules_translator/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/eventbus"
+)
+
+// SecurityRulesReleaseUsecase manages security rules the way Firebase Rules does: every
+// deployment creates an immutable ruleset with the rules source and its translation, and
+// the release of a database points at the active ruleset. Every change is attributed to
+// the user making it.
+type SecurityRulesReleaseUsecase interface {
+	// DeployRules parses and translates the rules source, stores it as a new ruleset and releases it
+	DeployRules(ctx context.Context, projectID, databaseID, source, user string) (*rtdomain.DeployResult, error)
+	// ListRulesets lists the metadata of the latest rulesets, oldest first, without their source and rules
+	ListRulesets(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.DeployHistory, error)
+	GetRuleset(ctx context.Context, projectID, databaseID, version string) (*rtdomain.DeployHistory, error)
+	// DiffRulesets compares the translated rules of two rulesets
+	DiffRulesets(ctx context.Context, projectID, databaseID, fromVersion, toVersion string) (*rtdomain.RulesDiff, error)
+
+	// ReleaseRuleset makes a stored ruleset the active one
+	ReleaseRuleset(ctx context.Context, projectID, databaseID, version, user string) (*rtdomain.RulesRelease, error)
+	// Rollback releases again the ruleset that was active before the current release
+	Rollback(ctx context.Context, projectID, databaseID, user string) (*rtdomain.RulesRelease, error)
+	// GetRelease returns the active release
+	GetRelease(ctx context.Context, projectID, databaseID string) (*rtdomain.RulesRelease, error)
+	// ListReleases lists the latest releases, oldest first; it is the audit log of rules changes
+	ListReleases(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.RulesRelease, error)
+}
+
+type securityRulesReleaseUsecase struct {
+	parser     rtdomain.RulesParser
+	translator rtdomain.RulesTranslator
+	deployer   rtdomain.RulesDeployer
+	bus        *eventbus.EventBus
+}
+
+// NewSecurityRulesReleaseUsecase creates the rules release usecase
+func NewSecurityRulesReleaseUsecase(parser rtdomain.RulesParser, translator rtdomain.RulesTranslator, deployer rtdomain.RulesDeployer) SecurityRulesReleaseUsecase {
+	return NewSecurityRulesReleaseUsecaseWithEvents(parser, translator, deployer, nil)
+}
+
+// NewSecurityRulesReleaseUsecaseWithEvents creates the rules release usecase publishing a
+// security.rules_changed event on the bus after every release
+func NewSecurityRulesReleaseUsecaseWithEvents(parser rtdomain.RulesParser, translator rtdomain.RulesTranslator, deployer rtdomain.RulesDeployer, bus *eventbus.EventBus) SecurityRulesReleaseUsecase {
+	return &securityRulesReleaseUsecase{parser: parser, translator: translator, deployer: deployer, bus: bus}
+}
+
+func (uc *securityRulesReleaseUsecase) DeployRules(ctx context.Context, projectID, databaseID, source, user string) (*rtdomain.DeployResult, error) {
+	if source == "" {
+		return nil, errors.NewValidationError("rules source is required")
+	}
+	parseResult, err := uc.parser.ParseString(ctx, source)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid rules: %v", err))
+	}
+	translation, err := uc.translator.Translate(ctx, parseResult.Ruleset)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("failed to translate rules: %v", err))
+	}
+	if len(translation.Errors) > 0 {
+		return nil, errors.NewValidationError(fmt.Sprintf("failed to translate rules: %v", translation.Errors))
+	}
+
+	result, err := uc.deployer.DeployRuleset(ctx, projectID, databaseID, source, translation.Rules, user)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return result, errors.NewValidationError(fmt.Sprintf("deployment failed: %v", result.Errors))
+	}
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID, Version: result.Version, User: user})
+	return result, nil
+}
+
+func (uc *securityRulesReleaseUsecase) ListRulesets(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.DeployHistory, error) {
+	rulesets, err := uc.deployer.GetDeployHistory(ctx, projectID, databaseID, limit)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]*rtdomain.DeployHistory, len(rulesets))
+	for i, ruleset := range rulesets {
+		summary := *ruleset
+		summary.Source, summary.Rules = "", nil
+		summaries[i] = &summary
+	}
+	return summaries, nil
+}
+
+func (uc *securityRulesReleaseUsecase) GetRuleset(ctx context.Context, projectID, databaseID, version string) (*rtdomain.DeployHistory, error) {
+	return uc.deployer.GetRuleset(ctx, projectID, databaseID, version)
+}
+
+func (uc *securityRulesReleaseUsecase) DiffRulesets(ctx context.Context, projectID, databaseID, fromVersion, toVersion string) (*rtdomain.RulesDiff, error) {
+	if fromVersion == "" || toVersion == "" {
+		return nil, errors.NewValidationError("both ruleset versions are required")
+	}
+	from, err := uc.deployer.GetRuleset(ctx, projectID, databaseID, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := uc.deployer.GetRuleset(ctx, projectID, databaseID, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	fromRules, _ := from.Rules.([]*repository.SecurityRule)
+	toRules, _ := to.Rules.([]*repository.SecurityRule)
+	return rtusecase.DiffRules(fromRules, toRules), nil
+}
+
+func (uc *securityRulesReleaseUsecase) ReleaseRuleset(ctx context.Context, projectID, databaseID, version, user string) (*rtdomain.RulesRelease, error) {
+	if version == "" {
+		return nil, errors.NewValidationError("ruleset version is required")
+	}
+	release, err := uc.deployer.Release(ctx, projectID, databaseID, version, user)
+	if err != nil {
+		return nil, err
+	}
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID, Version: release.Version, User: user})
+	return release, nil
+}
+
+func (uc *securityRulesReleaseUsecase) Rollback(ctx context.Context, projectID, databaseID, user string) (*rtdomain.RulesRelease, error) {
+	release, err := uc.deployer.Rollback(ctx, projectID, databaseID, user)
+	if err != nil {
+		return nil, err
+	}
+	publishRulesChange(ctx, uc.bus, RulesChange{ProjectID: projectID, DatabaseID: databaseID, Version: release.Version, User: user})
+	return release, nil
+}
+
+func (uc *securityRulesReleaseUsecase) GetRelease(ctx context.Context, projectID, databaseID string) (*rtdomain.RulesRelease, error) {
+	release, err := uc.deployer.GetRelease(ctx, projectID, databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		return nil, errors.NewNotFoundError("rules release")
+	}
+	return release, nil
+}
+
+func (uc *securityRulesReleaseUsecase) ListReleases(ctx context.Context, projectID, databaseID string, limit int) ([]*rtdomain.RulesRelease, error) {
+	return uc.deployer.GetReleaseHistory(ctx, projectID, databaseID, limit)
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/errors"
+)
+
+// SecurityRulesSimulatorUsecase is the rules playground: it evaluates a simulated
+// request against the deployed rules or candidate rules and explains the decision
+type SecurityRulesSimulatorUsecase interface {
+	Simulate(ctx context.Context, req SimulateRulesRequest) (*repository.SimulationResult, error)
+}
+
+// SimulateRulesRequest is a simulated request. Candidate rules are given either as a
+// rules source, translated before the simulation, or as translated rules.
+type SimulateRulesRequest struct {
+	repository.SimulationRequest
+	Source string `json:"source,omitempty"`
+	// RecordCoverage counts the simulated request in the rules coverage of the database,
+	// as a request evaluated by the deployed rules. Only administrators may record it.
+	RecordCoverage bool `json:"recordCoverage,omitempty"`
+	// User is the caller; only administrators may evaluate candidate rules against the
+	// stored document
+	User *authModel.User `json:"-"`
+}
+
+type securityRulesSimulatorUsecase struct {
+	simulator  repository.RulesSimulator
+	parser     rtdomain.RulesParser
+	translator rtdomain.RulesTranslator
+	coverage   repository.RulesCoverageStore
+}
+
+// NewSecurityRulesSimulatorUsecase creates the rules simulator usecase
+func NewSecurityRulesSimulatorUsecase(simulator repository.RulesSimulator, parser rtdomain.RulesParser, translator rtdomain.RulesTranslator) SecurityRulesSimulatorUsecase {
+	return NewSecurityRulesSimulatorUsecaseWithCoverage(simulator, parser, translator, nil)
+}
+
+// NewSecurityRulesSimulatorUsecaseWithCoverage creates the rules simulator usecase
+// recording the simulations which ask for it in the coverage store
+func NewSecurityRulesSimulatorUsecaseWithCoverage(simulator repository.RulesSimulator, parser rtdomain.RulesParser, translator rtdomain.RulesTranslator, coverage repository.RulesCoverageStore) SecurityRulesSimulatorUsecase {
+	return &securityRulesSimulatorUsecase{simulator: simulator, parser: parser, translator: translator, coverage: coverage}
+}
+
+func (uc *securityRulesSimulatorUsecase) Simulate(ctx context.Context, req SimulateRulesRequest) (*repository.SimulationResult, error) {
+	if req.Path == "" {
+		return nil, errors.NewValidationError("path is required")
+	}
+	if !isRulesOperation(req.Operation) {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid operation type: %s", req.Operation))
+	}
+
+	if req.RecordCoverage {
+		if uc.coverage == nil {
+			return nil, errors.NewValidationError("rules coverage is not enabled")
+		}
+		if !isAdminUser(req.User) {
+			return nil, errors.NewAuthorizationError("only administrators may record rules coverage")
+		}
+		if req.Source != "" || len(req.Rules) > 0 {
+			return nil, errors.NewValidationError("coverage is only recorded for the deployed rules")
+		}
+	}
+
+	candidate := req.Source != "" || len(req.Rules) > 0
+	if candidate && req.UseExistingDocument && req.Resource == nil && !isAdminUser(req.User) {
+		return nil, errors.NewAuthorizationError("only administrators may simulate candidate rules against the stored document")
+	}
+
+	if req.Source != "" {
+		rules, err := translateRulesSource(ctx, uc.parser, uc.translator, req.Source)
+		if err != nil {
+			return nil, err
+		}
+		req.Rules = rules
+	}
+	result, err := uc.simulator.SimulateAccess(ctx, &req.SimulationRequest)
+	if err != nil {
+		return nil, err
+	}
+	if req.RecordCoverage {
+		uc.coverage.RecordSimulation(req.ProjectID, req.DatabaseID, result)
+	}
+	if readStoredDocuments(&req.SimulationRequest, result) {
+		redactExpressionValues(result)
+	}
+	return result, nil
+}
+
+// readStoredDocuments reports whether the simulation read documents from the database
+// rather than mocked ones: the existing document or a get() or exists() not mocked
+func readStoredDocuments(req *repository.SimulationRequest, result *repository.SimulationResult) bool {
+	if req.UseExistingDocument && req.Resource == nil {
+		return true
+	}
+	for _, lookup := range result.Lookups {
+		if lookup.Found && !lookup.Mocked {
+			return true
+		}
+	}
+	return false
+}
+
+// redactExpressionValues removes from the trace the values of the sub-expressions
+// which may carry stored data. Boolean values are kept, they are what explains the
+// decision.
+func redactExpressionValues(result *repository.SimulationResult) {
+	for _, match := range result.Matches {
+		for _, condition := range match.Conditions {
+			for _, expression := range condition.Expressions {
+				if _, ok := expression.Value.(bool); ok || expression.Value == nil {
+					continue
+				}
+				expression.Value = nil
+				expression.Redacted = true
+			}
+		}
+	}
+}
+
+// translateRulesSource parses and translates a rules source; errors in the rules are
+// validation errors
+func translateRulesSource(ctx context.Context, parser rtdomain.RulesParser, translator rtdomain.RulesTranslator, source string) ([]*repository.SecurityRule, error) {
+	parseResult, err := parser.ParseString(ctx, source)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid rules: %v", err))
+	}
+	translation, err := translator.Translate(ctx, parseResult.Ruleset)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("failed to translate rules: %v", err))
+	}
+	if len(translation.Errors) > 0 {
+		return nil, errors.NewValidationError(fmt.Sprintf("failed to translate rules: %v", translation.Errors))
+	}
+	rules, ok := translation.Rules.([]*repository.SecurityRule)
+	if !ok {
+		return nil, fmt.Errorf("unexpected translated rules type %T", translation.Rules)
+	}
+	return rules, nil
+}
+
+// isRulesOperation reports whether an operation is one the rules grant
+func isRulesOperation(operation repository.OperationType) bool {
+	switch operation {
+	case repository.OperationRead, repository.OperationWrite, repository.OperationCreate,
+		repository.OperationUpdate, repository.OperationDelete, repository.OperationList:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	rtdomain "firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/shared/errors"
+)
+
+// SecurityRulesTestUsecase runs rules unit tests in memory, against a rules source or
+// the released ruleset, without touching the stored documents
+type SecurityRulesTestUsecase interface {
+	RunTests(ctx context.Context, projectID, databaseID string, req RunRulesTestsRequest) (*rtdomain.RulesTestReport, error)
+}
+
+// RunRulesTestsRequest is a test suite with the rules source it tests. Without a source
+// the suite tests the ruleset of the current release.
+type RunRulesTestsRequest struct {
+	rtdomain.RulesTestSuite
+	Source string `json:"source,omitempty"`
+}
+
+type securityRulesTestUsecase struct {
+	runner   rtdomain.RulesTestRunner
+	deployer rtdomain.RulesDeployer
+}
+
+// NewSecurityRulesTestUsecase creates the rules test usecase
+func NewSecurityRulesTestUsecase(runner rtdomain.RulesTestRunner, deployer rtdomain.RulesDeployer) SecurityRulesTestUsecase {
+	return &securityRulesTestUsecase{runner: runner, deployer: deployer}
+}
+
+func (uc *securityRulesTestUsecase) RunTests(ctx context.Context, projectID, databaseID string, req RunRulesTestsRequest) (*rtdomain.RulesTestReport, error) {
+	if len(req.Tests) == 0 {
+		return nil, errors.NewValidationError("tests are required")
+	}
+
+	source := req.Source
+	if source == "" {
+		released, err := uc.releasedSource(ctx, projectID, databaseID)
+		if err != nil {
+			return nil, err
+		}
+		source = released
+	}
+
+	report, err := uc.runner.Run(ctx, source, &req.RulesTestSuite)
+	if err != nil {
+		return nil, errors.NewValidationError(err.Error())
+	}
+	return report, nil
+}
+
+// releasedSource returns the rules source of the current release
+func (uc *securityRulesTestUsecase) releasedSource(ctx context.Context, projectID, databaseID string) (string, error) {
+	release, err := uc.deployer.GetRelease(ctx, projectID, databaseID)
+	if err != nil {
+		return "", err
+	}
+	if release == nil {
+		return "", errors.NewValidationError("source is required: no rules have been released")
+	}
+	ruleset, err := uc.deployer.GetRuleset(ctx, projectID, databaseID, release.Version)
+	if err != nil {
+		return "", err
+	}
+	if ruleset.Source == "" {
+		return "", errors.NewValidationError(fmt.Sprintf("source is required: ruleset %s has no rules source", release.Version))
+	}
+	return ruleset.Source, nil
+}
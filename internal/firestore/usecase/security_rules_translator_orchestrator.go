@@ -34,22 +34,40 @@ func NewSecurityRulesTranslatorOrchestrator(
 
 // ImportAndDeployFirestoreRules importa, valida, traduce y despliega reglas Firestore
 func (o *SecurityRulesTranslatorOrchestrator) ImportAndDeployFirestoreRules(ctx context.Context, rulesContent, projectID, databaseID string) error {
+	rulesSlice, err := o.TranslateFirestoreRules(ctx, rulesContent)
+	if err != nil {
+		return err
+	}
+
+	// Desplegar reglas en el motor de seguridad
+	if err := o.engine.SaveRules(ctx, projectID, databaseID, rulesSlice); err != nil {
+		return fmt.Errorf("error al guardar reglas en el motor de seguridad: %w", err)
+	}
+
+	// Limpiar caché para aplicar reglas nuevas
+	o.engine.ClearCache(projectID, databaseID)
+
+	return nil
+}
+
+// TranslateFirestoreRules parsea, traduce, optimiza y valida reglas Firestore sin desplegarlas
+func (o *SecurityRulesTranslatorOrchestrator) TranslateFirestoreRules(ctx context.Context, rulesContent string) ([]*repository.SecurityRule, error) {
 	// 1. Parsear reglas Firestore
 	parseResult, err := o.parser.ParseString(ctx, rulesContent)
 	if err != nil {
-		return fmt.Errorf("error al parsear reglas Firestore: %w", err)
+		return nil, fmt.Errorf("error al parsear reglas Firestore: %w", err)
 	}
 
 	// 2. Traducir AST a reglas internas
 	translationResult, err := o.translator.Translate(ctx, parseResult.Ruleset)
 	if err != nil {
-		return fmt.Errorf("error al traducir reglas Firestore: %w", err)
+		return nil, fmt.Errorf("error al traducir reglas Firestore: %w", err)
 	}
 
 	// 3. (Opcional) Optimizar reglas
-	finalRules := translationResult.Rules // Corrección: usar el campo correcto
+	finalRules := translationResult.Rules
 	if o.optimizer != nil {
-		optimized, _, err := o.optimizer.Optimize(ctx, finalRules) // Corrección: capturar los 3 valores
+		optimized, _, err := o.optimizer.Optimize(ctx, finalRules)
 		if err == nil && optimized != nil {
 			finalRules = optimized
 		}
@@ -58,19 +76,28 @@ func (o *SecurityRulesTranslatorOrchestrator) ImportAndDeployFirestoreRules(ctx
 	// 4. Validar reglas con el motor de seguridad
 	rulesSlice, ok := finalRules.([]*repository.SecurityRule)
 	if !ok {
-		return fmt.Errorf("el resultado de la traducción no es []*repository.SecurityRule, sino %T", finalRules)
+		return nil, fmt.Errorf("el resultado de la traducción no es []*repository.SecurityRule, sino %T", finalRules)
 	}
 	if err := o.engine.ValidateRules(rulesSlice); err != nil {
-		return fmt.Errorf("reglas traducidas no válidas: %w", err)
+		return nil, fmt.Errorf("reglas traducidas no válidas: %w", err)
 	}
+	return rulesSlice, nil
+}
 
-	// 5. Desplegar reglas en el motor de seguridad
-	if err := o.engine.SaveRules(ctx, projectID, databaseID, rulesSlice); err != nil {
-		return fmt.Errorf("error al guardar reglas en el motor de seguridad: %w", err)
-	}
+// Parser devuelve el parser de reglas del orquestador
+func (o *SecurityRulesTranslatorOrchestrator) Parser() interface {
+	ParseString(ctx context.Context, rulesText string) (interface{}, error)
+} {
+	return orchestratorParser{parser: o.parser}
+}
 
-	// 6. Limpiar caché para aplicar reglas nuevas
-	o.engine.ClearCache(projectID, databaseID)
+// orchestratorParser adapta rtdomain.RulesParser al parser que espera el CRUD de reglas
+type orchestratorParser struct {
+	parser rtdomain.RulesParser
+}
 
-	return nil
+func (p orchestratorParser) ParseString(ctx context.Context, rulesText string) (interface{}, error) {
+	return p.parser.ParseString(ctx, rulesText)
 }
+
+var _ SecurityRulesOrchestrator = (*SecurityRulesTranslatorOrchestrator)(nil)
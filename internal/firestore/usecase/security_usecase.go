@@ -4,7 +4,8 @@ import (
 	"context"
 	"time"
 
-	"firestore-clone/internal/auth/domain/model"
+	authModel "firestore-clone/internal/auth/domain/model"
+	"firestore-clone/internal/firestore/domain/model"
 	"firestore-clone/internal/firestore/domain/repository"
 	"firestore-clone/internal/shared/errors"
 	"firestore-clone/internal/shared/firestore"
@@ -16,19 +17,23 @@ import (
 // SecurityUsecase defines the interface for security-related operations.
 type SecurityUsecase interface {
 	// ValidateRead checks if a user can read from a specific Firestore path
-	ValidateRead(ctx context.Context, user *model.User, firestorePath string) error
+	ValidateRead(ctx context.Context, user *authModel.User, firestorePath string) error
 
 	// ValidateWrite checks if a user can write to a specific Firestore path
-	ValidateWrite(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}) error
+	ValidateWrite(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}) error
 
 	// ValidateDelete checks if a user can delete from a specific Firestore path
-	ValidateDelete(ctx context.Context, user *model.User, firestorePath string) error
+	ValidateDelete(ctx context.Context, user *authModel.User, firestorePath string) error
 
 	// ValidateCreate checks if a user can create a document at a specific Firestore path
-	ValidateCreate(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}) error
+	ValidateCreate(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}) error
 
 	// ValidateUpdate checks if a user can update a document at a specific Firestore path
-	ValidateUpdate(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}, existingData map[string]interface{}) error
+	ValidateUpdate(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}, existingData map[string]interface{}) error
+
+	// ValidateQuery checks if the constraints of a query of the collection at a specific
+	// Firestore path guarantee that every document it returns can be listed by the user
+	ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error
 }
 
 type securityUsecaseImpl struct {
@@ -45,32 +50,80 @@ func NewSecurityUsecase(rulesEngine repository.SecurityRulesEngine, log logger.L
 }
 
 // ValidateRead implements the SecurityUsecase interface.
-func (uc *securityUsecaseImpl) ValidateRead(ctx context.Context, user *model.User, firestorePath string) error {
+func (uc *securityUsecaseImpl) ValidateRead(ctx context.Context, user *authModel.User, firestorePath string) error {
 	return uc.validateOperation(ctx, user, firestorePath, repository.OperationRead, nil, nil)
 }
 
 // ValidateWrite implements the SecurityUsecase interface.
-func (uc *securityUsecaseImpl) ValidateWrite(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}) error {
+func (uc *securityUsecaseImpl) ValidateWrite(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}) error {
 	return uc.validateOperation(ctx, user, firestorePath, repository.OperationWrite, data, nil)
 }
 
 // ValidateDelete implements the SecurityUsecase interface.
-func (uc *securityUsecaseImpl) ValidateDelete(ctx context.Context, user *model.User, firestorePath string) error {
+func (uc *securityUsecaseImpl) ValidateDelete(ctx context.Context, user *authModel.User, firestorePath string) error {
 	return uc.validateOperation(ctx, user, firestorePath, repository.OperationDelete, nil, nil)
 }
 
 // ValidateCreate implements the SecurityUsecase interface.
-func (uc *securityUsecaseImpl) ValidateCreate(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}) error {
+func (uc *securityUsecaseImpl) ValidateCreate(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}) error {
 	return uc.validateOperation(ctx, user, firestorePath, repository.OperationCreate, data, nil)
 }
 
 // ValidateUpdate implements the SecurityUsecase interface.
-func (uc *securityUsecaseImpl) ValidateUpdate(ctx context.Context, user *model.User, firestorePath string, data map[string]interface{}, existingData map[string]interface{}) error {
+func (uc *securityUsecaseImpl) ValidateUpdate(ctx context.Context, user *authModel.User, firestorePath string, data map[string]interface{}, existingData map[string]interface{}) error {
 	return uc.validateOperation(ctx, user, firestorePath, repository.OperationUpdate, data, existingData)
 }
 
+// ValidateQuery implements the SecurityUsecase interface. Engines which cannot check
+// query constraints evaluate the list rules for the collection path instead.
+func (uc *securityUsecaseImpl) ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error {
+	queryEvaluator, ok := uc.rulesEngine.(repository.QueryRulesEvaluator)
+	if !ok {
+		return uc.validateOperation(ctx, user, collectionPath, repository.OperationList, nil, nil)
+	}
+
+	pathInfo, err := firestore.ParseFirestorePath(collectionPath)
+	if err != nil {
+		uc.log.Error("Invalid Firestore path",
+			zap.String("path", collectionPath),
+			zap.Error(err))
+		return errors.NewValidationError("invalid firestore path")
+	}
+
+	securityContext := &repository.SecurityContext{
+		User:       user,
+		ProjectID:  pathInfo.ProjectID,
+		DatabaseID: pathInfo.DatabaseID,
+		Timestamp:  time.Now().Unix(),
+		Path:       pathInfo.DocumentPath,
+	}
+	result, err := queryEvaluator.EvaluateQueryAccess(ctx, securityContext, query)
+	if err != nil {
+		uc.log.Error("Error evaluating security rules for query",
+			zap.String("path", collectionPath),
+			zap.String("userID", getUserID(user)),
+			zap.Error(err))
+		return errors.NewInternalError("security rules evaluation failed")
+	}
+	if !result.Allowed {
+		uc.log.Warn("Query denied by security rules",
+			zap.String("path", collectionPath),
+			zap.String("userID", getUserID(user)),
+			zap.String("reason", result.Reason),
+			zap.String("deniedBy", result.DeniedBy))
+		// Unlike document reads, the reason tells the client which constraint is missing
+		return errors.NewAuthorizationError("query denied by security rules: " + result.Reason)
+	}
+
+	uc.log.Debug("Query allowed by security rules",
+		zap.String("path", collectionPath),
+		zap.String("userID", getUserID(user)),
+		zap.String("allowedBy", result.AllowedBy))
+	return nil
+}
+
 // validateOperation is a common method for validating operations
-func (uc *securityUsecaseImpl) validateOperation(ctx context.Context, user *model.User, firestorePath string, operation repository.OperationType, requestData map[string]interface{}, existingData map[string]interface{}) error {
+func (uc *securityUsecaseImpl) validateOperation(ctx context.Context, user *authModel.User, firestorePath string, operation repository.OperationType, requestData map[string]interface{}, existingData map[string]interface{}) error {
 	// Parse Firestore path
 	pathInfo, err := firestore.ParseFirestorePath(firestorePath)
 	if err != nil {
@@ -122,7 +175,7 @@ func (uc *securityUsecaseImpl) validateOperation(ctx context.Context, user *mode
 }
 
 // getUserID safely extracts user ID from user object
-func getUserID(user *model.User) string {
+func getUserID(user *authModel.User) string {
 	if user == nil {
 		return "anonymous"
 	}
@@ -510,6 +510,10 @@ func (m *MockSecurityUsecase) ValidateDelete(ctx context.Context, user *authMode
 	return m.ValidateRead(ctx, user, path)
 }
 
+func (m *MockSecurityUsecase) ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error {
+	return m.ValidateRead(ctx, user, collectionPath)
+}
+
 // MockAuthClient implements AuthClient for testing with configurable user
 type MockAuthClient struct {
 	user *authModel.User
@@ -0,0 +1,584 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/client"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/google/uuid"
+)
+
+// triggerDefinitionsTTL is how long the triggers of a database are cached before they are
+// read again from the store, so that triggers created on another instance fire
+const triggerDefinitionsTTL = 30 * time.Second
+
+// TriggerUsecase defines the primary port for document triggers delivered to HTTP webhooks.
+// Matching writes are queued as CloudEvents and delivered by a background worker with
+// retries and exponential backoff; deliveries that keep failing are dead-lettered.
+type TriggerUsecase interface {
+	CreateTrigger(ctx context.Context, req CreateTriggerRequest) (*model.DocumentTrigger, error)
+	GetTrigger(ctx context.Context, projectID, databaseID, triggerID string) (*model.DocumentTrigger, error)
+	ListTriggers(ctx context.Context, projectID, databaseID string) ([]*model.DocumentTrigger, error)
+	DeleteTrigger(ctx context.Context, projectID, databaseID, triggerID string) error
+
+	// ListDeliveries lists the deliveries of a database in a state, newest first.
+	// Dead-lettered deliveries are the failed deliveries kept for inspection.
+	ListDeliveries(ctx context.Context, projectID, databaseID string, state model.TriggerDeliveryState, limit int) ([]*model.TriggerDelivery, error)
+	// RetryDelivery queues a dead-lettered delivery again with a fresh attempt budget
+	RetryDelivery(ctx context.Context, projectID, databaseID, deliveryID string) (*model.TriggerDelivery, error)
+
+	// DeliverDue attempts the queued deliveries that are due and returns how many were attempted
+	DeliverDue(ctx context.Context) (int, error)
+	// Start runs the delivery worker until Stop is called
+	Start(ctx context.Context)
+	Stop()
+
+	// Write notifications, called by the document change repository
+	DocumentChangeHandler
+}
+
+// DocumentTriggerStore defines the secondary port for trigger persistence. Triggers are
+// read from the organization in the context: projects of different organizations can share
+// an ID, and a write must never fire the triggers of another organization.
+type DocumentTriggerStore interface {
+	SaveTrigger(ctx context.Context, trigger *model.DocumentTrigger) error
+	GetTrigger(ctx context.Context, projectID, databaseID, triggerID string) (*model.DocumentTrigger, error)
+	ListTriggers(ctx context.Context, projectID, databaseID string) ([]*model.DocumentTrigger, error)
+	DeleteTrigger(ctx context.Context, projectID, databaseID, triggerID string) error
+}
+
+// TriggerDeliveryQueue defines the secondary port for the persistent delivery queue.
+// Deliveries are listed and read from the organization in the context, while the delivery
+// worker claims the due deliveries of every organization.
+type TriggerDeliveryQueue interface {
+	Enqueue(ctx context.Context, deliveries []*model.TriggerDelivery) error
+	// ClaimDue leases up to limit pending deliveries due at now by moving their next attempt
+	// to now+lease, so that other workers skip them while they are being sent
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.TriggerDelivery, error)
+	SaveDelivery(ctx context.Context, delivery *model.TriggerDelivery) error
+	GetDelivery(ctx context.Context, projectID, databaseID, deliveryID string) (*model.TriggerDelivery, error)
+	ListDeliveries(ctx context.Context, projectID, databaseID string, state model.TriggerDeliveryState, limit int) ([]*model.TriggerDelivery, error)
+}
+
+// TriggerDeliveryConfig holds the retry policy and the pacing of the delivery worker
+type TriggerDeliveryConfig struct {
+	MaxAttempts    int           // Attempts before a delivery is dead-lettered
+	InitialBackoff time.Duration // Delay before the first retry, doubled on every retry
+	MaxBackoff     time.Duration
+	PollInterval   time.Duration // How often the worker looks for due deliveries
+	BatchSize      int           // Deliveries claimed per poll
+	Lease          time.Duration // How long a claimed delivery is hidden from other workers
+}
+
+// DefaultTriggerDeliveryConfig returns the default delivery policy
+func DefaultTriggerDeliveryConfig() TriggerDeliveryConfig {
+	return TriggerDeliveryConfig{
+		MaxAttempts:    8,
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Minute,
+		PollInterval:   time.Second,
+		BatchSize:      50,
+		Lease:          2 * time.Minute,
+	}
+}
+
+// withDefaults fills the unset fields of the config from the default policy
+func (c TriggerDeliveryConfig) withDefaults() TriggerDeliveryConfig {
+	defaults := DefaultTriggerDeliveryConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaults.InitialBackoff
+	}
+	if c.MaxBackoff < c.InitialBackoff {
+		c.MaxBackoff = c.InitialBackoff
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaults.PollInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaults.Lease
+	}
+	return c
+}
+
+// backoff returns the delay before the retry following the given number of attempts
+func (c TriggerDeliveryConfig) backoff(attempts int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempts && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// InMemoryDocumentTriggerStore implements DocumentTriggerStore with in-memory storage
+type InMemoryDocumentTriggerStore struct {
+	triggers map[string]*model.DocumentTrigger // organizationID/projectID/databaseID/triggerID -> trigger
+	mu       sync.RWMutex
+}
+
+// NewInMemoryDocumentTriggerStore creates a new in-memory trigger store
+func NewInMemoryDocumentTriggerStore() DocumentTriggerStore {
+	return &InMemoryDocumentTriggerStore{
+		triggers: make(map[string]*model.DocumentTrigger),
+	}
+}
+
+func triggerStoreKey(organizationID, projectID, databaseID, id string) string {
+	return organizationID + "/" + projectID + "/" + databaseID + "/" + id
+}
+
+// SaveTrigger stores a copy of the trigger
+func (s *InMemoryDocumentTriggerStore) SaveTrigger(ctx context.Context, trigger *model.DocumentTrigger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *trigger
+	s.triggers[triggerStoreKey(trigger.OrganizationID, trigger.ProjectID, trigger.DatabaseID, trigger.TriggerID)] = &stored
+	return nil
+}
+
+// GetTrigger returns a copy of the stored trigger
+func (s *InMemoryDocumentTriggerStore) GetTrigger(ctx context.Context, projectID, databaseID, triggerID string) (*model.DocumentTrigger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trigger, exists := s.triggers[triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, triggerID)]
+	if !exists {
+		return nil, errors.NewNotFoundError("document trigger")
+	}
+	result := *trigger
+	return &result, nil
+}
+
+// ListTriggers returns copies of all triggers of a database ordered by creation
+func (s *InMemoryDocumentTriggerStore) ListTriggers(ctx context.Context, projectID, databaseID string) ([]*model.DocumentTrigger, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, "")
+	result := make([]*model.DocumentTrigger, 0)
+	for key, trigger := range s.triggers {
+		if strings.HasPrefix(key, prefix) {
+			copied := *trigger
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// DeleteTrigger removes a stored trigger
+func (s *InMemoryDocumentTriggerStore) DeleteTrigger(ctx context.Context, projectID, databaseID, triggerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, triggerID)
+	if _, exists := s.triggers[key]; !exists {
+		return errors.NewNotFoundError("document trigger")
+	}
+	delete(s.triggers, key)
+	return nil
+}
+
+// InMemoryTriggerDeliveryQueue implements TriggerDeliveryQueue with in-memory storage.
+// Deliveries are lost on restart; production deployments use a persistent queue.
+type InMemoryTriggerDeliveryQueue struct {
+	deliveries map[string]*model.TriggerDelivery // organizationID/projectID/databaseID/deliveryID -> delivery
+	mu         sync.Mutex
+}
+
+// NewInMemoryTriggerDeliveryQueue creates a new in-memory delivery queue
+func NewInMemoryTriggerDeliveryQueue() TriggerDeliveryQueue {
+	return &InMemoryTriggerDeliveryQueue{
+		deliveries: make(map[string]*model.TriggerDelivery),
+	}
+}
+
+// Enqueue stores copies of the deliveries
+func (q *InMemoryTriggerDeliveryQueue) Enqueue(ctx context.Context, deliveries []*model.TriggerDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		stored := *delivery
+		q.deliveries[triggerStoreKey(delivery.OrganizationID, delivery.ProjectID, delivery.DatabaseID, delivery.DeliveryID)] = &stored
+	}
+	return nil
+}
+
+// ClaimDue leases the oldest pending deliveries due at now
+func (q *InMemoryTriggerDeliveryQueue) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.TriggerDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	due := make([]*model.TriggerDelivery, 0)
+	for _, delivery := range q.deliveries {
+		if delivery.State == model.TriggerDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*model.TriggerDelivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = now.Add(lease)
+		copied := *delivery
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+// SaveDelivery stores a copy of the delivery
+func (q *InMemoryTriggerDeliveryQueue) SaveDelivery(ctx context.Context, delivery *model.TriggerDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stored := *delivery
+	q.deliveries[triggerStoreKey(delivery.OrganizationID, delivery.ProjectID, delivery.DatabaseID, delivery.DeliveryID)] = &stored
+	return nil
+}
+
+// GetDelivery returns a copy of the stored delivery
+func (q *InMemoryTriggerDeliveryQueue) GetDelivery(ctx context.Context, projectID, databaseID, deliveryID string) (*model.TriggerDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delivery, exists := q.deliveries[triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, deliveryID)]
+	if !exists {
+		return nil, errors.NewNotFoundError("trigger delivery")
+	}
+	result := *delivery
+	return &result, nil
+}
+
+// ListDeliveries returns copies of the deliveries of a database in a state, newest first
+func (q *InMemoryTriggerDeliveryQueue) ListDeliveries(ctx context.Context, projectID, databaseID string, state model.TriggerDeliveryState, limit int) ([]*model.TriggerDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	prefix := triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, "")
+	result := make([]*model.TriggerDelivery, 0)
+	for key, delivery := range q.deliveries {
+		if strings.HasPrefix(key, prefix) && (state == "" || delivery.State == state) {
+			copied := *delivery
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+type triggerUsecase struct {
+	store  DocumentTriggerStore
+	queue  TriggerDeliveryQueue
+	sender client.WebhookSender
+	config TriggerDeliveryConfig
+	logger logger.Logger
+
+	mu       sync.RWMutex
+	triggers map[string]*cachedTriggers // organizationID/projectID/databaseID -> triggers
+
+	worker *pollWorker
+}
+
+// cachedTriggers caches the triggers of a database
+type cachedTriggers struct {
+	triggers []*model.DocumentTrigger
+	loadedAt time.Time
+}
+
+// NewTriggerUsecase creates a new document trigger usecase
+func NewTriggerUsecase(store DocumentTriggerStore, queue TriggerDeliveryQueue, sender client.WebhookSender, config TriggerDeliveryConfig, log logger.Logger) TriggerUsecase {
+	if store == nil {
+		store = NewInMemoryDocumentTriggerStore()
+	}
+	if queue == nil {
+		queue = NewInMemoryTriggerDeliveryQueue()
+	}
+	uc := &triggerUsecase{
+		store:    store,
+		queue:    queue,
+		sender:   sender,
+		config:   config.withDefaults(),
+		logger:   log,
+		triggers: make(map[string]*cachedTriggers),
+	}
+	uc.worker = newPollWorker("Document trigger delivery worker", uc.config.PollInterval, uc.config.BatchSize, uc.DeliverDue, log)
+	return uc
+}
+
+// CreateTrigger implements TriggerUsecase
+func (uc *triggerUsecase) CreateTrigger(ctx context.Context, req CreateTriggerRequest) (*model.DocumentTrigger, error) {
+	if req.ProjectID == "" || req.DatabaseID == "" {
+		return nil, errors.NewValidationError("project ID and database ID are required")
+	}
+	now := time.Now()
+	trigger := &model.DocumentTrigger{
+		TriggerID:       uuid.New().String(),
+		OrganizationID:  utils.GetOrganizationIDOrDefault(ctx, ""),
+		ProjectID:       req.ProjectID,
+		DatabaseID:      req.DatabaseID,
+		EventType:       req.EventType,
+		DocumentPattern: strings.Trim(req.DocumentPattern, "/"),
+		URL:             req.URL,
+		Headers:         req.Headers,
+		Description:     req.Description,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := trigger.Validate(); err != nil {
+		return nil, errors.NewValidationError(err.Error()).WithCause(err)
+	}
+	if err := uc.sender.ValidateTarget(ctx, trigger.URL); err != nil {
+		return nil, errors.NewValidationError(err.Error()).WithCause(err)
+	}
+	if err := uc.store.SaveTrigger(ctx, trigger); err != nil {
+		return nil, err
+	}
+	uc.invalidate(ctx, req.ProjectID, req.DatabaseID)
+	uc.logger.Info("Document trigger created",
+		"projectID", trigger.ProjectID,
+		"databaseID", trigger.DatabaseID,
+		"eventType", trigger.EventType,
+		"document", trigger.DocumentPattern)
+	return trigger, nil
+}
+
+// GetTrigger implements TriggerUsecase
+func (uc *triggerUsecase) GetTrigger(ctx context.Context, projectID, databaseID, triggerID string) (*model.DocumentTrigger, error) {
+	return uc.store.GetTrigger(ctx, projectID, databaseID, triggerID)
+}
+
+// ListTriggers implements TriggerUsecase
+func (uc *triggerUsecase) ListTriggers(ctx context.Context, projectID, databaseID string) ([]*model.DocumentTrigger, error) {
+	return uc.store.ListTriggers(ctx, projectID, databaseID)
+}
+
+// DeleteTrigger implements TriggerUsecase. Deliveries already queued are still attempted.
+func (uc *triggerUsecase) DeleteTrigger(ctx context.Context, projectID, databaseID, triggerID string) error {
+	if err := uc.store.DeleteTrigger(ctx, projectID, databaseID, triggerID); err != nil {
+		return err
+	}
+	uc.invalidate(ctx, projectID, databaseID)
+	return nil
+}
+
+// ListDeliveries implements TriggerUsecase
+func (uc *triggerUsecase) ListDeliveries(ctx context.Context, projectID, databaseID string, state model.TriggerDeliveryState, limit int) ([]*model.TriggerDelivery, error) {
+	switch state {
+	case "", model.TriggerDeliveryPending, model.TriggerDeliveryDelivered, model.TriggerDeliveryDeadLetter:
+	default:
+		return nil, errors.NewValidationError(fmt.Sprintf("unknown delivery state %q", state))
+	}
+	return uc.queue.ListDeliveries(ctx, projectID, databaseID, state, limit)
+}
+
+// RetryDelivery implements TriggerUsecase
+func (uc *triggerUsecase) RetryDelivery(ctx context.Context, projectID, databaseID, deliveryID string) (*model.TriggerDelivery, error) {
+	delivery, err := uc.queue.GetDelivery(ctx, projectID, databaseID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.State != model.TriggerDeliveryDeadLetter {
+		return nil, errors.NewConflictError("only dead-lettered deliveries can be retried")
+	}
+	now := time.Now()
+	delivery.State = model.TriggerDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := uc.queue.SaveDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	uc.worker.signal()
+	return delivery, nil
+}
+
+// WatchesChanges implements DocumentChangeHandler
+func (uc *triggerUsecase) WatchesChanges(ctx context.Context, projectID, databaseID string) bool {
+	return len(uc.loadTriggers(ctx, projectID, databaseID)) > 0
+}
+
+// HandleChanges implements DocumentChangeHandler by queueing one delivery per matching trigger
+func (uc *triggerUsecase) HandleChanges(ctx context.Context, changes []*model.DocumentChange) {
+	if err := uc.RecordChanges(ctx, changes); err != nil {
+		uc.logger.Error("Failed to queue trigger deliveries", "changes", len(changes), "error", err)
+		return
+	}
+	uc.worker.signal()
+}
+
+// RecordChanges implements DocumentChangeRecorder by queueing one delivery per matching
+// trigger of the organization that made the write
+func (uc *triggerUsecase) RecordChanges(ctx context.Context, changes []*model.DocumentChange) error {
+	now := time.Now()
+	var deliveries []*model.TriggerDelivery
+	for _, change := range changes {
+		for _, trigger := range uc.loadTriggers(ctx, change.ProjectID, change.DatabaseID) {
+			params, ok := trigger.Match(change)
+			if !ok {
+				continue
+			}
+			deliveryID := uuid.New().String()
+			event, err := json.Marshal(model.NewDocumentCloudEvent(deliveryID, trigger.EventType, change, params))
+			if err != nil {
+				uc.logger.Error("Failed to encode trigger event", "trigger", trigger.TriggerID, "document", change.DocumentPath, "error", err)
+				continue
+			}
+			deliveries = append(deliveries, &model.TriggerDelivery{
+				DeliveryID:     deliveryID,
+				TriggerID:      trigger.TriggerID,
+				OrganizationID: trigger.OrganizationID,
+				ProjectID:      trigger.ProjectID,
+				DatabaseID:     trigger.DatabaseID,
+				URL:            trigger.URL,
+				Headers:        trigger.Headers,
+				Event:          event,
+				State:          model.TriggerDeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return uc.queue.Enqueue(ctx, deliveries)
+}
+
+// ChangesRecorded implements DocumentChangeRecorder
+func (uc *triggerUsecase) ChangesRecorded(ctx context.Context) {
+	uc.worker.signal()
+}
+
+// DeliverDue implements TriggerUsecase. Claimed deliveries are sent concurrently.
+func (uc *triggerUsecase) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := uc.queue.ClaimDue(ctx, time.Now(), uc.config.BatchSize, uc.config.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim trigger deliveries: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *model.TriggerDelivery) {
+			defer wg.Done()
+			uc.attempt(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// attempt sends a delivery once and records the outcome
+func (uc *triggerUsecase) attempt(ctx context.Context, delivery *model.TriggerDelivery) {
+	status, err := uc.sender.Send(ctx, delivery.URL, delivery.Headers, delivery.Event)
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	delivery.UpdatedAt = now
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		delivery.State = model.TriggerDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	default:
+		if err != nil {
+			delivery.LastError = err.Error()
+		} else {
+			delivery.LastError = fmt.Sprintf("webhook responded with status %d", status)
+		}
+		if delivery.Attempts >= uc.config.MaxAttempts || isPermanentWebhookFailure(status) || stderrors.Is(err, client.ErrWebhookTargetForbidden) {
+			delivery.State = model.TriggerDeliveryDeadLetter
+			uc.logger.Warn("Trigger delivery dead-lettered",
+				"delivery", delivery.DeliveryID,
+				"trigger", delivery.TriggerID,
+				"attempts", delivery.Attempts,
+				"error", delivery.LastError)
+		} else {
+			delivery.NextAttemptAt = now.Add(uc.config.backoff(delivery.Attempts))
+		}
+	}
+	if err := uc.queue.SaveDelivery(ctx, delivery); err != nil {
+		// The lease expires and the delivery is attempted again
+		uc.logger.Error("Failed to record trigger delivery attempt", "delivery", delivery.DeliveryID, "error", err)
+	}
+}
+
+// isPermanentWebhookFailure reports client errors that a retry cannot fix. Timeouts and
+// rate limiting are retried.
+func isPermanentWebhookFailure(status int) bool {
+	return status >= 400 && status < 500 && status != 408 && status != 429
+}
+
+// Start implements TriggerUsecase
+func (uc *triggerUsecase) Start(ctx context.Context) {
+	uc.worker.Start(ctx)
+}
+
+// Stop implements TriggerUsecase and waits for the attempts in progress
+func (uc *triggerUsecase) Stop() {
+	uc.worker.Stop()
+}
+
+func (uc *triggerUsecase) invalidate(ctx context.Context, projectID, databaseID string) {
+	uc.mu.Lock()
+	delete(uc.triggers, triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, ""))
+	uc.mu.Unlock()
+}
+
+// loadTriggers returns the triggers of a database of the organization in the context,
+// reading them from the store when the cache has expired
+func (uc *triggerUsecase) loadTriggers(ctx context.Context, projectID, databaseID string) []*model.DocumentTrigger {
+	key := triggerStoreKey(utils.GetOrganizationIDOrDefault(ctx, ""), projectID, databaseID, "")
+	uc.mu.RLock()
+	cached := uc.triggers[key]
+	uc.mu.RUnlock()
+	if cached != nil && time.Since(cached.loadedAt) < triggerDefinitionsTTL {
+		return cached.triggers
+	}
+
+	triggers, err := uc.store.ListTriggers(ctx, projectID, databaseID)
+	if err != nil {
+		uc.logger.Warn("Failed to read document triggers", "projectID", projectID, "databaseID", databaseID, "error", err)
+		if cached != nil {
+			return cached.triggers
+		}
+		return nil
+	}
+	uc.mu.Lock()
+	uc.triggers[key] = &cachedTriggers{triggers: triggers, loadedAt: time.Now()}
+	uc.mu.Unlock()
+	return triggers
+}
@@ -0,0 +1,351 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/adapter/webhook"
+	"firestore-clone/internal/firestore/domain/client"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/errors"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookStandIn is a local HTTP endpoint answering with the queued status codes, then 200
+type webhookStandIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	events   []model.CloudEvent
+	headers  []http.Header
+}
+
+func newWebhookStandIn(t *testing.T, statuses ...int) *webhookStandIn {
+	w := &webhookStandIn{statuses: statuses}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event model.CloudEvent
+		require.NoError(t, json.Unmarshal(body, &event))
+
+		w.mu.Lock()
+		w.events = append(w.events, event)
+		w.headers = append(w.headers, r.Header.Clone())
+		status := http.StatusOK
+		if len(w.statuses) > 0 {
+			status, w.statuses = w.statuses[0], w.statuses[1:]
+		}
+		w.mu.Unlock()
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *webhookStandIn) received() []model.CloudEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]model.CloudEvent(nil), w.events...)
+}
+
+func newTriggerTestSetup(t *testing.T, config TriggerDeliveryConfig) (TriggerUsecase, repository.FirestoreRepository) {
+	// The stand-in endpoints listen on the loopback interface
+	uc := NewTriggerUsecase(nil, nil, webhook.NewHTTPSenderWithConfig(webhook.HTTPSenderConfig{AllowPrivateNetworks: true}), config, &MockLogger{})
+	return uc, NewDocumentChangeRepository(newMemDocRepo(), uc)
+}
+
+func TestTriggers_DeliverCloudEventsForMatchingWrites(t *testing.T) {
+	ctx := context.Background()
+	endpoint := newWebhookStandIn(t)
+	uc, repo := newTriggerTestSetup(t, DefaultTriggerDeliveryConfig())
+
+	_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d",
+		EventType:       model.TriggerOnWritten,
+		DocumentPattern: "users/{uid}/orders/{oid}",
+		URL:             endpoint.URL,
+		Headers:         map[string]string{"Authorization": "Bearer secret"},
+	})
+	require.NoError(t, err)
+
+	_, err = repo.CreateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "placed"}))
+	require.NoError(t, err)
+	_, err = repo.UpdateDocument(ctx, "p", "d", "users/u1/orders", "o1", fields(map[string]interface{}{"status": "shipped"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "users/u1/orders", "o1"))
+	// Neither a document outside the pattern nor another database fires the trigger
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "other", "users/u1/orders", "o2", fields(map[string]interface{}{"total": int64(1)}))
+	require.NoError(t, err)
+
+	attempted, err := uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempted)
+
+	events := endpoint.received()
+	require.Len(t, events, 3)
+	byOrder := map[string]model.CloudEvent{}
+	for _, event := range events {
+		assert.Equal(t, "google.cloud.firestore.document.v1.written", event.Type)
+		assert.Equal(t, "documents/users/u1/orders/o1", event.Subject)
+		assert.Equal(t, map[string]string{"uid": "u1", "oid": "o1"}, event.Params)
+		switch {
+		case event.Data.OldValue == nil:
+			byOrder["created"] = event
+		case event.Data.Value == nil:
+			byOrder["deleted"] = event
+		default:
+			byOrder["updated"] = event
+		}
+	}
+	require.Len(t, byOrder, 3)
+	assert.Equal(t, "placed", byOrder["updated"].Data.OldValue.Fields["status"].Value)
+	assert.Equal(t, "shipped", byOrder["updated"].Data.Value.Fields["status"].Value)
+	assert.Equal(t, []string{"status"}, byOrder["updated"].Data.UpdateMask.FieldPaths)
+	assert.Equal(t, "shipped", byOrder["deleted"].Data.OldValue.Fields["status"].Value)
+	assert.Equal(t, "Bearer secret", endpoint.headers[0].Get("Authorization"))
+	assert.Contains(t, endpoint.headers[0].Get("Content-Type"), "application/cloudevents+json")
+
+	delivered, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryDelivered, 0)
+	require.NoError(t, err)
+	assert.Len(t, delivered, 3)
+}
+
+func TestTriggers_OnlyFireForTheOrganizationThatWrote(t *testing.T) {
+	endpointA := newWebhookStandIn(t)
+	endpointB := newWebhookStandIn(t)
+	uc, repo := newTriggerTestSetup(t, DefaultTriggerDeliveryConfig())
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+
+	// Both organizations have a project "p" with a trigger on the same documents
+	for _, target := range []struct {
+		ctx context.Context
+		url string
+	}{{orgA, endpointA.URL}, {orgB, endpointB.URL}} {
+		_, err := uc.CreateTrigger(target.ctx, CreateTriggerRequest{
+			ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: target.url,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := repo.CreateDocument(orgA, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	attempted, err := uc.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+	assert.Len(t, endpointA.received(), 1)
+	assert.Empty(t, endpointB.received())
+
+	// Triggers and deliveries of the other organization are not visible
+	triggers, err := uc.ListTriggers(orgB, "p", "d")
+	require.NoError(t, err)
+	require.Len(t, triggers, 1)
+	assert.Equal(t, endpointB.URL, triggers[0].URL)
+	deliveries, err := uc.ListDeliveries(orgB, "p", "d", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+	deliveries, err = uc.ListDeliveries(orgA, "p", "d", "", 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	_, err = uc.RetryDelivery(orgB, "p", "d", deliveries[0].DeliveryID)
+	assert.True(t, errors.IsNotFound(err))
+	assert.True(t, errors.IsNotFound(uc.DeleteTrigger(orgA, "p", "d", triggers[0].TriggerID)))
+}
+
+func TestTriggers_RetryWithBackoffThenDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	endpoint := newWebhookStandIn(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusBadGateway)
+	uc, repo := newTriggerTestSetup(t, TriggerDeliveryConfig{MaxAttempts: 2, InitialBackoff: 20 * time.Millisecond, MaxBackoff: time.Second})
+
+	_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: endpoint.URL,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	// The first failure schedules a retry after the backoff
+	attempted, err := uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+	pending, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, pending[0].LastStatusCode)
+	attempted, err = uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, attempted, "the retry is not due before the backoff")
+
+	// The last allowed attempt fails and the delivery is dead-lettered
+	time.Sleep(30 * time.Millisecond)
+	_, err = uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	dead, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryDeadLetter, 0)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Contains(t, dead[0].LastError, "500")
+
+	// A retry from the admin view gets a fresh attempt budget
+	_, err = uc.RetryDelivery(ctx, "p", "d", dead[0].DeliveryID)
+	require.NoError(t, err)
+	_, err = uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	pending, err = uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryPending, 0)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "502 is retried")
+	time.Sleep(30 * time.Millisecond)
+	_, err = uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	delivered, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryDelivered, 0)
+	require.NoError(t, err)
+	assert.Len(t, delivered, 1)
+	assert.Len(t, endpoint.received(), 4)
+
+	_, err = uc.RetryDelivery(ctx, "p", "d", delivered[0].DeliveryID)
+	assert.Error(t, err, "delivered deliveries cannot be retried")
+}
+
+func TestTriggers_ClientErrorsAreDeadLetteredImmediately(t *testing.T) {
+	ctx := context.Background()
+	endpoint := newWebhookStandIn(t, http.StatusNotFound)
+	uc, repo := newTriggerTestSetup(t, DefaultTriggerDeliveryConfig())
+
+	_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnDelete, DocumentPattern: "users/{uid}", URL: endpoint.URL,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "users", "u1"))
+
+	attempted, err := uc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted, "only the delete fires an onDelete trigger")
+	dead, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryDeadLetter, 0)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, 1, dead[0].Attempts)
+}
+
+func TestTriggers_WorkerDeliversInTheBackground(t *testing.T) {
+	ctx := context.Background()
+	endpoint := newWebhookStandIn(t)
+	uc, repo := newTriggerTestSetup(t, TriggerDeliveryConfig{PollInterval: time.Hour})
+	uc.Start(ctx)
+	defer uc.Stop()
+
+	_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: endpoint.URL,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+
+	// New deliveries wake the worker up without waiting for the poll interval
+	assert.Eventually(t, func() bool { return len(endpoint.received()) == 1 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestTriggers_CreateValidatesTheTrigger(t *testing.T) {
+	uc, _ := newTriggerTestSetup(t, DefaultTriggerDeliveryConfig())
+	_, err := uc.CreateTrigger(context.Background(), CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users", URL: "https://hooks.example.com",
+	})
+	assert.Error(t, err)
+
+	_, err = uc.ListDeliveries(context.Background(), "p", "d", "LOST", 0)
+	assert.Error(t, err)
+}
+
+func TestTriggers_RejectInternalWebhookTargets(t *testing.T) {
+	ctx := context.Background()
+	uc := NewTriggerUsecase(nil, nil, webhook.NewHTTPSender(nil), DefaultTriggerDeliveryConfig(), &MockLogger{})
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.7/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+			ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: target,
+		})
+		assert.True(t, errors.IsValidation(err), target)
+		assert.ErrorIs(t, err, client.ErrWebhookTargetForbidden, target)
+	}
+
+	// Addresses are checked again when connecting, whatever the host resolved to at creation
+	endpoint := newWebhookStandIn(t)
+	_, err := webhook.NewHTTPSender(nil).Send(ctx, endpoint.URL, nil, []byte(`{}`))
+	assert.ErrorIs(t, err, client.ErrWebhookTargetForbidden)
+	assert.Empty(t, endpoint.received())
+}
+
+// failingDeliveryQueue cannot queue deliveries
+type failingDeliveryQueue struct {
+	TriggerDeliveryQueue
+}
+
+func (q *failingDeliveryQueue) Enqueue(ctx context.Context, deliveries []*model.TriggerDelivery) error {
+	return stderrors.New("queue unavailable")
+}
+
+// countingCommitScope runs writes in place and counts them
+type countingCommitScope struct {
+	writes int
+}
+
+func (s *countingCommitScope) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	s.writes++
+	return fn(ctx)
+}
+
+func TestTriggers_DeliveriesAreQueuedWithTheWrite(t *testing.T) {
+	ctx := context.Background()
+	sender := webhook.NewHTTPSenderWithConfig(webhook.HTTPSenderConfig{AllowPrivateNetworks: true})
+	queue := NewInMemoryTriggerDeliveryQueue()
+	uc := NewTriggerUsecase(nil, queue, sender, DefaultTriggerDeliveryConfig(), &MockLogger{})
+	scope := &countingCommitScope{}
+	repo := NewDocumentChangeRepositoryWithCommitScope(newMemDocRepo(), scope, uc)
+	_, err := uc.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: "http://127.0.0.1:9/hook",
+	})
+	require.NoError(t, err)
+
+	_, err = repo.CreateDocument(ctx, "p", "d", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, scope.writes)
+	pending, err := uc.ListDeliveries(ctx, "p", "d", model.TriggerDeliveryPending, 0)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// Writes to databases without triggers do not open a transaction
+	_, err = repo.CreateDocument(ctx, "p", "other", "users", "u1", fields(map[string]interface{}{"name": "Ada"}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, scope.writes)
+
+	// A delivery that cannot be queued fails the write, so the transaction is rolled back
+	failing := NewTriggerUsecase(nil, &failingDeliveryQueue{TriggerDeliveryQueue: queue}, sender, DefaultTriggerDeliveryConfig(), &MockLogger{})
+	_, err = failing.CreateTrigger(ctx, CreateTriggerRequest{
+		ProjectID: "p", DatabaseID: "d", EventType: model.TriggerOnCreate, DocumentPattern: "users/{uid}", URL: "http://127.0.0.1:9/hook",
+	})
+	require.NoError(t, err)
+	failingRepo := NewDocumentChangeRepositoryWithCommitScope(newMemDocRepo(), scope, failing)
+	_, err = failingRepo.CreateDocument(ctx, "p", "d", "users", "u2", fields(map[string]interface{}{"name": "Grace"}))
+	assert.ErrorContains(t, err, "queue unavailable")
+}
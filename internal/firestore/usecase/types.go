@@ -1,6 +1,9 @@
 package usecase
 
 import (
+	"time"
+
+	authModel "firestore-clone/internal/auth/domain/model"
 	"firestore-clone/internal/firestore/domain/model"
 )
 
@@ -88,6 +91,53 @@ type ListSubcollectionsRequest struct {
 	DocumentID   string `json:"documentId" validate:"required"`
 }
 
+// Recursive delete operations
+type RecursiveDeleteRequest struct {
+	ProjectID   string          `json:"projectId" validate:"required"`
+	DatabaseID  string          `json:"databaseId" validate:"required"`
+	Path        string          `json:"path" validate:"required"` // Document or collection path relative to /documents
+	BatchSize   int             `json:"batchSize,omitempty"`
+	BypassRules bool            `json:"bypassRules,omitempty"` // Admin only
+	User        *authModel.User `json:"-"`
+}
+
+// Collection schema operations
+type SetCollectionSchemaRequest struct {
+	ProjectID         string             `json:"projectId" validate:"required"`
+	DatabaseID        string             `json:"databaseId" validate:"required"`
+	CollectionPattern string             `json:"collectionPattern" validate:"required"` // e.g. "users" or "{path=**}/posts"
+	Mode              model.SchemaMode   `json:"mode,omitempty"`                        // Defaults to enforce
+	Schema            *model.FieldSchema `json:"schema" validate:"required"`
+	Description       string             `json:"description,omitempty"`
+}
+
+type DiscoverSchemaRequest struct {
+	ProjectID    string `json:"projectId" validate:"required"`
+	DatabaseID   string `json:"databaseId" validate:"required"`
+	CollectionID string `json:"collectionId" validate:"required"`
+	SampleSize   int    `json:"sampleSize,omitempty"`  // Documents to profile, defaults to DefaultSchemaSampleSize
+	FullScan     bool   `json:"fullScan,omitempty"`    // Profile every document, ignoring SampleSize
+	MaxExamples  int    `json:"maxExamples,omitempty"` // Example values kept per field path
+}
+
+// Full-text search operations
+type SetSearchConfigRequest struct {
+	ProjectID    string              `json:"projectId" validate:"required"`
+	DatabaseID   string              `json:"databaseId" validate:"required"`
+	CollectionID string              `json:"collectionId" validate:"required"`
+	Fields       []model.SearchField `json:"fields" validate:"required"`
+}
+
+type SearchRequest struct {
+	ProjectID    string         `json:"projectId" validate:"required"`
+	DatabaseID   string         `json:"databaseId" validate:"required"`
+	CollectionID string         `json:"collectionId" validate:"required"`
+	Query        string         `json:"query" validate:"required"`
+	Filters      []model.Filter `json:"filters,omitempty"`
+	Limit        int            `json:"limit,omitempty"`
+	MatchAny     bool           `json:"matchAny,omitempty"` // Match documents containing any query word instead of all
+}
+
 // Index operations
 type CreateIndexRequest struct {
 	ProjectID  string      `json:"projectId" validate:"required"`
@@ -261,3 +311,25 @@ type AggregationResult struct {
 type AggregationResultData struct {
 	AggregateFields map[string]interface{} `json:"aggregateFields"`
 }
+
+// Document trigger operations
+type CreateTriggerRequest struct {
+	ProjectID       string                         `json:"projectId" validate:"required"`
+	DatabaseID      string                         `json:"databaseId" validate:"required"`
+	EventType       model.DocumentTriggerEventType `json:"eventType" validate:"required"` // onCreate, onUpdate, onDelete or onWritten
+	DocumentPattern string                         `json:"document" validate:"required"`  // e.g. "users/{userId}/orders/{orderId}"
+	URL             string                         `json:"url" validate:"required"`
+	Headers         map[string]string              `json:"headers,omitempty"`
+	Description     string                         `json:"description,omitempty"`
+}
+
+// Change feed operations
+type ReadChangesRequest struct {
+	ProjectID       string               `json:"projectId" validate:"required"`
+	DatabaseID      string               `json:"databaseId" validate:"required"`
+	Since           string               `json:"since,omitempty"`           // Cursor of the last change read; empty reads from the oldest retained change
+	CollectionGroup string               `json:"collectionGroup,omitempty"` // Only changes of documents in collections with this ID
+	Limit           int                  `json:"limit,omitempty"`
+	View            model.ChangeFeedView `json:"view,omitempty"` // FULL (default) or AFTER
+	Wait            time.Duration        `json:"-"`              // How long to wait for changes when there are none
+}
@@ -0,0 +1,283 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/domain/service"
+	"firestore-clone/internal/shared/logger"
+	"firestore-clone/internal/shared/utils"
+)
+
+const (
+	// vectorIndexDefinitionsTTL is how long the vector index definitions of a collection
+	// are cached before they are read again through the index API
+	vectorIndexDefinitionsTTL = 30 * time.Second
+	// vectorCandidatesPerResult and minVectorCandidates size the preselection of an
+	// approximate search; the exact ranking then runs over the candidates only
+	vectorCandidatesPerResult = 10
+	minVectorCandidates       = 100
+)
+
+// VectorIndexUsecase maintains approximate nearest neighbor indexes for the vector index
+// fields declared through the index API, and preselects candidates for findNearest queries
+type VectorIndexUsecase interface {
+	// Candidates preselects documents for a findNearest query. ok is false when no vector
+	// index serves the query and every pre-filtered document must be ranked.
+	Candidates(ctx context.Context, projectID, databaseID, collectionID string, nearest *model.FindNearest) (documentIDs []string, ok bool)
+
+	// Index maintenance, called after successful writes
+	DocumentIndexer
+}
+
+// vectorIndexUsecase implements VectorIndexUsecase. Indexes are built from storage on the
+// first findNearest query of a field and follow writes afterwards. Before each query they
+// apply the changelog, so the writes of other instances are candidates too; when the index
+// cannot be brought up to date the query falls back to an exact search.
+type vectorIndexUsecase struct {
+	firestoreRepo repository.FirestoreRepository
+	vectors       service.VectorIndexService
+	changes       *changeLogFollower
+	logger        logger.Logger
+
+	mu          sync.RWMutex
+	definitions map[string]*vectorIndexDefinitions // collection key -> vector fields
+	built       map[string]map[string]bool         // collection key -> fields built from storage
+}
+
+// vectorIndexDefinitions caches the vector fields of a collection: field path -> dimension
+type vectorIndexDefinitions struct {
+	fields   map[string]int
+	loadedAt time.Time
+}
+
+// NewVectorIndexUsecase creates a vector index usecase that only follows the writes of its
+// own instance. firestoreRepo is used to read index definitions and build indexes, so it
+// must not be the indexing repository.
+func NewVectorIndexUsecase(firestoreRepo repository.FirestoreRepository, vectors service.VectorIndexService, log logger.Logger) VectorIndexUsecase {
+	return NewVectorIndexUsecaseWithChangeLog(firestoreRepo, vectors, nil, log)
+}
+
+// NewVectorIndexUsecaseWithChangeLog creates a vector index usecase whose indexes also apply
+// the changes recorded in the changelog by other instances
+func NewVectorIndexUsecaseWithChangeLog(firestoreRepo repository.FirestoreRepository, vectors service.VectorIndexService, changeLog ChangeLogStore, log logger.Logger) VectorIndexUsecase {
+	if vectors == nil {
+		vectors = service.NewVectorIndexService()
+	}
+	return &vectorIndexUsecase{
+		firestoreRepo: firestoreRepo,
+		vectors:       vectors,
+		changes:       newChangeLogFollower(changeLog),
+		logger:        log,
+		definitions:   make(map[string]*vectorIndexDefinitions),
+		built:         make(map[string]map[string]bool),
+	}
+}
+
+// vectorFieldIndexKey isolates the index of one vector field of a tenant collection
+func vectorFieldIndexKey(collectionKey, fieldPath string) string {
+	return collectionKey + "#" + fieldPath
+}
+
+// Candidates implements VectorIndexUsecase
+func (uc *vectorIndexUsecase) Candidates(ctx context.Context, projectID, databaseID, collectionID string, nearest *model.FindNearest) ([]string, bool) {
+	if nearest == nil {
+		return nil, false
+	}
+	collectionKey := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	fields := uc.loadDefinitions(ctx, collectionKey, projectID, databaseID, collectionID)
+	dimension, declared := fields[nearest.VectorField]
+	if !declared || dimension != len(nearest.QueryVector) {
+		return nil, false
+	}
+	// The index follows the changelog of the organization that owns the collection
+	organizationID := utils.GetOrganizationIDOrDefault(ctx, "")
+	if err := uc.ensureBuilt(ctx, collectionKey, organizationID, projectID, databaseID, collectionID, nearest.VectorField, dimension); err != nil {
+		uc.logger.Warn("Vector index unavailable, using exact search", "collection", collectionID, "error", err)
+		return nil, false
+	}
+
+	minCandidates := nearest.Limit * vectorCandidatesPerResult
+	if minCandidates < minVectorCandidates {
+		minCandidates = minVectorCandidates
+	}
+	return uc.vectors.Candidates(vectorFieldIndexKey(collectionKey, nearest.VectorField), nearest.QueryVector, nearest.DistanceMeasure, minCandidates)
+}
+
+// IsIndexed implements DocumentIndexer
+func (uc *vectorIndexUsecase) IsIndexed(ctx context.Context, projectID, databaseID, collectionID string) bool {
+	return len(uc.builtFields(searchIndexKey(ctx, projectID, databaseID, collectionID))) > 0
+}
+
+// IndexDocument implements DocumentIndexer
+func (uc *vectorIndexUsecase) IndexDocument(ctx context.Context, projectID, databaseID, collectionID string, doc *model.Document) {
+	if doc == nil {
+		return
+	}
+	collectionKey := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	fields := uc.cachedDefinitions(collectionKey)
+	for _, fieldPath := range uc.builtFields(collectionKey) {
+		uc.index(vectorFieldIndexKey(collectionKey, fieldPath), fieldPath, fields[fieldPath], doc)
+	}
+}
+
+// RemoveDocument implements DocumentIndexer
+func (uc *vectorIndexUsecase) RemoveDocument(ctx context.Context, projectID, databaseID, collectionID, documentID string) {
+	collectionKey := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	for _, fieldPath := range uc.builtFields(collectionKey) {
+		uc.vectors.Remove(vectorFieldIndexKey(collectionKey, fieldPath), documentID)
+	}
+}
+
+// RemoveCollection implements DocumentIndexer. The indexes stay built for new documents.
+func (uc *vectorIndexUsecase) RemoveCollection(ctx context.Context, projectID, databaseID, collectionID string) {
+	collectionKey := searchIndexKey(ctx, projectID, databaseID, collectionID)
+	for _, fieldPath := range uc.builtFields(collectionKey) {
+		uc.vectors.DropIndex(vectorFieldIndexKey(collectionKey, fieldPath))
+	}
+}
+
+func (uc *vectorIndexUsecase) cachedDefinitions(collectionKey string) map[string]int {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	if definitions := uc.definitions[collectionKey]; definitions != nil {
+		return definitions.fields
+	}
+	return nil
+}
+
+func (uc *vectorIndexUsecase) builtFields(collectionKey string) []string {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	fields := make([]string, 0, len(uc.built[collectionKey]))
+	for fieldPath := range uc.built[collectionKey] {
+		fields = append(fields, fieldPath)
+	}
+	return fields
+}
+
+// loadDefinitions returns the vector fields declared for a collection, reading them through
+// the index API when the cache has expired
+func (uc *vectorIndexUsecase) loadDefinitions(ctx context.Context, collectionKey, projectID, databaseID, collectionID string) map[string]int {
+	uc.mu.RLock()
+	cached := uc.definitions[collectionKey]
+	uc.mu.RUnlock()
+	if cached != nil && time.Since(cached.loadedAt) < vectorIndexDefinitionsTTL {
+		return cached.fields
+	}
+
+	fields := make(map[string]int)
+	indexes, err := uc.firestoreRepo.ListIndexes(ctx, projectID, databaseID, collectionID)
+	if err != nil {
+		uc.logger.Warn("Failed to read vector index definitions", "collection", collectionID, "error", err)
+	}
+	for _, index := range indexes {
+		if index == nil {
+			continue
+		}
+		for _, field := range index.Fields {
+			if field.VectorConfig != nil && field.VectorConfig.Dimension > 0 {
+				fields[field.Path] = field.VectorConfig.Dimension
+			}
+		}
+	}
+
+	uc.mu.Lock()
+	uc.definitions[collectionKey] = &vectorIndexDefinitions{fields: fields, loadedAt: time.Now()}
+	for fieldPath := range uc.built[collectionKey] {
+		// Drop the indexes of fields no longer declared or declared with another dimension
+		if dimension, declared := fields[fieldPath]; !declared || (cached != nil && cached.fields[fieldPath] != dimension) {
+			delete(uc.built[collectionKey], fieldPath)
+			uc.vectors.DropIndex(vectorFieldIndexKey(collectionKey, fieldPath))
+			uc.changes.forget(vectorFieldIndexKey(collectionKey, fieldPath))
+		}
+	}
+	uc.mu.Unlock()
+	return fields
+}
+
+// ensureBuilt builds the index of a vector field from storage the first time it is queried,
+// and afterwards applies the changes committed since, including those of other instances
+func (uc *vectorIndexUsecase) ensureBuilt(ctx context.Context, collectionKey, organizationID, projectID, databaseID, collectionID, fieldPath string, dimension int) error {
+	uc.mu.RLock()
+	built := uc.built[collectionKey][fieldPath]
+	uc.mu.RUnlock()
+	if !built {
+		return uc.rebuild(ctx, collectionKey, organizationID, projectID, databaseID, collectionID, fieldPath, dimension)
+	}
+
+	key := vectorFieldIndexKey(collectionKey, fieldPath)
+	err := uc.changes.catchUp(ctx, key, organizationID, projectID, databaseID, collectionID, func(documentID string, after *model.Document) {
+		if after == nil {
+			uc.vectors.Remove(key, documentID)
+			return
+		}
+		uc.index(key, fieldPath, dimension, after)
+	})
+	if errors.Is(err, model.ErrChangeFeedCursorExpired) {
+		return uc.rebuild(ctx, collectionKey, organizationID, projectID, databaseID, collectionID, fieldPath, dimension)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update vector index for %s.%s: %w", collectionID, fieldPath, err)
+	}
+	return nil
+}
+
+func (uc *vectorIndexUsecase) rebuild(ctx context.Context, collectionKey, organizationID, projectID, databaseID, collectionID, fieldPath string, dimension int) error {
+	// Changes recorded after this point are applied by the next catch-up
+	head, err := uc.changes.head(ctx, organizationID, projectID, databaseID)
+	if err != nil {
+		return fmt.Errorf("failed to build vector index for %s.%s: %w", collectionID, fieldPath, err)
+	}
+	key := vectorFieldIndexKey(collectionKey, fieldPath)
+	uc.vectors.DropIndex(key)
+	pageToken := ""
+	for {
+		docs, nextPageToken, err := uc.firestoreRepo.ListDocuments(ctx, projectID, databaseID, collectionID, searchRebuildPageSize, pageToken, "", false)
+		if err != nil {
+			return fmt.Errorf("failed to build vector index for %s.%s: %w", collectionID, fieldPath, err)
+		}
+		for _, doc := range docs {
+			uc.index(key, fieldPath, dimension, doc)
+		}
+		if nextPageToken == "" || nextPageToken == pageToken {
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	uc.mu.Lock()
+	if uc.built[collectionKey] == nil {
+		uc.built[collectionKey] = make(map[string]bool)
+	}
+	uc.built[collectionKey][fieldPath] = true
+	uc.mu.Unlock()
+	uc.changes.markBuilt(key, head)
+	uc.logger.Debug("Vector index built", "collection", collectionID, "field", fieldPath, "vectors", uc.vectors.Size(key))
+	return nil
+}
+
+// index stores the document vector, or removes the document when its field does not hold
+// a vector of the index dimension
+func (uc *vectorIndexUsecase) index(key, fieldPath string, dimension int, doc *model.Document) {
+	if vector, ok := documentVector(doc, fieldPath); ok && len(vector.Values) == dimension {
+		uc.vectors.Upsert(key, doc.DocumentID, vector.Values)
+		return
+	}
+	uc.vectors.Remove(key, doc.DocumentID)
+}
+
+// documentVector resolves a dotted field path to a vector value
+func documentVector(doc *model.Document, fieldPath string) (*model.VectorValue, bool) {
+	value, found := lookupFieldPath(doc.Fields, strings.Split(fieldPath, "."))
+	if !found {
+		return nil, false
+	}
+	return model.VectorFromValue(value)
+}
@@ -0,0 +1,237 @@
+package usecase_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/firestore/domain/service"
+	. "firestore-clone/internal/firestore/usecase"
+	"firestore-clone/internal/shared/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vectorDocRepo is a memDocRepo with index definitions
+type vectorDocRepo struct {
+	*memDocRepo
+	indexes []*model.CollectionIndex
+}
+
+func (r *vectorDocRepo) ListIndexes(ctx context.Context, projectID, databaseID, collectionID string) ([]*model.CollectionIndex, error) {
+	return r.indexes, nil
+}
+
+// rankingQueryEngine ranks the stored documents in memory and records the executed queries
+type rankingQueryEngine struct {
+	repository.QueryEngine
+	store   *memDocRepo
+	queries []model.Query
+}
+
+func (e *rankingQueryEngine) ExecuteQuery(ctx context.Context, collectionPath string, query model.Query) ([]*model.Document, error) {
+	e.queries = append(e.queries, query)
+	docs, _, _ := e.store.ListDocuments(ctx, "p", "d", collectionPath, 0, "", "", false)
+	if ids := query.FindNearest.CandidateIDs; ids != nil {
+		allowed := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			allowed[id] = true
+		}
+		var candidates []*model.Document
+		for _, doc := range docs {
+			if allowed[doc.DocumentID] {
+				candidates = append(candidates, doc)
+			}
+		}
+		docs = candidates
+	}
+	return query.FindNearest.Rank(docs), nil
+}
+
+func newVectorTestSetup(t *testing.T, ctx context.Context) (repository.QueryEngine, *rankingQueryEngine, repository.FirestoreRepository) {
+	store := &vectorDocRepo{memDocRepo: newMemDocRepo(), indexes: []*model.CollectionIndex{{
+		Name:   "embedding_vector",
+		Fields: []model.IndexField{{Path: "embedding", VectorConfig: &model.VectorIndexConfig{Dimension: 2}}},
+	}}}
+	vectorUC := NewVectorIndexUsecase(store, service.NewVectorIndexService(), &MockLogger{})
+	repo := NewIndexingRepository(store, vectorUC)
+
+	// Two well separated clusters, large enough to be partitioned
+	for i := 0; i < service.VectorIndexMinTrainingSize; i++ {
+		center := float64((i % 2) * 100)
+		offset := float64(i%50) / 50
+		_, err := repo.CreateDocument(ctx, "p", "d", "items", fmt.Sprintf("doc-%04d", i), fields(map[string]interface{}{
+			"embedding": &model.VectorValue{Values: []float64{center + offset, center + offset}},
+		}))
+		require.NoError(t, err)
+	}
+
+	engine := &rankingQueryEngine{store: store.memDocRepo}
+	return NewVectorSearchQueryEngine(engine, vectorUC), engine, repo
+}
+
+func nearestQuery(limit int, vector ...float64) model.Query {
+	return model.Query{
+		Path:         "projects/p/databases/d/documents",
+		CollectionID: "items",
+		FindNearest:  &model.FindNearest{VectorField: "embedding", QueryVector: vector, DistanceMeasure: model.DistanceMeasureEuclidean, Limit: limit},
+	}
+}
+
+func TestVectorSearch_UsesTheApproximateIndex(t *testing.T) {
+	ctx := utils.WithOrganizationID(context.Background(), "org-a")
+	engine, recorder, repo := newVectorTestSetup(t, ctx)
+
+	docs, err := engine.ExecuteQuery(ctx, "items", nearestQuery(3, 100, 100))
+	require.NoError(t, err)
+	require.Len(t, docs, 3)
+	require.Len(t, recorder.queries, 1)
+	candidates := recorder.queries[0].FindNearest.CandidateIDs
+	require.NotNil(t, candidates)
+	assert.Less(t, len(candidates), service.VectorIndexMinTrainingSize)
+
+	// The index follows writes made after it was built
+	_, err = repo.CreateDocument(ctx, "p", "d", "items", "exact", fields(map[string]interface{}{
+		"embedding": &model.VectorValue{Values: []float64{100, 100}},
+	}))
+	require.NoError(t, err)
+	docs, err = engine.ExecuteQuery(ctx, "items", nearestQuery(3, 100, 100))
+	require.NoError(t, err)
+	assert.Equal(t, "exact", docs[0].DocumentID)
+
+	require.NoError(t, repo.DeleteDocument(ctx, "p", "d", "items", "exact"))
+	docs, err = engine.ExecuteQuery(ctx, "items", nearestQuery(3, 100, 100))
+	require.NoError(t, err)
+	assert.NotEqual(t, "exact", docs[0].DocumentID)
+}
+
+func TestVectorSearch_FallsBackToExactSearch(t *testing.T) {
+	ctx := context.Background()
+	engine, recorder, _ := newVectorTestSetup(t, ctx)
+
+	// No vector index of this dimension: a single exact search
+	_, err := engine.ExecuteQuery(ctx, "items", nearestQuery(3, 1, 1, 1))
+	require.NoError(t, err)
+	require.Len(t, recorder.queries, 1)
+	assert.Nil(t, recorder.queries[0].FindNearest.CandidateIDs)
+
+	// Too few candidates survive the threshold: the approximate search is followed by an exact one
+	recorder.queries = nil
+	query := nearestQuery(50, 0, 0)
+	threshold := 0.0
+	query.FindNearest.DistanceThreshold = &threshold
+	_, err = engine.ExecuteQuery(ctx, "items", query)
+	require.NoError(t, err)
+	require.Len(t, recorder.queries, 2)
+	assert.NotNil(t, recorder.queries[0].FindNearest.CandidateIDs)
+	assert.Nil(t, recorder.queries[1].FindNearest.CandidateIDs)
+}
+
+// failingChangeLogStore is a changelog that can no longer be read
+type failingChangeLogStore struct {
+	ChangeLogStore
+	failing bool
+}
+
+func (s *failingChangeLogStore) GetState(ctx context.Context, projectID, databaseID string) (*model.ChangeLogState, error) {
+	if s.failing {
+		return nil, fmt.Errorf("changelog unavailable")
+	}
+	return s.ChangeLogStore.GetState(ctx, projectID, databaseID)
+}
+
+func TestVectorSearch_FollowsOtherInstances(t *testing.T) {
+	ctx := utils.WithOrganizationID(context.Background(), "org-a")
+	changeLog := &failingChangeLogStore{ChangeLogStore: NewInMemoryChangeLogStore()}
+	store := &vectorDocRepo{memDocRepo: newMemDocRepo(), indexes: []*model.CollectionIndex{{
+		Name:   "embedding_vector",
+		Fields: []model.IndexField{{Path: "embedding", VectorConfig: &model.VectorIndexConfig{Dimension: 2}}},
+	}}}
+	for i := 0; i < service.VectorIndexMinTrainingSize; i++ {
+		center := float64((i % 2) * 100)
+		_, err := store.CreateDocument(ctx, "p", "d", "items", fmt.Sprintf("doc-%04d", i), fields(map[string]interface{}{
+			"embedding": &model.VectorValue{Values: []float64{center, center}},
+		}))
+		require.NoError(t, err)
+	}
+	instanceB := NewVectorIndexUsecaseWithChangeLog(store, service.NewVectorIndexService(), changeLog, &MockLogger{})
+	nearest := nearestQuery(3, 100, 100).FindNearest
+
+	candidates, ok := instanceB.Candidates(ctx, "p", "d", "items", nearest)
+	require.True(t, ok)
+	assert.NotContains(t, candidates, "exact")
+
+	// Another instance commits a document and records it in the changelog
+	doc, err := store.CreateDocument(ctx, "p", "d", "items", "exact", fields(map[string]interface{}{
+		"embedding": &model.VectorValue{Values: []float64{100, 100}},
+	}))
+	require.NoError(t, err)
+	require.NoError(t, changeLog.Append(ctx, "p", "d", []*model.ChangeLogEntry{{
+		Change: &model.DocumentChange{DocumentPath: "items/exact", After: doc},
+	}}))
+	candidates, ok = instanceB.Candidates(ctx, "p", "d", "items", nearest)
+	require.True(t, ok)
+	assert.Contains(t, candidates, "exact")
+
+	// An index that cannot be brought up to date is not used
+	changeLog.failing = true
+	_, ok = instanceB.Candidates(ctx, "p", "d", "items", nearest)
+	assert.False(t, ok, "falls back to an exact search")
+}
+
+func TestVectorSearch_CatchUpFollowsTheOrganizationAndWaitsForSequencesInFlight(t *testing.T) {
+	orgA := utils.WithOrganizationID(context.Background(), "org-a")
+	orgB := utils.WithOrganizationID(context.Background(), "org-b")
+	changeLog := &gapChangeLogStore{ChangeLogStore: NewInMemoryChangeLogStore()}
+	store := &vectorDocRepo{memDocRepo: newMemDocRepo(), indexes: []*model.CollectionIndex{{
+		Name:   "embedding_vector",
+		Fields: []model.IndexField{{Path: "embedding", VectorConfig: &model.VectorIndexConfig{Dimension: 2}}},
+	}}}
+	for i := 0; i < service.VectorIndexMinTrainingSize; i++ {
+		center := float64((i % 2) * 100)
+		_, err := store.CreateDocument(orgA, "p", "d", "items", fmt.Sprintf("doc-%04d", i), fields(map[string]interface{}{
+			"embedding": &model.VectorValue{Values: []float64{center, center}},
+		}))
+		require.NoError(t, err)
+	}
+	instanceB := NewVectorIndexUsecaseWithChangeLog(store, service.NewVectorIndexService(), changeLog, &MockLogger{})
+	nearest := nearestQuery(3, 100, 100).FindNearest
+	_, ok := instanceB.Candidates(orgA, "p", "d", "items", nearest)
+	require.True(t, ok)
+
+	// Another instance commits a document and records it in the changelog
+	write := func(ctx context.Context, documentID string) {
+		doc, err := store.CreateDocument(ctx, "p", "d", "items", documentID, fields(map[string]interface{}{
+			"embedding": &model.VectorValue{Values: []float64{100, 100}},
+		}))
+		require.NoError(t, err)
+		require.NoError(t, changeLog.Append(ctx, "p", "d", []*model.ChangeLogEntry{{
+			Change:     &model.DocumentChange{DocumentPath: "items/" + documentID, After: doc},
+			RecordedAt: time.Now(),
+		}}))
+	}
+
+	// Changes of another organization never reach the index
+	write(orgB, "other-org")
+	candidates, ok := instanceB.Candidates(orgA, "p", "d", "items", nearest)
+	require.True(t, ok)
+	assert.NotContains(t, candidates, "other-org")
+
+	// A young gap stops the catch-up before it, so the missing change is not skipped
+	changeLog.hidden = 1
+	write(orgA, "first")
+	write(orgA, "second")
+	candidates, ok = instanceB.Candidates(orgA, "p", "d", "items", nearest)
+	require.True(t, ok)
+	assert.NotContains(t, candidates, "second")
+
+	changeLog.hidden = 0
+	candidates, ok = instanceB.Candidates(orgA, "p", "d", "items", nearest)
+	require.True(t, ok)
+	assert.Contains(t, candidates, "first")
+	assert.Contains(t, candidates, "second")
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+)
+
+// vectorSearchQueryEngine decorates a QueryEngine so that findNearest queries over a
+// collection with a vector index only rank the candidates preselected by the index.
+// When the pre-filters or the distance threshold leave fewer results than requested,
+// the query runs again as an exact search over every pre-filtered document.
+type vectorSearchQueryEngine struct {
+	repository.QueryEngine
+	vectorUC VectorIndexUsecase
+}
+
+// NewVectorSearchQueryEngine wraps engine with approximate findNearest execution
+func NewVectorSearchQueryEngine(engine repository.QueryEngine, vectorUC VectorIndexUsecase) repository.QueryEngine {
+	return &vectorSearchQueryEngine{QueryEngine: engine, vectorUC: vectorUC}
+}
+
+// ExecuteQuery implements repository.QueryEngine
+func (e *vectorSearchQueryEngine) ExecuteQuery(ctx context.Context, collectionPath string, query model.Query) ([]*model.Document, error) {
+	return e.execute(ctx, collectionPath, query, func(q model.Query) ([]*model.Document, error) {
+		return e.QueryEngine.ExecuteQuery(ctx, collectionPath, q)
+	})
+}
+
+// ExecuteQueryWithProjection implements repository.QueryEngine
+func (e *vectorSearchQueryEngine) ExecuteQueryWithProjection(ctx context.Context, collectionPath string, query model.Query, projection []string) ([]*model.Document, error) {
+	return e.execute(ctx, collectionPath, query, func(q model.Query) ([]*model.Document, error) {
+		return e.QueryEngine.ExecuteQueryWithProjection(ctx, collectionPath, q, projection)
+	})
+}
+
+func (e *vectorSearchQueryEngine) execute(ctx context.Context, collectionPath string, query model.Query, run func(model.Query) ([]*model.Document, error)) ([]*model.Document, error) {
+	if query.FindNearest == nil || query.AllDescendants || e.vectorUC == nil {
+		return run(query)
+	}
+	projectID, databaseID, ok := queryProjectAndDatabase(query.Path)
+	if !ok {
+		return run(query)
+	}
+	candidates, ok := e.vectorUC.Candidates(ctx, projectID, databaseID, collectionPath, query.FindNearest)
+	if !ok {
+		return run(query)
+	}
+
+	approximate := *query.FindNearest
+	approximate.CandidateIDs = candidates
+	approximateQuery := query
+	approximateQuery.FindNearest = &approximate
+	docs, err := run(approximateQuery)
+	if err == nil && len(docs) >= query.FindNearest.Limit {
+		return docs, nil
+	}
+	return run(query)
+}
+
+// queryProjectAndDatabase extracts the project and database of a query parent path:
+// projects/{PROJECT_ID}/databases/{DATABASE_ID}/documents[/...]
+func queryProjectAndDatabase(path string) (projectID, databaseID string, ok bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 4 || segments[0] != "projects" || segments[2] != "databases" {
+		return "", "", false
+	}
+	return segments[1], segments[3], true
+}
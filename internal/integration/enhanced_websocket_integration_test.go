@@ -440,6 +440,19 @@ func (m *MockSecurityUsecase) ValidateUpdate(ctx context.Context, user *authMode
 	return nil
 }
 
+func (m *MockSecurityUsecase) ValidateQuery(ctx context.Context, user *authModel.User, collectionPath string, query *model.Query) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.shouldValidate {
+		if m.validationErr != nil {
+			return m.validationErr
+		}
+		return fmt.Errorf("query validation failed")
+	}
+	return nil
+}
+
 // MockAuthClient provides authentication mock for testing with Firestore compatibility
 type MockAuthClient struct {
 	users map[string]*authModel.User
@@ -0,0 +1,35 @@
+package adapter
+
+import (
+	"context"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/repository"
+)
+
+// MemoryResourceAccessor implementación en memoria de ResourceAccessor para evaluar
+// reglas sin base de datos: solo existen los documentos con los que se crea
+type MemoryResourceAccessor struct {
+	documents map[string]map[string]interface{}
+}
+
+// NewMemoryResourceAccessor crea el accessor con documentos por ruta relativa a la base de datos
+func NewMemoryResourceAccessor(documents map[string]map[string]interface{}) *MemoryResourceAccessor {
+	stored := make(map[string]map[string]interface{}, len(documents))
+	for path, data := range documents {
+		stored[strings.Trim(path, "/")] = data
+	}
+	return &MemoryResourceAccessor{documents: stored}
+}
+
+// GetDocument devuelve el documento, nil si no existe
+func (a *MemoryResourceAccessor) GetDocument(ctx context.Context, projectID, databaseID, path string) (map[string]interface{}, error) {
+	return a.documents[strings.Trim(path, "/")], nil
+}
+
+// ExistsDocument indica si el documento existe
+func (a *MemoryResourceAccessor) ExistsDocument(ctx context.Context, projectID, databaseID, path string) (bool, error) {
+	return a.documents[strings.Trim(path, "/")] != nil, nil
+}
+
+var _ repository.ResourceAccessor = (*MemoryResourceAccessor)(nil)
@@ -26,6 +26,11 @@ const (
 	ALLOW
 	DENY
 	IF
+	FUNCTION
+	LET
+	RETURN
+	IN
+	IS
 
 	// Operators
 	EQUALS
@@ -40,6 +45,10 @@ const (
 	DOT
 	PLUS
 	MINUS
+	MULTIPLY
+	DIVIDE
+	MODULO
+	QUESTION
 	DOLLAR
 	SEMICOLON
 	COLON
@@ -99,11 +108,10 @@ func (l *Lexer) Tokenize() ([]Token, error) {
 	for l.position < len(l.input) {
 		// Skip whitespace
 		if l.isWhitespace(l.current()) {
+			// advance() avanza la columna; tras un salto de línea la deja en 1
 			if l.current() == '\n' {
 				l.line++
-				l.column = 1
-			} else {
-				l.column++
+				l.column = 0
 			}
 			l.advance()
 			continue
@@ -222,6 +230,15 @@ func (l *Lexer) nextToken() (Token, error) {
 	case '-':
 		l.advance()
 		return l.makeToken(MINUS, "-", startLine, startColumn), nil
+	case '*':
+		l.advance()
+		return l.makeToken(MULTIPLY, "*", startLine, startColumn), nil
+	case '%':
+		l.advance()
+		return l.makeToken(MODULO, "%", startLine, startColumn), nil
+	case '?':
+		l.advance()
+		return l.makeToken(QUESTION, "?", startLine, startColumn), nil
 	case '[':
 		l.advance()
 		return l.makeToken(LBRACKET, "[", startLine, startColumn), nil
@@ -234,7 +251,11 @@ func (l *Lexer) nextToken() (Token, error) {
 	case '"', '\'':
 		return l.readString()
 	case '/':
-		// Path segment
+		// A slash followed by whitespace is a division; otherwise a path literal
+		if l.isWhitespace(l.peek()) {
+			l.advance()
+			return l.makeToken(DIVIDE, "/", startLine, startColumn), nil
+		}
 		return l.readPath()
 	case 0:
 		return l.makeToken(EOF, "", startLine, startColumn), nil
@@ -245,8 +266,12 @@ func (l *Lexer) nextToken() (Token, error) {
 			return l.readNumber()
 		}
 
-		return Token{}, fmt.Errorf("unexpected character '%c' at line %d, column %d",
-			ch, l.line, l.column)
+		return Token{}, domain.ParseError{
+			Line:    l.line,
+			Column:  l.column,
+			Message: fmt.Sprintf("unexpected character '%c'", ch),
+			Type:    "lexical",
+		}
 	}
 }
 
@@ -310,6 +335,7 @@ func (p *ModernParser) ParseString(ctx context.Context, content string) (*domain
 
 	p.tokens = tokens
 	p.current = 0
+	p.errors = make([]domain.ParseError, 0)
 
 	// Fase 2: Syntactic Analysis
 	ruleset, err := p.parseRuleset()
@@ -360,52 +386,62 @@ func (p *ModernParser) parseRuleset() (*domain.FirestoreRuleset, error) {
 		return nil, p.error("expected 'service' declaration")
 	}
 
-	service, matches, err := p.parseService()
+	service, functions, matches, err := p.parseService()
 	if err != nil {
 		return nil, err
 	}
 
 	ruleset.Service = service
+	ruleset.Functions = functions
 	ruleset.Matches = matches
 
 	return ruleset, nil
 }
 
 // parseService parsea la declaración service
-func (p *ModernParser) parseService() (string, []*domain.MatchBlock, error) {
+func (p *ModernParser) parseService() (string, []*domain.FunctionDeclaration, []*domain.MatchBlock, error) {
 	// service
 	if !p.consume(SERVICE) {
-		return "", nil, p.error("expected 'service'")
+		return "", nil, nil, p.error("expected 'service'")
 	}
 
 	// service name
 	if !p.check(IDENTIFIER) {
-		return "", nil, p.error("expected service name")
+		return "", nil, nil, p.error("expected service name")
 	}
 
 	serviceName := p.advance().Value
 
 	// {
 	if !p.consume(LBRACE) {
-		return "", nil, p.error("expected '{'")
+		return "", nil, nil, p.error("expected '{'")
 	}
 
-	// match blocks
+	// functions y match blocks
+	functions := make([]*domain.FunctionDeclaration, 0)
 	matches := make([]*domain.MatchBlock, 0)
 
 	for !p.check(RBRACE) && !p.isAtEnd() {
+		if p.check(FUNCTION) {
+			function, err := p.parseFunction()
+			if err != nil {
+				return "", nil, nil, err
+			}
+			functions = append(functions, function)
+			continue
+		}
 		match, err := p.parseMatchBlock()
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 		matches = append(matches, match)
 	}
 	// }
 	if !p.consume(RBRACE) {
-		return "", nil, p.error("unclosed service block - expected '}'")
+		return "", nil, nil, p.error("unclosed service block - expected '}'")
 	}
 
-	return serviceName, matches, nil
+	return serviceName, functions, matches, nil
 }
 
 // Helper methods
@@ -475,9 +511,7 @@ func (l *Lexer) skipMultiLineComment() {
 
 		if l.current() == '\n' {
 			l.line++
-			l.column = 1
-		} else {
-			l.column++
+			l.column = 0
 		}
 		l.advance()
 	}
@@ -492,14 +526,14 @@ func (l *Lexer) readString() (Token, error) {
 	value := ""
 	for l.current() != quote && l.current() != 0 {
 		if l.current() == '\n' {
-			return Token{}, fmt.Errorf("unterminated string at line %d", l.line)
+			return Token{}, domain.ParseError{Line: startLine, Column: startColumn, Message: "unterminated string", Type: "lexical"}
 		}
 		value += string(l.current())
 		l.advance()
 	}
 
 	if l.current() == 0 {
-		return Token{}, fmt.Errorf("unterminated string at line %d", l.line)
+		return Token{}, domain.ParseError{Line: startLine, Column: startColumn, Message: "unterminated string", Type: "lexical"}
 	}
 
 	l.advance() // Skip closing quote
@@ -599,6 +633,16 @@ func (l *Lexer) getKeywordType(value string) TokenType {
 		return DENY
 	case "if":
 		return IF
+	case "function":
+		return FUNCTION
+	case "let":
+		return LET
+	case "return":
+		return RETURN
+	case "in":
+		return IN
+	case "is":
+		return IS
 	default:
 		return IDENTIFIER
 	}
@@ -641,7 +685,7 @@ func (p *ModernParser) consume(tokenType TokenType) bool {
 
 func (p *ModernParser) error(message string) error {
 	token := p.peek()
-	return fmt.Errorf("%s at line %d, column %d", message, token.Line, token.Column)
+	return domain.ParseError{Line: token.Line, Column: token.Column, Message: message, Type: "syntax"}
 }
 
 func (p *ModernParser) parseRulesVersion() (string, error) {
@@ -666,6 +710,7 @@ func (p *ModernParser) parseRulesVersion() (string, error) {
 }
 
 func (p *ModernParser) parseMatchBlock() (*domain.MatchBlock, error) {
+	line, column := p.peek().Line, p.peek().Column
 	if !p.consume(MATCH) {
 		return nil, p.error("expected 'match'")
 	}
@@ -707,7 +752,10 @@ func (p *ModernParser) parseMatchBlock() (*domain.MatchBlock, error) {
 		Variables: make(map[string]string),
 		Allow:     make([]*domain.AllowStatement, 0),
 		Deny:      make([]*domain.DenyStatement, 0),
+		Functions: make([]*domain.FunctionDeclaration, 0),
 		Nested:    make([]*domain.MatchBlock, 0),
+		Line:      line,
+		Column:    column,
 	}
 
 	// Parse variables from path
@@ -727,6 +775,12 @@ func (p *ModernParser) parseMatchBlock() (*domain.MatchBlock, error) {
 				return nil, err
 			}
 			matchBlock.Deny = append(matchBlock.Deny, denyStmt)
+		} else if p.check(FUNCTION) {
+			function, err := p.parseFunction()
+			if err != nil {
+				return nil, err
+			}
+			matchBlock.Functions = append(matchBlock.Functions, function)
 		} else if p.check(MATCH) {
 			nestedMatch, err := p.parseMatchBlock()
 			if err != nil {
@@ -750,6 +804,7 @@ func (p *ModernParser) parseMatchBlock() (*domain.MatchBlock, error) {
 }
 
 func (p *ModernParser) parseAllowStatement() (*domain.AllowStatement, error) {
+	line, column := p.peek().Line, p.peek().Column
 	if !p.consume(ALLOW) {
 		return nil, p.error("expected 'allow'")
 	}
@@ -799,11 +854,13 @@ func (p *ModernParser) parseAllowStatement() (*domain.AllowStatement, error) {
 	return &domain.AllowStatement{
 		Operations: operations,
 		Condition:  condition,
-		Line:       p.current,
+		Line:       line,
+		Column:     column,
 	}, nil
 }
 
 func (p *ModernParser) parseDenyStatement() (*domain.DenyStatement, error) {
+	line, column := p.peek().Line, p.peek().Column
 	if !p.consume(DENY) {
 		return nil, p.error("expected 'deny'")
 	}
@@ -850,10 +907,100 @@ func (p *ModernParser) parseDenyStatement() (*domain.DenyStatement, error) {
 	return &domain.DenyStatement{
 		Operations: operations,
 		Condition:  condition,
-		Line:       p.current,
+		Line:       line,
+		Column:     column,
 	}, nil
 }
 
+// parseFunction parsea "function name(params) { let x = expr; ... return expr; }"
+func (p *ModernParser) parseFunction() (*domain.FunctionDeclaration, error) {
+	line := p.peek().Line
+	if !p.consume(FUNCTION) {
+		return nil, p.error("expected 'function'")
+	}
+
+	if !p.check(IDENTIFIER) || strings.Contains(p.peek().Value, ".") {
+		return nil, p.error("expected function name")
+	}
+	function := &domain.FunctionDeclaration{
+		Name:       p.advance().Value,
+		Parameters: make([]string, 0),
+		Line:       line,
+	}
+
+	if !p.consume(LPAREN) {
+		return nil, p.error(fmt.Sprintf("expected '(' after function name '%s'", function.Name))
+	}
+	for !p.check(RPAREN) {
+		if !p.check(IDENTIFIER) || strings.Contains(p.peek().Value, ".") {
+			return nil, p.error(fmt.Sprintf("expected parameter name in function '%s'", function.Name))
+		}
+		function.Parameters = append(function.Parameters, p.advance().Value)
+		if !p.consume(COMMA) {
+			break
+		}
+	}
+	if !p.consume(RPAREN) {
+		return nil, p.error(fmt.Sprintf("expected ')' after parameters of function '%s'", function.Name))
+	}
+
+	if !p.consume(LBRACE) {
+		return nil, p.error(fmt.Sprintf("expected '{' to open function '%s'", function.Name))
+	}
+
+	// let bindings seguidos de un único return
+	for p.check(LET) {
+		binding, err := p.parseLetBinding()
+		if err != nil {
+			return nil, err
+		}
+		function.Bindings = append(function.Bindings, binding)
+	}
+
+	if !p.consume(RETURN) {
+		return nil, p.error(fmt.Sprintf("expected 'return' in function '%s'", function.Name))
+	}
+	expression, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	function.Return = expression
+	p.consume(SEMICOLON) // Opcional antes de '}'
+
+	if !p.consume(RBRACE) {
+		return nil, p.error(fmt.Sprintf("unclosed function '%s' - expected '}' after return", function.Name))
+	}
+
+	return function, nil
+}
+
+// parseLetBinding parsea "let name = expr;"
+func (p *ModernParser) parseLetBinding() (*domain.LetBinding, error) {
+	if !p.consume(LET) {
+		return nil, p.error("expected 'let'")
+	}
+
+	if !p.check(IDENTIFIER) || strings.Contains(p.peek().Value, ".") {
+		return nil, p.error("expected variable name after 'let'")
+	}
+	name := p.advance().Value
+
+	if !p.check(EQUALS) || p.peek().Value != "=" {
+		return nil, p.error(fmt.Sprintf("expected '=' after 'let %s'", name))
+	}
+	p.advance()
+
+	expression, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(SEMICOLON) {
+		return nil, p.error(fmt.Sprintf("expected ';' after 'let %s'", name))
+	}
+
+	return &domain.LetBinding{Name: name, Expression: expression}, nil
+}
+
 func (p *ModernParser) parseCondition() (string, error) {
 	// Parse complex conditions with proper tokenization
 	condition := ""
@@ -921,7 +1068,7 @@ func (p *ModernParser) parseCondition() (string, error) {
 			condition += " "
 		}
 
-		condition += p.advance().Value
+		condition += tokenText(p.advance())
 	}
 	if condition == "" {
 		// Provide better error context
@@ -937,6 +1084,17 @@ func (p *ModernParser) parseCondition() (string, error) {
 	return strings.TrimSpace(condition), nil
 }
 
+// tokenText returns the source text of a token, restoring the quotes of string literals
+func tokenText(token Token) string {
+	if token.Type != STRING {
+		return token.Value
+	}
+	if strings.Contains(token.Value, "'") {
+		return `"` + token.Value + `"`
+	}
+	return "'" + token.Value + "'"
+}
+
 // shouldOmitSpace determines if space should be omitted between two token types
 func shouldOmitSpace(prevType, currType TokenType) bool {
 	// No space after dots
@@ -1018,6 +1176,8 @@ func (p *ModernParser) validateSemantics(ruleset *domain.FirestoreRuleset) error
 		})
 	}
 
+	p.validateFunctions(ruleset.Functions)
+
 	// Validar paths y variables
 	for _, match := range ruleset.Matches {
 		if err := p.validateMatchBlock(match); err != nil {
@@ -1038,6 +1198,8 @@ func (p *ModernParser) validateMatchBlock(block *domain.MatchBlock) error {
 		})
 	}
 
+	p.validateFunctions(block.Functions)
+
 	// Validar nested blocks
 	for _, nested := range block.Nested {
 		if err := p.validateMatchBlock(nested); err != nil {
@@ -1048,6 +1210,21 @@ func (p *ModernParser) validateMatchBlock(block *domain.MatchBlock) error {
 	return nil
 }
 
+// validateFunctions reporta funciones declaradas dos veces en el mismo scope
+func (p *ModernParser) validateFunctions(functions []*domain.FunctionDeclaration) {
+	declared := make(map[string]bool, len(functions))
+	for _, function := range functions {
+		if declared[function.Name] {
+			p.errors = append(p.errors, domain.ParseError{
+				Line:    function.Line,
+				Message: fmt.Sprintf("Function '%s' is already declared in this scope", function.Name),
+				Type:    "semantic",
+			})
+		}
+		declared[function.Name] = true
+	}
+}
+
 // Validate implementa validación sin parsing completo
 func (p *ModernParser) Validate(ctx context.Context, content io.Reader) ([]domain.ParseError, error) {
 	result, err := p.Parse(ctx, content)
@@ -8,6 +8,7 @@ import (
 
 	"firestore-clone/internal/firestore/domain/repository"
 	"firestore-clone/internal/rules_translator/domain"
+	sharederrors "firestore-clone/internal/shared/errors"
 )
 
 // RulesDeployer implementa despliegue seguro y eficiente de reglas
@@ -18,6 +19,8 @@ type RulesDeployer struct {
 	config         *DeployerConfig
 }
 
+var _ domain.RulesDeployer = (*RulesDeployer)(nil)
+
 type DeployerConfig struct {
 	EnableValidation   bool          `json:"enable_validation"`
 	EnableRollback     bool          `json:"enable_rollback"`
@@ -33,12 +36,22 @@ type RulesValidator interface {
 	ValidateAgainstCurrent(ctx context.Context, newRules, currentRules []*repository.SecurityRule) error
 }
 
+// DeployHistoryStore guarda los rulesets desplegados y las releases que los publican.
+// Los getters devuelven nil sin error cuando no hay nada guardado.
 type DeployHistoryStore interface {
 	SaveDeployment(ctx context.Context, projectID, databaseID string, deployment *domain.DeployHistory) error
 	GetHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*domain.DeployHistory, error)
 	GetLastDeployment(ctx context.Context, projectID, databaseID string) (*domain.DeployHistory, error)
+	GetDeployment(ctx context.Context, projectID, databaseID, version string) (*domain.DeployHistory, error)
+
+	SaveRelease(ctx context.Context, release *domain.RulesRelease) error
+	GetRelease(ctx context.Context, projectID, databaseID string) (*domain.RulesRelease, error)
+	GetReleaseHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*domain.RulesRelease, error)
 }
 
+// defaultDeployer es el usuario al que se atribuyen los despliegues sin usuario
+const defaultDeployer = "rules-importer"
+
 // NewRulesDeployer crea una nueva instancia del deployer
 func NewRulesDeployer(
 	securityEngine repository.SecurityRulesEngine,
@@ -87,7 +100,16 @@ func (d *RulesDeployer) Deploy(ctx context.Context, projectID, databaseID string
 
 // DeployWithValidation despliega con validación completa
 func (d *RulesDeployer) DeployWithValidation(ctx context.Context, projectID, databaseID string, rules interface{}) (*domain.DeployResult, error) {
+	return d.DeployRuleset(ctx, projectID, databaseID, "", rules, defaultDeployer)
+}
+
+// DeployRuleset despliega con validación completa y guarda el ruleset, con su texto
+// original, como la nueva release atribuida al usuario
+func (d *RulesDeployer) DeployRuleset(ctx context.Context, projectID, databaseID, source string, rules interface{}, user string) (*domain.DeployResult, error) {
 	startTime := time.Now()
+	if user == "" {
+		user = defaultDeployer
+	}
 
 	securityRules, ok := rules.([]*repository.SecurityRule)
 	if !ok {
@@ -147,18 +169,23 @@ func (d *RulesDeployer) DeployWithValidation(ctx context.Context, projectID, dat
 		}
 	}
 
-	// 5. Guardar en historial
+	// 5. Guardar el ruleset en el historial y publicarlo
 	if d.history != nil {
+		now := time.Now()
 		deployment := &domain.DeployHistory{
 			Version:    result.Version,
-			DeployedAt: time.Now(),
-			DeployedBy: "rules-importer", // TODO: obtener del contexto
+			DeployedAt: now,
+			DeployedBy: user,
 			RulesCount: len(securityRules),
 			Status:     "success",
+			Source:     source,
+			Rules:      securityRules,
 		}
 
 		if err := d.history.SaveDeployment(ctx, projectID, databaseID, deployment); err != nil {
 			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to save deployment history: %v", err))
+		} else if err := d.history.SaveRelease(ctx, newRelease(projectID, databaseID, result.Version, user, now)); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to save release: %v", err))
 		}
 	}
 
@@ -168,25 +195,34 @@ func (d *RulesDeployer) DeployWithValidation(ctx context.Context, projectID, dat
 	return result, nil
 }
 
-// Rollback revierte al conjunto de reglas anterior
-func (d *RulesDeployer) Rollback(ctx context.Context, projectID, databaseID string) error {
+// Release publica un ruleset guardado: lo despliega en el motor de seguridad y mueve la
+// release a su versión
+func (d *RulesDeployer) Release(ctx context.Context, projectID, databaseID, version, user string) (*domain.RulesRelease, error) {
+	return d.release(ctx, projectID, databaseID, version, user, "")
+}
+
+// Rollback vuelve a publicar el ruleset que estaba activo antes de la release actual
+func (d *RulesDeployer) Rollback(ctx context.Context, projectID, databaseID, user string) (*domain.RulesRelease, error) {
 	if !d.config.EnableRollback || d.history == nil {
-		return fmt.Errorf("rollback not enabled")
+		return nil, fmt.Errorf("rollback not enabled")
 	}
 
-	// Obtener último despliegue exitoso
-	lastDeploy, err := d.history.GetLastDeployment(ctx, projectID, databaseID)
+	releases, err := d.history.GetReleaseHistory(ctx, projectID, databaseID, 0)
 	if err != nil {
-		return fmt.Errorf("failed to get deployment history: %w", err)
+		return nil, fmt.Errorf("failed to get release history: %w", err)
 	}
-
-	if lastDeploy == nil {
-		return fmt.Errorf("no previous deployment found")
+	if len(releases) == 0 {
+		return nil, sharederrors.NewNotFoundError("rules release")
 	}
 
-	// TODO: Implementar lógica para obtener reglas del historial
-	// Por ahora retorna error indicando que se necesita implementación adicional
-	return fmt.Errorf("rollback implementation requires rules storage in history")
+	// La release anterior es la última que publicó una versión distinta de la actual
+	current := releases[len(releases)-1]
+	for i := len(releases) - 2; i >= 0; i-- {
+		if releases[i].Version != current.Version {
+			return d.release(ctx, projectID, databaseID, releases[i].Version, user, current.Version)
+		}
+	}
+	return nil, sharederrors.NewValidationError("no previous release to roll back to")
 }
 
 // GetCurrentVersion obtiene la versión actual de reglas
@@ -195,6 +231,14 @@ func (d *RulesDeployer) GetCurrentVersion(ctx context.Context, projectID, databa
 		return "", fmt.Errorf("history store not available")
 	}
 
+	release, err := d.history.GetRelease(ctx, projectID, databaseID)
+	if err != nil {
+		return "", err
+	}
+	if release != nil {
+		return release.Version, nil
+	}
+
 	lastDeploy, err := d.history.GetLastDeployment(ctx, projectID, databaseID)
 	if err != nil {
 		return "", err
@@ -220,6 +264,48 @@ func (d *RulesDeployer) GetDeployHistory(ctx context.Context, projectID, databas
 	return d.history.GetHistory(ctx, projectID, databaseID, limit)
 }
 
+// GetRuleset obtiene un ruleset guardado por su versión
+func (d *RulesDeployer) GetRuleset(ctx context.Context, projectID, databaseID, version string) (*domain.DeployHistory, error) {
+	if d.history == nil {
+		return nil, fmt.Errorf("history store not available")
+	}
+
+	ruleset, err := d.history.GetDeployment(ctx, projectID, databaseID, version)
+	if err != nil {
+		return nil, err
+	}
+	if ruleset == nil {
+		return nil, sharederrors.NewNotFoundError("ruleset")
+	}
+	return ruleset, nil
+}
+
+// GetRelease obtiene la release actual de la base de datos
+func (d *RulesDeployer) GetRelease(ctx context.Context, projectID, databaseID string) (*domain.RulesRelease, error) {
+	if d.history == nil {
+		return nil, fmt.Errorf("history store not available")
+	}
+	return d.history.GetRelease(ctx, projectID, databaseID)
+}
+
+// GetReleaseHistory obtiene las últimas releases, de la más antigua a la más reciente
+func (d *RulesDeployer) GetReleaseHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*domain.RulesRelease, error) {
+	if d.history == nil {
+		return nil, fmt.Errorf("history store not available")
+	}
+
+	if limit <= 0 {
+		limit = d.config.MaxHistoryEntries
+	}
+
+	return d.history.GetReleaseHistory(ctx, projectID, databaseID, limit)
+}
+
+// GetCurrentRules obtiene las reglas desplegadas actualmente en el motor de seguridad
+func (d *RulesDeployer) GetCurrentRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
+	return d.getCurrentRules(ctx, projectID, databaseID)
+}
+
 // Helper methods
 
 func (d *RulesDeployer) validateBeforeDeploy(ctx context.Context, projectID, databaseID string, rules []*repository.SecurityRule, result *domain.DeployResult) error {
@@ -231,6 +317,23 @@ func (d *RulesDeployer) validateBeforeDeploy(ctx context.Context, projectID, dat
 		return fmt.Errorf("rules validation failed: %w", err)
 	}
 
+	// Las advertencias de seguridad no impiden el despliegue, ni los errores aceptados
+	// explícitamente, que quedan en el resultado para la auditoría
+	if linter, ok := d.validator.(domain.RulesLinter); ok {
+		findings, err := linter.Lint(validationCtx, rules)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("could not lint rules: %v", err))
+		}
+		for _, finding := range findings {
+			switch {
+			case finding.Severity == domain.LintSeverityWarning:
+				result.Warnings = append(result.Warnings, finding.String())
+			case finding.Severity == domain.LintSeverityError && domain.InsecureRulesAllowed(ctx):
+				result.Warnings = append(result.Warnings, "insecure rules allowed: "+finding.String())
+			}
+		}
+	}
+
 	// Validación contra reglas actuales
 	currentRules, err := d.getCurrentRules(validationCtx, projectID, databaseID)
 	if err != nil {
@@ -266,28 +369,78 @@ func (d *RulesDeployer) backupCurrentRules(ctx context.Context, projectID, datab
 }
 
 func (d *RulesDeployer) getCurrentRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
-	// TODO: Implementar método para obtener reglas actuales del SecurityRulesEngine
-	// Por ahora retorna slice vacío
-	return make([]*repository.SecurityRule, 0), nil
+	return d.securityEngine.LoadRules(ctx, projectID, databaseID)
+}
+
+// release despliega las reglas de un ruleset guardado y guarda la release que lo publica
+func (d *RulesDeployer) release(ctx context.Context, projectID, databaseID, version, user, rollbackOf string) (*domain.RulesRelease, error) {
+	ruleset, err := d.GetRuleset(ctx, projectID, databaseID, version)
+	if err != nil {
+		return nil, err
+	}
+	securityRules, ok := ruleset.Rules.([]*repository.SecurityRule)
+	if !ok {
+		return nil, fmt.Errorf("ruleset %s has no stored rules", version)
+	}
+	if user == "" {
+		user = defaultDeployer
+	}
+
+	deployCtx, cancel := context.WithTimeout(ctx, d.config.DeployTimeout)
+	defer cancel()
+	if err := d.securityEngine.SaveRules(deployCtx, projectID, databaseID, securityRules); err != nil {
+		return nil, fmt.Errorf("deploy failed: %w", err)
+	}
+
+	release := newRelease(projectID, databaseID, version, user, time.Now())
+	release.RollbackOf = rollbackOf
+	if err := d.history.SaveRelease(ctx, release); err != nil {
+		return nil, fmt.Errorf("failed to save release: %w", err)
+	}
+	return release, nil
+}
+
+func newRelease(projectID, databaseID, version, user string, releasedAt time.Time) *domain.RulesRelease {
+	return &domain.RulesRelease{
+		Name:       domain.FirestoreReleaseName,
+		ProjectID:  projectID,
+		DatabaseID: databaseID,
+		Version:    version,
+		ReleasedAt: releasedAt,
+		ReleasedBy: user,
+	}
 }
 
 func (d *RulesDeployer) performRollback(ctx context.Context, projectID, databaseID string, backupRules []*repository.SecurityRule) error {
 	return d.securityEngine.SaveRules(ctx, projectID, databaseID, backupRules)
 }
 
+// generateDeployVersion genera la versión de un ruleset; los nanosegundos evitan que dos
+// despliegues del mismo segundo compartan versión
 func generateDeployVersion() string {
-	return fmt.Sprintf("deploy-%d", time.Now().Unix())
+	return fmt.Sprintf("deploy-%d", time.Now().UnixNano())
+}
+
+// SimpleValidator implementación básica de RulesValidator, que además revisa la seguridad
+// de las reglas con el linter
+type SimpleValidator struct {
+	linter domain.RulesLinter
 }
 
-// SimpleValidator implementación básica de RulesValidator
-type SimpleValidator struct{}
+var _ domain.RulesLinter = (*SimpleValidator)(nil)
 
 // NewSimpleValidator crea un validador básico
 func NewSimpleValidator() *SimpleValidator {
-	return &SimpleValidator{}
+	return &SimpleValidator{linter: NewRulesLinter()}
 }
 
-// ValidateRules valida reglas básicamente
+// Lint implementa domain.RulesLinter con el linter del validador
+func (v *SimpleValidator) Lint(ctx context.Context, rules interface{}) ([]domain.LintFinding, error) {
+	return v.linter.Lint(ctx, rules)
+}
+
+// ValidateRules valida reglas básicamente; los hallazgos de seguridad de severidad error
+// invalidan las reglas salvo que el contexto los acepte con domain.WithInsecureRulesAllowed
 func (v *SimpleValidator) ValidateRules(ctx context.Context, rules []*repository.SecurityRule) error {
 	for i, rule := range rules {
 		if rule.Match == "" {
@@ -312,6 +465,17 @@ func (v *SimpleValidator) ValidateRules(ctx context.Context, rules []*repository
 		}
 	}
 
+	if domain.InsecureRulesAllowed(ctx) {
+		return nil
+	}
+	findings, err := v.Lint(ctx, rules)
+	if err != nil {
+		return err
+	}
+	if problems := domain.LintErrors(findings); len(problems) > 0 {
+		return domain.InsecureRulesError(problems)
+	}
+
 	return nil
 }
 
@@ -330,9 +494,11 @@ func (v *SimpleValidator) ValidateAgainstCurrent(ctx context.Context, newRules,
 	return nil
 }
 
-// MemoryHistoryStore implementación en memoria de DeployHistoryStore
+// MemoryHistoryStore implementación en memoria de DeployHistoryStore. El historial se
+// pierde al reiniciar; los servidores usan el store de MongoDB.
 type MemoryHistoryStore struct {
 	deployments map[string][]*domain.DeployHistory
+	releases    map[string][]*domain.RulesRelease
 	mutex       sync.RWMutex
 }
 
@@ -340,6 +506,7 @@ type MemoryHistoryStore struct {
 func NewMemoryHistoryStore() *MemoryHistoryStore {
 	return &MemoryHistoryStore{
 		deployments: make(map[string][]*domain.DeployHistory),
+		releases:    make(map[string][]*domain.RulesRelease),
 	}
 }
 
@@ -397,3 +564,64 @@ func (h *MemoryHistoryStore) GetLastDeployment(ctx context.Context, projectID, d
 
 	return deployments[len(deployments)-1], nil
 }
+
+// GetDeployment obtiene un despliegue por su versión
+func (h *MemoryHistoryStore) GetDeployment(ctx context.Context, projectID, databaseID, version string) (*domain.DeployHistory, error) {
+	key := fmt.Sprintf("%s:%s", projectID, databaseID)
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, deployment := range h.deployments[key] {
+		if deployment.Version == version {
+			return deployment, nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveRelease añade una release al historial; la última es la release actual
+func (h *MemoryHistoryStore) SaveRelease(ctx context.Context, release *domain.RulesRelease) error {
+	key := fmt.Sprintf("%s:%s", release.ProjectID, release.DatabaseID)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.releases[key] = append(h.releases[key], release)
+
+	return nil
+}
+
+// GetRelease obtiene la release actual
+func (h *MemoryHistoryStore) GetRelease(ctx context.Context, projectID, databaseID string) (*domain.RulesRelease, error) {
+	key := fmt.Sprintf("%s:%s", projectID, databaseID)
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	releases := h.releases[key]
+	if len(releases) == 0 {
+		return nil, nil
+	}
+
+	return releases[len(releases)-1], nil
+}
+
+// GetReleaseHistory obtiene las últimas 'limit' releases, todas si limit no es positivo
+func (h *MemoryHistoryStore) GetReleaseHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*domain.RulesRelease, error) {
+	key := fmt.Sprintf("%s:%s", projectID, databaseID)
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	releases := h.releases[key]
+	start := 0
+	if limit > 0 && len(releases) > limit {
+		start = len(releases) - limit
+	}
+
+	result := make([]*domain.RulesRelease, len(releases)-start)
+	copy(result, releases[start:])
+
+	return result, nil
+}
@@ -0,0 +1,422 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/domain"
+)
+
+// documentsPrefix es el prefijo de los match traducidos; los hallazgos muestran la ruta sin él
+const documentsPrefix = "/databases/{database}/documents"
+
+// lintOperationOrder es el orden en que se muestran las operaciones de un hallazgo
+var lintOperationOrder = []repository.OperationType{
+	repository.OperationRead, repository.OperationList,
+	repository.OperationCreate, repository.OperationUpdate, repository.OperationDelete, repository.OperationWrite,
+}
+
+var (
+	// authNullCheck es la comprobación de que la petición está autenticada
+	authNullCheck = regexp.MustCompile(`request\.auth(\.uid)?\s*!=\s*null|null\s*!=\s*request\.auth(\.uid)?`)
+	// userWildcardName reconoce los wildcards que identifican a un usuario, como {userId}
+	userWildcardName = regexp.MustCompile(`(?i)^(uid|user.*|owner.*|author.*)$`)
+)
+
+// RulesLinter revisa la seguridad de las reglas traducidas. Las condiciones ya tienen las
+// funciones expandidas, así que las comprobaciones ven lo que evalúa el motor.
+type RulesLinter struct{}
+
+var _ domain.RulesLinter = (*RulesLinter)(nil)
+
+// NewRulesLinter crea el linter de seguridad de reglas
+func NewRulesLinter() *RulesLinter {
+	return &RulesLinter{}
+}
+
+// lintStatement son las operaciones que un allow o deny del archivo concede o deniega
+// con una misma condición
+type lintStatement struct {
+	rule       *repository.SecurityRule
+	effect     string
+	condition  string
+	operations []repository.OperationType
+	line       int
+	column     int
+}
+
+// Lint implementa domain.RulesLinter. Los hallazgos se ordenan por su posición en el archivo.
+func (l *RulesLinter) Lint(ctx context.Context, rules interface{}) ([]domain.LintFinding, error) {
+	securityRules, ok := rules.([]*repository.SecurityRule)
+	if !ok {
+		return nil, fmt.Errorf("invalid rules type")
+	}
+
+	findings := make([]domain.LintFinding, 0)
+	for i, rule := range securityRules {
+		// Las condiciones de un match inalcanzable no se evalúan nunca
+		if finding, unreachable := l.checkUnreachableMatch(rule); unreachable {
+			findings = append(findings, finding)
+			continue
+		}
+		for _, stmt := range lintStatements(rule, "allow", rule.Allow, rule.AllowLines, rule.AllowColumns) {
+			findings = append(findings, l.checkAllow(securityRules, i, stmt)...)
+		}
+		for _, stmt := range lintStatements(rule, "deny", rule.Deny, rule.DenyLines, rule.DenyColumns) {
+			findings = append(findings, l.checkDeny(securityRules, i, stmt)...)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings, nil
+}
+
+// checkUnreachableMatch detecta match que apuntan a una colección o a la raíz de la base
+// de datos: las peticiones son siempre sobre documentos
+func (l *RulesLinter) checkUnreachableMatch(rule *repository.SecurityRule) (domain.LintFinding, bool) {
+	if !strings.HasPrefix(rule.Match, documentsPrefix) {
+		return domain.LintFinding{}, false
+	}
+	segments := documentSegments(rule.Match)
+	for _, segment := range segments {
+		if isRecursiveWildcard(segment) {
+			return domain.LintFinding{}, false
+		}
+	}
+	if len(segments) > 0 && len(segments)%2 == 0 {
+		return domain.LintFinding{}, false
+	}
+
+	match := displayMatch(rule.Match)
+	return domain.LintFinding{
+		Check:    domain.LintUnreachableMatch,
+		Severity: domain.LintSeverityWarning,
+		Match:    match,
+		Line:     rule.Line,
+		Column:   rule.Column,
+		Message:  fmt.Sprintf("match %s points to a collection, not to its documents, so it never matches a request", match),
+		Fix:      fmt.Sprintf("Match the documents of the collection: match %s/{docId}", strings.TrimSuffix(match, "/")),
+	}, true
+}
+
+// checkAllow revisa un allow
+func (l *RulesLinter) checkAllow(rules []*repository.SecurityRule, index int, stmt *lintStatement) []domain.LintFinding {
+	var findings []domain.LintFinding
+	rule := stmt.rule
+	match := displayMatch(rule.Match)
+	open := isConstantCondition(stmt.condition, "true")
+	writes := stmt.filter(repository.OperationCreate, repository.OperationUpdate, repository.OperationDelete, repository.OperationWrite)
+
+	switch {
+	case open && len(writes) > 0:
+		findings = append(findings, stmt.finding(domain.LintPublicWrite, domain.LintSeverityError,
+			fmt.Sprintf("anyone, signed in or not, can %s documents at %s", operationList(stmt.operations), match),
+			"Require a signed-in user and check ownership, e.g. request.auth != null && request.auth.uid == resource.data.owner"))
+	case len(writes) > 0 && isRecursiveMatch(rule.Match):
+		findings = append(findings, stmt.finding(domain.LintRecursiveWrite, domain.LintSeverityWarning,
+			fmt.Sprintf("%s on %s applies to every document below it, including collections added later", operationList(writes), match),
+			"Match the collections that need writes explicitly and keep the recursive wildcard for reads"))
+	}
+	if open {
+		if stmt.has(repository.OperationList) {
+			findings = append(findings, stmt.unboundedList(match))
+		}
+		return findings
+	}
+
+	if wildcard := userWildcard(rule.Match); wildcard != "" && authNullCheck.MatchString(stmt.condition) && !hasIdentityCheck(stmt.condition, wildcard) {
+		findings = append(findings, stmt.finding(domain.LintAuthWithoutOwnership, domain.LintSeverityWarning,
+			fmt.Sprintf("any signed-in user can %s the documents of every user at %s: the condition checks request.auth but not that request.auth.uid is {%s}",
+				operationList(stmt.operations), match, wildcard),
+			fmt.Sprintf("Add request.auth.uid == %s to the condition", wildcard)))
+	}
+
+	if shadowed, by := shadowingAllow(rules, index, stmt.operations, false); len(shadowed) > 0 {
+		findings = append(findings, stmt.finding(domain.LintShadowedAllow, domain.LintSeverityWarning,
+			fmt.Sprintf("the condition never restricts %s: match %s (line %d) allows it without conditions on every document this match covers",
+				operationList(shadowed), displayMatch(by.Match), by.Line),
+			fmt.Sprintf("Remove the unconditional allow from match %s or narrow that match", displayMatch(by.Match))))
+	}
+
+	if unvalidated := stmt.filter(repository.OperationCreate, repository.OperationUpdate); len(unvalidated) > 0 && !strings.Contains(stmt.condition, "request.resource.data") {
+		findings = append(findings, stmt.finding(domain.LintUnvalidatedWrite, domain.LintSeverityInfo,
+			fmt.Sprintf("%s on %s accepts documents with any fields and values", operationList(unvalidated), match),
+			"Validate the written data, e.g. request.resource.data.keys().hasOnly(['title', 'owner']) && request.resource.data.title is string"))
+	}
+
+	if stmt.has(repository.OperationList) && isUnboundedList(rule.Match, stmt.condition) {
+		findings = append(findings, stmt.unboundedList(match))
+	}
+	return findings
+}
+
+// checkDeny revisa un deny. El motor evalúa las reglas por prioridad y se detiene en el
+// primer allow que se cumple, así que un deny detrás de un allow sin condición que cubre
+// su match no se evalúa nunca.
+func (l *RulesLinter) checkDeny(rules []*repository.SecurityRule, index int, stmt *lintStatement) []domain.LintFinding {
+	if isConstantCondition(stmt.condition, "false") {
+		return []domain.LintFinding{stmt.finding(domain.LintUnreachableDeny, domain.LintSeverityWarning,
+			fmt.Sprintf("deny %s on %s never applies: its condition is always false", operationList(stmt.operations), displayMatch(stmt.rule.Match)),
+			"Remove the deny or fix its condition")}
+	}
+
+	shadowed, by := shadowingAllow(rules, index, stmt.operations, true)
+	if len(shadowed) == 0 {
+		return nil
+	}
+	return []domain.LintFinding{stmt.finding(domain.LintUnreachableDeny, domain.LintSeverityError,
+		fmt.Sprintf("deny %s on %s is never evaluated: match %s (line %d) is checked first and allows it without conditions",
+			operationList(shadowed), displayMatch(stmt.rule.Match), displayMatch(by.Match), by.Line),
+		fmt.Sprintf("Remove the unconditional allow from match %s or narrow that match", displayMatch(by.Match)))}
+}
+
+// shadowingAllow devuelve las operaciones que otra regla que cubre el match de la regla
+// index permite sin condición, y la primera de esas reglas. Con evaluatedFirst solo
+// cuentan las reglas que el motor evalúa antes.
+func shadowingAllow(rules []*repository.SecurityRule, index int, operations []repository.OperationType, evaluatedFirst bool) ([]repository.OperationType, *repository.SecurityRule) {
+	var shadowed []repository.OperationType
+	var by *repository.SecurityRule
+	for _, op := range operations {
+		for j, other := range rules {
+			if j == index || (evaluatedFirst && !evaluatedBefore(rules, j, index)) {
+				continue
+			}
+			if !isConstantCondition(other.Allow[op], "true") || !matchCovers(splitMatch(other.Match), splitMatch(rules[index].Match)) {
+				continue
+			}
+			shadowed = append(shadowed, op)
+			if by == nil {
+				by = other
+			}
+			break
+		}
+	}
+	return shadowed, by
+}
+
+// evaluatedBefore indica si el motor evalúa la regla i antes que la j: primero las de
+// mayor prioridad
+func evaluatedBefore(rules []*repository.SecurityRule, i, j int) bool {
+	if rules[i].Priority != rules[j].Priority {
+		return rules[i].Priority > rules[j].Priority
+	}
+	return i < j
+}
+
+// matchCovers indica si el patrón general coincide con todas las rutas del específico
+func matchCovers(general, specific []string) bool {
+	if len(general) == 0 {
+		return len(specific) == 0
+	}
+	if isRecursiveWildcard(general[0]) {
+		// {name=**} coincide con uno o más segmentos
+		for consumed := 1; consumed <= len(specific); consumed++ {
+			if matchCovers(general[1:], specific[consumed:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(specific) == 0 || isRecursiveWildcard(specific[0]) {
+		return false
+	}
+	if isWildcard(general[0]) || general[0] == specific[0] {
+		return matchCovers(general[1:], specific[1:])
+	}
+	return false
+}
+
+// isUnboundedList indica si una condición list deja leer la colección entera: no exige
+// un límite, ni un filtro sobre los datos, ni depende de los wildcards de la ruta
+func isUnboundedList(match, condition string) bool {
+	if strings.Contains(condition, "request.query.limit") || strings.Contains(condition, "resource.data") {
+		return false
+	}
+	for _, segment := range splitMatch(match) {
+		if isWildcard(segment) && !isRecursiveWildcard(segment) && segment != "{database}" &&
+			containsIdentifier(condition, wildcardName(segment)) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasIdentityCheck indica si la condición compara el usuario autenticado con algo más que
+// null, o usa el wildcard del usuario
+func hasIdentityCheck(condition, wildcard string) bool {
+	withoutNullChecks := authNullCheck.ReplaceAllString(condition, "")
+	return strings.Contains(withoutNullChecks, "request.auth") || containsIdentifier(condition, wildcard)
+}
+
+// userWildcard devuelve el wildcard que identifica al usuario dueño de los documentos del
+// match, como userId en /users/{userId}/posts/{postId}
+func userWildcard(match string) string {
+	segments := documentSegments(match)
+	for i, segment := range segments {
+		if !isWildcard(segment) || isRecursiveWildcard(segment) {
+			continue
+		}
+		name := wildcardName(segment)
+		if userWildcardName.MatchString(name) || (i > 0 && segments[i-1] == "users") {
+			return name
+		}
+	}
+	return ""
+}
+
+// lintStatements agrupa las operaciones de una regla por el allow o deny del que vienen
+func lintStatements(rule *repository.SecurityRule, effect string, conditions map[repository.OperationType]string, lines, columns map[repository.OperationType]int) []*lintStatement {
+	var statements []*lintStatement
+	for _, op := range sortedOperations(conditions) {
+		line, column := lines[op], columns[op]
+		if line == 0 {
+			line, column = rule.Line, rule.Column
+		}
+
+		var stmt *lintStatement
+		for _, existing := range statements {
+			if existing.line == line && existing.column == column && existing.condition == conditions[op] {
+				stmt = existing
+				break
+			}
+		}
+		if stmt == nil {
+			stmt = &lintStatement{rule: rule, effect: effect, condition: conditions[op], line: line, column: column}
+			statements = append(statements, stmt)
+		}
+		stmt.operations = append(stmt.operations, op)
+	}
+	return statements
+}
+
+// sortedOperations devuelve las operaciones de las condiciones en el orden de lintOperationOrder
+func sortedOperations(conditions map[repository.OperationType]string) []repository.OperationType {
+	operations := make([]repository.OperationType, 0, len(conditions))
+	for op := range conditions {
+		operations = append(operations, op)
+	}
+	position := func(op repository.OperationType) int {
+		for i, ordered := range lintOperationOrder {
+			if op == ordered {
+				return i
+			}
+		}
+		return len(lintOperationOrder)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if position(operations[i]) != position(operations[j]) {
+			return position(operations[i]) < position(operations[j])
+		}
+		return operations[i] < operations[j]
+	})
+	return operations
+}
+
+func (s *lintStatement) has(operation repository.OperationType) bool {
+	return len(s.filter(operation)) > 0
+}
+
+// filter devuelve las operaciones del statement que están entre las dadas
+func (s *lintStatement) filter(operations ...repository.OperationType) []repository.OperationType {
+	var filtered []repository.OperationType
+	for _, op := range s.operations {
+		for _, wanted := range operations {
+			if op == wanted {
+				filtered = append(filtered, op)
+			}
+		}
+	}
+	return filtered
+}
+
+func (s *lintStatement) finding(check, severity, message, fix string) domain.LintFinding {
+	operations := make([]string, len(s.operations))
+	for i, op := range s.operations {
+		operations[i] = string(op)
+	}
+	return domain.LintFinding{
+		Check:      check,
+		Severity:   severity,
+		Match:      displayMatch(s.rule.Match),
+		Operations: operations,
+		Line:       s.line,
+		Column:     s.column,
+		Message:    message,
+		Fix:        fix,
+	}
+}
+
+func (s *lintStatement) unboundedList(match string) domain.LintFinding {
+	finding := s.finding(domain.LintUnboundedList, domain.LintSeverityWarning,
+		fmt.Sprintf("list lets clients read every document of %s in a single query", match),
+		"Require request.query.limit <= 100 or a filter on the data, e.g. resource.data.owner == request.auth.uid")
+	finding.Operations = []string{string(repository.OperationList)}
+	return finding
+}
+
+// isConstantCondition indica si la condición es el literal dado, con o sin paréntesis
+func isConstantCondition(condition, literal string) bool {
+	return strings.Trim(strings.Join(strings.Fields(condition), ""), "()") == literal
+}
+
+func containsIdentifier(condition, identifier string) bool {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(identifier) + `\b`).MatchString(condition)
+}
+
+func operationList(operations []repository.OperationType) string {
+	names := make([]string, len(operations))
+	for i, op := range operations {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
+
+// displayMatch devuelve el match relativo a /databases/{database}/documents, como se escribe
+// en los match anidados del archivo
+func displayMatch(match string) string {
+	if relative := strings.TrimPrefix(match, documentsPrefix); relative != match {
+		if relative == "" {
+			return "/"
+		}
+		return relative
+	}
+	return match
+}
+
+func splitMatch(match string) []string {
+	return strings.Split(strings.Trim(match, "/"), "/")
+}
+
+// documentSegments devuelve los segmentos del match bajo /databases/{database}/documents
+func documentSegments(match string) []string {
+	relative := strings.Trim(strings.TrimPrefix(match, documentsPrefix), "/")
+	if relative == "" {
+		return nil
+	}
+	return strings.Split(relative, "/")
+}
+
+func isRecursiveMatch(match string) bool {
+	return strings.Contains(match, "=**}")
+}
+
+func isWildcard(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+func isRecursiveWildcard(segment string) bool {
+	return isWildcard(segment) && strings.HasSuffix(segment, "=**}")
+}
+
+func wildcardName(segment string) string {
+	return strings.TrimSuffix(strings.Trim(segment, "{}"), "=**")
+}
@@ -180,14 +180,20 @@ func (o *RulesOptimizer) mergeRules(rules []*repository.SecurityRule) *repositor
 
 	// Usar la primera regla como base
 	merged := &repository.SecurityRule{
-		Match:       rules[0].Match,
-		Priority:    rules[0].Priority,
-		Description: fmt.Sprintf("Merged rule from %d rules", len(rules)),
-		Allow:       make(map[repository.OperationType]string),
-		Deny:        make(map[repository.OperationType]string),
-	}
-
-	// Combinar todas las operaciones
+		Match:        rules[0].Match,
+		Priority:     rules[0].Priority,
+		Description:  fmt.Sprintf("Merged rule from %d rules", len(rules)),
+		Allow:        make(map[repository.OperationType]string),
+		Deny:         make(map[repository.OperationType]string),
+		Line:         rules[0].Line,
+		Column:       rules[0].Column,
+		AllowLines:   make(map[repository.OperationType]int),
+		AllowColumns: make(map[repository.OperationType]int),
+		DenyLines:    make(map[repository.OperationType]int),
+		DenyColumns:  make(map[repository.OperationType]int),
+	}
+
+	// Combinar todas las operaciones; cada condición conserva la línea de la primera regla
 	for _, rule := range rules {
 		for op, condition := range rule.Allow {
 			// Usar la condición más permisiva (OR lógico)
@@ -195,6 +201,8 @@ func (o *RulesOptimizer) mergeRules(rules []*repository.SecurityRule) *repositor
 				merged.Allow[op] = o.combineConditions(existing, condition, "OR")
 			} else {
 				merged.Allow[op] = condition
+				merged.AllowLines[op] = rule.AllowLines[op]
+				merged.AllowColumns[op] = rule.AllowColumns[op]
 			}
 		}
 
@@ -204,6 +212,8 @@ func (o *RulesOptimizer) mergeRules(rules []*repository.SecurityRule) *repositor
 				merged.Deny[op] = o.combineConditions(existing, condition, "AND")
 			} else {
 				merged.Deny[op] = condition
+				merged.DenyLines[op] = rule.DenyLines[op]
+				merged.DenyColumns[op] = rule.DenyColumns[op]
 			}
 		}
 
@@ -416,15 +426,24 @@ func (o *RulesOptimizer) combineConditions(cond1, cond2, operator string) string
 	if cond1 == cond2 {
 		return cond1
 	}
-	return fmt.Sprintf("(%s) %s (%s)", cond1, strings.ToLower(operator), cond2)
+	celOperator := "||"
+	if operator == "AND" {
+		celOperator = "&&"
+	}
+	return fmt.Sprintf("(%s) %s (%s)", cond1, celOperator, cond2)
 }
 
 func (o *RulesOptimizer) removeRedundantConditions(condition string) string {
-	// Eliminar condiciones como "true && condition" -> "condition"
-	condition = strings.ReplaceAll(condition, "true &&", "")
-	condition = strings.ReplaceAll(condition, "&& true", "")
-	condition = strings.ReplaceAll(condition, "false ||", "")
-	condition = strings.ReplaceAll(condition, "|| false", "")
+	// Eliminar condiciones como "true && condition" -> "condition". Solo se eliminan al
+	// principio y al final, donde la precedencia de && sobre || no cambia el resultado;
+	// en medio de la expresión "x == true && y" no es redundante.
+	condition = strings.TrimSpace(condition)
+	for _, prefix := range []string{"true && ", "false || "} {
+		condition = strings.TrimPrefix(condition, prefix)
+	}
+	for _, suffix := range []string{" && true", " || false"} {
+		condition = strings.TrimSuffix(condition, suffix)
+	}
 	return strings.TrimSpace(condition)
 }
 
@@ -434,9 +453,8 @@ func (o *RulesOptimizer) reorderConditionsBySpeed(condition string) string {
 }
 
 func (o *RulesOptimizer) optimizeExpensiveFunctions(condition string) string {
-	// Optimizar get() y exists() agregando hints de caché
-	condition = strings.ReplaceAll(condition, "get(", "get_cached(")
-	condition = strings.ReplaceAll(condition, "exists(", "exists_cached(")
+	// get() y exists() se mantienen: el motor no tiene variantes con caché, y renombrarlas
+	// también alteraría map.get() y la macro exists() de las listas
 	return condition
 }
 
@@ -552,7 +570,10 @@ func (o *RulesOptimizer) generateRecommendations(rules []*repository.SecurityRul
 // Métodos helper simplificados
 
 func (o *RulesOptimizer) isStaticCondition(condition string) bool {
-	return !strings.Contains(condition, "request.") && !strings.Contains(condition, "resource.")
+	// Solo los literales: una condición sin request ni resource puede depender de
+	// wildcards, auth o get()
+	condition = strings.TrimSpace(condition)
+	return condition == "true" || condition == "false"
 }
 
 func (o *RulesOptimizer) evaluateStaticCondition(condition string) bool {
@@ -0,0 +1,92 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"firestore-clone/internal/rules_translator/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseRulesTestSuite lee una suite de pruebas de reglas en YAML o JSON. JSON es un
+// subconjunto de YAML, así que basta un decodificador; los campos desconocidos son un
+// error para detectar erratas en los casos.
+func ParseRulesTestSuite(content []byte) (*domain.RulesTestSuite, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	var suite domain.RulesTestSuite
+	if err := decoder.Decode(&suite); err != nil {
+		return nil, fmt.Errorf("invalid rules test file: %w", err)
+	}
+	if len(suite.Tests) == 0 {
+		return nil, fmt.Errorf("invalid rules test file: no tests")
+	}
+	return &suite, nil
+}
+
+// Estructura de un reporte JUnit XML, el formato que entienden los servidores de CI
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// FormatJUnitReport convierte el reporte de una suite en JUnit XML
+func FormatJUnitReport(report *domain.RulesTestReport) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:     report.Name,
+		Tests:    report.Total,
+		Failures: report.Failed,
+		Errors:   report.Errors,
+		Time:     fmt.Sprintf("%.3f", report.Duration.Seconds()),
+		Cases:    make([]junitTestCase, 0, len(report.Results)),
+	}
+	for _, result := range report.Results {
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: report.Name,
+			Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+		}
+		switch {
+		case result.Error != "":
+			testCase.Error = &junitProblem{Message: result.Error, Text: result.Error}
+		case !result.Passed:
+			message := fmt.Sprintf("expected %s, got %s", result.Expected, result.Actual)
+			testCase.Failure = &junitProblem{
+				Message: message,
+				Text:    fmt.Sprintf("%s %s: %s (%s)", result.Operation, result.Path, message, result.Reason),
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	output, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(output, '\n')...), nil
+}
@@ -1,31 +1,36 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 )
 
 // FirestoreRuleset representa el contenido completo de un archivo firestore.rules
 // optimizado para velocidad de procesamiento
 type FirestoreRuleset struct {
-	Service   string        `json:"service"`
-	Matches   []*MatchBlock `json:"matches"`
-	CreatedAt time.Time     `json:"created_at"`
-	Version   string        `json:"version"`
+	Service   string                 `json:"service"`
+	Functions []*FunctionDeclaration `json:"functions,omitempty"` // Funciones a nivel de service
+	Matches   []*MatchBlock          `json:"matches"`
+	CreatedAt time.Time              `json:"created_at"`
+	Version   string                 `json:"version"`
 }
 
 // MatchBlock representa un bloque "match /path/{wildcard} { ... }"
 // Optimizado con índices para búsqueda rápida
 type MatchBlock struct {
-	Path         string            `json:"path"`
-	PathSegments []string          `json:"path_segments"` // Pre-procesado para matching rápido
-	Variables    map[string]string `json:"variables"`     // Variables extraídas como {userId}
-	Allow        []*AllowStatement `json:"allow"`
-	Deny         []*DenyStatement  `json:"deny,omitempty"`
-	Nested       []*MatchBlock     `json:"nested,omitempty"`
-	ParentPath   string            `json:"parent_path,omitempty"`
-	FullPath     string            `json:"full_path"` // Ruta completa pre-calculada
-	Depth        int               `json:"depth"`     // Profundidad para prioridad
-	Priority     string            `json:"priority"`  // Prioridad como string para comparación exacta
+	Path         string                 `json:"path"`
+	PathSegments []string               `json:"path_segments"` // Pre-procesado para matching rápido
+	Variables    map[string]string      `json:"variables"`     // Variables extraídas como {userId}
+	Allow        []*AllowStatement      `json:"allow"`
+	Deny         []*DenyStatement       `json:"deny,omitempty"`
+	Functions    []*FunctionDeclaration `json:"functions,omitempty"` // Visibles en este bloque y sus anidados
+	Nested       []*MatchBlock          `json:"nested,omitempty"`
+	ParentPath   string                 `json:"parent_path,omitempty"`
+	FullPath     string                 `json:"full_path"` // Ruta completa pre-calculada
+	Depth        int                    `json:"depth"`     // Profundidad para prioridad
+	Priority     string                 `json:"priority"`  // Prioridad como string para comparación exacta
+	Line         int                    `json:"line"`      // Línea del "match" en el archivo
+	Column       int                    `json:"column"`    // Columna del "match" en el archivo
 }
 
 // AllowStatement representa una línea "allow operation: if condition;"
@@ -33,6 +38,22 @@ type AllowStatement struct {
 	Operations []string `json:"operations"` // ["read", "write", "update", etc.]
 	Condition  string   `json:"condition"`  // La condición "if" como string
 	Line       int      `json:"line"`       // Línea del archivo para debugging
+	Column     int      `json:"column"`
+}
+
+// FunctionDeclaration representa "function name(params) { let x = expr; return expr; }"
+type FunctionDeclaration struct {
+	Name       string        `json:"name"`
+	Parameters []string      `json:"parameters"`
+	Bindings   []*LetBinding `json:"bindings,omitempty"` // Declaraciones let en orden
+	Return     string        `json:"return"`             // Expresión del return como string
+	Line       int           `json:"line"`
+}
+
+// LetBinding representa una declaración "let name = expr;" dentro de una función
+type LetBinding struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
 }
 
 // DenyStatement representa una línea "deny operation: if condition;"
@@ -40,6 +61,7 @@ type DenyStatement struct {
 	Operations []string `json:"operations"`
 	Condition  string   `json:"condition"`
 	Line       int      `json:"line"`
+	Column     int      `json:"column"`
 }
 
 // ParseResult encapsula el resultado del parsing con metadatos de rendimiento
@@ -60,6 +82,14 @@ type ParseError struct {
 	Type    string `json:"type"`
 }
 
+// Error permite devolver un ParseError como error de sintaxis con su posición
+func (e ParseError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s at line %d", e.Message, e.Line)
+}
+
 // ParseWarning representa una advertencia durante el parsing
 type ParseWarning struct {
 	Line    int    `json:"line"`
@@ -77,6 +107,29 @@ type TranslationResult struct {
 	Errors          []string      `json:"errors,omitempty"`
 }
 
+// RulesDiff compara las reglas desplegadas con las reglas a desplegar, condición por
+// condición
+type RulesDiff struct {
+	Added     []*RuleChange `json:"added,omitempty"`
+	Removed   []*RuleChange `json:"removed,omitempty"`
+	Changed   []*RuleChange `json:"changed,omitempty"`
+	Unchanged int           `json:"unchanged"`
+}
+
+// RuleChange representa una condición allow o deny añadida, eliminada o modificada
+type RuleChange struct {
+	Match     string `json:"match"`
+	Effect    string `json:"effect"` // "allow" o "deny"
+	Operation string `json:"operation"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// HasChanges indica si el despliegue modificaría las reglas actuales
+func (d *RulesDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
 // CacheKey representa una clave optimizada para caché de reglas
 type CacheKey struct {
 	ProjectID  string `json:"project_id"`
@@ -107,3 +160,99 @@ type TranslationMetrics struct {
 	ErrorRate            float64       `json:"error_rate"`
 	LastTranslation      time.Time     `json:"last_translation"`
 }
+
+// RulesTestSuite es un archivo de pruebas de reglas, en YAML o JSON
+type RulesTestSuite struct {
+	Name string `json:"name,omitempty" yaml:"name"`
+	// Rules es el archivo .rules probado, relativo al archivo de pruebas
+	Rules string `json:"rules,omitempty" yaml:"rules"`
+	// Documents son los documentos sembrados para todos los casos, por ruta relativa a la base de datos
+	Documents map[string]map[string]interface{} `json:"documents,omitempty" yaml:"documents"`
+	Tests     []*RulesTestCase                  `json:"tests" yaml:"tests"`
+	// Coverage añade al informe la cobertura de las reglas por los casos
+	Coverage bool `json:"coverage,omitempty" yaml:"coverage"`
+}
+
+// RulesTestCase es una petición y la decisión esperada de las reglas
+type RulesTestCase struct {
+	Name string `json:"name,omitempty" yaml:"name"`
+	// Auth es request.auth (uid y token); nil para peticiones no autenticadas
+	Auth      map[string]interface{} `json:"auth,omitempty" yaml:"auth"`
+	Operation string                 `json:"operation" yaml:"operation"`
+	Path      string                 `json:"path" yaml:"path"`
+	// Documents se suman a los documentos sembrados de la suite; null borra un documento
+	Documents map[string]map[string]interface{} `json:"documents,omitempty" yaml:"documents"`
+	// Data es request.resource.data, el documento tras la escritura
+	Data   map[string]interface{} `json:"data,omitempty" yaml:"data"`
+	Expect string                 `json:"expect" yaml:"expect"` // "allow" o "deny"
+}
+
+// Decisiones esperadas de un caso de prueba
+const (
+	RulesTestAllow = "allow"
+	RulesTestDeny  = "deny"
+)
+
+// RulesTestResult es el resultado de un caso de prueba
+type RulesTestResult struct {
+	Name      string        `json:"name"`
+	Operation string        `json:"operation"`
+	Path      string        `json:"path"`
+	Expected  string        `json:"expected"`
+	Actual    string        `json:"actual,omitempty"` // Vacío si el caso no se pudo evaluar
+	Passed    bool          `json:"passed"`
+	Reason    string        `json:"reason,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// RulesTestReport resume la ejecución de una suite de pruebas
+type RulesTestReport struct {
+	Name     string               `json:"name"`
+	Total    int                  `json:"total"`
+	Passed   int                  `json:"passed"`
+	Failed   int                  `json:"failed"` // Casos con una decisión distinta de la esperada
+	Errors   int                  `json:"errors"` // Casos inválidos o que no se pudieron evaluar
+	Duration time.Duration        `json:"duration"`
+	Results  []*RulesTestResult   `json:"results"`
+	Coverage *RulesCoverageReport `json:"coverage,omitempty"`
+}
+
+// Success indica si todos los casos pasaron
+func (r *RulesTestReport) Success() bool {
+	return r.Passed == r.Total
+}
+
+// Tipos de línea instrumentada de un informe de cobertura
+const (
+	CoverageLineMatch = "match"
+	CoverageLineAllow = "allow"
+	CoverageLineDeny  = "deny"
+)
+
+// RulesCoverageReport anota las líneas del archivo .rules con la cobertura de sus reglas:
+// cuántas veces se aplicó cada match y cómo se evaluó cada condición allow o deny
+type RulesCoverageReport struct {
+	ProjectID  string `json:"projectId,omitempty"`
+	DatabaseID string `json:"databaseId,omitempty"`
+	Version    string `json:"version,omitempty"` // Ruleset anotado, si está desplegado
+	// Conditions cuenta las condiciones por operación; Covered las evaluadas alguna vez
+	Conditions int                  `json:"conditions"`
+	Covered    int                  `json:"covered"`
+	Percent    float64              `json:"percent"`
+	Lines      []*RulesCoverageLine `json:"lines"`
+}
+
+// RulesCoverageLine es una línea del archivo .rules. Las líneas sin Kind no tienen
+// reglas ni condiciones.
+type RulesCoverageLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+	Kind   string `json:"kind,omitempty"` // "match", "allow" o "deny"
+	Hits   int64  `json:"hits"`
+	True   int64  `json:"true,omitempty"`
+	False  int64  `json:"false,omitempty"`
+	Error  int64  `json:"error,omitempty"`
+	// Operations son las operaciones de las condiciones de la línea nunca evaluadas
+	Uncovered []string `json:"uncovered,omitempty"`
+}
@@ -2,7 +2,9 @@ package domain
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -69,14 +71,30 @@ type RulesDeployer interface {
 	// DeployWithValidation despliega con validación previa
 	DeployWithValidation(ctx context.Context, projectID, databaseID string, rules interface{}) (*DeployResult, error)
 
-	// Rollback revierte al conjunto de reglas anterior
-	Rollback(ctx context.Context, projectID, databaseID string) error
+	// DeployRuleset crea un ruleset inmutable con el texto original y las reglas traducidas,
+	// lo despliega y lo publica en la release, atribuido al usuario
+	DeployRuleset(ctx context.Context, projectID, databaseID, source string, rules interface{}, user string) (*DeployResult, error)
+
+	// Release publica un ruleset existente como reglas activas
+	Release(ctx context.Context, projectID, databaseID, version, user string) (*RulesRelease, error)
+
+	// Rollback vuelve a publicar el ruleset de la release anterior
+	Rollback(ctx context.Context, projectID, databaseID, user string) (*RulesRelease, error)
 
 	// GetCurrentVersion obtiene la versión actual de reglas
 	GetCurrentVersion(ctx context.Context, projectID, databaseID string) (string, error)
 
 	// GetDeployHistory obtiene historial de despliegues
 	GetDeployHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*DeployHistory, error)
+
+	// GetRuleset obtiene un ruleset por su versión
+	GetRuleset(ctx context.Context, projectID, databaseID, version string) (*DeployHistory, error)
+
+	// GetRelease obtiene la release actual, nil si nunca se publicaron reglas
+	GetRelease(ctx context.Context, projectID, databaseID string) (*RulesRelease, error)
+
+	// GetReleaseHistory obtiene las últimas publicaciones, de la más antigua a la más reciente
+	GetReleaseHistory(ctx context.Context, projectID, databaseID string, limit int) ([]*RulesRelease, error)
 }
 
 // RulesOptimizer define el puerto para optimización de reglas
@@ -91,6 +109,18 @@ type RulesOptimizer interface {
 	SuggestImprovements(ctx context.Context, rules interface{}) ([]OptimizationSuggestion, error)
 }
 
+// RulesLinter define el puerto para revisar la seguridad de las reglas
+type RulesLinter interface {
+	// Lint devuelve los problemas de seguridad de las reglas traducidas
+	Lint(ctx context.Context, rules interface{}) ([]LintFinding, error)
+}
+
+// RulesTestRunner define el puerto para ejecutar pruebas de reglas sin desplegarlas
+type RulesTestRunner interface {
+	// Run ejecuta los casos de la suite contra el texto de las reglas
+	Run(ctx context.Context, source string, suite *RulesTestSuite) (*RulesTestReport, error)
+}
+
 // Structs de soporte para métricas y análisis
 
 type ParserMetrics struct {
@@ -122,12 +152,32 @@ type DeployResult struct {
 	Warnings      []string      `json:"warnings,omitempty"`
 }
 
+// DeployHistory es un ruleset inmutable: el texto original de un despliegue y sus reglas
+// traducidas. Los rulesets no cambian; qué ruleset está activo lo decide la release.
 type DeployHistory struct {
+	Version    string      `json:"version"`
+	DeployedAt time.Time   `json:"deployed_at"`
+	DeployedBy string      `json:"deployed_by"`
+	RulesCount int         `json:"rules_count"`
+	Status     string      `json:"status"`
+	RollbackOf string      `json:"rollback_of,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	Rules      interface{} `json:"rules,omitempty"`
+}
+
+// FirestoreReleaseName es el nombre de la release de las reglas de Firestore
+const FirestoreReleaseName = "cloud.firestore"
+
+// RulesRelease apunta al ruleset activo de una base de datos. Cada publicación se guarda
+// como una release nueva, así que el historial de releases es también el registro de
+// quién cambió las reglas y cuándo.
+type RulesRelease struct {
+	Name       string    `json:"name"`
+	ProjectID  string    `json:"project_id"`
+	DatabaseID string    `json:"database_id"`
 	Version    string    `json:"version"`
-	DeployedAt time.Time `json:"deployed_at"`
-	DeployedBy string    `json:"deployed_by"`
-	RulesCount int       `json:"rules_count"`
-	Status     string    `json:"status"`
+	ReleasedAt time.Time `json:"released_at"`
+	ReleasedBy string    `json:"released_by"`
 	RollbackOf string    `json:"rollback_of,omitempty"`
 }
 
@@ -140,6 +190,83 @@ type OptimizationSuggestion struct {
 	Line        int    `json:"line"`
 }
 
+// LintFinding es un problema de seguridad de las reglas, con su posición en el archivo
+// .rules y cómo corregirlo
+type LintFinding struct {
+	Check      string   `json:"check"`    // Comprobación que lo detectó, como "public-write"
+	Severity   string   `json:"severity"` // "error", "warning" o "info"
+	Match      string   `json:"match"`
+	Operations []string `json:"operations,omitempty"`
+	Line       int      `json:"line,omitempty"`
+	Column     int      `json:"column,omitempty"`
+	Message    string   `json:"message"`
+	Fix        string   `json:"fix"`
+}
+
+// String describe el hallazgo con su posición, si la conoce
+func (f LintFinding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s [%s]", f.Line, f.Column, f.Message, f.Check)
+	}
+	return fmt.Sprintf("%s [%s]", f.Message, f.Check)
+}
+
+// Severidades de los hallazgos del linter; los errores impiden desplegar las reglas salvo
+// que el despliegue los acepte con WithInsecureRulesAllowed
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityInfo    = "info"
+)
+
+// insecureRulesAllowedKey marca en el contexto los despliegues que aceptan errores del linter
+type insecureRulesAllowedKey struct{}
+
+// WithInsecureRulesAllowed devuelve un contexto cuyos despliegues no fallan por los
+// hallazgos de severidad error, que pasan a ser advertencias del resultado. Es la anulación
+// explícita para reglas inseguras a propósito, como una colección pública de solo escritura.
+func WithInsecureRulesAllowed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, insecureRulesAllowedKey{}, true)
+}
+
+// InsecureRulesAllowed indica si el contexto acepta los hallazgos de severidad error
+func InsecureRulesAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(insecureRulesAllowedKey{}).(bool)
+	return allowed
+}
+
+// LintErrors devuelve los hallazgos de severidad error
+func LintErrors(findings []LintFinding) []LintFinding {
+	var errors []LintFinding
+	for _, finding := range findings {
+		if finding.Severity == LintSeverityError {
+			errors = append(errors, finding)
+		}
+	}
+	return errors
+}
+
+// InsecureRulesError describe los hallazgos de severidad error que impiden desplegar las reglas
+func InsecureRulesError(findings []LintFinding) error {
+	problems := make([]string, len(findings))
+	for i, finding := range findings {
+		problems[i] = finding.String()
+	}
+	return fmt.Errorf("insecure rules: %s", strings.Join(problems, "; "))
+}
+
+// Comprobaciones del linter de seguridad
+const (
+	LintPublicWrite          = "public-write"           // Escrituras sin condición
+	LintAuthWithoutOwnership = "auth-without-ownership" // Rutas de usuario abiertas a cualquier usuario autenticado
+	LintRecursiveWrite       = "recursive-write"        // Escrituras concedidas por un wildcard {document=**}
+	LintUnreachableMatch     = "unreachable-match"      // Match que no puede coincidir con ningún documento
+	LintShadowedAllow        = "shadowed-allow"         // Condición anulada por un allow sin condición más amplio
+	LintUnreachableDeny      = "unreachable-deny"       // Deny que nunca se evalúa o nunca deniega
+	LintUnvalidatedWrite     = "unvalidated-write"      // create o update sin validar request.resource.data
+	LintUnboundedList        = "unbounded-list"         // list sin límite ni filtro
+)
+
 type OptimizationReport struct {
 	RulesOptimized  int                      `json:"rules_optimized"`
 	PerformanceGain float64                  `json:"performance_gain"`
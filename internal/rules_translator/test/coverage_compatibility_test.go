@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+	"firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/rules_translator/usecase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const coverageRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /posts/{postId} {
+      allow get: if resource.data.published == true;
+      allow create: if request.auth != null;
+      deny delete: if true;
+    }
+  }
+}`
+
+// TestParserStatementLines verifica que los match y las sentencias guardan su línea del texto
+func TestParserStatementLines(t *testing.T) {
+	result, err := parser.NewModernParserInstance().ParseString(context.Background(), coverageRules)
+	require.NoError(t, err)
+
+	match := findMatchByPath(result.Ruleset, "/posts/{postId}")
+	require.NotNil(t, match)
+	assert.Equal(t, 4, match.Line)
+	require.Len(t, match.Allow, 2)
+	assert.Equal(t, 5, match.Allow[0].Line)
+	assert.Equal(t, 6, match.Allow[1].Line)
+	require.Len(t, match.Deny, 1)
+	assert.Equal(t, 7, match.Deny[0].Line)
+}
+
+// TestRulesCoverageReport verifica la cobertura que el runner anota sobre el texto de las reglas
+func TestRulesCoverageReport(t *testing.T) {
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	runner := usecase.NewRulesTestRunner(parser.NewModernParserInstance(), setupTestTranslator(t), rules_cel.NewSimulator(env, nil))
+
+	suite := &domain.RulesTestSuite{
+		Coverage: true,
+		Documents: map[string]map[string]interface{}{
+			"posts/p1": {"published": true},
+			"posts/p2": {"published": false},
+		},
+		Tests: []*domain.RulesTestCase{
+			{Name: "published", Operation: "read", Path: "posts/p1", Expect: domain.RulesTestAllow},
+			{Name: "draft", Operation: "read", Path: "posts/p2", Expect: domain.RulesTestDeny},
+		},
+	}
+	report, err := runner.Run(context.Background(), coverageRules, suite)
+	require.NoError(t, err)
+	require.True(t, report.Success())
+	coverage := report.Coverage
+	require.NotNil(t, coverage)
+	require.Len(t, coverage.Lines, 10)
+
+	assert.Equal(t, domain.CoverageLineMatch, coverage.Lines[3].Kind)
+	assert.Equal(t, int64(2), coverage.Lines[3].Hits)
+
+	get := coverage.Lines[4]
+	assert.Equal(t, domain.CoverageLineAllow, get.Kind)
+	assert.Equal(t, int64(1), get.True)
+	assert.Equal(t, int64(1), get.False)
+	assert.Empty(t, get.Uncovered)
+
+	// Ninguna prueba crea ni borra documentos
+	assert.Equal(t, []string{"create"}, coverage.Lines[5].Uncovered)
+	assert.Equal(t, domain.CoverageLineDeny, coverage.Lines[6].Kind)
+	assert.Equal(t, []string{"delete"}, coverage.Lines[6].Uncovered)
+
+	assert.Equal(t, 3, coverage.Conditions)
+	assert.Equal(t, 1, coverage.Covered)
+	assert.Equal(t, 33.3, coverage.Percent)
+
+	// Sin la opción el informe no tiene cobertura
+	suite.Coverage = false
+	report, err = runner.Run(context.Background(), coverageRules, suite)
+	require.NoError(t, err)
+	assert.Nil(t, report.Coverage)
+}
+
+// TestCoverageStoreConcurrentRecording verifica que los contadores de cobertura no pierden
+// peticiones registradas en paralelo y que cada base de datos se cuenta por separado
+func TestCoverageStoreConcurrentRecording(t *testing.T) {
+	store := rules_cel.NewCoverageStore()
+	const workers, requests = 8, 500
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		databaseID := "a"
+		if i%2 == 1 {
+			databaseID = "b"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				store.RecordRule("p", databaseID, "/posts/{postId}")
+				store.RecordCondition("p", databaseID, "/posts/{postId}", "allow", repository.OperationRead, repository.ConditionTrue)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, databaseID := range []string{"a", "b"} {
+		rule := store.Coverage("p", databaseID).Rules["/posts/{postId}"]
+		require.NotNil(t, rule)
+		assert.Equal(t, int64(workers/2*requests), rule.Hits)
+		condition := rule.Conditions[repository.ConditionKey("allow", repository.OperationRead)]
+		require.NotNil(t, condition)
+		assert.Equal(t, int64(workers/2*requests), condition.True)
+	}
+
+	store.Reset("p", "a")
+	assert.Empty(t, store.Coverage("p", "a").Rules)
+	assert.Len(t, store.Coverage("p", "b").Rules, 1)
+}
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const functionRules = `rules_version = '2';
+service cloud.firestore {
+  function signedIn() {
+    return request.auth != null;
+  }
+
+  match /databases/{database}/documents {
+    function isOwner(uid) {
+      let u = request.auth.uid;
+      return signedIn() && u == uid;
+    }
+
+    match /users/{userId} {
+      allow read: if isOwner(userId) || resource.data.role in ['admin', 'staff'];
+      allow write: if isOwner(userId) && request.resource.data.age * 2 > 36;
+
+      match /posts/{postId} {
+        function isOwner(uid) {
+          return uid == 'root';
+        }
+        function canEdit(owner, editors) {
+          let me = request.auth.uid;
+          let allowed = editors;
+          return me == owner || me in allowed;
+        }
+        allow update: if canEdit(resource.data.owner, resource.data.editors) || isOwner(userId);
+        allow delete: if exists(/databases/$(database)/documents/admins/$(request.auth.uid));
+      }
+    }
+  }
+}`
+
+// TestRulesFunctionsAndLetBindings verifica funciones, let y su traducción a CEL
+func TestRulesFunctionsAndLetBindings(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := parser.NewModernParserInstance().ParseString(ctx, functionRules)
+	require.NoError(t, err, "Should parse functions, let bindings and path interpolation")
+	require.Empty(t, result.Errors)
+
+	ruleset := result.Ruleset
+	require.Len(t, ruleset.Functions, 1, "Service level function")
+	assert.Equal(t, "signedIn", ruleset.Functions[0].Name)
+
+	documents := findMatchByPath(ruleset, "/databases/{database}/documents")
+	require.NotNil(t, documents)
+	require.Len(t, documents.Functions, 1)
+	isOwner := documents.Functions[0]
+	assert.Equal(t, []string{"uid"}, isOwner.Parameters)
+	require.Len(t, isOwner.Bindings, 1)
+	assert.Equal(t, "u", isOwner.Bindings[0].Name)
+	assert.Equal(t, "request.auth.uid", isOwner.Bindings[0].Expression)
+	assert.Equal(t, "signedIn() && u == uid", isOwner.Return)
+
+	users := findMatchInBlock(documents, "/users/{userId}")
+	require.NotNil(t, users)
+	assert.Contains(t, users.Allow[0].Condition, "in ['admin','staff']", "String literals keep their quotes")
+
+	translation, err := setupTestTranslator(t).Translate(ctx, ruleset)
+	require.NoError(t, err)
+	require.Empty(t, translation.Errors)
+	rules := translation.Rules.([]*repository.SecurityRule)
+
+	userRule := findRuleByMatch(rules, "/databases/{database}/documents/users/{userId}")
+	require.NotNil(t, userRule)
+	assert.Equal(t,
+		"((request.auth != null) && (request.auth.uid) == (userId)) || resource.data.role in ['admin','staff']",
+		userRule.Allow[repository.OperationRead])
+
+	postRule := findRuleByMatch(rules, "/databases/{database}/documents/users/{userId}/posts/{postId}")
+	require.NotNil(t, postRule)
+	// The nested isOwner shadows the one of the parent block
+	assert.Equal(t,
+		"((request.auth.uid) == (resource.data.owner) || (request.auth.uid) in ((resource.data.editors))) || ((userId) == 'root')",
+		postRule.Allow[repository.OperationUpdate])
+	assert.Equal(t,
+		"exists(/databases/$(database)/documents/admins/$(request.auth.uid))",
+		postRule.Allow[repository.OperationDelete])
+
+	// The expanded conditions are valid CEL
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.DynType),
+		cel.Variable("resource", cel.DynType),
+		cel.Variable("userId", cel.StringType),
+	)
+	require.NoError(t, err)
+	for _, condition := range []string{
+		userRule.Allow[repository.OperationRead],
+		userRule.Allow[repository.OperationCreate],
+		postRule.Allow[repository.OperationUpdate],
+	} {
+		_, issues := env.Compile(condition)
+		assert.NoError(t, issues.Err(), "Should compile as CEL: %s", condition)
+	}
+}
+
+// TestRulesFunctionErrors verifica los errores de traducción de funciones
+func TestRulesFunctionErrors(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		rules   string
+		message string
+	}{
+		{
+			name: "Recursive function",
+			rules: `service cloud.firestore {
+  match /items/{id} {
+    function loop(x) { return loop(x); }
+    allow read: if loop(id);
+  }
+}`,
+			message: "recursive call to function 'loop'",
+		},
+		{
+			name: "Wrong argument count",
+			rules: `service cloud.firestore {
+  match /items/{id} {
+    function owner(a, b) { return a == b; }
+    allow read: if owner(id);
+  }
+}`,
+			message: "function 'owner' expects 2 arguments, got 1",
+		},
+		{
+			name: "Function of a sibling block",
+			rules: `service cloud.firestore {
+  match /a/{id} {
+    function hidden() { return true; }
+  }
+  match /b/{id} {
+    allow read: if hidden();
+  }
+}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := parser.NewModernParserInstance().ParseString(ctx, tc.rules)
+			require.NoError(t, err)
+
+			translation, err := setupTestTranslator(t).Translate(ctx, result.Ruleset)
+			require.NoError(t, err)
+			rules := translation.Rules.([]*repository.SecurityRule)
+
+			if tc.message == "" {
+				// Out of scope the call is left as is, like an unknown built-in
+				rule := findRuleByMatch(rules, "/b/{id}")
+				require.NotNil(t, rule)
+				assert.Equal(t, "hidden()", rule.Allow[repository.OperationRead])
+				return
+			}
+			require.Len(t, translation.Errors, 1)
+			assert.Contains(t, translation.Errors[0], tc.message)
+			rule := findRuleByMatch(rules, "/items/{id}")
+			require.NotNil(t, rule)
+			_, granted := rule.Allow[repository.OperationRead]
+			assert.False(t, granted, "An untranslatable condition does not grant access")
+		})
+	}
+}
+
+// TestParserDuplicateFunction verifica que una función no se declare dos veces en un scope
+func TestParserDuplicateFunction(t *testing.T) {
+	rules := `service cloud.firestore {
+  match /items/{id} {
+    function f() { return true; }
+    function f() { return false; }
+    allow read: if f();
+  }
+}`
+	result, err := parser.NewModernParser().ParseString(context.Background(), rules)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "Function 'f' is already declared")
+}
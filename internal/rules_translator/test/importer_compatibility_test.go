@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+	"firestore-clone/internal/rules_translator/domain"
+	"firestore-clone/internal/rules_translator/usecase"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParserErrorPositions verifica que los errores del parser indican línea y columna
+func TestParserErrorPositions(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name      string
+		rules     string
+		line      int
+		column    int
+		errorType string
+	}{
+		{
+			name: "Syntax error",
+			rules: `rules_version = '2';
+service cloud.firestore {
+  match /users/{userId} {
+    allow read if true;
+  }
+}`,
+			line: 4, column: 16, errorType: "syntax",
+		},
+		{
+			name: "Unterminated string",
+			rules: `rules_version = '2';
+service cloud.firestore {
+  match /users/{userId} {
+    allow read: if resource.data.name == 'abc;
+  }
+}`,
+			line: 4, column: 42, errorType: "lexical",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parser.NewModernParserInstance().ParseString(ctx, tc.rules)
+			require.Error(t, err)
+
+			var parseErr domain.ParseError
+			require.True(t, errors.As(err, &parseErr), "Error should carry its position: %v", err)
+			assert.Equal(t, tc.line, parseErr.Line)
+			assert.Equal(t, tc.column, parseErr.Column)
+			assert.Equal(t, tc.errorType, parseErr.Type)
+		})
+	}
+}
+
+// TestDiffRules verifica la comparación de reglas desplegadas y propuestas
+func TestDiffRules(t *testing.T) {
+	current := []*repository.SecurityRule{
+		{
+			Match: "/users/{userId}",
+			Allow: map[repository.OperationType]string{
+				repository.OperationRead:   "request.auth != null",
+				repository.OperationUpdate: "request.auth.uid == userId",
+			},
+		},
+		{
+			Match: "/legacy/{id}",
+			Allow: map[repository.OperationType]string{repository.OperationRead: "true"},
+		},
+	}
+	proposed := []*repository.SecurityRule{
+		{
+			Match: "/users/{userId}",
+			Allow: map[repository.OperationType]string{
+				repository.OperationRead:   "request.auth != null",
+				repository.OperationUpdate: "request.auth.uid == userId && request.resource.data.age >= 18",
+			},
+			Deny: map[repository.OperationType]string{repository.OperationDelete: "true"},
+		},
+	}
+
+	diff := usecase.DiffRules(current, proposed)
+	require.True(t, diff.HasChanges())
+	assert.Equal(t, 1, diff.Unchanged)
+
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, domain.RuleChange{Match: "/users/{userId}", Effect: "deny", Operation: "delete", After: "true"}, *diff.Added[0])
+
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "/legacy/{id}", diff.Removed[0].Match)
+	assert.Equal(t, "true", diff.Removed[0].Before)
+
+	require.Len(t, diff.Changed, 1)
+	assert.Equal(t, "update", diff.Changed[0].Operation)
+	assert.Equal(t, "request.auth.uid == userId", diff.Changed[0].Before)
+
+	assert.False(t, usecase.DiffRules(proposed, proposed).HasChanges())
+}
+
+// TestOptimizedFixturesCompile verifica que las reglas de los fixtures, traducidas con
+// todas las optimizaciones como hace el importador, compilan en el motor
+func TestOptimizedFixturesCompile(t *testing.T) {
+	ctx := context.Background()
+
+	optimizerConfig := adapter.DefaultOptimizerConfig()
+	optimizerConfig.EnableAggressiveOptim = true
+	translator := usecase.NewFastTranslator(
+		adapter.NewMemoryCache(adapter.DefaultCacheConfig()),
+		adapter.NewRulesOptimizer(optimizerConfig),
+		usecase.DefaultTranslatorConfig(),
+	)
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+
+	for _, fixture := range []string{"fixtures/firestore_official_examples.rules", "fixtures/firestore_complex_examples.rules"} {
+		t.Run(fixture, func(t *testing.T) {
+			content, err := os.ReadFile(fixture)
+			require.NoError(t, err)
+			result, err := parser.NewModernParserInstance().ParseString(ctx, string(content))
+			require.NoError(t, err)
+			translation, err := translator.Translate(ctx, result.Ruleset)
+			require.NoError(t, err)
+			require.Empty(t, translation.Errors)
+
+			for _, rule := range translation.Rules.([]*repository.SecurityRule) {
+				wildcards := extractPathVariables(rule.Match)
+				for i, wildcard := range wildcards {
+					wildcards[i] = strings.SplitN(wildcard, "=", 2)[0]
+				}
+				for op, condition := range rule.Allow {
+					_, err := rules_cel.Compile(env, condition, wildcards)
+					assert.NoError(t, err, "%s allow %s: %s", rule.Match, op, condition)
+				}
+				for op, condition := range rule.Deny {
+					_, err := rules_cel.Compile(env, condition, wildcards)
+					assert.NoError(t, err, "%s deny %s: %s", rule.Match, op, condition)
+				}
+			}
+		})
+	}
+}
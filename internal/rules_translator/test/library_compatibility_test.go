@@ -0,0 +1,162 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResourceAccessor serves get() and exists() from a map of document paths
+type fakeResourceAccessor struct {
+	documents map[string]map[string]interface{}
+}
+
+func (f *fakeResourceAccessor) GetDocument(ctx context.Context, projectID, databaseID, path string) (map[string]interface{}, error) {
+	return f.documents[path], nil
+}
+
+func (f *fakeResourceAccessor) ExistsDocument(ctx context.Context, projectID, databaseID, path string) (bool, error) {
+	_, ok := f.documents[path]
+	return ok, nil
+}
+
+// evaluateRulesCondition compiles a condition with the rules library and evaluates it
+// for an update of users/u1 by u1
+func evaluateRulesCondition(t *testing.T, condition string) (bool, error) {
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	program, err := rules_cel.Compile(env, condition, []string{"database", "userId"})
+	if err != nil {
+		return false, err
+	}
+
+	evaluation := &rules_cel.Evaluation{
+		Context: context.Background(),
+		Accessor: &fakeResourceAccessor{documents: map[string]map[string]interface{}{
+			"users/u1":  {"role": "admin", "name": "Ana"},
+			"admins/u1": {},
+		}},
+		ProjectID:  "p1",
+		DatabaseID: "d1",
+	}
+	vars := evaluation.Bind(map[string]interface{}{
+		"auth":      map[string]interface{}{"uid": "u1"},
+		"path":      "users/u1",
+		"variables": map[string]string{"database": "(default)", "userId": "u1"},
+		"database":  "(default)",
+		"userId":    "u1",
+		"request": map[string]interface{}{
+			"auth": map[string]interface{}{"uid": "u1"},
+			"time": time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC),
+			"resource": map[string]interface{}{"data": map[string]interface{}{
+				"name": "Ana Lopez", "age": 31, "tags": []interface{}{"a", "b", "a"}, "owner": "u1",
+			}},
+		},
+		"resource": map[string]interface{}{"data": map[string]interface{}{
+			"name": "Ana", "age": 31, "tags": []interface{}{"a"}, "legacy": true,
+		}},
+	})
+
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := out.Value().(bool)
+	require.True(t, ok, "Condition should be boolean: %s", condition)
+	return result, nil
+}
+
+// TestRulesStandardLibrary verifies the Firestore rules library in the engine environment
+func TestRulesStandardLibrary(t *testing.T) {
+	testCases := []struct {
+		name      string
+		condition string
+	}{
+		{"Map diff affected keys", "request.resource.data.diff(resource.data).affectedKeys().hasOnly(['name', 'tags', 'owner', 'legacy'])"},
+		{"Map diff added and removed keys", "resource.data.diff(request.resource.data).addedKeys() == ['legacy'].toSet() && request.resource.data.diff(resource.data).removedKeys().hasAll(['legacy'])"},
+		{"Map diff unchanged keys", "request.resource.data.diff(resource.data).unchangedKeys() == ['age'].toSet()"},
+		{"Map keys and values", "request.resource.data.keys().hasAll(['name', 'age']) && 31 in request.resource.data.values()"},
+		{"Map get with default", "request.resource.data.get('missing', 'x') == 'x' && request.resource.data.get(['name'], '') == 'Ana Lopez'"},
+		{"List to set", "request.resource.data.tags.toSet() == ['b', 'a'].toSet() && request.resource.data.tags.toSet().size() == 2"},
+		{"List membership", "request.resource.data.tags.hasAny(['z', 'b']) && !request.resource.data.tags.hasOnly(['a'])"},
+		{"List join and removeAll", "request.resource.data.tags.removeAll(['a']).join(',') == 'b'"},
+		{"Set operations", "['a', 'b'].toSet().union(['c'].toSet()).difference(['a'].toSet()) == ['c', 'b'].toSet() && ['a', 'b'].toSet().intersection(['b']) == ['b']"},
+		{"String matches whole string", "request.resource.data.name.matches('Ana.*') && !request.resource.data.name.matches('Lopez')"},
+		{"String helpers", "request.resource.data.name.lower() == 'ana lopez' && ' x '.trim().upper() == 'X' && 'a-b-c'.split('-').size() == 3 && 'aXbX'.replace('X', '') == 'ab'"},
+		{"Request time", "request.time > timestamp.date(2025, 1, 1) && request.time.year() == 2025 && request.time.month() == 3 && request.time.dayOfWeek() == 5"},
+		{"Timestamp arithmetic", "request.time.date() + duration.value(1, 'd') == timestamp.date(2025, 3, 15) && timestamp.value(0).toMillis() == 0"},
+		{"Durations", "duration.time(1, 30, 0, 0) == duration.value(90, 'm') && duration.abs(duration.value(-2, 'h')).seconds() == 7200 && request.time.time() > duration.value(15, 'h')"},
+		{"Math", "math.abs(-3) == 3 && math.floor(2.7) == 2 && math.ceil(2.1) == 3 && math.pow(2, 10) == 1024 && math.sqrt(16) == 4.0 && !math.isNaN(1.0)"},
+		{"Numbers compare across int and float", "request.resource.data.age > 30.5 && float(request.resource.data.age) == 31.0"},
+		{"Hashing", "hashing.sha256('abc').toHexString() == 'BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD' && hashing.md5(b'abc').size() == 16 && hashing.crc32('abc').size() == 4"},
+		{"Bytes", "'hi'.toUtf8().toBase64() == 'aGk='"},
+		{"LatLng", "latlng.value(0.0, 0.0).distance(latlng.value(0.0, 1.0)) > 111000.0 && latlng.value(1.5, 2.5).latitude() == 1.5"},
+		{"Type checks", "request.resource.data.name is string && request.resource.data.age is int && request.resource.data.age is number && request.resource.data.tags is list && request.resource.data is map && request.time is timestamp && !(request.resource.data.name is bool)"},
+		{"Path binding", "path('/databases/$(database)/documents/users/$(uid)').bind({'database': database, 'uid': 'u1'}) == '/databases/(default)/documents/users/u1'"},
+		{"Get with path literal", "get(/databases/$(database)/documents/users/$(request.auth.uid)).data.role == 'admin' && get(/databases/$(database)/documents/users/$(userId)).id == 'u1'"},
+		{"Exists with path literal", "exists(/databases/$(database)/documents/admins/$(request.auth.uid)) && !exists(/databases/$(database)/documents/admins/nobody)"},
+		{"Division is not a path", "request.resource.data.age / 31 == 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed, err := evaluateRulesCondition(t, tc.condition)
+			require.NoError(t, err, tc.condition)
+			assert.True(t, allowed, tc.condition)
+		})
+	}
+
+	t.Run("Get of a missing document denies", func(t *testing.T) {
+		_, err := evaluateRulesCondition(t, "get(/databases/$(database)/documents/users/nobody).data.role == 'admin'")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "document does not exist")
+	})
+}
+
+// TestTranslatedRulesCompileWithLibrary verifies that translated fixture rules compile
+// in the engine environment
+func TestTranslatedRulesCompileWithLibrary(t *testing.T) {
+	ctx := context.Background()
+	rules := `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    function isAdmin() {
+      return get(/databases/$(database)/documents/users/$(request.auth.uid)).data.role in ['admin', 'staff'];
+    }
+    match /profiles/{userId} {
+      allow update: if request.auth.uid == userId &&
+                    request.resource.data.diff(resource.data).affectedKeys().hasOnly(['name', 'bio']) &&
+                    request.resource.data.name is string &&
+                    request.resource.data.name.matches('[A-Za-z ]+');
+      allow delete: if isAdmin() || request.time < resource.data.createdAt + duration.value(1, 'h');
+    }
+  }
+}`
+
+	result, err := parser.NewModernParserInstance().ParseString(ctx, rules)
+	require.NoError(t, err)
+	translation, err := setupTestTranslator(t).Translate(ctx, result.Ruleset)
+	require.NoError(t, err)
+	require.Empty(t, translation.Errors)
+
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	for _, rule := range translation.Rules.([]*repository.SecurityRule) {
+		wildcards := extractPathVariables(rule.Match)
+		for i, wildcard := range wildcards {
+			wildcards[i] = strings.SplitN(wildcard, "=", 2)[0]
+		}
+		for op, condition := range rule.Allow {
+			_, err := rules_cel.Compile(env, condition, wildcards)
+			assert.NoError(t, err, "%s %s: %s", rule.Match, op, condition)
+		}
+	}
+}
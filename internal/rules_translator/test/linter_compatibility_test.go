@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+	"firestore-clone/internal/rules_translator/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const linterRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    function signedIn() {
+      return request.auth != null;
+    }
+    match /users/{userId} {
+      allow read: if signedIn();
+      allow create: if signedIn() && request.auth.uid == userId && request.resource.data.name is string;
+    }
+    match /public/{publicId} {
+      allow read, write: if true;
+    }
+    match /shared/{sharedId} {
+      allow read: if request.auth.uid == resource.data.owner;
+      allow update: if request.auth.uid == resource.data.owner;
+    }
+    match /inbox {
+      allow read: if signedIn();
+    }
+    match /{document=**} {
+      allow update: if signedIn() && request.resource.data.size() < 10;
+      deny delete: if false;
+    }
+  }
+}`
+
+// lintSource traduce las reglas y devuelve los hallazgos del linter
+func lintSource(t *testing.T, source string) []domain.LintFinding {
+	ctx := context.Background()
+	result, err := parser.NewModernParserInstance().ParseString(ctx, source)
+	require.NoError(t, err)
+	translation, err := setupTestTranslator(t).Translate(ctx, result.Ruleset)
+	require.NoError(t, err)
+	findings, err := adapter.NewRulesLinter().Lint(ctx, translation.Rules)
+	require.NoError(t, err)
+	return findings
+}
+
+// findingAt busca el hallazgo de una comprobación en una línea
+func findingAt(findings []domain.LintFinding, check string, line int) *domain.LintFinding {
+	for i := range findings {
+		if findings[i].Check == check && findings[i].Line == line {
+			return &findings[i]
+		}
+	}
+	return nil
+}
+
+// TestRulesLinter verifica cada comprobación del linter sobre un archivo de reglas
+func TestRulesLinter(t *testing.T) {
+	findings := lintSource(t, linterRules)
+
+	t.Run("Public writes are errors", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintPublicWrite, 12)
+		require.NotNil(t, finding)
+		assert.Equal(t, domain.LintSeverityError, finding.Severity)
+		assert.Equal(t, 7, finding.Column)
+		assert.Equal(t, "/public/{publicId}", finding.Match)
+		assert.Equal(t, []string{"read", "list", "create", "update", "delete"}, finding.Operations)
+		assert.NotEmpty(t, finding.Fix)
+		assert.NotNil(t, findingAt(findings, domain.LintUnboundedList, 12))
+	})
+
+	t.Run("Signed-in checks on user paths need an ownership check", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintAuthWithoutOwnership, 8)
+		require.NotNil(t, finding, "The function is expanded before the check")
+		assert.Equal(t, domain.LintSeverityWarning, finding.Severity)
+		assert.Contains(t, finding.Fix, "request.auth.uid == userId")
+		assert.Nil(t, findingAt(findings, domain.LintAuthWithoutOwnership, 9))
+		assert.Nil(t, findingAt(findings, domain.LintUnvalidatedWrite, 9))
+	})
+
+	t.Run("List rules need a limit or a filter", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintUnboundedList, 8)
+		require.NotNil(t, finding)
+		assert.Equal(t, []string{"list"}, finding.Operations)
+		assert.Nil(t, findingAt(findings, domain.LintUnboundedList, 15), "resource.data makes queries filter")
+	})
+
+	t.Run("Writes without data validation", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintUnvalidatedWrite, 16)
+		require.NotNil(t, finding)
+		assert.Equal(t, domain.LintSeverityInfo, finding.Severity)
+	})
+
+	t.Run("Matches on collections never match", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintUnreachableMatch, 18)
+		require.NotNil(t, finding)
+		assert.Equal(t, 5, finding.Column)
+		assert.Contains(t, finding.Fix, "match /inbox/{docId}")
+		assert.Nil(t, findingAt(findings, domain.LintAuthWithoutOwnership, 19))
+	})
+
+	t.Run("Recursive wildcards granting writes", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintRecursiveWrite, 22)
+		require.NotNil(t, finding)
+		assert.Equal(t, []string{"update"}, finding.Operations)
+	})
+
+	t.Run("Deny rules which never deny", func(t *testing.T) {
+		finding := findingAt(findings, domain.LintUnreachableDeny, 23)
+		require.NotNil(t, finding)
+		assert.Equal(t, domain.LintSeverityWarning, finding.Severity)
+	})
+
+	t.Run("Findings are sorted by position", func(t *testing.T) {
+		for i := 1; i < len(findings); i++ {
+			assert.LessOrEqual(t, findings[i-1].Line, findings[i].Line)
+		}
+	})
+}
+
+// TestRulesLinterEvaluationOrder verifica las comprobaciones que dependen del orden en que
+// el motor evalúa las reglas: primero las de mayor prioridad
+func TestRulesLinterEvaluationOrder(t *testing.T) {
+	rules := []*repository.SecurityRule{
+		{
+			Match: "/databases/{database}/documents/{collection}/{docId}", Priority: 1200, Line: 4,
+			Allow:      map[repository.OperationType]string{repository.OperationRead: "true", repository.OperationDelete: "true"},
+			AllowLines: map[repository.OperationType]int{repository.OperationRead: 5, repository.OperationDelete: 5},
+		},
+		{
+			Match: "/databases/{database}/documents/posts/{postId}", Priority: 1100, Line: 7,
+			Allow:      map[repository.OperationType]string{repository.OperationRead: "request.auth != null"},
+			AllowLines: map[repository.OperationType]int{repository.OperationRead: 8},
+			Deny:       map[repository.OperationType]string{repository.OperationDelete: "resource.data.locked"},
+			DenyLines:  map[repository.OperationType]int{repository.OperationDelete: 9},
+		},
+	}
+	findings, err := adapter.NewRulesLinter().Lint(context.Background(), rules)
+	require.NoError(t, err)
+
+	shadowed := findingAt(findings, domain.LintShadowedAllow, 8)
+	require.NotNil(t, shadowed)
+	assert.Contains(t, shadowed.Message, "/{collection}/{docId} (line 4)")
+
+	deny := findingAt(findings, domain.LintUnreachableDeny, 9)
+	require.NotNil(t, deny, "The broader rule is evaluated first and allows the delete")
+	assert.Equal(t, domain.LintSeverityError, deny.Severity)
+
+	// Evaluado después, el deny se alcanza
+	rules[0].Priority = 1000
+	findings, err = adapter.NewRulesLinter().Lint(context.Background(), rules)
+	require.NoError(t, err)
+	assert.Nil(t, findingAt(findings, domain.LintUnreachableDeny, 9))
+	assert.NotNil(t, findingAt(findings, domain.LintShadowedAllow, 8), "Allow conditions are shadowed in any order")
+}
+
+// TestRulesLinterInDeploy verifica que los errores de seguridad impiden desplegar y que las
+// advertencias se devuelven con el despliegue
+func TestRulesLinterInDeploy(t *testing.T) {
+	ctx := context.Background()
+	deployer := adapter.NewRulesDeployer(&releaseTestEngine{}, adapter.NewSimpleValidator(), adapter.NewMemoryHistoryStore(), nil)
+
+	open := []*repository.SecurityRule{{
+		Match: "/databases/{database}/documents/posts/{postId}",
+		Allow: map[repository.OperationType]string{repository.OperationCreate: "true"},
+	}}
+	result, err := deployer.DeployRuleset(ctx, "p1", "d1", "", open, "alice")
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	require.NotEmpty(t, result.Errors)
+	assert.Contains(t, result.Errors[0], domain.LintPublicWrite)
+
+	signedIn := []*repository.SecurityRule{{
+		Match: "/databases/{database}/documents/users/{userId}",
+		Allow: map[repository.OperationType]string{repository.OperationRead: "request.auth != null"},
+	}}
+	result, err = deployer.DeployRuleset(ctx, "p1", "d1", "", signedIn, "alice")
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Contains(t, result.Warnings[0], domain.LintAuthWithoutOwnership)
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/model"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const queryRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /notes/{noteId} {
+      allow list: if request.auth.uid == resource.data.owner && request.query.limit <= 50;
+    }
+    match /posts/{postId} {
+      allow read: if resource.data.visibility == 'public' || resource.data.author == request.auth.uid;
+    }
+    match /teams/{teamId}/tasks/{taskId} {
+      allow list: if request.auth.uid in resource.data.members;
+    }
+    match /comments/{commentId} {
+      allow list: if resource.data.approved == true;
+    }
+    match /{path=**}/comments/{commentId} {
+      allow list: if resource.data.approved == true;
+    }
+    match /drafts/{draftId} {
+      allow list: if draftId == request.auth.uid;
+    }
+  }
+}`
+
+// queryTestRules traduce las reglas de las consultas
+func queryTestRules(t *testing.T) []*repository.SecurityRule {
+	ctx := context.Background()
+	result, err := parser.NewModernParserInstance().ParseString(ctx, queryRules)
+	require.NoError(t, err)
+	translation, err := setupTestTranslator(t).Translate(ctx, result.Ruleset)
+	require.NoError(t, err)
+	return translation.Rules.([]*repository.SecurityRule)
+}
+
+// TestQueryRules verifica que las consultas solo se permiten cuando sus filtros garantizan
+// que las reglas list se cumplen para cualquier documento devuelto
+func TestQueryRules(t *testing.T) {
+	ctx := context.Background()
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	rules := queryTestRules(t)
+	alice := map[string]interface{}{"uid": "alice"}
+
+	evaluate := func(t *testing.T, collectionPath string, query *model.Query) *repository.RuleEvaluationResult {
+		result, err := rules_cel.EvaluateQuery(ctx, env, rules, nil, &rules_cel.QueryRequest{
+			ProjectID: "p1", DatabaseID: "(default)", CollectionPath: collectionPath, Auth: alice, Query: query,
+		})
+		require.NoError(t, err)
+		return result
+	}
+	equal := func(field string, value interface{}) model.Filter {
+		return model.Filter{Field: field, Operator: model.OperatorEqual, Value: value}
+	}
+
+	t.Run("Query filtered by the owner is allowed", func(t *testing.T) {
+		result := evaluate(t, "notes", &model.Query{Filters: []model.Filter{equal("owner", "alice")}, Limit: 20})
+		assert.True(t, result.Allowed, result.Reason)
+		assert.Equal(t, "/databases/{database}/documents/notes/{noteId}", result.AllowedBy)
+	})
+
+	t.Run("Rules are not filters", func(t *testing.T) {
+		// Sin filtro la consulta podría devolver notas de otros usuarios
+		result := evaluate(t, "notes", &model.Query{Limit: 20})
+		assert.False(t, result.Allowed)
+		assert.Contains(t, result.Reason, "resource.data.owner is not constrained by the query")
+
+		result = evaluate(t, "notes", &model.Query{Filters: []model.Filter{equal("owner", "bob")}, Limit: 20})
+		assert.False(t, result.Allowed)
+
+		// Los filtros de rango no garantizan la igualdad
+		result = evaluate(t, "notes", &model.Query{Filters: []model.Filter{{Field: "owner", Operator: model.OperatorGreaterThanOrEqual, Value: "alice"}}, Limit: 20})
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("request.query exposes the limit", func(t *testing.T) {
+		result := evaluate(t, "notes", &model.Query{Filters: []model.Filter{equal("owner", "alice")}, Limit: 100})
+		assert.False(t, result.Allowed)
+
+		// Una consulta sin límite no tiene request.query.limit
+		result = evaluate(t, "notes", &model.Query{Filters: []model.Filter{equal("owner", "alice")}})
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("Each disjunction must be allowed", func(t *testing.T) {
+		result := evaluate(t, "posts", &model.Query{Filters: []model.Filter{{Field: "visibility", Operator: model.OperatorIn, Value: []interface{}{"public"}}}})
+		assert.True(t, result.Allowed, result.Reason)
+
+		result = evaluate(t, "posts", &model.Query{Filters: []model.Filter{{Field: "visibility", Operator: model.OperatorIn, Value: []interface{}{"public", "private"}}}})
+		assert.False(t, result.Allowed)
+
+		result = evaluate(t, "posts", &model.Query{Filters: []model.Filter{{Composite: "or", SubFilters: []model.Filter{
+			equal("visibility", "public"),
+			equal("author", "alice"),
+		}}}})
+		assert.True(t, result.Allowed, result.Reason)
+
+		result = evaluate(t, "posts", &model.Query{Filters: []model.Filter{{Composite: "or", SubFilters: []model.Filter{
+			equal("visibility", "public"),
+			equal("author", "bob"),
+		}}}})
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("array-contains proves membership", func(t *testing.T) {
+		result := evaluate(t, "teams/t1/tasks", &model.Query{Filters: []model.Filter{{Field: "members", Operator: model.OperatorArrayContains, Value: "alice"}}})
+		assert.True(t, result.Allowed, result.Reason)
+
+		result = evaluate(t, "teams/t1/tasks", &model.Query{Filters: []model.Filter{{Field: "members", Operator: model.OperatorArrayContainsAny, Value: []interface{}{"alice", "bob"}}}})
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("Collection group queries are checked at every depth", func(t *testing.T) {
+		result := evaluate(t, "comments", &model.Query{AllDescendants: true, Filters: []model.Filter{equal("approved", true)}})
+		assert.True(t, result.Allowed, result.Reason)
+
+		result = evaluate(t, "comments", &model.Query{AllDescendants: true})
+		assert.False(t, result.Allowed)
+
+		// Las tareas fuera de /teams/{teamId} no tienen reglas
+		result = evaluate(t, "tasks", &model.Query{AllDescendants: true, Filters: []model.Filter{{Field: "members", Operator: model.OperatorArrayContains, Value: "alice"}}})
+		assert.False(t, result.Allowed)
+	})
+
+	t.Run("Document IDs are not constrained by queries", func(t *testing.T) {
+		result := evaluate(t, "drafts", &model.Query{})
+		assert.False(t, result.Allowed)
+		assert.Contains(t, result.Reason, "draftId is not constrained by the query")
+	})
+
+	t.Run("Collections without rules are denied", func(t *testing.T) {
+		result := evaluate(t, "secrets", &model.Query{Filters: []model.Filter{equal("owner", "alice")}})
+		assert.False(t, result.Allowed)
+		assert.Equal(t, "No matching rule found (default deny)", result.Reason)
+	})
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter"
+	"firestore-clone/internal/shared/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseTestEngine guarda las reglas desplegadas en memoria
+type releaseTestEngine struct {
+	repository.SecurityRulesEngine
+	rules []*repository.SecurityRule
+}
+
+func (e *releaseTestEngine) LoadRules(ctx context.Context, projectID, databaseID string) ([]*repository.SecurityRule, error) {
+	return e.rules, nil
+}
+
+func (e *releaseTestEngine) SaveRules(ctx context.Context, projectID, databaseID string, rules []*repository.SecurityRule) error {
+	e.rules = rules
+	return nil
+}
+
+func releaseTestRules(condition string) []*repository.SecurityRule {
+	return []*repository.SecurityRule{{
+		Match: "/users/{userId}",
+		Allow: map[repository.OperationType]string{repository.OperationRead: condition},
+	}}
+}
+
+// TestRulesReleases verifica que cada despliegue guarda un ruleset con su texto, que la
+// release apunta al activo y que el rollback vuelve a publicar el anterior
+func TestRulesReleases(t *testing.T) {
+	ctx := context.Background()
+	engine := &releaseTestEngine{}
+	deployer := adapter.NewRulesDeployer(engine, adapter.NewSimpleValidator(), adapter.NewMemoryHistoryStore(), nil)
+
+	first, err := deployer.DeployRuleset(ctx, "p1", "d1", "source v1", releaseTestRules("true"), "alice")
+	require.NoError(t, err)
+	require.True(t, first.Success)
+	second, err := deployer.DeployRuleset(ctx, "p1", "d1", "source v2", releaseTestRules("false"), "bob")
+	require.NoError(t, err)
+	require.NotEqual(t, first.Version, second.Version)
+
+	ruleset, err := deployer.GetRuleset(ctx, "p1", "d1", first.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "source v1", ruleset.Source)
+	assert.Equal(t, "alice", ruleset.DeployedBy)
+	assert.Equal(t, releaseTestRules("true"), ruleset.Rules)
+
+	version, err := deployer.GetCurrentVersion(ctx, "p1", "d1")
+	require.NoError(t, err)
+	assert.Equal(t, second.Version, version)
+
+	// El rollback publica el ruleset anterior, atribuido a quien lo pide
+	release, err := deployer.Rollback(ctx, "p1", "d1", "carol")
+	require.NoError(t, err)
+	assert.Equal(t, first.Version, release.Version)
+	assert.Equal(t, second.Version, release.RollbackOf)
+	assert.Equal(t, "carol", release.ReleasedBy)
+	assert.Equal(t, "true", engine.rules[0].Allow[repository.OperationRead])
+
+	// Publicar una versión concreta
+	release, err = deployer.Release(ctx, "p1", "d1", second.Version, "dave")
+	require.NoError(t, err)
+	assert.Empty(t, release.RollbackOf)
+	assert.Equal(t, "false", engine.rules[0].Allow[repository.OperationRead])
+
+	releases, err := deployer.GetReleaseHistory(ctx, "p1", "d1", 0)
+	require.NoError(t, err)
+	require.Len(t, releases, 4)
+	var releasedBy []string
+	for _, release := range releases {
+		releasedBy = append(releasedBy, release.ReleasedBy)
+	}
+	assert.Equal(t, []string{"alice", "bob", "carol", "dave"}, releasedBy)
+
+	_, err = deployer.Release(ctx, "p1", "d1", "missing", "dave")
+	assert.True(t, errors.IsNotFound(err))
+
+	// Sin una release anterior no hay rollback
+	other := adapter.NewRulesDeployer(&releaseTestEngine{}, nil, adapter.NewMemoryHistoryStore(), nil)
+	_, err = other.DeployRuleset(ctx, "p1", "d1", "source", releaseTestRules("true"), "alice")
+	require.NoError(t, err)
+	_, err = other.Rollback(ctx, "p1", "d1", "alice")
+	assert.True(t, errors.IsValidation(err))
+}
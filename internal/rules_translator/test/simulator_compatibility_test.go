@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"firestore-clone/internal/firestore/adapter/rules_cel"
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/adapter/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const simulatorRules = `rules_version = '2';
+service cloud.firestore {
+  match /databases/{database}/documents {
+    match /posts/{postId} {
+      allow read: if resource.data.published == true || request.auth.uid == resource.data.author;
+      allow update: if request.auth != null
+        && get(/databases/$(database)/documents/users/$(request.auth.uid)).data.role == 'editor'
+        && request.resource.data.title.size() > 0;
+    }
+  }
+}`
+
+// simulatorTestRules traduce las reglas del simulador
+func simulatorTestRules(t *testing.T) []*repository.SecurityRule {
+	ctx := context.Background()
+	result, err := parser.NewModernParserInstance().ParseString(ctx, simulatorRules)
+	require.NoError(t, err)
+	translation, err := setupTestTranslator(t).Translate(ctx, result.Ruleset)
+	require.NoError(t, err)
+	return translation.Rules.([]*repository.SecurityRule)
+}
+
+// TestRulesSimulator verifica la decisión y la traza del simulador de reglas
+func TestRulesSimulator(t *testing.T) {
+	ctx := context.Background()
+	env, err := rules_cel.NewEnvironment()
+	require.NoError(t, err)
+	rules := simulatorTestRules(t)
+
+	t.Run("Read of a draft by another user is denied", func(t *testing.T) {
+		result, err := rules_cel.Simulate(ctx, env, rules, nil, &repository.SimulationRequest{
+			ProjectID: "p1", DatabaseID: "(default)", Operation: repository.OperationRead, Path: "posts/p1",
+			Auth:     map[string]interface{}{"uid": "bob"},
+			Resource: map[string]interface{}{"published": false, "author": "alice"},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		require.Len(t, result.Matches, 1)
+		assert.Equal(t, "p1", result.Matches[0].Variables["postId"])
+
+		require.Len(t, result.Matches[0].Conditions, 1)
+		condition := result.Matches[0].Conditions[0]
+		assert.Equal(t, "allow", condition.Effect)
+		assert.False(t, condition.Result)
+
+		// La traza incluye los valores de las subexpresiones
+		values := make(map[string]interface{})
+		for _, expression := range condition.Expressions {
+			values[expression.Expression] = expression.Value
+		}
+		assert.Equal(t, false, values["resource.data.published"])
+		assert.Equal(t, "alice", values["resource.data.author"])
+		assert.Equal(t, false, values["request.auth.uid == resource.data.author"])
+	})
+
+	t.Run("Read by the author is allowed", func(t *testing.T) {
+		result, err := rules_cel.Simulate(ctx, env, rules, nil, &repository.SimulationRequest{
+			ProjectID: "p1", DatabaseID: "(default)", Operation: repository.OperationRead, Path: "/databases/(default)/documents/posts/p1",
+			Auth:     map[string]interface{}{"uid": "alice"},
+			Resource: map[string]interface{}{"published": false, "author": "alice"},
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, "/databases/{database}/documents/posts/{postId}", result.DecidedBy)
+	})
+
+	t.Run("get() calls are traced", func(t *testing.T) {
+		request := &repository.SimulationRequest{
+			ProjectID: "p1", DatabaseID: "(default)", Operation: repository.OperationUpdate, Path: "posts/p1",
+			Auth:        map[string]interface{}{"uid": "alice"},
+			RequestData: map[string]interface{}{"title": "Hello"},
+			Documents:   map[string]map[string]interface{}{"users/alice": {"role": "editor"}},
+		}
+		result, err := rules_cel.Simulate(ctx, env, rules, nil, request)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		require.Len(t, result.Lookups, 1)
+		assert.Equal(t, repository.DocumentLookupTrace{Function: "get", Path: "users/alice", Found: true, Mocked: true}, *result.Lookups[0])
+
+		// Un documento inexistente hace fallar la condición
+		request.Documents = map[string]map[string]interface{}{"users/alice": nil}
+		result, err = rules_cel.Simulate(ctx, env, rules, nil, request)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		require.Len(t, result.Lookups, 1)
+		assert.False(t, result.Lookups[0].Found)
+		assert.NotEmpty(t, result.Matches[0].Conditions[0].Error)
+	})
+
+	t.Run("Paths without rules are denied by default", func(t *testing.T) {
+		result, err := rules_cel.Simulate(ctx, env, rules, nil, &repository.SimulationRequest{
+			ProjectID: "p1", DatabaseID: "(default)", Operation: repository.OperationRead, Path: "comments/c1",
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Empty(t, result.Matches)
+		assert.Equal(t, "No matching rule found (default deny)", result.Reason)
+	})
+}
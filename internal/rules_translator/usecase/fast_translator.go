@@ -73,8 +73,12 @@ func NewFastTranslator(cache domain.RulesCache, optimizer domain.RulesOptimizer,
 	translator.rulePool = sync.Pool{
 		New: func() interface{} {
 			return &repository.SecurityRule{
-				Allow: make(map[repository.OperationType]string),
-				Deny:  make(map[repository.OperationType]string),
+				Allow:        make(map[repository.OperationType]string),
+				Deny:         make(map[repository.OperationType]string),
+				AllowLines:   make(map[repository.OperationType]int),
+				AllowColumns: make(map[repository.OperationType]int),
+				DenyLines:    make(map[repository.OperationType]int),
+				DenyColumns:  make(map[repository.OperationType]int),
 			}
 		},
 	}
@@ -104,14 +108,19 @@ func (t *FastTranslator) Translate(ctx context.Context, ruleset *domain.Firestor
 		Errors: make([]string, 0),
 	}
 
+	// Las funciones del service son visibles en todos los matches
+	scope := newFunctionScope(nil, ruleset.Functions)
+
 	// Traducir todos los matches de forma eficiente
 	var securityRules []*repository.SecurityRule
+	var translationErrors []string
 
 	if t.config.ParallelTranslation && len(ruleset.Matches) > 2 {
-		securityRules = t.translateParallel(ctx, ruleset.Matches)
+		securityRules, translationErrors = t.translateParallel(ctx, ruleset.Matches, scope)
 	} else {
-		securityRules = t.translateSequential(ctx, ruleset.Matches)
+		securityRules, translationErrors = t.translateSequential(ctx, ruleset.Matches, scope)
 	}
+	result.Errors = append(result.Errors, translationErrors...)
 
 	// Optimizar reglas si está habilitado
 	if t.config.EnableOptimization && t.optimizer != nil {
@@ -132,9 +141,15 @@ func (t *FastTranslator) Translate(ctx context.Context, ruleset *domain.Firestor
 	return result, nil
 }
 
+// matchTranslation es el resultado de traducir un match block en paralelo
+type matchTranslation struct {
+	rules  []*repository.SecurityRule
+	errors []string
+}
+
 // translateParallel traduce matches en paralelo para máxima velocidad
-func (t *FastTranslator) translateParallel(ctx context.Context, matches []*domain.MatchBlock) []*repository.SecurityRule {
-	resultChan := make(chan []*repository.SecurityRule, len(matches))
+func (t *FastTranslator) translateParallel(ctx context.Context, matches []*domain.MatchBlock, scope *functionScope) ([]*repository.SecurityRule, []string) {
+	resultChan := make(chan matchTranslation, len(matches))
 	var wg sync.WaitGroup
 
 	// Procesar cada match en su propia goroutine
@@ -142,8 +157,8 @@ func (t *FastTranslator) translateParallel(ctx context.Context, matches []*domai
 		wg.Add(1)
 		go func(m *domain.MatchBlock) {
 			defer wg.Done()
-			rules := t.translateMatchBlock(ctx, m, "")
-			resultChan <- rules
+			rules, errors := t.translateMatchBlock(ctx, m, "", scope)
+			resultChan <- matchTranslation{rules: rules, errors: errors}
 		}(match)
 	}
 
@@ -155,31 +170,38 @@ func (t *FastTranslator) translateParallel(ctx context.Context, matches []*domai
 
 	// Recolectar resultados
 	var allRules []*repository.SecurityRule
-	for rules := range resultChan {
-		allRules = append(allRules, rules...)
+	var allErrors []string
+	for translation := range resultChan {
+		allRules = append(allRules, translation.rules...)
+		allErrors = append(allErrors, translation.errors...)
 	}
 
-	return allRules
+	return allRules, allErrors
 }
 
 // translateSequential traduce matches secuencialmente
-func (t *FastTranslator) translateSequential(ctx context.Context, matches []*domain.MatchBlock) []*repository.SecurityRule {
+func (t *FastTranslator) translateSequential(ctx context.Context, matches []*domain.MatchBlock, scope *functionScope) ([]*repository.SecurityRule, []string) {
 	var allRules []*repository.SecurityRule
+	var allErrors []string
 
 	for _, match := range matches {
-		rules := t.translateMatchBlock(ctx, match, "")
+		rules, errors := t.translateMatchBlock(ctx, match, "", scope)
 		allRules = append(allRules, rules...)
+		allErrors = append(allErrors, errors...)
 	}
 
-	return allRules
+	return allRules, allErrors
 }
 
-// translateMatchBlock convierte un MatchBlock a SecurityRules (recursivo optimizado)
-func (t *FastTranslator) translateMatchBlock(ctx context.Context, block *domain.MatchBlock, parentPath string) []*repository.SecurityRule {
+// translateMatchBlock convierte un MatchBlock a SecurityRules (recursivo optimizado).
+// Las funciones del bloque se suman al scope heredado y se expanden en sus condiciones.
+func (t *FastTranslator) translateMatchBlock(ctx context.Context, block *domain.MatchBlock, parentPath string, scope *functionScope) ([]*repository.SecurityRule, []string) {
 	var rules []*repository.SecurityRule
+	var errors []string
 
 	// Construir ruta completa de forma eficiente
 	fullPath := t.buildFullPath(parentPath, block.Path)
+	scope = newFunctionScope(scope, block.Functions)
 
 	// Crear regla principal si tiene declaraciones allow/deny
 	if len(block.Allow) > 0 || len(block.Deny) > 0 {
@@ -187,29 +209,47 @@ func (t *FastTranslator) translateMatchBlock(ctx context.Context, block *domain.
 		rule.Match = fullPath
 		rule.Priority = t.calculatePriority(fullPath, block.Depth)
 		rule.Description = fmt.Sprintf("Auto-generated from match %s", block.Path)
+		rule.Line, rule.Column = block.Line, block.Column
 
 		// Procesar allow statements optimizado
-		t.processAllowStatements(rule, block.Allow)
+		errors = append(errors, t.processAllowStatements(rule, block.Allow, scope)...)
 
 		// Procesar deny statements optimizado
-		t.processDenyStatements(rule, block.Deny)
+		errors = append(errors, t.processDenyStatements(rule, block.Deny, scope)...)
 
 		rules = append(rules, rule)
 	}
 
 	// Procesar bloques anidados recursivamente
 	for _, nested := range block.Nested {
-		nestedRules := t.translateMatchBlock(ctx, nested, fullPath)
+		nestedRules, nestedErrors := t.translateMatchBlock(ctx, nested, fullPath, scope)
 		rules = append(rules, nestedRules...)
+		errors = append(errors, nestedErrors...)
 	}
 
-	return rules
+	return rules, errors
+}
+
+// translateCondition expande las funciones de la condición y la optimiza; los errores
+// indican la línea de la declaración en el archivo de reglas
+func (t *FastTranslator) translateCondition(rule *repository.SecurityRule, condition string, line int, scope *functionScope) (string, error) {
+	expanded, err := expandFunctions(condition, scope, nil)
+	if err != nil {
+		return "", fmt.Errorf("line %d: match %s: %w", line, rule.Match, err)
+	}
+	return t.optimizeCondition(expanded), nil
 }
 
-// processAllowStatements procesa declaraciones allow de forma optimizada
-func (t *FastTranslator) processAllowStatements(rule *repository.SecurityRule, allowStmts []*domain.AllowStatement) {
+// processAllowStatements procesa declaraciones allow de forma optimizada; una condición
+// que no se puede traducir no concede la operación
+func (t *FastTranslator) processAllowStatements(rule *repository.SecurityRule, allowStmts []*domain.AllowStatement, scope *functionScope) []string {
+	var errors []string
 	for _, stmt := range allowStmts {
-		condition := t.optimizeCondition(stmt.Condition)
+		condition, err := t.translateCondition(rule, stmt.Condition, stmt.Line, scope)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
 
 		for _, opStr := range stmt.Operations {
 			// Expandir operaciones compuestas para compatibilidad total con Firestore
@@ -218,16 +258,25 @@ func (t *FastTranslator) processAllowStatements(rule *repository.SecurityRule, a
 			for _, op := range operations {
 				if mappedOp, exists := t.operationMap[op]; exists {
 					rule.Allow[mappedOp] = condition
+					rule.AllowLines[mappedOp] = stmt.Line
+					rule.AllowColumns[mappedOp] = stmt.Column
 				}
 			}
 		}
 	}
+	return errors
 }
 
-// processDenyStatements procesa declaraciones deny de forma optimizada
-func (t *FastTranslator) processDenyStatements(rule *repository.SecurityRule, denyStmts []*domain.DenyStatement) {
+// processDenyStatements procesa declaraciones deny de forma optimizada; una condición
+// que no se puede traducir deniega la operación siempre
+func (t *FastTranslator) processDenyStatements(rule *repository.SecurityRule, denyStmts []*domain.DenyStatement, scope *functionScope) []string {
+	var errors []string
 	for _, stmt := range denyStmts {
-		condition := t.optimizeCondition(stmt.Condition)
+		condition, err := t.translateCondition(rule, stmt.Condition, stmt.Line, scope)
+		if err != nil {
+			errors = append(errors, err.Error())
+			condition = "true"
+		}
 
 		for _, opStr := range stmt.Operations {
 			operations := t.expandOperation(opStr)
@@ -235,10 +284,13 @@ func (t *FastTranslator) processDenyStatements(rule *repository.SecurityRule, de
 			for _, op := range operations {
 				if mappedOp, exists := t.operationMap[op]; exists {
 					rule.Deny[mappedOp] = condition
+					rule.DenyLines[mappedOp] = stmt.Line
+					rule.DenyColumns[mappedOp] = stmt.Column
 				}
 			}
 		}
 	}
+	return errors
 }
 
 // expandOperation expande operaciones compuestas (ej: "read" -> ["read", "list"], "write" -> ["create", "update", "delete"])
@@ -441,6 +493,7 @@ func (t *FastTranslator) getSecurityRule() *repository.SecurityRule {
 	rule.Match = ""
 	rule.Priority = 0
 	rule.Description = ""
+	rule.Line, rule.Column = 0, 0
 
 	// Limpiar maps pero mantener capacidad
 	for k := range rule.Allow {
@@ -449,6 +502,14 @@ func (t *FastTranslator) getSecurityRule() *repository.SecurityRule {
 	for k := range rule.Deny {
 		delete(rule.Deny, k)
 	}
+	for k := range rule.AllowLines {
+		delete(rule.AllowLines, k)
+		delete(rule.AllowColumns, k)
+	}
+	for k := range rule.DenyLines {
+		delete(rule.DenyLines, k)
+		delete(rule.DenyColumns, k)
+	}
 
 	return rule
 }
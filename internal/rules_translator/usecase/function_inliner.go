@@ -0,0 +1,313 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"firestore-clone/internal/rules_translator/domain"
+)
+
+// maxFunctionCallDepth es el límite de llamadas anidadas que impone Firestore
+const maxFunctionCallDepth = 20
+
+// functionScope contiene las funciones visibles en un match block. Como en Firestore,
+// una función declarada en un bloque es visible en ese bloque y en sus anidados, y una
+// declaración interna oculta a la del bloque padre con el mismo nombre.
+type functionScope struct {
+	parent    *functionScope
+	functions map[string]*scopedFunction
+}
+
+// scopedFunction asocia una función con el scope donde fue declarada, que es el que
+// resuelve las llamadas de su cuerpo
+type scopedFunction struct {
+	declaration *domain.FunctionDeclaration
+	scope       *functionScope
+}
+
+// newFunctionScope crea un scope hijo de parent con las funciones declaradas
+func newFunctionScope(parent *functionScope, declarations []*domain.FunctionDeclaration) *functionScope {
+	if len(declarations) == 0 && parent != nil {
+		return parent
+	}
+
+	scope := &functionScope{
+		parent:    parent,
+		functions: make(map[string]*scopedFunction, len(declarations)),
+	}
+	for _, declaration := range declarations {
+		scope.functions[declaration.Name] = &scopedFunction{declaration: declaration, scope: scope}
+	}
+	return scope
+}
+
+// lookup busca una función en el scope y sus padres
+func (s *functionScope) lookup(name string) *scopedFunction {
+	for scope := s; scope != nil; scope = scope.parent {
+		if function, ok := scope.functions[name]; ok {
+			return function
+		}
+	}
+	return nil
+}
+
+// expandFunctions reemplaza las llamadas a funciones de las reglas por su cuerpo, ya que
+// CEL no admite funciones definidas por el usuario. Los argumentos y los let se
+// sustituyen entre paréntesis, de modo que el resultado conserva la precedencia.
+func expandFunctions(expression string, scope *functionScope, stack []string) (string, error) {
+	if scope == nil {
+		return expression, nil
+	}
+
+	return rewriteIdentifiers(expression, func(text, name string, end int) (string, int, error) {
+		open := skipSpaces(text, end)
+		if open >= len(text) || text[open] != '(' {
+			return name, end, nil
+		}
+		function := scope.lookup(name)
+		if function == nil {
+			return name, end, nil // Función integrada como get() o exists()
+		}
+
+		for _, caller := range stack {
+			if caller == name {
+				return "", 0, fmt.Errorf("recursive call to function '%s'", name)
+			}
+		}
+		if len(stack) >= maxFunctionCallDepth {
+			return "", 0, fmt.Errorf("function call depth exceeds %d calling '%s'", maxFunctionCallDepth, name)
+		}
+
+		closing := matchingParen(text, open)
+		if closing < 0 {
+			return "", 0, fmt.Errorf("unclosed call to function '%s'", name)
+		}
+		arguments := splitArguments(text[open+1 : closing])
+		declaration := function.declaration
+		if len(arguments) != len(declaration.Parameters) {
+			return "", 0, fmt.Errorf("function '%s' expects %d arguments, got %d",
+				name, len(declaration.Parameters), len(arguments))
+		}
+
+		bindings := make(map[string]string, len(arguments)+len(declaration.Bindings))
+		for i, argument := range arguments {
+			expanded, err := expandFunctions(argument, scope, stack)
+			if err != nil {
+				return "", 0, err
+			}
+			bindings[declaration.Parameters[i]] = "(" + expanded + ")"
+		}
+		for _, binding := range declaration.Bindings {
+			value, err := substituteBindings(binding.Expression, bindings)
+			if err != nil {
+				return "", 0, err
+			}
+			bindings[binding.Name] = "(" + value + ")"
+		}
+
+		body, err := substituteBindings(declaration.Return, bindings)
+		if err != nil {
+			return "", 0, err
+		}
+		body, err = expandFunctions(body, function.scope, append(stack, name))
+		if err != nil {
+			return "", 0, err
+		}
+		return "(" + body + ")", closing + 1, nil
+	})
+}
+
+// substituteBindings reemplaza los parámetros y variables let por sus valores
+func substituteBindings(expression string, bindings map[string]string) (string, error) {
+	return rewriteIdentifiers(expression, func(text, name string, end int) (string, int, error) {
+		if value, ok := bindings[name]; ok {
+			return value, end, nil
+		}
+		return name, end, nil
+	})
+}
+
+// rewriteIdentifiers recorre una expresión y permite reemplazar cada identificador libre:
+// no cuenta accesos a miembros (x.name), literales de texto ni segmentos de paths, pero
+// sí las interpolaciones $(expr) de los paths. replace recibe el texto y el fin del
+// identificador, y retorna el reemplazo y el offset donde continúa el recorrido.
+func rewriteIdentifiers(expression string, replace func(text, name string, end int) (string, int, error)) (string, error) {
+	var sb strings.Builder
+	operand := false // Si el último token termina un operando, '/' es una división
+
+	for i := 0; i < len(expression); {
+		ch := expression[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := skipString(expression, i)
+			sb.WriteString(expression[i:end])
+			i = end
+			operand = true
+
+		case ch == '/' && !operand:
+			end, err := rewritePath(&sb, expression, i, replace)
+			if err != nil {
+				return "", err
+			}
+			i = end
+			operand = true
+
+		case isIdentifierStart(ch):
+			end := i
+			for end < len(expression) && isIdentifierPart(expression[end]) {
+				end++
+			}
+			name := expression[i:end]
+			if previousNonSpace(expression, i) == '.' {
+				sb.WriteString(name)
+				i = end
+			} else {
+				replacement, next, err := replace(expression, name, end)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteString(replacement)
+				i = next
+			}
+			operand = true
+
+		case ch >= '0' && ch <= '9':
+			sb.WriteByte(ch)
+			i++
+			operand = true
+
+		case ch == ')' || ch == ']' || ch == '}':
+			sb.WriteByte(ch)
+			i++
+			operand = true
+
+		default:
+			sb.WriteByte(ch)
+			i++
+			if ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r' {
+				operand = false
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// rewritePath copia un literal de path como /databases/$(database)/documents/x,
+// recorriendo sus interpolaciones; retorna el offset donde termina el path
+func rewritePath(sb *strings.Builder, expression string, start int, replace func(text, name string, end int) (string, int, error)) (int, error) {
+	i := start
+	for i < len(expression) {
+		ch := expression[i]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ')' || ch == ',' || ch == ';' {
+			break
+		}
+		if ch == '$' && i+1 < len(expression) && expression[i+1] == '(' {
+			closing := matchingParen(expression, i+1)
+			if closing < 0 {
+				return 0, fmt.Errorf("unclosed path interpolation in '%s'", expression[start:])
+			}
+			inner, err := rewriteIdentifiers(expression[i+2:closing], replace)
+			if err != nil {
+				return 0, err
+			}
+			sb.WriteString("$(" + inner + ")")
+			i = closing + 1
+			continue
+		}
+		sb.WriteByte(ch)
+		i++
+	}
+	return i, nil
+}
+
+// matchingParen retorna la posición del ')' que cierra el '(' en open, o -1
+func matchingParen(text string, open int) int {
+	depth := 0
+	for i := open; i < len(text); {
+		switch text[i] {
+		case '\'', '"':
+			i = skipString(text, i)
+			continue
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if text[i] != ')' {
+					return -1
+				}
+				return i
+			}
+		}
+		i++
+	}
+	return -1
+}
+
+// splitArguments separa los argumentos de una llamada por las comas de primer nivel
+func splitArguments(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	var arguments []string
+	depth, start := 0, 0
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '\'', '"':
+			i = skipString(text, i)
+			continue
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				arguments = append(arguments, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+		i++
+	}
+	return append(arguments, strings.TrimSpace(text[start:]))
+}
+
+// skipString retorna el offset siguiente al literal de texto que empieza en start
+func skipString(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] == quote {
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+func skipSpaces(text string, i int) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\n' || text[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+func previousNonSpace(text string, i int) byte {
+	for i--; i >= 0; i-- {
+		if text[i] != ' ' && text[i] != '\t' && text[i] != '\n' && text[i] != '\r' {
+			return text[i]
+		}
+	}
+	return 0
+}
+
+func isIdentifierStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+}
+
+func isIdentifierPart(ch byte) bool {
+	return isIdentifierStart(ch) || (ch >= '0' && ch <= '9')
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/domain"
+)
+
+// AnnotateRulesCoverage anota las líneas del texto de las reglas con la cobertura
+// registrada para las reglas traducidas de ese texto. Cada condición cuenta una vez por
+// operación: "allow read" son las condiciones get y list.
+func AnnotateRulesCoverage(source string, rules []*repository.SecurityRule, coverage *repository.RulesCoverage) *domain.RulesCoverageReport {
+	texts := strings.Split(source, "\n")
+	report := &domain.RulesCoverageReport{
+		ProjectID:  coverage.ProjectID,
+		DatabaseID: coverage.DatabaseID,
+		Lines:      make([]*domain.RulesCoverageLine, len(texts)),
+	}
+	for i, text := range texts {
+		report.Lines[i] = &domain.RulesCoverageLine{Number: i + 1, Text: strings.TrimRight(text, "\r")}
+	}
+
+	for _, rule := range rules {
+		ruleCoverage := coverage.Rules[rule.Match]
+		if line := coverageLine(report, rule.Line); line != nil {
+			line.Kind = domain.CoverageLineMatch
+			if ruleCoverage != nil {
+				line.Hits += ruleCoverage.Hits
+			}
+		}
+		annotateConditions(report, ruleCoverage, domain.CoverageLineDeny, rule.Deny, rule.DenyLines)
+		annotateConditions(report, ruleCoverage, domain.CoverageLineAllow, rule.Allow, rule.AllowLines)
+	}
+
+	if report.Conditions > 0 {
+		report.Percent = math.Round(float64(report.Covered)*1000/float64(report.Conditions)) / 10
+	}
+	return report
+}
+
+// annotateConditions suma a sus líneas la cobertura de las condiciones allow o deny de una regla
+func annotateConditions(report *domain.RulesCoverageReport, ruleCoverage *repository.RuleCoverage, effect string, conditions map[repository.OperationType]string, lines map[repository.OperationType]int) {
+	operations := make([]string, 0, len(conditions))
+	for operation := range conditions {
+		operations = append(operations, string(operation))
+	}
+	sort.Strings(operations)
+
+	for _, operation := range operations {
+		report.Conditions++
+		line := coverageLine(report, lines[repository.OperationType(operation)])
+		if line != nil {
+			line.Kind = effect
+		}
+
+		var condition *repository.ConditionCoverage
+		if ruleCoverage != nil {
+			condition = ruleCoverage.Conditions[repository.ConditionKey(effect, repository.OperationType(operation))]
+		}
+		if condition == nil || condition.Hits() == 0 {
+			if line != nil {
+				line.Uncovered = append(line.Uncovered, operation)
+			}
+			continue
+		}
+
+		report.Covered++
+		if line != nil {
+			line.Hits += condition.Hits()
+			line.True += condition.True
+			line.False += condition.False
+			line.Error += condition.Error
+		}
+	}
+}
+
+// coverageLine devuelve una línea del informe, o nil si la regla no viene del texto
+func coverageLine(report *domain.RulesCoverageReport, number int) *domain.RulesCoverageLine {
+	if number < 1 || number > len(report.Lines) {
+		return nil
+	}
+	return report.Lines[number-1]
+}
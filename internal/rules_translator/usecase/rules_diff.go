@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"sort"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/domain"
+)
+
+// ruleConditionKey identifica una condición por match, efecto y operación
+type ruleConditionKey struct {
+	match     string
+	effect    string
+	operation string
+}
+
+// DiffRules compara las reglas actuales con las propuestas condición por condición. Los
+// cambios se ordenan por match, efecto y operación para que la salida sea estable.
+func DiffRules(current, proposed []*repository.SecurityRule) *domain.RulesDiff {
+	before := indexConditions(current)
+	after := indexConditions(proposed)
+	diff := &domain.RulesDiff{}
+
+	for key, condition := range after {
+		previous, existed := before[key]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, newRuleChange(key, "", condition))
+		case previous != condition:
+			diff.Changed = append(diff.Changed, newRuleChange(key, previous, condition))
+		default:
+			diff.Unchanged++
+		}
+	}
+	for key, condition := range before {
+		if _, exists := after[key]; !exists {
+			diff.Removed = append(diff.Removed, newRuleChange(key, condition, ""))
+		}
+	}
+
+	sortRuleChanges(diff.Added)
+	sortRuleChanges(diff.Removed)
+	sortRuleChanges(diff.Changed)
+	return diff
+}
+
+// indexConditions indexa las condiciones allow y deny de un conjunto de reglas
+func indexConditions(rules []*repository.SecurityRule) map[ruleConditionKey]string {
+	conditions := make(map[ruleConditionKey]string)
+	for _, rule := range rules {
+		for op, condition := range rule.Allow {
+			conditions[ruleConditionKey{match: rule.Match, effect: "allow", operation: string(op)}] = condition
+		}
+		for op, condition := range rule.Deny {
+			conditions[ruleConditionKey{match: rule.Match, effect: "deny", operation: string(op)}] = condition
+		}
+	}
+	return conditions
+}
+
+func newRuleChange(key ruleConditionKey, before, after string) *domain.RuleChange {
+	return &domain.RuleChange{
+		Match:     key.match,
+		Effect:    key.effect,
+		Operation: key.operation,
+		Before:    before,
+		After:     after,
+	}
+}
+
+func sortRuleChanges(changes []*domain.RuleChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Match != b.Match {
+			return a.Match < b.Match
+		}
+		if a.Effect != b.Effect {
+			return a.Effect < b.Effect
+		}
+		return a.Operation < b.Operation
+	})
+}
@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"firestore-clone/internal/firestore/domain/repository"
+	"firestore-clone/internal/rules_translator/domain"
+)
+
+// testDatabaseID es la base de datos de las peticiones simuladas; las reglas la ven
+// como el wildcard {database}
+const testDatabaseID = "(default)"
+
+// RulesTestRunner ejecuta suites de pruebas de reglas en memoria: traduce las reglas y
+// evalúa cada caso con el simulador, con los documentos sembrados de la suite y del caso
+// como únicos documentos existentes
+type RulesTestRunner struct {
+	parser     domain.RulesParser
+	translator domain.RulesTranslator
+	simulator  repository.RulesSimulator
+}
+
+// NewRulesTestRunner crea el runner. El simulador debe leer los documentos no sembrados
+// de un almacén en memoria, nunca de la base de datos.
+func NewRulesTestRunner(parser domain.RulesParser, translator domain.RulesTranslator, simulator repository.RulesSimulator) *RulesTestRunner {
+	return &RulesTestRunner{parser: parser, translator: translator, simulator: simulator}
+}
+
+var _ domain.RulesTestRunner = (*RulesTestRunner)(nil)
+
+// Run traduce las reglas y ejecuta los casos de la suite. Un error en las reglas aborta
+// la ejecución; un caso inválido se reporta como error del caso. Con Coverage el informe
+// anota el texto de las reglas con la cobertura de los casos.
+func (r *RulesTestRunner) Run(ctx context.Context, source string, suite *domain.RulesTestSuite) (*domain.RulesTestReport, error) {
+	startTime := time.Now()
+	rules, err := r.translate(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.RulesTestReport{
+		Name:    suite.Name,
+		Results: make([]*domain.RulesTestResult, 0, len(suite.Tests)),
+	}
+	if report.Name == "" {
+		report.Name = "rules"
+	}
+	var coverage *repository.RulesCoverage
+	if suite.Coverage {
+		coverage = repository.NewRulesCoverage("", "")
+	}
+	for i, testCase := range suite.Tests {
+		result := r.runCase(ctx, rules, suite, testCase, i, coverage)
+		report.Results = append(report.Results, result)
+		report.Total++
+		switch {
+		case result.Error != "":
+			report.Errors++
+		case result.Passed:
+			report.Passed++
+		default:
+			report.Failed++
+		}
+	}
+	if coverage != nil {
+		report.Coverage = AnnotateRulesCoverage(source, rules, coverage)
+	}
+	report.Duration = time.Since(startTime)
+	return report, nil
+}
+
+// translate parsea y traduce el texto de las reglas
+func (r *RulesTestRunner) translate(ctx context.Context, source string) ([]*repository.SecurityRule, error) {
+	parseResult, err := r.parser.ParseString(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	translation, err := r.translator.Translate(ctx, parseResult.Ruleset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate rules: %w", err)
+	}
+	if len(translation.Errors) > 0 {
+		return nil, fmt.Errorf("failed to translate rules: %v", translation.Errors)
+	}
+	rules, ok := translation.Rules.([]*repository.SecurityRule)
+	if !ok {
+		return nil, fmt.Errorf("unexpected translated rules type %T", translation.Rules)
+	}
+	return rules, nil
+}
+
+// runCase evalúa un caso, sumando su traza a la cobertura si se registra. El documento
+// sembrado en la ruta del caso es resource.
+func (r *RulesTestRunner) runCase(ctx context.Context, rules []*repository.SecurityRule, suite *domain.RulesTestSuite, testCase *domain.RulesTestCase, index int, coverage *repository.RulesCoverage) *domain.RulesTestResult {
+	startTime := time.Now()
+	result := &domain.RulesTestResult{
+		Name:      testCase.Name,
+		Operation: testCase.Operation,
+		Path:      testCase.Path,
+		Expected:  testCase.Expect,
+	}
+	if result.Name == "" {
+		result.Name = fmt.Sprintf("test %d: %s %s", index+1, testCase.Operation, testCase.Path)
+	}
+	defer func() { result.Duration = time.Since(startTime) }()
+
+	if err := validateTestCase(testCase); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	documents := make(map[string]map[string]interface{}, len(suite.Documents)+len(testCase.Documents))
+	for path, data := range suite.Documents {
+		documents[strings.Trim(path, "/")] = data
+	}
+	for path, data := range testCase.Documents {
+		documents[strings.Trim(path, "/")] = data
+	}
+
+	simulation, err := r.simulator.SimulateAccess(ctx, &repository.SimulationRequest{
+		DatabaseID:  testDatabaseID,
+		Operation:   repository.OperationType(testCase.Operation),
+		Path:        testCase.Path,
+		Auth:        testCase.Auth,
+		RequestData: testCase.Data,
+		Resource:    documents[strings.Trim(testCase.Path, "/")],
+		Documents:   documents,
+		Rules:       rules,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if coverage != nil {
+		coverage.AddSimulation(simulation)
+	}
+
+	result.Actual = domain.RulesTestDeny
+	if simulation.Allowed {
+		result.Actual = domain.RulesTestAllow
+	}
+	result.Passed = result.Actual == result.Expected
+	result.Reason = simulation.Reason
+	return result
+}
+
+// validateTestCase comprueba la operación, la ruta y la decisión esperada de un caso
+func validateTestCase(testCase *domain.RulesTestCase) error {
+	switch repository.OperationType(testCase.Operation) {
+	case repository.OperationRead, repository.OperationWrite, repository.OperationCreate,
+		repository.OperationUpdate, repository.OperationDelete, repository.OperationList:
+	default:
+		return fmt.Errorf("invalid operation %q", testCase.Operation)
+	}
+	if strings.Trim(testCase.Path, "/") == "" {
+		return fmt.Errorf("path is required")
+	}
+	if testCase.Expect != domain.RulesTestAllow && testCase.Expect != domain.RulesTestDeny {
+		return fmt.Errorf("expect must be %q or %q, got %q", domain.RulesTestAllow, domain.RulesTestDeny, testCase.Expect)
+	}
+	return nil
+}
@@ -28,6 +28,9 @@ const (
 	TokenKey  = contextKey("token")
 	ClaimsKey = contextKey("claims")
 
+	// Write context keys
+	CommitTimeKey = contextKey("commitTime") // Shared by the writes committed together
+
 	// Component context keys
 	ComponentKey = contextKey("component")
 	OperationKey = contextKey("operation")
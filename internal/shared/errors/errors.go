@@ -221,7 +221,7 @@ func IsNotFound(err error) bool {
 	if appErr, ok := err.(*AppError); ok {
 		return appErr.Type == ErrorTypeNotFound
 	}
-	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrDocumentNotFound) || errors.Is(err, ErrUserNotFound)
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrDocumentNotFound) || errors.Is(err, ErrCollectionNotFound) || errors.Is(err, ErrUserNotFound)
 }
 
 // IsValidation checks if an error is a validation error
@@ -152,7 +152,7 @@ func (eb *EventBus) executeHandler(ctx context.Context, event Event, handler Han
 			time.Sleep(eb.config.RetryDelay)
 		}
 
-		if err := handler(ctx, event); err != nil {
+		if err := invokeHandler(ctx, event, handler); err != nil {
 			lastErr = err
 			eb.logger.Errorf("Handler %d failed for event %s: %v", handlerIndex, event.Type(), err)
 			continue
@@ -169,6 +169,17 @@ func (eb *EventBus) executeHandler(ctx context.Context, event Event, handler Han
 	return fmt.Errorf("handler failed after %d attempts: %w", eb.config.MaxRetries+1, lastErr)
 }
 
+// invokeHandler calls a handler once, turning a panic into an error so that one faulty
+// handler cannot crash the publisher or stop the other handlers
+func invokeHandler(ctx context.Context, event Event, handler Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, event)
+}
+
 // PublishAndForget publishes an event asynchronously without waiting for completion
 func (eb *EventBus) PublishAndForget(ctx context.Context, event Event) {
 	go func() {
@@ -255,9 +266,11 @@ const (
 	EventTypeDocumentCreated       = "document.created"
 	EventTypeDocumentUpdated       = "document.updated"
 	EventTypeDocumentDeleted       = "document.deleted"
+	EventTypeDocumentWritten       = "document.written"
 	EventTypeUserAuthenticated     = "user.authenticated"
 	EventTypeUserLoggedOut         = "user.logged_out"
 	EventTypeSecurityRuleViolation = "security.rule_violation"
+	EventTypeSecurityRulesChanged  = "security.rules_changed"
 )
 
 // noopLogger implements logger.Logger but does nothing (for nil logger)
@@ -100,3 +100,21 @@ func TestEventBus_PublishAndForget(t *testing.T) {
 		t.Fatal("timeout waiting for PublishAndForget")
 	}
 }
+
+func TestEventBus_RetriesAndRecoversPanics(t *testing.T) {
+	bus := NewEventBusWithConfig(nil, BusConfig{MaxRetries: 2, RetryDelay: time.Millisecond})
+	calls := 0
+	bus.Subscribe("flaky", func(ctx context.Context, event Event) error {
+		calls++
+		if calls < 3 {
+			panic("boom")
+		}
+		return nil
+	})
+	assert.NoError(t, bus.Publish(context.Background(), &DummyEvent{typeStr: "flaky", timestamp: time.Now()}))
+	assert.Equal(t, 3, calls)
+
+	bus.Subscribe("broken", func(ctx context.Context, event Event) error { panic("always") })
+	err := bus.Publish(context.Background(), &DummyEvent{typeStr: "broken", timestamp: time.Now()})
+	assert.ErrorContains(t, err, "handler panicked: always")
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"errors"
+	"time"
 
 	"firestore-clone/internal/shared/contextkeys"
 )
@@ -164,8 +165,22 @@ func WithOperation(ctx context.Context, operation string) context.Context {
 	return context.WithValue(ctx, contextkeys.OperationKey, operation)
 }
 
+// WithCommitTime adds the commit time of the writes made with the context. Storage stamps
+// those writes with it instead of the clock, so writes committed together share one time.
+func WithCommitTime(ctx context.Context, commitTime time.Time) context.Context {
+	return context.WithValue(ctx, contextkeys.CommitTimeKey, commitTime)
+}
+
 // Optional getters that return default values instead of errors
 
+// GetCommitTimeOrNow retrieves the commit time from context or returns the current time
+func GetCommitTimeOrNow(ctx context.Context) time.Time {
+	if commitTime, ok := ctx.Value(contextkeys.CommitTimeKey).(time.Time); ok && !commitTime.IsZero() {
+		return commitTime
+	}
+	return time.Now()
+}
+
 // GetTenantIDOrDefault retrieves the tenant ID from context or returns a default value
 func GetTenantIDOrDefault(ctx context.Context, def string) string {
 	if v, err := GetTenantIDFromContext(ctx); err == nil {